@@ -27,9 +27,13 @@ package main
 import (
 	"flag"
 	"l2/lacp/asicdMgr"
+	"l2/lacp/protocol/lacp"
 	"l2/lacp/protocol/utils"
 	"l2/lacp/rpc"
 	"l2/lacp/server"
+	"os"
+	"os/signal"
+	"syscall"
 	"utils/asicdClient"
 	"utils/commonDefs"
 	"utils/keepalive"
@@ -48,9 +52,15 @@ func main() {
 		path = path + "/"
 	}
 	clientInfoFile := path + "clients.json"
+	aggKeyFile := path + "lacpd_aggkeys.json"
 
 	logger, _ := logging.NewLogger("lacpd", "LA", true)
 	utils.SetLaLogger(logger)
+
+	// restore the aggregator keys allocated prior to restart
+	if err = lacp.LaAggKeyDbInit(aggKeyFile); err != nil {
+		logger.Err("Unable to restore Aggregator Keys " + err.Error())
+	}
 	laServer := server.NewLAServer(logger)
 
 	// lets setup north bound notifications
@@ -69,6 +79,18 @@ func main() {
 	go keepalive.InitKeepAlive("lacpd", path)
 
 	laServer.InitServer()
+
+	// an orderly shutdown sends an OAM dying gasp so peers don't have
+	// to wait for the lost link timer
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigChan
+		logger.Info("Shutting down LACP daemon")
+		laServer.Shutdown()
+		os.Exit(0)
+	}()
+
 	confIface := rpc.NewLACPDServiceHandler(laServer)
 	logger.Info("Starting LACP Thrift daemon")
 	rpc.StartServer(utils.GetLaLogger(), confIface, *paramsDir)
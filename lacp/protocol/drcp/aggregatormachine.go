@@ -145,7 +145,13 @@ func (am *AMachine) DrcpAMachineDRNIPortUpdate(m fsm.Machine, data interface{})
 // DrcpAMachinePSPortUpdate function to be called after
 // State transition to PS_PORT_UPDATE
 func (am *AMachine) DrcpAMachinePSPortUpdate(m fsm.Machine, data interface{}) fsm.State {
+	dr := am.dr
+	prev := dr.DrniPortalSystemPortConversation
 	am.updatePortalSystemPortConversation()
+	// aggregator port of these conversations has moved
+	dr.flushConversationFdb(AMachineModuleStr,
+		changedConversations(&prev, &dr.DrniPortalSystemPortConversation),
+		dr.forwardingIfIndexList())
 
 	// next State
 	return AmStateDRNIPortUpdate
@@ -300,6 +306,29 @@ func (am *AMachine) setPortConversation() {
 func (am *AMachine) updatePortalSystemPortConversation() {
 	dr := am.dr
 
+	if dr.DrniThreeSystemPortal {
+		// This function sets the Drni_Portal_System_Port_Conversation to the result of the logical
+		// AND operation between, the Boolean vector constructed from the
+		// Drni_Port_Conversation, by setting to FALSE all the indexed Port Conversation ID
+		// entries that are associated with other Portal Systems in the Portal, and the Boolean vector
+		// constructed from the Ipp_Other_Port_Conversation_Portal_System, by setting to FALSE
+		// all the indexed Port Conversation ID entries that are associated with other Portal Systems
+		// in the Portal.
+		var home [MAX_CONVERSATION_IDS]bool
+		for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+			home[i] = dr.portConversationPortalSystem(i) == dr.DrniPortalSystemNumber
+			for _, ipp := range dr.Ipplinks {
+				// 0 means the neighbor has not provided any info for this conversation
+				if ipp.IppOtherPortConversationPortalSystem[i] != 0 &&
+					ipp.IppOtherPortConversationPortalSystem[i] != dr.DrniPortalSystemNumber {
+					home[i] = false
+				}
+			}
+		}
+		dr.DrniPortalSystemPortConversation = home
+		return
+	}
+
 	for _, ipp := range dr.Ipplinks {
 		if ipp.DifferPortDigest &&
 			!dr.DrniThreeSystemPortal {
@@ -313,16 +342,6 @@ func (am *AMachine) updatePortalSystemPortConversation() {
 				dr.DrniPortalSystemPortConversation[i+6] = ipp.DrniNeighborPortConversation[i]>>1&0x1 == 1
 				dr.DrniPortalSystemPortConversation[i+7] = ipp.DrniNeighborPortConversation[i]>>0&0x1 == 1
 			}
-		} else {
-
-			// TODO not sure this a applicable as OTHER should always be false
-			// This function sets the Drni_Portal_System_Port_Conversation to the result of the logical
-			// AND operation between, the Boolean vector constructed from the
-			// Drni_Port_Conversation, by setting to FALSE all the indexed Port Conversation ID
-			// entries that are associated with other Portal Systems in the Portal, and the Boolean vector
-			// constructed from the Ipp_Other_Port_Conversation_Portal_System, by setting to FALSE
-			// all the indexed Port Conversation ID entries that are associated with other Portal Systems
-			// in the Portal.
 		}
 	}
 }
@@ -31,11 +31,16 @@ import (
 	"l2/lacp/protocol/lacp"
 	"l2/lacp/protocol/utils"
 	"net"
+	"strings"
 )
 
 const (
 	DRNI_PORTAL_SYSTEM_ID_MIN = 1
-	DRNI_PORTAL_SYSTEM_ID_MAX = 2 // only support two portal system
+	DRNI_PORTAL_SYSTEM_ID_MAX = 3
+	// two portal system only uses system numbers 1 and 2
+	DRNI_2P_PORTAL_SYSTEM_ID_MAX = 2
+	// three portal system is a ring, each system has an IPP to each neighbor
+	DRNI_3P_MAX_IPP_LINKS = 2
 )
 
 const DRCPConfigModuleStr = "DRCP Config"
@@ -58,10 +63,18 @@ type DistributedRelayConfig struct {
 	DrniNeighborAdminPortAlgorithm         string
 	DrniNeighborAdminDRCPState             string
 	DrniEncapMethod                        string
-	DrniIPLEncapMap                        [16]uint32
-	DrniNetEncapMap                        [16]uint32
+	DrniIPLEncapMap                        map[uint32]uint32
+	DrniNetEncapMap                        map[uint32]uint32
 	DrniPortConversationControl            bool
 	DrniIntraPortalPortProtocolDA          string
+	DrniEcmpFlowHashFields                 uint32
+	DrniServiceIdList                      []uint32 // I-SID or TE-SID service identifiers
+	DrniKeepaliveSourceIp                  string
+	DrniKeepaliveDestIp                    string
+	DrniKeepaliveUdpPort                   uint16
+	DrniKeepaliveInterval                  uint32
+	DrniKeepalivePriority                  uint16
+	DrniSplitBrainPolicy                   string
 }
 
 // Conversations are typically related to the various service types to which
@@ -81,6 +94,7 @@ type DRConversationConfig struct {
 	Svlan      uint16
 	Bvid       uint16
 	Psuedowire uint32
+	Tesid      uint32
 	PortList   []int32
 }
 
@@ -96,23 +110,41 @@ func (d *DistributedRelayConfig) GetKey() string {
 	return d.DrniName
 }
 
-// DistributedRelayConfigCreateCheck
+// DistributedRelayConfigCreateCheck will validate that the aggregator is not
+// already used by another distributed relay
 func DistributedRelayConfigCreateCheck(drniname string, aggregatorid uint32) error {
-	if _, ok := ConfigDrMap[drniname]; ok {
-
-		for name, aggid := range ConfigDrMap {
-			if drniname != name {
-				if aggregatorid == aggid {
-					return errors.New(fmt.Sprintf("ERROR Aggregator %d already associated with Distributed Relay %s", aggregatorid, name))
-				}
+	for name, aggid := range ConfigDrMap {
+		if drniname != name {
+			if aggregatorid == aggid {
+				return errors.New(fmt.Sprintf("ERROR Aggregator %d already associated with Distributed Relay %s", aggregatorid, name))
 			}
 		}
-
-		ConfigDrMap[drniname] = aggregatorid
 	}
 	return nil
 }
 
+// DistributedRelayConfigSave will record the config once it has passed all
+// checks, the config is kept regardless of the lacp global state
+func DistributedRelayConfigSave(drniname string, aggregatorid uint32) {
+	ConfigDrMap[drniname] = aggregatorid
+}
+
+// DistributedRelayConfigGetNext will iterate over the configured distributed
+// relays in name order, returns the name and aggregator id
+func DistributedRelayConfigGetNext(drniname string) (string, uint32, bool) {
+	nextname := ""
+	for name := range ConfigDrMap {
+		if name > drniname &&
+			(nextname == "" || name < nextname) {
+			nextname = name
+		}
+	}
+	if nextname == "" {
+		return "", 0, false
+	}
+	return nextname, ConfigDrMap[nextname], true
+}
+
 // DistributedRelayConfigParamCheck will validate the config from the user after it has
 // been translated to something the Lacp module expects.  Thus if translation
 // layer fails it should produce an invalid value.  The error returned
@@ -125,12 +157,14 @@ func DistributedRelayConfigParamCheck(mlag *DistributedRelayConfig) error {
 	}
 
 	invalidlinkcnt := 0
+	validlinkcnt := 0
 	for _, ippid := range mlag.DrniIntraPortalLinkList {
 		portid := ippid & 0xffff
 		if portid > 0 {
 			if _, ok := utils.PortConfigMap[int32(portid)]; !ok {
 				return errors.New(fmt.Sprintln("ERROR Invalid Intra Portal Link Port Id supplied", portid, utils.PortConfigMap))
 			}
+			validlinkcnt++
 		} else {
 			invalidlinkcnt++
 		}
@@ -140,12 +174,27 @@ func DistributedRelayConfigParamCheck(mlag *DistributedRelayConfig) error {
 	}
 
 	if mlag.DrniThreePortalSystem {
-		return errors.New(fmt.Sprintln("ERROR Only support a 2 Portal System"))
+		if validlinkcnt > DRNI_3P_MAX_IPP_LINKS {
+			return errors.New(fmt.Sprintln("ERROR Invalid Intra Portal Link, 3 Portal System supports at most", DRNI_3P_MAX_IPP_LINKS, "links"))
+		}
+		if mlag.DrniPortalSystemNumber < DRNI_PORTAL_SYSTEM_ID_MIN ||
+			mlag.DrniPortalSystemNumber > DRNI_PORTAL_SYSTEM_ID_MAX {
+			return errors.New(fmt.Sprintln("ERROR Invalid Portal System Number must be between 1 and ", DRNI_PORTAL_SYSTEM_ID_MAX))
+		}
+	} else {
+		if mlag.DrniPortalSystemNumber < DRNI_PORTAL_SYSTEM_ID_MIN ||
+			mlag.DrniPortalSystemNumber > DRNI_2P_PORTAL_SYSTEM_ID_MAX {
+			return errors.New(fmt.Sprintln("ERROR Invalid Portal System Number must be between 1 and ", DRNI_2P_PORTAL_SYSTEM_ID_MAX))
+		}
 	}
 
-	if mlag.DrniPortalSystemNumber < DRNI_PORTAL_SYSTEM_ID_MIN ||
-		mlag.DrniPortalSystemNumber > DRNI_PORTAL_SYSTEM_ID_MAX {
-		return errors.New(fmt.Sprintln("ERROR Invalid Portal System Number must be between 1 and ", DRNI_PORTAL_SYSTEM_ID_MAX))
+	maxPortalSystemNumber := uint8(DRNI_2P_PORTAL_SYSTEM_ID_MAX)
+	if mlag.DrniThreePortalSystem {
+		maxPortalSystemNumber = DRNI_PORTAL_SYSTEM_ID_MAX
+	}
+	err = validateDrniConvAdminGateway(mlag.DrniConvAdminGateway, maxPortalSystemNumber)
+	if err != nil {
+		return err
 	}
 
 	validPortGatewayAlgorithms := map[string]bool{
@@ -173,17 +222,68 @@ func DistributedRelayConfigParamCheck(mlag *DistributedRelayConfig) error {
 		return errors.New(fmt.Sprintln("ERROR Invalid Neighbor Port Algorithm supplied must be in the format 00:80:C2:XX where XX is 1-5 the value of the algorithm ", mlag.DrniNeighborAdminPortAlgorithm))
 	}
 
+	if mlag.DrniEcmpFlowHashFields&^ECMP_FLOW_HASH_FIELDS_ALL != 0 {
+		return errors.New(fmt.Sprintf("ERROR Invalid ECMP Flow Hash Fields 0x%x supported fields 0x%x", mlag.DrniEcmpFlowHashFields, ECMP_FLOW_HASH_FIELDS_ALL))
+	}
+
+	// service identifiers only apply to the I-SID and TE-SID algorithms
+	gatewayAlgorithm := convertDrniGatewayAlgorithm(mlag.DrniGatewayAlgorithm)
+	if len(mlag.DrniServiceIdList) > 0 &&
+		gatewayAlgorithm != GATEWAY_ALGORITHM_ISID &&
+		gatewayAlgorithm != GATEWAY_ALGORITHM_TE_SID {
+		return errors.New(fmt.Sprintln("ERROR Service Id List is only valid with the I-SID or TE-SID Gateway Algorithm", mlag.DrniGatewayAlgorithm))
+	}
+	for _, id := range mlag.DrniServiceIdList {
+		cfg := DRConversationConfig{
+			Idtype: gatewayAlgorithm,
+			Isid:   id,
+			Tesid:  id,
+		}
+		if _, err = cfg.GetConversationId(); err != nil {
+			return err
+		}
+	}
+
 	validEncapStrings := map[string]bool{
 		"00:80:C2:00": true, // seperate physical or lag link
 		"00:80:C2:01": true, // shared by time
 		"00:80:C2:02": true, // shared by tag
+		"00:80:C2:03": true, // shared by I-TAG (I-SID)
+		"00:80:C2:04": true, // shared by B-VLAN (B-VID)
 		"00-80-C2-00": true, // seperate physical or lag link
 		"00-80-C2-01": true, // shared by time
 		"00-80-C2-02": true, // shared by tag
+		"00-80-C2-03": true, // shared by I-TAG (I-SID)
+		"00-80-C2-04": true, // shared by B-VLAN (B-VID)
 	}
 
 	if _, ok := validEncapStrings[mlag.DrniEncapMethod]; !ok {
-		return errors.New(fmt.Sprintln("ERROR Invalid Encap Method supplied must be in the format 00:80:C2:XX where XX is 0-2 the value of the encap method ", mlag.DrniEncapMethod))
+		return errors.New(fmt.Sprintln("ERROR Invalid Encap Method supplied must be in the format 00:80:C2:XX where XX is 0-4 the value of the encap method ", mlag.DrniEncapMethod))
+	}
+
+	// sharing by tag the IPL frames of each gateway conversation are
+	// translated to the identifier found in the IPL Encap Map
+	var maxEncapId uint32
+	switch strings.Replace(mlag.DrniEncapMethod, "-", ":", -1) {
+	case "00:80:C2:02", "00:80:C2:04":
+		// C-VID/S-VID or B-VID
+		maxEncapId = 4094
+	case "00:80:C2:03":
+		// I-SID
+		maxEncapId = 0xffffff
+	}
+	if maxEncapId != 0 {
+		if len(mlag.DrniIPLEncapMap) == 0 {
+			return errors.New(fmt.Sprintln("ERROR IPL Encap Map must be supplied when IPL is shared by tag, encap method", mlag.DrniEncapMethod))
+		}
+		err = validateDrniEncapMap("IPL", mlag.DrniIPLEncapMap, maxEncapId)
+		if err != nil {
+			return err
+		}
+		err = validateDrniEncapMap("Net", mlag.DrniNetEncapMap, maxEncapId)
+		if err != nil {
+			return err
+		}
 	}
 
 	_, err = net.ParseMAC(mlag.DrniIntraPortalPortProtocolDA)
@@ -201,6 +301,34 @@ func DistributedRelayConfigParamCheck(mlag *DistributedRelayConfig) error {
 		return errors.New(fmt.Sprintln("ERROR Invalid Port Protocol DA only support 01:80:C2:00:00:03 rcvd: ", mlag.DrniIntraPortalPortProtocolDA))
 	}
 
+	// optional out of band keepalive between the portal systems
+	if mlag.DrniKeepaliveDestIp != "" {
+		if mlag.DrniThreePortalSystem {
+			return errors.New("ERROR Peer Keepalive only supported in a 2 Portal System")
+		}
+		if net.ParseIP(mlag.DrniKeepaliveSourceIp) == nil {
+			return errors.New(fmt.Sprintln("ERROR Invalid Peer Keepalive Source IP rcvd: ", mlag.DrniKeepaliveSourceIp))
+		}
+		if net.ParseIP(mlag.DrniKeepaliveDestIp) == nil {
+			return errors.New(fmt.Sprintln("ERROR Invalid Peer Keepalive Destination IP rcvd: ", mlag.DrniKeepaliveDestIp))
+		}
+		if mlag.DrniKeepaliveInterval != 0 &&
+			(mlag.DrniKeepaliveInterval < DRNI_KEEPALIVE_INTERVAL_MIN ||
+				mlag.DrniKeepaliveInterval > DRNI_KEEPALIVE_INTERVAL_MAX) {
+			return errors.New(fmt.Sprintf("ERROR Invalid Peer Keepalive Interval %d must be between %d and %d msec", mlag.DrniKeepaliveInterval, DRNI_KEEPALIVE_INTERVAL_MIN, DRNI_KEEPALIVE_INTERVAL_MAX))
+		}
+	}
+
+	validSplitBrainPolicy := map[string]bool{
+		DRNI_SPLIT_BRAIN_POLICY_NONE:                true,
+		DRNI_SPLIT_BRAIN_POLICY_LOWER_PRIORITY_DOWN: true,
+		DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN:            true,
+	}
+	if _, ok := validSplitBrainPolicy[mlag.DrniSplitBrainPolicy]; !ok &&
+		mlag.DrniSplitBrainPolicy != "" {
+		return errors.New(fmt.Sprintln("ERROR Invalid Split Brain Policy must be none, lower-priority-down or all-down rcvd: ", mlag.DrniSplitBrainPolicy))
+	}
+
 	// only L2 Aggregator supported with MLAG
 	var a *lacp.LaAggregator
 	if lacp.LaFindAggById(int(mlag.DrniAggregator), &a) {
@@ -212,6 +340,51 @@ func DistributedRelayConfigParamCheck(mlag *DistributedRelayConfig) error {
 	return nil
 }
 
+// validateDrniEncapMap will validate that each gateway conversation id is
+// mapped to a unique identifier within the range of the encap method
+func validateDrniEncapMap(name string, encapMap map[uint32]uint32, maxEncapId uint32) error {
+	usedIds := make(map[uint32]uint32)
+	for cid, id := range encapMap {
+		if cid >= MAX_CONVERSATION_IDS {
+			return errors.New(fmt.Sprintf("ERROR Invalid %s Encap Map Conversation Id %d must be less than %d", name, cid, MAX_CONVERSATION_IDS))
+		}
+		if id == 0 || id > maxEncapId {
+			return errors.New(fmt.Sprintf("ERROR Invalid %s Encap Map identifier %d for Conversation Id %d must be between 1 and %d", name, id, cid, maxEncapId))
+		}
+		if othercid, ok := usedIds[id]; ok {
+			return errors.New(fmt.Sprintf("ERROR Invalid %s Encap Map identifier %d used by Conversation Id %d and %d", name, id, othercid, cid))
+		}
+		usedIds[id] = cid
+	}
+	return nil
+}
+
+// validateDrniConvAdminGateway will validate that each conversation gateway
+// list is in priority order of valid portal system numbers, unused entries
+// must be 0 and follow the used entries
+func validateDrniConvAdminGateway(convAdminGateway [MAX_CONVERSATION_IDS][3]uint8, maxPortalSystemNumber uint8) error {
+	for cid, data := range convAdminGateway {
+		var usedSystems [DRNI_PORTAL_SYSTEM_ID_MAX + 1]bool
+		for i, sysnum := range data {
+			if sysnum == 0 {
+				if i+1 < len(data) && data[i+1] != 0 {
+					return errors.New(fmt.Sprintf("ERROR Invalid Conversation Admin Gateway list %v for Conversation Id %d must not contain gaps", data, cid))
+				}
+				continue
+			}
+			if sysnum < DRNI_PORTAL_SYSTEM_ID_MIN ||
+				sysnum > maxPortalSystemNumber {
+				return errors.New(fmt.Sprintf("ERROR Invalid Conversation Admin Gateway portal system %d for Conversation Id %d must be between %d and %d", sysnum, cid, DRNI_PORTAL_SYSTEM_ID_MIN, maxPortalSystemNumber))
+			}
+			if usedSystems[sysnum] {
+				return errors.New(fmt.Sprintf("ERROR Invalid Conversation Admin Gateway list %v for Conversation Id %d portal system %d listed more than once", data, cid, sysnum))
+			}
+			usedSystems[sysnum] = true
+		}
+	}
+	return nil
+}
+
 //DistributedRelayConfigDeleteCheck
 func DistributedRelayConfigDeleteCheck(drniname string) error {
 	// nothing to check
@@ -66,6 +66,38 @@ type MyTestMock struct {
 	asicdmock.MockAsicdClientMgr
 }
 
+// DrniTestMock implements the DRNI extension of the asicd client, tests
+// override the operations they need to verify
+type DrniTestMock struct{}
+
+func (m *DrniTestMock) DrniFlowHashFieldsSet(aggId int32, fields uint32) error {
+	return nil
+}
+
+func (m *DrniTestMock) IppFlowHashConversationSet(cid uint16, ippid int32) error {
+	return nil
+}
+
+func (m *DrniTestMock) IppFlowHashConversationClear(cid uint16, ippid int32) error {
+	return nil
+}
+
+func (m *DrniTestMock) IppEncapTranslationSet(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error {
+	return nil
+}
+
+func (m *DrniTestMock) IppEncapTranslationClear(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error {
+	return nil
+}
+
+func (m *DrniTestMock) FdbFlushByConversation(cidList []uint16, ifindexList []int32) error {
+	return nil
+}
+
+func (m *DrniTestMock) FdbFlushByPort(ifindex int32) error {
+	return nil
+}
+
 func (m *MyTestMock) GetBulkVlan(curMark, count int) (*commonDefs.VlanGetInfo, error) {
 
 	getinfo := &commonDefs.VlanGetInfo{
@@ -671,8 +703,8 @@ func TestConfigInvalidThreePortalSystemSet(t *testing.T) {
 		DrniName:                          "DR-1",
 		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
 		DrniPortalPriority:                128,
-		DrniThreePortalSystem:             true, // invalid not supported
-		DrniPortalSystemNumber:            1,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            3, // invalid in 2P system
 		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
 		DrniAggregator:                    100,
 		DrniGatewayAlgorithm:              "00:80:C2:01",
@@ -686,7 +718,36 @@ func TestConfigInvalidThreePortalSystemSet(t *testing.T) {
 
 	err := DistributedRelayConfigParamCheck(cfg)
 	if err == nil {
-		t.Error("Parameter check did not fail setting 3P system")
+		t.Error("Parameter check did not fail setting portal system 3 in 2P system")
+	}
+
+	// valid 3P system, ring with an IPP to each neighbor
+	cfg.DrniThreePortalSystem = true
+	cfg.DrniIntraPortalLinkList = [3]uint32{uint32(ipplink1), uint32(ipplink2)}
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid 3P config", err)
+	}
+
+	// admin gateway may name all three systems
+	cfg.DrniConvAdminGateway[100] = [3]uint8{1, 2, 3}
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed setting Conversation Admin Gateway in 3P system", err)
+	}
+	cfg.DrniGatewayAlgorithm = "00:80:C2:05"
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed setting Conversation Admin Gateway in 3P ECMP flow hash system", err)
+	}
+	cfg.DrniGatewayAlgorithm = "00:80:C2:01"
+	cfg.DrniConvAdminGateway[100] = [3]uint8{}
+
+	// portal system number must still be valid
+	cfg.DrniPortalSystemNumber = 4
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check did not fail setting portal system 4 in 3P system")
 	}
 	lacp.DeleteLaAgg(a.AggId)
 	ConfigTestTeardwon(t)
@@ -955,6 +1016,99 @@ func TestConfigInvalidEncapMethod(t *testing.T) {
 	ConfigTestTeardwon(t)
 }
 
+func TestConfigInvalidEncapMap(t *testing.T) {
+	ConfigTestSetup()
+	a := OnlyForTestSetupCreateAggGroup(100)
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    100,
+		DrniGatewayAlgorithm:              "00:80:C2:01",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:01",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:02", // sharing by tag
+		DrniIPLEncapMap:                   map[uint32]uint32{100: 1100, 101: 1101},
+		DrniNetEncapMap:                   map[uint32]uint32{100: 2100},
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03", // only supported value that we are going to support
+	}
+
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+
+	cfg.DrniIPLEncapMap = nil
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check did not fail missing IPL Encap Map")
+	}
+
+	cfg.DrniIPLEncapMap = map[uint32]uint32{100: 4095}
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check did not fail IPL Encap Map vlan out of range")
+	}
+
+	cfg.DrniIPLEncapMap = map[uint32]uint32{100: 1100, 101: 1100}
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check did not fail IPL Encap Map duplicate identifier")
+	}
+
+	cfg.DrniIPLEncapMap = map[uint32]uint32{4096: 1100}
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check did not fail IPL Encap Map invalid conversation id")
+	}
+
+	// I-SID range is larger than a vlan
+	cfg.DrniEncapMethod = "00-80-C2-03"
+	cfg.DrniIPLEncapMap = map[uint32]uint32{100: 0x10000}
+	cfg.DrniNetEncapMap = nil
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid I-TAG config", err)
+	}
+
+	lacp.DeleteLaAgg(a.AggId)
+	ConfigTestTeardwon(t)
+}
+
+// a relay which fails its checks must not hold the aggregator
+func TestConfigDistributedRelayCreateCheckDoesNotRecord(t *testing.T) {
+
+	if err := DistributedRelayConfigCreateCheck("DR-1", 100); err != nil {
+		t.Error("Create check failed for unused aggregator", err)
+	}
+	if _, ok := ConfigDrMap["DR-1"]; ok {
+		t.Error("Create check recorded Distributed Relay before config was saved")
+	}
+	if err := DistributedRelayConfigCreateCheck("DR-2", 100); err != nil {
+		t.Error("Create check failed for aggregator of a rejected Distributed Relay", err)
+	}
+
+	DistributedRelayConfigSave("DR-1", 100)
+	if err := DistributedRelayConfigCreateCheck("DR-2", 100); err == nil {
+		t.Error("Create check did not fail for aggregator already associated with DR-1")
+	}
+	// update of the same relay
+	if err := DistributedRelayConfigCreateCheck("DR-1", 100); err != nil {
+		t.Error("Create check failed for update of DR-1", err)
+	}
+
+	DistributedRelayConfigDeleteCheck("DR-1")
+	if len(ConfigDrMap) != 0 {
+		t.Error("Distributed Relay config not cleared on delete", ConfigDrMap)
+	}
+}
+
 func TestConfigInvalidPortalPortProtocolDA(t *testing.T) {
 	ConfigTestSetup()
 	a := OnlyForTestSetupCreateAggGroup(100)
@@ -0,0 +1,197 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// consistency.go
+package drcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DRNIConsistencyDiff is a single portal parameter which differs between the
+// home portal system and what the neighbor advertised in its DRCPDUs
+type DRNIConsistencyDiff struct {
+	Field    string
+	Home     string
+	Neighbor string
+}
+
+// DRNIConsistencyReport is the result of comparing the home configuration
+// against the neighbor portal system reachable through an IPP
+type DRNIConsistencyReport struct {
+	DrniName string
+	IntfRef  string
+	// neighbor info is only valid once a DRCPDU has been received
+	NeighborValid bool
+	Consistent    bool
+	Diffs         []DRNIConsistencyDiff
+	// conversation ids which the home and neighbor portal systems do not
+	// agree on, derived from the received conversation vectors when the
+	// digests differ
+	GatewayConversationDiff []uint16
+	PortConversationDiff    []uint16
+}
+
+func (r *DRNIConsistencyReport) addDiff(field string, home, neighbor interface{}) {
+	r.Diffs = append(r.Diffs, DRNIConsistencyDiff{
+		Field:    field,
+		Home:     fmt.Sprintf("%v", home),
+		Neighbor: fmt.Sprintf("%v", neighbor),
+	})
+}
+
+func (r *DRNIConsistencyReport) checkDiff(field string, home, neighbor string) {
+	if home != neighbor {
+		r.addDiff(field, home, neighbor)
+	}
+}
+
+func consistencyAlgorithmStr(alg [4]uint8) string {
+	return fmt.Sprintf("%02X:%02X:%02X:%02X", alg[0], alg[1], alg[2], alg[3])
+}
+
+func consistencyDigestStr(d Md5Digest) string {
+	return fmt.Sprintf("%x", d[:])
+}
+
+// DRNIConsistencyCheck will compare the home portal configuration against the
+// information received from the neighbor on every IPP of the distributed relay
+func DRNIConsistencyCheck(drniname string) ([]*DRNIConsistencyReport, error) {
+	var dr *DistributedRelay
+	if !DrFindByName(drniname, &dr) {
+		return nil, errors.New(fmt.Sprintf("ERROR Distributed Relay %s not found", drniname))
+	}
+
+	reportList := make([]*DRNIConsistencyReport, 0)
+	for _, ipp := range dr.Ipplinks {
+		reportList = append(reportList, ipp.ConsistencyCheck())
+	}
+	return reportList, nil
+}
+
+// ConsistencyCheck will compare the home portal configuration against the
+// information received from the neighbor on this IPP
+func (p *DRCPIpp) ConsistencyCheck() *DRNIConsistencyReport {
+	dr := p.dr
+	r := &DRNIConsistencyReport{
+		DrniName: dr.DrniName,
+		IntfRef:  p.Name,
+		Diffs:    make([]DRNIConsistencyDiff, 0),
+	}
+
+	// a misconfigured portal will discard the DRCPDU after recording the
+	// neighbor values, thus those are valid to compare against as well
+	if p.RxMachineFsm != nil &&
+		p.RxMachineFsm.Machine != nil {
+		state := p.RxMachineFsm.Machine.Curr.CurrentState()
+		r.NeighborValid = state == RxmStateCurrent ||
+			state == RxmStateDiscard
+	}
+	if !r.NeighborValid {
+		// nothing to compare against, values are defaulted
+		return r
+	}
+
+	// portal must be the same on all portal systems
+	r.checkDiff("PortalAddress", dr.DrniPortalAddr.String(), net.HardwareAddr(p.DrniNeighborPortalAddr[:]).String())
+	r.checkDiff("PortalPriority", fmt.Sprint(dr.DrniPortalPriority), fmt.Sprint(p.DrniNeighborPortalPriority))
+	r.checkDiff("ThreePortalSystem", fmt.Sprint(dr.DrniThreeSystemPortal), fmt.Sprint(p.DrniNeighborThreeSystemPortal))
+	r.checkDiff("AggregatorId", net.HardwareAddr(dr.DrniAggregatorId[:]).String(), net.HardwareAddr(p.DRFNeighborAggregatorId[:]).String())
+	r.checkDiff("AggregatorPriority", fmt.Sprint(dr.DrniAggregatorPriority), fmt.Sprint(p.DRFNeighborAggregatorPriority))
+	// admin keys are allocated per portal system and may differ, the
+	// negotiated operational key must agree, as in recordPortalConfValues
+	r.checkDiff("OperAggregatorKey", fmt.Sprintf("0x%x", dr.DRFHomeOperAggregatorKey&0x3fff), fmt.Sprintf("0x%x", p.DRFNeighborOperAggregatorKey&0x3fff))
+
+	// portal system numbers, what we expect the neighbor to be and
+	// what the neighbor expects us to be
+	r.checkDiff("NeighborPortalSystemNumber", fmt.Sprint(p.DRFHomeConfNeighborPortalSystemNumber), fmt.Sprint(p.DRFNeighborPortalSystemNumber))
+	r.checkDiff("PortalSystemNumber", fmt.Sprint(dr.DrniPortalSystemNumber), fmt.Sprint(p.DRFNeighborConfPortalSystemNumber))
+
+	// algorithms and digests
+	r.checkDiff("GatewayAlgorithm", consistencyAlgorithmStr(dr.DRFHomeGatewayAlgorithm), consistencyAlgorithmStr(p.DRFNeighborGatewayAlgorithm))
+	r.checkDiff("PortAlgorithm", consistencyAlgorithmStr(dr.DRFHomePortAlgorithm), consistencyAlgorithmStr(p.DRFNeighborPortAlgorithm))
+	r.checkDiff("ConvGatewayListDigest", consistencyDigestStr(dr.DRFHomeConversationGatewayListDigest), consistencyDigestStr(p.DRFNeighborConversationGatewayListDigest))
+	r.checkDiff("ConvPortListDigest", consistencyDigestStr(dr.DRFHomeConversationPortListDigest), consistencyDigestStr(p.DRFNeighborConversationPortListDigest))
+	r.checkDiff("EncapMethod", consistencyAlgorithmStr(dr.DrniEncapMethod), consistencyAlgorithmStr(p.DRFNeighborNetworkIPLSharingMethod))
+	r.checkDiff("IPLEncapDigest", consistencyDigestStr(p.DRFHomeNetworkIPLIPLEncapDigest), consistencyDigestStr(p.DRFNeighborNetworkIPLIPLEncapDigest))
+	r.checkDiff("NetEncapDigest", consistencyDigestStr(p.DRFHomeNetworkIPLIPLNetEncapDigest), consistencyDigestStr(p.DRFNeighborNetworkIPLNetEncapDigest))
+
+	// administratively expected neighbor values
+	r.checkDiff("NeighborAdminGatewayAlgorithm", consistencyAlgorithmStr(dr.DrniNeighborAdminGatewayAlgorithm), consistencyAlgorithmStr(p.DRFNeighborGatewayAlgorithm))
+	r.checkDiff("NeighborAdminPortAlgorithm", consistencyAlgorithmStr(dr.DrniNeighborAdminPortAlgorithm), consistencyAlgorithmStr(p.DRFNeighborPortAlgorithm))
+	r.checkDiff("NeighborAdminConvGatewayListDigest", consistencyDigestStr(dr.DrniNeighborAdminConvGatewayListDigest), consistencyDigestStr(p.DRFNeighborConversationGatewayListDigest))
+	r.checkDiff("NeighborAdminConvPortListDigest", consistencyDigestStr(dr.DrniNeighborAdminConvPortListDigest), consistencyDigestStr(p.DRFNeighborConversationPortListDigest))
+
+	// a digest can not be decoded, but when it differs the neighbor sends
+	// its conversation vectors which can be compared per conversation id
+	if p.DifferGatewayDigest &&
+		!p.MissingRcvGatewayConVector {
+		r.GatewayConversationDiff = p.conversationVectorDiff(&p.DrniNeighborGatewayConversation, dr.gatewayConversationAdminPortalSystem)
+	}
+	if p.DifferPortDigest &&
+		!p.MissingRcvPortConVector {
+		r.PortConversationDiff = p.conversationVectorDiff(&p.DrniNeighborPortConversation, dr.portConversationPortalSystem)
+	}
+
+	r.Consistent = len(r.Diffs) == 0 &&
+		len(r.GatewayConversationDiff) == 0 &&
+		len(r.PortConversationDiff) == 0
+	return r
+}
+
+// gatewayConversationAdminPortalSystem returns the highest priority Portal
+// System Number provisioned for the Gateway Conversation ID, 0 if none
+func (dr *DistributedRelay) gatewayConversationAdminPortalSystem(cid int) uint8 {
+	for _, portalsystemnumber := range dr.DrniConvAdminGateway[cid] {
+		if portalsystemnumber != 0 {
+			return portalsystemnumber
+		}
+	}
+	return 0
+}
+
+// conversationVectorDiff returns the conversation ids for which the received
+// conversation vector does not agree with the home view of which portal system
+// owns the conversation.  A 2P vector is a boolean vector indicating which
+// conversations the neighbor owns, a 3P vector holds the owning portal system
+// number of each conversation
+func (p *DRCPIpp) conversationVectorDiff(conv *[1024]uint8, homePortalSystem func(cid int) uint8) []uint16 {
+	dr := p.dr
+	cidList := make([]uint16, 0)
+	for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+		home := homePortalSystem(cid)
+		if dr.DrniThreeSystemPortal {
+			if threePortalConversationPortalSystem(conv, cid) != home {
+				cidList = append(cidList, uint16(cid))
+			}
+		} else {
+			neighborOwns := conv[cid/8]>>uint(7-cid%8)&0x1 == 1
+			if neighborOwns != (home == p.DRFNeighborPortalSystemNumber) {
+				cidList = append(cidList, uint16(cid))
+			}
+		}
+	}
+	return cidList
+}
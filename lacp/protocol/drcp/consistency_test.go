@@ -0,0 +1,225 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// consistency_test.go
+package drcp
+
+import (
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+	"testing"
+)
+
+func TestConsistencyConversationVectorDiff(t *testing.T) {
+
+	dr := &DistributedRelay{
+		DrniPortalSystemNumber: 1,
+	}
+	ipp := &DRCPIpp{
+		dr: dr,
+	}
+	ipp.DRFNeighborPortalSystemNumber = 2
+
+	// conversation 100 gateway is the neighbor
+	dr.DrniConvAdminGateway[100] = []uint8{2, 1}
+
+	// 2P boolean vector, neighbor claims 100
+	var conv [1024]uint8
+	conv[100/8] |= 1 << uint(7-100%8)
+	if cidList := ipp.conversationVectorDiff(&conv, dr.gatewayConversationAdminPortalSystem); len(cidList) != 0 {
+		t.Error("ERROR Expected no conversation differences found", cidList)
+	}
+
+	// neighbor claims 200 as well
+	conv[200/8] |= 1 << uint(7-200%8)
+	cidList := ipp.conversationVectorDiff(&conv, dr.gatewayConversationAdminPortalSystem)
+	if len(cidList) != 1 ||
+		cidList[0] != 200 {
+		t.Error("ERROR Expected conversation 200 to differ found", cidList)
+	}
+
+	// neighbor no longer claims 100
+	conv[100/8] = 0
+	cidList = ipp.conversationVectorDiff(&conv, dr.gatewayConversationAdminPortalSystem)
+	if len(cidList) != 2 ||
+		cidList[0] != 100 ||
+		cidList[1] != 200 {
+		t.Error("ERROR Expected conversations 100 and 200 to differ found", cidList)
+	}
+
+	// 3P vector holds the portal system number of every conversation
+	dr.DrniThreeSystemPortal = true
+	threePortalConversationVectorFill(2, &conv)
+	cidList = ipp.conversationVectorDiff(&conv, dr.gatewayConversationAdminPortalSystem)
+	if len(cidList) != MAX_CONVERSATION_IDS-1 {
+		t.Error("ERROR Expected all but conversation 100 to differ found", len(cidList))
+	}
+	for _, cid := range cidList {
+		if cid == 100 {
+			t.Error("ERROR Conversation 100 should not differ")
+		}
+	}
+}
+
+func TestConsistencyCheckGatewayAlgorithmDiff(t *testing.T) {
+
+	RxMachineTestSetup()
+	a := OnlyForRxMachineTestSetupCreateAggGroup(200)
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    uint32(a.AggId),
+		DrniGatewayAlgorithm:              "00:80:C2:01",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:01",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:01",
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03", // only supported value that we are going to support
+	}
+	cfg.DrniConvAdminGateway[100][0] = cfg.DrniPortalSystemNumber
+
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+	// just create instance not starting any state machines
+	dr := NewDistributedRelay(cfg)
+	dr.a = a
+	a.DrniName = dr.DrniName
+	dr.SetTimeSharingPortAndGatwewayDigest()
+
+	ipp := dr.Ipplinks[0]
+
+	// no DRCPDU received nothing to compare against
+	reportList, err := DRNIConsistencyCheck(dr.DrniName)
+	if err != nil ||
+		len(reportList) != 1 {
+		t.Error("ERROR Consistency Check failed", err, reportList)
+	} else if reportList[0].NeighborValid {
+		t.Error("ERROR Neighbor should not be valid before a DRCPDU is received")
+	}
+
+	if _, err = DRNIConsistencyCheck("DR-UNKNOWN"); err == nil {
+		t.Error("ERROR Consistency Check should fail for unknown Distributed Relay")
+	}
+
+	// rx machine sends event to each of these machines according to figure 9-22
+	DrcpAMachineFSMBuild(dr)
+	DrcpGMachineFSMBuild(dr)
+	DrcpPsMachineFSMBuild(dr)
+	DrcpTxMachineFSMBuild(ipp)
+	DrcpPtxMachineFSMBuild(ipp)
+
+	ipp.DrcpRxMachineMain()
+	ipp.DRCPEnabled = true
+
+	responseChan := make(chan string)
+
+	dr.PsMachineFsm.DrcpPsMachinePortalSystemInitialize(*dr.PsMachineFsm.Machine, nil)
+
+	ipp.RxMachineFsm.RxmEvents <- utils.MachineEvent{
+		E:            RxmEventBegin,
+		Src:          "CONSISTENCY TEST",
+		ResponseChan: responseChan,
+	}
+
+	<-responseChan
+
+	// neighbor is provisioned with a different gateway algorithm
+	drcp := OnlyForRxMachineCreateValidDRCPPacket()
+	drcp.PortalConfigInfo.GatewayAlgorithm = [4]uint8{0x00, 0x80, 0xC2, 0x4}
+
+	ipp.RxMachineFsm.RxmPktRxEvent <- RxDrcpPdu{
+		pdu:          drcp,
+		src:          "CONSISTENCY TEST",
+		responseChan: responseChan,
+	}
+
+	<-responseChan
+
+	report := ipp.ConsistencyCheck()
+	if !report.NeighborValid {
+		t.Error("ERROR Neighbor should be valid after DRCPDU received")
+	}
+	if report.Consistent {
+		t.Error("ERROR Portal should not be consistent")
+	}
+	foundGatewayAlgorithm := false
+	foundNeighborAdminGatewayAlgorithm := false
+	for _, diff := range report.Diffs {
+		switch diff.Field {
+		case "GatewayAlgorithm":
+			foundGatewayAlgorithm = diff.Home == "00:80:C2:01" &&
+				diff.Neighbor == "00:80:C2:04"
+		case "NeighborAdminGatewayAlgorithm":
+			foundNeighborAdminGatewayAlgorithm = true
+		case "PortalAddress":
+			t.Error("ERROR Portal Address should not differ", diff)
+		}
+	}
+	if !foundGatewayAlgorithm ||
+		!foundNeighborAdminGatewayAlgorithm {
+		t.Error("ERROR Gateway Algorithm difference not reported", report.Diffs)
+	}
+
+	lacp.DeleteLaAgg(a.AggId)
+	dr.DeleteDistributedRelay()
+	RxMachineTestTeardown(t)
+}
+
+// a correctly formed portal is consistent even though the admin aggregator
+// keys are allocated per portal system
+func TestConsistencyCheckPortalFormed(t *testing.T) {
+	for _, numSystems := range []int{2, 3} {
+		h := NewPortalHarness(numSystems)
+
+		h.VerifyPortalFormed("formation", t)
+
+		for _, s := range h.systems {
+			dr := h.DR(s.num)
+			var reportList []*DRNIConsistencyReport
+			if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+				reportList, _ = DRNIConsistencyCheck(dr.DrniName)
+				for _, r := range reportList {
+					if !r.NeighborValid ||
+						!r.Consistent {
+						return false
+					}
+				}
+				return len(reportList) == len(dr.Ipplinks)
+			}) {
+				for _, r := range reportList {
+					t.Error("ERROR", numSystems, "Portal System", s.num, "IPP", r.IntfRef, "not consistent", r.NeighborValid, r.Diffs, r.GatewayConversationDiff, r.PortConversationDiff)
+				}
+			}
+		}
+
+		h.Teardown(t)
+	}
+}
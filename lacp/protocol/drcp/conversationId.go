@@ -25,6 +25,7 @@
 package drcp
 
 import (
+	"errors"
 	"fmt"
 	"l2/lacp/protocol/utils"
 )
@@ -34,6 +35,14 @@ import (
 // in this map
 var ConversationIdMap [MAX_CONVERSATION_IDS]ConvIdTypeValue
 
+// Conversation ID maps for the provider gateway algorithms, the C-VID
+// conversations are held in ConversationIdMap.  Each algorithm has its
+// own map as the same Conversation ID may be derived from different
+// service identifiers depending on the algorithm
+var SVIDConversationIdMap [MAX_CONVERSATION_IDS]ConvIdTypeValue
+var ISIDConversationIdMap [MAX_CONVERSATION_IDS]ConvIdTypeValue
+var TESIDConversationIdMap [MAX_CONVERSATION_IDS]ConvIdTypeValue
+
 type ConvIdTypeValue struct {
 	Valid      bool
 	Refcnt     int
@@ -43,11 +52,184 @@ type ConvIdTypeValue struct {
 	Svlan      uint16
 	Bvid       uint16
 	Psuedowire uint32
+	Tesid      uint32
 	PortList   []int32
 }
 
+// GetConversationIdMap returns the conversation map associated with the
+// gateway algorithm or nil if the algorithm is not supported
+func GetConversationIdMap(idtype GatewayAlgorithm) *[MAX_CONVERSATION_IDS]ConvIdTypeValue {
+	switch idtype {
+	case GATEWAY_ALGORITHM_CVID:
+		return &ConversationIdMap
+	case GATEWAY_ALGORITHM_SVID:
+		return &SVIDConversationIdMap
+	case GATEWAY_ALGORITHM_ISID:
+		return &ISIDConversationIdMap
+	case GATEWAY_ALGORITHM_TE_SID:
+		return &TESIDConversationIdMap
+	}
+	return nil
+}
+
+// GetConversationId derives the Gateway Conversation ID from the service
+// identifier associated with the gateway algorithm 802.1Q-2014 8.2
+// C-VID and S-VID map 1:1 to the Conversation ID, I-SID (24 bit) and
+// TE-SID (32 bit) use the 12 least significant bits of the identifier.
+// Identifiers which fold onto a Conversation ID already in use are
+// rejected by CreateConversationId
+func (cfg *DRConversationConfig) GetConversationId() (uint16, error) {
+	switch cfg.Idtype {
+	case GATEWAY_ALGORITHM_CVID:
+		if cfg.Cvlan < MAX_CONVERSATION_IDS {
+			return cfg.Cvlan, nil
+		}
+		return 0, errors.New(fmt.Sprintln("ERROR Invalid C-VID supplied", cfg.Cvlan))
+	case GATEWAY_ALGORITHM_SVID:
+		if cfg.Svlan < MAX_CONVERSATION_IDS {
+			return cfg.Svlan, nil
+		}
+		return 0, errors.New(fmt.Sprintln("ERROR Invalid S-VID supplied", cfg.Svlan))
+	case GATEWAY_ALGORITHM_ISID:
+		if cfg.Isid <= 0xffffff {
+			return uint16(cfg.Isid & 0xfff), nil
+		}
+		return 0, errors.New(fmt.Sprintln("ERROR Invalid I-SID supplied must be 24 bits", cfg.Isid))
+	case GATEWAY_ALGORITHM_TE_SID:
+		return uint16(cfg.Tesid & 0xfff), nil
+	}
+	return 0, errors.New(fmt.Sprintln("ERROR Unsupported Gateway Algorithm", cfg.Idtype.String()))
+}
+
+// setServiceId will store the service identifier from the config
+// into the conversation entry
+func (ent *ConvIdTypeValue) setServiceId(cfg *DRConversationConfig) {
+	ent.Idtype = cfg.Idtype
+	switch cfg.Idtype {
+	case GATEWAY_ALGORITHM_CVID:
+		ent.Cvlan = cfg.Cvlan
+	case GATEWAY_ALGORITHM_SVID:
+		ent.Svlan = cfg.Svlan
+	case GATEWAY_ALGORITHM_ISID:
+		ent.Isid = cfg.Isid
+	case GATEWAY_ALGORITHM_TE_SID:
+		ent.Tesid = cfg.Tesid
+	}
+}
+
+// serviceIdEqual returns true when the entry was created for the service
+// identifier in the config
+func (ent *ConvIdTypeValue) serviceIdEqual(cfg *DRConversationConfig) bool {
+	switch cfg.Idtype {
+	case GATEWAY_ALGORITHM_CVID:
+		return ent.Cvlan == cfg.Cvlan
+	case GATEWAY_ALGORITHM_SVID:
+		return ent.Svlan == cfg.Svlan
+	case GATEWAY_ALGORITHM_ISID:
+		return ent.Isid == cfg.Isid
+	case GATEWAY_ALGORITHM_TE_SID:
+		return ent.Tesid == cfg.Tesid
+	}
+	return false
+}
+
+// serviceIdString is used when logging the service identifier of a config
+func (cfg *DRConversationConfig) serviceIdString() string {
+	switch cfg.Idtype {
+	case GATEWAY_ALGORITHM_SVID:
+		return fmt.Sprintf("S-VID %d", cfg.Svlan)
+	case GATEWAY_ALGORITHM_ISID:
+		return fmt.Sprintf("I-SID %d", cfg.Isid)
+	case GATEWAY_ALGORITHM_TE_SID:
+		return fmt.Sprintf("TE-SID %d", cfg.Tesid)
+	}
+	return fmt.Sprintf("C-VID %d", cfg.Cvlan)
+}
+
+// isSVIDPortalConfigured returns true if a portal other than the one supplied
+// is running the S-VID gateway algorithm, the S-VID conversations are only
+// needed by these portals
+func isSVIDPortalConfigured(exclude *DistributedRelay) bool {
+	for _, dr := range DistributedRelayDBList {
+		if dr != exclude &&
+			dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_SVID {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceConversationConfig returns the conversation config of an I-SID or
+// TE-SID service identifier carried by the portal
+func (dr *DistributedRelay) serviceConversationConfig(id uint32) *DRConversationConfig {
+	cfg := &DRConversationConfig{
+		DrniName: dr.DrniName,
+		Idtype:   dr.DrniGatewayAlgorithm,
+	}
+	if dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ISID {
+		cfg.Isid = id
+	} else {
+		cfg.Tesid = id
+	}
+	return cfg
+}
+
+// attachGatewayConversations will fill in the conversations needed by the
+// gateway algorithm of the portal.  Provider edge portals use the vlans known
+// by asicd as S-VLANs, the first S-VID portal fills in the conversations.
+// I-SID and TE-SID conversations come from the configured service identifiers
+func (dr *DistributedRelay) attachGatewayConversations() {
+	switch dr.DrniGatewayAlgorithm {
+	case GATEWAY_ALGORITHM_SVID:
+		if !isSVIDPortalConfigured(dr) {
+			GetAllSVIDConversations()
+		}
+	case GATEWAY_ALGORITHM_ISID, GATEWAY_ALGORITHM_TE_SID:
+		for _, id := range dr.DrniServiceIdList {
+			if err := CreateConversationId(dr.serviceConversationConfig(id)); err != nil {
+				dr.LaDrLog(err.Error())
+			}
+		}
+	}
+}
+
+// detachGatewayConversations will release the conversations held by the
+// gateway algorithm of the portal, the S-VID conversations are cleared once
+// the last S-VID portal is gone
+func (dr *DistributedRelay) detachGatewayConversations() {
+	switch dr.DrniGatewayAlgorithm {
+	case GATEWAY_ALGORITHM_SVID:
+		if !isSVIDPortalConfigured(dr) {
+			ClearSVIDConversations()
+		}
+	case GATEWAY_ALGORITHM_ISID, GATEWAY_ALGORITHM_TE_SID:
+		for _, id := range dr.DrniServiceIdList {
+			DeleteConversationId(dr.serviceConversationConfig(id), false)
+		}
+	}
+}
+
 // GetAllCVIDConversations: Fill in the mapping of vlan -> conversation id which is 1:1
 func GetAllCVIDConversations() {
+	getAllVlanConversations(GATEWAY_ALGORITHM_CVID)
+}
+
+// GetAllSVIDConversations: Fill in the mapping of s-vlan -> conversation id which
+// is 1:1.  On provider edge ports the vlans known by asicd are S-VLANs
+func GetAllSVIDConversations() {
+	getAllVlanConversations(GATEWAY_ALGORITHM_SVID)
+}
+
+// ClearSVIDConversations will clear the s-vlan conversations filled in by
+// GetAllSVIDConversations
+func ClearSVIDConversations() {
+	for cid := range SVIDConversationIdMap {
+		SVIDConversationIdMap[cid] = ConvIdTypeValue{}
+	}
+}
+
+func getAllVlanConversations(idtype GatewayAlgorithm) {
+	convMap := GetConversationIdMap(idtype)
 	curMark := 0
 	count := 100
 	more := true
@@ -61,11 +243,14 @@ func GetAllCVIDConversations() {
 				curMark = int(bulkVlanInfo.EndIdx)
 				for i := 0; i < objCnt; i++ {
 					vlan := bulkVlanInfo.VlanList[i].VlanId
-					ent := ConversationIdMap[uint16(vlan)]
+					ent := convMap[uint16(vlan)]
 					ent.Valid = true
 					ent.Refcnt = 1
-					ent.Idtype = GATEWAY_ALGORITHM_CVID
-					ent.Cvlan = uint16(vlan)
+					ent.setServiceId(&DRConversationConfig{
+						Idtype: idtype,
+						Cvlan:  uint16(vlan),
+						Svlan:  uint16(vlan),
+					})
 					if ent.PortList == nil {
 						ent.PortList = make([]int32, 0)
 					}
@@ -75,7 +260,7 @@ func GetAllCVIDConversations() {
 						ent.PortList = append(ent.PortList, ifindex)
 					}
 					//fmt.Println("Creating Conversation Id", ent)
-					ConversationIdMap[uint16(vlan)] = ent
+					convMap[uint16(vlan)] = ent
 				}
 			} else {
 				more = false
@@ -84,82 +269,118 @@ func GetAllCVIDConversations() {
 	}
 }
 
-// CreateConversationId is a config api to handle conversationId updates
-func CreateConversationId(cfg *DRConversationConfig) {
+// CreateConversationId is a config api to handle conversationId updates.
+// A service identifier whose Conversation ID is already used by another
+// identifier is rejected
+func CreateConversationId(cfg *DRConversationConfig) error {
 
-	// only supported converstation at this time
-	if cfg.Idtype == GATEWAY_ALGORITHM_CVID {
-		if cfg.Cvlan < MAX_CONVERSATION_IDS {
-			if ConversationIdMap[cfg.Cvlan].Valid {
-				ent := ConversationIdMap[cfg.Cvlan]
-				ent.Refcnt++
-				// add any new ports into the ConversationIdMap
-				for _, p := range cfg.PortList {
-					foundEntry := false
-					for _, p2 := range ent.PortList {
-						if p == p2 {
-							foundEntry = true
-						}
-					}
-					if !foundEntry {
-						ent.PortList = append(ent.PortList, p)
-					}
-				}
-				ConversationIdMap[cfg.Cvlan] = ent
-			} else {
-				ent := ConversationIdMap[cfg.Cvlan]
-				ent.Valid = true
-				ent.Refcnt = 1
-				ent.Idtype = GATEWAY_ALGORITHM_CVID
-				ent.Cvlan = uint16(cfg.Cvlan)
-				ent.PortList = nil
-				if cfg.PortList != nil {
-					ent.PortList = make([]int32, 0)
-				}
-
-				for _, p := range cfg.PortList {
-					ent.PortList = append(ent.PortList, p)
-				}
+	convMap := GetConversationIdMap(cfg.Idtype)
+	if convMap == nil {
+		return errors.New(fmt.Sprintln("ERROR Unsupported Gateway Algorithm", cfg.Idtype.String()))
+	}
+	cid, err := cfg.GetConversationId()
+	if err != nil {
+		utils.GlobalLogger.Err(fmt.Sprintln(err))
+		return err
+	}
 
-				ConversationIdMap[uint16(cfg.Cvlan)] = ent
+	if convMap[cid].Valid &&
+		!convMap[cid].serviceIdEqual(cfg) {
+		ent := convMap[cid]
+		owner := &DRConversationConfig{
+			Idtype: ent.Idtype,
+			Cvlan:  ent.Cvlan,
+			Svlan:  ent.Svlan,
+			Isid:   ent.Isid,
+			Tesid:  ent.Tesid,
+		}
+		err = errors.New(fmt.Sprintf("ERROR %s maps to Conversation Id %d which is already used by %s",
+			cfg.serviceIdString(), cid, owner.serviceIdString()))
+		return err
+	}
 
-			}
-			// update the local digests and converstaion lists
-			for _, dr := range DistributedRelayDBList {
-				if dr.DrniName == cfg.DrniName {
-					dr.LaDrLog(fmt.Sprintf("Creating Converstaion %d", cfg.Cvlan))
-					dr.SetTimeSharingPortAndGatwewayDigest()
+	if convMap[cid].Valid {
+		ent := convMap[cid]
+		ent.Refcnt++
+		// add any new ports into the ConversationIdMap
+		for _, p := range cfg.PortList {
+			foundEntry := false
+			for _, p2 := range ent.PortList {
+				if p == p2 {
+					foundEntry = true
 				}
 			}
+			if !foundEntry {
+				ent.PortList = append(ent.PortList, p)
+			}
+		}
+		convMap[cid] = ent
+	} else {
+		ent := convMap[cid]
+		ent.Valid = true
+		ent.Refcnt = 1
+		ent.setServiceId(cfg)
+		ent.PortList = nil
+		if cfg.PortList != nil {
+			ent.PortList = make([]int32, 0)
+		}
+
+		for _, p := range cfg.PortList {
+			ent.PortList = append(ent.PortList, p)
+		}
+
+		convMap[cid] = ent
+
+	}
+	// update the local digests and converstaion lists
+	for _, dr := range DistributedRelayDBList {
+		if dr.DrniName == cfg.DrniName {
+			dr.LaDrLog(fmt.Sprintf("Creating Converstaion %d type %s", cid, cfg.Idtype.String()))
+			dr.SetTimeSharingPortAndGatwewayDigest()
 		}
 	}
+	return nil
 }
 
 // CreateConversationId is a config api to handle conversationId updates
 func DeleteConversationId(cfg *DRConversationConfig, force bool) {
 
-	// only supported converstation at this time
-	if cfg.Idtype == GATEWAY_ALGORITHM_CVID {
-		if cfg.Cvlan < MAX_CONVERSATION_IDS && (ConversationIdMap[cfg.Cvlan].Valid || force) {
-			ent := ConversationIdMap[cfg.Cvlan]
-			if ent.Refcnt > 1 {
-				// TODO FUTURE when you can map multiple conversations types
-				// to the same conversation id
-				ent.Refcnt--
-			} else {
-				ent.Valid = false
-				ent.Refcnt = 0
-				ent.Idtype = GATEWAY_ALGORITHM_NULL
-				ent.Cvlan = uint16(cfg.Cvlan)
-				ent.PortList = nil
-				ConversationIdMap[uint16(cfg.Cvlan)] = ent
-
-				// update the local digests and converstaion lists
-				for _, dr := range DistributedRelayDBList {
-					if dr.DrniName == cfg.DrniName {
-						dr.LaDrLog(fmt.Sprintf("Deleting Converstaion %d", cfg.Cvlan))
-						dr.SetTimeSharingPortAndGatwewayDigest()
-					}
+	convMap := GetConversationIdMap(cfg.Idtype)
+	if convMap == nil {
+		return
+	}
+	cid, err := cfg.GetConversationId()
+	if err != nil {
+		utils.GlobalLogger.Err(fmt.Sprintln(err))
+		return
+	}
+
+	// conversation belongs to another service identifier
+	if convMap[cid].Valid &&
+		!convMap[cid].serviceIdEqual(cfg) {
+		return
+	}
+
+	if convMap[cid].Valid || force {
+		ent := convMap[cid]
+		if ent.Refcnt > 1 {
+			// the same service identifier may have been
+			// created more than once
+			ent.Refcnt--
+			convMap[cid] = ent
+		} else {
+			ent.Valid = false
+			ent.Refcnt = 0
+			ent.setServiceId(cfg)
+			ent.Idtype = GATEWAY_ALGORITHM_NULL
+			ent.PortList = nil
+			convMap[cid] = ent
+
+			// update the local digests and converstaion lists
+			for _, dr := range DistributedRelayDBList {
+				if dr.DrniName == cfg.DrniName {
+					dr.LaDrLog(fmt.Sprintf("Deleting Converstaion %d type %s", cid, cfg.Idtype.String()))
+					dr.SetTimeSharingPortAndGatwewayDigest()
 				}
 			}
 		}
@@ -171,31 +392,36 @@ func DeleteConversationId(cfg *DRConversationConfig, force bool) {
 // NOTE: portList should always contain the complete valid port list
 func UpdateConversationId(cfg *DRConversationConfig) {
 
-	// only supported converstation at this time
-	if cfg.Idtype == GATEWAY_ALGORITHM_CVID {
-		if cfg.Cvlan < MAX_CONVERSATION_IDS && ConversationIdMap[cfg.Cvlan].Valid {
-			ent := ConversationIdMap[cfg.Cvlan]
-			ent.Valid = true
-			ent.Idtype = GATEWAY_ALGORITHM_NULL
-			ent.Cvlan = uint16(cfg.Cvlan)
-			ent.PortList = nil
-			ConversationIdMap[uint16(cfg.Cvlan)] = ent
-			ent.PortList = nil
-			if cfg.PortList != nil {
-				ent.PortList = make([]int32, 0)
-			}
+	convMap := GetConversationIdMap(cfg.Idtype)
+	if convMap == nil {
+		return
+	}
+	cid, err := cfg.GetConversationId()
+	if err != nil {
+		utils.GlobalLogger.Err(fmt.Sprintln(err))
+		return
+	}
 
-			// cfg.PortList contains the final valid list so lets just
-			// overwrite the port list
-			for _, p := range cfg.PortList {
-				ent.PortList = append(ent.PortList, p)
-			}
-			ConversationIdMap[uint16(cfg.Cvlan)] = ent
+	if convMap[cid].Valid &&
+		convMap[cid].serviceIdEqual(cfg) {
+		ent := convMap[cid]
+		ent.Valid = true
+		ent.setServiceId(cfg)
+		ent.PortList = nil
+		if cfg.PortList != nil {
+			ent.PortList = make([]int32, 0)
+		}
 
-			// update the local digests and converstaion lists
-			for _, dr := range DistributedRelayDBList {
-				dr.SetTimeSharingPortAndGatwewayDigest()
-			}
+		// cfg.PortList contains the final valid list so lets just
+		// overwrite the port list
+		for _, p := range cfg.PortList {
+			ent.PortList = append(ent.PortList, p)
+		}
+		convMap[cid] = ent
+
+		// update the local digests and converstaion lists
+		for _, dr := range DistributedRelayDBList {
+			dr.SetTimeSharingPortAndGatwewayDigest()
 		}
 	}
 }
@@ -79,6 +79,9 @@ func OnlyForConversationIdTestSetup() {
 		ConversationIdMap[i].Cvlan = 0
 		ConversationIdMap[i].Refcnt = 0
 		ConversationIdMap[i].Idtype = [4]uint8{}
+		SVIDConversationIdMap[i] = ConvIdTypeValue{}
+		ISIDConversationIdMap[i] = ConvIdTypeValue{}
+		TESIDConversationIdMap[i] = ConvIdTypeValue{}
 	}
 	// fill in conversations
 	//GetAllCVIDConversations()
@@ -94,6 +97,9 @@ func OnlyForConversationIdTestTeardown() {
 		ConversationIdMap[i].Cvlan = 0
 		ConversationIdMap[i].Refcnt = 0
 		ConversationIdMap[i].Idtype = [4]uint8{}
+		SVIDConversationIdMap[i] = ConvIdTypeValue{}
+		ISIDConversationIdMap[i] = ConvIdTypeValue{}
+		TESIDConversationIdMap[i] = ConvIdTypeValue{}
 	}
 }
 
@@ -535,3 +541,250 @@ func TestConversationIdVlanMembershipCreateWithPortsThenDelPorts(t *testing.T) {
 	dr.DeleteDistributedRelay()
 	RxMachineTestTeardown(t)
 }
+
+func TestConversationIdServiceIdToConversationId(t *testing.T) {
+
+	testCases := []struct {
+		cfg   DRConversationConfig
+		cid   uint16
+		valid bool
+	}{
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_CVID, Cvlan: 100}, 100, true},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_CVID, Cvlan: 4096}, 0, false},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_SVID, Svlan: 200}, 200, true},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_SVID, Svlan: 5000}, 0, false},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_ISID, Isid: 0x123456}, 0x456, true},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_ISID, Isid: 0x1000000}, 0, false},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_TE_SID, Tesid: 0xdeadbeef}, 0xeef, true},
+		{DRConversationConfig{Idtype: GATEWAY_ALGORITHM_ECMP_FLOW_HASH}, 0, false},
+	}
+
+	for _, tc := range testCases {
+		cid, err := tc.cfg.GetConversationId()
+		if tc.valid && err != nil {
+			t.Error("ERROR unexpected failure deriving conversation id", tc.cfg, err)
+		} else if !tc.valid && err == nil {
+			t.Error("ERROR expected failure deriving conversation id", tc.cfg, cid)
+		} else if tc.valid && cid != tc.cid {
+			t.Error("ERROR conversation id derived incorrectly expected", tc.cid, "found", cid)
+		}
+	}
+}
+
+// I-SIDs which fold onto the same conversation id are rejected
+func TestConversationIdISIDCollisionRejected(t *testing.T) {
+
+	OnlyForConversationIdTestSetup()
+
+	isid1 := &DRConversationConfig{
+		DrniName: "DR-1",
+		Idtype:   GATEWAY_ALGORITHM_ISID,
+		Isid:     0x010064,
+	}
+	isid2 := &DRConversationConfig{
+		DrniName: "DR-1",
+		Idtype:   GATEWAY_ALGORITHM_ISID,
+		Isid:     0x020064,
+	}
+
+	if err := CreateConversationId(isid1); err != nil {
+		t.Error("ERROR unexpected failure creating I-SID conversation", err)
+	}
+	if err := CreateConversationId(isid2); err == nil {
+		t.Error("ERROR colliding I-SID conversation was not rejected")
+	}
+
+	if !ISIDConversationIdMap[100].Valid ||
+		ISIDConversationIdMap[100].Refcnt != 1 ||
+		ISIDConversationIdMap[100].Isid != isid1.Isid {
+		t.Error("ERRRO I-SID Conversation Map was not updated as expected", ISIDConversationIdMap[100])
+	}
+	if ConversationIdMap[100].Valid {
+		t.Error("ERRRO C-VID Conversation Map was updated by I-SID conversation")
+	}
+
+	// the rejected I-SID does not own the conversation
+	DeleteConversationId(isid2, false)
+	if !ISIDConversationIdMap[100].Valid ||
+		ISIDConversationIdMap[100].Isid != isid1.Isid {
+		t.Error("ERRRO I-SID Conversation Map was cleared by the wrong I-SID", ISIDConversationIdMap[100])
+	}
+
+	// same I-SID created again is reference counted
+	if err := CreateConversationId(isid1); err != nil {
+		t.Error("ERROR unexpected failure creating I-SID conversation", err)
+	}
+	if ISIDConversationIdMap[100].Refcnt != 2 {
+		t.Error("ERRRO I-SID Conversation Map was not updated as expected", ISIDConversationIdMap[100])
+	}
+	DeleteConversationId(isid1, false)
+	DeleteConversationId(isid1, false)
+	if ISIDConversationIdMap[100].Valid {
+		t.Error("ERRRO I-SID Conversation Map was not cleared as expected", ISIDConversationIdMap[100])
+	}
+
+	OnlyForConversationIdTestTeardown()
+}
+
+func TestConversationIdSVIDCreateWithPorts(t *testing.T) {
+
+	ConversationIdTestSetup()
+	a := OnlyForConversationIdTestSetupCreateAggGroup(200)
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    uint32(a.AggId),
+		DrniGatewayAlgorithm:              "00:80:C2:02",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:02",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:01",
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03", // only supported value that we are going to support
+	}
+
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+	// just create instance not starting any state machines
+	dr := NewDistributedRelay(cfg)
+	dr.a = a
+	// set gateway info and digest
+	dr.SetTimeSharingPortAndGatwewayDigest()
+
+	// lets get the IPP
+	ipp := dr.Ipplinks[0]
+
+	// rx machine sends event to each of these machines according to figure 9-22
+	DrcpAMachineFSMBuild(dr)
+	DrcpGMachineFSMBuild(dr)
+	DrcpPsMachineFSMBuild(dr)
+	DrcpTxMachineFSMBuild(ipp)
+	DrcpPtxMachineFSMBuild(ipp)
+	DrcpRxMachineFSMBuild(ipp)
+
+	dr.PsMachineFsm.Machine.Curr.SetState(PsmStatePortalSystemInitialize)
+
+	// enable because aggregator was attached above
+	ipp.DRCPEnabled = true
+
+	conversationCfg := &DRConversationConfig{
+		DrniName: dr.DrniName,
+		Idtype:   GATEWAY_ALGORITHM_SVID,
+		Svlan:    100,
+		PortList: []int32{aggport1, aggport2},
+	}
+
+	CreateConversationId(conversationCfg)
+
+	if !SVIDConversationIdMap[100].Valid ||
+		SVIDConversationIdMap[100].Svlan != 100 {
+		t.Error("ERRRO S-VID Conversation Map was not updated as expected", SVIDConversationIdMap[100])
+	}
+	if ConversationIdMap[100].Valid {
+		t.Error("ERRRO C-VID Conversation Map was updated by S-VID conversation")
+	}
+
+	if !SliceEqual(dr.DrniConvAdminGateway[100], []uint8{2, 1}) {
+		t.Error("ERRRO DrniConvAdminGateway values have not been set as expected", dr.DrniConvAdminGateway[100])
+	}
+
+	eventReceived := false
+	go func(evrx *bool) {
+		for i := 0; i < 10 && !*evrx; i++ {
+			time.Sleep(time.Second * 1)
+		}
+		if !(*evrx) {
+			dr.PsMachineFsm.PsmEvents <- utils.MachineEvent{
+				E:   fsm.Event(0),
+				Src: "CONVERSATION ID: FORCE TEST FAIL",
+			}
+		}
+	}(&eventReceived)
+
+	evt := <-dr.PsMachineFsm.PsmEvents
+	if evt.E != PsmEventChangePortal {
+		t.Error("ERRRO Failed to received portal change event")
+	}
+	eventReceived = true
+
+	lacp.DeleteLaAgg(a.AggId)
+	dr.DeleteDistributedRelay()
+	RxMachineTestTeardown(t)
+}
+
+// I-SID conversations come from the portal service identifiers and are
+// released with the portal, S-VID conversations are cleared with the last
+// S-VID portal
+func TestConversationIdPortalServiceConversations(t *testing.T) {
+
+	ConversationIdTestSetup()
+	defer ConversationIdTestTeardwon()
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    200,
+		DrniGatewayAlgorithm:              "00:80:C2:03",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:03",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:01",
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03",
+		DrniServiceIdList:                 []uint32{0x010064, 0x0100c8},
+	}
+	if err := DistributedRelayConfigParamCheck(cfg); err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+
+	dr := NewDistributedRelay(cfg)
+	if !ISIDConversationIdMap[100].Valid ||
+		ISIDConversationIdMap[100].Isid != 0x010064 ||
+		!ISIDConversationIdMap[200].Valid {
+		t.Error("ERROR I-SID conversations were not created from the service id list", ISIDConversationIdMap[100], ISIDConversationIdMap[200])
+	}
+
+	cfg.DrniServiceIdList = []uint32{0x010064, 0x01012c}
+	UpdateDistributedRelayServiceIdList(cfg)
+	if ISIDConversationIdMap[200].Valid ||
+		!ISIDConversationIdMap[300].Valid {
+		t.Error("ERROR I-SID conversations were not updated from the service id list", ISIDConversationIdMap[200], ISIDConversationIdMap[300])
+	}
+
+	dr.DeleteDistributedRelay()
+	if ISIDConversationIdMap[100].Valid ||
+		ISIDConversationIdMap[300].Valid {
+		t.Error("ERROR I-SID conversations were not released with the portal")
+	}
+
+	// service ids are only valid with I-SID or TE-SID
+	cfg.DrniGatewayAlgorithm = "00:80:C2:02"
+	cfg.DrniNeighborAdminGatewayAlgorithm = "00:80:C2:02"
+	if err := DistributedRelayConfigParamCheck(cfg); err == nil {
+		t.Error("ERROR expected service id list to be rejected with the S-VID algorithm")
+	}
+
+	cfg.DrniServiceIdList = nil
+	dr = NewDistributedRelay(cfg)
+	CreateConversationId(&DRConversationConfig{
+		DrniName: dr.DrniName,
+		Idtype:   GATEWAY_ALGORITHM_SVID,
+		Svlan:    100,
+	})
+	if !SVIDConversationIdMap[100].Valid {
+		t.Error("ERROR S-VID conversation was not created", SVIDConversationIdMap[100])
+	}
+	dr.DeleteDistributedRelay()
+	if SVIDConversationIdMap[100].Valid {
+		t.Error("ERROR S-VID conversations were not cleared with the last S-VID portal", SVIDConversationIdMap[100])
+	}
+}
@@ -59,7 +59,7 @@ var ENCAP_METHOD_SEPARATE_LINKS [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x00}
 var ENCAP_METHOD_SHARING_BY_TIME [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x01}
 var ENCAP_METHOD_SHARING_BY_TAG [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x02}
 var ENCAP_METHOD_SHARING_BY_ITAG [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x03}
-var ENCAP_METHOD_SHARING_BY_BTAG [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x04}
+var ENCAP_METHOD_SHARING_BY_BVID [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x04}
 var ENCAP_METHOD_SHARING_BY_PSEUDOWIRE [4]uint8 = [4]uint8{0x00, 0x80, 0xC2, 0x05}
 
 type GatewayAlgorithm [4]uint8
@@ -120,3 +120,22 @@ func (d Md5Digest) calculateGatewayDigest(gatewayList [][]uint8) Md5Digest {
 	}
 	return d
 }
+
+// calculateEncapDigest 802.1AX-2014 9.4.3.2 digest of the IPL/Net Encap
+// Map, a 4 octet identifier for each of the 4096 gateway conversation ids in
+// conversation id order.  A conversation without an identifier is zero
+func (d Md5Digest) calculateEncapDigest(encapMap map[uint32]uint32) Md5Digest {
+	hash := md5.New()
+	for cid := uint32(0); cid < MAX_CONVERSATION_IDS; cid++ {
+		buf := new(bytes.Buffer)
+		// network byte order
+		binary.Write(buf, binary.BigEndian, encapMap[cid])
+		hash.Write(buf.Bytes())
+	}
+
+	digest := hash.Sum(nil)
+	for i, _ := range digest {
+		d[i] = digest[i]
+	}
+	return d
+}
@@ -30,7 +30,6 @@ import (
 	"fmt"
 	"l2/lacp/protocol/lacp"
 	"l2/lacp/protocol/utils"
-	"math"
 	"net"
 	"strconv"
 	"strings"
@@ -87,14 +86,21 @@ type DistributedRelay struct {
 	DrniPSI                                bool
 	DrniPortConversationControl            bool
 	DrniPortalPortProtocolIDA              net.HardwareAddr
+	DrniEcmpFlowHashFields                 uint32
+	DrniServiceIdList                      []uint32
+	DrniKeepaliveSourceIp                  net.IP
+	DrniKeepaliveDestIp                    net.IP
+	DrniKeepaliveUdpPort                   uint16
+	DrniKeepaliveInterval                  uint32
+	DrniKeepalivePriority                  uint16
+	DrniSplitBrainPolicy                   string
 
 	// 9.4.10
-	PortConversationUpdate     bool
-	IppAllPortUpdate           bool
-	GatewayConversationUpdate  bool
-	IppAllGatewayUpdate        bool
-	HomeGatewayVectorTransmit  bool
-	OtherGatewayVectorTransmit bool
+	PortConversationUpdate    bool
+	IppAllPortUpdate          bool
+	GatewayConversationUpdate bool
+	IppAllGatewayUpdate       bool
+	HomeGatewayVectorTransmit bool
 
 	// channel used to wait on response from distributed event send
 	drEvtResponseChan chan string
@@ -117,6 +123,15 @@ type DistributedRelay struct {
 	AMachineFsm  *AMachine
 
 	Ipplinks []*DRCPIpp
+
+	// out of band peer keepalive used to detect split brain
+	// when all IPLs are down
+	keepalive              *DrniKeepalive
+	SplitBrainOutOfService bool
+
+	// gateway lists supplied by the user per conversation, these take
+	// precedence over the 2P gateway algorithm
+	convAdminGatewayConfig [MAX_CONVERSATION_IDS][]uint8
 }
 
 // 802.1ax-2014 Section 9.4.8 Per-DR Function variables
@@ -239,29 +254,55 @@ func (dr *DistributedRelay) isAggPortInConverstaion(portList []int32) bool {
 // forward frames out the aggregator as well as any network links to
 // which the frame is destined for
 func (dr *DistributedRelay) SetTimeSharingPortAndGatwewayDigest() {
-	// algorithm assumes 2P system only
-	if dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_CVID {
-		if !dr.DrniThreeSystemPortal {
-			dr.setAdminConvGatewayAndNeighborGatewayListDigest()
-			dr.setAdminConvPortAndNeighborPortListDigest()
-		}
+	if dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+		// conversations are hash buckets which are balanced
+		// across all portal systems
+		dr.setEcmpFlowHashConvGatewayAndNeighborGatewayListDigest()
+		dr.setAdminConvPortAndNeighborPortListDigest()
+	} else if GetConversationIdMap(dr.DrniGatewayAlgorithm) != nil {
+		dr.setAdminConvGatewayAndNeighborGatewayListDigest()
+		dr.setAdminConvPortAndNeighborPortListDigest()
 	}
 }
 
+// adminConvGatewayList returns the gateway priority list of portal system
+// numbers for a conversation.  In a 2P portal every even conversation will
+// have its gateway in system 2 and every odd conversation will have its
+// gateway in system 1.  In a 3P portal the conversations are spread across
+// all three systems in the same way, the remaining systems follow in ring
+// order as backup gateways
+func adminConvGatewayList(cid uint16, numPortalSystems uint8) []uint8 {
+	gatewayList := make([]uint8, 0, numPortalSystems)
+	first := uint8((cid + 1) % uint16(numPortalSystems))
+	for i := uint8(0); i < numPortalSystems; i++ {
+		gatewayList = append(gatewayList, (first+i)%numPortalSystems+1)
+	}
+	return gatewayList
+}
+
 // setAdminConvGatewayAndNeighborGatewayListDigest will set the predetermined
-// algorithm as the gateway.  Every even vlan will have its gateway in system
-// 2 and every odd vlan will have its gateway in system 1
+// algorithm as the gateway, see adminConvGatewayList.
+// Conversations are taken from the map of the configured gateway algorithm
+// (C-VID, S-VID, I-SID or TE-SID)
 func (dr *DistributedRelay) setAdminConvGatewayAndNeighborGatewayListDigest() {
 	isNewConversation := false
+	numPortalSystems := uint8(DRNI_2P_PORTAL_SYSTEM_ID_MAX)
+	if dr.DrniThreeSystemPortal {
+		numPortalSystems = DRNI_PORTAL_SYSTEM_ID_MAX
+	}
 	ghash := md5.New()
-	for cid, conv := range ConversationIdMap {
+	for cid, conv := range GetConversationIdMap(dr.DrniGatewayAlgorithm) {
 		if conv.Valid && dr.isAggPortInConverstaion(conv.PortList) {
 
 			// mark this call as new so that we can update the state machines
-			if dr.DrniConvAdminGateway[cid] == nil {
-				dr.DrniConvAdminGateway[cid] = make([]uint8, 0)
+			if dr.DrniConvAdminGateway[cid] == nil &&
+				dr.convAdminGatewayConfig[cid] != nil {
+				dr.DrniConvAdminGateway[cid] = dr.convAdminGatewayConfig[cid]
 				isNewConversation = true
-				// Fixed algorithm for 2P system
+				dr.LaDrLog(fmt.Sprintf("Adding New Admin Gateway Conversation %d portallist[%+v]", cid, dr.DrniConvAdminGateway[cid]))
+			} else if dr.DrniConvAdminGateway[cid] == nil {
+				isNewConversation = true
+				// Fixed algorithm
 				// Because we only support sharing by time we don't really care which
 				// system is the "gateway" of the conversation because all conversations
 				// are free to be delivered on both systems based on bridging rules.
@@ -271,19 +312,14 @@ func (dr *DistributedRelay) setAdminConvGatewayAndNeighborGatewayListDigest() {
 				//
 				// NOTE when other sharing methods are supported then this algorithm will
 				// need to be changed
-				if math.Mod(float64(conv.Cvlan), 2) == 0 {
-					dr.DrniConvAdminGateway[cid] = append(dr.DrniConvAdminGateway[cid], 2)
-					dr.DrniConvAdminGateway[cid] = append(dr.DrniConvAdminGateway[cid], 1)
-				} else {
-					dr.DrniConvAdminGateway[cid] = append(dr.DrniConvAdminGateway[cid], 1)
-					dr.DrniConvAdminGateway[cid] = append(dr.DrniConvAdminGateway[cid], 2)
-				}
+				dr.DrniConvAdminGateway[cid] = adminConvGatewayList(uint16(cid), numPortalSystems)
 				dr.LaDrLog(fmt.Sprintf("Adding New Gateway Conversation %d portallist[%+v]", cid, dr.DrniConvAdminGateway[cid]))
 			}
 			buf := new(bytes.Buffer)
 			//dr.LaDrLog(fmt.Sprintf("Adding to Gateway Digest:", conv.Cvlan, math.Mod(float64(conv.Cvlan), 2), []uint8{dr.DrniConvAdminGateway[cid][0], dr.DrniConvAdminGateway[cid][1], uint8(cid >> 8 & 0xff), uint8(cid & 0xff)}))
 			// network byte order
-			binary.Write(buf, binary.BigEndian, []uint8{dr.DrniConvAdminGateway[cid][0], dr.DrniConvAdminGateway[cid][1], uint8(cid >> 8 & 0xff), uint8(cid & 0xff)})
+			binary.Write(buf, binary.BigEndian, dr.DrniConvAdminGateway[cid])
+			binary.Write(buf, binary.BigEndian, []uint8{uint8(cid >> 8 & 0xff), uint8(cid & 0xff)})
 			ghash.Write(buf.Bytes())
 		} else {
 			buf := new(bytes.Buffer)
@@ -358,38 +394,15 @@ func NewDistributedRelay(cfg *DistributedRelayConfig) *DistributedRelay {
 		DrniPSI: true, // by default this is true until the neighbor pkt is received
 	}
 
-	neighborPortalSystemNumber := uint32(2)
-	if cfg.DrniPortalSystemNumber == 1 {
-		neighborPortalSystemNumber = 1
-
-	}
-	// Only support two portal system so we need to adjust
-	// the ipp port id.  This should ideally come from the user
-	// but lets make provisioning as simple as possible
-	for i, ippPortId := range cfg.DrniIntraPortalLinkList {
-		if ippPortId>>16&0x3 == 0 {
-			dr.DrniIntraPortalLinkList[i] = ippPortId | (neighborPortalSystemNumber << 16)
-		}
-	}
+	dr.setIntraPortalLinkList(cfg.DrniIntraPortalLinkList)
 
 	for i, _ := range dr.DrniPortalSystemState {
 		dr.DrniPortalSystemState[i].mutex = &sync.Mutex{}
 	}
 
-	/*
-		Not allowing user to set we are goign to fill this in via
-		setTimeSharingPortAndGatwewayDigest
-		for cid, data := range cfg.DrniConvAdminGateway {
-			if data != [3]uint8{} {
-				dr.DrniConvAdminGateway[cid] = make([]uint8, 0)
-				for _, sysnum := range data {
-					if sysnum != 0 {
-						dr.DrniConvAdminGateway[cid] = append(dr.DrniConvAdminGateway[cid], sysnum)
-					}
-				}
-			}
-		}
-	*/
+	// user supplied gateway lists override the algorithm in
+	// setTimeSharingPortAndGatwewayDigest
+	dr.setConvAdminGatewayConfig(cfg.DrniConvAdminGateway)
 	dr.DrniPortalAddr, _ = net.ParseMAC(cfg.DrniPortalAddress)
 	for i, macbyte := range dr.DrniPortalAddr {
 		dr.DrniAggregatorId[i] = macbyte
@@ -415,10 +428,6 @@ func NewDistributedRelay(cfg *DistributedRelayConfig) *DistributedRelay {
 	if strings.Contains(cfg.DrniEncapMethod, "-") {
 		encapmethod = strings.Split(cfg.DrniEncapMethod, "-")
 	}
-	gatewayalgorithm := strings.Split(cfg.DrniGatewayAlgorithm, ":")
-	if strings.Contains(cfg.DrniGatewayAlgorithm, "-") {
-		gatewayalgorithm = strings.Split(cfg.DrniGatewayAlgorithm, "-")
-	}
 
 	neighborgatewayalgorithm := strings.Split(cfg.DrniNeighborAdminGatewayAlgorithm, ":")
 	if strings.Contains(cfg.DrniNeighborAdminGatewayAlgorithm, "-") {
@@ -431,11 +440,7 @@ func NewDistributedRelay(cfg *DistributedRelayConfig) *DistributedRelay {
 	val3, _ = strconv.ParseInt(encapmethod[2], 16, 16)
 	val4, _ = strconv.ParseInt(encapmethod[3], 16, 16)
 	dr.DrniEncapMethod = EncapMethod{uint8(val1), uint8(val2), uint8(val3), uint8(val4)}
-	val1, _ = strconv.ParseInt(gatewayalgorithm[0], 16, 16)
-	val2, _ = strconv.ParseInt(gatewayalgorithm[1], 16, 16)
-	val3, _ = strconv.ParseInt(gatewayalgorithm[2], 16, 16)
-	val4, _ = strconv.ParseInt(gatewayalgorithm[3], 16, 16)
-	dr.DrniGatewayAlgorithm = [4]uint8{uint8(val1), uint8(val2), uint8(val3), uint8(val4)}
+	dr.DrniGatewayAlgorithm = convertDrniGatewayAlgorithm(cfg.DrniGatewayAlgorithm)
 	val1, _ = strconv.ParseInt(neighborgatewayalgorithm[0], 16, 16)
 	val2, _ = strconv.ParseInt(neighborgatewayalgorithm[1], 16, 16)
 	val3, _ = strconv.ParseInt(neighborgatewayalgorithm[2], 16, 16)
@@ -443,16 +448,48 @@ func NewDistributedRelay(cfg *DistributedRelayConfig) *DistributedRelay {
 	dr.DrniNeighborAdminGatewayAlgorithm = [4]uint8{uint8(val1), uint8(val2), uint8(val3), uint8(val4)}
 	dr.DRFNeighborAdminGatewayAlgorithm = [4]uint8{uint8(val1), uint8(val2), uint8(val3), uint8(val4)}
 
-	for i, data := range cfg.DrniIPLEncapMap {
-		dr.DrniIPLEncapMap[uint32(i)] = data
+	// gateway conversation id -> identifier used when sharing by tag
+	for cid, id := range cfg.DrniIPLEncapMap {
+		dr.DrniIPLEncapMap[cid] = id
 	}
-	for i, data := range cfg.DrniNetEncapMap {
-		dr.DrniNetEncapMap[uint32(i)] = data
+	for cid, id := range cfg.DrniNetEncapMap {
+		dr.DrniNetEncapMap[cid] = id
 	}
 
 	netMac, _ := net.ParseMAC(cfg.DrniIntraPortalPortProtocolDA)
 	dr.DrniPortalPortProtocolIDA = netMac
 
+	dr.DrniEcmpFlowHashFields = cfg.DrniEcmpFlowHashFields
+	if dr.DrniEcmpFlowHashFields == 0 {
+		dr.DrniEcmpFlowHashFields = ECMP_FLOW_HASH_FIELDS_DEFAULT
+	}
+	dr.DrniServiceIdList = append([]uint32(nil), cfg.DrniServiceIdList...)
+
+	// optional peer keepalive
+	if cfg.DrniKeepaliveDestIp != "" {
+		dr.DrniKeepaliveSourceIp = net.ParseIP(cfg.DrniKeepaliveSourceIp)
+		dr.DrniKeepaliveDestIp = net.ParseIP(cfg.DrniKeepaliveDestIp)
+	}
+	dr.DrniKeepaliveUdpPort = cfg.DrniKeepaliveUdpPort
+	if dr.DrniKeepaliveUdpPort == 0 {
+		dr.DrniKeepaliveUdpPort = DRNI_KEEPALIVE_UDP_PORT_DEFAULT
+	}
+	dr.DrniKeepaliveInterval = cfg.DrniKeepaliveInterval
+	if dr.DrniKeepaliveInterval == 0 {
+		dr.DrniKeepaliveInterval = DRNI_KEEPALIVE_INTERVAL_DEFAULT
+	}
+	dr.DrniKeepalivePriority = cfg.DrniKeepalivePriority
+	if dr.DrniKeepalivePriority == 0 {
+		dr.DrniKeepalivePriority = DRNI_KEEPALIVE_PRIORITY_DEFAULT
+	}
+	dr.DrniSplitBrainPolicy = cfg.DrniSplitBrainPolicy
+	if dr.DrniSplitBrainPolicy == "" {
+		dr.DrniSplitBrainPolicy = DRNI_SPLIT_BRAIN_POLICY_NONE
+	}
+
+	// conversations used by the provider gateway algorithms
+	dr.attachGatewayConversations()
+
 	// add to the global db's
 	DistributedRelayDB[dr.DrniName] = dr
 	DistributedRelayDBList = append(DistributedRelayDBList, dr)
@@ -498,6 +535,7 @@ func (dr *DistributedRelay) DeleteDistributedRelay() {
 				DistributedRelayDBList = append(DistributedRelayDBList[:i], DistributedRelayDBList[i+1:]...)
 			}
 		}
+		dr.detachGatewayConversations()
 	}
 }
 
@@ -523,6 +561,8 @@ func (dr *DistributedRelay) BEGIN(restart bool) {
 		dr.DrcpGMachineMain()
 		// Aggregator Machine
 		dr.DrcpAMachineMain()
+		// Peer Keepalive
+		dr.DrcpKeepaliveStart()
 	}
 
 	// wait group used when stopping all the
@@ -572,6 +612,10 @@ func (dr *DistributedRelay) waitgroupstop(m string) {
 
 func (dr *DistributedRelay) Stop() {
 
+	// keepalive notifies the Psm so stop it first
+	dr.DrcpKeepaliveStop()
+	dr.SplitBrainOutOfService = false
+
 	// Psm
 	if dr.PsMachineFsm != nil {
 		dr.PsMachineFsm.Stop()
@@ -651,6 +695,159 @@ func extractPortConversationID() {
 
 }
 
+// setIntraPortalLinkList will save the Intra Portal Link list.  The neighbor
+// portal system number is encoded in the ipp port id.  This should ideally
+// come from the user but lets make provisioning as simple as possible and
+// derive it from the position of the link in the list when it has not been
+// supplied
+func (dr *DistributedRelay) setIntraPortalLinkList(ipllist [MAX_IPP_LINKS]uint32) {
+	dr.DrniIntraPortalLinkList = ipllist
+	ippidx := 0
+	for i, ippPortId := range ipllist {
+		if ippPortId&0xffff == 0 {
+			continue
+		}
+		if ippPortId>>16&0x3 == 0 {
+			neighborPortalSystemNumber := uint32(dr.ippNeighborPortalSystemNumber(ippidx))
+			dr.DrniIntraPortalLinkList[i] = ippPortId | (neighborPortalSystemNumber << 16)
+		}
+		ippidx++
+	}
+}
+
+// setAggPortSystemInfo will assign the portal system info and operational
+// key to the aggregator port, port will be unselected until the DR is synced
+func (dr *DistributedRelay) setAggPortSystemInfo(src string, aggport uint16) {
+	if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
+		dr.setIppAggPortDiscard(src, aggport, true)
+	}
+	lacp.SetLaAggPortSystemInfoFromDistributedRelay(
+		aggport,
+		fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
+			dr.DrniPortalAddr[0],
+			dr.DrniPortalAddr[1],
+			dr.DrniPortalAddr[2],
+			dr.DrniPortalAddr[3],
+			dr.DrniPortalAddr[4],
+			dr.DrniPortalAddr[5]),
+		dr.DrniPortalPriority,
+		dr.DRFHomeOperAggregatorKey,
+		dr.DrniName,
+		false)
+}
+
+// setConvAdminGatewayConfig will save the user supplied gateway list of each
+// conversation, returns the conversations whose list has changed
+func (dr *DistributedRelay) setConvAdminGatewayConfig(convAdminGateway [MAX_CONVERSATION_IDS][3]uint8) []uint16 {
+	changedcids := make([]uint16, 0)
+	for cid, data := range convAdminGateway {
+		var gatewayList []uint8
+		for _, sysnum := range data {
+			if sysnum != 0 {
+				gatewayList = append(gatewayList, sysnum)
+			}
+		}
+		if !bytes.Equal(dr.convAdminGatewayConfig[cid], gatewayList) {
+			dr.convAdminGatewayConfig[cid] = gatewayList
+			changedcids = append(changedcids, uint16(cid))
+		}
+	}
+	return changedcids
+}
+
+// convertDrniGatewayAlgorithm converts the gateway algorithm of the format
+// "00:00:00:00" or "00-00-00-00" to the 4 octet algorithm
+func convertDrniGatewayAlgorithm(algorithm string) GatewayAlgorithm {
+	gatewayalgorithm := strings.Split(algorithm, ":")
+	if strings.Contains(algorithm, "-") {
+		gatewayalgorithm = strings.Split(algorithm, "-")
+	}
+	var val [4]int64
+	for i := 0; i < len(val) && i < len(gatewayalgorithm); i++ {
+		val[i], _ = strconv.ParseInt(gatewayalgorithm[i], 16, 16)
+	}
+	return GatewayAlgorithm{uint8(val[0]), uint8(val[1]), uint8(val[2]), uint8(val[3])}
+}
+
+// ippNeighborPortalSystemNumber returns the default Portal System Number of the
+// neighbor connected to the IPP at position idx in the Intra Portal Link list.
+// In a Three Portal System the systems form a ring, the first IPP connects to
+// the next system in the ring and the second IPP to the previous one
+func (dr *DistributedRelay) ippNeighborPortalSystemNumber(idx int) uint8 {
+	if !dr.DrniThreeSystemPortal {
+		if dr.DrniPortalSystemNumber == 2 {
+			return 1
+		}
+		return 2
+	}
+	if idx == 0 {
+		return dr.DrniPortalSystemNumber%3 + 1
+	}
+	return (dr.DrniPortalSystemNumber+1)%3 + 1
+}
+
+// otherPortalSystemNumber returns the Portal System Number of the Other neighbor,
+// which is the system that is neither this system nor the immediate neighbor on
+// an IPP.  Only a Three Portal System has an Other neighbor, 0 is returned otherwise
+func (dr *DistributedRelay) otherPortalSystemNumber(neighbor uint8) uint8 {
+	if !dr.DrniThreeSystemPortal ||
+		neighbor == 0 ||
+		neighbor == dr.DrniPortalSystemNumber {
+		return 0
+	}
+	return 6 - dr.DrniPortalSystemNumber - neighbor
+}
+
+// ippForPortalSystem returns the IPP used to reach the portal system.  The IPP
+// to the immediate neighbor is preferred, otherwise in a Three Portal System the
+// IPP on which the system is known as the Other neighbor is used.  This allows
+// traffic to go around the ring when an IPL has failed.  nil if not reachable
+func (dr *DistributedRelay) ippForPortalSystem(portalSystemNum uint8) *DRCPIpp {
+	var other *DRCPIpp
+	for _, ipp := range dr.Ipplinks {
+		ipp.IppPortalSystemState[portalSystemNum].mutex.Lock()
+		opstate := ipp.IppPortalSystemState[portalSystemNum].OpState
+		ipp.IppPortalSystemState[portalSystemNum].mutex.Unlock()
+		if !opstate {
+			continue
+		}
+		if ipp.DRFNeighborPortalSystemNumber == portalSystemNum {
+			return ipp
+		}
+		if other == nil {
+			other = ipp
+		}
+	}
+	return other
+}
+
+// portConversationPortalSystem returns the Portal System Number passing the
+// Port Conversation ID, which is the system owning the highest priority
+// operational Aggregation Port for the conversation.  0 if none
+func (dr *DistributedRelay) portConversationPortalSystem(cid int) uint8 {
+	for _, portid := range dr.DrniPortConversation[cid] {
+		if portid == 0 {
+			continue
+		}
+		for i := uint8(1); i <= MAX_PORTAL_SYSTEM_IDS; i++ {
+			found := false
+			dr.DrniPortalSystemState[i].mutex.Lock()
+			if dr.DrniPortalSystemState[i].OpState {
+				for _, id := range dr.DrniPortalSystemState[i].PortIdList {
+					if id == uint32(portid) {
+						found = true
+					}
+				}
+			}
+			dr.DrniPortalSystemState[i].mutex.Unlock()
+			if found {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
 // updatePortalState This function updates the Drni_Portal_System_State[] as follows
 func (dr *DistributedRelay) updatePortalState(src string) {
 
@@ -671,41 +868,93 @@ func (dr *DistributedRelay) updatePortalState(src string) {
 	dr.DRFHomeState.mutex.Unlock()
 	dr.DrniPortalSystemState[dr.DrniPortalSystemNumber].mutex.Unlock()
 
-	if len(dr.Ipplinks) > 1 {
-		// TODO need for the following case when more than a single IPL is supported
-		// if any of the other Portal System’s state information is available from two IPPs in this Portal
-		// System, then....
-	} else if len(dr.Ipplinks) == 1 {
-		// single IPP case
+	// Portal System state information received directly from an immediate
+	// Neighbor on an IPP is preferred over the information relayed by a
+	// neighbor about the Other neighbor.  In a Three Portal System ring the
+	// Other info allows a system to still be reached when the IPL connecting
+	// to it directly has failed
+	var direct [MAX_PORTAL_SYSTEM_IDS + 1]bool
+	for i := uint8(1); i <= MAX_PORTAL_SYSTEM_IDS; i++ {
+		if i == dr.DrniPortalSystemNumber {
+			continue
+		}
+		learned := false
 		for _, ipp := range dr.Ipplinks {
-			dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Lock()
+			if learned ||
+				ipp.DRFNeighborPortalSystemNumber != i {
+				continue
+			}
+			dr.DrniPortalSystemState[i].mutex.Lock()
 			ipp.DRFNeighborState.mutex.Lock()
-			ipp.DRFOtherNeighborState.mutex.Lock()
-			if ipp.DRFNeighborState.OpState {
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].OpState = ipp.DRFNeighborState.OpState
+			if ipp.DRFNeighborState.OpState &&
+				ipp.DRFNeighborState.GatewayVector != nil {
 				seqvector := ipp.DRFNeighborState.GatewayVector[0]
 				dr.LaDrLog(fmt.Sprintf("updatePortalState (%s): DrniPortalSystemState[%d] from DRFNeighborState OpState %t updating vector sequence %d portList %v",
 					src,
-					ipp.DRFNeighborPortalSystemNumber,
+					i,
 					ipp.DRFNeighborState.OpState,
 					seqvector.Sequence,
 					ipp.DRFNeighborState.PortIdList))
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].updateGatewayVector(seqvector.Sequence, seqvector.Vector)
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].PortIdList = ipp.DRFNeighborState.PortIdList
+				dr.DrniPortalSystemState[i].OpState = true
+				dr.DrniPortalSystemState[i].updateGatewayVector(seqvector.Sequence, seqvector.Vector)
+				dr.DrniPortalSystemState[i].PortIdList = ipp.DRFNeighborState.PortIdList
+				learned = true
+				direct[i] = true
+			}
+			ipp.DRFNeighborState.mutex.Unlock()
+			dr.DrniPortalSystemState[i].mutex.Unlock()
+		}
+
+		if !dr.DrniThreeSystemPortal {
+			continue
+		}
 
+		for _, ipp := range dr.Ipplinks {
+			// Other info is only valid when the neighbor learned it from
+			// its immediate neighbor, otherwise it is our own info relayed back
+			if learned ||
+				ipp.DrniNeighborONN ||
+				dr.otherPortalSystemNumber(ipp.DRFNeighborPortalSystemNumber) != i {
+				continue
 			}
-			if ipp.DRFOtherNeighborState.OpState {
-				ipp.DrniNeighborONN = true
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].OpState = ipp.DRFOtherNeighborState.OpState
+			dr.DrniPortalSystemState[i].mutex.Lock()
+			ipp.DRFOtherNeighborState.mutex.Lock()
+			if ipp.DRFOtherNeighborState.OpState &&
+				ipp.DRFOtherNeighborState.GatewayVector != nil {
 				seqvector := ipp.DRFOtherNeighborState.GatewayVector[0]
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].updateGatewayVector(seqvector.Sequence, seqvector.Vector)
-				dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].PortIdList = ipp.DRFOtherNeighborState.PortIdList
+				dr.LaDrLog(fmt.Sprintf("updatePortalState (%s): DrniPortalSystemState[%d] from DRFOtherNeighborState OpState %t updating vector sequence %d portList %v",
+					src,
+					i,
+					ipp.DRFOtherNeighborState.OpState,
+					seqvector.Sequence,
+					ipp.DRFOtherNeighborState.PortIdList))
+				dr.DrniPortalSystemState[i].OpState = true
+				dr.DrniPortalSystemState[i].updateGatewayVector(seqvector.Sequence, seqvector.Vector)
+				dr.DrniPortalSystemState[i].PortIdList = ipp.DRFOtherNeighborState.PortIdList
+				learned = true
 			}
 			ipp.DRFOtherNeighborState.mutex.Unlock()
-			ipp.DRFNeighborState.mutex.Unlock()
-			dr.DrniPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Unlock()
+			dr.DrniPortalSystemState[i].mutex.Unlock()
 		}
+
+		// system is no longer reachable via either IPP of the ring
+		if !learned {
+			dr.DrniPortalSystemState[i].mutex.Lock()
+			if dr.DrniPortalSystemState[i].OpState {
+				dr.LaDrLog(fmt.Sprintf("updatePortalState (%s): DrniPortalSystemState[%d] no longer reachable", src, i))
+			}
+			dr.DrniPortalSystemState[i].OpState = false
+			dr.DrniPortalSystemState[i].mutex.Unlock()
+		}
+	}
+
+	// ONN is set when the Other info transmitted on an IPP was not learned
+	// from an immediate neighbor
+	for _, ipp := range dr.Ipplinks {
+		other := dr.otherPortalSystemNumber(ipp.DRFNeighborPortalSystemNumber)
+		ipp.ONN = other != 0 && !direct[other]
 	}
+
 	// clear unset portals (ignore first index)
 	for i, stateinfo := range dr.DrniPortalSystemState {
 		if i != 0 && !stateinfo.OpState {
@@ -739,26 +988,45 @@ func (dr *DistributedRelay) updatePortalState(src string) {
 	*/
 
 	// update ipp_portal_system_state
-	// TODO If any other Portal System’s state information is available from two IPPs, then
-	if len(dr.Ipplinks) > 1 {
+	// Ipp portal state contains the neighbor state and in a Three Portal System
+	// the Other neighbor state that was received on this IPP
+	for _, ipp := range dr.Ipplinks {
+		ipp.DRFNeighborState.mutex.Lock()
+		if ipp.DRFNeighborState.OpState &&
+			ipp.DRFNeighborState.GatewayVector != nil {
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Lock()
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].OpState = true
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].updateGatewayVector(ipp.DRFNeighborState.GatewayVector[0].Sequence, ipp.DRFNeighborState.GatewayVector[0].Vector)
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].PortIdList = ipp.DRFNeighborState.PortIdList
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Unlock()
+		} else {
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Lock()
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].OpState = false
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].GatewayVector = nil
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].PortIdList = nil
+			ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Unlock()
+		}
+		ipp.DRFNeighborState.mutex.Unlock()
 
-	} else if len(dr.Ipplinks) == 1 {
-		// single ipl
-		// Ipp portal state contains the neighbor state as first entry and if there
-		// are any other portals received on this IPP they will follow
-		for _, ipp := range dr.Ipplinks {
-			ipp.DRFNeighborState.mutex.Lock()
-			if ipp.DRFNeighborState.OpState {
-				ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Lock()
-				ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].OpState = true
-				ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].updateGatewayVector(ipp.DRFNeighborState.GatewayVector[0].Sequence, ipp.DRFNeighborState.GatewayVector[0].Vector)
-				ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].PortIdList = ipp.DRFNeighborState.PortIdList
-				ipp.IppPortalSystemState[ipp.DRFNeighborPortalSystemNumber].mutex.Unlock()
-				// TODO add the any other portal state info from received DRCP here
-				// Not being done today because should only be one other system in 2P config
-			}
-			ipp.DRFNeighborState.mutex.Unlock()
+		other := dr.otherPortalSystemNumber(ipp.DRFNeighborPortalSystemNumber)
+		if other == 0 {
+			continue
 		}
+		ipp.IppPortalSystemState[other].mutex.Lock()
+		ipp.DRFOtherNeighborState.mutex.Lock()
+		if !ipp.DrniNeighborONN &&
+			ipp.DRFOtherNeighborState.OpState &&
+			ipp.DRFOtherNeighborState.GatewayVector != nil {
+			ipp.IppPortalSystemState[other].OpState = true
+			ipp.IppPortalSystemState[other].updateGatewayVector(ipp.DRFOtherNeighborState.GatewayVector[0].Sequence, ipp.DRFOtherNeighborState.GatewayVector[0].Vector)
+			ipp.IppPortalSystemState[other].PortIdList = ipp.DRFOtherNeighborState.PortIdList
+		} else {
+			ipp.IppPortalSystemState[other].OpState = false
+			ipp.IppPortalSystemState[other].GatewayVector = nil
+			ipp.IppPortalSystemState[other].PortIdList = nil
+		}
+		ipp.DRFOtherNeighborState.mutex.Unlock()
+		ipp.IppPortalSystemState[other].mutex.Unlock()
 	}
 	for _, ipp := range dr.Ipplinks {
 		// clear the port sync as the neighbor should not know about this update
@@ -879,6 +1147,10 @@ func (dr *DistributedRelay) AttachAggregatorToDistributedRelay(aggId int32) {
 			// setDefaultPortalSystemParameters
 			a.AggMacAddr = dr.DrniAggregatorId
 
+			if dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+				dr.setEcmpFlowHashFields()
+			}
+
 			// only need to set this once the key has been negotiated.
 			if dr.PsMachineFsm != nil &&
 				dr.PsMachineFsm.Machine.Curr.CurrentState() == PsmStatePortalSystemUpdate {
@@ -889,35 +1161,8 @@ func (dr *DistributedRelay) AttachAggregatorToDistributedRelay(aggId int32) {
 					if lacp.LaFindPortById(aggport, &p) {
 
 						dr.LaDrLog(fmt.Sprintf("Aggregator found updating system parameters moving to unselected until DR is synced"))
-						if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-							for _, client := range utils.GetAsicDPluginList() {
-								for _, ippid := range dr.DrniIntraPortalLinkList {
-									inport := ippid & 0xffff
-									if inport > 0 {
-										dr.LaDrLog(fmt.Sprintf("AttachAgg: Blocking IPP %d to AggPort %d", inport, aggport))
-										/* TMP - changes made to progress the compilation. Need to add actual fpPort */
-										err := client.IppIngressEgressDrop("fpPort1", "fpPort2")
-										if err != nil {
-											dr.LaDrLog(fmt.Sprintf("ERROR (AttachAgg) setting Block from %s tolag port %s", utils.GetNameFromIfIndex(int32(inport)), int32(aggport)))
-										}
-									}
-								}
-							}
-						}
 						// assign the new values to the aggregator
-						lacp.SetLaAggPortSystemInfoFromDistributedRelay(
-							uint16(aggport),
-							fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
-								dr.DrniPortalAddr[0],
-								dr.DrniPortalAddr[1],
-								dr.DrniPortalAddr[2],
-								dr.DrniPortalAddr[3],
-								dr.DrniPortalAddr[4],
-								dr.DrniPortalAddr[5]),
-							dr.DrniPortalPriority,
-							dr.DRFHomeOperAggregatorKey,
-							dr.DrniName,
-							false)
+						dr.setAggPortSystemInfo(DRCPConfigModuleStr, aggport)
 
 					} else {
 						dr.LaDrLog(fmt.Sprintf("ERROR unable update system info on port %d not found", aggport))
@@ -985,16 +1230,7 @@ func (dr *DistributedRelay) DetachAggregatorFromDistributedRelay(aggId int32) {
 					dr.PrevAggregatorPriority)
 
 				if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-					for _, client := range utils.GetAsicDPluginList() {
-						for _, ippid := range dr.DrniIntraPortalLinkList {
-							inport := ippid & 0xffff
-							if inport > 0 {
-								dr.LaDrLog(fmt.Sprintf("UnBlocking IPP %d to AggPort %d", inport, aggport))
-								/* TEMP - add actual port names */
-								client.IppIngressEgressPass("fpPort1", "fpPort2")
-							}
-						}
-					}
+					dr.setIppAggPortDiscard(DRCPConfigModuleStr, aggport, false)
 				}
 			}
 			// reset aggregator values
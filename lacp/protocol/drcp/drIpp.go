@@ -29,6 +29,7 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"utils/fsm"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
@@ -95,6 +96,7 @@ type DRCPIntraPortal struct {
 	DifferConfPortal             bool
 	DifferConfPortalSystemNumber bool
 	DifferGatewayDigest          bool
+	DifferGatewayAlgorithm       bool
 	DifferPortDigest             bool
 	DifferPortal                 bool
 	// range 1..3
@@ -142,7 +144,7 @@ type DRCPIntraPortal struct {
 	DrniNeighborPortalAddr                   [6]uint8
 	DrniNeighborPortalPriority               uint16
 	DrniNeighborState                        [4]StateVectorInfo
-	// This should always be false as we will not support 3 portal system initially
+	// neighbor 3 System Portal bit from the received Topology State
 	DrniNeighborThreeSystemPortal        bool
 	EnabledTimeShared                    bool
 	EnabledEncTagShared                  bool
@@ -162,6 +164,7 @@ type DRCPIntraPortal struct {
 	IppAllUpdate                bool
 	IppGatewayUpdate            bool
 	IppPortUpdate               bool
+	OtherGatewayVectorTransmit  bool
 	PortConversationTransmit    bool
 
 	// 9.3.4.3
@@ -198,9 +201,10 @@ type DRCPIpp struct {
 
 func NewDRCPIpp(id uint32, dr *DistributedRelay) *DRCPIpp {
 
-	neighborPortalSystemNum := uint8(2)
-	if dr.DrniPortalSystemNumber == 2 {
-		neighborPortalSystemNum = 1
+	// neighbor system number is encoded in bits 16-17 of the ipp id
+	neighborPortalSystemNum := uint8(id >> 16 & 0x3)
+	if neighborPortalSystemNum == 0 {
+		neighborPortalSystemNum = dr.ippNeighborPortalSystemNumber(0)
 	}
 
 	ipp := &DRCPIpp{
@@ -214,6 +218,8 @@ func NewDRCPIpp(id uint32, dr *DistributedRelay) *DRCPIpp {
 			// neighbor system id contained in the port id
 			DRFHomeConfNeighborPortalSystemNumber: neighborPortalSystemNum,
 			DRFHomeNetworkIPLSharingMethod:        dr.DrniEncapMethod,
+			DRFHomeNetworkIPLIPLEncapDigest:       Md5Digest{}.calculateEncapDigest(dr.DrniIPLEncapMap),
+			DRFHomeNetworkIPLIPLNetEncapDigest:    Md5Digest{}.calculateEncapDigest(dr.DrniNetEncapMap),
 			DRFNeighborState:                      StateVectorInfo{mutex: &sync.Mutex{}},
 			DRFOtherNeighborState:                 StateVectorInfo{mutex: &sync.Mutex{}},
 		},
@@ -392,6 +398,11 @@ func (p *DRCPIpp) Stop() {
 	}
 	// NetIplShare
 	if p.NetIplShareMachineFsm != nil {
+		// remove any tag translation programmed on the IPP
+		if p.EnabledEncTagShared {
+			p.NetIplShareMachineFsm.setIplEncapTranslation(false)
+			p.EnabledEncTagShared = false
+		}
 		p.NetIplShareMachineFsm.Stop()
 		p.NetIplShareMachineFsm = nil
 	}
@@ -617,6 +628,38 @@ func (p *DRCPIpp) NotifyNTTDRCPUDChange(src string, oldval, newval bool) {
 	}
 }
 
+// NotifyCCTimeSharedChange informs the Net/IPL sharing machine that the home
+// and neighbor agreement on Network / IPL sharing by time has changed
+func (p *DRCPIpp) NotifyCCTimeSharedChange(src string, oldval, newval bool) {
+	if oldval != newval &&
+		p.NetIplShareMachineFsm != nil {
+		event := NetIplSharemEventCCTimeShare
+		if !newval {
+			event = NetIplSharemEventNotCCTimeShare
+		}
+		p.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+			E:   fsm.Event(event),
+			Src: src,
+		}
+	}
+}
+
+// NotifyCCEncTagSharedChange informs the Net/IPL sharing machine that the home
+// and neighbor agreement on Network / IPL sharing by tag has changed
+func (p *DRCPIpp) NotifyCCEncTagSharedChange(src string, oldval, newval bool) {
+	if oldval != newval &&
+		p.NetIplShareMachineFsm != nil {
+		event := NetIplSharemEventCCEncTagShared
+		if !newval {
+			event = NetIplSharemEventNotCCEncTagShared
+		}
+		p.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+			E:   fsm.Event(event),
+			Src: src,
+		}
+	}
+}
+
 // ReportToManagement send events for various reason to infor management of something
 // is wrong.
 func (p *DRCPIpp) reportToManagement() {
@@ -698,3 +741,50 @@ func (nsi *StateVectorInfo) getGatewayVectorByIndex(index int32) *GatewayVectorE
 	}
 	return nil
 }
+
+// threePortalConversationFillByte returns a 3P conversation vector octet
+// where each of the four 2 bit entries is set to the portal system number
+func threePortalConversationFillByte(portalSystemNum uint8) uint8 {
+	val := portalSystemNum & 0x3
+	return val<<6 | val<<4 | val<<2 | val
+}
+
+// threePortalConversationVectorFill sets every conversation in a 3P conversation
+// vector to the portal system number
+func threePortalConversationVectorFill(portalSystemNum uint8, conv *[1024]uint8) {
+	for i := 0; i < 1024; i++ {
+		conv[i] = threePortalConversationFillByte(portalSystemNum)
+	}
+}
+
+// threePortalConversationVectorSave concatenates the two halves received in the
+// 3P Conversation Vector-1 and Vector-2 TLVs into a 3P conversation vector
+func threePortalConversationVectorSave(vector1 []uint8, vector2 []uint8, conv *[1024]uint8) {
+	*conv = [1024]uint8{}
+	copy(conv[:512], vector1)
+	copy(conv[512:], vector2)
+}
+
+// threePortalConversationPortalSystem returns the portal system number stored
+// for the conversation id in a 3P conversation vector, each octet holds four
+// conversations with the lowest conversation id in the most significant bits
+func threePortalConversationPortalSystem(conv *[1024]uint8, cid int) uint8 {
+	return conv[cid/4] >> uint(6-2*(cid%4)) & 0x3
+}
+
+// threePortalConversationVectorBuild encodes the portal system number for each
+// conversation id into the two halves sent in the 3P Conversation Vector-1
+// and Vector-2 TLVs
+func threePortalConversationVectorBuild(portalSystemNum func(cid int) uint8) (vector1 []uint8, vector2 []uint8) {
+	vector1 = make([]uint8, 512)
+	vector2 = make([]uint8, 512)
+	for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+		val := (portalSystemNum(cid) & 0x3) << uint(6-2*(cid%4))
+		if cid < MAX_CONVERSATION_IDS/2 {
+			vector1[cid/4] |= val
+		} else {
+			vector2[cid/4-512] |= val
+		}
+	}
+	return vector1, vector2
+}
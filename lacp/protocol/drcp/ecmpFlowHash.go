@@ -0,0 +1,186 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// ecmpFlowHash.go
+package drcp
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"l2/lacp/protocol/utils"
+	"net"
+)
+
+// ECMP flow hash gateway algorithm 00-80-C2-05
+// Conversations are not derived from a service identifier (vlan) but rather
+// from a hash of the configured frame fields.  Every Conversation ID is a
+// hash bucket, thus a single vlan is spread across all the buckets and all
+// portal systems actively forward frames of the same vlan.
+const (
+	ECMP_FLOW_HASH_FIELD_SRC_MAC uint32 = 1 << iota
+	ECMP_FLOW_HASH_FIELD_DST_MAC
+	ECMP_FLOW_HASH_FIELD_VLAN
+	ECMP_FLOW_HASH_FIELD_ETHERTYPE
+	ECMP_FLOW_HASH_FIELD_SRC_IP
+	ECMP_FLOW_HASH_FIELD_DST_IP
+	ECMP_FLOW_HASH_FIELD_IP_PROTO
+	ECMP_FLOW_HASH_FIELD_L4_SRC_PORT
+	ECMP_FLOW_HASH_FIELD_L4_DST_PORT
+
+	ECMP_FLOW_HASH_FIELDS_ALL uint32 = (ECMP_FLOW_HASH_FIELD_L4_DST_PORT << 1) - 1
+	// default is the classic 5-tuple
+	ECMP_FLOW_HASH_FIELDS_DEFAULT uint32 = ECMP_FLOW_HASH_FIELD_SRC_IP |
+		ECMP_FLOW_HASH_FIELD_DST_IP |
+		ECMP_FLOW_HASH_FIELD_IP_PROTO |
+		ECMP_FLOW_HASH_FIELD_L4_SRC_PORT |
+		ECMP_FLOW_HASH_FIELD_L4_DST_PORT
+)
+
+// EcmpFlowKey contains the frame fields which may be used as input
+// to the flow hash
+type EcmpFlowKey struct {
+	SrcMac    net.HardwareAddr
+	DstMac    net.HardwareAddr
+	Vlan      uint16
+	EtherType uint16
+	SrcIp     net.IP
+	DstIp     net.IP
+	IpProto   uint8
+	L4SrcPort uint16
+	L4DstPort uint16
+}
+
+// GetEcmpFlowHashConversationId will hash the fields of the key selected by
+// fields and fold the result into a Conversation ID.  This must produce the
+// same result as the hash programmed in the asic
+func GetEcmpFlowHashConversationId(key *EcmpFlowKey, fields uint32) uint16 {
+	buf := new(bytes.Buffer)
+	if fields&ECMP_FLOW_HASH_FIELD_SRC_MAC != 0 {
+		buf.Write(key.SrcMac)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_DST_MAC != 0 {
+		buf.Write(key.DstMac)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_VLAN != 0 {
+		binary.Write(buf, binary.BigEndian, key.Vlan)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_ETHERTYPE != 0 {
+		binary.Write(buf, binary.BigEndian, key.EtherType)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_SRC_IP != 0 {
+		buf.Write(key.SrcIp.To16())
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_DST_IP != 0 {
+		buf.Write(key.DstIp.To16())
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_IP_PROTO != 0 {
+		buf.WriteByte(key.IpProto)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_L4_SRC_PORT != 0 {
+		binary.Write(buf, binary.BigEndian, key.L4SrcPort)
+	}
+	if fields&ECMP_FLOW_HASH_FIELD_L4_DST_PORT != 0 {
+		binary.Write(buf, binary.BigEndian, key.L4DstPort)
+	}
+
+	hash := crc32.ChecksumIEEE(buf.Bytes())
+	// fold the 32 bit hash into the 12 bit conversation id space
+	return uint16((hash ^ (hash >> 12) ^ (hash >> 24)) & (MAX_CONVERSATION_IDS - 1))
+}
+
+// ecmpFlowHashGatewayList returns the gateway priority list of portal system
+// numbers for a hash bucket.  Buckets are assigned round robin so that the
+// gateway load is balanced across the portal systems, the remaining systems
+// follow in ring order as backup gateways
+func ecmpFlowHashGatewayList(cid uint16, numPortalSystems uint8) []uint8 {
+	gatewayList := make([]uint8, 0, numPortalSystems)
+	first := uint8(cid % uint16(numPortalSystems))
+	for i := uint8(0); i < numPortalSystems; i++ {
+		gatewayList = append(gatewayList, (first+i)%numPortalSystems+1)
+	}
+	return gatewayList
+}
+
+// setEcmpFlowHashConvGatewayAndNeighborGatewayListDigest will assign every
+// hash bucket a gateway and compute the gateway digest.  The configured hash
+// fields are part of the digest as both portal systems must hash a frame to
+// the same bucket otherwise frames may be forwarded by both or neither system
+func (dr *DistributedRelay) setEcmpFlowHashConvGatewayAndNeighborGatewayListDigest() {
+	isNewConversation := false
+	numPortalSystems := uint8(DRNI_2P_PORTAL_SYSTEM_ID_MAX)
+	if dr.DrniThreeSystemPortal {
+		numPortalSystems = DRNI_PORTAL_SYSTEM_ID_MAX
+	}
+
+	ghash := md5.New()
+	for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+		gatewayList := ecmpFlowHashGatewayList(uint16(cid), numPortalSystems)
+		if len(dr.DrniConvAdminGateway[cid]) != len(gatewayList) ||
+			dr.DrniConvAdminGateway[cid][0] != gatewayList[0] {
+			dr.DrniConvAdminGateway[cid] = gatewayList
+			isNewConversation = true
+		}
+		buf := new(bytes.Buffer)
+		// network byte order
+		binary.Write(buf, binary.BigEndian, gatewayList)
+		binary.Write(buf, binary.BigEndian, uint16(cid))
+		ghash.Write(buf.Bytes())
+	}
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, dr.DrniEcmpFlowHashFields)
+	ghash.Write(buf.Bytes())
+
+	for i, val := range ghash.Sum(nil) {
+		dr.DrniNeighborAdminConvGatewayListDigest[i] = val
+		dr.DRFNeighborAdminConversationGatewayListDigest[i] = val
+		dr.DRFHomeConversationGatewayListDigest[i] = val
+	}
+
+	if isNewConversation {
+		dr.LaDrLog(fmt.Sprintf("ECMP Flow Hash Gateway Conversations assigned across %d portal systems fields 0x%x", numPortalSystems, dr.DrniEcmpFlowHashFields))
+	}
+
+	// always send regardless of state because all states expect this event
+	if isNewConversation &&
+		dr.PsMachineFsm != nil {
+		dr.ChangePortal = true
+		dr.PsMachineFsm.PsmEvents <- utils.MachineEvent{
+			E:   PsmEventChangePortal,
+			Src: DRCPConfigModuleStr,
+		}
+	}
+}
+
+// setEcmpFlowHashFields will program the hash fields used to select the
+// conversation of a frame into the asic
+func (dr *DistributedRelay) setEcmpFlowHashFields() {
+	for _, client := range utils.GetDrniPluginList("DrniFlowHashFieldsSet") {
+		err := client.DrniFlowHashFieldsSet(dr.DrniAggregator, dr.DrniEcmpFlowHashFields)
+		if err != nil {
+			dr.LaDrLog(fmt.Sprintf("ERROR setting ECMP flow hash fields 0x%x on aggregator %d: %s", dr.DrniEcmpFlowHashFields, dr.DrniAggregator, err))
+		}
+	}
+}
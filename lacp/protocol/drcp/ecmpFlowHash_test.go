@@ -0,0 +1,173 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// ecmpFlowHash_test.go
+package drcp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestEcmpFlowHashConversationIdFields(t *testing.T) {
+
+	key := &EcmpFlowKey{
+		SrcMac:    net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		DstMac:    net.HardwareAddr{0x00, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E},
+		Vlan:      100,
+		EtherType: 0x0800,
+		SrcIp:     net.ParseIP("10.1.1.1"),
+		DstIp:     net.ParseIP("10.1.1.2"),
+		IpProto:   6,
+		L4SrcPort: 1024,
+		L4DstPort: 80,
+	}
+
+	cid := GetEcmpFlowHashConversationId(key, ECMP_FLOW_HASH_FIELDS_DEFAULT)
+	if cid >= MAX_CONVERSATION_IDS {
+		t.Error("ERROR Conversation Id out of range", cid)
+	}
+	if cid != GetEcmpFlowHashConversationId(key, ECMP_FLOW_HASH_FIELDS_DEFAULT) {
+		t.Error("ERROR same flow produced different conversation ids")
+	}
+
+	// mac is not part of the default 5-tuple
+	key.SrcMac = net.HardwareAddr{0x00, 0x01, 0x02, 0x03, 0x04, 0x06}
+	if cid != GetEcmpFlowHashConversationId(key, ECMP_FLOW_HASH_FIELDS_DEFAULT) {
+		t.Error("ERROR field not selected changed the conversation id")
+	}
+
+	// another flow of the same vlan should land in a different bucket
+	key.L4SrcPort = 1025
+	if cid == GetEcmpFlowHashConversationId(key, ECMP_FLOW_HASH_FIELDS_DEFAULT) {
+		t.Error("ERROR different flows produced the same conversation id", cid)
+	}
+}
+
+func TestEcmpFlowHashGatewayBalanced(t *testing.T) {
+
+	for _, numPortalSystems := range []uint8{DRNI_2P_PORTAL_SYSTEM_ID_MAX, DRNI_PORTAL_SYSTEM_ID_MAX} {
+		gatewayCnt := make(map[uint8]int)
+		for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+			gatewayList := ecmpFlowHashGatewayList(uint16(cid), numPortalSystems)
+			if len(gatewayList) != int(numPortalSystems) {
+				t.Error("ERROR gateway list does not contain all portal systems", cid, gatewayList)
+				continue
+			}
+			seen := make(map[uint8]bool)
+			for _, portalsystemnumber := range gatewayList {
+				if portalsystemnumber < DRNI_PORTAL_SYSTEM_ID_MIN ||
+					portalsystemnumber > numPortalSystems ||
+					seen[portalsystemnumber] {
+					t.Error("ERROR invalid gateway list", cid, gatewayList)
+				}
+				seen[portalsystemnumber] = true
+			}
+			gatewayCnt[gatewayList[0]]++
+		}
+
+		for portalsystemnumber := uint8(1); portalsystemnumber <= numPortalSystems; portalsystemnumber++ {
+			cnt := gatewayCnt[portalsystemnumber]
+			if cnt < MAX_CONVERSATION_IDS/int(numPortalSystems) ||
+				cnt > MAX_CONVERSATION_IDS/int(numPortalSystems)+1 {
+				t.Error("ERROR gateway conversations not balanced", numPortalSystems, gatewayCnt)
+			}
+		}
+	}
+}
+
+func TestEcmpFlowHashGatewayDigest(t *testing.T) {
+
+	dr1 := &DistributedRelay{
+		DrniName:               "DR-1",
+		DrniPortalSystemNumber: 1,
+		DrniGatewayAlgorithm:   GATEWAY_ALGORITHM_ECMP_FLOW_HASH,
+		DrniEcmpFlowHashFields: ECMP_FLOW_HASH_FIELDS_DEFAULT,
+	}
+	dr2 := &DistributedRelay{
+		DrniName:               "DR-1",
+		DrniPortalSystemNumber: 2,
+		DrniGatewayAlgorithm:   GATEWAY_ALGORITHM_ECMP_FLOW_HASH,
+		DrniEcmpFlowHashFields: ECMP_FLOW_HASH_FIELDS_DEFAULT,
+	}
+
+	dr1.SetTimeSharingPortAndGatwewayDigest()
+	dr2.SetTimeSharingPortAndGatwewayDigest()
+
+	for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+		if dr1.DrniConvAdminGateway[cid] == nil {
+			t.Error("ERROR hash bucket was not assigned a gateway", cid)
+			break
+		}
+	}
+	if !SliceEqual(dr1.DrniConvAdminGateway[100], []uint8{1, 2}) ||
+		!SliceEqual(dr1.DrniConvAdminGateway[101], []uint8{2, 1}) {
+		t.Error("ERROR unexpected gateway list", dr1.DrniConvAdminGateway[100], dr1.DrniConvAdminGateway[101])
+	}
+
+	if dr1.DRFHomeConversationGatewayListDigest != dr2.DRFHomeConversationGatewayListDigest {
+		t.Error("ERROR portal systems with same config computed different gateway digests")
+	}
+
+	// both systems must hash frames the same way
+	dr2.DrniEcmpFlowHashFields |= ECMP_FLOW_HASH_FIELD_VLAN
+	dr2.SetTimeSharingPortAndGatwewayDigest()
+	if dr1.DRFHomeConversationGatewayListDigest == dr2.DRFHomeConversationGatewayListDigest {
+		t.Error("ERROR gateway digest did not change with the flow hash fields")
+	}
+}
+
+func TestEcmpFlowHashInvalidFieldsConfig(t *testing.T) {
+
+	ConversationIdTestSetup()
+	defer ConversationIdTestTeardwon()
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    200,
+		DrniGatewayAlgorithm:              "00:80:C2:05",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:05",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:01",
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03",
+		DrniEcmpFlowHashFields:            ECMP_FLOW_HASH_FIELDS_DEFAULT,
+	}
+
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+
+	cfg.DrniEcmpFlowHashFields = ECMP_FLOW_HASH_FIELDS_ALL + 1
+	err = DistributedRelayConfigParamCheck(cfg)
+	if err == nil {
+		t.Error("Parameter check passed for invalid ECMP flow hash fields")
+	}
+}
@@ -0,0 +1,134 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// forwarding.go
+package drcp
+
+import (
+	"fmt"
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+)
+
+// changedConversations returns the conversation ids whose ownership differs
+// between the previous and current conversation vectors
+func changedConversations(prev, curr *[MAX_CONVERSATION_IDS]bool) []uint16 {
+	cidList := make([]uint16, 0)
+	for cid := 0; cid < MAX_CONVERSATION_IDS; cid++ {
+		if prev[cid] != curr[cid] {
+			cidList = append(cidList, uint16(cid))
+		}
+	}
+	return cidList
+}
+
+// forwardingIfIndexList returns the aggregator and ipp interfaces on which
+// MAC entries of a conversation may have been learned
+func (dr *DistributedRelay) forwardingIfIndexList() []int32 {
+	ifindexList := make([]int32, 0)
+	if dr.a != nil &&
+		dr.a.HwAggId != 0 {
+		ifindexList = append(ifindexList, dr.a.HwAggId)
+	}
+	for _, ipp := range dr.Ipplinks {
+		ifindexList = append(ifindexList, int32(ipp.Id))
+	}
+	return ifindexList
+}
+
+// flushConversationFdb will flush the MAC entries learned on the supplied
+// conversations so that traffic is not black holed until the entries age
+// out after the conversation has moved to another portal system
+func (dr *DistributedRelay) flushConversationFdb(src string, cidList []uint16, ifindexList []int32) {
+	if len(cidList) == 0 ||
+		len(ifindexList) == 0 {
+		return
+	}
+	dr.LaDrLog(fmt.Sprintf("%s: Flushing FDB for %d conversations on interfaces %v", src, len(cidList), ifindexList))
+	for _, client := range utils.GetDrniPluginList("FdbFlushByConversation") {
+		err := client.FdbFlushByConversation(cidList, ifindexList)
+		if err != nil {
+			dr.LaDrLog(fmt.Sprintf("ERROR %s: flushing FDB by conversation %v", src, err))
+		}
+	}
+}
+
+// flushAggregatorFdb will flush all MAC entries learned on the aggregator
+func (dr *DistributedRelay) flushAggregatorFdb(src string) {
+	if dr.a == nil ||
+		dr.a.HwAggId == 0 {
+		return
+	}
+	dr.LaDrLog(fmt.Sprintf("%s: Flushing FDB for Aggregator %s", src, dr.a.AggName))
+	for _, client := range utils.GetDrniPluginList("FdbFlushByPort") {
+		err := client.FdbFlushByPort(dr.a.HwAggId)
+		if err != nil {
+			dr.LaDrLog(fmt.Sprintf("ERROR %s: flushing FDB on Aggregator %s %v", src, dr.a.AggName, err))
+		}
+	}
+}
+
+// setIppAggPortDiscard will program the discard filter between the IPPs and
+// an aggregator port, Annex G: a frame received over the IPL shall never be
+// forwarded over the Aggregator Port.  The filter is removed when the
+// neighbor no longer has any aggregator ports so that the local aggregator
+// can take over the traffic
+func (dr *DistributedRelay) setIppAggPortDiscard(src string, aggport uint16, discard bool) {
+	var p *lacp.LaAggPort
+	if !lacp.LaFindPortById(aggport, &p) {
+		dr.LaDrLog(fmt.Sprintf("ERROR %s: unable to find AggPort %d to update IPP discard", src, aggport))
+		return
+	}
+	for _, client := range utils.GetAsicDPluginList() {
+		for _, ippid := range dr.DrniIntraPortalLinkList {
+			inport := ippid & 0xffff
+			if inport == 0 {
+				continue
+			}
+			ippname := utils.GetNameFromIfIndex(int32(inport))
+			var err error
+			if discard {
+				dr.LaDrLog(fmt.Sprintf("%s: Blocking IPP %s to AggPort %s", src, ippname, p.IntfNum))
+				err = client.IppIngressEgressDrop(ippname, p.IntfNum)
+			} else {
+				dr.LaDrLog(fmt.Sprintf("%s: UnBlocking IPP %s to AggPort %s", src, ippname, p.IntfNum))
+				err = client.IppIngressEgressPass(ippname, p.IntfNum)
+			}
+			if err != nil {
+				dr.LaDrLog(fmt.Sprintf("ERROR %s: setting discard %t from IPP %s to AggPort %s %v", src, discard, ippname, p.IntfNum, err))
+			}
+		}
+	}
+}
+
+// passingConversations returns the gateway conversations which currently
+// pass through the IPP
+func (p *DRCPIpp) passingConversations() []uint16 {
+	cidList := make([]uint16, 0)
+	for cid, passes := range p.IppGatewayConversationPasses {
+		if passes {
+			cidList = append(cidList, uint16(cid))
+		}
+	}
+	return cidList
+}
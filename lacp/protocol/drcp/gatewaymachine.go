@@ -143,7 +143,13 @@ func (gm *GMachine) DrcpGMachineDRNIGatewayUpdate(m fsm.Machine, data interface{
 // DrcpGMachinePSGatewayUpdate function to be called after
 // State transition to PS_GATEWAY_UPDATE
 func (gm *GMachine) DrcpGMachinePSGatewayUpdate(m fsm.Machine, data interface{}) fsm.State {
+	dr := gm.dr
+	prev := dr.DrniPortalSystemGatewayConversation
 	gm.updatePortalSystemGatewayConversation()
+	// gateway of these conversations has moved
+	dr.flushConversationFdb(GMachineModuleStr,
+		changedConversations(&prev, &dr.DrniPortalSystemGatewayConversation),
+		dr.forwardingIfIndexList())
 
 	// next State
 	return GmStateDRNIGatewayUpdate
@@ -325,6 +331,31 @@ func (gm *GMachine) setGatewayConversation() {
 func (gm *GMachine) updatePortalSystemGatewayConversation() {
 	dr := gm.dr
 
+	// ECMP flow hash conversations have a single gateway in a 2P portal as well
+	if dr.DrniThreeSystemPortal ||
+		dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+		// This function sets the Drni_Portal_System_Gateway_Conversation to the result of the
+		// logical AND operation between, the Boolean vector constructed from the
+		// Drni_Gateway_Conversation, by setting to FALSE all the indexed Gateway
+		// Conversation ID entries that are associated with other Portal Systems in the Portal, and the
+		// Boolean vectors constructed from all IPPs Ipp_Other_Gateway_Conversation, by setting
+		// to FALSE all the indexed Gateway Conversation ID entries that are associated with other
+		// Portal Systems in the Portal
+		for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+			home := dr.DrniGatewayConversation[i] != nil &&
+				dr.DrniGatewayConversation[i][0] == dr.DrniPortalSystemNumber
+			for _, ipp := range dr.Ipplinks {
+				// 0 means the neighbor has not provided any info for this conversation
+				if ipp.IppOtherGatewayConversation[i] != 0 &&
+					ipp.IppOtherGatewayConversation[i] != dr.DrniPortalSystemNumber {
+					home = false
+				}
+			}
+			dr.DrniPortalSystemGatewayConversation[i] = home
+		}
+		return
+	}
+
 	for _, ipp := range dr.Ipplinks {
 		if ipp.DifferGatewayDigest &&
 			!dr.DrniThreeSystemPortal {
@@ -338,15 +369,6 @@ func (gm *GMachine) updatePortalSystemGatewayConversation() {
 				dr.DrniPortalSystemGatewayConversation[i+6] = ipp.DrniNeighborGatewayConversation[i]>>1&0x1 == 1
 				dr.DrniPortalSystemGatewayConversation[i+7] = ipp.DrniNeighborGatewayConversation[i]>>0&0x1 == 1
 			}
-		} else {
-			// TODO when 3P supported
-			// his function sets the Drni_Portal_System_Gateway_Conversation to the result of the
-			// logical AND operation between, the Boolean vector constructed from the
-			// Drni_Gateway_Conversation, by setting to FALSE all the indexed Gateway
-			// Conversation ID entries that are associated with other Portal Systems in the Portal, and the
-			// Boolean vectors constructed from all IPPs Ipp_Other_Gateway_Conversation, by setting
-			// to FALSE all the indexed Gateway Conversation ID entries that are associated with other
-			// Portal Systems in the Portal
 		}
 	}
 }
@@ -0,0 +1,197 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// ippState.go
+package drcp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+	"utils/fsm"
+)
+
+// DRCPIppState is a snapshot of the IPP state which is reported to
+// management in order to determine why a portal is not forming
+type DRCPIppState struct {
+	IntfRef                            string
+	DrniName                           string
+	IppPortEnabled                     bool
+	DRCPEnabled                        bool
+	HomeConfNeighborPortalSystemNumber uint8
+	NeighborPortalSystemNumber         uint8
+	NeighborConfPortalSystemNumber     uint8
+	NeighborPortalAddr                 string
+	NeighborPortalPriority             uint16
+	NeighborOperDRCPState              string
+	NeighborONN                        bool
+	NeighborThreeSystemPortal          bool
+	DifferPortal                       bool
+	DifferConfPortal                   bool
+	DifferConfPortalSystemNumber       bool
+	DifferGatewayDigest                bool
+	DifferGatewayAlgorithm             bool
+	DifferPortDigest                   bool
+	DifferPortalReason                 string
+	RxMachineState                     string
+	PtxMachineState                    string
+	TxMachineState                     string
+	IAMachineState                     string
+	IGMachineState                     string
+	NetIplShareMachineState            string
+	HomeConvGatewayListDigest          string
+	NeighborConvGatewayListDigest      string
+	HomeConvPortListDigest             string
+	NeighborConvPortListDigest         string
+	HomeIPLEncapDigest                 string
+	NeighborIPLEncapDigest             string
+	HomeNetEncapDigest                 string
+	NeighborNetEncapDigest             string
+	// one entry per neighbor portal system state vector
+	NeighborPortalSystemState []string
+	DRCPDUsRx                 uint32
+	IllegalRx                 uint32
+	DRCPDUsTx                 uint32
+	LastRxTime                time.Time
+}
+
+// DRCPIppGetNext will iterate over all the IPPs of all Distributed Relays
+func DRCPIppGetNext(p **DRCPIpp) bool {
+	returnNext := false
+	for _, ipp := range DRCPIppDBList {
+		if *p == nil {
+			// first ipp
+			*p = ipp
+			return true
+		} else if *p == ipp {
+			// found ipp
+			returnNext = true
+		} else if returnNext {
+			// next ipp
+			*p = ipp
+			return true
+		}
+	}
+
+	*p = nil
+	return false
+}
+
+// DRCPIppFindByName will find the IPP based on the interface name and the
+// name of the Distributed Relay it belongs to
+func DRCPIppFindByName(intfref, drniname string, p **DRCPIpp) bool {
+	return DRFindPortByKey(IppDbKey{Name: intfref, DrName: drniname}, p)
+}
+
+// machineStateStr returns the current state of the machine, or an
+// empty string when the machine has not been created
+func machineStateStr(m *fsm.Machine, strStateMap map[fsm.State]string) string {
+	if m == nil ||
+		m.Curr == nil {
+		return ""
+	}
+	return strStateMap[m.Curr.CurrentState()]
+}
+
+// GetState will return a snapshot of the IPP state
+func (p *DRCPIpp) GetState() *DRCPIppState {
+	dr := p.dr
+
+	state := &DRCPIppState{
+		IntfRef:                            p.Name,
+		DrniName:                           dr.DrniName,
+		IppPortEnabled:                     p.IppPortEnabled,
+		DRCPEnabled:                        p.DRCPEnabled,
+		HomeConfNeighborPortalSystemNumber: p.DRFHomeConfNeighborPortalSystemNumber,
+		NeighborPortalSystemNumber:         p.DRFNeighborPortalSystemNumber,
+		NeighborConfPortalSystemNumber:     p.DRFNeighborConfPortalSystemNumber,
+		NeighborPortalPriority:             p.DrniNeighborPortalPriority,
+		NeighborOperDRCPState:              strconv.FormatInt(int64(p.DRFNeighborOperDRCPState), 2),
+		NeighborONN:                        p.DrniNeighborONN,
+		NeighborThreeSystemPortal:          p.DrniNeighborThreeSystemPortal,
+		DifferPortal:                       p.DifferPortal,
+		DifferConfPortal:                   p.DifferConfPortal,
+		DifferConfPortalSystemNumber:       p.DifferConfPortalSystemNumber,
+		DifferGatewayDigest:                p.DifferGatewayDigest,
+		DifferGatewayAlgorithm:             p.DifferGatewayAlgorithm,
+		DifferPortDigest:                   p.DifferPortDigest,
+		DifferPortalReason:                 p.DifferPortalReason,
+		HomeConvGatewayListDigest:          hex.EncodeToString(dr.DRFHomeConversationGatewayListDigest[:]),
+		NeighborConvGatewayListDigest:      hex.EncodeToString(p.DRFNeighborConversationGatewayListDigest[:]),
+		HomeConvPortListDigest:             hex.EncodeToString(dr.DRFHomeConversationPortListDigest[:]),
+		NeighborConvPortListDigest:         hex.EncodeToString(p.DRFNeighborConversationPortListDigest[:]),
+		HomeIPLEncapDigest:                 hex.EncodeToString(p.DRFHomeNetworkIPLIPLEncapDigest[:]),
+		NeighborIPLEncapDigest:             hex.EncodeToString(p.DRFNeighborNetworkIPLIPLEncapDigest[:]),
+		HomeNetEncapDigest:                 hex.EncodeToString(p.DRFHomeNetworkIPLIPLNetEncapDigest[:]),
+		NeighborNetEncapDigest:             hex.EncodeToString(p.DRFNeighborNetworkIPLNetEncapDigest[:]),
+		DRCPDUsRx:                          p.DRCPDUsRX,
+		IllegalRx:                          p.IllegalRX,
+		DRCPDUsTx:                          p.DRCPDUsTX,
+		LastRxTime:                         p.LastRXTime,
+	}
+
+	state.NeighborPortalAddr = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
+		p.DrniNeighborPortalAddr[0],
+		p.DrniNeighborPortalAddr[1],
+		p.DrniNeighborPortalAddr[2],
+		p.DrniNeighborPortalAddr[3],
+		p.DrniNeighborPortalAddr[4],
+		p.DrniNeighborPortalAddr[5])
+
+	if p.RxMachineFsm != nil {
+		state.RxMachineState = machineStateStr(p.RxMachineFsm.Machine, RxmStateStrMap)
+	}
+	if p.PtxMachineFsm != nil {
+		state.PtxMachineState = machineStateStr(p.PtxMachineFsm.Machine, PtxmStateStrMap)
+	}
+	if p.TxMachineFsm != nil {
+		state.TxMachineState = machineStateStr(p.TxMachineFsm.Machine, TxmStateStrMap)
+	}
+	if p.IAMachineFsm != nil {
+		state.IAMachineState = machineStateStr(p.IAMachineFsm.Machine, IAmStateStrMap)
+	}
+	if p.IGMachineFsm != nil {
+		state.IGMachineState = machineStateStr(p.IGMachineFsm.Machine, IGmStateStrMap)
+	}
+	if p.NetIplShareMachineFsm != nil {
+		state.NetIplShareMachineState = machineStateStr(p.NetIplShareMachineFsm.Machine, NetIplSharemStateStrMap)
+	}
+
+	for i := 1; i <= MAX_PORTAL_SYSTEM_IDS; i++ {
+		p.DrniNeighborState[i].mutex.Lock()
+		sequence := uint32(0)
+		if len(p.DrniNeighborState[i].GatewayVector) > 0 {
+			sequence = p.DrniNeighborState[i].GatewayVector[0].Sequence
+		}
+		state.NeighborPortalSystemState = append(state.NeighborPortalSystemState,
+			fmt.Sprintf("System %d OpState %t GatewaySequence %d Ports %v",
+				i,
+				p.DrniNeighborState[i].OpState,
+				sequence,
+				p.DrniNeighborState[i].PortIdList))
+		p.DrniNeighborState[i].mutex.Unlock()
+	}
+
+	return state
+}
@@ -0,0 +1,149 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// ippState_test.go
+package drcp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// ippStateTestIllegalFrame is an ip frame sent to the nearest bridge group
+// address, it is not a DRCPDU and must be counted as illegal on the IPP
+func ippStateTestIllegalFrame() gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       []uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		DstMAC:       []uint8{0x01, 0x80, 0xC2, 0x00, 0x00, 0x03},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths: true,
+	}
+	gopacket.SerializeLayers(buf, opts, &eth, gopacket.Payload(make([]byte, 46)))
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+// 2P portal, the IPP state reported to management reflects the formed portal
+func TestDRCPIppGetStatePortalFormed(t *testing.T) {
+
+	h := NewPortalHarness(2)
+
+	h.VerifyPortalFormed("formation", t)
+
+	ipp := h.Ipp(1, 2)
+	if ipp == nil {
+		t.Fatal("Error unable to find IPP on Portal System 1")
+	}
+	var found *DRCPIpp
+	if !DRCPIppFindByName(ipp.Name, "DR-1", &found) || found != ipp {
+		t.Error("Error unable to find IPP by name", ipp.Name)
+	}
+
+	// only the frame below should be counted as illegal
+	h.findIpl(1, 2).bridge.RxIppPort1 <- ippStateTestIllegalFrame()
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return ipp.GetState().IllegalRx == 1
+	}) {
+		t.Error("Error illegal frame not counted on IPP", ipp.GetState().IllegalRx)
+	}
+
+	state := ipp.GetState()
+	if state.IntfRef != ipp.Name ||
+		state.DrniName != "DR-1" {
+		t.Error("Error IPP state identity incorrect", state.IntfRef, state.DrniName)
+	}
+	if !state.IppPortEnabled || !state.DRCPEnabled {
+		t.Error("Error IPP state should be enabled", state.IppPortEnabled, state.DRCPEnabled)
+	}
+
+	// machine states
+	if state.RxMachineState != RxmStateStrMap[RxmStateCurrent] {
+		t.Error("Error Rx Machine state expected", RxmStateStrMap[RxmStateCurrent], "actual", state.RxMachineState)
+	}
+	if state.PtxMachineState != PtxmStateStrMap[PtxmStateFastPeriodic] &&
+		state.PtxMachineState != PtxmStateStrMap[PtxmStateSlowPeriodic] &&
+		state.PtxMachineState != PtxmStateStrMap[PtxmStatePeriodicTx] {
+		t.Error("Error Periodic Tx Machine should be periodic, actual", state.PtxMachineState)
+	}
+	if state.TxMachineState != TxmStateStrMap[TxmStateOn] &&
+		state.TxMachineState != TxmStateStrMap[TxmStateOff] {
+		t.Error("Error Tx Machine state unexpected", state.TxMachineState)
+	}
+	if state.IAMachineState != IAmStateStrMap[IAmStateIPPPortUpdate] {
+		t.Error("Error IPP Aggregator Machine state expected", IAmStateStrMap[IAmStateIPPPortUpdate], "actual", state.IAMachineState)
+	}
+	if state.IGMachineState != IGmStateStrMap[IGmStateIPPGatewayUpdate] {
+		t.Error("Error IPP Gateway Machine state expected", IGmStateStrMap[IGmStateIPPGatewayUpdate], "actual", state.IGMachineState)
+	}
+
+	// counters
+	if state.DRCPDUsRx == 0 || state.DRCPDUsTx == 0 {
+		t.Error("Error DRCPDU counters not incremented rx", state.DRCPDUsRx, "tx", state.DRCPDUsTx)
+	}
+	if state.IllegalRx != 1 {
+		t.Error("Error illegal rx counter expected 1 actual", state.IllegalRx)
+	}
+	if state.LastRxTime.IsZero() {
+		t.Error("Error last rx time not recorded")
+	}
+
+	// neighbor vectors
+	if state.NeighborPortalSystemNumber != 2 ||
+		state.HomeConfNeighborPortalSystemNumber != 2 {
+		t.Error("Error neighbor portal system number expected 2 actual", state.NeighborPortalSystemNumber, state.HomeConfNeighborPortalSystemNumber)
+	}
+	if !strings.EqualFold(state.NeighborPortalAddr, PortalHarnessPortalAddr) {
+		t.Error("Error neighbor portal address expected", PortalHarnessPortalAddr, "actual", state.NeighborPortalAddr)
+	}
+	if state.DifferPortal || state.DifferConfPortal || state.DifferGatewayDigest || state.DifferPortDigest {
+		t.Error("Error formed portal should not report differences", state.DifferPortalReason)
+	}
+	if len(state.NeighborPortalSystemState) != MAX_PORTAL_SYSTEM_IDS {
+		t.Error("Error expected a state vector per portal system", state.NeighborPortalSystemState)
+	} else if !strings.HasPrefix(state.NeighborPortalSystemState[1], fmt.Sprintf("System %d OpState true", 2)) {
+		t.Error("Error neighbor state vector not operational", state.NeighborPortalSystemState[1])
+	}
+
+	// digests agree with the neighbor
+	zero := hex.EncodeToString(make([]byte, 16))
+	if state.HomeConvGatewayListDigest == zero ||
+		state.HomeConvGatewayListDigest != state.NeighborConvGatewayListDigest {
+		t.Error("Error gateway list digest mismatch home", state.HomeConvGatewayListDigest, "neighbor", state.NeighborConvGatewayListDigest)
+	}
+	if state.HomeConvPortListDigest != state.NeighborConvPortListDigest {
+		t.Error("Error port list digest mismatch home", state.HomeConvPortListDigest, "neighbor", state.NeighborConvPortListDigest)
+	}
+	if state.HomeIPLEncapDigest != state.NeighborIPLEncapDigest ||
+		state.HomeNetEncapDigest != state.NeighborNetEncapDigest {
+		t.Error("Error encap digest mismatch", state.HomeIPLEncapDigest, state.NeighborIPLEncapDigest, state.HomeNetEncapDigest, state.NeighborNetEncapDigest)
+	}
+
+	h.Teardown(t)
+}
@@ -225,9 +225,11 @@ func (iam *IAMachine) setIPPPortConversation() {
 
 	if p.DifferPortDigest &&
 		p.dr.DrniThreeSystemPortal {
-		// TODO when a 3P system is supported
-		//for i, j := 0, 512; i < MAX_CONVERSATION_IDS; i, j = i+8, i+1 {
-		//}
+		// Drni_Neighbor_Port_Conversation holds the portal system
+		// number for each conversation id as reported by the neighbor
+		for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+			p.IppOtherPortConversationPortalSystem[i] = threePortalConversationPortalSystem(&p.DrniNeighborPortConversation, i)
+		}
 	} else if p.DifferPortDigest &&
 		!p.dr.DrniThreeSystemPortal {
 		var neighborConversationSystemNumbers [1024]uint8
@@ -30,6 +30,8 @@ import (
 	"strconv"
 	"strings"
 	"utils/fsm"
+
+	"github.com/google/gopacket/layers"
 )
 
 const IGMachineModuleStr = "IPP Gateway Machine"
@@ -226,7 +228,11 @@ func (igm *IGMachine) setIPPGatewayConversation() {
 
 	if p.DifferGatewayDigest &&
 		dr.DrniThreeSystemPortal {
-		// TODO handle 3 portal system logic
+		// Drni_Neighbor_Gateway_Conversation holds the portal system
+		// number for each conversation id as reported by the neighbor
+		for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+			p.IppOtherGatewayConversation[i] = threePortalConversationPortalSystem(&p.DrniNeighborGatewayConversation, i)
+		}
 	} else if p.DifferGatewayDigest &&
 		!dr.DrniThreeSystemPortal {
 		for i, j := 0, 0; i < 512; i, j = i+1, j+8 {
@@ -284,13 +290,16 @@ func (igm *IGMachine) setIPPGatewayConversation() {
 			if portalsystemnumbers != nil {
 				for _, portalsystemnumber := range portalsystemnumbers {
 					p.DrniNeighborState[portalsystemnumber].mutex.Lock()
-					if portalsystemnumber != 0 &&
+					enabled := portalsystemnumber != 0 &&
 						p.DrniNeighborState[portalsystemnumber].OpState &&
 						p.DrniNeighborState[portalsystemnumber].GatewayVector != nil &&
-						p.DrniNeighborState[portalsystemnumber].GatewayVector[0].Vector[cid] {
+						p.DrniNeighborState[portalsystemnumber].GatewayVector[0].Vector[cid]
+					p.DrniNeighborState[portalsystemnumber].mutex.Unlock()
+					// list is in priority order, highest priority wins
+					if enabled {
 						p.IppOtherGatewayConversation[cid] = portalsystemnumber
+						break
 					}
-					p.DrniNeighborState[portalsystemnumber].mutex.Unlock()
 				}
 			}
 		}
@@ -310,7 +319,16 @@ func (igm *IGMachine) updateIPPGatewayConversationDirection() {
 	//   disagreement for any Gateway Conversation ID:
 	//   It sets DRF_HomIe_Oper_DRCP_State.Gateway_Sync to FALSE, and;
 	//   NTTDRCPDU to TRUE.
-	if !dr.DrniThreeSystemPortal {
+
+	// conversations which have started or stopped passing through this IPP,
+	// the macs learned for these conversations are no longer valid
+	changedcids := make([]uint16, 0)
+	defer func() {
+		dr.flushConversationFdb(IGMachineModuleStr, changedcids, dr.forwardingIfIndexList())
+	}()
+
+	if !dr.DrniThreeSystemPortal &&
+		dr.DrniGatewayAlgorithm != GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
 		for conid := 0; conid < MAX_CONVERSATION_IDS; conid++ {
 
 			if (dr.DrniGatewayConversation[conid] != nil && !p.IppGatewayConversationPasses[conid]) ||
@@ -320,49 +338,29 @@ func (igm *IGMachine) updateIPPGatewayConversationDirection() {
 				// owns this conversation, however it should be noted
 				// that in the case of sharing by time both systems
 				// will own a conversation
-				if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-					igm.DrcpIGmLog(fmt.Sprintf("Made it here Conversation Id %d ipp port %d\n", conid, p.Id))
-
-					for _, statevector := range p.IppPortalSystemState {
-						statevector.mutex.Lock()
-						if statevector.OpState {
-
-							if statevector.GatewayVector != nil {
-								seqvector := statevector.GatewayVector[0]
-								if seqvector.Vector != nil {
-									if seqvector.Vector[conid] &&
-										!p.IppGatewayConversationPasses[conid] {
-										p.IppGatewayConversationPasses[conid] = true
-										if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-											// TODO  Set the Vlan Membership for each conversation that is valid
-											igm.DrcpIGmLog(fmt.Sprintf("Setting Vlan Membership for Conversation Id %d ipp port %d\n", conid, p.Id))
-											for _, client := range utils.GetAsicDPluginList() {
-												err := client.IppVlanConversationSet(uint16(conid), int32(p.Id))
-												if err != nil {
-													igm.DrcpIGmLog(fmt.Sprintf("ERROR setting Vlan membership %v", err))
-												}
-											}
-										}
-									} else if !seqvector.Vector[conid] {
-										if p.IppGatewayConversationPasses[conid] &&
-											dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-											// TODO  Set the Vlan Membership for each conversation that is valid
-											igm.DrcpIGmLog(fmt.Sprintf("Clearing Vlan Membership for Conversation Id %d ipp port %d\n", conid, p.Id))
-											for _, client := range utils.GetAsicDPluginList() {
-												err := client.IppVlanConversationClear(uint16(conid), int32(p.Id))
-												if err != nil {
-													igm.DrcpIGmLog(fmt.Sprintf("ERROR clearing Vlan membership %v", err))
-												}
-											}
-										}
-										p.IppGatewayConversationPasses[conid] = false
-									}
+				for _, statevector := range p.IppPortalSystemState {
+					statevector.mutex.Lock()
+					if statevector.OpState {
+
+						if statevector.GatewayVector != nil {
+							seqvector := statevector.GatewayVector[0]
+							if seqvector.Vector != nil {
+								if seqvector.Vector[conid] &&
+									!p.IppGatewayConversationPasses[conid] {
+									p.IppGatewayConversationPasses[conid] = true
+									igm.setIppConversationMembership(uint16(conid), true)
+									changedcids = append(changedcids, uint16(conid))
+								} else if !seqvector.Vector[conid] &&
+									p.IppGatewayConversationPasses[conid] {
+									p.IppGatewayConversationPasses[conid] = false
+									igm.setIppConversationMembership(uint16(conid), false)
+									changedcids = append(changedcids, uint16(conid))
 								}
 							}
 						}
-						statevector.mutex.Unlock()
-
 					}
+					statevector.mutex.Unlock()
+
 				}
 			}
 		}
@@ -373,6 +371,83 @@ func (igm *IGMachine) updateIPPGatewayConversationDirection() {
 		// NTTDRCPDU to TRUE.
 		// Otherwise:
 		// DRF_Home_Oper_DRCP_State.Gateway_Sync and NTTDRCPDU are left unchanged.
+	} else {
+		// In a ring the conversation passes this IPP when the gateway system is
+		// reached through this IPP, either as the immediate neighbor or as the
+		// Other neighbor when the IPL to that system has failed.
+		// ECMP flow hash conversations always have a single gateway thus
+		// a 2P portal follows the same rules
+		disagree := false
+		for conid := 0; conid < MAX_CONVERSATION_IDS; conid++ {
+			passes := false
+			if dr.DrniGatewayConversation[conid] != nil {
+				portalsystemnumber := dr.DrniGatewayConversation[conid][0]
+				if portalsystemnumber != dr.DrniPortalSystemNumber {
+					passes = dr.ippForPortalSystem(portalsystemnumber) == p
+				}
+				if p.IppOtherGatewayConversation[conid] != 0 &&
+					p.IppOtherGatewayConversation[conid] != portalsystemnumber {
+					disagree = true
+					passes = false
+				}
+			}
+			if passes == p.IppGatewayConversationPasses[conid] {
+				continue
+			}
+			p.IppGatewayConversationPasses[conid] = passes
+			changedcids = append(changedcids, uint16(conid))
+			igm.setIppConversationMembership(uint16(conid), passes)
+		}
+		if disagree {
+			igm.DrcpIGmLog("Drni_Gateway_Conversation and Ipp_Other_Gateway_Conversation disagree, clearing Gateway Sync")
+			dr.DRFHomeOperDRCPState.ClearState(layers.DRCPStateGatewaySync)
+			defer p.NotifyNTTDRCPUDChange(IGMachineModuleStr, p.NTTDRCPDU, true)
+			p.NTTDRCPDU = true
+		}
+	}
+}
+
+// setIppConversationMembership will program the asic so that frames of a
+// conversation either pass or are blocked on the IPP.  Vlan based gateway
+// algorithms use the vlan membership of the IPP, the ECMP flow hash
+// algorithm programs the hash bucket.  Only an IPL shared by time relies on
+// the membership, when sharing by tag frames are steered by the encap map
+func (igm *IGMachine) setIppConversationMembership(conid uint16, passes bool) {
+	p := igm.p
+
+	if p.dr.DrniEncapMethod != ENCAP_METHOD_SHARING_BY_TIME {
+		return
+	}
+
+	if p.dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+		for _, client := range utils.GetDrniPluginList("IppFlowHashConversationSet") {
+			var err error
+			if passes {
+				igm.DrcpIGmLog(fmt.Sprintf("Setting Flow Hash Conversation Id %d ipp port %d\n", conid, p.Id))
+				err = client.IppFlowHashConversationSet(conid, int32(p.Id))
+			} else {
+				igm.DrcpIGmLog(fmt.Sprintf("Clearing Flow Hash Conversation Id %d ipp port %d\n", conid, p.Id))
+				err = client.IppFlowHashConversationClear(conid, int32(p.Id))
+			}
+			if err != nil {
+				igm.DrcpIGmLog(fmt.Sprintf("ERROR updating Flow Hash Conversation %v", err))
+			}
+		}
+		return
+	}
+
+	for _, client := range utils.GetAsicDPluginList() {
+		var err error
+		if passes {
+			igm.DrcpIGmLog(fmt.Sprintf("Setting Vlan Membership for Conversation Id %d ipp port %d\n", conid, p.Id))
+			err = client.IppVlanConversationSet(conid, int32(p.Id))
+		} else {
+			igm.DrcpIGmLog(fmt.Sprintf("Clearing Vlan Membership for Conversation Id %d ipp port %d\n", conid, p.Id))
+			err = client.IppVlanConversationClear(conid, int32(p.Id))
+		}
+		if err != nil {
+			igm.DrcpIGmLog(fmt.Sprintf("ERROR updating Vlan membership %v", err))
+		}
 	}
 }
 
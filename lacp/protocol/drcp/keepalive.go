@@ -0,0 +1,430 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// keepalive.go
+package drcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"net"
+	"sync"
+	"time"
+)
+
+// Split brain policy, action taken by the portal system when both the IPLs and
+// the peer keepalive are down, thus it is unknown if the neighbor portal
+// system is still forwarding
+const (
+	// keep the aggregator ports in service, neighbor is assumed to be down
+	DRNI_SPLIT_BRAIN_POLICY_NONE = "none"
+	// lower priority portal system takes its aggregator ports out of service
+	DRNI_SPLIT_BRAIN_POLICY_LOWER_PRIORITY_DOWN = "lower-priority-down"
+	// all portal systems take their aggregator ports out of service
+	DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN = "all-down"
+)
+
+const (
+	DRNI_KEEPALIVE_UDP_PORT_DEFAULT = 6400
+	// msec
+	DRNI_KEEPALIVE_INTERVAL_DEFAULT = 1000
+	DRNI_KEEPALIVE_INTERVAL_MIN     = 100
+	DRNI_KEEPALIVE_INTERVAL_MAX     = 10000
+	// peer is declared down after missing this many keepalives
+	DRNI_KEEPALIVE_TIMEOUT_MULTIPLIER = 3
+	DRNI_KEEPALIVE_PRIORITY_DEFAULT   = 32768
+
+	DRNI_KEEPALIVE_VERSION = 1
+	DRNI_KEEPALIVE_PDU_LEN = 16
+)
+
+// keepalive flags
+const (
+	DRNI_KEEPALIVE_FLAG_IPL_UP = 1 << iota
+	DRNI_KEEPALIVE_FLAG_OUT_OF_SERVICE
+)
+
+const KeepaliveModuleStr = "DRNI Keepalive"
+
+// DrniKeepalivePdu is sent periodically over UDP between the portal systems
+// using a management network which is independent of the IPLs
+//
+//	0       : version
+//	1 - 6   : portal address
+//	7       : portal system number
+//	8 - 9   : keepalive priority
+//	10      : flags
+//	11      : reserved
+//	12 - 15 : sequence
+type DrniKeepalivePdu struct {
+	Version            uint8
+	PortalAddr         net.HardwareAddr
+	PortalSystemNumber uint8
+	Priority           uint16
+	Flags              uint8
+	Sequence           uint32
+}
+
+// DrniKeepalive holds the state of the keepalive session with the neighbor
+// portal system
+type DrniKeepalive struct {
+	dr       *DistributedRelay
+	conn     *net.UDPConn
+	peerAddr *net.UDPAddr
+	interval time.Duration
+	timeout  time.Duration
+
+	mutex                  *sync.Mutex
+	peerAlive              bool
+	peerPriority           uint16
+	peerPortalSystemNumber uint8
+	peerFlags              uint8
+	lastRxTime             time.Time
+	sequence               uint32
+	// local state advertised in the flags, owned by the portal
+	// system machine
+	localPSI          bool
+	localOutOfService bool
+
+	// stats
+	KeepalivesTx        uint32
+	KeepalivesRx        uint32
+	KeepalivesIllegalRx uint32
+
+	rxPdu chan *DrniKeepalivePdu
+	stop  chan bool
+	wg    sync.WaitGroup
+}
+
+func (pdu *DrniKeepalivePdu) encode() []byte {
+	b := make([]byte, DRNI_KEEPALIVE_PDU_LEN)
+	b[0] = pdu.Version
+	copy(b[1:7], pdu.PortalAddr)
+	b[7] = pdu.PortalSystemNumber
+	binary.BigEndian.PutUint16(b[8:10], pdu.Priority)
+	b[10] = pdu.Flags
+	binary.BigEndian.PutUint32(b[12:16], pdu.Sequence)
+	return b
+}
+
+func decodeDrniKeepalivePdu(b []byte) (*DrniKeepalivePdu, error) {
+	if len(b) < DRNI_KEEPALIVE_PDU_LEN {
+		return nil, errors.New(fmt.Sprintf("ERROR Keepalive PDU length %d too short", len(b)))
+	}
+	if b[0] != DRNI_KEEPALIVE_VERSION {
+		return nil, errors.New(fmt.Sprintf("ERROR Keepalive PDU version %d not supported", b[0]))
+	}
+	pdu := &DrniKeepalivePdu{
+		Version:            b[0],
+		PortalAddr:         make(net.HardwareAddr, 6),
+		PortalSystemNumber: b[7],
+		Priority:           binary.BigEndian.Uint16(b[8:10]),
+		Flags:              b[10],
+		Sequence:           binary.BigEndian.Uint32(b[12:16]),
+	}
+	copy(pdu.PortalAddr, b[1:7])
+	return pdu, nil
+}
+
+// NewDrniKeepalive will open the keepalive socket on the source address, the
+// session is not started until Start is called
+func NewDrniKeepalive(dr *DistributedRelay) (*DrniKeepalive, error) {
+	srcAddr := &net.UDPAddr{IP: dr.DrniKeepaliveSourceIp, Port: int(dr.DrniKeepaliveUdpPort)}
+	peerAddr := &net.UDPAddr{IP: dr.DrniKeepaliveDestIp, Port: int(dr.DrniKeepaliveUdpPort)}
+
+	conn, err := net.ListenUDP("udp", srcAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(dr.DrniKeepaliveInterval) * time.Millisecond
+	ka := &DrniKeepalive{
+		dr:       dr,
+		conn:     conn,
+		peerAddr: peerAddr,
+		interval: interval,
+		timeout:  interval * DRNI_KEEPALIVE_TIMEOUT_MULTIPLIER,
+		mutex:    &sync.Mutex{},
+		// until the peer is heard from assume it has the same
+		// priority, thus the portal system number decides
+		peerPriority:           dr.DrniKeepalivePriority,
+		peerPortalSystemNumber: dr.ippNeighborPortalSystemNumber(0),
+		localPSI:               dr.DrniPSI,
+		localOutOfService:      dr.SplitBrainOutOfService,
+		rxPdu:                  make(chan *DrniKeepalivePdu, 10),
+		stop:                   make(chan bool),
+	}
+	return ka, nil
+}
+
+// Start will start the tx/rx of keepalives with the peer
+func (ka *DrniKeepalive) Start() {
+	ka.wg.Add(2)
+
+	go func(ka *DrniKeepalive) {
+		defer ka.wg.Done()
+		buf := make([]byte, 256)
+		for {
+			n, _, err := ka.conn.ReadFromUDP(buf)
+			if err != nil {
+				// socket is closed as part of stop
+				select {
+				case <-ka.stop:
+					return
+				default:
+					continue
+				}
+			}
+			pdu, err := decodeDrniKeepalivePdu(buf[:n])
+			if err != nil {
+				ka.mutex.Lock()
+				ka.KeepalivesIllegalRx++
+				ka.mutex.Unlock()
+				continue
+			}
+			select {
+			case ka.rxPdu <- pdu:
+			case <-ka.stop:
+				return
+			}
+		}
+	}(ka)
+
+	go func(ka *DrniKeepalive) {
+		defer ka.wg.Done()
+		ka.dr.LaDrLog(fmt.Sprintf("%s: Start peer %s interval %s", KeepaliveModuleStr, ka.peerAddr, ka.interval))
+		ticker := time.NewTicker(ka.interval)
+		defer ticker.Stop()
+		ka.sendKeepalive()
+		for {
+			select {
+			case <-ticker.C:
+				ka.sendKeepalive()
+				ka.checkPeerTimeout()
+			case pdu := <-ka.rxPdu:
+				ka.processKeepalive(pdu)
+			case <-ka.stop:
+				ka.dr.LaDrLog(fmt.Sprintf("%s: Stop", KeepaliveModuleStr))
+				return
+			}
+		}
+	}(ka)
+}
+
+// Stop will stop the keepalive session and close the socket
+func (ka *DrniKeepalive) Stop() {
+	close(ka.stop)
+	ka.conn.Close()
+	ka.wg.Wait()
+}
+
+func (ka *DrniKeepalive) sendKeepalive() {
+	dr := ka.dr
+	var flags uint8
+	ka.mutex.Lock()
+	if !ka.localPSI {
+		flags |= DRNI_KEEPALIVE_FLAG_IPL_UP
+	}
+	if ka.localOutOfService {
+		flags |= DRNI_KEEPALIVE_FLAG_OUT_OF_SERVICE
+	}
+	ka.mutex.Unlock()
+	ka.sequence++
+	pdu := &DrniKeepalivePdu{
+		Version:            DRNI_KEEPALIVE_VERSION,
+		PortalAddr:         dr.DrniPortalAddr,
+		PortalSystemNumber: dr.DrniPortalSystemNumber,
+		Priority:           dr.DrniKeepalivePriority,
+		Flags:              flags,
+		Sequence:           ka.sequence,
+	}
+	_, err := ka.conn.WriteToUDP(pdu.encode(), ka.peerAddr)
+	if err == nil {
+		ka.mutex.Lock()
+		ka.KeepalivesTx++
+		ka.mutex.Unlock()
+	}
+}
+
+// processKeepalive validates that the keepalive is from the neighbor portal
+// system within the same portal and updates the peer state
+func (ka *DrniKeepalive) processKeepalive(pdu *DrniKeepalivePdu) {
+	dr := ka.dr
+	ka.mutex.Lock()
+	if pdu.PortalAddr.String() != dr.DrniPortalAddr.String() ||
+		pdu.PortalSystemNumber == dr.DrniPortalSystemNumber {
+		ka.KeepalivesIllegalRx++
+		ka.mutex.Unlock()
+		return
+	}
+	ka.KeepalivesRx++
+	ka.lastRxTime = time.Now()
+	changed := !ka.peerAlive ||
+		ka.peerPriority != pdu.Priority ||
+		ka.peerPortalSystemNumber != pdu.PortalSystemNumber
+	ka.peerAlive = true
+	ka.peerPriority = pdu.Priority
+	ka.peerPortalSystemNumber = pdu.PortalSystemNumber
+	ka.peerFlags = pdu.Flags
+	ka.mutex.Unlock()
+
+	if changed {
+		dr.LaDrLog(fmt.Sprintf("%s: Peer portal system %d priority %d is alive", KeepaliveModuleStr, pdu.PortalSystemNumber, pdu.Priority))
+		ka.notifyPeerChange()
+	}
+}
+
+func (ka *DrniKeepalive) checkPeerTimeout() {
+	ka.mutex.Lock()
+	expired := ka.peerAlive &&
+		time.Since(ka.lastRxTime) > ka.timeout
+	if expired {
+		ka.peerAlive = false
+	}
+	ka.mutex.Unlock()
+
+	if expired {
+		ka.dr.LaDrLog(fmt.Sprintf("%s: Peer portal system %d keepalive timeout", KeepaliveModuleStr, ka.peerPortalSystemNumber))
+		ka.notifyPeerChange()
+	}
+}
+
+// notifyPeerChange lets the portal system machine re-evaluate whether
+// the aggregator ports should be in service
+func (ka *DrniKeepalive) notifyPeerChange() {
+	dr := ka.dr
+	if dr.PsMachineFsm != nil {
+		dr.PsMachineFsm.PsmEvents <- utils.MachineEvent{
+			E:   PsmEventSplitBrainChange,
+			Src: KeepaliveModuleStr,
+		}
+	}
+}
+
+// setLocalState records the local state advertised to the peer, called by
+// the portal system machine
+func (ka *DrniKeepalive) setLocalState(psi, outOfService bool) {
+	ka.mutex.Lock()
+	ka.localPSI = psi
+	ka.localOutOfService = outOfService
+	ka.mutex.Unlock()
+}
+
+// isPeerOutOfService returns true when the peer has advertised that its
+// aggregator ports are out of service
+func (ka *DrniKeepalive) isPeerOutOfService() bool {
+	ka.mutex.Lock()
+	defer ka.mutex.Unlock()
+	return ka.peerAlive &&
+		ka.peerFlags&DRNI_KEEPALIVE_FLAG_OUT_OF_SERVICE != 0
+}
+
+// isPeerIplUp returns true when the peer has advertised that at least one
+// of its IPLs is up
+func (ka *DrniKeepalive) isPeerIplUp() bool {
+	ka.mutex.Lock()
+	defer ka.mutex.Unlock()
+	return ka.peerAlive &&
+		ka.peerFlags&DRNI_KEEPALIVE_FLAG_IPL_UP != 0
+}
+
+// IsPeerAlive returns true if keepalives are being received from the peer
+func (ka *DrniKeepalive) IsPeerAlive() bool {
+	ka.mutex.Lock()
+	defer ka.mutex.Unlock()
+	return ka.peerAlive
+}
+
+// isLowerPriority returns true when this portal system has a lower priority
+// than the peer, numerically higher value is lower priority and the portal
+// system number is used as the tie breaker
+func (ka *DrniKeepalive) isLowerPriority() bool {
+	dr := ka.dr
+	ka.mutex.Lock()
+	defer ka.mutex.Unlock()
+	if dr.DrniKeepalivePriority != ka.peerPriority {
+		return dr.DrniKeepalivePriority > ka.peerPriority
+	}
+	return dr.DrniPortalSystemNumber > ka.peerPortalSystemNumber
+}
+
+// DrcpKeepaliveStart will start the peer keepalive if it has been configured
+func (dr *DistributedRelay) DrcpKeepaliveStart() {
+	if dr.DrniKeepaliveDestIp == nil ||
+		dr.keepalive != nil {
+		return
+	}
+	ka, err := NewDrniKeepalive(dr)
+	if err != nil {
+		dr.LaDrLog(fmt.Sprintf("ERROR %s: unable to open socket %s", KeepaliveModuleStr, err))
+		return
+	}
+	dr.keepalive = ka
+	ka.Start()
+}
+
+// DrcpKeepaliveStop will stop the peer keepalive
+func (dr *DistributedRelay) DrcpKeepaliveStop() {
+	if dr.keepalive != nil {
+		dr.keepalive.Stop()
+		dr.keepalive = nil
+	}
+}
+
+// isSplitBrainOutOfService determines if the aggregator ports should be taken
+// out of service.  Only applies when all IPLs are down, if the peer keepalive
+// is alive the neighbor is still forwarding as the same portal thus the lower
+// priority portal system must stop forwarding, unless the peer has already
+// taken itself out of service.  A peer which still has an IPL up keeps
+// forwarding thus this portal system must stop.  Otherwise the split brain
+// policy is applied
+func (dr *DistributedRelay) isSplitBrainOutOfService() bool {
+	ka := dr.keepalive
+	if !dr.DrniPSI ||
+		ka == nil {
+		return false
+	}
+
+	if ka.IsPeerAlive() {
+		// one portal system must keep forwarding for the portal
+		if ka.isPeerOutOfService() {
+			return false
+		}
+		// peer still sees the IPL up and will keep forwarding, thus this
+		// isolated portal system must stop regardless of priority
+		if ka.isPeerIplUp() {
+			return true
+		}
+		return ka.isLowerPriority()
+	}
+
+	switch dr.DrniSplitBrainPolicy {
+	case DRNI_SPLIT_BRAIN_POLICY_LOWER_PRIORITY_DOWN:
+		return ka.isLowerPriority()
+	case DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN:
+		return true
+	}
+	return false
+}
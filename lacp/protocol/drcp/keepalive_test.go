@@ -0,0 +1,301 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// keepalive_test.go
+package drcp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+const keepaliveTestUdpPort = 16400
+
+func keepaliveTestDistributedRelay(sysnum uint8, srcip, dstip string) *DistributedRelay {
+	portaladdr, _ := net.ParseMAC("00:00:DE:AD:BE:EF")
+	return &DistributedRelay{
+		DrniName:               "DR-1",
+		DrniPortalAddr:         portaladdr,
+		DrniPortalSystemNumber: sysnum,
+		DrniKeepaliveSourceIp:  net.ParseIP(srcip),
+		DrniKeepaliveDestIp:    net.ParseIP(dstip),
+		DrniKeepaliveUdpPort:   keepaliveTestUdpPort,
+		DrniKeepaliveInterval:  DRNI_KEEPALIVE_INTERVAL_MIN,
+		DrniKeepalivePriority:  DRNI_KEEPALIVE_PRIORITY_DEFAULT,
+		DrniSplitBrainPolicy:   DRNI_SPLIT_BRAIN_POLICY_NONE,
+		// IPLs are down
+		DrniPSI: true,
+	}
+}
+
+func keepaliveTestWaitPeerAlive(dr *DistributedRelay, alive bool) bool {
+	for i := 0; i < 50; i++ {
+		if dr.keepalive.IsPeerAlive() == alive {
+			return true
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	return false
+}
+
+func TestKeepalivePduEncodeDecode(t *testing.T) {
+	portaladdr, _ := net.ParseMAC("00:00:DE:AD:BE:EF")
+	pdu := &DrniKeepalivePdu{
+		Version:            DRNI_KEEPALIVE_VERSION,
+		PortalAddr:         portaladdr,
+		PortalSystemNumber: 2,
+		Priority:           100,
+		Flags:              DRNI_KEEPALIVE_FLAG_IPL_UP,
+		Sequence:           0x01020304,
+	}
+
+	b := pdu.encode()
+	if len(b) != DRNI_KEEPALIVE_PDU_LEN {
+		t.Error("ERROR Invalid encoded keepalive length", len(b))
+	}
+
+	rxpdu, err := decodeDrniKeepalivePdu(b)
+	if err != nil {
+		t.Error("ERROR decoding keepalive", err)
+		return
+	}
+	if rxpdu.PortalAddr.String() != pdu.PortalAddr.String() ||
+		rxpdu.PortalSystemNumber != pdu.PortalSystemNumber ||
+		rxpdu.Priority != pdu.Priority ||
+		rxpdu.Flags != pdu.Flags ||
+		rxpdu.Sequence != pdu.Sequence {
+		t.Errorf("ERROR decoded keepalive %+v does not match %+v", rxpdu, pdu)
+	}
+
+	if _, err = decodeDrniKeepalivePdu(b[:DRNI_KEEPALIVE_PDU_LEN-1]); err == nil {
+		t.Error("ERROR short keepalive should have failed to decode")
+	}
+	b[0] = DRNI_KEEPALIVE_VERSION + 1
+	if _, err = decodeDrniKeepalivePdu(b); err == nil {
+		t.Error("ERROR unsupported keepalive version should have failed to decode")
+	}
+}
+
+// TestKeepaliveLoopback runs the keepalive of two portal systems
+// over loopback while the IPLs are down
+func TestKeepaliveLoopback(t *testing.T) {
+
+	dr1 := keepaliveTestDistributedRelay(1, "127.0.0.1", "127.0.0.2")
+	dr2 := keepaliveTestDistributedRelay(2, "127.0.0.2", "127.0.0.1")
+	dr1.DrniKeepalivePriority = DRNI_KEEPALIVE_PRIORITY_DEFAULT + 1
+
+	dr1.DrcpKeepaliveStart()
+	dr2.DrcpKeepaliveStart()
+	if dr1.keepalive == nil ||
+		dr2.keepalive == nil {
+		t.Error("ERROR Unable to start keepalive over loopback")
+		dr1.DrcpKeepaliveStop()
+		dr2.DrcpKeepaliveStop()
+		return
+	}
+	defer dr2.DrcpKeepaliveStop()
+
+	if !keepaliveTestWaitPeerAlive(dr1, true) ||
+		!keepaliveTestWaitPeerAlive(dr2, true) {
+		t.Error("ERROR Peer keepalive never came up")
+		dr1.DrcpKeepaliveStop()
+		return
+	}
+
+	// priority learned from the peer takes precedence over the
+	// portal system number
+	if !dr1.keepalive.isLowerPriority() ||
+		dr2.keepalive.isLowerPriority() {
+		t.Error("ERROR Portal System 1 should be lower priority")
+	}
+	if !dr1.isSplitBrainOutOfService() {
+		t.Error("ERROR Portal System 1 should be out of service")
+	}
+	if dr2.isSplitBrainOutOfService() {
+		t.Error("ERROR Portal System 2 should remain in service")
+	}
+
+	// neighbor system goes away
+	dr1.DrcpKeepaliveStop()
+	if !keepaliveTestWaitPeerAlive(dr2, false) {
+		t.Error("ERROR Peer keepalive never timed out")
+	}
+}
+
+func TestKeepaliveSplitBrainPolicy(t *testing.T) {
+
+	dr := keepaliveTestDistributedRelay(2, "127.0.0.2", "127.0.0.1")
+	// no session is started, peer state is set directly
+	dr.keepalive = &DrniKeepalive{
+		dr:                     dr,
+		mutex:                  &sync.Mutex{},
+		peerPriority:           DRNI_KEEPALIVE_PRIORITY_DEFAULT,
+		peerPortalSystemNumber: 1,
+	}
+
+	for _, tc := range []struct {
+		psi          bool
+		peerAlive    bool
+		policy       string
+		outOfService bool
+	}{
+		// IPL is up no split brain
+		{false, true, DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN, false},
+		{false, false, DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN, false},
+		// IPL is down but neighbor is alive, lower priority goes down
+		{true, true, DRNI_SPLIT_BRAIN_POLICY_NONE, true},
+		// IPL and keepalive are down, apply the policy
+		{true, false, DRNI_SPLIT_BRAIN_POLICY_NONE, false},
+		{true, false, DRNI_SPLIT_BRAIN_POLICY_LOWER_PRIORITY_DOWN, true},
+		{true, false, DRNI_SPLIT_BRAIN_POLICY_ALL_DOWN, true},
+	} {
+		dr.DrniPSI = tc.psi
+		dr.keepalive.peerAlive = tc.peerAlive
+		dr.DrniSplitBrainPolicy = tc.policy
+		if dr.isSplitBrainOutOfService() != tc.outOfService {
+			t.Errorf("ERROR psi %t peer alive %t policy %s expected out of service %t", tc.psi, tc.peerAlive, tc.policy, tc.outOfService)
+		}
+	}
+
+	// higher priority system never goes down unless all must go down
+	dr.keepalive.peerPriority = DRNI_KEEPALIVE_PRIORITY_DEFAULT + 1
+	dr.DrniPSI = true
+	dr.keepalive.peerAlive = false
+	dr.DrniSplitBrainPolicy = DRNI_SPLIT_BRAIN_POLICY_LOWER_PRIORITY_DOWN
+	if dr.isSplitBrainOutOfService() {
+		t.Error("ERROR higher priority Portal System should remain in service")
+	}
+
+	// lower priority system stays in service when the peer has already
+	// taken itself out of service
+	dr.keepalive.peerPriority = DRNI_KEEPALIVE_PRIORITY_DEFAULT
+	dr.keepalive.peerAlive = true
+	dr.keepalive.peerFlags = DRNI_KEEPALIVE_FLAG_OUT_OF_SERVICE
+	dr.DrniSplitBrainPolicy = DRNI_SPLIT_BRAIN_POLICY_NONE
+	if dr.isSplitBrainOutOfService() {
+		t.Error("ERROR Portal System should remain in service when peer is out of service")
+	}
+}
+
+func TestKeepaliveIllegalPortal(t *testing.T) {
+
+	dr1 := keepaliveTestDistributedRelay(1, "127.0.0.1", "127.0.0.2")
+	dr2 := keepaliveTestDistributedRelay(2, "127.0.0.2", "127.0.0.1")
+	// different portal
+	dr2.DrniPortalAddr, _ = net.ParseMAC("00:00:DE:AD:BE:EE")
+
+	dr1.DrcpKeepaliveStart()
+	dr2.DrcpKeepaliveStart()
+	if dr1.keepalive == nil ||
+		dr2.keepalive == nil {
+		t.Error("ERROR Unable to start keepalive over loopback")
+		dr1.DrcpKeepaliveStop()
+		dr2.DrcpKeepaliveStop()
+		return
+	}
+	defer dr1.DrcpKeepaliveStop()
+	defer dr2.DrcpKeepaliveStop()
+
+	time.Sleep(time.Millisecond * DRNI_KEEPALIVE_INTERVAL_MIN * 3)
+
+	if dr1.keepalive.IsPeerAlive() {
+		t.Error("ERROR Keepalive from another portal should be ignored")
+	}
+	dr1.keepalive.mutex.Lock()
+	illegalRx := dr1.keepalive.KeepalivesIllegalRx
+	dr1.keepalive.mutex.Unlock()
+	if illegalRx == 0 {
+		t.Error("ERROR Keepalive from another portal should be counted as illegal")
+	}
+}
+
+// TestKeepaliveSplitBrainPortalSystemMachine checks that the portal system
+// machine only brings the aggregator ports back into service once syncing is
+// allowed, and that a peer advertising its IPL up is honored
+func TestKeepaliveSplitBrainPortalSystemMachine(t *testing.T) {
+
+	dr := keepaliveTestDistributedRelay(2, "127.0.0.2", "127.0.0.1")
+	dr.keepalive = &DrniKeepalive{
+		dr:                     dr,
+		mutex:                  &sync.Mutex{},
+		peerPriority:           DRNI_KEEPALIVE_PRIORITY_DEFAULT,
+		peerPortalSystemNumber: 1,
+		peerAlive:              true,
+	}
+	ipp := &DRCPIpp{}
+	dr.Ipplinks = append(dr.Ipplinks, ipp)
+	psm := NewDrcpPsMachine(dr)
+
+	// IPL is down and the peer is alive, lower priority goes out of service
+	psm.updateSplitBrainState()
+	if !dr.SplitBrainOutOfService {
+		t.Error("ERROR Portal System 2 should be out of service")
+	}
+	if !dr.keepalive.localPSI ||
+		!dr.keepalive.localOutOfService {
+		t.Error("ERROR keepalive should advertise IPL down and out of service", dr.keepalive.localPSI, dr.keepalive.localOutOfService)
+	}
+
+	// peer went away, no split brain policy but the isolated portal
+	// system has no home gateway thus must remain unsynced
+	dr.keepalive.peerAlive = false
+	psm.updateSplitBrainState()
+	if !dr.SplitBrainOutOfService {
+		t.Error("ERROR isolated Portal System without a home gateway should remain out of service")
+	}
+	dr.DRFHomeOperDRCPState.SetState(layers.DRCPStateHomeGatewayBit)
+	psm.updateSplitBrainState()
+	if dr.SplitBrainOutOfService {
+		t.Error("ERROR isolated Portal System with a home gateway should be back in service")
+	}
+
+	// peer still sees the IPL up, this portal system must go out of service
+	// even though it has the higher priority
+	dr.keepalive.peerAlive = true
+	dr.keepalive.peerPriority = DRNI_KEEPALIVE_PRIORITY_DEFAULT + 1
+	dr.keepalive.peerFlags = DRNI_KEEPALIVE_FLAG_IPL_UP
+	psm.updateSplitBrainState()
+	if !dr.SplitBrainOutOfService {
+		t.Error("ERROR Portal System should be out of service when peer IPL is up")
+	}
+
+	// IPL recovers, ports wait for the neighbor to sync
+	dr.DrniPSI = false
+	psm.updateSplitBrainState()
+	if !dr.SplitBrainOutOfService {
+		t.Error("ERROR Portal System should remain out of service until the neighbor is synced")
+	}
+	if dr.keepalive.localPSI ||
+		dr.keepalive.localOutOfService {
+		t.Error("ERROR keepalive should advertise IPL up and in service", dr.keepalive.localPSI, dr.keepalive.localOutOfService)
+	}
+	ipp.DRFNeighborOperDRCPState.SetState(layers.DRCPStatePortSync)
+	psm.updateSplitBrainState()
+	if dr.SplitBrainOutOfService {
+		t.Error("ERROR Portal System should be back in service once the neighbor is synced")
+	}
+}
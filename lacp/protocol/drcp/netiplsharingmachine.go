@@ -27,6 +27,7 @@
 package drcp
 
 import (
+	"fmt"
 	"l2/lacp/protocol/utils"
 	"strconv"
 	"strings"
@@ -125,6 +126,9 @@ func (nism *NetIplShareMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
 func (nism *NetIplShareMachine) DrcpNetIplShareMachineNoManipulatedFramesSent(m fsm.Machine, data interface{}) fsm.State {
 
 	p := nism.p
+	if p.EnabledEncTagShared {
+		nism.setIplEncapTranslation(false)
+	}
 	p.EnabledTimeShared = false
 	p.EnabledEncTagShared = false
 	return NetIplSharemStateNoManipulatedFramesSent
@@ -144,9 +148,38 @@ func (nism *NetIplShareMachine) DrcpNetIplShareMachineTimeSharedMethod(m fsm.Mac
 func (nism *NetIplShareMachine) DrcpNetIplShareMachineManipulatedFramesSent(m fsm.Machine, data interface{}) fsm.State {
 	p := nism.p
 	p.EnabledEncTagShared = true
+	nism.setIplEncapTranslation(true)
 	return NetIplSharemStateManipulatedFramesSent
 }
 
+// setIplEncapTranslation will program the translation of the frames of each
+// gateway conversation in the IPL Encap Map to the IPL identifier on egress of
+// the IPP and back to the network identifier on ingress
+func (nism *NetIplShareMachine) setIplEncapTranslation(enable bool) {
+	p := nism.p
+	dr := p.dr
+
+	for _, client := range utils.GetDrniPluginList("IppEncapTranslationSet") {
+		for cid := uint32(0); cid < MAX_CONVERSATION_IDS; cid++ {
+			iplId, ok := dr.DrniIPLEncapMap[cid]
+			if !ok {
+				continue
+			}
+			// 0 means the conversation is not translated on the network
+			netId := dr.DrniNetEncapMap[cid]
+			var err error
+			if enable {
+				err = client.IppEncapTranslationSet(int32(p.Id), dr.DrniEncapMethod.String(), uint16(cid), iplId, netId)
+			} else {
+				err = client.IppEncapTranslationClear(int32(p.Id), dr.DrniEncapMethod.String(), uint16(cid), iplId, netId)
+			}
+			if err != nil {
+				nism.DrcpNetIplSharemLog(fmt.Sprintf("ERROR updating IPL encap translation conversation %d ipl id %d net id %d: %s", cid, iplId, netId, err))
+			}
+		}
+	}
+}
+
 // DrcpNetIplShareMachineFSMBuild will build the State machine with callbacks
 func DrcpNetIplShareMachineFSMBuild(p *DRCPIpp) *NetIplShareMachine {
 
@@ -251,7 +284,7 @@ func (nism *NetIplShareMachine) processPostStates() {
 		}
 	} else if nism.Machine.Curr.CurrentState() == NetIplSharemStateTimeShareMethod {
 		if !p.CCTimeShared {
-			rv := nism.Machine.ProcessEvent(NetIplShareMachineModuleStr, NetIplSharemEventCCTimeShare, nil)
+			rv := nism.Machine.ProcessEvent(NetIplShareMachineModuleStr, NetIplSharemEventNotCCTimeShare, nil)
 			if rv == nil {
 				nism.processPostStates()
 			}
@@ -0,0 +1,169 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// netiplsharingmachine_test.go
+package drcp
+
+import (
+	"crypto/md5"
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+	"testing"
+)
+
+type NetIplShareTestMock struct {
+	MyTestMock
+	DrniTestMock
+	// conversation id -> ipl id, net id
+	translations map[uint16][2]uint32
+}
+
+func (m *NetIplShareTestMock) IppEncapTranslationSet(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error {
+	m.translations[cid] = [2]uint32{iplId, netId}
+	return nil
+}
+
+func (m *NetIplShareTestMock) IppEncapTranslationClear(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error {
+	delete(m.translations, cid)
+	return nil
+}
+
+func TestNetIplShareMachineSharingByTagTranslation(t *testing.T) {
+	ConfigTestSetup()
+	mock := &NetIplShareTestMock{
+		translations: make(map[uint16][2]uint32),
+	}
+	utils.DeleteAllAsicDPlugins()
+	utils.SetAsicDPlugin(mock)
+	a := OnlyForTestSetupCreateAggGroup(100)
+
+	cfg := &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    100,
+		DrniGatewayAlgorithm:              "00:80:C2:01",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:01",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:02",
+		DrniIPLEncapMap:                   map[uint32]uint32{100: 1100, 101: 1101},
+		DrniNetEncapMap:                   map[uint32]uint32{100: 2100},
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03",
+	}
+
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+
+	dr := NewDistributedRelay(cfg)
+	dr.a = a
+	ipp := dr.Ipplinks[0]
+
+	if ipp.DRFHomeNetworkIPLIPLEncapDigest != (Md5Digest{}.calculateEncapDigest(cfg.DrniIPLEncapMap)) ||
+		ipp.DRFHomeNetworkIPLIPLNetEncapDigest != (Md5Digest{}.calculateEncapDigest(cfg.DrniNetEncapMap)) {
+		t.Error("ERROR Home IPL/Net encap digests not set from the encap maps")
+	}
+	if ipp.DRFHomeNetworkIPLIPLEncapDigest == ipp.DRFHomeNetworkIPLIPLNetEncapDigest {
+		t.Error("ERROR different encap maps produced the same digest")
+	}
+
+	ipp.NetIplShareMachineMain()
+
+	responseChan := make(chan string)
+	ipp.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+		E:            NetIplSharemEventBegin,
+		Src:          "NET IPL SHARE TEST",
+		ResponseChan: responseChan,
+	}
+	<-responseChan
+
+	if ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState() != NetIplSharemStateNoManipulatedFramesSent {
+		t.Error("ERROR Net/IPL Sharing Machine not in expected state", NetIplSharemStateStrMap[ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState()])
+	}
+
+	// neighbor agrees on encap method and digests
+	ipp.CCEncTagShared = true
+	ipp.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+		E:            NetIplSharemEventCCEncTagShared,
+		Src:          "NET IPL SHARE TEST",
+		ResponseChan: responseChan,
+	}
+	<-responseChan
+
+	if ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState() != NetIplSharemStateManipulatedFramesSent ||
+		!ipp.EnabledEncTagShared {
+		t.Error("ERROR Net/IPL Sharing Machine not in expected state", NetIplSharemStateStrMap[ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState()])
+	}
+	if len(mock.translations) != 2 ||
+		mock.translations[100] != [2]uint32{1100, 2100} ||
+		mock.translations[101] != [2]uint32{1101, 0} {
+		t.Error("ERROR IPL encap translation not programmed as expected", mock.translations)
+	}
+
+	// neighbor digest changed
+	ipp.CCEncTagShared = false
+	ipp.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+		E:            NetIplSharemEventNotCCEncTagShared,
+		Src:          "NET IPL SHARE TEST",
+		ResponseChan: responseChan,
+	}
+	<-responseChan
+
+	if ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState() != NetIplSharemStateNoManipulatedFramesSent ||
+		ipp.EnabledEncTagShared {
+		t.Error("ERROR Net/IPL Sharing Machine not in expected state", NetIplSharemStateStrMap[ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState()])
+	}
+	if len(mock.translations) != 0 {
+		t.Error("ERROR IPL encap translation not cleared as expected", mock.translations)
+	}
+
+	dr.DeleteDistributedRelay()
+	lacp.DeleteLaAgg(a.AggId)
+	ConfigTestTeardwon(t)
+}
+
+func TestCalculateEncapDigest(t *testing.T) {
+	var digest Md5Digest
+
+	// every conversation contributes a 4 octet identifier, zero when unset
+	expected := md5.Sum(make([]byte, MAX_CONVERSATION_IDS*4))
+	if digest.calculateEncapDigest(map[uint32]uint32{}) != Md5Digest(expected) {
+		t.Error("ERROR empty Encap Map digest should be the digest of all zero identifiers")
+	}
+	if digest.calculateEncapDigest(map[uint32]uint32{100: 0}) != Md5Digest(expected) {
+		t.Error("ERROR zero identifier should not change the Encap Map digest")
+	}
+
+	b := make([]byte, MAX_CONVERSATION_IDS*4)
+	b[100*4+3] = 200
+	expected = md5.Sum(b)
+	if digest.calculateEncapDigest(map[uint32]uint32{100: 200}) != Md5Digest(expected) {
+		t.Error("ERROR Encap Map digest does not match 802.1AX-2014 9.4.3.2")
+	}
+}
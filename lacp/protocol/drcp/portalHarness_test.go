@@ -0,0 +1,850 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+// portalHarness_test.go
+package drcp
+
+import (
+	"fmt"
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+	"net"
+	"sync"
+	"testing"
+	"time"
+	asicdmock "utils/asicdClient/mock"
+	"utils/commonDefs"
+	"utils/logging"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// The portal harness runs two or three independent Portal Systems within
+// the same process.  Each Portal System consists of a Distributed Relay and
+// a LACP system whose aggregator has a single port connected to a common
+// partner LACP system.  Portal Systems are connected to each other via
+// simulated Intra Portal Links, in a 3P portal the links form a ring.
+//
+//                     partner
+//             /          |          \
+//       system 1 --- system 2 --- system 3
+//           \_______________________/      (3P only)
+//
+
+const PortalHarnessVlan = 100
+const PortalHarnessPeerAggId = 200
+const PortalHarnessPortalAddr = "00:00:DE:AD:BE:EF"
+const PortalHarnessWaitTime = time.Second * 15
+
+func portalHarnessAggPort(num uint8) int32 {
+	return 80 + int32(num)
+}
+
+func portalHarnessPeerPort(num uint8) int32 {
+	return 90 + int32(num)
+}
+
+func portalHarnessIppPort(home, neighbor uint8) int32 {
+	return 100 + int32(home)*10 + int32(neighbor)
+}
+
+func portalHarnessAggId(num uint8) int {
+	return 100 * (2*int(num) - 1)
+}
+
+// PortalHarnessAsicdMock records the IPP state programmed by every
+// Portal System of the harness
+type PortalHarnessAsicdMock struct {
+	asicdmock.MockAsicdClientMgr
+	DrniTestMock
+	mutex             *sync.Mutex
+	vlanPortList      []int32
+	ippVlanConv       map[int32]map[uint16]bool
+	ingressEgressPass int
+	ingressEgressDrop int
+	fdbFlush          int
+}
+
+func NewPortalHarnessAsicdMock(vlanPortList []int32) *PortalHarnessAsicdMock {
+	return &PortalHarnessAsicdMock{
+		mutex:        &sync.Mutex{},
+		vlanPortList: vlanPortList,
+		ippVlanConv:  make(map[int32]map[uint16]bool),
+	}
+}
+
+func (m *PortalHarnessAsicdMock) GetBulkVlan(curMark, count int) (*commonDefs.VlanGetInfo, error) {
+
+	// consecutive vlans so that the conversations land on every
+	// portal system of a 3P portal
+	getinfo := &commonDefs.VlanGetInfo{
+		StartIdx: 1,
+		EndIdx:   3,
+		Count:    3,
+		More:     false,
+		VlanList: []commonDefs.Vlan{
+			{VlanId: PortalHarnessVlan, IfIndexList: m.vlanPortList},
+			{VlanId: PortalHarnessVlan + 1, IfIndexList: m.vlanPortList},
+			{VlanId: PortalHarnessVlan + 2, IfIndexList: m.vlanPortList},
+		},
+	}
+
+	return getinfo, nil
+}
+
+func (m *PortalHarnessAsicdMock) GetPortLinkStatus(port int32) bool {
+	return true
+}
+
+func (m *PortalHarnessAsicdMock) IppVlanConversationSet(cid uint16, ippid int32) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.ippVlanConv[ippid]; !ok {
+		m.ippVlanConv[ippid] = make(map[uint16]bool)
+	}
+	m.ippVlanConv[ippid][cid] = true
+	return nil
+}
+
+func (m *PortalHarnessAsicdMock) IppVlanConversationClear(cid uint16, ippid int32) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.ippVlanConv[ippid]; ok {
+		delete(m.ippVlanConv[ippid], cid)
+	}
+	return nil
+}
+
+func (m *PortalHarnessAsicdMock) IppIngressEgressDrop(inport, aggport string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ingressEgressDrop++
+	return nil
+}
+
+func (m *PortalHarnessAsicdMock) IppIngressEgressPass(inport, aggport string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.ingressEgressPass++
+	return nil
+}
+
+// IsIppVlanConversationSet returns whether the conversation vlan membership
+// is currently programmed on the ipp
+func (m *PortalHarnessAsicdMock) IsIppVlanConversationSet(ippid int32, cid uint16) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.ippVlanConv[ippid][cid]
+}
+
+func (m *PortalHarnessAsicdMock) IngressEgressPassCnt() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.ingressEgressPass
+}
+
+func (m *PortalHarnessAsicdMock) IngressEgressDropCnt() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.ingressEgressDrop
+}
+
+func (m *PortalHarnessAsicdMock) FdbFlushByConversation(cidList []uint16, ifindexList []int32) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.fdbFlush++
+	return nil
+}
+
+func (m *PortalHarnessAsicdMock) FdbFlushByPort(ifindex int32) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.fdbFlush++
+	return nil
+}
+
+func (m *PortalHarnessAsicdMock) FdbFlushCnt() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.fdbFlush
+}
+
+// PortalHarnessSystem is a single Portal System along with the link from
+// its aggregator port to the partner system
+type PortalHarnessSystem struct {
+	num       uint8
+	cfg       DistributedRelayConfig
+	lasys     lacp.LacpSystem
+	aconf     *lacp.LaAggConfig
+	pconf     *lacp.LaAggPortConfig
+	peerpconf *lacp.LaAggPortConfig
+	bridge    lacp.SimulationBridge
+}
+
+// PortalHarnessIpl is the Intra Portal Link between Portal System a and b
+type PortalHarnessIpl struct {
+	a      uint8
+	b      uint8
+	bridge SimulationNeighborBridge
+}
+
+type PortalHarness struct {
+	threeSystem bool
+	asicd       *PortalHarnessAsicdMock
+	systems     []*PortalHarnessSystem
+	ipls        []*PortalHarnessIpl
+	peersys     lacp.LacpSystem
+	peeraconf   *lacp.LaAggConfig
+}
+
+// NewPortalHarness will create a 2P or 3P portal with each portal system
+// aggregator connected to the same partner system
+func NewPortalHarness(numSystems int) *PortalHarness {
+	h := &PortalHarness{
+		threeSystem: numSystems == 3,
+		systems:     make([]*PortalHarnessSystem, 0),
+		ipls:        make([]*PortalHarnessIpl, 0),
+		peersys: lacp.LacpSystem{Actor_System_priority: 128,
+			Actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0xC8}},
+	}
+
+	vlanPortList := make([]int32, 0)
+	for num := uint8(1); num <= uint8(numSystems); num++ {
+		vlanPortList = append(vlanPortList, portalHarnessAggPort(num), portalHarnessPeerPort(num))
+	}
+	h.asicd = NewPortalHarnessAsicdMock(vlanPortList)
+
+	logger, _ := logging.NewLogger("lacpd", "TEST", false)
+	utils.SetLaLogger(logger)
+	utils.DeleteAllAsicDPlugins()
+	utils.SetAsicDPlugin(h.asicd)
+	// fill in conversations
+	GetAllCVIDConversations()
+
+	// a 2P portal has a single ipl, a 3P portal is a ring
+	for a := uint8(1); a <= uint8(numSystems); a++ {
+		b := a%uint8(numSystems) + 1
+		if !h.threeSystem && a == 2 {
+			break
+		}
+		ipl := &PortalHarnessIpl{
+			a: a,
+			b: b,
+			bridge: SimulationNeighborBridge{
+				Port1:      uint32(portalHarnessIppPort(a, b)),
+				Port2:      uint32(portalHarnessIppPort(b, a)),
+				RxIppPort1: make(chan gopacket.Packet, 10),
+				RxIppPort2: make(chan gopacket.Packet, 10),
+			},
+		}
+		for _, ippid := range []int32{portalHarnessIppPort(a, b), portalHarnessIppPort(b, a)} {
+			utils.PortConfigMap[ippid] = utils.PortConfig{Name: fmt.Sprintf("SIMIPPeth%d", ippid),
+				HardwareAddr: net.HardwareAddr{0x00, byte(ippid), 0x11, 0x22, 0x22, 0x33},
+			}
+		}
+		h.registerIplTx(ipl)
+		DrRxMain(uint16(ipl.bridge.Port1), PortalHarnessPortalAddr, ipl.bridge.RxIppPort1)
+		DrRxMain(uint16(ipl.bridge.Port2), PortalHarnessPortalAddr, ipl.bridge.RxIppPort2)
+		h.ipls = append(h.ipls, ipl)
+	}
+
+	for num := uint8(1); num <= uint8(numSystems); num++ {
+		s := &PortalHarnessSystem{
+			num: num,
+			lasys: lacp.LacpSystem{Actor_System_priority: 128,
+				Actor_System: [6]uint8{0x00, 0x00, 0x00, 0x00, num, 0x64}},
+		}
+		aggport := portalHarnessAggPort(num)
+		peerport := portalHarnessPeerPort(num)
+		utils.PortConfigMap[aggport] = utils.PortConfig{Name: fmt.Sprintf("SIMAggeth%d", num),
+			HardwareAddr: net.HardwareAddr{0x00, byte(aggport), 0x11, 0x22, 0x22, 0x33},
+		}
+		utils.PortConfigMap[peerport] = utils.PortConfig{Name: fmt.Sprintf("SIMPeereth%d", num),
+			HardwareAddr: net.HardwareAddr{0x00, byte(peerport), 0x11, 0x22, 0x22, 0x33},
+		}
+
+		s.cfg = DistributedRelayConfig{
+			DrniName:                          fmt.Sprintf("DR-%d", num),
+			DrniPortalAddress:                 PortalHarnessPortalAddr,
+			DrniPortalPriority:                128,
+			DrniThreePortalSystem:             h.threeSystem,
+			DrniPortalSystemNumber:            num,
+			DrniAggregator:                    uint32(portalHarnessAggId(num)),
+			DrniGatewayAlgorithm:              "00:80:C2:01",
+			DrniNeighborAdminGatewayAlgorithm: "00:80:C2:01",
+			DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+			DrniNeighborAdminDRCPState:        "00000000",
+			DrniEncapMethod:                   "00:80:C2:01",
+			DrniPortConversationControl:       false,
+			DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03",
+		}
+		// neighbor portal system number is encoded in the ipp id
+		idx := 0
+		for _, ipl := range h.ipls {
+			if ipl.a == num {
+				s.cfg.DrniIntraPortalLinkList[idx] = uint32(portalHarnessIppPort(num, ipl.b)) | uint32(ipl.b)<<16
+				idx++
+			} else if ipl.b == num {
+				s.cfg.DrniIntraPortalLinkList[idx] = uint32(portalHarnessIppPort(num, ipl.a)) | uint32(ipl.a)<<16
+				idx++
+			}
+		}
+		CreateDistributedRelay(&s.cfg)
+
+		s.bridge = lacp.SimulationBridge{
+			Port1:       uint16(aggport),
+			Port2:       uint16(peerport),
+			RxLacpPort1: make(chan gopacket.Packet, 10),
+			RxLacpPort2: make(chan gopacket.Packet, 10),
+		}
+		h.systems = append(h.systems, s)
+	}
+
+	// must be called to initialize the global
+	PeerSystem := lacp.LacpSysGlobalInfoInit(h.peersys)
+	for _, s := range h.systems {
+		ActorSystem := lacp.LacpSysGlobalInfoInit(s.lasys)
+		ActorSystem.LaSysGlobalRegisterTxCallback(utils.PortConfigMap[int32(s.bridge.Port1)].Name, s.bridge.TxViaGoChannel)
+		PeerSystem.LaSysGlobalRegisterTxCallback(utils.PortConfigMap[int32(s.bridge.Port2)].Name, s.bridge.TxViaGoChannel)
+		lacp.LaRxMain(s.bridge.Port1, s.bridge.RxLacpPort1)
+		lacp.LaRxMain(s.bridge.Port2, s.bridge.RxLacpPort2)
+	}
+
+	h.peeraconf = &lacp.LaAggConfig{
+		Mac:  [6]uint8{0x00, 0x00, 0x02, 0x02, 0x02, 0x02},
+		Id:   PortalHarnessPeerAggId,
+		Name: "aggpeer",
+		Key:  PortalHarnessPeerAggId,
+		Lacp: lacp.LacpConfigInfo{Interval: lacp.LacpSlowPeriodicTime,
+			Mode:           lacp.LacpModeActive,
+			SystemIdMac:    "00:00:00:00:00:C8",
+			SystemPriority: 128},
+	}
+	lacp.CreateLaAgg(h.peeraconf)
+
+	for _, s := range h.systems {
+		s.aconf = &lacp.LaAggConfig{
+			Mac:  [6]uint8{0x00, 0x00, s.num, 0x01, 0x01, 0x01},
+			Id:   portalHarnessAggId(s.num),
+			Name: fmt.Sprintf("agg%d", s.num),
+			Key:  uint16(portalHarnessAggId(s.num)),
+			Lacp: lacp.LacpConfigInfo{Interval: lacp.LacpSlowPeriodicTime,
+				Mode:           lacp.LacpModeActive,
+				SystemIdMac:    fmt.Sprintf("00:00:00:00:%02x:64", s.num),
+				SystemPriority: 128},
+		}
+		lacp.CreateLaAgg(s.aconf)
+
+		s.pconf = &lacp.LaAggPortConfig{
+			Id:     s.bridge.Port1,
+			Prio:   0x80,
+			Key:    s.aconf.Key,
+			AggId:  s.aconf.Id,
+			Enable: true,
+			Mode:   lacp.LacpModeActive,
+			Properties: lacp.PortProperties{
+				Mac:    net.HardwareAddr{0x00, byte(s.bridge.Port1), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: lacp.LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   utils.PortConfigMap[int32(s.bridge.Port1)].Name,
+			TraceEna: true,
+		}
+		s.peerpconf = &lacp.LaAggPortConfig{
+			Id:     s.bridge.Port2,
+			Prio:   0x80,
+			Key:    PortalHarnessPeerAggId,
+			AggId:  PortalHarnessPeerAggId,
+			Enable: true,
+			Mode:   lacp.LacpModeActive,
+			Properties: lacp.PortProperties{
+				Mac:    net.HardwareAddr{0x00, byte(s.bridge.Port2), 0xDE, 0xAD, 0xBE, 0xEF},
+				Speed:  1000000000,
+				Duplex: lacp.LacpPortDuplexFull,
+				Mtu:    1500,
+			},
+			IntfId:   utils.PortConfigMap[int32(s.bridge.Port2)].Name,
+			TraceEna: true,
+		}
+		// actor / neighbor
+		lacp.CreateLaAggPort(s.pconf)
+		// peer
+		lacp.CreateLaAggPort(s.peerpconf)
+	}
+
+	return h
+}
+
+func (h *PortalHarness) Teardown(t *testing.T) {
+	for _, s := range h.systems {
+		close(s.bridge.RxLacpPort1)
+		close(s.bridge.RxLacpPort2)
+		s.bridge.RxLacpPort1 = nil
+		s.bridge.RxLacpPort2 = nil
+		lacp.DeleteLaAgg(s.aconf.Id)
+	}
+	lacp.DeleteLaAgg(h.peeraconf.Id)
+	for _, sgi := range lacp.LacpSysGlobalInfoGet() {
+		if len(sgi.AggList) > 0 || len(sgi.AggMap) > 0 {
+			t.Error("System Agg List or Map is not empty", sgi.AggList, sgi.AggMap)
+		}
+		if len(sgi.PortList) > 0 || len(sgi.PortMap) > 0 {
+			t.Error("System Port List or Map is not empty", sgi.PortList, sgi.PortMap)
+		}
+	}
+
+	for _, s := range h.systems {
+		DeleteDistributedRelay(s.cfg.DrniName)
+		lacp.LacpSysGlobalInfoDestroy(s.lasys)
+	}
+	lacp.LacpSysGlobalInfoDestroy(h.peersys)
+
+	for _, ipl := range h.ipls {
+		h.deregisterIplTx(ipl)
+		close(ipl.bridge.RxIppPort1)
+		close(ipl.bridge.RxIppPort2)
+		ipl.bridge.RxIppPort1 = nil
+		ipl.bridge.RxIppPort2 = nil
+	}
+
+	OnlyForTestTeardown(t)
+
+	for _, s := range h.systems {
+		delete(utils.PortConfigMap, int32(s.bridge.Port1))
+		delete(utils.PortConfigMap, int32(s.bridge.Port2))
+	}
+	for _, ipl := range h.ipls {
+		delete(utils.PortConfigMap, int32(ipl.bridge.Port1))
+		delete(utils.PortConfigMap, int32(ipl.bridge.Port2))
+	}
+}
+
+func (h *PortalHarness) iplKeys(ipl *PortalHarnessIpl) []IppDbKey {
+	return []IppDbKey{
+		{
+			Name:   utils.PortConfigMap[int32(ipl.bridge.Port1)].Name,
+			DrName: fmt.Sprintf("DR-%d", ipl.a),
+		},
+		{
+			Name:   utils.PortConfigMap[int32(ipl.bridge.Port2)].Name,
+			DrName: fmt.Sprintf("DR-%d", ipl.b),
+		},
+	}
+}
+
+func (h *PortalHarness) registerIplTx(ipl *PortalHarnessIpl) {
+	for _, key := range h.iplKeys(ipl) {
+		DRGlobalSystem.DRSystemGlobalRegisterTxCallback(key, ipl.bridge.TxViaGoChannel)
+	}
+}
+
+func (h *PortalHarness) deregisterIplTx(ipl *PortalHarnessIpl) {
+	for _, key := range h.iplKeys(ipl) {
+		DRGlobalSystem.DRSystemGlobalDeRegisterTxCallback(key)
+	}
+}
+
+func (h *PortalHarness) findIpl(a, b uint8) *PortalHarnessIpl {
+	for _, ipl := range h.ipls {
+		if (ipl.a == a && ipl.b == b) ||
+			(ipl.a == b && ipl.b == a) {
+			return ipl
+		}
+	}
+	return nil
+}
+
+// DR returns the Distributed Relay of the Portal System
+func (h *PortalHarness) DR(num uint8) *DistributedRelay {
+	var dr *DistributedRelay
+	if DrFindByName(fmt.Sprintf("DR-%d", num), &dr) {
+		return dr
+	}
+	return nil
+}
+
+// Ipp returns the IPP on the home Portal System connected to the neighbor
+func (h *PortalHarness) Ipp(home, neighbor uint8) *DRCPIpp {
+	var p *DRCPIpp
+	key := IppDbKey{
+		Name:   utils.PortConfigMap[portalHarnessIppPort(home, neighbor)].Name,
+		DrName: fmt.Sprintf("DR-%d", home),
+	}
+	if DRFindPortByKey(key, &p) {
+		return p
+	}
+	return nil
+}
+
+// BreakIpl will take down both ends of the Intra Portal Link between
+// Portal System a and b
+func (h *PortalHarness) BreakIpl(a, b uint8) {
+	if ipl := h.findIpl(a, b); ipl != nil {
+		// link down will de-register the tx callback
+		h.Ipp(a, b).DrIppLinkDown()
+		h.Ipp(b, a).DrIppLinkDown()
+	}
+}
+
+// RestoreIpl will bring the Intra Portal Link between Portal System a and b
+// back up
+func (h *PortalHarness) RestoreIpl(a, b uint8) {
+	if ipl := h.findIpl(a, b); ipl != nil {
+		h.registerIplTx(ipl)
+		h.Ipp(a, b).DrIppLinkUp()
+		h.Ipp(b, a).DrIppLinkUp()
+	}
+}
+
+// DisableAggPort will disable the aggregator port of the Portal System
+func (h *PortalHarness) DisableAggPort(num uint8) {
+	lacp.DisableLaAggPort(uint16(portalHarnessAggPort(num)))
+}
+
+// EnableAggPort will enable the aggregator port of the Portal System
+func (h *PortalHarness) EnableAggPort(num uint8) {
+	lacp.EnableLaAggPort(uint16(portalHarnessAggPort(num)))
+}
+
+// portalHarnessWaitFor will poll the condition until it is met or the
+// timeout expires
+func portalHarnessWaitFor(timeout time.Duration, cond func() bool) bool {
+	for start := time.Now(); time.Since(start) < timeout; time.Sleep(time.Millisecond * 250) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func portalHarnessLaPortDistributing(pId uint16) bool {
+	var p *lacp.LaAggPort
+	return lacp.LaFindPortById(pId, &p) &&
+		p.MuxMachineFsm.Machine.Curr.CurrentState() == lacp.LacpMuxmStateDistributing
+}
+
+func portalHarnessIppSynced(ipp *DRCPIpp) bool {
+	return ipp != nil &&
+		ipp.DRFNeighborOperDRCPState.GetState(layers.DRCPStateIPPActivity) &&
+		ipp.DRFNeighborOperDRCPState.GetState(layers.DRCPStateGatewaySync) &&
+		ipp.DRFNeighborOperDRCPState.GetState(layers.DRCPStatePortSync)
+}
+
+func portalHarnessPortalSystemUp(dr *DistributedRelay, num uint8) bool {
+	dr.DrniPortalSystemState[num].mutex.Lock()
+	defer dr.DrniPortalSystemState[num].mutex.Unlock()
+	return dr.DrniPortalSystemState[num].OpState
+}
+
+func portalHarnessPortalSystemPortCnt(dr *DistributedRelay, num uint8) int {
+	dr.DrniPortalSystemState[num].mutex.Lock()
+	defer dr.DrniPortalSystemState[num].mutex.Unlock()
+	return len(dr.DrniPortalSystemState[num].PortIdList)
+}
+
+// VerifyPortalFormed will check that every aggregator port is distributing
+// with the partner and that every portal system has synced with every
+// other portal system
+func (h *PortalHarness) VerifyPortalFormed(step string, t *testing.T) {
+	for _, s := range h.systems {
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return portalHarnessLaPortDistributing(s.pconf.Id) &&
+				portalHarnessLaPortDistributing(s.peerpconf.Id)
+		}) {
+			t.Error(fmt.Sprintf("step: %s Portal System %d Port State %s did not come up properly with partner %s", step, s.num,
+				lacp.LacpStateToStr(lacp.GetLaAggPortActorOperState(s.pconf.Id)),
+				lacp.LacpStateToStr(lacp.GetLaAggPortActorOperState(s.peerpconf.Id))))
+		}
+	}
+
+	for _, s := range h.systems {
+		dr := h.DR(s.num)
+		if dr == nil {
+			t.Error(fmt.Sprintf("step: %s Unable to find DR for Portal System %d", step, s.num))
+			continue
+		}
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			for _, ipp := range dr.Ipplinks {
+				if !portalHarnessIppSynced(ipp) {
+					return false
+				}
+			}
+			for _, other := range h.systems {
+				if !portalHarnessPortalSystemUp(dr, other.num) ||
+					portalHarnessPortalSystemPortCnt(dr, other.num) != 1 {
+					return false
+				}
+			}
+			return dr.DRFHomeOperDRCPState.GetState(layers.DRCPStateIPPActivity) &&
+				dr.DRFHomeOperDRCPState.GetState(layers.DRCPStateGatewaySync) &&
+				dr.DRFHomeOperDRCPState.GetState(layers.DRCPStatePortSync)
+		}) {
+			t.Error(fmt.Sprintf("step: %s Portal System %d did not sync up with its neighbors current state %s", step, s.num, dr.DRFHomeOperDRCPState.String()))
+		}
+		for _, ipp := range dr.Ipplinks {
+			if ipp.ONN {
+				t.Error(fmt.Sprintf("step: %s Portal System %d IPP %d unexpectedly set ONN", step, s.num, ipp.Id))
+			}
+		}
+	}
+}
+
+// VerifyGateway will check that every portal system agrees on the gateway
+// of the conversation and that only the gateway system considers itself
+// the gateway
+func (h *PortalHarness) VerifyGateway(step string, cid uint16, gateway uint8, t *testing.T) {
+	for _, s := range h.systems {
+		dr := h.DR(s.num)
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return dr.DrniGatewayConversation[cid] != nil &&
+				dr.DrniGatewayConversation[cid][0] == gateway &&
+				dr.DrniPortalSystemGatewayConversation[cid] == (s.num == gateway)
+		}) {
+			t.Error(fmt.Sprintf("step: %s Portal System %d Gateway Conversation %d expected gateway system %d gateway list %v home gateway %t",
+				step, s.num, cid, gateway, dr.DrniGatewayConversation[cid], dr.DrniPortalSystemGatewayConversation[cid]))
+		}
+	}
+}
+
+// 2P portal, both systems sync and the ipl is programmed for the conversation
+func TestPortalHarnessTwoSystemPortalFormation(t *testing.T) {
+
+	h := NewPortalHarness(2)
+
+	h.VerifyPortalFormed("formation", t)
+
+	// even vlan gateway is system 2
+	for _, num := range []uint8{1, 2} {
+		dr := h.DR(num)
+		if dr.DrniGatewayConversation[PortalHarnessVlan] == nil ||
+			dr.DrniGatewayConversation[PortalHarnessVlan][0] != 2 {
+			t.Error("Error Portal System", num, "Gateway Conversation not set to system 2", dr.DrniGatewayConversation[PortalHarnessVlan])
+		}
+	}
+
+	for _, ippid := range []int32{portalHarnessIppPort(1, 2), portalHarnessIppPort(2, 1)} {
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return h.asicd.IsIppVlanConversationSet(ippid, PortalHarnessVlan)
+		}) {
+			t.Error("Error IPP", ippid, "vlan membership not programmed for conversation", PortalHarnessVlan)
+		}
+	}
+
+	h.Teardown(t)
+}
+
+// 2P portal, ipl goes down each system should no longer consider the
+// neighbor as part of the portal, once restored the portal should reform
+func TestPortalHarnessTwoSystemIplLoss(t *testing.T) {
+
+	h := NewPortalHarness(2)
+
+	h.VerifyPortalFormed("formation", t)
+
+	h.BreakIpl(1, 2)
+
+	for _, num := range []uint8{1, 2} {
+		dr := h.DR(num)
+		ipp := h.Ipp(num, 3-num)
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return !portalHarnessPortalSystemUp(dr, 3-num) &&
+				!ipp.DRFNeighborOperDRCPState.GetState(layers.DRCPStateIPPActivity)
+		}) {
+			t.Error("Error Portal System", num, "still considers neighbor up after ipl loss", ipp.DRFNeighborOperDRCPState.String())
+		}
+		if !portalHarnessPortalSystemUp(dr, num) {
+			t.Error("Error Portal System", num, "home state went down after ipl loss")
+		}
+	}
+
+	h.RestoreIpl(1, 2)
+
+	h.VerifyPortalFormed("ipl restored", t)
+
+	h.Teardown(t)
+}
+
+// 2P portal, system 2 is the gateway for the even conversation, when the
+// ipl is lost system 1 must take over as gateway and give it back once the
+// ipl is restored
+func TestPortalHarnessTwoSystemGatewayHandover(t *testing.T) {
+
+	h := NewPortalHarness(2)
+
+	h.VerifyPortalFormed("formation", t)
+
+	dr1 := h.DR(1)
+	dr2 := h.DR(2)
+
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return dr2.DrniPortalSystemGatewayConversation[PortalHarnessVlan] &&
+			!dr1.DrniPortalSystemGatewayConversation[PortalHarnessVlan]
+	}) {
+		t.Error("Error expected Portal System 2 to be gateway for conversation", PortalHarnessVlan)
+	}
+
+	h.BreakIpl(1, 2)
+
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return dr1.DrniPortalSystemGatewayConversation[PortalHarnessVlan] &&
+			dr1.DrniGatewayConversation[PortalHarnessVlan] != nil &&
+			dr1.DrniGatewayConversation[PortalHarnessVlan][0] == 1
+	}) {
+		t.Error("Error Portal System 1 did not take over as gateway for conversation", PortalHarnessVlan, dr1.DrniGatewayConversation[PortalHarnessVlan])
+	}
+	if !dr2.DrniPortalSystemGatewayConversation[PortalHarnessVlan] {
+		t.Error("Error Portal System 2 gave up gateway for conversation", PortalHarnessVlan)
+	}
+
+	h.RestoreIpl(1, 2)
+
+	h.VerifyPortalFormed("ipl restored", t)
+
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return dr2.DrniPortalSystemGatewayConversation[PortalHarnessVlan] &&
+			!dr1.DrniPortalSystemGatewayConversation[PortalHarnessVlan]
+	}) {
+		t.Error("Error Portal System 1 did not hand gateway back to system 2 for conversation", PortalHarnessVlan)
+	}
+	for _, ippid := range []int32{portalHarnessIppPort(1, 2), portalHarnessIppPort(2, 1)} {
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return h.asicd.IsIppVlanConversationSet(ippid, PortalHarnessVlan)
+		}) {
+			t.Error("Error IPP", ippid, "vlan membership not programmed for conversation", PortalHarnessVlan)
+		}
+	}
+
+	h.Teardown(t)
+}
+
+// 2P portal, aggregator port on system 1 goes down, the partner should
+// continue distributing to system 2 and system 2 should unblock the ipl
+func TestPortalHarnessTwoSystemAggregatorFailover(t *testing.T) {
+
+	h := NewPortalHarness(2)
+
+	h.VerifyPortalFormed("formation", t)
+
+	dr2 := h.DR(2)
+	passcnt := h.asicd.IngressEgressPassCnt()
+	flushcnt := h.asicd.FdbFlushCnt()
+
+	h.DisableAggPort(1)
+
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return portalHarnessPortalSystemPortCnt(dr2, 1) == 0
+	}) {
+		t.Error("Error Portal System 2 still sees active ports on system 1 after aggregator failure")
+	}
+	if portalHarnessPortalSystemPortCnt(dr2, 2) != 1 {
+		t.Error("Error Portal System 2 lost its own active port after system 1 aggregator failure")
+	}
+	if !portalHarnessLaPortDistributing(h.systems[1].pconf.Id) ||
+		!portalHarnessLaPortDistributing(h.systems[1].peerpconf.Id) {
+		t.Error("Error Partner stopped distributing to Portal System 2 after system 1 aggregator failure")
+	}
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return h.asicd.IngressEgressPassCnt() > passcnt
+	}) {
+		t.Error("Error Portal System 2 did not unblock ipl to aggregator after system 1 aggregator failure")
+	}
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return h.asicd.FdbFlushCnt() > flushcnt
+	}) {
+		t.Error("Error FDB was not flushed after system 1 aggregator failure")
+	}
+
+	dropcnt := h.asicd.IngressEgressDropCnt()
+
+	h.EnableAggPort(1)
+
+	h.VerifyPortalFormed("aggregator restored", t)
+
+	if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+		return h.asicd.IngressEgressDropCnt() > dropcnt
+	}) {
+		t.Error("Error Portal System 2 did not block ipl to aggregator after system 1 aggregator restored")
+	}
+
+	h.Teardown(t)
+}
+
+// 3P portal, every system should learn of every other system
+func TestPortalHarnessThreeSystemPortalFormation(t *testing.T) {
+
+	h := NewPortalHarness(3)
+
+	h.VerifyPortalFormed("formation", t)
+
+	for _, s := range h.systems {
+		for _, ipp := range h.DR(s.num).Ipplinks {
+			if ipp.DRFNeighborPortalSystemNumber != uint8(ipp.Id%10) {
+				t.Error("Error Portal System", s.num, "IPP", ipp.Id, "neighbor portal system number incorrect", ipp.DRFNeighborPortalSystemNumber)
+			}
+		}
+	}
+
+	// conversations are spread across all three systems
+	h.VerifyGateway("formation", PortalHarnessVlan, 3, t)
+	h.VerifyGateway("formation", PortalHarnessVlan+1, 1, t)
+	h.VerifyGateway("formation", PortalHarnessVlan+2, 2, t)
+
+	h.Teardown(t)
+}
+
+// 3P portal, losing a single ipl should not split the portal as the
+// systems remain connected via the other neighbor
+func TestPortalHarnessThreeSystemIplLoss(t *testing.T) {
+
+	h := NewPortalHarness(3)
+
+	h.VerifyPortalFormed("formation", t)
+
+	h.BreakIpl(1, 2)
+
+	// system 1 and 2 now learn about each other through system 3
+	for _, num := range []uint8{1, 2} {
+		dr := h.DR(num)
+		ipp := h.Ipp(num, 3)
+		if !portalHarnessWaitFor(PortalHarnessWaitTime, func() bool {
+			return ipp.ONN
+		}) {
+			t.Error("Error Portal System", num, "IPP to system 3 did not set ONN after ipl loss")
+		}
+		for _, other := range h.systems {
+			if !portalHarnessPortalSystemUp(dr, other.num) {
+				t.Error("Error Portal System", num, "lost portal system", other.num, "after single ipl loss")
+			}
+		}
+	}
+	// every system is still part of the portal so the gateway remains
+	h.VerifyGateway("ipl loss", PortalHarnessVlan, 3, t)
+
+	h.RestoreIpl(1, 2)
+
+	h.VerifyPortalFormed("ipl restored", t)
+	h.VerifyGateway("ipl restored", PortalHarnessVlan, 3, t)
+
+	h.Teardown(t)
+}
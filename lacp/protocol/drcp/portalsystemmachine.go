@@ -58,6 +58,7 @@ const (
 	PsmEventBegin = iota + 1
 	PsmEventChangePortal
 	PsmEventChangeDRFPorts
+	PsmEventSplitBrainChange
 )
 
 // PsMachine holds FSM and current State
@@ -132,6 +133,7 @@ func (psm *PsMachine) DrcpPsMachinePortalSystemUpdate(m fsm.Machine, data interf
 
 	psm.updateDRFHomeState(dr.ChangePortal, dr.ChangeDRFPorts)
 	psm.updateKey()
+	psm.updateSplitBrainState()
 	dr.ChangePortal = false
 	dr.ChangeDRFPorts = false
 
@@ -163,6 +165,11 @@ func DrcpPsMachineFSMBuild(dr *DistributedRelay) *PsMachine {
 	rules.AddRule(PsmStatePortalSystemInitialize, PsmEventChangeDRFPorts, psm.DrcpPsMachinePortalSystemUpdate)
 	rules.AddRule(PsmStatePortalSystemUpdate, PsmEventChangeDRFPorts, psm.DrcpPsMachinePortalSystemUpdate)
 
+	// SPLIT BRAIN CHANGE > PORTAL SYSTEM UPDATE
+	// not part of the standard, peer keepalive state change
+	rules.AddRule(PsmStatePortalSystemInitialize, PsmEventSplitBrainChange, psm.DrcpPsMachinePortalSystemUpdate)
+	rules.AddRule(PsmStatePortalSystemUpdate, PsmEventSplitBrainChange, psm.DrcpPsMachinePortalSystemUpdate)
+
 	// Create a new FSM and apply the rules
 	psm.Apply(&rules)
 
@@ -318,34 +325,7 @@ func (psm *PsMachine) updateKey() {
 				a.ActorOperKey = operKey
 
 				for _, aggport := range a.PortNumList {
-					if dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-						for _, client := range utils.GetAsicDPluginList() {
-							for _, ippid := range dr.DrniIntraPortalLinkList {
-								inport := ippid & 0xffff
-								if inport > 0 {
-									dr.LaDrLog(fmt.Sprintf("updateKey: Blocking IPP %d to AggPort %d", inport, aggport))
-									/* TEMP - add actual port names */
-									err := client.IppIngressEgressDrop("fpPort1", "fpPort2")
-									if err != nil {
-										dr.LaDrLog(fmt.Sprintf("ERROR (updateKey) setting Block from %s tolag port %s", utils.GetNameFromIfIndex(int32(inport)), int32(aggport)))
-									}
-								}
-							}
-						}
-					}
-					lacp.SetLaAggPortSystemInfoFromDistributedRelay(
-						uint16(aggport),
-						fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x",
-							dr.DrniPortalAddr[0],
-							dr.DrniPortalAddr[1],
-							dr.DrniPortalAddr[2],
-							dr.DrniPortalAddr[3],
-							dr.DrniPortalAddr[4],
-							dr.DrniPortalAddr[5]),
-						dr.DrniPortalPriority,
-						dr.DRFHomeOperAggregatorKey,
-						dr.DrniName,
-						false)
+					dr.setAggPortSystemInfo(PsMachineModuleStr, aggport)
 				}
 			}
 		}
@@ -458,6 +438,57 @@ func (psm *PsMachine) updateDRFHomeState(changePortal, changeDRFPorts bool) {
 	}
 }
 
+// updateSplitBrainState will take the aggregator ports out of service when the
+// IPLs are down and this portal system should not forward as the portal, and
+// bring them back into service once the condition clears.  Updating the key
+// may re-sync the ports thus while out of service the ports are always forced
+// back to unselected.  Ports remain out of service until the isolated portal
+// system rule of updateKey no longer applies or, when the IPL has recovered,
+// until the neighbor has synced
+func (psm *PsMachine) updateSplitBrainState() {
+	dr := psm.dr
+	a := dr.a
+	outOfService := dr.isSplitBrainOutOfService()
+	// keepalive tx runs on its own timer, hand it the state to advertise
+	if dr.keepalive != nil {
+		dr.keepalive.setLocalState(dr.DrniPSI, outOfService)
+	}
+	if outOfService == dr.SplitBrainOutOfService &&
+		!outOfService {
+		return
+	}
+	if !outOfService &&
+		!psm.isAggPortSyncAllowed() {
+		psm.DrcpPsmLog("Split Brain cleared, aggregator ports remain out of service until synced")
+		return
+	}
+	if outOfService != dr.SplitBrainOutOfService {
+		psm.DrcpPsmLog(fmt.Sprintf("Split Brain aggregator ports out of service changed from %t to %t", dr.SplitBrainOutOfService, outOfService))
+	}
+	dr.SplitBrainOutOfService = outOfService
+	if a != nil {
+		for _, intfref := range a.PortNumList {
+			lacp.SetLaAggPortCheckSelectionDistributedRelayIsSynced(intfref, !outOfService)
+		}
+	}
+}
+
+// isAggPortSyncAllowed returns true when the aggregator ports may be synced,
+// an isolated portal system without a home gateway keeps the ports unselected
+// and once the IPL has recovered the neighbor must have synced via DRCP
+func (psm *PsMachine) isAggPortSyncAllowed() bool {
+	dr := psm.dr
+	if dr.DrniPSI {
+		return dr.DRFHomeOperDRCPState.GetState(layers.DRCPStateHomeGatewayBit)
+	}
+	for _, ipp := range dr.Ipplinks {
+		if ipp.DRFNeighborOperDRCPState.GetState(layers.DRCPStatePortSync) {
+			return true
+		}
+	}
+	return false
+}
+
 // setDRFHomeState TRUE indicates operable (i.e., the local DR
 // Function is able to relay traffic through its Gateway Port and at least one of its other Ports—
 // IPP(s) or Aggregator) and that connectivity through the local Gateway is enabled by the
@@ -490,9 +521,10 @@ func (psm *PsMachine) setDRFHomeState(changeDRFPorts bool) (gatewayChanged bool)
 	if !distributedPortsValid &&
 		changeDRFPorts &&
 		dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
-		// TODO flush the mac table so no mac is forwarded
+		// flush the mac table so no mac is forwarded
 		// to neighbor card and not this local lag
 		psm.DrcpPsmLog(fmt.Sprintln("Flush DB based on AGG", dr.DrniAggregator))
+		dr.flushAggregatorFdb(PsMachineModuleStr)
 	}
 
 	return gatewayChanged
@@ -72,6 +72,7 @@ func DrRxMain(pId uint16, portaladdr string, rxPktChan chan gopacket.Packet) {
 						}
 					} else {
 						fmt.Println("Non-DRCP frame received")
+						drcpIllegalRx(rxMainPort)
 					}
 				} else {
 					return
@@ -118,6 +119,16 @@ func IsControlFrame(pId uint16, packet gopacket.Packet) bool {
 	return isdrcp
 }
 
+// drcpIllegalRx will count a frame received on an IPP which was not
+// a valid DRCPDU
+func drcpIllegalRx(pId uint16) {
+	for _, ipp := range DRCPIppDBList {
+		if ipp.Id == uint32(pId) {
+			ipp.IllegalRX++
+		}
+	}
+}
+
 // ProcessDrcpFrame will lookup the cooresponding port from which the
 // packet arrived and forward the packet to the Rx Machine for processing
 func ProcessDrcpFrame(pId uint16, pa string, drcp *layers.DRCP) {
@@ -401,11 +401,14 @@ func (p *DRCPIpp) DrcpRxMachineMain() {
 				}
 			case rx, ok := <-m.RxmPktRxEvent:
 				if ok {
+					m.p.DRCPDUsRX++
+					m.p.LastRXTime = time.Now()
 					rv := m.Machine.ProcessEvent(RxMachineModuleStr, RxmEventDRCPDURx, rx.pdu)
 					if rv == nil {
 						/* continue State transition */
 						m.processPostStates(rx.pdu)
 					}
+					m.p.DRCPRXState = RxmStateStrMap[m.Machine.Curr.CurrentState()]
 
 					// respond to caller if necessary so that we don't have a deadlock
 					if rx.responseChan != nil {
@@ -653,7 +656,11 @@ func (rxm *RxMachine) recordDefaultDRCPDU() {
 	p.DRFNeighborOperPartnerAggregatorKey = 0
 	p.DRFOtherNeighborOperPartnerAggregatorKey = 0
 	if dr.DrniThreeSystemPortal {
-		// TODO may need to chage this logic when 3P system supported
+		// every conversation is associated with the configured neighbor
+		threePortalConversationVectorFill(p.DRFHomeConfNeighborPortalSystemNumber, &p.DrniNeighborGatewayConversation)
+		if dr.ChangePortal {
+			threePortalConversationVectorFill(p.DRFHomeConfNeighborPortalSystemNumber, &p.DrniNeighborPortConversation)
+		}
 	} else {
 
 		for i := 0; i < 1024; i++ {
@@ -664,6 +671,8 @@ func (rxm *RxMachine) recordDefaultDRCPDU() {
 			}
 		}
 	}
+	defer p.NotifyCCTimeSharedChange(RxMachineModuleStr, p.CCTimeShared, false)
+	defer p.NotifyCCEncTagSharedChange(RxMachineModuleStr, p.CCEncTagShared, false)
 	p.CCTimeShared = false
 	p.CCEncTagShared = false
 
@@ -775,6 +784,8 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 	portAlgorithmEqual := p.DRFNeighborPortAlgorithm == dr.DRFHomePortAlgorithm
 	conversationPortListDigestEqual := p.DRFNeighborConversationPortListDigest == dr.DRFHomeConversationPortListDigest
 	gatewayAlgorithmEqual := p.DRFNeighborGatewayAlgorithm == dr.DRFHomeGatewayAlgorithm
+	// neighbor is expected to run the administratively configured neighbor gateway algorithm
+	neighborAdminGatewayAlgorithmEqual := p.DRFNeighborGatewayAlgorithm == dr.DrniNeighborAdminGatewayAlgorithm
 	//fmt.Println("RX: GatewayListDigest:", p.DRFNeighborConversationGatewayListDigest, dr.DRFHomeConversationGatewayListDigest)
 	conversationGatewayListDigestEqual := p.DRFNeighborConversationGatewayListDigest == dr.DRFHomeConversationGatewayListDigest
 
@@ -815,16 +826,25 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 		p.DifferPortalReason += "Three System Portal, "
 	}
 
+	p.DifferGatewayAlgorithm = !gatewayAlgorithmEqual || !neighborAdminGatewayAlgorithmEqual
 	if !gatewayAlgorithmEqual {
 		rxm.DrcpRxmLog(fmt.Sprintf("Gateway Algorithm Diff Local %+v Neighbor %+v", p.DRFNeighborGatewayAlgorithm, dr.DRFHomeGatewayAlgorithm))
 		p.DifferPortalReason += "Gateway Algorithm, "
 	}
+	if !neighborAdminGatewayAlgorithmEqual {
+		rxm.DrcpRxmLog(fmt.Sprintf("Neighbor Gateway Algorithm Diff Admin %+v Neighbor %+v", dr.DrniNeighborAdminGatewayAlgorithm, p.DRFNeighborGatewayAlgorithm))
+		p.DifferPortalReason += "Neighbor Admin Gateway Algorithm, "
+	}
 
 	if !p.DifferConfPortal &&
 		(!threeSystemPortalEqual || !gatewayAlgorithmEqual) {
-		for i := 0; i < 1024; i++ {
-			// boolean vector
-			p.DrniNeighborGatewayConversation[i] = 0xff
+		if p.dr.DrniThreeSystemPortal {
+			threePortalConversationVectorFill(p.DRFHomeConfNeighborPortalSystemNumber, &p.DrniNeighborGatewayConversation)
+		} else {
+			for i := 0; i < 1024; i++ {
+				// boolean vector
+				p.DrniNeighborGatewayConversation[i] = 0xff
+			}
 		}
 		p.DifferGatewayDigest = true
 
@@ -849,7 +869,10 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 				p.MissingRcvGatewayConVector = false
 			} else if ThreePGatewayConversationVectorPresent &&
 				p.dr.DrniThreeSystemPortal {
-				// TODO need to change the logic to concatinate the two 3P vector
+				threePortalConversationVectorSave(drcpPduInfo.ThreePortalGatewayConversationVector1.Vector,
+					drcpPduInfo.ThreePortalGatewayConversationVector2.Vector,
+					&p.DrniNeighborGatewayConversation)
+				p.MissingRcvGatewayConVector = false
 			} else if !TwoPGatewayConverationVectorPresent &&
 				!ThreePGatewayConversationVectorPresent &&
 				commonMethodsEqual &&
@@ -864,7 +887,10 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 					p.MissingRcvGatewayConVector = false
 				} else if ThreePPortConversationVectorPresent &&
 					p.dr.DrniThreeSystemPortal {
-					// TODO need to change logic to concatinate the two 3P vector
+					threePortalConversationVectorSave(drcpPduInfo.ThreePortalPortConversationVector1.Vector,
+						drcpPduInfo.ThreePortalPortConversationVector2.Vector,
+						&p.DrniNeighborGatewayConversation)
+					p.MissingRcvGatewayConVector = false
 				}
 			} else {
 				p.MissingRcvGatewayConVector = true
@@ -875,7 +901,7 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 	if !p.DifferConfPortal &&
 		(!threeSystemPortalEqual || !portAlgorithmEqual) {
 		if p.dr.DrniThreeSystemPortal {
-			// TODO when 3P system supported
+			threePortalConversationVectorFill(p.DRFHomeConfNeighborPortalSystemNumber, &p.DrniNeighborPortConversation)
 		} else {
 			for i := 0; i < 1024; i++ {
 				// boolean vector
@@ -900,7 +926,10 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 				p.MissingRcvPortConVector = false
 			} else if ThreePPortConversationVectorPresent &&
 				p.dr.DrniThreeSystemPortal {
-				// TODO when support 3P system
+				threePortalConversationVectorSave(drcpPduInfo.ThreePortalPortConversationVector1.Vector,
+					drcpPduInfo.ThreePortalPortConversationVector2.Vector,
+					&p.DrniNeighborPortConversation)
+				p.MissingRcvPortConVector = false
 			} else if !TwoPPortConversationVectorPresent &&
 				!ThreePPortConversationVectorPresent &&
 				p.DrniNeighborCommonMethods == p.dr.DrniCommonMethods &&
@@ -913,7 +942,9 @@ func (rxm *RxMachine) recordPortalConfValues(drcpPduInfo *layers.DRCP) {
 						p.DrniNeighborPortConversation[i] = drcpPduInfo.TwoPortalGatewayConversationVector.Vector[i]
 					}
 				} else {
-					// TODO when 3P system supported
+					threePortalConversationVectorSave(drcpPduInfo.ThreePortalGatewayConversationVector1.Vector,
+						drcpPduInfo.ThreePortalGatewayConversationVector2.Vector,
+						&p.DrniNeighborPortConversation)
 				}
 				p.MissingRcvPortConVector = false
 			}
@@ -1045,6 +1076,13 @@ func (rxm *RxMachine) recordNeighborState(drcpPduInfo *layers.DRCP) {
 	rxm.saveRcvOtherGatewayVector(drcpPduInfo)
 
 	if drcpPduInfo.State.State.GetState(layers.DRCPStatePortSync) {
+		// aggregator ports held out of service by split brain are waiting
+		// for the neighbor to sync before they are brought back into service
+		if !p.DRFNeighborOperDRCPState.GetState(layers.DRCPStatePortSync) &&
+			dr.SplitBrainOutOfService {
+			defer rxm.NotifyChangePortalChanged(dr.ChangePortal, true)
+			dr.ChangePortal = true
+		}
 		p.DRFNeighborOperDRCPState.SetState(layers.DRCPStatePortSync)
 	} else {
 		p.DRFNeighborOperDRCPState.ClearState(layers.DRCPStatePortSync)
@@ -1059,19 +1097,24 @@ func (rxm *RxMachine) recordNeighborState(drcpPduInfo *layers.DRCP) {
 		p.DrniNeighborState[p.DRFNeighborPortalSystemNumber].mutex.Unlock()
 
 		p.DRFNeighborState.mutex.Lock()
-		// TODO
-		// Is this the correct place to unlock the block?
-		// This would be the fastest point of entry
-		if len(p.DRFNeighborState.PortIdList) > 0 &&
-			len(drcpPduInfo.HomePortsInfo.ActiveHomePorts) == 0 &&
-			a != nil {
-			for _, client := range utils.GetAsicDPluginList() {
+		// Neighbor aggregator has lost all its ports, frames received over the
+		// IPL must now be forwarded over the local aggregator.  Once the neighbor
+		// aggregator ports return the discard is restored.  This is the fastest
+		// point of entry
+		if a != nil &&
+			dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TIME {
+			if len(p.DRFNeighborState.PortIdList) > 0 &&
+				len(drcpPduInfo.HomePortsInfo.ActiveHomePorts) == 0 {
 				for _, aggport := range a.PortNumList {
-					/* TEMP - add actual port names */
-					err := client.IppIngressEgressPass("fpPort1", "fpPort2")
-					if err != nil {
-						dr.LaDrLog(fmt.Sprintf("ERROR (AttachAgg) setting Block from %s tolag port %s", utils.GetNameFromIfIndex(int32(p.Id)), int32(aggport)))
-					}
+					dr.setIppAggPortDiscard(RxMachineModuleStr, aggport, false)
+				}
+				// macs learned from the neighbor aggregator are now
+				// reachable through the local aggregator
+				dr.flushConversationFdb(RxMachineModuleStr, p.passingConversations(), []int32{int32(p.Id)})
+			} else if len(p.DRFNeighborState.PortIdList) == 0 &&
+				len(drcpPduInfo.HomePortsInfo.ActiveHomePorts) > 0 {
+				for _, aggport := range a.PortNumList {
+					dr.setIppAggPortDiscard(RxMachineModuleStr, aggport, true)
 				}
 			}
 		}
@@ -1091,7 +1134,7 @@ func (rxm *RxMachine) recordNeighborState(drcpPduInfo *layers.DRCP) {
 
 	rxm.compareOtherPortsInfo(drcpPduInfo)
 
-	// Network / IPL sharing by time (9.3.2.1) is supported
+	// Network / IPL sharing by time (9.3.2.1) and by tag (9.3.2.2) are supported
 	rxm.compareNetworkIPLMethod(drcpPduInfo)
 	rxm.compareNetworkIPLSharingEncapsulation(drcpPduInfo)
 	rxm.compareGatewayOperGatewayVector()
@@ -1191,16 +1234,16 @@ func (rxm *RxMachine) saveRcvNeighborGatewayVector(portalSystemNum uint8, drcpPd
 				// TRUE, which mean we need to get the other ipp
 				for _, ipp := range dr.Ipplinks {
 					if ipp != p && ipp.DRCPEnabled {
-						dr.OtherGatewayVectorTransmit = true
+						ipp.OtherGatewayVectorTransmit = true
 					}
 				}
 			} else if vectorlen == 0 {
 				// The OtherGatewayVectorTransmit on the other IPP, if it exists and is operational, is set to
-				// TRUE
+				// FALSE
 				p.DrniNeighborState[portalSystemNum].mutex.Lock()
 				for _, ipp := range dr.Ipplinks {
 					if ipp != p && ipp.DRCPEnabled {
-						dr.OtherGatewayVectorTransmit = false
+						ipp.OtherGatewayVectorTransmit = false
 					}
 					// If the tuple (Home_Gateway_Sequence, Neighbor_Gateway_Vector) is stored as the first
 					// entry in the database, then
@@ -1349,64 +1392,75 @@ func (rxm *RxMachine) saveRcvHomeGatewayVector(portalSystemNum uint8, drcpPduInf
 //        with all its 4096 elements set to 1, and;
 //        The OtherGatewayVectorTransmit on this IPP is set to TRUE.
 func (rxm *RxMachine) saveRcvOtherGatewayVector(drcpPduInfo *layers.DRCP) {
-	/* TODO when 3P portal system supported
-
 	p := rxm.p
-	if !drcpPduInfo.State.State.GetState(layers.DRCPStateOtherGatewayBit) {
+	dr := p.dr
+
+	otherSystemNum := dr.otherPortalSystemNumber(p.DRFNeighborPortalSystemNumber)
+	if !drcpPduInfo.State.State.GetState(layers.DRCPStateOtherGatewayBit) ||
+		otherSystemNum == 0 {
 		p.DRFNeighborOperDRCPState.ClearState(layers.DRCPStateOtherGatewayBit)
 		// clear all entries == NULL
 		p.DRFRcvOtherGatewayConversationMask = [MAX_CONVERSATION_IDS]bool{}
+		return
+	}
+
+	p.DRFNeighborOperDRCPState.SetState(layers.DRCPStateOtherGatewayBit)
+	if drcpPduInfo.OtherGatewayVector.TlvTypeLength.GetTlv() != layers.DRCPTLVTypeOtherGatewayVector {
+		return
+	}
+
+	if len(drcpPduInfo.OtherGatewayVector.Vector) == 512 {
+		rxm.DrcpRxmLog(fmt.Sprintf("saveRcvOtherGatewayVector: Other Gateway Vector update portal[%d] seq %d onn %t",
+			otherSystemNum, drcpPduInfo.OtherGatewayVector.Sequence, p.DrniNeighborONN))
+		vector := make([]bool, MAX_CONVERSATION_IDS)
+		for i, j := 0, 0; i < 512; i, j = i+1, j+8 {
+			for k := 0; k < 8; k++ {
+				vector[j+k] = drcpPduInfo.OtherGatewayVector.Vector[i]>>uint(7-k)&0x1 == 1
+				p.DRFRcvOtherGatewayConversationMask[j+k] = vector[j+k]
+			}
+		}
+		p.DRFRcvOtherGatewaySequence = uint16(drcpPduInfo.OtherGatewayVector.Sequence)
+
+		// the neighbor learned the other system via this system, the info
+		// is not stored as it would otherwise loop around the ring
+		if !p.DrniNeighborONN {
+			p.DrniNeighborState[otherSystemNum].mutex.Lock()
+			p.DrniNeighborState[otherSystemNum].updateGatewayVector(drcpPduInfo.OtherGatewayVector.Sequence, vector)
+			p.DrniNeighborState[otherSystemNum].mutex.Unlock()
+
+			p.DRFOtherNeighborState.mutex.Lock()
+			p.DRFOtherNeighborState.updateGatewayVector(drcpPduInfo.OtherGatewayVector.Sequence, vector)
+			p.DRFOtherNeighborState.mutex.Unlock()
+		}
 	} else {
-		p.DRFNeighborOperDRCPState.SetState(layers.DRCPStateOtherGatewayBit)
-		if drcpPduInfo.OtherGatewayVector.TlvTypeLength.GetTlv() == layers.DRCPTLVTypeOtherGatewayVector {
-			if len(drcpPduInfo.OtherGatewayVector.Vector) > 0 {
-				for i, j := 0, 0; i < 512; i, j = i+1, j+8 {
-					p.DRFRcvOtherGatewayConversationMask[j] = drcpPduInfo.OtherGatewayVector.Vector[j]>>7&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+1] = drcpPduInfo.OtherGatewayVector.Vector[j]>>6&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+2] = drcpPduInfo.OtherGatewayVector.Vector[j]>>5&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+3] = drcpPduInfo.OtherGatewayVector.Vector[j]>>4&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+4] = drcpPduInfo.OtherGatewayVector.Vector[j]>>3&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+5] = drcpPduInfo.OtherGatewayVector.Vector[j]>>2&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+6] = drcpPduInfo.OtherGatewayVector.Vector[j]>>1&0x1 == 1
-					p.DRFRcvOtherGatewayConversationMask[j+7] = drcpPduInfo.OtherGatewayVector.Vector[j]>>0&0x1 == 1
-				}
-				if !p.DRNINeighborONN {
-					p.DrniNeighborState[neighborSystemNum].updateGatewayVector(drcpPduInfo.OtherGatewayVector.Sequence,
-						drcpPduInfo.OtherGatewayVecto.Vector)
-				}
-			} else {
-				index := p.DrniNeighborState[neighborSystemNum].getNeighborVectorGatwaySequenceIndex(drcpPduInfo.OtherGatewayVector.Sequence,
-					drcpPduInfo.NeighborGatewayVector.Vector)
-				if index != -1 {
-					p.DRFRcvOtherGatewayConversationMask = p.DRFRcvNeighborGatewayConversationMask[index]
-					if index == 1 {
-						p.OtherGatewayVectorTransmit = false
-					} else {
-						p.OtherGatewayVectorTransmit = true
-					}
-				} else {
-					for i := 0; i < 4096; i++ {
-						p.DRFRcvOtherGatewayConversationMask[i] = true
-					}
-					p.OtherGatewayVectorTransmit = true
-				}
+		p.DrniNeighborState[otherSystemNum].mutex.Lock()
+		index := p.DrniNeighborState[otherSystemNum].getNeighborVectorGatwaySequenceIndex(drcpPduInfo.OtherGatewayVector.Sequence, nil)
+		if index != -1 {
+			for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+				p.DRFRcvOtherGatewayConversationMask[i] = p.DrniNeighborState[otherSystemNum].GatewayVector[index].Vector[i]
 			}
+			// on this IPP
+			p.OtherGatewayVectorTransmit = index != 0
+		} else {
+			for i := 0; i < MAX_CONVERSATION_IDS; i++ {
+				p.DRFRcvOtherGatewayConversationMask[i] = true
+			}
+			p.OtherGatewayVectorTransmit = true
 		}
+		p.DrniNeighborState[otherSystemNum].mutex.Unlock()
 	}
-	*/
-
 }
 
 // compareNetworkIPLSharingEncapsulation will compare the local portal encap method
-// and sharing method with what the neighbor has configured
-// NOTE: this implementation only supports SHARING_BY_TIME, thus this logic
-//       should have not affect
+// and sharing method with what the neighbor has configured.  The IPL and Net
+// encap digests must agree otherwise frames translated by one portal system
+// would be misinterpreted by the other
 func (rxm *RxMachine) compareNetworkIPLSharingEncapsulation(drcpPduInfo *layers.DRCP) {
 	p := rxm.p
 	dr := p.dr
 	if (dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_TAG ||
 		dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_ITAG ||
-		dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_BTAG ||
+		dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_BVID ||
 		dr.DrniEncapMethod == ENCAP_METHOD_SHARING_BY_PSEUDOWIRE) &&
 		drcpPduInfo.NetworkIPLEncapsulation.TlvTypeLength.GetTlv() == layers.DRCPTLVNetworkIPLSharingEncapsulation &&
 		drcpPduInfo.NetworkIPLMethod.TlvTypeLength.GetTlv() == layers.DRCPTLVNetworkIPLSharingMethod {
@@ -1423,7 +1477,8 @@ func (rxm *RxMachine) compareNetworkIPLSharingEncapsulation(drcpPduInfo *layers.
 		if p.DRFHomeNetworkIPLSharingMethod == p.DRFNeighborNetworkIPLSharingMethod &&
 			p.DRFHomeNetworkIPLIPLEncapDigest == p.DRFNeighborNetworkIPLIPLEncapDigest &&
 			p.DRFHomeNetworkIPLIPLNetEncapDigest == p.DRFNeighborNetworkIPLNetEncapDigest {
-			rxm.DrcpRxmLog("Neighbor and Home IPL Sharing And Encap Do not agree")
+			rxm.DrcpRxmLog("Neighbor and Home IPL Sharing And Encap agree")
+			defer p.NotifyCCEncTagSharedChange(RxMachineModuleStr, p.CCEncTagShared, true)
 			p.CCEncTagShared = true
 		} else {
 			rxm.DrcpRxmLog(fmt.Sprintf("Neighbor and Home IPL Sharing And Encap Do not agree local method[%+v] ipldigest[%+v] netdigest[%+v] neighbor method[%+v] ipldigest[%+v] netdigest[%+v]",
@@ -1433,59 +1488,85 @@ func (rxm *RxMachine) compareNetworkIPLSharingEncapsulation(drcpPduInfo *layers.
 				p.DRFNeighborNetworkIPLSharingMethod,
 				p.DRFNeighborNetworkIPLIPLEncapDigest,
 				p.DRFNeighborNetworkIPLNetEncapDigest))
+			defer p.NotifyCCEncTagSharedChange(RxMachineModuleStr, p.CCEncTagShared, false)
 			p.CCEncTagShared = false
 		}
 	}
 }
 
-// compareOtherPortsInfo function will be used to compare the Other Ports info logic
-// but since we are only supporting a 2P System then this function logic is not needed
-// will be a placeholder and know that it is incomplete
+// compareOtherPortsInfo records the Other Ports Information TLV which carries
+// the ports of the Other neighbor, the portal system which is not the immediate
+// neighbor on this IPP. Only present in a Three Portal System
 func (rxm *RxMachine) compareOtherPortsInfo(drcpPduInfo *layers.DRCP) {
-	/*
-		p := rxm.p
-		if drcpPduInfo.OtherPortsInfo.TlvTypeLength.GetTlv() == layers.DRCPTLVTypeOtherPortsInfo {
-			// the Other_Neighbor_Ports in the Other Ports Information TLV,
-			// carried in a received DRCPDU on the IPP, are used as the current values for the
-			// DRF_Other_Neighbor_State on this IPP and are associated with the Portal System identified
-			// by the value assigned to the two most significant bits of the
-			// DRF_Other_Neighbor_Admin_Aggregator_Key carried within the Other Ports Information
-			// TLV in the received DRCPDU
-			p.DRFOtherNeighborState.PortIdList = drcpPduInfo.OtherPortsInfo.NeighborPorts
-		} else if p.dr.DrniThreeSystemPortal {
+	p := rxm.p
+	dr := p.dr
+
+	if drcpPduInfo.OtherPortsInfo.TlvTypeLength.GetTlv() == layers.DRCPTLVTypeOtherPortsInfo {
+		// the Other_Neighbor_Ports in the Other Ports Information TLV,
+		// carried in a received DRCPDU on the IPP, are used as the current values for the
+		// DRF_Other_Neighbor_State on this IPP and are associated with the Portal System identified
+		// by the value assigned to the two most significant bits of the
+		// DRF_Other_Neighbor_Admin_Aggregator_Key carried within the Other Ports Information
+		// TLV in the received DRCPDU
+		p.DRFOtherNeighborAdminAggregatorKey = drcpPduInfo.OtherPortsInfo.AdminAggKey
+		p.DRFOtherNeighborOperPartnerAggregatorKey = drcpPduInfo.OtherPortsInfo.OperPartnerAggKey
+		otherSystemNum := uint8(p.DRFOtherNeighborAdminAggregatorKey >> 14 & 0x3)
+		if otherSystemNum == 0 {
+			otherSystemNum = dr.otherPortalSystemNumber(p.DRFNeighborPortalSystemNumber)
+		}
+		if otherSystemNum == 0 ||
+			otherSystemNum != dr.otherPortalSystemNumber(p.DRFNeighborPortalSystemNumber) {
+			rxm.DrcpRxmLog(fmt.Sprintf("Other Ports Info for unexpected portal system %d neighbor %d", otherSystemNum, p.DRFNeighborPortalSystemNumber))
+			return
+		}
+
+		p.DRFOtherNeighborState.mutex.Lock()
+		if p.DrniNeighborONN {
+			// info was learned by the neighbor via this system
 			p.DRFOtherNeighborState.OpState = false
 			p.DRFOtherNeighborState.GatewayVector = nil
 			p.DRFOtherNeighborState.PortIdList = nil
-			// no Portal System state information is available on this IPP for the distant
-			// Neighbor Portal System on the IPP
-			// TODO what does this mean that the info is not present for now going to clear/set
-			// the keys
-			p.DRFNeighborAdminAggregatorKey = p.dr.DRFHomeAdminAggregatorKey
-			p.DRFNeighborOperPartnerAggregatorKey = p.dr.DRFHomeOperPartnerAggregatorKey
-			// TODO Document states to set to this but if we reached this if the other should be
-			// set to NULL
-			//p.DRFOtherNeighborAdminAggregatorKey = p.DRFOtherNeighborAdminAggregatorKey
-			//p.DRFOtherNeighborOperPartnerAggregatorKey = p.DRFOtherNeighborOperPartnerAggregatorKey
-			p.DRFOtherNeighborAdminAggregatorKey = 0
-			p.DRFOtherNeighborOperPartnerAggregatorKey = 0
+		} else {
+			p.DRFOtherNeighborState.PortIdList = drcpPduInfo.OtherPortsInfo.NeighborPorts
+		}
+		p.DRFOtherNeighborState.mutex.Unlock()
+
+		if !p.DrniNeighborONN {
+			p.DrniNeighborState[otherSystemNum].mutex.Lock()
+			p.DrniNeighborState[otherSystemNum].PortIdList = drcpPduInfo.OtherPortsInfo.NeighborPorts
+			p.DrniNeighborState[otherSystemNum].mutex.Unlock()
 		}
-	*/
+	} else if dr.DrniThreeSystemPortal {
+		// no Portal System state information is available on this IPP for the distant
+		// Neighbor Portal System on the IPP
+		p.DRFOtherNeighborState.mutex.Lock()
+		p.DRFOtherNeighborState.OpState = false
+		p.DRFOtherNeighborState.GatewayVector = nil
+		p.DRFOtherNeighborState.PortIdList = nil
+		p.DRFOtherNeighborState.mutex.Unlock()
+		p.DRFOtherNeighborAdminAggregatorKey = 0
+		p.DRFOtherNeighborOperPartnerAggregatorKey = 0
+	}
 }
 
 // compareNetworkIPLMethod will compare the network sharing method between what is configured
 // between local portal and neighbor portal.
-// NOTE: only SHARING_BY_TIME supported in this implementation
+// NOTE: sharing by tag methods are compared in compareNetworkIPLSharingEncapsulation
 func (rxm *RxMachine) compareNetworkIPLMethod(drcpPduInfo *layers.DRCP) {
 	p := rxm.p
 	if drcpPduInfo.NetworkIPLMethod.TlvTypeLength.GetTlv() == layers.DRCPTLVNetworkIPLSharingMethod {
 		p.DRFNeighborNetworkIPLSharingMethod = drcpPduInfo.NetworkIPLMethod.Method
 		if p.DRFNeighborNetworkIPLSharingMethod == p.DRFHomeNetworkIPLSharingMethod &&
 			p.DRFHomeNetworkIPLSharingMethod == ENCAP_METHOD_SHARING_BY_TIME {
+			defer p.NotifyCCTimeSharedChange(RxMachineModuleStr, p.CCTimeShared, true)
 			p.CCTimeShared = true
 		} else {
-			rxm.DrcpRxmLog(fmt.Sprintf("Neighbor and Home IPL Sharing by Time differ local method[%+v] neighbor method[%+v]",
-				p.DRFNeighborNetworkIPLSharingMethod,
-				p.DRFHomeNetworkIPLSharingMethod))
+			if p.DRFHomeNetworkIPLSharingMethod == ENCAP_METHOD_SHARING_BY_TIME {
+				rxm.DrcpRxmLog(fmt.Sprintf("Neighbor and Home IPL Sharing by Time differ local method[%+v] neighbor method[%+v]",
+					p.DRFHomeNetworkIPLSharingMethod,
+					p.DRFNeighborNetworkIPLSharingMethod))
+			}
+			defer p.NotifyCCTimeSharedChange(RxMachineModuleStr, p.CCTimeShared, false)
 			p.CCTimeShared = false
 		}
 	}
@@ -1549,8 +1630,7 @@ func (rxm *RxMachine) compareGatewayOperGatewayVector() {
 		if p.MissingRcvGatewayConVector {
 			for i := 0; i < 1024; i++ {
 				if p.dr.DrniThreeSystemPortal {
-					// TOOD need to change logic
-					p.DrniNeighborGatewayConversation[i] = p.DRFHomeConfNeighborPortalSystemNumber
+					p.DrniNeighborGatewayConversation[i] = threePortalConversationFillByte(p.DRFHomeConfNeighborPortalSystemNumber)
 				} else {
 					p.DrniNeighborGatewayConversation[i] = 0xff
 				}
@@ -1587,8 +1667,8 @@ func (rxm *RxMachine) comparePortIds() {
 	dr := p.dr
 	portListDiffer := false
 
-	// only need to check 1, 2 as we only support 2P systems
-	for i := 1; i < MAX_PORTAL_SYSTEM_IDS && !portListDiffer; i++ {
+	// portal system 3 lists are empty in a 2P system
+	for i := 1; i <= MAX_PORTAL_SYSTEM_IDS && !portListDiffer; i++ {
 		dr.DrniPortalSystemState[i].mutex.Lock()
 		p.DrniNeighborState[i].mutex.Lock()
 		//rxm.DrcpRxmLog(fmt.Sprintf("comparePortIds: localPortal[%d] Portal PortList %v neighbor view PortList %v\n",
@@ -1618,7 +1698,7 @@ func (rxm *RxMachine) comparePortIds() {
 		if p.MissingRcvPortConVector {
 			for i := 0; i < 1024; i++ {
 				if p.dr.DrniThreeSystemPortal {
-					// TODO when 3P system supported
+					p.DrniNeighborPortConversation[i] = threePortalConversationFillByte(p.DRFHomeConfNeighborPortalSystemNumber)
 				} else {
 					p.DrniNeighborPortConversation[i] = 0xff
 				}
@@ -1325,6 +1325,12 @@ func TestRxMachineRxPktDRCPDUNeighborPortalInfoDifferGatewayAlgorithmDiff(t *tes
 	if !strings.Contains(ipp.DifferPortalReason, "Gateway Algorithm") {
 		t.Error("ERROR Portal Difference Detected", ipp.DifferPortalReason)
 	}
+	if !ipp.DifferGatewayAlgorithm {
+		t.Error("ERROR DifferGatewayAlgorithm is not set as it should be")
+	}
+	if !strings.Contains(ipp.DifferPortalReason, "Neighbor Admin Gateway Algorithm") {
+		t.Error("ERROR Neighbor Admin Gateway Algorithm Difference not Detected", ipp.DifferPortalReason)
+	}
 
 	lacp.DeleteLaAgg(a.AggId)
 	dr.DeleteDistributedRelay()
@@ -190,6 +190,11 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 				val = 1
 			}
 			drcp.PortalConfigInfo.TopologyState.SetState(layers.DRCPTopologyStateCommonMethods, val)
+			val = 0
+			if dr.DrniThreeSystemPortal && p.ONN {
+				val = 1
+			}
+			drcp.PortalConfigInfo.TopologyState.SetState(layers.DRCPTopologyStateOtherNonNeighbor, val)
 
 			drcp.PortalConfigInfo.TlvTypeLength.SetTlv(uint16(layers.DRCPTLVTypePortalConfigInfo))
 			drcp.PortalConfigInfo.TlvTypeLength.SetLength(uint16(layers.DRCPTLVPortalConfigurationInfoLength))
@@ -233,7 +238,7 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 						}
 					}
 				} else {
-					// TODO 3P Not supported
+					pktLength += txm.setThreePortalPortConversationVector(&drcp)
 				}
 			} else if p.GatewayConversationTransmit &&
 				p.PortConversationTransmit &&
@@ -271,14 +276,14 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 							}
 						}
 					} else {
-						// TODO 3P Not supported
+						pktLength += txm.setThreePortalGatewayConversationVector(&drcp)
 					}
 				} else {
-					drcp.TwoPortalPortConversationVector.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV2PPortConversationVector))
-					drcp.TwoPortalPortConversationVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLV2PPortConversationVectorLength))
-					pktLength += uint32(layers.DRCPTLV2PPortConversationVectorLength) + uint32(layers.DRCPTlvAndLengthSize)
 					// lets only send the port conversation vector
 					if !dr.DrniThreeSystemPortal {
+						drcp.TwoPortalPortConversationVector.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV2PPortConversationVector))
+						drcp.TwoPortalPortConversationVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLV2PPortConversationVectorLength))
+						pktLength += uint32(layers.DRCPTLV2PPortConversationVectorLength) + uint32(layers.DRCPTlvAndLengthSize)
 						for i, j := 0, 0; i < 512; i, j = i+1, j+8 {
 
 							if dr.DrniPortalSystemPortConversation[j] {
@@ -307,7 +312,7 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 							}
 						}
 					} else {
-						// TODO 3P Not supported
+						pktLength += txm.setThreePortalPortConversationVector(&drcp)
 					}
 				}
 			} else if p.GatewayConversationTransmit {
@@ -345,7 +350,7 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 						}
 					}
 				} else {
-					// TODO 3P Not supported
+					pktLength += txm.setThreePortalGatewayConversationVector(&drcp)
 				}
 			} else if p.PortConversationTransmit {
 				if !dr.DrniThreeSystemPortal {
@@ -381,13 +386,25 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 
 					}
 				} else {
-					// TODO 3P Not supported
+					pktLength += txm.setThreePortalPortConversationVector(&drcp)
 				}
 			}
 
 			drcp.State.TlvTypeLength.SetTlv(uint16(layers.DRCPTLVTypeDRCPState))
 			drcp.State.TlvTypeLength.SetLength(uint16(layers.DRCPTLVStateLength))
 			drcp.State.State = dr.DRFHomeOperDRCPState
+			// Other Gateway is per IPP as it reflects the Other neighbor
+			// of the immediate neighbor on this IPP
+			otherSystemNum := dr.otherPortalSystemNumber(p.DRFNeighborPortalSystemNumber)
+			if otherSystemNum != 0 {
+				dr.DrniPortalSystemState[otherSystemNum].mutex.Lock()
+				if dr.DrniPortalSystemState[otherSystemNum].OpState {
+					drcp.State.State.SetState(layers.DRCPStateOtherGatewayBit)
+				} else {
+					drcp.State.State.ClearState(layers.DRCPStateOtherGatewayBit)
+				}
+				dr.DrniPortalSystemState[otherSystemNum].mutex.Unlock()
+			}
 			pktLength += uint32(layers.DRCPTLVStateLength) + uint32(layers.DRCPTlvAndLengthSize)
 
 			drcp.HomePortsInfo = layers.DRCPHomePortsInfoTlv{
@@ -425,17 +442,21 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 			drcp.NeighborPortsInfo.TlvTypeLength.SetTlv(uint16(layers.DRCPTLVTypeNeighborPortsInfo))
 			drcp.NeighborPortsInfo.TlvTypeLength.SetLength(uint16(4 + (4 * len(drcp.NeighborPortsInfo.ActiveNeighborPorts))))
 
+			if otherSystemNum != 0 {
+				pktLength += txm.setOtherPortsInfo(&drcp, otherSystemNum)
+			}
+
 			//portMtu := uint32(utils.PortConfigMap[int32(p.Id)].Mtu)
 			portMtu := uint32(32768)
 			/*
 				fmt.Printf("TX: HomeGatewayVectorTransmit %t OtherGatewayVectorTRansmit %t pktlength %d mtu %d\n",
 					dr.HomeGatewayVectorTransmit,
-					dr.OtherGatewayVectorTransmit,
+					p.OtherGatewayVectorTransmit,
 					pktLength,
 					portMtu)
 			*/
 			if (dr.HomeGatewayVectorTransmit ||
-				dr.OtherGatewayVectorTransmit) &&
+				p.OtherGatewayVectorTransmit) &&
 				pktLength < portMtu {
 				// TODO WTF is the standard trying to say is supposed to happen here
 				// Only include it if it does not make the packet exceed the MTU?  But other parts of standard say
@@ -473,13 +494,36 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 					drcp.HomeGatewayVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLVHomeGatewayVectorLength_1))
 				}
 
-				// 3P system not supported, thus other should not be set
+				// other vector is only valid in a 3P system
 				drcp.OtherGatewayVector = layers.DRCPOtherGatewayVectorTlv{}
+				if otherSystemNum != 0 {
+					dr.DrniPortalSystemState[otherSystemNum].mutex.Lock()
+					othervector := dr.DrniPortalSystemState[otherSystemNum].getGatewayVectorByIndex(0)
+					if othervector != nil {
+						drcp.OtherGatewayVector.Sequence = othervector.Sequence
+					}
+					// vector is only included when it has changed
+					if othervector != nil &&
+						othervector.Vector != nil &&
+						p.OtherGatewayVectorTransmit {
+						drcp.OtherGatewayVector.Vector = make([]uint8, 512)
+						for i, vector := range othervector.Vector {
+							if vector {
+								drcp.OtherGatewayVector.Vector[i/8] |= uint8(1 << uint(7-i%8))
+							}
+						}
+					}
+					dr.DrniPortalSystemState[otherSystemNum].mutex.Unlock()
+				}
 				drcp.OtherGatewayVector.TlvTypeLength.SetTlv(uint16(layers.DRCPTLVTypeOtherGatewayVector))
-				drcp.OtherGatewayVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLVOtherGatewayVectorLength_1))
+				if len(drcp.OtherGatewayVector.Vector) > 0 {
+					drcp.OtherGatewayVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLVOtherGatewayVectorLength_2))
+				} else {
+					drcp.OtherGatewayVector.TlvTypeLength.SetLength(uint16(layers.DRCPTLVOtherGatewayVectorLength_1))
+				}
 
 			} else if (dr.HomeGatewayVectorTransmit ||
-				dr.OtherGatewayVectorTransmit) &&
+				p.OtherGatewayVectorTransmit) &&
 				pktLength > portMtu {
 				txm.DrcpTxmLog(fmt.Sprintf("Unable to send packet pkt size %d exceeds MTU %d of IPP %d", pktLength, portMtu, p.Id))
 			}
@@ -527,6 +571,68 @@ func (txm *TxMachine) DrcpTxMachineOn(m fsm.Machine, data interface{}) fsm.State
 	return TxmStateOn
 }
 
+// setThreePortalGatewayConversationVector fills in the 3P Gateway Conversation
+// Vector TLVs from the Drni_Gateway_Conversation, each conversation holds the
+// Portal System Number of the gateway passing it.  Returns the length added
+// to the packet
+func (txm *TxMachine) setThreePortalGatewayConversationVector(drcp *layers.DRCP) uint32 {
+	dr := txm.p.dr
+
+	vector1, vector2 := threePortalConversationVectorBuild(func(cid int) uint8 {
+		if dr.DrniGatewayConversation[cid] != nil {
+			return dr.DrniGatewayConversation[cid][0]
+		}
+		return 0
+	})
+	drcp.ThreePortalGatewayConversationVector1.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV3PGatewayConversationVector1))
+	drcp.ThreePortalGatewayConversationVector1.TlvTypeLength.SetLength(uint16(layers.DRCPTLV3PGatewayConversationVectorLength))
+	drcp.ThreePortalGatewayConversationVector1.Vector = vector1
+	drcp.ThreePortalGatewayConversationVector2.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV3PGatewayConversationVector2))
+	drcp.ThreePortalGatewayConversationVector2.TlvTypeLength.SetLength(uint16(layers.DRCPTLV3PGatewayConversationVectorLength))
+	drcp.ThreePortalGatewayConversationVector2.Vector = vector2
+	return 2 * (uint32(layers.DRCPTLV3PGatewayConversationVectorLength) + uint32(layers.DRCPTlvAndLengthSize))
+}
+
+// setThreePortalPortConversationVector fills in the 3P Port Conversation
+// Vector TLVs from the Drni_Port_Conversation, each conversation holds the
+// Portal System Number of the port passing it.  Returns the length added
+// to the packet
+func (txm *TxMachine) setThreePortalPortConversationVector(drcp *layers.DRCP) uint32 {
+	dr := txm.p.dr
+
+	vector1, vector2 := threePortalConversationVectorBuild(dr.portConversationPortalSystem)
+	drcp.ThreePortalPortConversationVector1.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV3PPortConversationVector1))
+	drcp.ThreePortalPortConversationVector1.TlvTypeLength.SetLength(uint16(layers.DRCPTLV3PPortConversationVectorLength))
+	drcp.ThreePortalPortConversationVector1.Vector = vector1
+	drcp.ThreePortalPortConversationVector2.TlvTypeLength.SetTlv(uint16(layers.DRCPTLV3PPortConversationVector2))
+	drcp.ThreePortalPortConversationVector2.TlvTypeLength.SetLength(uint16(layers.DRCPTLV3PPortConversationVectorLength))
+	drcp.ThreePortalPortConversationVector2.Vector = vector2
+	return 2 * (uint32(layers.DRCPTLV3PPortConversationVectorLength) + uint32(layers.DRCPTlvAndLengthSize))
+}
+
+// setOtherPortsInfo fills in the Other Ports Information TLV with the active
+// ports of the Other neighbor.  The two most significant bits of the admin key
+// identify the Other Portal System.  Returns the length added to the packet
+func (txm *TxMachine) setOtherPortsInfo(drcp *layers.DRCP, otherSystemNum uint8) uint32 {
+	dr := txm.p.dr
+
+	drcp.OtherPortsInfo = layers.DRCPOtherPortsInfoTlv{
+		AdminAggKey:       dr.DRFHomeAdminAggregatorKey&0x3fff | uint16(otherSystemNum)<<14,
+		OperPartnerAggKey: dr.DRFHomeOperPartnerAggregatorKey,
+	}
+	dr.DrniPortalSystemState[otherSystemNum].mutex.Lock()
+	if dr.DrniPortalSystemState[otherSystemNum].OpState {
+		for _, portId := range dr.DrniPortalSystemState[otherSystemNum].PortIdList {
+			drcp.OtherPortsInfo.NeighborPorts = append(drcp.OtherPortsInfo.NeighborPorts, portId)
+		}
+	}
+	dr.DrniPortalSystemState[otherSystemNum].mutex.Unlock()
+	length := uint32(4 + (4 * len(drcp.OtherPortsInfo.NeighborPorts)))
+	drcp.OtherPortsInfo.TlvTypeLength.SetTlv(uint16(layers.DRCPTLVTypeOtherPortsInfo))
+	drcp.OtherPortsInfo.TlvTypeLength.SetLength(uint16(length))
+	return length + uint32(layers.DRCPTlvAndLengthSize)
+}
+
 // DrcpTxMachineOff will ensure that no packets are transmitted, typically means that
 // drcp has been disabled or a packet was just transmitted
 func (txm *TxMachine) DrcpTxMachineOff(m fsm.Machine, data interface{}) fsm.State {
@@ -0,0 +1,321 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// update.go
+package drcp
+
+import (
+	"fmt"
+	"l2/lacp/protocol/utils"
+)
+
+// The following updates are applied to a running Distributed Relay without
+// tearing down the portal.  The neighbor is informed of the change via NTT
+// and the Portal System machine re-evaluates the portal via Change Portal
+
+// UpdateDistributedRelayPortalPriority will update the portal priority, the
+// aggregator ports are updated with the new system info once the key has
+// been negotiated
+func UpdateDistributedRelayPortalPriority(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) ||
+		dr.DrniPortalPriority == cfg.DrniPortalPriority {
+		return
+	}
+
+	dr.LaDrLog(fmt.Sprintf("Updating Portal Priority from %d to %d", dr.DrniPortalPriority, cfg.DrniPortalPriority))
+	dr.DrniPortalPriority = cfg.DrniPortalPriority
+
+	if dr.a != nil &&
+		dr.PsMachineFsm != nil &&
+		dr.PsMachineFsm.Machine.Curr.CurrentState() == PsmStatePortalSystemUpdate {
+		for _, aggport := range dr.a.PortNumList {
+			dr.setAggPortSystemInfo(DRCPConfigModuleStr, aggport)
+		}
+	}
+	dr.notifyNTTDRCPDU(DRCPConfigModuleStr)
+}
+
+// UpdateDistributedRelayGatewayAlgorithm will update the gateway algorithm,
+// the gateway conversations are rebuilt from the conversations of the
+// new algorithm
+func UpdateDistributedRelayGatewayAlgorithm(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) {
+		return
+	}
+	gatewayAlgorithm := convertDrniGatewayAlgorithm(cfg.DrniGatewayAlgorithm)
+	ecmpFlowHashFields := cfg.DrniEcmpFlowHashFields
+	if ecmpFlowHashFields == 0 {
+		ecmpFlowHashFields = ECMP_FLOW_HASH_FIELDS_DEFAULT
+	}
+	if dr.DrniGatewayAlgorithm == gatewayAlgorithm &&
+		dr.DrniEcmpFlowHashFields == ecmpFlowHashFields {
+		return
+	}
+
+	dr.LaDrLog(fmt.Sprintf("Updating Gateway Algorithm from %s to %s", dr.DrniGatewayAlgorithm.String(), gatewayAlgorithm.String()))
+	if dr.DrniGatewayAlgorithm != gatewayAlgorithm {
+		dr.detachGatewayConversations()
+	}
+	prevGatewayAlgorithm := dr.DrniGatewayAlgorithm
+	dr.DrniGatewayAlgorithm = gatewayAlgorithm
+	dr.DRFHomeGatewayAlgorithm = gatewayAlgorithm
+	dr.DrniServiceIdList = append([]uint32(nil), cfg.DrniServiceIdList...)
+	if prevGatewayAlgorithm != gatewayAlgorithm {
+		dr.attachGatewayConversations()
+	}
+	dr.DrniEcmpFlowHashFields = ecmpFlowHashFields
+	if dr.a != nil &&
+		dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+		dr.setEcmpFlowHashFields()
+	}
+
+	// conversations of the previous algorithm no longer apply
+	for cid := range dr.DrniConvAdminGateway {
+		dr.DrniConvAdminGateway[cid] = nil
+	}
+	dr.SetTimeSharingPortAndGatwewayDigest()
+	dr.notifyChangePortal(DRCPConfigModuleStr)
+	dr.notifyNTTDRCPDU(DRCPConfigModuleStr)
+}
+
+// UpdateDistributedRelayServiceIdList will update the I-SID or TE-SID service
+// identifiers carried by the portal, conversations of identifiers which were
+// removed are deleted and conversations of new identifiers are created
+func UpdateDistributedRelayServiceIdList(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) {
+		return
+	}
+
+	prevIds := make(map[uint32]bool)
+	for _, id := range dr.DrniServiceIdList {
+		prevIds[id] = true
+	}
+	newIds := make(map[uint32]bool)
+	for _, id := range cfg.DrniServiceIdList {
+		newIds[id] = true
+	}
+	dr.DrniServiceIdList = append([]uint32(nil), cfg.DrniServiceIdList...)
+	if dr.DrniGatewayAlgorithm != GATEWAY_ALGORITHM_ISID &&
+		dr.DrniGatewayAlgorithm != GATEWAY_ALGORITHM_TE_SID {
+		return
+	}
+
+	changed := false
+	for id := range prevIds {
+		if !newIds[id] {
+			DeleteConversationId(dr.serviceConversationConfig(id), false)
+			changed = true
+		}
+	}
+	for id := range newIds {
+		if !prevIds[id] {
+			if err := CreateConversationId(dr.serviceConversationConfig(id)); err != nil {
+				dr.LaDrLog(err.Error())
+			}
+			changed = true
+		}
+	}
+	if changed {
+		dr.LaDrLog(fmt.Sprintf("Updated Service Id List %v", dr.DrniServiceIdList))
+		dr.notifyNTTDRCPDU(DRCPConfigModuleStr)
+	}
+}
+
+// UpdateDistributedRelayConvAdminGateway will update the user supplied
+// gateway list of the conversations.  A conversation without a list will
+// have its gateway assigned by the gateway algorithm
+func UpdateDistributedRelayConvAdminGateway(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) {
+		return
+	}
+
+	changedcids := dr.setConvAdminGatewayConfig(cfg.DrniConvAdminGateway)
+	if len(changedcids) == 0 {
+		return
+	}
+	if dr.DrniGatewayAlgorithm == GATEWAY_ALGORITHM_ECMP_FLOW_HASH {
+		dr.LaDrLog(fmt.Sprintf("Conversation Admin Gateway saved but not applied, gateways are assigned by %s", dr.DrniGatewayAlgorithm.String()))
+		return
+	}
+
+	dr.LaDrLog(fmt.Sprintf("Updating Conversation Admin Gateway for %d conversations", len(changedcids)))
+	// clearing the gateway list will allow the digest calculation to
+	// pick up the new list and inform the portal of the change
+	for _, cid := range changedcids {
+		dr.DrniConvAdminGateway[cid] = nil
+	}
+	dr.SetTimeSharingPortAndGatwewayDigest()
+	dr.notifyNTTDRCPDU(DRCPConfigModuleStr)
+}
+
+// UpdateDistributedRelayIntraPortalLinkList will update the Intra Portal Link
+// list, IPPs which are no longer in the list are deleted and new IPPs are
+// started if the aggregator has been attached.  IPPs which have not changed
+// are left running
+func UpdateDistributedRelayIntraPortalLinkList(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) {
+		return
+	}
+
+	prevIntraPortalLinkList := dr.DrniIntraPortalLinkList
+	dr.setIntraPortalLinkList(cfg.DrniIntraPortalLinkList)
+	if prevIntraPortalLinkList == dr.DrniIntraPortalLinkList {
+		return
+	}
+
+	ipplinks := make([]*DRCPIpp, 0)
+	for _, ipp := range dr.Ipplinks {
+		if dr.isIntraPortalLink(ipp.Id, ipp.DRFHomeConfNeighborPortalSystemNumber) {
+			ipplinks = append(ipplinks, ipp)
+		} else {
+			dr.LaDrLog(fmt.Sprintf("Deleting Ipp %s", ipp.Name))
+			ipp.DeleteDRCPIpp()
+		}
+	}
+
+	newipplinks := make([]*DRCPIpp, 0)
+	for _, ippid := range dr.DrniIntraPortalLinkList {
+		portid := ippid & 0xffff
+		if portid == 0 {
+			continue
+		}
+		found := false
+		for _, ipp := range ipplinks {
+			if ipp.Id == portid &&
+				ipp.DRFHomeConfNeighborPortalSystemNumber == uint8(ippid>>16&0x3) {
+				found = true
+			}
+		}
+		if !found {
+			dr.LaDrLog(fmt.Sprintf("Adding Ipp %s", utils.PortConfigMap[int32(portid)].Name))
+			ipp := NewDRCPIpp(ippid, dr)
+			// disabled until an aggregator has been attached
+			ipp.DRCPEnabled = false
+			ipplinks = append(ipplinks, ipp)
+			newipplinks = append(newipplinks, ipp)
+		}
+	}
+	dr.Ipplinks = ipplinks
+
+	if dr.a != nil {
+		for _, ipp := range newipplinks {
+			dr.LaDrLog(fmt.Sprintf("Starting Ipp %s", ipp.Name))
+			ipp.DRCPEnabled = true
+			ipp.BEGIN(false)
+		}
+	}
+	dr.notifyChangePortal(DRCPConfigModuleStr)
+}
+
+// UpdateDistributedRelayEncapMap will update the IPL and Net Encap Maps used
+// when the IPL is shared by tag.  The translation programmed on each IPP is
+// replaced and the new digests are sent to the neighbor, if the neighbor does
+// not agree the Net/IPL sharing machine will stop manipulating frames
+func UpdateDistributedRelayEncapMap(cfg *DistributedRelayConfig) {
+	var dr *DistributedRelay
+	if !DrFindByName(cfg.DrniName, &dr) {
+		return
+	}
+	if encapMapEqual(dr.DrniIPLEncapMap, cfg.DrniIPLEncapMap) &&
+		encapMapEqual(dr.DrniNetEncapMap, cfg.DrniNetEncapMap) {
+		return
+	}
+
+	dr.LaDrLog("Updating IPL and Net Encap Map")
+	// translation was programmed using the previous maps
+	for _, ipp := range dr.Ipplinks {
+		if ipp.EnabledEncTagShared &&
+			ipp.NetIplShareMachineFsm != nil {
+			ipp.NetIplShareMachineFsm.setIplEncapTranslation(false)
+		}
+	}
+
+	dr.DrniIPLEncapMap = make(map[uint32]uint32)
+	for cid, id := range cfg.DrniIPLEncapMap {
+		dr.DrniIPLEncapMap[cid] = id
+	}
+	dr.DrniNetEncapMap = make(map[uint32]uint32)
+	for cid, id := range cfg.DrniNetEncapMap {
+		dr.DrniNetEncapMap[cid] = id
+	}
+
+	for _, ipp := range dr.Ipplinks {
+		ipp.DRFHomeNetworkIPLIPLEncapDigest = Md5Digest{}.calculateEncapDigest(dr.DrniIPLEncapMap)
+		ipp.DRFHomeNetworkIPLIPLNetEncapDigest = Md5Digest{}.calculateEncapDigest(dr.DrniNetEncapMap)
+		if ipp.EnabledEncTagShared &&
+			ipp.NetIplShareMachineFsm != nil {
+			ipp.NetIplShareMachineFsm.setIplEncapTranslation(true)
+		}
+	}
+	dr.notifyNTTDRCPDU(DRCPConfigModuleStr)
+}
+
+// isIntraPortalLink returns true if the port connected to the neighbor portal
+// system is in the Intra Portal Link list
+func (dr *DistributedRelay) isIntraPortalLink(portid uint32, neighborPortalSystemNumber uint8) bool {
+	for _, ippid := range dr.DrniIntraPortalLinkList {
+		if ippid&0xffff == portid &&
+			uint8(ippid>>16&0x3) == neighborPortalSystemNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyChangePortal informs the Portal System machine that the portal
+// configuration has changed
+func (dr *DistributedRelay) notifyChangePortal(src string) {
+	if dr.PsMachineFsm != nil {
+		dr.ChangePortal = true
+		dr.PsMachineFsm.PsmEvents <- utils.MachineEvent{
+			E:   PsmEventChangePortal,
+			Src: src,
+		}
+	}
+}
+
+// notifyNTTDRCPDU will transmit a DRCPDU on each IPP so that the neighbor
+// learns of the updated configuration
+func (dr *DistributedRelay) notifyNTTDRCPDU(src string) {
+	for _, ipp := range dr.Ipplinks {
+		defer ipp.NotifyNTTDRCPUDChange(src, ipp.NTTDRCPDU, true)
+		ipp.NTTDRCPDU = true
+	}
+}
+
+func encapMapEqual(a, b map[uint32]uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for cid, id := range a {
+		if otherid, ok := b[cid]; !ok || otherid != id {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// update_test.go
+package drcp
+
+import (
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+	"testing"
+)
+
+func UpdateTestDistributedRelayConfig() *DistributedRelayConfig {
+	return &DistributedRelayConfig{
+		DrniName:                          "DR-1",
+		DrniPortalAddress:                 "00:00:DE:AD:BE:EF",
+		DrniPortalPriority:                128,
+		DrniThreePortalSystem:             false,
+		DrniPortalSystemNumber:            1,
+		DrniIntraPortalLinkList:           [3]uint32{uint32(ipplink1)},
+		DrniAggregator:                    100,
+		DrniGatewayAlgorithm:              "00:80:C2:01",
+		DrniNeighborAdminGatewayAlgorithm: "00:80:C2:01",
+		DrniNeighborAdminPortAlgorithm:    "00:80:C2:01",
+		DrniNeighborAdminDRCPState:        "00000000",
+		DrniEncapMethod:                   "00:80:C2:01",
+		DrniPortConversationControl:       false,
+		DrniIntraPortalPortProtocolDA:     "01:80:C2:00:00:03",
+	}
+}
+
+func TestUpdateDistributedRelayIntraPortalLinkList(t *testing.T) {
+	ConfigTestSetup()
+
+	cfg := UpdateTestDistributedRelayConfig()
+	// just create instance not starting any state machines
+	dr := NewDistributedRelay(cfg)
+	origipp := dr.Ipplinks[0]
+
+	// nothing changed the ipp should not be touched
+	UpdateDistributedRelayIntraPortalLinkList(cfg)
+	if len(dr.Ipplinks) != 1 ||
+		dr.Ipplinks[0] != origipp {
+		t.Error("ERROR Ipp was re-created when the Intra Portal Link List did not change")
+	}
+
+	// add a link
+	cfg.DrniIntraPortalLinkList = [3]uint32{uint32(ipplink1), uint32(ipplink2)}
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+	UpdateDistributedRelayIntraPortalLinkList(cfg)
+	if len(dr.Ipplinks) != 2 ||
+		dr.Ipplinks[0] != origipp ||
+		dr.Ipplinks[1].Id != uint32(ipplink2) {
+		t.Error("ERROR Ipp was not added as expected", dr.Ipplinks)
+	}
+	if dr.Ipplinks[1].DRCPEnabled {
+		t.Error("ERROR Ipp enabled before an aggregator has been attached")
+	}
+
+	// remove the original link
+	cfg.DrniIntraPortalLinkList = [3]uint32{uint32(ipplink2)}
+	UpdateDistributedRelayIntraPortalLinkList(cfg)
+	if len(dr.Ipplinks) != 1 ||
+		dr.Ipplinks[0].Id != uint32(ipplink2) {
+		t.Error("ERROR Ipp was not deleted as expected", dr.Ipplinks)
+	}
+	key := IppDbKey{
+		Name:   origipp.Name,
+		DrName: dr.DrniName,
+	}
+	if _, ok := DRCPIppDB[key]; ok {
+		t.Error("ERROR deleted Ipp still found in the Ipp DB")
+	}
+
+	dr.DeleteDistributedRelay()
+	ConfigTestTeardwon(t)
+}
+
+func TestUpdateDistributedRelayConvAdminGateway(t *testing.T) {
+	ConversationIdTestSetup()
+	a := OnlyForConversationIdTestSetupCreateAggGroup(200)
+
+	cfg := UpdateTestDistributedRelayConfig()
+	cfg.DrniAggregator = uint32(a.AggId)
+	dr := NewDistributedRelay(cfg)
+	dr.a = a
+
+	for _, cid := range []int{100, 101} {
+		ConversationIdMap[cid].Valid = true
+		ConversationIdMap[cid].Cvlan = uint16(cid)
+		ConversationIdMap[cid].PortList = []int32{aggport1}
+	}
+	dr.SetTimeSharingPortAndGatwewayDigest()
+	origDigest := dr.DRFHomeConversationGatewayListDigest
+	if !SliceEqual(dr.DrniConvAdminGateway[100], []uint8{2, 1}) ||
+		!SliceEqual(dr.DrniConvAdminGateway[101], []uint8{1, 2}) {
+		t.Error("ERROR unexpected gateway list", dr.DrniConvAdminGateway[100], dr.DrniConvAdminGateway[101])
+	}
+
+	// only system 1 may be the gateway of conversation 100
+	cfg.DrniConvAdminGateway[100] = [3]uint8{1}
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+	UpdateDistributedRelayConvAdminGateway(cfg)
+	if !SliceEqual(dr.DrniConvAdminGateway[100], []uint8{1}) ||
+		!SliceEqual(dr.DrniConvAdminGateway[101], []uint8{1, 2}) {
+		t.Error("ERROR admin gateway list not applied", dr.DrniConvAdminGateway[100], dr.DrniConvAdminGateway[101])
+	}
+	if dr.DRFHomeConversationGatewayListDigest == origDigest {
+		t.Error("ERROR gateway digest did not change with the admin gateway list")
+	}
+
+	// remove the admin list the algorithm should assign the gateway again
+	cfg.DrniConvAdminGateway[100] = [3]uint8{}
+	UpdateDistributedRelayConvAdminGateway(cfg)
+	if !SliceEqual(dr.DrniConvAdminGateway[100], []uint8{2, 1}) {
+		t.Error("ERROR algorithm gateway list not restored", dr.DrniConvAdminGateway[100])
+	}
+	if dr.DRFHomeConversationGatewayListDigest != origDigest {
+		t.Error("ERROR gateway digest not restored")
+	}
+
+	// invalid lists
+	cfg.DrniConvAdminGateway[100] = [3]uint8{3}
+	if DistributedRelayConfigParamCheck(cfg) == nil {
+		t.Error("Parameter check passed for invalid 2P admin gateway portal system")
+	}
+	cfg.DrniConvAdminGateway[100] = [3]uint8{1, 1}
+	if DistributedRelayConfigParamCheck(cfg) == nil {
+		t.Error("Parameter check passed for duplicate admin gateway portal system")
+	}
+	cfg.DrniConvAdminGateway[100] = [3]uint8{0, 1}
+	if DistributedRelayConfigParamCheck(cfg) == nil {
+		t.Error("Parameter check passed for admin gateway list with gaps")
+	}
+
+	lacp.DeleteLaAgg(a.AggId)
+	dr.DeleteDistributedRelay()
+	ConversationIdTestTeardwon()
+}
+
+func TestUpdateDistributedRelayEncapMap(t *testing.T) {
+	ConfigTestSetup()
+	mock := &NetIplShareTestMock{
+		translations: make(map[uint16][2]uint32),
+	}
+	utils.DeleteAllAsicDPlugins()
+	utils.SetAsicDPlugin(mock)
+	a := OnlyForTestSetupCreateAggGroup(100)
+
+	cfg := UpdateTestDistributedRelayConfig()
+	cfg.DrniEncapMethod = "00:80:C2:02"
+	cfg.DrniIPLEncapMap = map[uint32]uint32{100: 1100, 101: 1101}
+	cfg.DrniNetEncapMap = map[uint32]uint32{100: 2100}
+
+	dr := NewDistributedRelay(cfg)
+	dr.a = a
+	ipp := dr.Ipplinks[0]
+
+	ipp.NetIplShareMachineMain()
+
+	responseChan := make(chan string)
+	ipp.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+		E:            NetIplSharemEventBegin,
+		Src:          "UPDATE TEST",
+		ResponseChan: responseChan,
+	}
+	<-responseChan
+	ipp.CCEncTagShared = true
+	ipp.NetIplShareMachineFsm.NetIplSharemEvents <- utils.MachineEvent{
+		E:            NetIplSharemEventCCEncTagShared,
+		Src:          "UPDATE TEST",
+		ResponseChan: responseChan,
+	}
+	<-responseChan
+	if len(mock.translations) != 2 {
+		t.Error("ERROR IPL encap translation not programmed as expected", mock.translations)
+	}
+
+	// add a vlan to the portal
+	cfg.DrniIPLEncapMap = map[uint32]uint32{100: 1100, 102: 1102}
+	cfg.DrniNetEncapMap = map[uint32]uint32{100: 2100, 102: 2102}
+	err := DistributedRelayConfigParamCheck(cfg)
+	if err != nil {
+		t.Error("Parameter check failed for what was expected to be a valid config", err)
+	}
+	UpdateDistributedRelayEncapMap(cfg)
+
+	if ipp.DRFHomeNetworkIPLIPLEncapDigest != (Md5Digest{}.calculateEncapDigest(cfg.DrniIPLEncapMap)) ||
+		ipp.DRFHomeNetworkIPLIPLNetEncapDigest != (Md5Digest{}.calculateEncapDigest(cfg.DrniNetEncapMap)) {
+		t.Error("ERROR Home IPL/Net encap digests not updated from the encap maps")
+	}
+	if ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState() != NetIplSharemStateManipulatedFramesSent ||
+		!ipp.EnabledEncTagShared {
+		t.Error("ERROR Net/IPL Sharing Machine not in expected state", NetIplSharemStateStrMap[ipp.NetIplShareMachineFsm.Machine.Curr.CurrentState()])
+	}
+	if len(mock.translations) != 2 ||
+		mock.translations[100] != [2]uint32{1100, 2100} ||
+		mock.translations[102] != [2]uint32{1102, 2102} {
+		t.Error("ERROR IPL encap translation not updated as expected", mock.translations)
+	}
+
+	dr.DeleteDistributedRelay()
+	lacp.DeleteLaAgg(a.AggId)
+	ConfigTestTeardwon(t)
+}
@@ -0,0 +1,306 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// aggkey.go - allocation of the Actor_Admin_Aggregator_Key for each configured
+// aggregator.  Keys are unique per system, may be supplied by the operator and
+// are saved off to a file so that an aggregator keeps its key across restarts.
+package lacp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"l2/lacp/protocol/utils"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	LaAggKeyMin uint16 = 1
+	// 802.1AX-2014 9.3.3.2 DRCP owns the two most significant bits of the
+	// operational aggregator key (DRF_Home_Oper_Aggregator_Key), thus the
+	// admin key must be contained within the lower 14 bits.  Selection
+	// logic items r) and s) depend on these bits being zero in the admin key
+	LaAggKeyMax uint16 = 0x3fff
+	// mask used to compare keys the same way DRCP compares them
+	LaAggKeyDrcpMask uint16 = 0x3fff
+)
+
+// LaAggKeyDbEntry is the persisted form of a key allocation
+type LaAggKeyDbEntry struct {
+	Name string
+	Key  uint16
+	// operator supplied key vs auto allocated key
+	Static bool
+}
+
+type laAggKeyDb struct {
+	sync.Mutex
+	// aggregator name -> allocation
+	nameMap map[string]LaAggKeyDbEntry
+	// key (DRCP masked) -> aggregator name
+	keyMap map[uint16]string
+	// file where the allocations are saved, empty means no persistence
+	fileName string
+}
+
+var gLaAggKeyDb = newLaAggKeyDb()
+
+func newLaAggKeyDb() *laAggKeyDb {
+	return &laAggKeyDb{
+		nameMap: make(map[string]LaAggKeyDbEntry),
+		keyMap:  make(map[uint16]string),
+	}
+}
+
+// LaAggKeyDbInit will set the file used to persist the key allocations and
+// restore any allocations previously saved in this file
+func LaAggKeyDbInit(fileName string) error {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+
+	db.nameMap = make(map[string]LaAggKeyDbEntry)
+	db.keyMap = make(map[uint16]string)
+	db.fileName = fileName
+	if fileName == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var entries []LaAggKeyDbEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, ent := range entries {
+		if laAggKeyIsValid(ent.Key) &&
+			ent.Name != "" {
+			if _, ok := db.keyMap[ent.Key&LaAggKeyDrcpMask]; ok {
+				if utils.GlobalLogger != nil {
+					utils.GlobalLogger.Err(fmt.Sprintf("Aggregator Key DB: ignoring duplicate key %d for %s", ent.Key, ent.Name))
+				}
+				continue
+			}
+			db.nameMap[ent.Name] = ent
+			db.keyMap[ent.Key&LaAggKeyDrcpMask] = ent.Name
+		}
+	}
+	return nil
+}
+
+// save will write the current allocations, caller must hold the lock
+func (db *laAggKeyDb) save() {
+	if db.fileName == "" {
+		return
+	}
+
+	entries := make([]LaAggKeyDbEntry, 0, len(db.nameMap))
+	for _, ent := range db.nameMap {
+		entries = append(entries, ent)
+	}
+	sort.Sort(laAggKeyDbEntryList(entries))
+
+	data, err := json.Marshal(entries)
+	if err == nil {
+		err = ioutil.WriteFile(db.fileName, data, 0644)
+	}
+	if err != nil &&
+		utils.GlobalLogger != nil {
+		utils.GlobalLogger.Err(fmt.Sprintln("Aggregator Key DB: unable to save key allocations", db.fileName, err))
+	}
+}
+
+type laAggKeyDbEntryList []LaAggKeyDbEntry
+
+func (l laAggKeyDbEntryList) Len() int           { return len(l) }
+func (l laAggKeyDbEntryList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l laAggKeyDbEntryList) Less(i, j int) bool { return l[i].Key < l[j].Key }
+
+func laAggKeyIsValid(key uint16) bool {
+	return key >= LaAggKeyMin && key <= LaAggKeyMax
+}
+
+// conflict will return the name of the aggregator which owns the key if it
+// is not the aggregator supplied, caller must hold the lock
+func (db *laAggKeyDb) conflict(name string, key uint16) string {
+	if owner, ok := db.keyMap[key&LaAggKeyDrcpMask]; ok && owner != name {
+		return owner
+	}
+	return ""
+}
+
+// check will validate an operator supplied key, caller must hold the lock
+func (db *laAggKeyDb) check(name string, key uint16) error {
+	if key == 0 {
+		return nil
+	}
+	if !laAggKeyIsValid(key) {
+		return errors.New(fmt.Sprintf("ERROR Invalid Aggregator Key %d must be between %d and %d", key, LaAggKeyMin, LaAggKeyMax))
+	}
+	if owner := db.conflict(name, key); owner != "" {
+		return errors.New(fmt.Sprintf("ERROR Aggregator Key %d already assigned to Aggregator %s", key, owner))
+	}
+	return nil
+}
+
+// LaAggKeyCheck will validate an operator supplied key against the range
+// allowed by DRCP and against the keys already allocated to other aggregators.
+// A key of zero means the key should be allocated by the system
+func LaAggKeyCheck(name string, key uint16) error {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+	return db.check(name, key)
+}
+
+// LaAggKeyAllocation records the allocation made for an aggregator so that
+// it can be undone if the aggregator create fails
+type LaAggKeyAllocation struct {
+	Name string
+	Key  uint16
+	// allocation which existed before this allocation
+	prev    LaAggKeyDbEntry
+	existed bool
+}
+
+// LaAggKeyAllocate will return the key assigned to the aggregator.  If the
+// requested key is zero, the previously allocated key is returned or a new key
+// is allocated, otherwise the requested key is assigned to the aggregator
+func LaAggKeyAllocate(name string, reqKey uint16) (uint16, error) {
+	alloc, err := LaAggKeyReserve(name, reqKey)
+	return alloc.Key, err
+}
+
+// LaAggKeyReserve will validate and allocate the key in one step, the
+// returned allocation may be used to restore the previous allocation
+func LaAggKeyReserve(name string, reqKey uint16) (LaAggKeyAllocation, error) {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+
+	alloc := LaAggKeyAllocation{
+		Name: name,
+	}
+	if err := db.check(name, reqKey); err != nil {
+		return alloc, err
+	}
+
+	ent, exists := db.nameMap[name]
+	alloc.prev = ent
+	alloc.existed = exists
+	if exists &&
+		(reqKey == 0 || reqKey == ent.Key) {
+		if reqKey != 0 && !ent.Static {
+			ent.Static = true
+			db.nameMap[name] = ent
+			db.save()
+		}
+		alloc.Key = ent.Key
+		return alloc, nil
+	}
+
+	key := reqKey
+	if key == 0 {
+		for k := LaAggKeyMin; k <= LaAggKeyMax; k++ {
+			if _, ok := db.keyMap[k]; !ok {
+				key = k
+				break
+			}
+		}
+		if key == 0 {
+			return alloc, errors.New(fmt.Sprintf("ERROR Unable to allocate Aggregator Key for %s, no keys available", name))
+		}
+	}
+
+	if exists {
+		delete(db.keyMap, ent.Key&LaAggKeyDrcpMask)
+	}
+	db.nameMap[name] = LaAggKeyDbEntry{
+		Name:   name,
+		Key:    key,
+		Static: reqKey != 0,
+	}
+	db.keyMap[key&LaAggKeyDrcpMask] = name
+	db.save()
+	alloc.Key = key
+	return alloc, nil
+}
+
+// Rollback will restore the allocation which existed before the key was
+// reserved.  Nothing is done if the aggregator allocation has since changed
+func (alloc LaAggKeyAllocation) Rollback() {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+
+	ent, ok := db.nameMap[alloc.Name]
+	if !ok ||
+		ent.Key != alloc.Key {
+		return
+	}
+	if alloc.existed &&
+		alloc.prev == ent {
+		return
+	}
+
+	delete(db.nameMap, alloc.Name)
+	delete(db.keyMap, ent.Key&LaAggKeyDrcpMask)
+	if alloc.existed {
+		// previous key may have been taken in the meantime
+		if db.conflict(alloc.Name, alloc.prev.Key) == "" {
+			db.nameMap[alloc.Name] = alloc.prev
+			db.keyMap[alloc.prev.Key&LaAggKeyDrcpMask] = alloc.Name
+		}
+	}
+	db.save()
+}
+
+// LaAggKeyGet will return the key allocated to the aggregator
+func LaAggKeyGet(name string) (uint16, bool) {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+	ent, ok := db.nameMap[name]
+	return ent.Key, ok
+}
+
+// LaAggKeyRelease will free the key allocated to the aggregator
+func LaAggKeyRelease(name string) {
+	db := gLaAggKeyDb
+	db.Lock()
+	defer db.Unlock()
+	if ent, ok := db.nameMap[name]; ok {
+		delete(db.nameMap, name)
+		delete(db.keyMap, ent.Key&LaAggKeyDrcpMask)
+		db.save()
+	}
+}
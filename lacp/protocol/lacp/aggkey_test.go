@@ -0,0 +1,189 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// aggkey_test.go
+package lacp
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLaAggKeyAllocateUnique(t *testing.T) {
+	LaAggKeyDbInit("")
+	defer LaAggKeyDbInit("")
+
+	seen := make(map[uint16]string)
+	for _, name := range []string{"agg1", "agg2", "agg3"} {
+		key, err := LaAggKeyAllocate(name, 0)
+		if err != nil {
+			t.Error("ERROR unexpected allocation failure", name, err)
+		}
+		if owner, ok := seen[key]; ok {
+			t.Error("ERROR key", key, "allocated to both", owner, "and", name)
+		}
+		seen[key] = name
+	}
+
+	// same aggregator should always get the same key
+	key1, _ := LaAggKeyGet("agg1")
+	key2, _ := LaAggKeyAllocate("agg1", 0)
+	if key1 != key2 {
+		t.Error("ERROR key changed on second allocation", key1, key2)
+	}
+
+	// freed key should be reused
+	LaAggKeyRelease("agg2")
+	if _, ok := LaAggKeyGet("agg2"); ok {
+		t.Error("ERROR key still allocated after release")
+	}
+	key, _ := LaAggKeyAllocate("agg4", 0)
+	if seen[key] != "agg2" {
+		t.Error("ERROR expected released key to be reused", key)
+	}
+}
+
+func TestLaAggKeyOperatorKey(t *testing.T) {
+	LaAggKeyDbInit("")
+	defer LaAggKeyDbInit("")
+
+	key, err := LaAggKeyAllocate("agg1", 100)
+	if err != nil || key != 100 {
+		t.Error("ERROR operator key not honored", key, err)
+	}
+
+	// conflict with another aggregator
+	if err = LaAggKeyCheck("agg2", 100); err == nil {
+		t.Error("ERROR expected conflict for key 100")
+	}
+	if _, err = LaAggKeyAllocate("agg2", 100); err == nil {
+		t.Error("ERROR expected allocation failure for key 100")
+	}
+
+	// keys using the DRCP owned bits are not allowed
+	if err = LaAggKeyCheck("agg2", 100|0x4000); err == nil {
+		t.Error("ERROR expected key outside of DRCP key space to fail")
+	}
+
+	// auto allocation must skip operator keys
+	LaAggKeyAllocate("agg3", 1)
+	key, _ = LaAggKeyAllocate("agg4", 0)
+	if key == 1 || key == 100 {
+		t.Error("ERROR auto allocated key collides with operator key", key)
+	}
+
+	// invalid range
+	for _, k := range []uint16{LaAggKeyMax + 1, 0xffff} {
+		if err = LaAggKeyCheck("agg5", k); err == nil {
+			t.Error("ERROR expected invalid key failure", k)
+		}
+	}
+}
+
+func TestLaAggKeyParamCheck(t *testing.T) {
+	LaAggKeyDbInit("")
+	defer LaAggKeyDbInit("")
+
+	LaAggKeyAllocate("agg1", 200)
+	ac := &LaAggConfig{
+		Name: "agg2",
+		Key:  200,
+		Type: LaAggTypeLACP,
+		Lacp: LacpConfigInfo{Interval: LacpSlowPeriodicTime,
+			Mode: LacpModeActive},
+	}
+	if err := LaAggConfigParamCheck(ac); err == nil {
+		t.Error("ERROR expected param check to fail on duplicate key")
+	}
+	ac.Key = 201
+	if err := LaAggConfigParamCheck(ac); err != nil {
+		t.Error("ERROR unexpected param check failure", err)
+	}
+}
+
+func TestLaAggKeyReserveRollback(t *testing.T) {
+	LaAggKeyDbInit("")
+	defer LaAggKeyDbInit("")
+
+	// newly allocated key is freed
+	alloc, err := LaAggKeyReserve("agg1", 0)
+	if err != nil {
+		t.Error("ERROR unexpected allocation failure", err)
+	}
+	alloc.Rollback()
+	if _, ok := LaAggKeyGet("agg1"); ok {
+		t.Error("ERROR key still allocated after rollback")
+	}
+
+	// previous key is restored
+	key1, _ := LaAggKeyAllocate("agg1", 0)
+	alloc, err = LaAggKeyReserve("agg1", 400)
+	if err != nil || alloc.Key != 400 {
+		t.Error("ERROR operator key not honored", alloc.Key, err)
+	}
+	alloc.Rollback()
+	if key, ok := LaAggKeyGet("agg1"); !ok || key != key1 {
+		t.Error("ERROR previous key not restored", key, key1)
+	}
+	if err = LaAggKeyCheck("agg2", 400); err != nil {
+		t.Error("ERROR key 400 should be free after rollback", err)
+	}
+
+	// existing allocation is left alone
+	alloc, _ = LaAggKeyReserve("agg1", 0)
+	alloc.Rollback()
+	if key, ok := LaAggKeyGet("agg1"); !ok || key != key1 {
+		t.Error("ERROR existing key removed by rollback", key, key1)
+	}
+}
+
+func TestLaAggKeyPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lacpkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "aggkeys.json")
+
+	LaAggKeyDbInit(fileName)
+	defer LaAggKeyDbInit("")
+	key1, _ := LaAggKeyAllocate("agg1", 0)
+	key2, _ := LaAggKeyAllocate("agg2", 300)
+
+	// simulate restart
+	LaAggKeyDbInit(fileName)
+	if key, ok := LaAggKeyGet("agg1"); !ok || key != key1 {
+		t.Error("ERROR agg1 key not restored", key, key1)
+	}
+	if key, ok := LaAggKeyGet("agg2"); !ok || key != key2 {
+		t.Error("ERROR agg2 key not restored", key, key2)
+	}
+
+	LaAggKeyRelease("agg1")
+	LaAggKeyDbInit(fileName)
+	if _, ok := LaAggKeyGet("agg1"); ok {
+		t.Error("ERROR agg1 key restored after release")
+	}
+}
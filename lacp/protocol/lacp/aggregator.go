@@ -219,6 +219,9 @@ type LaAggregator struct {
 
 	// Indication of whether lag has been created in H/W
 	PresentInHw bool
+
+	// RFC 7130 Micro BFD config applied to each member
+	MicroBfd MicroBfdConfig
 }
 
 func NewLaAggregator(ac *LaAggConfig) *LaAggregator {
@@ -247,6 +250,7 @@ func NewLaAggregator(ac *LaAggConfig) *LaAggregator {
 		DistributedPortNumList: make([]string, 0),
 		LagHash:                ac.HashMode,
 		DrniName:               "",
+		MicroBfd:               ac.MicroBfd,
 	}
 
 	// add port agg map and register port oper state events
@@ -0,0 +1,279 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// autorate.go - adaptive LACP rate.  When enabled the actor requests a
+// SHORT timeout from the partner while the port is negotiating, once the
+// port has been distributing for the configured stable time the actor will
+// request a LONG timeout.  Any churn moves the port back to SHORT timeout.
+package lacp
+
+import (
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"sync"
+	"time"
+)
+
+const AutoRateModuleStr = "Auto Rate"
+
+const (
+	LacpAutoRateDefaultStableTime = time.Second * 60
+)
+
+const (
+	LacpAutoRateStateDisabled = iota
+	LacpAutoRateStateFast
+	LacpAutoRateStateSlow
+)
+
+var LacpAutoRateStateStrMap = map[int]string{
+	LacpAutoRateStateDisabled: "",
+	LacpAutoRateStateFast:     "FAST",
+	LacpAutoRateStateSlow:     "SLOW",
+}
+
+// laAutoRatePortInfo holds the per port auto rate state
+type laAutoRatePortInfo struct {
+	sync.Mutex
+	enable      bool
+	stableTime  time.Duration
+	slow        bool
+	lastChange  time.Time
+	fastTime    time.Duration
+	slowTime    time.Duration
+	transitions uint64
+	stableTimer *time.Timer
+}
+
+// LacpAutoRateStats is a snapshot of the auto rate state of a port
+type LacpAutoRateStats struct {
+	State       string
+	FastTime    time.Duration
+	SlowTime    time.Duration
+	Transitions uint64
+}
+
+// laAggPortLacpTimeoutSet will set the actor timeout SHORT when fast is set
+// otherwise LONG.  The current while timer is restarted if it no longer
+// matches the timeout and the partner is notified of the change
+func (p *LaAggPort) laAggPortLacpTimeoutSet(fast bool, src string) {
+	rxm := p.RxMachineFsm
+
+	if fast {
+		LacpStateSet(&p.ActorAdmin.State, LacpStateTimeoutBit)
+		// must also set the operational State
+		LacpStateSet(&p.ActorOper.State, LacpStateTimeoutBit)
+	} else {
+		LacpStateClear(&p.ActorAdmin.State, LacpStateTimeoutBit)
+		// must also set the operational State
+		LacpStateClear(&p.ActorOper.State, LacpStateTimeoutBit)
+	}
+	if rxm != nil {
+		if timeoutTime, ok := rxm.CurrentWhileTimerValid(); !ok {
+			rxm.CurrentWhileTimerTimeoutSet(timeoutTime)
+			rxm.CurrentWhileTimerStart()
+		}
+	}
+	// state change lets update ntt
+	if p.TxMachineFsm != nil {
+		p.TxMachineFsm.TxmEvents <- utils.MachineEvent{
+			E:   LacpTxmEventNtt,
+			Src: src}
+	}
+}
+
+// laAutoRateTimeoutPost will have the rx machine, which owns the current
+// while timer, apply the auto rate timeout.  Must not be called with the
+// auto rate lock held
+func (p *LaAggPort) laAutoRateTimeoutPost() {
+	if p.RxMachineFsm != nil {
+		p.RxMachineFsm.RxmEvents <- utils.MachineEvent{
+			E:   LacpRxmEventAutoRateTimeout,
+			Src: AutoRateModuleStr}
+	} else {
+		p.LaAutoRateTimeoutApply()
+	}
+}
+
+// LaAutoRateTimeoutApply will set the actor timeout from the current auto
+// rate, called by the rx machine
+func (p *LaAggPort) LaAutoRateTimeoutApply() {
+	ar := &p.autoRate
+	ar.Lock()
+	enable, fast := ar.enable, !ar.slow
+	ar.Unlock()
+	if enable {
+		p.laAggPortLacpTimeoutSet(fast, AutoRateModuleStr)
+	}
+}
+
+// laAutoRateAccumulate will add the time spent in the current rate to the
+// stats, lock must be held by caller
+func (ar *laAutoRatePortInfo) laAutoRateAccumulate(now time.Time) {
+	if ar.slow {
+		ar.slowTime += now.Sub(ar.lastChange)
+	} else {
+		ar.fastTime += now.Sub(ar.lastChange)
+	}
+	ar.lastChange = now
+}
+
+// LaAutoRateEnable will start auto rate on the port.  The port always starts
+// at the fast rate, if the port is already distributing the stable timer
+// is started
+func (p *LaAggPort) LaAutoRateEnable(stableTime time.Duration) {
+	if stableTime == 0 {
+		stableTime = LacpAutoRateDefaultStableTime
+	}
+	ar := &p.autoRate
+	ar.Lock()
+	if ar.enable {
+		ar.stableTime = stableTime
+		ar.Unlock()
+		return
+	}
+	ar.enable = true
+	ar.stableTime = stableTime
+	ar.slow = false
+	ar.lastChange = time.Now()
+	if LacpStateIsSet(p.ActorOper.State, LacpStateDistributingBit) {
+		ar.stableTimer = time.AfterFunc(ar.stableTime, p.laAutoRateStableTimerExpired)
+	}
+	ar.Unlock()
+
+	p.LaPortLog(fmt.Sprintf("%s: enabled stable time %s", AutoRateModuleStr, stableTime))
+	p.laAutoRateTimeoutPost()
+}
+
+// LaAutoRateDisable will stop auto rate on the port, the current rate is left
+// as is and is expected to be set by the caller
+func (p *LaAggPort) LaAutoRateDisable() {
+	ar := &p.autoRate
+	ar.Lock()
+	defer ar.Unlock()
+	if !ar.enable {
+		return
+	}
+	if ar.stableTimer != nil {
+		ar.stableTimer.Stop()
+		ar.stableTimer = nil
+	}
+	ar.laAutoRateAccumulate(time.Now())
+	ar.enable = false
+	ar.slow = false
+	p.LaPortLog(fmt.Sprintf("%s: disabled", AutoRateModuleStr))
+}
+
+// LaAutoRateStableStart is called when the port has entered distributing,
+// if the port remains distributing for the stable time the rate will move
+// to slow
+func (p *LaAggPort) LaAutoRateStableStart() {
+	ar := &p.autoRate
+	ar.Lock()
+	defer ar.Unlock()
+	if !ar.enable || ar.slow {
+		return
+	}
+	if ar.stableTimer != nil {
+		ar.stableTimer.Stop()
+	}
+	ar.stableTimer = time.AfterFunc(ar.stableTime, p.laAutoRateStableTimerExpired)
+}
+
+// laAutoRateStableTimerExpired runs on the timer goroutine, it will move
+// the port to the slow rate if the port is still distributing.  The
+// timeout change is posted to the rx machine
+func (p *LaAggPort) laAutoRateStableTimerExpired() {
+	ar := &p.autoRate
+	ar.Lock()
+	if !ar.enable || ar.slow || ar.stableTimer == nil ||
+		!LacpStateIsSet(p.ActorOper.State, LacpStateDistributingBit) {
+		ar.Unlock()
+		return
+	}
+	ar.stableTimer = nil
+	ar.laAutoRateAccumulate(time.Now())
+	ar.slow = true
+	ar.transitions++
+	ar.Unlock()
+
+	p.LaPortLog(fmt.Sprintf("%s: port stable moving to SLOW rate", AutoRateModuleStr))
+	p.laAutoRateTimeoutPost()
+}
+
+// laAutoRateChurn moves the auto rate back to fast, returns true when the
+// actor timeout needs to change
+func (p *LaAggPort) laAutoRateChurn() bool {
+	ar := &p.autoRate
+	ar.Lock()
+	defer ar.Unlock()
+	if !ar.enable {
+		return false
+	}
+	if ar.stableTimer != nil {
+		ar.stableTimer.Stop()
+		ar.stableTimer = nil
+	}
+	if !ar.slow {
+		return false
+	}
+	ar.laAutoRateAccumulate(time.Now())
+	ar.slow = false
+	ar.transitions++
+	return true
+}
+
+// LaAutoRateChurn is called when the port has left distributing or churn
+// has been detected, the port will move back to the fast rate.  The
+// timeout change is posted to the rx machine
+func (p *LaAggPort) LaAutoRateChurn() {
+	if p.laAutoRateChurn() {
+		p.LaPortLog(fmt.Sprintf("%s: churn detected moving to FAST rate", AutoRateModuleStr))
+		p.laAutoRateTimeoutPost()
+	}
+}
+
+// LaAutoRateStatsGet returns the auto rate state and the time spent in
+// each rate
+func (p *LaAggPort) LaAutoRateStatsGet() LacpAutoRateStats {
+	ar := &p.autoRate
+	ar.Lock()
+	defer ar.Unlock()
+	stats := LacpAutoRateStats{
+		State:       LacpAutoRateStateStrMap[LacpAutoRateStateDisabled],
+		FastTime:    ar.fastTime,
+		SlowTime:    ar.slowTime,
+		Transitions: ar.transitions,
+	}
+	if ar.enable {
+		// include the time spent in the current rate
+		if ar.slow {
+			stats.State = LacpAutoRateStateStrMap[LacpAutoRateStateSlow]
+			stats.SlowTime += time.Since(ar.lastChange)
+		} else {
+			stats.State = LacpAutoRateStateStrMap[LacpAutoRateStateFast]
+			stats.FastTime += time.Since(ar.lastChange)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,156 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// autorate_test.go
+package lacp
+
+import (
+	"testing"
+	"time"
+)
+
+func AutoRateTestWaitForState(p *LaAggPort, state int) bool {
+	for i := 0; i < 50; i++ {
+		if p.LaAutoRateStatsGet().State == LacpAutoRateStateStrMap[state] {
+			return true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return false
+}
+
+func TestLaAutoRateStableAndChurn(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown()
+
+	p := &LaAggPort{PortNum: 10, IntfNum: "SIMeth0"}
+	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
+
+	p.LaAutoRateEnable(time.Millisecond * 50)
+	defer p.LaAutoRateDisable()
+	if !LacpStateIsSet(p.ActorAdmin.State, LacpStateTimeoutBit) ||
+		!LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR auto rate did not start at SHORT timeout")
+	}
+
+	if !AutoRateTestWaitForState(p, LacpAutoRateStateSlow) {
+		t.Error("ERROR auto rate did not move to SLOW once stable", p.LaAutoRateStatsGet())
+	}
+	// without an rx machine the timeout is applied by the timer
+	// goroutine once the rate has changed
+	time.Sleep(time.Millisecond * 20)
+	if LacpStateIsSet(p.ActorAdmin.State, LacpStateTimeoutBit) ||
+		LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR auto rate SLOW did not set LONG timeout")
+	}
+
+	p.LaAutoRateChurn()
+	stats := p.LaAutoRateStatsGet()
+	if stats.State != LacpAutoRateStateStrMap[LacpAutoRateStateFast] {
+		t.Error("ERROR auto rate did not move to FAST on churn", stats)
+	}
+	if !LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR auto rate FAST did not set SHORT timeout")
+	}
+	if stats.Transitions != 2 {
+		t.Error("ERROR unexpected auto rate transitions", stats.Transitions)
+	}
+	if stats.FastTime == 0 || stats.SlowTime == 0 {
+		t.Error("ERROR time in each rate not accounted", stats)
+	}
+}
+
+func TestLaAutoRateNotDistributing(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown()
+
+	p := &LaAggPort{PortNum: 10, IntfNum: "SIMeth0"}
+	p.LaAutoRateEnable(time.Millisecond * 20)
+
+	// port left distributing before the stable time expired
+	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
+	p.LaAutoRateStableStart()
+	p.LaAutoRateChurn()
+	LacpStateClear(&p.ActorOper.State, LacpStateDistributingBit)
+	time.Sleep(time.Millisecond * 60)
+
+	stats := p.LaAutoRateStatsGet()
+	if stats.State != LacpAutoRateStateStrMap[LacpAutoRateStateFast] ||
+		stats.Transitions != 0 {
+		t.Error("ERROR auto rate moved to SLOW while port not stable", stats)
+	}
+
+	p.LaAutoRateDisable()
+	if p.LaAutoRateStatsGet().State != LacpAutoRateStateStrMap[LacpAutoRateStateDisabled] {
+		t.Error("ERROR auto rate state not cleared on disable")
+	}
+}
+
+func TestLaAutoRatePostedToRxMachine(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown()
+
+	p := &LaAggPort{PortNum: 10, IntfNum: "SIMeth0"}
+	// rx machine is built but not started, events are consumed here
+	rxm := LacpRxMachineFSMBuild(p)
+	rxm.CurrentWhileTimerTimeoutSet(LacpLongTimeoutTime)
+	defer rxm.CurrentWhileTimerStop()
+	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
+
+	p.LaAutoRateEnable(time.Millisecond * 20)
+	defer p.LaAutoRateDisable()
+	select {
+	case event := <-p.RxMachineFsm.RxmEvents:
+		if event.E != LacpRxmEventAutoRateTimeout {
+			t.Error("ERROR unexpected rx machine event", event.E)
+		}
+	case <-time.After(time.Second):
+		t.Error("ERROR auto rate enable not posted to rx machine")
+	}
+	p.LaAutoRateTimeoutApply()
+	if !LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR auto rate did not start at SHORT timeout")
+	}
+
+	// stable timer expiry must not touch the actor state itself
+	select {
+	case event := <-p.RxMachineFsm.RxmEvents:
+		if event.E != LacpRxmEventAutoRateTimeout {
+			t.Error("ERROR unexpected rx machine event", event.E)
+		}
+	case <-time.After(time.Second):
+		t.Error("ERROR auto rate SLOW not posted to rx machine")
+	}
+	if !LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR actor timeout changed off the rx machine")
+	}
+	p.LaAutoRateTimeoutApply()
+	if LacpStateIsSet(p.ActorOper.State, LacpStateTimeoutBit) {
+		t.Error("ERROR auto rate SLOW did not set LONG timeout")
+	}
+
+	p.LaAutoRateChurn()
+	if len(p.RxMachineFsm.RxmEvents) != 1 {
+		t.Error("ERROR auto rate churn not posted to rx machine")
+	}
+}
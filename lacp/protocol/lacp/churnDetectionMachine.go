@@ -195,6 +195,8 @@ func (cdm *LacpActorCdMachine) LacpCdMachineActorChurn(m fsm.Machine, data inter
 	}
 
 	cdm.ChurnDetectionTimerStop()
+	p.LaErrDisableCheckChurn()
+	p.LaAutoRateChurn()
 	return LacpCdmStateActorChurn
 }
 
@@ -238,6 +240,8 @@ func (cdm *LacpPartnerCdMachine) LacpCdMachinePartnerChurn(m fsm.Machine, data i
 	}
 
 	cdm.ChurnDetectionTimerStop()
+	p.LaErrDisableCheckChurn()
+	p.LaAutoRateChurn()
 	return LacpCdmStatePartnerChurn
 }
 
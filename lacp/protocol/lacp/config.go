@@ -78,6 +78,9 @@ type LacpConfigInfo struct {
 	// In format AA:BB:CC:DD:EE:FF
 	SystemIdMac    string
 	SystemPriority uint16
+	// adaptive rate, Interval is ignored when set
+	AutoRate           bool
+	AutoRateStableTime time.Duration
 }
 
 type LaAggConfig struct {
@@ -106,6 +109,9 @@ type LaAggConfig struct {
 
 	// hash config
 	HashMode uint32
+
+	// RFC 7130 Micro BFD on each member
+	MicroBfd MicroBfdConfig
 }
 
 type AggPortConfig struct {
@@ -296,7 +302,7 @@ func LaAggConfigParamCheck(ac *LaAggConfig) error {
 	}
 	if ac.Lacp.Interval != LacpSlowPeriodicTime &&
 		ac.Lacp.Interval != LacpFastPeriodicTime {
-		return errors.New("ERROR Invalid Interval Configured Should be SLOW(1) or FAST(0)")
+		return errors.New("ERROR Invalid Interval Configured Should be SLOW(1), FAST(0) or AUTO(2)")
 	}
 	if ac.Lacp.Mode != LacpModeActive &&
 		ac.Lacp.Mode != LacpModePassive {
@@ -309,6 +315,19 @@ func LaAggConfigParamCheck(ac *LaAggConfig) error {
 		return errors.New("ERROR Invalid LACP Mode Configured Should be LAYER2(0) or LAYER3_4(2) or LAYER2_3(1)")
 	}
 
+	// operator supplied key must be unique and within the DRCP key space
+	if err := LaAggKeyCheck(ac.Name, ac.Key); err != nil {
+		return err
+	}
+
+	if ac.MicroBfd.Enable {
+		for _, addr := range []string{ac.MicroBfd.LocalIp, ac.MicroBfd.PeerIp} {
+			if ip := net.ParseIP(addr); ip == nil || ip.To4() == nil {
+				return errors.New(fmt.Sprintf("ERROR Invalid Micro BFD IPv4 address %s", addr))
+			}
+		}
+	}
+
 	// lets make sure the port associated with the lag are not associated with another lag
 	for _, ifindex := range ac.LagMembers {
 		var p *LaAggPort
@@ -620,27 +639,10 @@ func SetLaAggPortLacpPeriod(pId uint16, period time.Duration) {
 	// port is unselected
 	// agg exists
 	if LaFindPortById(pId, &p) {
-		rxm := p.RxMachineFsm
 		p.LaPortLog(fmt.Sprintf("NewPeriod", period))
 
 		// lets set the period
-		if period == LacpFastPeriodicTime {
-			LacpStateSet(&p.ActorAdmin.State, LacpStateTimeoutBit)
-			// must also set the operational State
-			LacpStateSet(&p.ActorOper.State, LacpStateTimeoutBit)
-		} else {
-			LacpStateClear(&p.ActorAdmin.State, LacpStateTimeoutBit)
-			// must also set the operational State
-			LacpStateClear(&p.ActorOper.State, LacpStateTimeoutBit)
-		}
-		if timeoutTime, ok := rxm.CurrentWhileTimerValid(); !ok {
-			rxm.CurrentWhileTimerTimeoutSet(timeoutTime)
-			rxm.CurrentWhileTimerStart()
-		}
-		// state change lets update ntt
-		p.TxMachineFsm.TxmEvents <- utils.MachineEvent{
-			E:   LacpTxmEventNtt,
-			Src: PortConfigModuleStr}
+		p.laAggPortLacpTimeoutSet(period == LacpFastPeriodicTime, PortConfigModuleStr)
 	}
 }
 
@@ -753,6 +755,51 @@ func SetLaAggHashMode(aggId int, hashmode uint32) {
 	}
 }
 
+// SetLaAggLacpAutoRate will enable or disable the adaptive lacp rate on all
+// members of the aggregator
+func SetLaAggLacpAutoRate(aggId int, enable bool, stableTime time.Duration) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		a.Config.AutoRate = enable
+		a.Config.AutoRateStableTime = stableTime
+		for _, pId := range a.PortNumList {
+			var p *LaAggPort
+			if LaFindPortById(pId, &p) {
+				if enable {
+					p.LaAutoRateEnable(stableTime)
+				} else {
+					p.LaAutoRateDisable()
+				}
+			}
+		}
+	} else {
+		fmt.Println("SetLaAggLacpAutoRate: Unable to find aggId", aggId)
+	}
+}
+
+// SetLaAggMicroBfd will restart the micro bfd sessions of all members
+// if the config has changed
+func SetLaAggMicroBfd(aggId int, cfg MicroBfdConfig) {
+	var a *LaAggregator
+	if LaFindAggById(aggId, &a) {
+		if a.MicroBfd == cfg {
+			return
+		}
+		a.MicroBfd = cfg
+		for _, pId := range a.PortNumList {
+			var p *LaAggPort
+			if LaFindPortById(pId, &p) {
+				p.MicroBfdSessionDelete()
+				if cfg.Enable {
+					p.MicroBfdSessionCreate(cfg)
+				}
+			}
+		}
+	} else {
+		fmt.Println("SetLaAggMicroBfd: Unable to find aggId", aggId)
+	}
+}
+
 func AddLaAggPortToAgg(Key uint16, pId uint16) {
 
 	var a *LaAggregator
@@ -784,6 +831,16 @@ func AddLaAggPortToAgg(Key uint16, pId uint16) {
 		if p.IsPortEnabled() {
 			p.CreateRxTx()
 		}
+
+		// start the micro bfd session for this member
+		if a.MicroBfd.Enable {
+			p.MicroBfdSessionCreate(a.MicroBfd)
+		}
+
+		// adaptive rate for this member
+		if a.Config.AutoRate {
+			p.LaAutoRateEnable(a.Config.AutoRateStableTime)
+		}
 		// attach the port to the aggregator
 		//LacpStateSet(&p.ActorAdmin.State, LacpStateAggregationBit)
 
@@ -811,6 +868,10 @@ func DeleteLaAggPortFromAgg(Key uint16, pId uint16) {
 		// disable the port
 		p.LaAggPortDisable()
 
+		// stop the micro bfd session for this member
+		p.MicroBfdSessionDelete()
+		p.LaAutoRateDisable()
+
 		// update selection to be unselected
 		p.checkConfigForSelection()
 
@@ -0,0 +1,336 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// errdisable.go - err-disable handling for misbehaving Aggregation Ports.
+// When a trigger condition is detected the port is brought admin down
+// via asicd and brought back up after the configured recovery interval.
+package lacp
+
+import (
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const ErrDisableModuleStr = "Err Disable"
+
+const (
+	LaErrDisableReasonNone = iota
+	LaErrDisableReasonChurn
+	LaErrDisableReasonLoopback
+	LaErrDisableReasonPartnerMismatch
+	LaErrDisableReasonPduStorm
+)
+
+var LaErrDisableReasonStrMap = map[int]string{
+	LaErrDisableReasonNone:            "",
+	LaErrDisableReasonChurn:           "LACP Churn",
+	LaErrDisableReasonLoopback:        "LACP Loopback",
+	LaErrDisableReasonPartnerMismatch: "LACP Partner Mismatch",
+	LaErrDisableReasonPduStorm:        "LACPDU Storm",
+}
+
+const (
+	LaErrDisableDefaultChurnCount           = 5
+	LaErrDisableDefaultChurnWindow          = time.Second * 60
+	LaErrDisableDefaultPartnerMismatchCount = 3
+	LaErrDisableDefaultPduStormRate         = 50
+	LaErrDisableDefaultRecoveryInterval     = time.Second * 300
+)
+
+// LaErrDisableConfig holds the system wide err-disable triggers
+type LaErrDisableConfig struct {
+	// churn detections within the window
+	ChurnEnable bool
+	ChurnCount  int
+	ChurnWindow time.Duration
+	// LACPDU received from our own system
+	LoopbackEnable bool
+	// partner info differs from other distributing members of the aggregator
+	// for the given number of consecutive LACPDUs
+	PartnerMismatchEnable bool
+	PartnerMismatchCount  int
+	// LACPDUs received per second
+	PduStormEnable bool
+	PduStormRate   int
+	// time before the port is brought back up
+	RecoveryInterval time.Duration
+}
+
+// laErrDisablePortInfo holds the per port err-disable state
+type laErrDisablePortInfo struct {
+	sync.Mutex
+	reason        int
+	disabledTime  time.Time
+	churnTimes    []time.Time
+	mismatchCnt   int
+	pduWindow     time.Time
+	pduCnt        int
+	recoveryTimer *time.Timer
+}
+
+var gLaErrDisableCfgMutex sync.RWMutex
+var gLaErrDisableCfg = LaErrDisableConfig{
+	ChurnCount:           LaErrDisableDefaultChurnCount,
+	ChurnWindow:          LaErrDisableDefaultChurnWindow,
+	PartnerMismatchCount: LaErrDisableDefaultPartnerMismatchCount,
+	PduStormRate:         LaErrDisableDefaultPduStormRate,
+	RecoveryInterval:     LaErrDisableDefaultRecoveryInterval,
+}
+
+// LaErrDisableConfigSet will update the err-disable triggers, values
+// which are zero will be set to the default value
+func LaErrDisableConfigSet(cfg LaErrDisableConfig) {
+	if cfg.ChurnCount == 0 {
+		cfg.ChurnCount = LaErrDisableDefaultChurnCount
+	}
+	if cfg.ChurnWindow == 0 {
+		cfg.ChurnWindow = LaErrDisableDefaultChurnWindow
+	}
+	if cfg.PartnerMismatchCount == 0 {
+		cfg.PartnerMismatchCount = LaErrDisableDefaultPartnerMismatchCount
+	}
+	if cfg.PduStormRate == 0 {
+		cfg.PduStormRate = LaErrDisableDefaultPduStormRate
+	}
+	// there is no way to clear an err-disabled port other than recovery
+	if cfg.RecoveryInterval == 0 {
+		cfg.RecoveryInterval = LaErrDisableDefaultRecoveryInterval
+	}
+	gLaErrDisableCfgMutex.Lock()
+	gLaErrDisableCfg = cfg
+	gLaErrDisableCfgMutex.Unlock()
+}
+
+// LaErrDisableConfigGet will return the current err-disable triggers
+func LaErrDisableConfigGet() LaErrDisableConfig {
+	gLaErrDisableCfgMutex.RLock()
+	defer gLaErrDisableCfgMutex.RUnlock()
+	return gLaErrDisableCfg
+}
+
+// ErrDisableReason returns the reason the port was err-disabled,
+// empty string if the port is not err-disabled
+func (p *LaAggPort) ErrDisableReason() string {
+	p.errDisable.Lock()
+	defer p.errDisable.Unlock()
+	return LaErrDisableReasonStrMap[p.errDisable.reason]
+}
+
+// IsErrDisabled returns true if the port has been err-disabled
+func (p *LaAggPort) IsErrDisabled() bool {
+	p.errDisable.Lock()
+	defer p.errDisable.Unlock()
+	return p.errDisable.reason != LaErrDisableReasonNone
+}
+
+// LaErrDisableCheckChurn is called each time actor or partner churn
+// is detected on the port
+func (p *LaAggPort) LaErrDisableCheckChurn() {
+	cfg := LaErrDisableConfigGet()
+	if !cfg.ChurnEnable {
+		return
+	}
+
+	now := time.Now()
+	p.errDisable.Lock()
+	// only keep the churn events within the window
+	times := make([]time.Time, 0, len(p.errDisable.churnTimes)+1)
+	for _, t := range p.errDisable.churnTimes {
+		if now.Sub(t) < cfg.ChurnWindow {
+			times = append(times, t)
+		}
+	}
+	times = append(times, now)
+	p.errDisable.churnTimes = times
+	exceeded := len(times) >= cfg.ChurnCount
+	p.errDisable.Unlock()
+
+	if exceeded {
+		p.LaErrDisable(LaErrDisableReasonChurn)
+	}
+}
+
+// LaErrDisableCheckPduRx is called for every LACPDU received on the port,
+// it is responsible for detecting LACPDU storms
+func (p *LaAggPort) LaErrDisableCheckPduRx() {
+	cfg := LaErrDisableConfigGet()
+	if !cfg.PduStormEnable {
+		return
+	}
+
+	now := time.Now()
+	p.errDisable.Lock()
+	if now.Sub(p.errDisable.pduWindow) >= time.Second {
+		p.errDisable.pduWindow = now
+		p.errDisable.pduCnt = 0
+	}
+	p.errDisable.pduCnt++
+	exceeded := p.errDisable.pduCnt > cfg.PduStormRate
+	p.errDisable.Unlock()
+
+	if exceeded {
+		p.LaErrDisable(LaErrDisableReasonPduStorm)
+	}
+}
+
+// LaErrDisableCheckPartner is called once the partner info from a
+// received LACPDU has been recorded
+func (p *LaAggPort) LaErrDisableCheckPartner() {
+	cfg := LaErrDisableConfigGet()
+
+	// 802.1ax-2014 6.4.14.1 a port which receives its own LACPDU
+	// is looped back to our own system, two ports of our own system
+	// cabled together will differ in port and key
+	if cfg.LoopbackEnable &&
+		reflect.DeepEqual(p.PartnerOper.System.Actor_System, p.ActorOper.System.Actor_System) &&
+		p.PartnerOper.port == p.ActorOper.port &&
+		p.PartnerOper.Key == p.ActorOper.Key {
+		p.LaErrDisable(LaErrDisableReasonLoopback)
+		return
+	}
+
+	if !cfg.PartnerMismatchEnable {
+		return
+	}
+
+	mismatch := p.laErrDisablePartnerMismatch()
+	p.errDisable.Lock()
+	if mismatch {
+		p.errDisable.mismatchCnt++
+	} else {
+		p.errDisable.mismatchCnt = 0
+	}
+	exceeded := p.errDisable.mismatchCnt >= cfg.PartnerMismatchCount
+	p.errDisable.Unlock()
+
+	if exceeded {
+		p.LaErrDisable(LaErrDisableReasonPartnerMismatch)
+	}
+}
+
+// laErrDisablePartnerMismatch will check the partner info of this port
+// against the distributing members of the same aggregator
+func (p *LaAggPort) laErrDisablePartnerMismatch() bool {
+	var a *LaAggregator
+	if !LaFindAggByKey(p.Key, &a) {
+		return false
+	}
+
+	distributing := make(map[string]bool)
+	for _, name := range a.DistributedPortNumList {
+		distributing[name] = true
+	}
+
+	for _, pId := range a.PortNumList {
+		var sp *LaAggPort
+		if pId != p.PortNum &&
+			LaFindPortById(pId, &sp) &&
+			distributing[sp.IntfNum] {
+			if !reflect.DeepEqual(sp.PartnerOper.System, p.PartnerOper.System) ||
+				sp.PartnerOper.Key != p.PartnerOper.Key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LaErrDisable will bring the port admin down and start the recovery timer
+func (p *LaAggPort) LaErrDisable(reason int) {
+	cfg := LaErrDisableConfigGet()
+
+	p.errDisable.Lock()
+	if p.errDisable.reason != LaErrDisableReasonNone {
+		p.errDisable.Unlock()
+		return
+	}
+	p.errDisable.reason = reason
+	p.errDisable.disabledTime = time.Now()
+	p.errDisable.churnTimes = nil
+	p.errDisable.mismatchCnt = 0
+	p.errDisable.pduCnt = 0
+	if cfg.RecoveryInterval != 0 {
+		p.errDisable.recoveryTimer = time.AfterFunc(cfg.RecoveryInterval, p.laErrDisableRecoveryTimerExpired)
+	}
+	p.errDisable.Unlock()
+
+	reasonStr := LaErrDisableReasonStrMap[reason]
+	p.LaPortLog(fmt.Sprintf("Port %s err-disabled reason %s", p.IntfNum, reasonStr))
+	for _, client := range utils.GetAsicDPluginList() {
+		err := client.ErrorDisablePort(int32(p.PortNum), "DOWN", reasonStr)
+		if err != nil {
+			utils.GlobalLogger.Err(fmt.Sprintf("Unable to err-disable port %s: %s", p.IntfNum, err))
+		}
+	}
+	utils.ProcessLacpPortErrDisabled(int32(p.PortNum))
+}
+
+// laErrDisableRecoveryTimerExpired runs on the timer goroutine, recovery is
+// posted to the rx machine which owns the port err-disable triggers
+func (p *LaAggPort) laErrDisableRecoveryTimerExpired() {
+	p.errDisable.Lock()
+	pending := p.errDisable.recoveryTimer != nil
+	p.errDisable.Unlock()
+	if !pending {
+		return
+	}
+	if p.RxMachineFsm != nil {
+		// never block the timer goroutine on a busy rx machine
+		select {
+		case p.RxMachineFsm.RxmErrDisableRecoverEvent <- true:
+		default:
+			// recovery already pending
+		}
+	} else {
+		p.LaErrDisableRecover()
+	}
+}
+
+// LaErrDisableRecover will bring the port back up, called by the rx machine
+// when the recovery timer expires
+func (p *LaAggPort) LaErrDisableRecover() {
+	p.errDisable.Lock()
+	if p.errDisable.reason == LaErrDisableReasonNone {
+		p.errDisable.Unlock()
+		return
+	}
+	reasonStr := LaErrDisableReasonStrMap[p.errDisable.reason]
+	p.errDisable.reason = LaErrDisableReasonNone
+	if p.errDisable.recoveryTimer != nil {
+		p.errDisable.recoveryTimer.Stop()
+		p.errDisable.recoveryTimer = nil
+	}
+	p.errDisable.Unlock()
+
+	p.LaPortLog(fmt.Sprintf("Port %s recovering from err-disable reason %s", p.IntfNum, reasonStr))
+	for _, client := range utils.GetAsicDPluginList() {
+		err := client.ErrorDisablePort(int32(p.PortNum), "UP", "")
+		if err != nil {
+			utils.GlobalLogger.Err(fmt.Sprintf("Unable to recover err-disabled port %s: %s", p.IntfNum, err))
+		}
+	}
+	utils.ProcessLacpPortErrDisableRecovered(int32(p.PortNum))
+}
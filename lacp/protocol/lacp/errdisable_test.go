@@ -0,0 +1,223 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// errdisable_test.go
+package lacp
+
+import (
+	"l2/lacp/protocol/utils"
+	"testing"
+	"time"
+	asicdmock "utils/asicdClient/mock"
+)
+
+type ErrDisableMockAsicdClientMgr struct {
+	asicdmock.MockAsicdClientMgr
+	adminState map[int32]string
+}
+
+func (m *ErrDisableMockAsicdClientMgr) ErrorDisablePort(ifIndex int32, adminState string, reason string) error {
+	m.adminState[ifIndex] = adminState
+	return nil
+}
+
+func ErrDisableTestSetup() *ErrDisableMockAsicdClientMgr {
+	OnlyForTestSetup()
+	utils.DeleteAllAsicDPlugins()
+	mock := &ErrDisableMockAsicdClientMgr{
+		adminState: make(map[int32]string),
+	}
+	utils.SetAsicDPlugin(mock)
+	return mock
+}
+
+func ErrDisableTestTeardown() {
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		RecoveryInterval: LaErrDisableDefaultRecoveryInterval,
+	})
+	OnlyForTestTeardown()
+}
+
+func TestLaErrDisableChurn(t *testing.T) {
+	mock := ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		ChurnEnable: true,
+		ChurnCount:  3,
+		ChurnWindow: time.Second * 10,
+	})
+
+	p := &LaAggPort{PortNum: 10, IntfNum: "SIMeth0"}
+	p.LaErrDisableCheckChurn()
+	p.LaErrDisableCheckChurn()
+	if p.IsErrDisabled() {
+		t.Error("ERROR port err-disabled before churn count reached")
+	}
+	p.LaErrDisableCheckChurn()
+	if !p.IsErrDisabled() {
+		t.Error("ERROR port not err-disabled after churn count reached")
+	}
+	if p.ErrDisableReason() != LaErrDisableReasonStrMap[LaErrDisableReasonChurn] {
+		t.Error("ERROR wrong err-disable reason", p.ErrDisableReason())
+	}
+	if mock.adminState[10] != "DOWN" {
+		t.Error("ERROR port was not brought down in hw", mock.adminState)
+	}
+
+	p.LaErrDisableRecover()
+	if p.IsErrDisabled() || p.ErrDisableReason() != "" {
+		t.Error("ERROR port still err-disabled after recovery")
+	}
+	if mock.adminState[10] != "UP" {
+		t.Error("ERROR port was not brought up in hw", mock.adminState)
+	}
+}
+
+func TestLaErrDisableDisabledTrigger(t *testing.T) {
+	mock := ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		ChurnCount: 1,
+	})
+
+	p := &LaAggPort{PortNum: 10, IntfNum: "SIMeth0"}
+	p.LaErrDisableCheckChurn()
+	p.LaErrDisableCheckPduRx()
+	if p.IsErrDisabled() {
+		t.Error("ERROR port err-disabled when triggers are not enabled")
+	}
+	if _, ok := mock.adminState[10]; ok {
+		t.Error("ERROR hw was called when triggers are not enabled")
+	}
+}
+
+// an unset value must not leave an err-disabled port down forever
+func TestLaErrDisableConfigDefaults(t *testing.T) {
+	ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{})
+	cfg := LaErrDisableConfigGet()
+	if cfg.ChurnCount != LaErrDisableDefaultChurnCount ||
+		cfg.ChurnWindow != LaErrDisableDefaultChurnWindow ||
+		cfg.PartnerMismatchCount != LaErrDisableDefaultPartnerMismatchCount ||
+		cfg.PduStormRate != LaErrDisableDefaultPduStormRate ||
+		cfg.RecoveryInterval != LaErrDisableDefaultRecoveryInterval {
+		t.Error("ERROR err-disable config not defaulted", cfg)
+	}
+}
+
+func TestLaErrDisablePduStormAutoRecovery(t *testing.T) {
+	mock := ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		PduStormEnable:   true,
+		PduStormRate:     5,
+		RecoveryInterval: time.Millisecond * 100,
+	})
+
+	p := &LaAggPort{PortNum: 11, IntfNum: "SIMeth1"}
+	for i := 0; i < 6; i++ {
+		p.LaErrDisableCheckPduRx()
+	}
+	if p.ErrDisableReason() != LaErrDisableReasonStrMap[LaErrDisableReasonPduStorm] {
+		t.Error("ERROR port not err-disabled on LACPDU storm", p.ErrDisableReason())
+	}
+
+	time.Sleep(time.Millisecond * 300)
+	if p.IsErrDisabled() {
+		t.Error("ERROR port did not auto recover")
+	}
+	if mock.adminState[11] != "UP" {
+		t.Error("ERROR port was not brought up in hw", mock.adminState)
+	}
+}
+
+func TestLaErrDisableRecoveryPostedToRxMachine(t *testing.T) {
+	mock := ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		PduStormEnable:   true,
+		PduStormRate:     5,
+		RecoveryInterval: time.Millisecond * 100,
+	})
+
+	p := &LaAggPort{PortNum: 12, IntfNum: "SIMeth2"}
+	p.RxMachineFsm = &LacpRxMachine{
+		RxmEvents:                 make(chan utils.MachineEvent, 10),
+		RxmErrDisableRecoverEvent: make(chan bool, 1),
+	}
+	for i := 0; i < 6; i++ {
+		p.LaErrDisableCheckPduRx()
+	}
+
+	select {
+	case <-p.RxMachineFsm.RxmErrDisableRecoverEvent:
+	case <-time.After(time.Second):
+		t.Error("ERROR recovery not posted to rx machine")
+	}
+	// timer goroutine must not recover the port itself
+	if !p.IsErrDisabled() || mock.adminState[12] != "DOWN" {
+		t.Error("ERROR port recovered off the rx machine", mock.adminState)
+	}
+	p.LaErrDisableRecover()
+}
+
+func TestLaErrDisableLoopback(t *testing.T) {
+	ErrDisableTestSetup()
+	defer ErrDisableTestTeardown()
+
+	LaErrDisableConfigSet(LaErrDisableConfig{
+		LoopbackEnable: true,
+	})
+
+	p := &LaAggPort{PortNum: 12, IntfNum: "SIMeth2"}
+	p.ActorOper.System.Actor_System = [6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	p.PartnerOper.System.Actor_System = [6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x66}
+	p.LaErrDisableCheckPartner()
+	if p.IsErrDisabled() {
+		t.Error("ERROR port err-disabled with different partner system")
+	}
+
+	// another port of our own system in a different aggregator
+	p.ActorOper.port = 12
+	p.ActorOper.Key = 100
+	p.PartnerOper.port = 13
+	p.PartnerOper.Key = 200
+	p.PartnerOper.System.Actor_System = p.ActorOper.System.Actor_System
+	p.LaErrDisableCheckPartner()
+	if p.IsErrDisabled() {
+		t.Error("ERROR port err-disabled when connected to another port of the same system")
+	}
+
+	p.PartnerOper.port = p.ActorOper.port
+	p.PartnerOper.Key = p.ActorOper.Key
+	p.LaErrDisableCheckPartner()
+	if p.ErrDisableReason() != LaErrDisableReasonStrMap[LaErrDisableReasonLoopback] {
+		t.Error("ERROR port not err-disabled on loopback", p.ErrDisableReason())
+	}
+}
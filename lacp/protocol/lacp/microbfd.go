@@ -0,0 +1,602 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// microbfd.go - RFC 7130 Micro BFD sessions on LAG member links.  Each member
+// runs an independent RFC 5880 asynchronous mode session over UDP port 6784.
+// A member whose session is not Up will not be allowed to distribute, and
+// a member which is distributing will fall back to collecting when its
+// session goes Down, the same way it would if the partner stopped collecting.
+package lacp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const MicroBfdModuleStr = "Micro BFD"
+
+const (
+	// RFC 7130 Section 2.2
+	MicroBfdUdpPort = 6784
+	// RFC 5881 Section 4
+	MicroBfdSrcPortMin = 49152
+	MicroBfdSrcPortMax = 65535
+	// RFC 5881 Section 5 single hop packets are sent with a TTL of 255
+	// and received packets with any other TTL are discarded
+	MicroBfdTtl = 255
+
+	MicroBfdVersion          = 1
+	MicroBfdControlPacketLen = 24
+)
+
+// RFC 5880 Section 4.1 State (Sta)
+const (
+	MicroBfdStateAdminDown = iota
+	MicroBfdStateDown
+	MicroBfdStateInit
+	MicroBfdStateUp
+)
+
+var MicroBfdStateStrMap = map[uint8]string{
+	MicroBfdStateAdminDown: "AdminDown",
+	MicroBfdStateDown:      "Down",
+	MicroBfdStateInit:      "Init",
+	MicroBfdStateUp:        "Up",
+}
+
+// RFC 5880 Section 4.1 Diagnostic (Diag)
+const (
+	MicroBfdDiagNone = iota
+	MicroBfdDiagControlDetectExpired
+	MicroBfdDiagEchoFailed
+	MicroBfdDiagNeighborDown
+	MicroBfdDiagForwardingPlaneReset
+	MicroBfdDiagPathDown
+	MicroBfdDiagConcatPathDown
+	MicroBfdDiagAdminDown
+	MicroBfdDiagRevConcatPathDown
+)
+
+const (
+	MicroBfdDefaultTxInterval = time.Millisecond * 300
+	MicroBfdDefaultRxInterval = time.Millisecond * 300
+	MicroBfdDefaultDetectMult = 3
+	// RFC 5880 Section 6.8.3 while the session is not Up the transmit
+	// interval must be at least one second
+	MicroBfdSlowTxInterval = time.Second
+)
+
+// MicroBfdConfig is supplied per aggregator, all members use the same
+// addresses as described in RFC 7130 Section 2.1
+type MicroBfdConfig struct {
+	Enable                bool
+	LocalIp               string
+	PeerIp                string
+	DesiredMinTxInterval  time.Duration
+	RequiredMinRxInterval time.Duration
+	DetectMult            uint8
+}
+
+// MicroBfdControlPacket RFC 5880 Section 4.1 mandatory section, the
+// authentication section is not supported
+type MicroBfdControlPacket struct {
+	Version                   uint8
+	Diag                      uint8
+	State                     uint8
+	Poll                      bool
+	Final                     bool
+	ControlPlaneIndependent   bool
+	AuthPresent               bool
+	Demand                    bool
+	Multipoint                bool
+	DetectMult                uint8
+	MyDiscriminator           uint32
+	YourDiscriminator         uint32
+	DesiredMinTxInterval      uint32
+	RequiredMinRxInterval     uint32
+	RequiredMinEchoRxInterval uint32
+}
+
+func (pkt *MicroBfdControlPacket) Encode() []byte {
+	b := make([]byte, MicroBfdControlPacketLen)
+	b[0] = pkt.Version<<5 | pkt.Diag&0x1f
+	b[1] = pkt.State << 6
+	if pkt.Poll {
+		b[1] |= 0x20
+	}
+	if pkt.Final {
+		b[1] |= 0x10
+	}
+	if pkt.ControlPlaneIndependent {
+		b[1] |= 0x08
+	}
+	if pkt.AuthPresent {
+		b[1] |= 0x04
+	}
+	if pkt.Demand {
+		b[1] |= 0x02
+	}
+	if pkt.Multipoint {
+		b[1] |= 0x01
+	}
+	b[2] = pkt.DetectMult
+	b[3] = MicroBfdControlPacketLen
+	binary.BigEndian.PutUint32(b[4:], pkt.MyDiscriminator)
+	binary.BigEndian.PutUint32(b[8:], pkt.YourDiscriminator)
+	binary.BigEndian.PutUint32(b[12:], pkt.DesiredMinTxInterval)
+	binary.BigEndian.PutUint32(b[16:], pkt.RequiredMinRxInterval)
+	binary.BigEndian.PutUint32(b[20:], pkt.RequiredMinEchoRxInterval)
+	return b
+}
+
+// MicroBfdControlPacketDecode will decode and validate a received packet
+// according to RFC 5880 Section 6.8.6
+func MicroBfdControlPacketDecode(b []byte) (*MicroBfdControlPacket, error) {
+	if len(b) < MicroBfdControlPacketLen {
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD packet too short %d", len(b)))
+	}
+	pkt := &MicroBfdControlPacket{
+		Version:                   b[0] >> 5,
+		Diag:                      b[0] & 0x1f,
+		State:                     b[1] >> 6,
+		Poll:                      b[1]&0x20 != 0,
+		Final:                     b[1]&0x10 != 0,
+		ControlPlaneIndependent:   b[1]&0x08 != 0,
+		AuthPresent:               b[1]&0x04 != 0,
+		Demand:                    b[1]&0x02 != 0,
+		Multipoint:                b[1]&0x01 != 0,
+		DetectMult:                b[2],
+		MyDiscriminator:           binary.BigEndian.Uint32(b[4:]),
+		YourDiscriminator:         binary.BigEndian.Uint32(b[8:]),
+		DesiredMinTxInterval:      binary.BigEndian.Uint32(b[12:]),
+		RequiredMinRxInterval:     binary.BigEndian.Uint32(b[16:]),
+		RequiredMinEchoRxInterval: binary.BigEndian.Uint32(b[20:]),
+	}
+	length := int(b[3])
+
+	if pkt.Version != MicroBfdVersion {
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD invalid version %d", pkt.Version))
+	}
+	if length < MicroBfdControlPacketLen ||
+		length > len(b) {
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD invalid length %d", length))
+	}
+	if pkt.DetectMult == 0 {
+		return nil, errors.New("ERROR Micro BFD detect mult is zero")
+	}
+	if pkt.Multipoint {
+		return nil, errors.New("ERROR Micro BFD multipoint bit set")
+	}
+	if pkt.MyDiscriminator == 0 {
+		return nil, errors.New("ERROR Micro BFD my discriminator is zero")
+	}
+	if pkt.YourDiscriminator == 0 &&
+		pkt.State != MicroBfdStateDown &&
+		pkt.State != MicroBfdStateAdminDown {
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD your discriminator is zero in state %s", MicroBfdStateStrMap[pkt.State]))
+	}
+	if pkt.AuthPresent {
+		return nil, errors.New("ERROR Micro BFD authentication not supported")
+	}
+	return pkt, nil
+}
+
+// MicroBfdTransport is used by a session to send and receive control packets
+type MicroBfdTransport interface {
+	Send(pkt []byte) error
+	// Recv will block until a packet is received or the transport is closed
+	Recv() ([]byte, error)
+	Close() error
+}
+
+// MicroBfdTransportCreate creates the transport for a member, by default this
+// is a UDP socket bound to the member interface.  Can be replaced by tests
+var MicroBfdTransportCreate = MicroBfdUdpTransportCreate
+
+var gMicroBfdDiscriminator uint32
+
+func microBfdDiscriminatorAlloc() uint32 {
+	d := atomic.AddUint32(&gMicroBfdDiscriminator, 1)
+	if d == 0 {
+		d = atomic.AddUint32(&gMicroBfdDiscriminator, 1)
+	}
+	return d
+}
+
+// MicroBfdSession holds the RFC 5880 Section 6.8.1 state variables of a
+// session running on an Aggregation Port
+type MicroBfdSession struct {
+	p         *LaAggPort
+	cfg       MicroBfdConfig
+	transport MicroBfdTransport
+
+	// bfd.SessionState, accessed atomically as it is read by the
+	// lacp machines
+	sessionState uint32
+	// bfd.RemoteSessionState
+	RemoteSessionState uint8
+	// bfd.LocalDiscr
+	LocalDiscr uint32
+	// bfd.RemoteDiscr
+	RemoteDiscr uint32
+	// bfd.LocalDiag
+	LocalDiag uint8
+	// bfd.DesiredMinTxInterval
+	DesiredMinTxInterval time.Duration
+	// bfd.RequiredMinRxInterval
+	RequiredMinRxInterval time.Duration
+	// bfd.RemoteMinRxInterval
+	RemoteMinRxInterval time.Duration
+	// remote DesiredMinTxInterval used to calculate the detection time
+	RemoteDesiredMinTxInterval time.Duration
+	// bfd.DetectMult
+	DetectMult uint8
+	// remote Detect Mult used to calculate the detection time
+	RemoteDetectMult uint8
+	// poll sequence in progress
+	pollActive bool
+
+	// counters
+	PktsRx         uint64
+	PktsTx         uint64
+	PktsRxDiscard  uint64
+	StateUpCount   uint64
+	StateDownCount uint64
+
+	txTimer     *time.Timer
+	detectTimer *time.Timer
+
+	rxPktCh chan *MicroBfdControlPacket
+	stopCh  chan bool
+	wg      sync.WaitGroup
+}
+
+// MicroBfdSessionCreate will create and start a session on the port
+func (p *LaAggPort) MicroBfdSessionCreate(cfg MicroBfdConfig) error {
+	if p.microBfd != nil {
+		return nil
+	}
+
+	if cfg.DesiredMinTxInterval == 0 {
+		cfg.DesiredMinTxInterval = MicroBfdDefaultTxInterval
+	}
+	if cfg.RequiredMinRxInterval == 0 {
+		cfg.RequiredMinRxInterval = MicroBfdDefaultRxInterval
+	}
+	if cfg.DetectMult == 0 {
+		cfg.DetectMult = MicroBfdDefaultDetectMult
+	}
+
+	transport, err := MicroBfdTransportCreate(p.IntfNum, cfg)
+	if err != nil {
+		p.LaPortLog(fmt.Sprintf("Unable to create Micro BFD transport: %s", err))
+		return err
+	}
+
+	s := &MicroBfdSession{
+		p:                     p,
+		cfg:                   cfg,
+		transport:             transport,
+		sessionState:          MicroBfdStateDown,
+		RemoteSessionState:    MicroBfdStateDown,
+		LocalDiscr:            microBfdDiscriminatorAlloc(),
+		DesiredMinTxInterval:  MicroBfdSlowTxInterval,
+		RequiredMinRxInterval: cfg.RequiredMinRxInterval,
+		RemoteMinRxInterval:   time.Microsecond,
+		DetectMult:            cfg.DetectMult,
+		rxPktCh:               make(chan *MicroBfdControlPacket, 10),
+		stopCh:                make(chan bool),
+	}
+	if cfg.DesiredMinTxInterval > MicroBfdSlowTxInterval {
+		s.DesiredMinTxInterval = cfg.DesiredMinTxInterval
+	}
+	p.microBfd = s
+
+	p.LaPortLog(fmt.Sprintf("Micro BFD session created local %s peer %s discriminator %d", cfg.LocalIp, cfg.PeerIp, s.LocalDiscr))
+	s.start()
+	return nil
+}
+
+// MicroBfdSessionDelete will stop and delete the session on the port
+func (p *LaAggPort) MicroBfdSessionDelete() {
+	s := p.microBfd
+	if s == nil {
+		return
+	}
+
+	close(s.stopCh)
+	s.transport.Close()
+	s.wg.Wait()
+	p.microBfd = nil
+	p.LaPortLog("Micro BFD session deleted")
+
+	// port is no longer gated by micro bfd
+	p.MicroBfdNotifyMux(true)
+}
+
+// MicroBfdIsUp will return true if the port is allowed to distribute as
+// far as micro bfd is concerned
+func (p *LaAggPort) MicroBfdIsUp() bool {
+	s := p.microBfd
+	return s == nil || s.SessionState() == MicroBfdStateUp
+}
+
+// MicroBfdStateGet returns the session state string, empty if micro bfd is
+// not running on the port
+func (p *LaAggPort) MicroBfdStateGet() string {
+	s := p.microBfd
+	if s == nil {
+		return ""
+	}
+	return MicroBfdStateStrMap[s.SessionState()]
+}
+
+func (s *MicroBfdSession) SessionState() uint8 {
+	return uint8(atomic.LoadUint32(&s.sessionState))
+}
+
+func (s *MicroBfdSession) start() {
+	s.txTimer = time.NewTimer(s.txInterval())
+	s.detectTimer = time.NewTimer(s.detectTime())
+	s.detectTimer.Stop()
+
+	// receiver
+	s.wg.Add(1)
+	go func(s *MicroBfdSession) {
+		defer s.wg.Done()
+		for {
+			b, err := s.transport.Recv()
+			if err != nil {
+				select {
+				case <-s.stopCh:
+					return
+				default:
+					// transport failure lets not spin
+					time.Sleep(time.Millisecond * 100)
+					continue
+				}
+			}
+			pkt, err := MicroBfdControlPacketDecode(b)
+			if err != nil {
+				atomic.AddUint64(&s.PktsRxDiscard, 1)
+				continue
+			}
+			select {
+			case s.rxPktCh <- pkt:
+			case <-s.stopCh:
+				return
+			}
+		}
+	}(s)
+
+	// session
+	s.wg.Add(1)
+	go func(s *MicroBfdSession) {
+		defer s.wg.Done()
+		defer s.txTimer.Stop()
+		defer s.detectTimer.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case pkt := <-s.rxPktCh:
+				s.processRxPkt(pkt)
+			case <-s.txTimer.C:
+				s.txPkt(false)
+				s.txTimer.Reset(s.txInterval())
+			case <-s.detectTimer.C:
+				s.detectTimerExpired()
+			}
+		}
+	}(s)
+}
+
+// txInterval RFC 5880 Section 6.8.7 periodic transmission interval reduced
+// by a jitter of up to 25%, when bfd.DetectMult is 1 the interval must be
+// between 75 and 90% of the negotiated interval
+func (s *MicroBfdSession) txInterval() time.Duration {
+	interval := s.DesiredMinTxInterval
+	if s.RemoteMinRxInterval > interval {
+		interval = s.RemoteMinRxInterval
+	}
+	if s.DetectMult == 1 {
+		return interval * time.Duration(75+rand.Intn(16)) / 100
+	}
+	return interval - interval*time.Duration(rand.Intn(26))/100
+}
+
+// detectTime RFC 5880 Section 6.8.4 detection time in asynchronous mode
+func (s *MicroBfdSession) detectTime() time.Duration {
+	interval := s.RequiredMinRxInterval
+	if s.RemoteDesiredMinTxInterval > interval {
+		interval = s.RemoteDesiredMinTxInterval
+	}
+	mult := s.RemoteDetectMult
+	if mult == 0 {
+		mult = s.DetectMult
+	}
+	return time.Duration(mult) * interval
+}
+
+func (s *MicroBfdSession) txPkt(final bool) {
+	pkt := MicroBfdControlPacket{
+		Version:               MicroBfdVersion,
+		Diag:                  s.LocalDiag,
+		State:                 s.SessionState(),
+		Poll:                  s.pollActive && !final,
+		Final:                 final,
+		DetectMult:            s.DetectMult,
+		MyDiscriminator:       s.LocalDiscr,
+		YourDiscriminator:     s.RemoteDiscr,
+		DesiredMinTxInterval:  uint32(s.DesiredMinTxInterval / time.Microsecond),
+		RequiredMinRxInterval: uint32(s.RequiredMinRxInterval / time.Microsecond),
+	}
+	if err := s.transport.Send(pkt.Encode()); err == nil {
+		atomic.AddUint64(&s.PktsTx, 1)
+	}
+}
+
+// processRxPkt RFC 5880 Section 6.8.6 reception of control packets
+func (s *MicroBfdSession) processRxPkt(pkt *MicroBfdControlPacket) {
+	if pkt.YourDiscriminator != 0 &&
+		pkt.YourDiscriminator != s.LocalDiscr {
+		atomic.AddUint64(&s.PktsRxDiscard, 1)
+		return
+	}
+	atomic.AddUint64(&s.PktsRx, 1)
+
+	s.RemoteDiscr = pkt.MyDiscriminator
+	s.RemoteSessionState = pkt.State
+	s.RemoteDetectMult = pkt.DetectMult
+	s.RemoteDesiredMinTxInterval = time.Duration(pkt.DesiredMinTxInterval) * time.Microsecond
+	s.RemoteMinRxInterval = time.Duration(pkt.RequiredMinRxInterval) * time.Microsecond
+
+	if pkt.Final {
+		s.pollActive = false
+	}
+
+	state := s.SessionState()
+	if state == MicroBfdStateAdminDown {
+		return
+	}
+
+	if pkt.State == MicroBfdStateAdminDown {
+		if state != MicroBfdStateDown {
+			s.stateSet(MicroBfdStateDown, MicroBfdDiagNeighborDown)
+		}
+	} else {
+		switch state {
+		case MicroBfdStateDown:
+			if pkt.State == MicroBfdStateDown {
+				s.stateSet(MicroBfdStateInit, MicroBfdDiagNone)
+			} else if pkt.State == MicroBfdStateInit {
+				s.stateSet(MicroBfdStateUp, MicroBfdDiagNone)
+			}
+		case MicroBfdStateInit:
+			if pkt.State == MicroBfdStateInit ||
+				pkt.State == MicroBfdStateUp {
+				s.stateSet(MicroBfdStateUp, MicroBfdDiagNone)
+			}
+		case MicroBfdStateUp:
+			if pkt.State == MicroBfdStateDown {
+				s.stateSet(MicroBfdStateDown, MicroBfdDiagNeighborDown)
+			}
+		}
+	}
+
+	// restart the detection timer
+	s.detectTimer.Stop()
+	if s.SessionState() != MicroBfdStateDown {
+		s.detectTimer.Reset(s.detectTime())
+	}
+
+	// RFC 5880 Section 6.8.6 a poll must be answered immediately with final
+	if pkt.Poll {
+		s.txPkt(true)
+	}
+}
+
+func (s *MicroBfdSession) detectTimerExpired() {
+	state := s.SessionState()
+	if state == MicroBfdStateInit ||
+		state == MicroBfdStateUp {
+		s.stateSet(MicroBfdStateDown, MicroBfdDiagControlDetectExpired)
+		// RFC 5880 Section 6.8.1 remote discriminator should be cleared
+		// once the detection time has passed
+		s.RemoteDiscr = 0
+		s.RemoteDetectMult = 0
+		s.RemoteDesiredMinTxInterval = 0
+		s.RemoteMinRxInterval = time.Microsecond
+	}
+}
+
+func (s *MicroBfdSession) stateSet(state uint8, diag uint8) {
+	prev := s.SessionState()
+	atomic.StoreUint32(&s.sessionState, uint32(state))
+	s.LocalDiag = diag
+	s.p.LaPortLog(fmt.Sprintf("Micro BFD state %s -> %s diag %d", MicroBfdStateStrMap[prev], MicroBfdStateStrMap[state], diag))
+
+	// RFC 5880 Section 6.8.3 transmit slowly unless the session is up,
+	// a change in the transmit interval requires a poll sequence
+	desiredTx := MicroBfdSlowTxInterval
+	if state == MicroBfdStateUp ||
+		s.cfg.DesiredMinTxInterval > MicroBfdSlowTxInterval {
+		desiredTx = s.cfg.DesiredMinTxInterval
+	}
+	if desiredTx != s.DesiredMinTxInterval {
+		s.DesiredMinTxInterval = desiredTx
+		s.pollActive = true
+	}
+
+	if state == MicroBfdStateUp &&
+		prev != MicroBfdStateUp {
+		atomic.AddUint64(&s.StateUpCount, 1)
+		s.p.MicroBfdNotifyMux(true)
+	} else if state != MicroBfdStateUp &&
+		prev == MicroBfdStateUp {
+		atomic.AddUint64(&s.StateDownCount, 1)
+		s.p.MicroBfdNotifyMux(false)
+	}
+	// let the peer know immediately
+	s.txPkt(false)
+}
+
+// MicroBfdNotifyMux will inform the mux machine of a change in the micro
+// bfd state.  RFC 7130 Section 3 a member is only allowed to distribute
+// when its session is Up
+func (p *LaAggPort) MicroBfdNotifyMux(up bool) {
+	if p.MuxMachineFsm == nil {
+		return
+	}
+
+	state := p.MuxMachineFsm.Machine.Curr.CurrentState()
+	if !up &&
+		(state == LacpMuxmStateDistributing ||
+			state == LacpMuxStateCCollectingDistributing) {
+		p.MuxMachineFsm.MuxmEvents <- utils.MachineEvent{
+			E:   LacpMuxmEventMicroBfdDown,
+			Src: MicroBfdModuleStr}
+	} else if up &&
+		state == LacpMuxmStateCollecting &&
+		p.aggSelected == LacpAggSelected &&
+		LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) &&
+		LacpStateIsSet(p.PartnerOper.State, LacpStateCollectingBit) {
+		p.MuxMachineFsm.MuxmEvents <- utils.MachineEvent{
+			E:   LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting,
+			Src: MicroBfdModuleStr}
+	} else if up &&
+		state == LacpMuxmStateCAttached &&
+		p.aggSelected == LacpAggSelected &&
+		LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) {
+		// coupled control collecting and distributing together
+		p.MuxMachineFsm.MuxmEvents <- utils.MachineEvent{
+			E:   LacpMuxmEventSelectedEqualSelectedAndPartnerSync,
+			Src: MicroBfdModuleStr}
+	}
+}
@@ -0,0 +1,387 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// microbfd_test.go
+package lacp
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// MicroBfdTestTransport is an in memory transport, two of these are
+// connected back to back to act as the local peer
+type MicroBfdTestTransport struct {
+	tx     chan []byte
+	rx     chan []byte
+	closed chan bool
+}
+
+func (t *MicroBfdTestTransport) Send(pkt []byte) error {
+	select {
+	case <-t.closed:
+		return errors.New("closed")
+	case t.tx <- pkt:
+	default:
+		// drop like a real link would
+	}
+	return nil
+}
+
+func (t *MicroBfdTestTransport) Recv() ([]byte, error) {
+	select {
+	case b := <-t.rx:
+		return b, nil
+	case <-t.closed:
+		return nil, errors.New("closed")
+	}
+}
+
+func (t *MicroBfdTestTransport) Close() error {
+	close(t.closed)
+	return nil
+}
+
+func MicroBfdTestTransportPair(if1, if2 string) map[string]*MicroBfdTestTransport {
+	a := make(chan []byte, 10)
+	b := make(chan []byte, 10)
+	return map[string]*MicroBfdTestTransport{
+		if1: &MicroBfdTestTransport{tx: a, rx: b, closed: make(chan bool)},
+		if2: &MicroBfdTestTransport{tx: b, rx: a, closed: make(chan bool)},
+	}
+}
+
+func MicroBfdTestWaitState(p *LaAggPort, state string, timeout time.Duration) bool {
+	for end := time.Now().Add(timeout); time.Now().Before(end); {
+		if p.MicroBfdStateGet() == state {
+			return true
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return false
+}
+
+func TestMicroBfdControlPacketEncodeDecode(t *testing.T) {
+	pkt := MicroBfdControlPacket{
+		Version:               MicroBfdVersion,
+		Diag:                  MicroBfdDiagNeighborDown,
+		State:                 MicroBfdStateInit,
+		Poll:                  true,
+		DetectMult:            3,
+		MyDiscriminator:       10,
+		YourDiscriminator:     20,
+		DesiredMinTxInterval:  100000,
+		RequiredMinRxInterval: 200000,
+	}
+
+	b := pkt.Encode()
+	if len(b) != MicroBfdControlPacketLen {
+		t.Error("ERROR invalid encoded length", len(b))
+	}
+	rxPkt, err := MicroBfdControlPacketDecode(b)
+	if err != nil {
+		t.Error("ERROR unable to decode packet", err)
+	} else if *rxPkt != pkt {
+		t.Errorf("ERROR decoded packet does not match\n%+v\n%+v", *rxPkt, pkt)
+	}
+
+	// RFC 5880 6.8.6 packets which should be discarded
+	invalid := []MicroBfdControlPacket{pkt, pkt, pkt, pkt, pkt}
+	invalid[0].Version = 0
+	invalid[1].DetectMult = 0
+	invalid[2].Multipoint = true
+	invalid[3].MyDiscriminator = 0
+	invalid[4].YourDiscriminator = 0
+	for i, ipkt := range invalid {
+		if _, err = MicroBfdControlPacketDecode(ipkt.Encode()); err == nil {
+			t.Error("ERROR invalid packet was not discarded", i)
+		}
+	}
+	if _, err = MicroBfdControlPacketDecode(b[:10]); err == nil {
+		t.Error("ERROR short packet was not discarded")
+	}
+}
+
+func TestMicroBfdSessionUpDown(t *testing.T) {
+	transports := MicroBfdTestTransportPair("SIMeth0", "SIMeth1")
+	MicroBfdTransportCreate = func(ifname string, cfg MicroBfdConfig) (MicroBfdTransport, error) {
+		return transports[ifname], nil
+	}
+	defer func() {
+		MicroBfdTransportCreate = MicroBfdUdpTransportCreate
+	}()
+
+	cfg := MicroBfdConfig{
+		Enable:                true,
+		LocalIp:               "10.1.1.1",
+		PeerIp:                "10.1.1.2",
+		DesiredMinTxInterval:  time.Millisecond * 50,
+		RequiredMinRxInterval: time.Millisecond * 50,
+		DetectMult:            3,
+	}
+
+	p1 := &LaAggPort{PortNum: 1, IntfNum: "SIMeth0"}
+	p2 := &LaAggPort{PortNum: 2, IntfNum: "SIMeth1"}
+
+	if p1.MicroBfdStateGet() != "" || !p1.MicroBfdIsUp() {
+		t.Error("ERROR port without micro bfd should not be gated")
+	}
+
+	p1.MicroBfdSessionCreate(cfg)
+	cfg.LocalIp, cfg.PeerIp = cfg.PeerIp, cfg.LocalIp
+	p2.MicroBfdSessionCreate(cfg)
+
+	if p1.MicroBfdIsUp() {
+		t.Error("ERROR port should be gated until session is up")
+	}
+
+	if !MicroBfdTestWaitState(p1, "Up", time.Second*5) ||
+		!MicroBfdTestWaitState(p2, "Up", time.Second*5) {
+		t.Error("ERROR sessions did not come up", p1.MicroBfdStateGet(), p2.MicroBfdStateGet())
+	}
+	if !p1.MicroBfdIsUp() {
+		t.Error("ERROR port should not be gated once session is up")
+	}
+
+	// peer goes away, detection time should expire
+	p2.MicroBfdSessionDelete()
+	if !MicroBfdTestWaitState(p1, "Down", time.Second*2) {
+		t.Error("ERROR session did not detect peer failure", p1.MicroBfdStateGet())
+	}
+
+	// session goroutines have stopped once delete returns
+	s := p1.microBfd
+	p1.MicroBfdSessionDelete()
+	if s.LocalDiag != MicroBfdDiagControlDetectExpired {
+		t.Error("ERROR expected diag control detection time expired", s.LocalDiag)
+	}
+}
+
+// TestMicroBfdCoupledMux verifies a coupled control member is held in
+// C_ATTACHED until its micro bfd session comes up
+func TestMicroBfdCoupledMux(t *testing.T) {
+	p := &LaAggPort{PortNum: 1, IntfNum: "SIMeth0"}
+	p.microBfd = &MicroBfdSession{sessionState: MicroBfdStateDown}
+	p.aggSelected = LacpAggSelected
+	LacpStateSet(&p.PartnerOper.State, LacpStateSyncBit)
+
+	muxm := p.LacpMuxMachineFSMBuild()
+	muxm.Machine.Curr.SetState(LacpMuxmStateCAttached)
+
+	if p.MicroBfdIsUp() {
+		t.Error("ERROR port should be gated until session is up")
+	}
+
+	// session down while attached, nothing to do
+	p.MicroBfdNotifyMux(false)
+	if len(muxm.MuxmEvents) != 0 {
+		t.Error("ERROR unexpected mux event while session down in C_ATTACHED")
+	}
+
+	// session up, coupled member may now collect and distribute
+	p.MicroBfdNotifyMux(true)
+	select {
+	case event := <-muxm.MuxmEvents:
+		if event.E != LacpMuxmEventSelectedEqualSelectedAndPartnerSync {
+			t.Error("ERROR expected SELECTED and PARTNER SYNC event actual", event.E)
+		}
+	default:
+		t.Error("ERROR expected mux event when session came up in C_ATTACHED")
+	}
+
+	// session down while collecting distributing
+	muxm.Machine.Curr.SetState(LacpMuxStateCCollectingDistributing)
+	p.MicroBfdNotifyMux(false)
+	select {
+	case event := <-muxm.MuxmEvents:
+		if event.E != LacpMuxmEventMicroBfdDown {
+			t.Error("ERROR expected MICRO BFD DOWN event actual", event.E)
+		}
+	default:
+		t.Error("ERROR expected mux event when session went down in C_COLLECTING_DISTRIBUTING")
+	}
+
+	// partner out of sync, stay attached
+	muxm.Machine.Curr.SetState(LacpMuxmStateCAttached)
+	LacpStateClear(&p.PartnerOper.State, LacpStateSyncBit)
+	p.MicroBfdNotifyMux(true)
+	if len(muxm.MuxmEvents) != 0 {
+		t.Error("ERROR unexpected mux event while partner out of sync")
+	}
+	muxm.WaitWhileTimerStop()
+}
+
+// TestMicroBfdMuxRuleGated verifies the mux transitions themselves will not
+// distribute while the session is down regardless of who sent the event
+func TestMicroBfdMuxRuleGated(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown()
+
+	p := &LaAggPort{PortNum: 1, IntfNum: "SIMeth0"}
+	p.microBfd = &MicroBfdSession{sessionState: MicroBfdStateDown}
+	p.aggSelected = LacpAggSelected
+	LacpStateSet(&p.PartnerOper.State, LacpStateSyncBit)
+	LacpStateSet(&p.PartnerOper.State, LacpStateCollectingBit)
+
+	muxm := p.LacpMuxMachineFSMBuild()
+	defer muxm.WaitWhileTimerStop()
+
+	muxm.Machine.Curr.SetState(LacpMuxmStateCAttached)
+	muxm.Machine.ProcessEvent(MicroBfdModuleStr, LacpMuxmEventSelectedEqualSelectedAndPartnerSync, nil)
+	if muxm.Machine.Curr.CurrentState() != LacpMuxmStateCAttached ||
+		LacpStateIsSet(p.ActorOper.State, LacpStateDistributingBit) {
+		t.Error("ERROR coupled member distributing while session down", MuxmStateStrMap[muxm.Machine.Curr.CurrentState()])
+	}
+
+	muxm.Machine.Curr.SetState(LacpMuxmStateCollecting)
+	muxm.Machine.ProcessEvent(MicroBfdModuleStr, LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting, nil)
+	if muxm.Machine.Curr.CurrentState() != LacpMuxmStateCollecting ||
+		LacpStateIsSet(p.ActorOper.State, LacpStateDistributingBit) {
+		t.Error("ERROR member distributing while session down", MuxmStateStrMap[muxm.Machine.Curr.CurrentState()])
+	}
+}
+
+// TestMicroBfdUdpLocalPeer runs two sessions back to back over UDP sockets
+// using loopback addresses
+func TestMicroBfdUdpLocalPeer(t *testing.T) {
+	cfg := MicroBfdConfig{
+		Enable:                true,
+		LocalIp:               "127.0.0.1",
+		PeerIp:                "127.0.0.2",
+		DesiredMinTxInterval:  time.Millisecond * 50,
+		RequiredMinRxInterval: time.Millisecond * 50,
+	}
+	peerCfg := cfg
+	peerCfg.LocalIp, peerCfg.PeerIp = cfg.PeerIp, cfg.LocalIp
+
+	p1 := &LaAggPort{PortNum: 1}
+	p2 := &LaAggPort{PortNum: 2}
+	if err := p1.MicroBfdSessionCreate(cfg); err != nil {
+		t.Skip("Unable to create UDP sockets", err)
+	}
+	defer p1.MicroBfdSessionDelete()
+	if err := p2.MicroBfdSessionCreate(peerCfg); err != nil {
+		t.Skip("Unable to create UDP sockets", err)
+	}
+	defer p2.MicroBfdSessionDelete()
+
+	if !MicroBfdTestWaitState(p1, "Up", time.Second*5) ||
+		!MicroBfdTestWaitState(p2, "Up", time.Second*5) {
+		t.Error("ERROR sessions did not come up", p1.MicroBfdStateGet(), p2.MicroBfdStateGet())
+	}
+}
+
+// TestMicroBfdUdpTtlCheck a packet which has not been sent with a TTL of 255
+// must be discarded
+func TestMicroBfdUdpTtlCheck(t *testing.T) {
+	cfg := MicroBfdConfig{
+		LocalIp: "127.0.0.3",
+		PeerIp:  "127.0.0.4",
+	}
+	peerCfg := cfg
+	peerCfg.LocalIp, peerCfg.PeerIp = cfg.PeerIp, cfg.LocalIp
+
+	rx, err := MicroBfdUdpTransportCreate("", cfg)
+	if err != nil {
+		t.Skip("Unable to create UDP sockets", err)
+	}
+	defer rx.Close()
+	tx, err := MicroBfdUdpTransportCreate("", peerCfg)
+	if err != nil {
+		t.Skip("Unable to create UDP sockets", err)
+	}
+	defer tx.Close()
+
+	// packet from the peer address as if it had been routed
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		t.Skip("Unable to create UDP socket", err)
+	}
+	defer syscall.Close(fd)
+	if err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL, 64); err != nil {
+		t.Skip("Unable to set TTL", err)
+	}
+	if err = syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 4}}); err != nil {
+		t.Skip("Unable to bind UDP socket", err)
+	}
+	syscall.Sendto(fd, []byte{1}, 0, &syscall.SockaddrInet4{Port: MicroBfdUdpPort, Addr: [4]byte{127, 0, 0, 3}})
+	tx.Send([]byte{2})
+
+	b, err := rx.Recv()
+	if err != nil {
+		t.Fatal("ERROR receive failed", err)
+	}
+	if len(b) != 1 || b[0] != 2 {
+		t.Error("ERROR packet not sent with TTL 255 was not discarded", b)
+	}
+}
+
+// TestMicroBfdTxIntervalJitter RFC 5880 Section 6.8.7
+func TestMicroBfdTxIntervalJitter(t *testing.T) {
+	s := &MicroBfdSession{
+		DesiredMinTxInterval: time.Millisecond * 100,
+		RemoteMinRxInterval:  time.Millisecond * 10,
+		DetectMult:           3,
+	}
+	for i := 0; i < 1000; i++ {
+		if d := s.txInterval(); d < time.Millisecond*75 ||
+			d > time.Millisecond*100 {
+			t.Fatal("ERROR transmit interval not within 75-100% of the interval", d)
+		}
+	}
+	s.DetectMult = 1
+	for i := 0; i < 1000; i++ {
+		if d := s.txInterval(); d < time.Millisecond*75 ||
+			d > time.Millisecond*90 {
+			t.Fatal("ERROR transmit interval not within 75-90% of the interval with detect mult 1", d)
+		}
+	}
+}
+
+// TestMicroBfdVethPeer runs a session against a BFD peer reachable over
+// a veth interface, ie the other end of the veth pair in another namespace:
+// MICROBFD_TEST_INTF=veth0 MICROBFD_TEST_LOCAL_IP=10.1.1.1 MICROBFD_TEST_PEER_IP=10.1.1.2
+func TestMicroBfdVethPeer(t *testing.T) {
+	intf := os.Getenv("MICROBFD_TEST_INTF")
+	if intf == "" {
+		t.Skip("MICROBFD_TEST_INTF not set")
+	}
+	cfg := MicroBfdConfig{
+		Enable:  true,
+		LocalIp: os.Getenv("MICROBFD_TEST_LOCAL_IP"),
+		PeerIp:  os.Getenv("MICROBFD_TEST_PEER_IP"),
+	}
+
+	p := &LaAggPort{PortNum: 1, IntfNum: intf}
+	if err := p.MicroBfdSessionCreate(cfg); err != nil {
+		t.Fatal("ERROR unable to create session", err)
+	}
+	defer p.MicroBfdSessionDelete()
+
+	if !MicroBfdTestWaitState(p, "Up", time.Second*10) {
+		t.Error("ERROR session did not come up", p.MicroBfdStateGet())
+	}
+}
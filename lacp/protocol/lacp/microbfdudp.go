@@ -0,0 +1,172 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// microbfdudp.go - UDP transport for Micro BFD, RFC 7130 Section 2.2 packets
+// are sent to UDP port 6784 and both sockets are bound to the member
+// interface so that each member session only sees its own packets
+package lacp
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+)
+
+type MicroBfdUdpTransport struct {
+	rxConn *net.UDPConn
+	txConn *net.UDPConn
+	peer   *net.UDPAddr
+}
+
+// microBfdUdpSocket will create a UDP socket bound to the interface and
+// address supplied, an empty ifname will not bind to a device
+func microBfdUdpSocket(ifname string, ip net.IP, port int) (*net.UDPConn, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_UDP)
+	if err != nil {
+		return nil, err
+	}
+	// all members share the same local address
+	if err = syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	if ifname != "" {
+		if err = syscall.BindToDevice(fd, ifname); err != nil {
+			syscall.Close(fd)
+			return nil, err
+		}
+	}
+	// RFC 5881 Section 5 single hop packets are sent with a TTL of 255
+	if err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_TTL, MicroBfdTtl); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	// the TTL of received packets is needed to discard routed packets
+	if err = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_RECVTTL, 1); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	sa := &syscall.SockaddrInet4{Port: port}
+	copy(sa.Addr[:], ip.To4())
+	if err = syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("microbfd-%s-%d", ifname, port))
+	conn, err := net.FilePacketConn(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
+// MicroBfdUdpTransportCreate will create the rx socket on UDP port 6784 and
+// a tx socket using a source port from the range defined in RFC 5881
+func MicroBfdUdpTransportCreate(ifname string, cfg MicroBfdConfig) (MicroBfdTransport, error) {
+	localIp := net.ParseIP(cfg.LocalIp)
+	peerIp := net.ParseIP(cfg.PeerIp)
+	if localIp == nil || localIp.To4() == nil ||
+		peerIp == nil || peerIp.To4() == nil {
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD invalid IPv4 address local %s peer %s", cfg.LocalIp, cfg.PeerIp))
+	}
+
+	rxConn, err := microBfdUdpSocket(ifname, localIp, MicroBfdUdpPort)
+	if err != nil {
+		return nil, err
+	}
+
+	var txConn *net.UDPConn
+	start := rand.Intn(MicroBfdSrcPortMax - MicroBfdSrcPortMin + 1)
+	for i := 0; i <= MicroBfdSrcPortMax-MicroBfdSrcPortMin; i++ {
+		port := MicroBfdSrcPortMin + (start+i)%(MicroBfdSrcPortMax-MicroBfdSrcPortMin+1)
+		if txConn, err = microBfdUdpSocket(ifname, localIp, port); err == nil {
+			break
+		}
+	}
+	if txConn == nil {
+		rxConn.Close()
+		return nil, errors.New(fmt.Sprintf("ERROR Micro BFD unable to allocate source port on %s: %s", ifname, err))
+	}
+
+	return &MicroBfdUdpTransport{
+		rxConn: rxConn,
+		txConn: txConn,
+		peer:   &net.UDPAddr{IP: peerIp, Port: MicroBfdUdpPort},
+	}, nil
+}
+
+func (t *MicroBfdUdpTransport) Send(pkt []byte) error {
+	_, err := t.txConn.WriteToUDP(pkt, t.peer)
+	return err
+}
+
+func (t *MicroBfdUdpTransport) Recv() ([]byte, error) {
+	b := make([]byte, 128)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	for {
+		n, oobn, _, addr, err := t.rxConn.ReadMsgUDP(b, oob)
+		if err != nil {
+			return nil, err
+		}
+		// only accept packets from the configured peer, RFC 5881 Section 5
+		// and RFC 7130 Section 2.2 packets which have been routed are
+		// discarded
+		if addr.IP.Equal(t.peer.IP) &&
+			microBfdUdpRxTtl(oob[:oobn]) == MicroBfdTtl {
+			return b[:n], nil
+		}
+	}
+}
+
+// microBfdUdpRxTtl returns the TTL from the IP_TTL control message of a
+// received packet, 0 if not present
+func microBfdUdpRxTtl(oob []byte) int {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return 0
+	}
+	for _, m := range msgs {
+		if m.Header.Level == syscall.IPPROTO_IP &&
+			m.Header.Type == syscall.IP_TTL &&
+			len(m.Data) >= 4 {
+			// host byte order int, the TTL fits within a single
+			// byte so the others are always zero
+			ttl := 0
+			for _, b := range m.Data[:4] {
+				ttl |= int(b)
+			}
+			return ttl
+		}
+	}
+	return 0
+}
+
+func (t *MicroBfdUdpTransport) Close() error {
+	t.txConn.Close()
+	return t.rxConn.Close()
+}
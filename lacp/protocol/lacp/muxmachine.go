@@ -81,6 +81,7 @@ func MuxxMachineStrStateMapCreate() {
 	MuxmEventStrMap[LacpMuxmEventNotPartnerSync] = "Event Partner Oper Sync state is NOT set"
 	MuxmEventStrMap[LacpMuxmEventNotPartnerCollecting] = "Event Partner Oper Collecting state is not set"
 	MuxmEventStrMap[LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting] = "Event Selected equals Selected and Partner Oper Sync and Collecting state is set"
+	MuxmEventStrMap[LacpMuxmEventMicroBfdDown] = "Event Micro BFD session is NOT up"
 
 }
 
@@ -94,6 +95,7 @@ const (
 	LacpMuxmEventNotPartnerSync
 	LacpMuxmEventNotPartnerCollecting
 	LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting
+	LacpMuxmEventMicroBfdDown
 )
 
 // LacpRxMachine holds FSM and current State
@@ -210,6 +212,9 @@ func (muxm *LacpMuxMachine) LacpMuxmDetached(m fsm.Machine, data interface{}) fs
 	// Disable Collecting
 	muxm.DisableCollecting()
 
+	// no longer distributing, adaptive rate moves back to fast
+	p.LaAutoRateChurn()
+
 	// NTT = TRUE
 	// TODO: is this necessary? May only want to let TxMachine
 	//       set ntt to true based on NTT event
@@ -296,6 +301,9 @@ func (muxm *LacpMuxMachine) LacpMuxmAttached(m fsm.Machine, data interface{}) fs
 	// Disable Collecting
 	muxm.DisableCollecting()
 
+	// no longer distributing, adaptive rate moves back to fast
+	p.LaAutoRateChurn()
+
 	return LacpMuxmStateAttached
 }
 
@@ -317,6 +325,9 @@ func (muxm *LacpMuxMachine) LacpMuxmCollecting(m fsm.Machine, data interface{})
 	//muxm.LacpMuxmLog("Clearing Actor Distributing Bit")
 	LacpStateClear(&p.ActorOper.State, LacpStateDistributingBit)
 
+	// no longer distributing, adaptive rate moves back to fast
+	p.LaAutoRateChurn()
+
 	if p.AggAttached != nil &&
 		len(p.AggAttached.DistributedPortNumList) == 0 {
 		p.AggAttached.OperState = false
@@ -332,6 +343,12 @@ func (muxm *LacpMuxMachine) LacpMuxmCollecting(m fsm.Machine, data interface{})
 func (muxm *LacpMuxMachine) LacpMuxmDistributing(m fsm.Machine, data interface{}) fsm.State {
 	p := muxm.p
 
+	// RFC 7130 member must not distribute until micro bfd is up
+	if !p.MicroBfdIsUp() {
+		muxm.LacpMuxmLog("Micro BFD session not up, remain in Collecting")
+		return LacpMuxmStateCollecting
+	}
+
 	// Actor Oper State Sync == TRUE
 	//muxm.LacpMuxmLog("Setting Actor Distributing Bit")
 	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
@@ -342,6 +359,9 @@ func (muxm *LacpMuxMachine) LacpMuxmDistributing(m fsm.Machine, data interface{}
 		p.AggAttached.OperState = true
 	}
 
+	// adaptive rate moves to slow once distributing has been stable
+	p.LaAutoRateStableStart()
+
 	// indicate that NTT = TRUE
 	defer muxm.SendTxMachineNtt()
 
@@ -377,6 +397,9 @@ func (muxm *LacpMuxMachine) LacpMuxmCDetached(m fsm.Machine, data interface{}) f
 	// Actor Oper State Distributing = FALSE
 	LacpStateClear(&p.ActorOper.State, LacpStateDistributingBit)
 
+	// no longer distributing, adaptive rate moves back to fast
+	p.LaAutoRateChurn()
+
 	return LacpMuxmStateDetached
 }
 
@@ -421,6 +444,9 @@ func (muxm *LacpMuxMachine) LacpMuxmCAttached(m fsm.Machine, data interface{}) f
 	// Actor Oper State Distributing = FALSE
 	LacpStateClear(&p.ActorOper.State, LacpStateDistributingBit)
 
+	// no longer distributing, adaptive rate moves back to fast
+	p.LaAutoRateChurn()
+
 	return LacpMuxmStateWaiting
 }
 
@@ -428,6 +454,12 @@ func (muxm *LacpMuxMachine) LacpMuxmCAttached(m fsm.Machine, data interface{}) f
 func (muxm *LacpMuxMachine) LacpMuxmCCollectingDistributing(m fsm.Machine, data interface{}) fsm.State {
 	p := muxm.p
 
+	// RFC 7130 coupled member must not distribute until micro bfd is up
+	if !p.MicroBfdIsUp() {
+		muxm.LacpMuxmLog("Micro BFD session not up, remain in C_ATTACHED")
+		return LacpMuxmStateCAttached
+	}
+
 	// Actor Oper State Distributing = TRUE
 	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
 
@@ -437,6 +469,9 @@ func (muxm *LacpMuxMachine) LacpMuxmCCollectingDistributing(m fsm.Machine, data
 	// Actor Oper State Distributing == FALSE
 	LacpStateSet(&p.ActorOper.State, LacpStateDistributingBit)
 
+	// adaptive rate moves to slow once distributing has been stable
+	p.LaAutoRateStableStart()
+
 	// indicate that NTT = TRUE
 	defer muxm.SendTxMachineNtt()
 
@@ -487,6 +522,8 @@ func (p *LaAggPort) LacpMuxMachineFSMBuild() *LacpMuxMachine {
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventSelectedEqualStandby, muxm.LacpMuxmCollecting)
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventNotPartnerSync, muxm.LacpMuxmCollecting)
 	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventNotPartnerCollecting, muxm.LacpMuxmCollecting)
+	// NOT MICRO BFD UP -> COLLECTING
+	rules.AddRule(LacpMuxmStateDistributing, LacpMuxmEventMicroBfdDown, muxm.LacpMuxmCollecting)
 
 	// MUX Coupled
 	//BEGIN -> DETACHED
@@ -512,6 +549,8 @@ func (p *LaAggPort) LacpMuxMachineFSMBuild() *LacpMuxMachine {
 	rules.AddRule(LacpMuxmStateCollecting, LacpMuxmEventSelectedEqualUnselected, muxm.LacpMuxmCAttached)
 	rules.AddRule(LacpMuxmStateCollecting, LacpMuxmEventSelectedEqualStandby, muxm.LacpMuxmCAttached)
 	rules.AddRule(LacpMuxmStateCollecting, LacpMuxmEventNotPartnerSync, muxm.LacpMuxmCAttached)
+	// NOT MICRO BFD UP -> ATTACHED
+	rules.AddRule(LacpMuxStateCCollectingDistributing, LacpMuxmEventMicroBfdDown, muxm.LacpMuxmCAttached)
 
 	// Create a new FSM and apply the rules
 	muxm.Apply(&rules)
@@ -598,7 +637,8 @@ func (p *LaAggPort) LacpMuxMachineMain() {
 							m.LacpMuxmWaitingEvaluateSelected(true)
 						}
 						if (m.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
-							m.Machine.Curr.CurrentState() == LacpMuxmStateCAttached) &&
+							(m.Machine.Curr.CurrentState() == LacpMuxmStateCAttached &&
+								p.MicroBfdIsUp())) &&
 							p.aggSelected == LacpAggSelected &&
 							LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) {
 
@@ -610,7 +650,8 @@ func (p *LaAggPort) LacpMuxMachineMain() {
 						if m.Machine.Curr.CurrentState() == LacpMuxmStateCollecting &&
 							p.aggSelected == LacpAggSelected &&
 							LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) &&
-							LacpStateIsSet(p.PartnerOper.State, LacpStateCollectingBit) {
+							LacpStateIsSet(p.PartnerOper.State, LacpStateCollectingBit) &&
+							p.MicroBfdIsUp() {
 
 							eventStr = strings.Join([]string{eventStr,
 								"and\nfrom", MuxMachineModuleStr, MuxmEventStrMap[LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting]}, " ")
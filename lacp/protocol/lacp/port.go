@@ -32,7 +32,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 )
@@ -308,6 +307,15 @@ type LaAggPort struct {
 	partnerVersion uint8
 
 	sysId net.HardwareAddr
+
+	// err-disable state
+	errDisable laErrDisablePortInfo
+
+	// RFC 7130 Micro BFD session, nil when not enabled
+	microBfd *MicroBfdSession
+
+	// adaptive lacp rate state
+	autoRate laAutoRatePortInfo
 }
 
 // find a port from the global map table by PortNum
@@ -501,26 +509,19 @@ func (p *LaAggPort) CreateRxTx() {
 
 			sgi := LacpSysGlobalInfoByIdGet(sysId)
 
-			handle, err := pcap.OpenLive(p.IntfNum, 65536, true, 50*time.Millisecond)
+			// capture may already be open on behalf of another
+			// slow protocol, ie EFM OAM
+			handle, err := LaSlowProtocolRxTxCreate(p.IntfNum, p.PortNum)
 			if err != nil {
 				// failure here may be ok as this may be SIM
 				if !strings.Contains(p.IntfNum, "SIM") {
-					fmt.Println("Error creating pcap OpenLive handle for port", p.PortNum, p.IntfNum, err)
+					fmt.Println("Error creating rx/tx for port", p.PortNum, p.IntfNum, err)
 				}
 				return
 			}
-			filter := fmt.Sprintf(`ether dst 01:80:C2:00:00:02`)
-			err = handle.SetBPFFilter(filter)
-			if err != nil {
-				p.LaPortLog(fmt.Sprintln("Unable to set bpf filter to pcap handler", err))
-			}
 			p.LaPortLog(fmt.Sprintln("Creating Listener for intf", p.IntfNum))
 			//p.LaPortLog(fmt.Sprintf("Creating Listener for intf", p.IntfNum))
 			p.handle = handle
-			src := gopacket.NewPacketSource(p.handle, layers.LayerTypeEthernet)
-			in := src.Packets()
-			// start rx routine
-			LaRxMain(p.PortNum, in)
 			p.LaPortLog(fmt.Sprintln("Rx Main Started for port", p.PortNum, sysId))
 
 			// register the tx func
@@ -549,7 +550,7 @@ func (p *LaAggPort) DeleteRxTx() {
 
 	// close rx/tx processing
 	if p.handle != nil {
-		p.handle.Close()
+		LaSlowProtocolRxTxDelete(p.IntfNum)
 		p.LaPortLog(fmt.Sprintf("RX/TX handle closed for port", p.PortNum))
 		p.handle = nil
 	}
@@ -590,6 +591,10 @@ func (p *LaAggPort) LaAggPortDelete() {
 			deletecb(int32(p.PortNum))
 		}
 	}
+	// port should not be left down once it is no longer managed by lacp
+	p.LaErrDisableRecover()
+	p.MicroBfdSessionDelete()
+	p.LaAutoRateDisable()
 	utils.DeleteEventMap(int32(p.PortNum))
 	p.Stop()
 	for _, sgi := range LacpSysGlobalInfoGet() {
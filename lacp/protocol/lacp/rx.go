@@ -84,7 +84,7 @@ func LaRxMain(pId uint16, rxPktChan chan gopacket.Packet) {
 							fmt.Println("Discard Packet not an lacp frame")
 							// discard packet
 						}
-					} else {
+					} else if !laSlowProtocolRxDispatch(rxMainPort, packet) {
 						// discard packet
 						fmt.Println("Discarding Packet not lacp or marker", packet)
 					}
@@ -125,17 +125,19 @@ func IsControlFrame(pId uint16, packet gopacket.Packet) (bool, bool) {
 			isSlowProtocolEtherType &&
 			slow.SubType == layers.SlowProtocolTypeLAMP {
 			marker = true
+		} else if isSlowProtocolMAC &&
+			isSlowProtocolEtherType &&
+			LaSlowProtocolRxRegistered(uint8(slow.SubType)) {
+			// picked up by another slow protocol, ie EFM OAM,
+			// they are not unknown
 		} else {
 			// Error cases for stats
 			if LaFindPortById(pId, &p) {
 				// 802.1ax-2014 7.3.3.1.5
-				// TODO Will need a way to know if a packet is picked up by
-				// another protocol for valid subtypes
+				// unknown subtype or mac/ethertype mismatch
 				// NOT handling 50 counters per second rate
-				if (!isSlowProtocolMAC &&
-					isSlowProtocolEtherType) ||
-					(isSlowProtocolMAC &&
-						!isSlowProtocolEtherType) {
+				if isSlowProtocolMAC ||
+					isSlowProtocolEtherType {
 					p.LacpCounter.AggPortStatsUnknownRx += 1
 				}
 			}
@@ -162,6 +164,7 @@ func ProcessLacpFrame(pId uint16, lacp *layers.LACP) {
 	// lets find the port via the info in the packet
 	if LaFindPortById(pId, &p) {
 		//fmt.Println(lacp)
+		p.LaErrDisableCheckPduRx()
 		if p.RxMachineFsm != nil {
 			p.RxMachineFsm.RxmPktRxEvent <- LacpRxLacpPdu{
 				pdu: lacp,
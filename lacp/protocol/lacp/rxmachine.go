@@ -74,6 +74,7 @@ const (
 	LacpRxmEventLacpEnabled
 	LacpRxmEventLacpPktRx
 	LacpRxmEventKillSignal
+	LacpRxmEventAutoRateTimeout
 )
 
 type LacpRxLacpPdu struct {
@@ -102,6 +103,9 @@ type LacpRxMachine struct {
 	RxmEvents         chan utils.MachineEvent
 	RxmPktRxEvent     chan LacpRxLacpPdu
 	RxmLogEnableEvent chan bool
+	// err-disable recovery timer expired, single slot as a pending
+	// recovery covers any later expiry
+	RxmErrDisableRecoverEvent chan bool
 }
 
 func (rxm *LacpRxMachine) PrevState() fsm.State { return rxm.PreviousState }
@@ -122,11 +126,12 @@ func (rxm *LacpRxMachine) Stop() {
 // NewLacpRxMachine will create a new instance of the LacpRxMachine
 func NewLacpRxMachine(port *LaAggPort) *LacpRxMachine {
 	rxm := &LacpRxMachine{
-		p:                 port,
-		PreviousState:     LacpRxmStateNone,
-		RxmEvents:         make(chan utils.MachineEvent, 10),
-		RxmPktRxEvent:     make(chan LacpRxLacpPdu, 1000),
-		RxmLogEnableEvent: make(chan bool)}
+		p:                         port,
+		PreviousState:             LacpRxmStateNone,
+		RxmEvents:                 make(chan utils.MachineEvent, 10),
+		RxmPktRxEvent:             make(chan LacpRxLacpPdu, 1000),
+		RxmLogEnableEvent:         make(chan bool),
+		RxmErrDisableRecoverEvent: make(chan bool, 1)}
 
 	port.RxMachineFsm = rxm
 
@@ -243,6 +248,13 @@ func (rxm *LacpRxMachine) LacpRxMachineExpired(m fsm.Machine, data interface{})
 			E:   LacpMuxmEventNotPartnerSync,
 			Src: RxMachineModuleStr}
 	}
+	// partner info expired, adaptive rate moves back to fast, already
+	// on the rx machine so apply the timeout here
+	if p.laAutoRateChurn() {
+		p.LaPortLog(fmt.Sprintf("%s: partner expired moving to FAST rate", AutoRateModuleStr))
+		p.LaAutoRateTimeoutApply()
+	}
+
 	// Short timeout
 	//rxm.LacpRxmLog("Setting Partner Timeout Bit")
 	LacpStateSet(&p.PartnerOper.State, LacpStateTimeoutBit)
@@ -336,6 +348,9 @@ func (rxm *LacpRxMachine) LacpRxMachineCurrent(m fsm.Machine, data interface{})
 	// record the current packet State
 	rxm.recordPDU(lacpPduInfo)
 
+	// check the recorded partner info against the err-disable triggers
+	p.LaErrDisableCheckPartner()
+
 	//rxm.LacpRxmLog(fmt.Sprintf("Partner Oper %#v", p.PartnerOper))
 
 	// Current while should already be set to
@@ -386,11 +401,15 @@ func (rxm *LacpRxMachine) InformMachinesOfStateChanges() {
 		if LacpStateIsSet(p.PartnerOper.State, LacpStateSyncBit) {
 			if p.aggSelected == LacpAggSelected {
 				if p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateAttached ||
-					p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCAttached {
+					(p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCAttached &&
+						p.MicroBfdIsUp()) {
+					// RFC 7130 coupled member must not distribute until micro bfd is up
 					p.MuxMachineFsm.MuxmEvents <- utils.MachineEvent{
 						E:   LacpMuxmEventSelectedEqualSelectedAndPartnerSync,
 						Src: RxMachineModuleStr}
-				} else if p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCollecting {
+				} else if p.MuxMachineFsm.Machine.Curr.CurrentState() == LacpMuxmStateCollecting &&
+					p.MicroBfdIsUp() {
+					// RFC 7130 member must not distribute until micro bfd is up
 					p.MuxMachineFsm.MuxmEvents <- utils.MachineEvent{
 						E:   LacpMuxmEventSelectedEqualSelectedPartnerSyncCollecting,
 						Src: RxMachineModuleStr}
@@ -529,6 +548,15 @@ func (p *LaAggPort) LacpRxMachineMain() {
 
 			case event, ok := <-m.RxmEvents:
 				if ok {
+					// auto rate timeout change, the current while
+					// timer is owned by this machine
+					if event.E == LacpRxmEventAutoRateTimeout {
+						m.p.LaAutoRateTimeoutApply()
+						if event.ResponseChan != nil {
+							utils.SendResponse(RxMachineModuleStr, event.ResponseChan)
+						}
+						break
+					}
 					rv := m.Machine.ProcessEvent(event.Src, event.E, nil)
 					if rv == nil {
 						p := m.p
@@ -595,6 +623,11 @@ func (p *LaAggPort) LacpRxMachineMain() {
 			case ena := <-m.RxmLogEnableEvent:
 				m.Machine.Curr.EnableLogging(ena)
 
+			case <-m.RxmErrDisableRecoverEvent:
+				// not a state transition, handled here so that recovery
+				// is serialized with the err-disable triggers from rx
+				m.p.LaErrDisableRecover()
+
 			}
 		}
 	}(rxm)
@@ -0,0 +1,159 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// slowprotocol.go - the slow protocol mac capture of an interface is shared
+// by lacp and the other slow protocols, ie 802.3 Annex 57A EFM OAM.  Frames
+// of a registered subtype are dispatched from the lacp rx path.
+package lacp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// LaSlowProtocolRxCb is called with a received slow protocol frame of the
+// registered subtype
+type LaSlowProtocolRxCb func(intfRef string, packet gopacket.Packet)
+
+type laSlowProtocolRx struct {
+	pId    uint16
+	handle *pcap.Handle
+	users  int
+}
+
+var slowProtocolRxLock sync.RWMutex
+var slowProtocolRxCbDb = make(map[uint8]LaSlowProtocolRxCb)
+var slowProtocolRxDb = make(map[string]*laSlowProtocolRx)
+
+func RegisterLaSlowProtocolRxCb(subtype uint8, cb LaSlowProtocolRxCb) {
+	slowProtocolRxLock.Lock()
+	slowProtocolRxCbDb[subtype] = cb
+	slowProtocolRxLock.Unlock()
+}
+
+func DeRegisterLaSlowProtocolRxCb(subtype uint8) {
+	slowProtocolRxLock.Lock()
+	delete(slowProtocolRxCbDb, subtype)
+	slowProtocolRxLock.Unlock()
+}
+
+// LaSlowProtocolRxRegistered returns true when another protocol has
+// registered for the slow protocol subtype
+func LaSlowProtocolRxRegistered(subtype uint8) bool {
+	slowProtocolRxLock.RLock()
+	defer slowProtocolRxLock.RUnlock()
+	_, ok := slowProtocolRxCbDb[subtype]
+	return ok
+}
+
+// LaSlowProtocolRxTxCreate returns the slow protocol capture of the interface,
+// the first user opens the handle and starts the rx routine, later users
+// share it
+func LaSlowProtocolRxTxCreate(intfRef string, pId uint16) (*pcap.Handle, error) {
+	slowProtocolRxLock.Lock()
+	defer slowProtocolRxLock.Unlock()
+
+	if rx, ok := slowProtocolRxDb[intfRef]; ok {
+		rx.users++
+		return rx.handle, nil
+	}
+
+	handle, err := pcap.OpenLive(intfRef, 65536, true, 50*time.Millisecond)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintln("Error creating pcap OpenLive handle for intf", intfRef, err))
+	}
+	filter := fmt.Sprintf(`ether dst 01:80:C2:00:00:02`)
+	err = handle.SetBPFFilter(filter)
+	if err != nil {
+		handle.Close()
+		return nil, errors.New(fmt.Sprintln("Unable to set bpf filter to pcap handler", intfRef, err))
+	}
+	slowProtocolRxDb[intfRef] = &laSlowProtocolRx{
+		pId:    pId,
+		handle: handle,
+		users:  1,
+	}
+
+	src := gopacket.NewPacketSource(handle, layers.LayerTypeEthernet)
+	// start rx routine, it ends when the handle is closed
+	LaRxMain(pId, src.Packets())
+	return handle, nil
+}
+
+// LaSlowProtocolRxTxDelete releases the slow protocol capture of the
+// interface, the handle is closed once the last user is gone
+func LaSlowProtocolRxTxDelete(intfRef string) {
+	slowProtocolRxLock.Lock()
+	defer slowProtocolRxLock.Unlock()
+
+	if rx, ok := slowProtocolRxDb[intfRef]; ok {
+		rx.users--
+		if rx.users == 0 {
+			rx.handle.Close()
+			delete(slowProtocolRxDb, intfRef)
+		}
+	}
+}
+
+// laSlowProtocolRxDispatch hands a slow protocol frame which is not lacp or
+// marker to the protocol registered for its subtype
+func laSlowProtocolRxDispatch(pId uint16, packet gopacket.Packet) bool {
+	slowProtocolLayer := packet.Layer(layers.LayerTypeSlowProtocol)
+	if slowProtocolLayer == nil {
+		return false
+	}
+	slow := slowProtocolLayer.(*layers.SlowProtocol)
+
+	var cb LaSlowProtocolRxCb
+	intfRef := ""
+	slowProtocolRxLock.RLock()
+	cb = slowProtocolRxCbDb[uint8(slow.SubType)]
+	for name, rx := range slowProtocolRxDb {
+		if rx.pId == pId {
+			intfRef = name
+			break
+		}
+	}
+	slowProtocolRxLock.RUnlock()
+
+	if cb == nil {
+		return false
+	}
+	if intfRef == "" {
+		// test channels are not registered with a capture, the
+		// lacp port knows its interface
+		var p *LaAggPort
+		if !LaFindPortById(pId, &p) {
+			return false
+		}
+		intfRef = p.IntfNum
+	}
+	cb(intfRef, packet)
+	return true
+}
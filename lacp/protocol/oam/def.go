@@ -0,0 +1,177 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// def.go - constants shared by the 802.3ah (clause 57) EFM OAM module
+package oam
+
+import (
+	"net"
+	"time"
+)
+
+// OAM rides the slow protocol ethertype with its own subtype
+const (
+	OamSlowProtocolEtherType = 0x8809
+	OamSlowProtocolSubType   = 0x03
+)
+
+var OamSlowProtocolMac = net.HardwareAddr{0x01, 0x80, 0xC2, 0x00, 0x00, 0x02}
+
+// 802.3 57.4.2.1 OAMPDU codes
+const (
+	OamCodeInformation     uint8 = 0x00
+	OamCodeEventNotify     uint8 = 0x01
+	OamCodeVarRequest      uint8 = 0x02
+	OamCodeVarResponse     uint8 = 0x03
+	OamCodeLoopbackControl uint8 = 0x04
+	OamCodeOrgSpecific     uint8 = 0xFE
+)
+
+// 802.3 57.4.2.1 OAMPDU flags field
+const (
+	OamFlagLinkFault        uint16 = 0x0001
+	OamFlagDyingGasp        uint16 = 0x0002
+	OamFlagCriticalEvent    uint16 = 0x0004
+	OamFlagLocalEvaluating  uint16 = 0x0008
+	OamFlagLocalStable      uint16 = 0x0010
+	OamFlagRemoteEvaluating uint16 = 0x0020
+	OamFlagRemoteStable     uint16 = 0x0040
+)
+
+// 802.3 57.5.2 Information TLV types
+const (
+	OamInfoTlvEndOfTlv    uint8 = 0x00
+	OamInfoTlvLocalInfo   uint8 = 0x01
+	OamInfoTlvRemoteInfo  uint8 = 0x02
+	OamInfoTlvOrgSpecific uint8 = 0xFE
+)
+
+// 802.3 57.5.3 Link Event TLV types
+const (
+	OamEventTlvEndOfTlv                uint8 = 0x00
+	OamEventTlvErroredSymbolPeriod     uint8 = 0x01
+	OamEventTlvErroredFrame            uint8 = 0x02
+	OamEventTlvErroredFramePeriod      uint8 = 0x03
+	OamEventTlvErroredFrameSecsSummary uint8 = 0x04
+	OamEventTlvOrgSpecific             uint8 = 0xFE
+)
+
+// TLV lengths, type and length octets included
+const (
+	OamInfoTlvLength                        = 16
+	OamEventTlvErroredSymbolPeriodLen       = 40
+	OamEventTlvErroredFrameLen              = 26
+	OamEventTlvErroredFramePeriodLen        = 28
+	OamEventTlvErroredFrameSecsSumLen       = 18
+	OamPduHeaderLength                      = 3  // flags + code
+	OamPduMinDataLength                     = 42 // 64 byte frame minimum
+	OamPduMaxSize                           = 1518
+	OamPduMinSize                           = 64
+	OamVersion                        uint8 = 0x01
+)
+
+// 802.3 57.5.2.2 OAM Configuration field
+const (
+	OamConfigModeActive       uint8 = 0x01
+	OamConfigUnidirectional   uint8 = 0x02
+	OamConfigLoopbackSupport  uint8 = 0x04
+	OamConfigLinkEvents       uint8 = 0x08
+	OamConfigVariableRetrieve uint8 = 0x10
+)
+
+// 802.3 57.5.2.1 State field, parser action bits 0-1 and
+// multiplexer action bit 2
+const (
+	OamStateParserForward  uint8 = 0x00
+	OamStateParserLoopback uint8 = 0x01
+	OamStateParserDiscard  uint8 = 0x02
+	OamStateParserMask     uint8 = 0x03
+	OamStateMuxDiscard     uint8 = 0x04
+)
+
+// 802.3 57.5.3.4 Loopback Control commands
+const (
+	OamLoopbackCmdEnable  uint8 = 0x01
+	OamLoopbackCmdDisable uint8 = 0x02
+)
+
+// OAM mode
+const (
+	OamModePassive = iota
+	OamModeActive
+)
+
+// local_pdu values 802.3 57.3.1.2
+const (
+	OamLocalPduLfInfo = iota + 1
+	OamLocalPduRxInfo
+	OamLocalPduInfo
+	OamLocalPduAny
+)
+
+var OamLocalPduStrMap = map[int]string{
+	OamLocalPduLfInfo: "LF_INFO",
+	OamLocalPduRxInfo: "RX_INFO",
+	OamLocalPduInfo:   "INFO",
+	OamLocalPduAny:    "ANY",
+}
+
+// remote loopback status of a port
+const (
+	OamLoopbackNone = iota
+	// local side sent enable and waits for the peer to loop
+	OamLoopbackInitiating
+	// peer is looping frames which were sent by this system
+	OamLoopbackLocal
+	// this system is looping frames back towards the peer
+	OamLoopbackRemote
+	// local side sent disable and waits for the peer to stop
+	OamLoopbackTerminating
+)
+
+var OamLoopbackStrMap = map[int]string{
+	OamLoopbackNone:        "None",
+	OamLoopbackInitiating:  "Initiating",
+	OamLoopbackLocal:       "LocalLoopback",
+	OamLoopbackRemote:      "RemoteLoopback",
+	OamLoopbackTerminating: "Terminating",
+}
+
+// 802.3 57.3.2 timers and limits
+const (
+	OamPduTimerInterval        = time.Second
+	OamLocalLostLinkTimeout    = 5 * time.Second
+	OamMaxPduPerSecond         = 10
+	OamDyingGaspTxCount        = 3
+	OamLinkMonitorPollInterval = 100 * time.Millisecond
+)
+
+// Link monitor defaults 802.3 30.3.6.1.34 - 30.3.6.1.41
+const (
+	OamDefaultErrSymPeriodWindow      uint64 = 125000000 // one second of 1000BASE-X symbols
+	OamDefaultErrSymPeriodThreshold   uint64 = 1
+	OamDefaultErrFrameWindow          uint16 = 10 // 100ms units
+	OamDefaultErrFrameThreshold       uint32 = 1
+	OamDefaultErrFramePeriodWindow    uint32 = 1488095 // one second of min size frames at 1G
+	OamDefaultErrFramePeriodThreshold uint32 = 1
+)
@@ -0,0 +1,316 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// discovery.go - 802.3 Figure 57-5 OAM Discovery state machine
+package oam
+
+import (
+	"l2/lacp/protocol/utils"
+	"strconv"
+	"strings"
+	"utils/fsm"
+)
+
+const OamDiscoveryModuleStr = "OAM Discovery"
+
+// discovery states
+const (
+	OamDiscoveryStateNone = iota + 1
+	OamDiscoveryStateFault
+	OamDiscoveryStateActiveSendLocal
+	OamDiscoveryStatePassiveWait
+	OamDiscoveryStateSendLocalRemote
+	OamDiscoveryStateSendLocalRemoteOk
+	OamDiscoveryStateSendAny
+)
+
+var OamDiscoveryStateStrMap map[fsm.State]string
+
+func OamDiscoveryMachineStrStateMapCreate() {
+	OamDiscoveryStateStrMap = make(map[fsm.State]string)
+	OamDiscoveryStateStrMap[OamDiscoveryStateNone] = "None"
+	OamDiscoveryStateStrMap[OamDiscoveryStateFault] = "Fault"
+	OamDiscoveryStateStrMap[OamDiscoveryStateActiveSendLocal] = "ActiveSendLocal"
+	OamDiscoveryStateStrMap[OamDiscoveryStatePassiveWait] = "PassiveWait"
+	OamDiscoveryStateStrMap[OamDiscoveryStateSendLocalRemote] = "SendLocalRemote"
+	OamDiscoveryStateStrMap[OamDiscoveryStateSendLocalRemoteOk] = "SendLocalRemoteOk"
+	OamDiscoveryStateStrMap[OamDiscoveryStateSendAny] = "SendAny"
+}
+
+// discovery events, conditions of Figure 57-5 are evaluated by
+// oamDiscoveryEvaluate and turned into these events
+const (
+	OamDiscoveryEventBegin = iota + 1
+	OamDiscoveryEventLinkFail
+	OamDiscoveryEventLocalLostLinkTimerDone
+	OamDiscoveryEventLinkOkActive
+	OamDiscoveryEventLinkOkPassive
+	OamDiscoveryEventRemoteStateValid
+	OamDiscoveryEventLocalSatisfied
+	OamDiscoveryEventLocalUnsatisfied
+	OamDiscoveryEventRemoteStable
+	OamDiscoveryEventRemoteUnstable
+)
+
+// OamDiscoveryMachine holds FSM and current State
+// and event channels for State transitions
+type OamDiscoveryMachine struct {
+	// for debugging
+	PreviousState fsm.State
+
+	Machine *fsm.Machine
+
+	p *OamPort
+
+	// machine specific events
+	OamDiscoveryEvents chan utils.MachineEvent
+}
+
+func (dm *OamDiscoveryMachine) PrevState() fsm.State { return dm.PreviousState }
+
+// PrevStateSet will set the previous State
+func (dm *OamDiscoveryMachine) PrevStateSet(s fsm.State) { dm.PreviousState = s }
+
+func (dm *OamDiscoveryMachine) OamDiscoveryLog(msg string) {
+	dm.p.OamPortLog(msg)
+}
+
+// A helpful function that lets us apply arbitrary rulesets to this
+// instances State machine without reallocating the machine.
+func (dm *OamDiscoveryMachine) Apply(r *fsm.Ruleset) *fsm.Machine {
+	if dm.Machine == nil {
+		dm.Machine = &fsm.Machine{}
+	}
+
+	// Assign the ruleset to be used for this machine
+	dm.Machine.Rules = r
+	dm.Machine.Curr = &utils.StateEvent{
+		StrStateMap: OamDiscoveryStateStrMap,
+		LogEna:      dm.p.logEna,
+		Logger:      dm.OamDiscoveryLog,
+		Owner:       OamDiscoveryModuleStr,
+	}
+
+	return dm.Machine
+}
+
+// NewOamDiscoveryMachine will create a new instance of the OamDiscoveryMachine
+func NewOamDiscoveryMachine(port *OamPort) *OamDiscoveryMachine {
+	dm := &OamDiscoveryMachine{
+		p:                  port,
+		PreviousState:      OamDiscoveryStateNone,
+		OamDiscoveryEvents: make(chan utils.MachineEvent, 10),
+	}
+
+	port.DiscoveryFsm = dm
+
+	return dm
+}
+
+// OamDiscoveryMachineFault 802.3 Figure 57-5 FAULT
+func (dm *OamDiscoveryMachine) OamDiscoveryMachineFault(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	if p.localLinkUp {
+		p.localPdu = OamLocalPduRxInfo
+	} else {
+		p.localPdu = OamLocalPduLfInfo
+	}
+	p.localStable = false
+	p.localSatisfied = false
+	p.remoteStable = false
+	p.remoteStateValid = false
+	p.localLostLinkTimer.Stop()
+
+	// a peer which is no longer seen can't keep us looping
+	if p.loopback != OamLoopbackNone {
+		p.oamLoopbackStateSet(OamLoopbackNone)
+	}
+	return OamDiscoveryStateFault
+}
+
+// OamDiscoveryMachineActiveSendLocal 802.3 Figure 57-5 ACTIVE_SEND_LOCAL
+func (dm *OamDiscoveryMachine) OamDiscoveryMachineActiveSendLocal(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	p.localPdu = OamLocalPduInfo
+	// kick off discovery without waiting for the pdu timer
+	p.oamTxInformation(false)
+	return OamDiscoveryStateActiveSendLocal
+}
+
+// OamDiscoveryMachinePassiveWait 802.3 Figure 57-5 PASSIVE_WAIT
+func (dm *OamDiscoveryMachine) OamDiscoveryMachinePassiveWait(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	p.localPdu = OamLocalPduRxInfo
+	return OamDiscoveryStatePassiveWait
+}
+
+// OamDiscoveryMachineSendLocalRemote 802.3 Figure 57-5 SEND_LOCAL_REMOTE
+func (dm *OamDiscoveryMachine) OamDiscoveryMachineSendLocalRemote(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	p.localPdu = OamLocalPduInfo
+	p.localStable = false
+	p.oamTxInformation(false)
+	return OamDiscoveryStateSendLocalRemote
+}
+
+// OamDiscoveryMachineSendLocalRemoteOk 802.3 Figure 57-5 SEND_LOCAL_REMOTE_OK
+func (dm *OamDiscoveryMachine) OamDiscoveryMachineSendLocalRemoteOk(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	p.localPdu = OamLocalPduInfo
+	p.localStable = true
+	p.oamTxInformation(false)
+	return OamDiscoveryStateSendLocalRemoteOk
+}
+
+// OamDiscoveryMachineSendAny 802.3 Figure 57-5 SEND_ANY
+func (dm *OamDiscoveryMachine) OamDiscoveryMachineSendAny(m fsm.Machine, data interface{}) fsm.State {
+	p := dm.p
+	p.localPdu = OamLocalPduAny
+	p.localStable = true
+	p.OamPortLog("Discovery complete")
+	return OamDiscoveryStateSendAny
+}
+
+func OamDiscoveryMachineFSMBuild(p *OamPort) *OamDiscoveryMachine {
+
+	OamDiscoveryMachineStrStateMapCreate()
+
+	rules := fsm.Ruleset{}
+
+	dm := NewOamDiscoveryMachine(p)
+
+	// BEGIN, link failure or lost peer -> FAULT
+	for _, s := range []fsm.State{
+		OamDiscoveryStateNone,
+		OamDiscoveryStateFault,
+		OamDiscoveryStateActiveSendLocal,
+		OamDiscoveryStatePassiveWait,
+		OamDiscoveryStateSendLocalRemote,
+		OamDiscoveryStateSendLocalRemoteOk,
+		OamDiscoveryStateSendAny,
+	} {
+		rules.AddRule(s, OamDiscoveryEventBegin, dm.OamDiscoveryMachineFault)
+		rules.AddRule(s, OamDiscoveryEventLinkFail, dm.OamDiscoveryMachineFault)
+		rules.AddRule(s, OamDiscoveryEventLocalLostLinkTimerDone, dm.OamDiscoveryMachineFault)
+	}
+
+	// FAULT -> ACTIVE_SEND_LOCAL / PASSIVE_WAIT
+	rules.AddRule(OamDiscoveryStateFault, OamDiscoveryEventLinkOkActive, dm.OamDiscoveryMachineActiveSendLocal)
+	rules.AddRule(OamDiscoveryStateFault, OamDiscoveryEventLinkOkPassive, dm.OamDiscoveryMachinePassiveWait)
+
+	// remote_state_valid -> SEND_LOCAL_REMOTE
+	rules.AddRule(OamDiscoveryStateActiveSendLocal, OamDiscoveryEventRemoteStateValid, dm.OamDiscoveryMachineSendLocalRemote)
+	rules.AddRule(OamDiscoveryStatePassiveWait, OamDiscoveryEventRemoteStateValid, dm.OamDiscoveryMachineSendLocalRemote)
+
+	// local_satisfied -> SEND_LOCAL_REMOTE_OK
+	rules.AddRule(OamDiscoveryStateSendLocalRemote, OamDiscoveryEventLocalSatisfied, dm.OamDiscoveryMachineSendLocalRemoteOk)
+
+	// !local_satisfied -> SEND_LOCAL_REMOTE
+	rules.AddRule(OamDiscoveryStateSendLocalRemoteOk, OamDiscoveryEventLocalUnsatisfied, dm.OamDiscoveryMachineSendLocalRemote)
+	rules.AddRule(OamDiscoveryStateSendAny, OamDiscoveryEventLocalUnsatisfied, dm.OamDiscoveryMachineSendLocalRemote)
+
+	// local_satisfied && remote_stable -> SEND_ANY
+	rules.AddRule(OamDiscoveryStateSendLocalRemoteOk, OamDiscoveryEventRemoteStable, dm.OamDiscoveryMachineSendAny)
+
+	// local_satisfied && !remote_stable -> SEND_LOCAL_REMOTE_OK
+	rules.AddRule(OamDiscoveryStateSendAny, OamDiscoveryEventRemoteUnstable, dm.OamDiscoveryMachineSendLocalRemoteOk)
+
+	// Create a new FSM and apply the rules
+	dm.Apply(&rules)
+
+	return dm
+}
+
+func (p *OamPort) oamDiscoveryProcessEvent(src string, e fsm.Event) {
+	dm := p.DiscoveryFsm
+	rv := dm.Machine.ProcessEvent(src, e, nil)
+	if rv != nil {
+		p.OamPortLog(strings.Join([]string{error.Error(rv), src, OamDiscoveryStateStrMap[dm.Machine.Curr.CurrentState()], strconv.Itoa(int(e))}, ":"))
+	}
+}
+
+// oamDiscoveryEvaluate evaluates the transition conditions of Figure 57-5
+// against the current variables until the machine settles
+func (p *OamPort) oamDiscoveryEvaluate() {
+	dm := p.DiscoveryFsm
+	for i := 0; i < 8; i++ {
+		var e fsm.Event
+		state := dm.Machine.Curr.CurrentState()
+		if !p.localLinkUp && state != OamDiscoveryStateFault {
+			e = OamDiscoveryEventLinkFail
+		} else {
+			switch state {
+			case OamDiscoveryStateFault:
+				if p.localLinkUp {
+					if p.Config.Mode == OamModeActive {
+						e = OamDiscoveryEventLinkOkActive
+					} else {
+						e = OamDiscoveryEventLinkOkPassive
+					}
+				}
+			case OamDiscoveryStateActiveSendLocal, OamDiscoveryStatePassiveWait:
+				if p.remoteStateValid {
+					e = OamDiscoveryEventRemoteStateValid
+				}
+			case OamDiscoveryStateSendLocalRemote:
+				if p.localSatisfied {
+					e = OamDiscoveryEventLocalSatisfied
+				}
+			case OamDiscoveryStateSendLocalRemoteOk:
+				if !p.localSatisfied {
+					e = OamDiscoveryEventLocalUnsatisfied
+				} else if p.remoteStable {
+					e = OamDiscoveryEventRemoteStable
+				}
+			case OamDiscoveryStateSendAny:
+				if !p.localSatisfied {
+					e = OamDiscoveryEventLocalUnsatisfied
+				} else if !p.remoteStable {
+					e = OamDiscoveryEventRemoteUnstable
+				}
+			}
+		}
+		if e == 0 {
+			return
+		}
+		p.oamDiscoveryProcessEvent(OamDiscoveryModuleStr, e)
+	}
+}
+
+// oamDiscoveryLocalSatisfiedEval is the local policy deciding whether the
+// peer's advertised configuration is acceptable 802.3 57.3.1.2
+func (p *OamPort) oamDiscoveryLocalSatisfiedEval() bool {
+	remote := &p.RemoteInfo
+	if remote.Version != OamVersion {
+		return false
+	}
+	// at least one side must be active
+	if p.Config.Mode == OamModePassive &&
+		remote.Config&OamConfigModeActive == 0 {
+		return false
+	}
+	if remote.PduConfig < OamPduMinSize {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,206 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// linkmon.go - 802.3 57.2.10.1 link monitoring, errored symbol period,
+// errored frame and errored frame period events
+package oam
+
+import (
+	"fmt"
+	"io/ioutil"
+	"l2/lacp/protocol/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OamLinkCounters are the raw counters the link monitor windows are
+// evaluated against
+type OamLinkCounters struct {
+	Symbols      uint64
+	SymbolErrors uint64
+	Frames       uint64
+	FrameErrors  uint64
+}
+
+// OamLinkCountersGet reads the receive counters of a port, replaceable for
+// platforms which expose PCS counters and for tests
+var OamLinkCountersGet = oamLinkCountersGetLinux
+
+// oamLinkCountersGetLinux uses the kernel netdev statistics.  Linux does
+// not expose PCS symbol counters so symbols are approximated as ten per
+// received octet (8b/10b) and symbol errors by alignment errors.
+func oamLinkCountersGetLinux(p *OamPort) (OamLinkCounters, error) {
+	var c OamLinkCounters
+	read := func(name string) (uint64, error) {
+		data, err := ioutil.ReadFile("/sys/class/net/" + p.IntfRef + "/statistics/" + name)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	}
+	octets, err := read("rx_bytes")
+	if err != nil {
+		return c, err
+	}
+	c.Symbols = octets * 10
+	if c.SymbolErrors, err = read("rx_frame_errors"); err != nil {
+		return c, err
+	}
+	if c.Frames, err = read("rx_packets"); err != nil {
+		return c, err
+	}
+	if c.FrameErrors, err = read("rx_crc_errors"); err != nil {
+		return c, err
+	}
+	// errored frames are not part of rx_packets
+	c.Frames += c.FrameErrors
+	return c, nil
+}
+
+// OamPortLinkStatusGet returns the oper state of the port, replaceable
+// for tests
+var OamPortLinkStatusGet = func(p *OamPort) bool {
+	for _, client := range utils.GetAsicDPluginList() {
+		return client.GetPortLinkStatus(p.IfIndex)
+	}
+	return false
+}
+
+type oamLinkMonitor struct {
+	started bool
+	// counters at the start of each window
+	symWindowStart    OamLinkCounters
+	frameWindowStart  OamLinkCounters
+	frameWindowTime   time.Time
+	periodWindowStart OamLinkCounters
+	// counters when monitoring began, running totals are relative to it
+	base         OamLinkCounters
+	symEvents    uint32
+	frameEvents  uint32
+	periodEvents uint32
+}
+
+func (m *oamLinkMonitor) reset() {
+	*m = oamLinkMonitor{}
+}
+
+// oamLinkMonitorPoll is called periodically from the port event loop
+func (p *OamPort) oamLinkMonitorPoll() {
+	if p.localPdu != OamLocalPduAny {
+		return
+	}
+	c, err := OamLinkCountersGet(p)
+	if err != nil {
+		return
+	}
+	m := &p.monitor
+	now := time.Now()
+	if !m.started ||
+		c.Symbols < m.base.Symbols ||
+		c.Frames < m.base.Frames {
+		// first sample or counters were cleared
+		m.reset()
+		m.started = true
+		m.base = c
+		m.symWindowStart = c
+		m.frameWindowStart = c
+		m.periodWindowStart = c
+		m.frameWindowTime = now
+		return
+	}
+
+	var tlvs []OamEventTlv
+	cfg := &p.Config
+
+	// 802.3 57.5.3.1 errored symbol period
+	if c.Symbols-m.symWindowStart.Symbols >= cfg.ErrSymPeriodWindow {
+		errs := c.SymbolErrors - m.symWindowStart.SymbolErrors
+		if errs >= cfg.ErrSymPeriodThreshold {
+			m.symEvents++
+			tlvs = append(tlvs, OamEventTlv{
+				Type:              OamEventTlvErroredSymbolPeriod,
+				Timestamp:         p.oamEventTimestamp(),
+				Window:            cfg.ErrSymPeriodWindow,
+				Threshold:         cfg.ErrSymPeriodThreshold,
+				Errors:            errs,
+				ErrorRunningTotal: c.SymbolErrors - m.base.SymbolErrors,
+				EventRunningTotal: m.symEvents,
+			})
+			p.Counters.ErroredSymbolEventTx++
+		}
+		m.symWindowStart = c
+	}
+
+	// 802.3 57.5.3.2 errored frame, window in 100ms units
+	if now.Sub(m.frameWindowTime) >= time.Duration(cfg.ErrFrameWindow)*100*time.Millisecond {
+		errs := c.FrameErrors - m.frameWindowStart.FrameErrors
+		if errs >= uint64(cfg.ErrFrameThreshold) {
+			m.frameEvents++
+			tlvs = append(tlvs, OamEventTlv{
+				Type:              OamEventTlvErroredFrame,
+				Timestamp:         p.oamEventTimestamp(),
+				Window:            uint64(cfg.ErrFrameWindow),
+				Threshold:         uint64(cfg.ErrFrameThreshold),
+				Errors:            errs,
+				ErrorRunningTotal: c.FrameErrors - m.base.FrameErrors,
+				EventRunningTotal: m.frameEvents,
+			})
+			p.Counters.ErroredFrameEventTx++
+		}
+		m.frameWindowStart = c
+		m.frameWindowTime = now
+	}
+
+	// 802.3 57.5.3.3 errored frame period, window in frames
+	if c.Frames-m.periodWindowStart.Frames >= uint64(cfg.ErrFramePeriodWindow) {
+		errs := c.FrameErrors - m.periodWindowStart.FrameErrors
+		if errs >= uint64(cfg.ErrFramePeriodThreshold) {
+			m.periodEvents++
+			tlvs = append(tlvs, OamEventTlv{
+				Type:              OamEventTlvErroredFramePeriod,
+				Timestamp:         p.oamEventTimestamp(),
+				Window:            uint64(cfg.ErrFramePeriodWindow),
+				Threshold:         uint64(cfg.ErrFramePeriodThreshold),
+				Errors:            errs,
+				ErrorRunningTotal: c.FrameErrors - m.base.FrameErrors,
+				EventRunningTotal: m.periodEvents,
+			})
+			p.Counters.ErroredPeriodEventTx++
+		}
+		m.periodWindowStart = c
+	}
+
+	if len(tlvs) > 0 {
+		p.OamPortLog(fmt.Sprintf("Local link events %d", len(tlvs)))
+		if p.Config.LinkEvents {
+			p.txEventSeq++
+			p.oamTxPdu(&OamPdu{
+				Flags:          p.oamTxFlags(),
+				Code:           OamCodeEventNotify,
+				SequenceNumber: p.txEventSeq,
+				Events:         tlvs,
+			}, false)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// loopback.go - 802.3 57.2.11 OAM remote loopback
+package oam
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// OamLoopbackDataplaneSet programs the parser/multiplexer actions of the
+// port.  The default reflects frames in software through a capture which is
+// only open while in remote loopback, a platform with hardware loopback can
+// replace it.
+var OamLoopbackDataplaneSet = oamLoopbackDataplaneSetPcap
+
+func oamLoopbackDataplaneSetPcap(p *OamPort, parser uint8, muxDiscard bool) {
+	reflect := parser == OamStateParserLoopback
+	if reflect == p.loopbackReflect {
+		return
+	}
+	p.loopbackReflect = reflect
+	if !reflect {
+		if p.reflectHandle != nil {
+			p.reflectHandle.Close()
+			p.reflectHandle = nil
+		}
+		return
+	}
+	if p.handle == nil {
+		return
+	}
+
+	handle, err := pcap.OpenLive(p.IntfRef, 65536, true, 50*time.Millisecond)
+	if err != nil {
+		p.OamPortLog(fmt.Sprintln("Error creating pcap OpenLive loopback handle", err))
+		return
+	}
+	// every frame from the peer has to come back to it, slow protocol
+	// frames arrive through the shared lacp capture
+	if err := handle.SetBPFFilter(oamLoopbackBpfFilter); err != nil {
+		p.OamPortLog(fmt.Sprintln("Unable to set bpf filter to pcap handler", err))
+	}
+	p.reflectHandle = handle
+	src := gopacket.NewPacketSource(handle, layers.LayerTypeEthernet)
+	go func(ifindex int32, rx chan gopacket.Packet) {
+		for packet := range rx {
+			oamLoopbackReflect(ifindex, packet)
+		}
+	}(p.IfIndex, src.Packets())
+}
+
+// oamLoopbackStateSet moves the local loopback state and derives the
+// parser and multiplexer actions advertised in the Local Information TLV
+func (p *OamPort) oamLoopbackStateSet(state int) {
+	prev := p.loopback
+	p.loopback = state
+	switch state {
+	case OamLoopbackNone:
+		p.localParser = OamStateParserForward
+		p.localMuxDiscard = false
+	case OamLoopbackInitiating, OamLoopbackTerminating:
+		p.localParser = OamStateParserDiscard
+		p.localMuxDiscard = true
+	case OamLoopbackLocal:
+		// test frames go out, looped frames are dropped on return
+		p.localParser = OamStateParserDiscard
+		p.localMuxDiscard = false
+	case OamLoopbackRemote:
+		p.localParser = OamStateParserLoopback
+		p.localMuxDiscard = true
+	}
+	OamLoopbackDataplaneSet(p, p.localParser, p.localMuxDiscard)
+	if prev != state {
+		p.OamPortLog(fmt.Sprintf("Loopback %s -> %s", OamLoopbackStrMap[prev], OamLoopbackStrMap[state]))
+		// state field of the local information changed
+		p.localRevision++
+	}
+}
+
+// OamPortRemoteLoopbackSet asks the peer to start or stop looping frames,
+// the peer must have completed discovery and advertised loopback support
+func OamPortRemoteLoopbackSet(ifindex int32, enable bool) error {
+	var p *OamPort
+	if !OamFindPortByIfIndex(ifindex, &p) {
+		return errors.New(fmt.Sprintf("ERROR OAM not configured on ifindex %d", ifindex))
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if enable {
+		if p.localPdu != OamLocalPduAny {
+			return errors.New(fmt.Sprintf("ERROR OAM discovery not complete on %s", p.IntfRef))
+		}
+		if p.RemoteInfo.Config&OamConfigLoopbackSupport == 0 {
+			return errors.New(fmt.Sprintf("ERROR OAM peer on %s does not support loopback", p.IntfRef))
+		}
+		if p.loopback == OamLoopbackRemote {
+			return errors.New(fmt.Sprintf("ERROR OAM %s is being looped by peer", p.IntfRef))
+		}
+		if p.loopback == OamLoopbackInitiating || p.loopback == OamLoopbackLocal {
+			return nil
+		}
+		p.oamLoopbackStateSet(OamLoopbackInitiating)
+		p.oamTxPdu(&OamPdu{
+			Flags:       p.oamTxFlags(),
+			Code:        OamCodeLoopbackControl,
+			LoopbackCmd: OamLoopbackCmdEnable,
+		}, false)
+	} else {
+		if p.loopback != OamLoopbackInitiating && p.loopback != OamLoopbackLocal {
+			return nil
+		}
+		if p.localPdu != OamLocalPduAny {
+			p.oamLoopbackStateSet(OamLoopbackNone)
+			return nil
+		}
+		p.oamLoopbackStateSet(OamLoopbackTerminating)
+		p.oamTxPdu(&OamPdu{
+			Flags:       p.oamTxFlags(),
+			Code:        OamCodeLoopbackControl,
+			LoopbackCmd: OamLoopbackCmdDisable,
+		}, false)
+	}
+	return nil
+}
+
+// oamLoopbackControlRx handles a Loopback Control OAMPDU from the peer
+func (p *OamPort) oamLoopbackControlRx(cmd uint8) {
+	if !p.Config.LoopbackSupport {
+		p.Counters.UnsupportedCodesRx++
+		return
+	}
+	switch cmd {
+	case OamLoopbackCmdEnable:
+		if p.loopback == OamLoopbackInitiating || p.loopback == OamLoopbackLocal {
+			// 802.3 57.2.11.1 both sides initiated, the higher source
+			// address yields
+			if p.Mac != nil && p.RemoteMac != nil &&
+				bytes.Compare(p.Mac, p.RemoteMac) < 0 {
+				return
+			}
+		}
+		p.oamLoopbackStateSet(OamLoopbackRemote)
+	case OamLoopbackCmdDisable:
+		if p.loopback != OamLoopbackRemote {
+			return
+		}
+		p.oamLoopbackStateSet(OamLoopbackNone)
+	default:
+		p.Counters.IllegalRx++
+		return
+	}
+	// acknowledge with the new state
+	p.oamTxInformation(false)
+}
+
+// oamLoopbackRemoteStateRx tracks the peer state while this side drives
+// a loopback test
+func (p *OamPort) oamLoopbackRemoteStateRx(state uint8) {
+	parser := state & OamStateParserMask
+	muxDiscard := state&OamStateMuxDiscard != 0
+	switch p.loopback {
+	case OamLoopbackInitiating:
+		if parser == OamStateParserLoopback && muxDiscard {
+			p.oamLoopbackStateSet(OamLoopbackLocal)
+		}
+	case OamLoopbackTerminating:
+		if parser == OamStateParserForward && !muxDiscard {
+			p.oamLoopbackStateSet(OamLoopbackNone)
+		}
+	case OamLoopbackLocal:
+		if parser != OamStateParserLoopback {
+			// peer left loopback on its own
+			p.oamLoopbackStateSet(OamLoopbackNone)
+		}
+	}
+}
+
+// oamLoopbackReflect returns a non OAM frame to the peer while the port is
+// in remote loopback
+func oamLoopbackReflect(ifindex int32, packet gopacket.Packet) {
+	var p *OamPort
+	if OamFindPortByIfIndex(ifindex, &p) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.loopbackReflect && p.reflectHandle != nil {
+			if err := p.reflectHandle.WritePacketData(packet.Data()); err != nil {
+				p.OamPortLog(fmt.Sprintln("Loopback reflect failed", err))
+			}
+		}
+	}
+}
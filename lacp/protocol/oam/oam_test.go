@@ -0,0 +1,345 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// oam_test.go
+package oam
+
+import (
+	"l2/lacp/protocol/utils"
+	"sync"
+	"testing"
+	"time"
+	"utils/fsm"
+	"utils/logging"
+
+	"github.com/google/gopacket"
+)
+
+var testCountersLock sync.Mutex
+var testCounters map[int32]*OamLinkCounters
+
+func OnlyForTestSetup() {
+	logger, _ := logging.NewLogger("lacpd", "TEST", false)
+	utils.SetLaLogger(logger)
+
+	testCounters = make(map[int32]*OamLinkCounters)
+	OamPortLinkStatusGet = func(p *OamPort) bool { return true }
+	OamLinkCountersGet = func(p *OamPort) (OamLinkCounters, error) {
+		testCountersLock.Lock()
+		defer testCountersLock.Unlock()
+		if c, ok := testCounters[p.IfIndex]; ok {
+			return *c, nil
+		}
+		return OamLinkCounters{}, nil
+	}
+	OamLoopbackDataplaneSet = func(p *OamPort, parser uint8, muxDiscard bool) {}
+}
+
+func OnlyForTestTeardown(t *testing.T) {
+	for _, p := range append([]*OamPort{}, OamPortDBList...) {
+		DeleteOamPort(p.IfIndex)
+	}
+	if len(OamPortDB) != 0 || len(OamPortDBList) != 0 {
+		t.Error("OAM port db not empty", OamPortDB, OamPortDBList)
+	}
+	OamLinkCountersGet = oamLinkCountersGetLinux
+	OamLoopbackDataplaneSet = oamLoopbackDataplaneSetPcap
+	utils.SetLaLogger(nil)
+}
+
+// OnlyForTestSetupPortPair creates two OAM ports connected back to back
+func OnlyForTestSetupPortPair(t *testing.T, cfg1, cfg2 OamPortConfig) (*OamPort, *OamPort, *SimulationBridge) {
+	bridge := &SimulationBridge{
+		Port1:   cfg1.IfIndex,
+		Port2:   cfg2.IfIndex,
+		RxPort1: make(chan gopacket.Packet, 100),
+		RxPort2: make(chan gopacket.Packet, 100),
+	}
+	OamRxMain(cfg1.IfIndex, bridge.RxPort1)
+	OamRxMain(cfg2.IfIndex, bridge.RxPort2)
+
+	enable1, enable2 := cfg1.Enable, cfg2.Enable
+	cfg1.Enable, cfg2.Enable = false, false
+	p1, err := CreateOamPort(&cfg1)
+	if err != nil {
+		t.Fatal("Create OAM port failed", err)
+	}
+	p2, err := CreateOamPort(&cfg2)
+	if err != nil {
+		t.Fatal("Create OAM port failed", err)
+	}
+	p1.OamPortRegisterTxCallback(bridge.TxViaGoChannel)
+	p2.OamPortRegisterTxCallback(bridge.TxViaGoChannel)
+	p1.Config.Enable, p2.Config.Enable = enable1, enable2
+	if enable1 {
+		p1.OamPortEnable()
+	}
+	if enable2 {
+		p2.OamPortEnable()
+	}
+	return p1, p2, bridge
+}
+
+func OamTestWaitForState(p *OamPort, check func(s OamPortState) bool) bool {
+	for i := 0; i < 50; i++ {
+		if check(p.OamPortStateGet()) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+func OamTestWaitForDiscoveryState(p *OamPort, state fsm.State) bool {
+	return OamTestWaitForState(p, func(s OamPortState) bool {
+		return s.DiscoveryState == OamDiscoveryStateStrMap[state]
+	})
+}
+
+func OamTestPortConfig(ifindex int32, mode int) OamPortConfig {
+	return OamPortConfig{
+		IfIndex:         ifindex,
+		IntfRef:         "SIMeth" + string('0'+rune(ifindex)),
+		Enable:          true,
+		Mode:            mode,
+		LoopbackSupport: true,
+		LinkEvents:      true,
+	}
+}
+
+func TestOamPduEncodeDecode(t *testing.T) {
+	info := &OamPdu{
+		Flags: OamFlagLocalStable | OamFlagRemoteEvaluating | OamFlagCriticalEvent,
+		Code:  OamCodeInformation,
+		LocalInfo: &OamInfoTlv{
+			Type:      OamInfoTlvLocalInfo,
+			Version:   OamVersion,
+			Revision:  5,
+			State:     OamStateParserLoopback | OamStateMuxDiscard,
+			Config:    OamConfigModeActive | OamConfigLoopbackSupport,
+			PduConfig: OamPduMaxSize,
+			Oui:       [3]uint8{0x00, 0x11, 0x22},
+		},
+		RemoteInfo: &OamInfoTlv{
+			Type:      OamInfoTlvRemoteInfo,
+			Version:   OamVersion,
+			PduConfig: 128,
+		},
+	}
+	b := info.Encode()
+	if len(b) != OamPduHeaderLength+OamPduMinDataLength {
+		t.Error("Information OAMPDU not padded to minimum", len(b))
+	}
+	rx, err := OamPduDecode(b)
+	if err != nil {
+		t.Fatal("Decode failed", err)
+	}
+	if rx.Flags != info.Flags || rx.Code != info.Code ||
+		rx.LocalInfo == nil || *rx.LocalInfo != *info.LocalInfo ||
+		rx.RemoteInfo == nil || *rx.RemoteInfo != *info.RemoteInfo {
+		t.Error("Information OAMPDU mismatch", rx, rx.LocalInfo, rx.RemoteInfo)
+	}
+
+	evt := &OamPdu{
+		Code:           OamCodeEventNotify,
+		SequenceNumber: 7,
+		Events: []OamEventTlv{
+			{Type: OamEventTlvErroredSymbolPeriod, Timestamp: 1, Window: 125000000, Threshold: 1, Errors: 3, ErrorRunningTotal: 10, EventRunningTotal: 2},
+			{Type: OamEventTlvErroredFrame, Timestamp: 2, Window: 10, Threshold: 1, Errors: 4, ErrorRunningTotal: 11, EventRunningTotal: 3},
+			{Type: OamEventTlvErroredFramePeriod, Timestamp: 3, Window: 1000, Threshold: 2, Errors: 5, ErrorRunningTotal: 12, EventRunningTotal: 4},
+			{Type: OamEventTlvErroredFrameSecsSummary, Timestamp: 4, Window: 600, Threshold: 1, Errors: 6, ErrorRunningTotal: 13, EventRunningTotal: 5},
+		},
+	}
+	b = evt.Encode()
+	if len(b) != OamPduHeaderLength+2+OamEventTlvErroredSymbolPeriodLen+OamEventTlvErroredFrameLen+OamEventTlvErroredFramePeriodLen+OamEventTlvErroredFrameSecsSumLen {
+		t.Error("Event Notification OAMPDU bad length", len(b))
+	}
+	rx, err = OamPduDecode(b)
+	if err != nil {
+		t.Fatal("Decode failed", err)
+	}
+	if rx.SequenceNumber != 7 || len(rx.Events) != len(evt.Events) {
+		t.Fatal("Event Notification OAMPDU mismatch", rx)
+	}
+	for i := range evt.Events {
+		if rx.Events[i] != evt.Events[i] {
+			t.Error("Event TLV mismatch", i, rx.Events[i], evt.Events[i])
+		}
+	}
+
+	lb := &OamPdu{
+		Code:        OamCodeLoopbackControl,
+		LoopbackCmd: OamLoopbackCmdEnable,
+	}
+	rx, err = OamPduDecode(lb.Encode())
+	if err != nil || rx.LoopbackCmd != OamLoopbackCmdEnable {
+		t.Error("Loopback Control OAMPDU mismatch", rx, err)
+	}
+
+	// truncated Local Information TLV
+	b = info.Encode()
+	b[4] = 10
+	if _, err = OamPduDecode(b); err == nil {
+		t.Error("Expected decode failure on bad TLV length")
+	}
+}
+
+func TestOamDiscoveryActivePassive(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown(t)
+
+	p1, p2, _ := OnlyForTestSetupPortPair(t, OamTestPortConfig(1, OamModeActive), OamTestPortConfig(2, OamModePassive))
+
+	if !OamTestWaitForDiscoveryState(p1, OamDiscoveryStateSendAny) {
+		t.Error("Active port failed to complete discovery", p1.OamPortStateGet())
+	}
+	if !OamTestWaitForDiscoveryState(p2, OamDiscoveryStateSendAny) {
+		t.Error("Passive port failed to complete discovery", p2.OamPortStateGet())
+	}
+	s := p2.OamPortStateGet()
+	if s.LocalPdu != OamLocalPduStrMap[OamLocalPduAny] ||
+		s.RemoteInfo.Config&OamConfigModeActive == 0 {
+		t.Error("Passive port did not learn active peer", s)
+	}
+
+	// link down on one side must drop both back out of SEND_ANY
+	OamPortLinkStatusSet(p1.IfIndex, false)
+	if !OamTestWaitForDiscoveryState(p1, OamDiscoveryStateFault) {
+		t.Error("Port did not move to Fault on link down", p1.OamPortStateGet())
+	}
+	OamPortLinkStatusSet(p1.IfIndex, true)
+	if !OamTestWaitForDiscoveryState(p1, OamDiscoveryStateSendAny) {
+		t.Error("Port failed to rediscover after link up", p1.OamPortStateGet())
+	}
+}
+
+func TestOamDiscoveryPassivePassive(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown(t)
+
+	p1, p2, _ := OnlyForTestSetupPortPair(t, OamTestPortConfig(1, OamModePassive), OamTestPortConfig(2, OamModePassive))
+	time.Sleep(1500 * time.Millisecond)
+	for _, p := range []*OamPort{p1, p2} {
+		s := p.OamPortStateGet()
+		if s.DiscoveryState != OamDiscoveryStateStrMap[OamDiscoveryStatePassiveWait] {
+			t.Error("Passive ports should never discover each other", s)
+		}
+		if s.Counters.InformationTx != 0 {
+			t.Error("Passive port transmitted before hearing a peer", s.Counters)
+		}
+	}
+}
+
+func TestOamRemoteLoopback(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown(t)
+
+	p1, p2, _ := OnlyForTestSetupPortPair(t, OamTestPortConfig(1, OamModeActive), OamTestPortConfig(2, OamModeActive))
+	if !OamTestWaitForDiscoveryState(p1, OamDiscoveryStateSendAny) ||
+		!OamTestWaitForDiscoveryState(p2, OamDiscoveryStateSendAny) {
+		t.Fatal("Discovery failed", p1.OamPortStateGet(), p2.OamPortStateGet())
+	}
+
+	if err := OamPortRemoteLoopbackSet(p1.IfIndex, true); err != nil {
+		t.Fatal("Loopback enable failed", err)
+	}
+	if !OamTestWaitForState(p2, func(s OamPortState) bool { return s.LoopbackStatus == OamLoopbackStrMap[OamLoopbackRemote] }) {
+		t.Error("Peer did not enter remote loopback", p2.OamPortStateGet())
+	}
+	if !OamTestWaitForState(p1, func(s OamPortState) bool { return s.LoopbackStatus == OamLoopbackStrMap[OamLoopbackLocal] }) {
+		t.Error("Initiator did not see peer loopback", p1.OamPortStateGet())
+	}
+	if err := OamPortRemoteLoopbackSet(p2.IfIndex, true); err == nil {
+		t.Error("Expected failure starting loopback on a looped port")
+	}
+
+	if err := OamPortRemoteLoopbackSet(p1.IfIndex, false); err != nil {
+		t.Fatal("Loopback disable failed", err)
+	}
+	for _, p := range []*OamPort{p1, p2} {
+		if !OamTestWaitForState(p, func(s OamPortState) bool { return s.LoopbackStatus == OamLoopbackStrMap[OamLoopbackNone] }) {
+			t.Error("Port did not leave loopback", p.OamPortStateGet())
+		}
+	}
+
+	// peer which does not support loopback
+	cfg := p2.Config
+	cfg.LoopbackSupport = false
+	UpdateOamPort(&cfg)
+	if !OamTestWaitForState(p1, func(s OamPortState) bool {
+		return s.DiscoveryState == OamDiscoveryStateStrMap[OamDiscoveryStateSendAny] &&
+			s.RemoteInfo.Config&OamConfigLoopbackSupport == 0
+	}) {
+		t.Fatal("Initiator did not learn updated peer config", p1.OamPortStateGet())
+	}
+	if err := OamPortRemoteLoopbackSet(p1.IfIndex, true); err == nil {
+		t.Error("Expected failure when peer does not support loopback")
+	}
+}
+
+func TestOamLinkEventsCriticalAndDyingGasp(t *testing.T) {
+	OnlyForTestSetup()
+	defer OnlyForTestTeardown(t)
+
+	p1, p2, _ := OnlyForTestSetupPortPair(t, OamTestPortConfig(1, OamModeActive), OamTestPortConfig(2, OamModeActive))
+	if !OamTestWaitForDiscoveryState(p1, OamDiscoveryStateSendAny) ||
+		!OamTestWaitForDiscoveryState(p2, OamDiscoveryStateSendAny) {
+		t.Fatal("Discovery failed", p1.OamPortStateGet(), p2.OamPortStateGet())
+	}
+
+	// let the link monitor take its baseline then inject crc errors
+	time.Sleep(300 * time.Millisecond)
+	testCountersLock.Lock()
+	testCounters[p1.IfIndex] = &OamLinkCounters{Frames: 100, FrameErrors: 5}
+	testCountersLock.Unlock()
+
+	if !OamTestWaitForState(p2, func(s OamPortState) bool {
+		for _, tlv := range s.RemoteEvents {
+			if tlv.Type == OamEventTlvErroredFrame && tlv.Errors == 5 {
+				return true
+			}
+		}
+		return false
+	}) {
+		t.Error("Peer did not receive errored frame event", p2.OamPortStateGet())
+	}
+
+	if err := OamPortCriticalEventSet(p1.IfIndex, true); err != nil {
+		t.Error("Critical event set failed", err)
+	}
+	if !OamTestWaitForState(p2, func(s OamPortState) bool { return s.RemoteCriticalEvent }) {
+		t.Error("Peer did not see critical event", p2.OamPortStateGet())
+	}
+	OamPortCriticalEventSet(p1.IfIndex, false)
+	if !OamTestWaitForState(p2, func(s OamPortState) bool { return !s.RemoteCriticalEvent }) {
+		t.Error("Peer did not see critical event clear", p2.OamPortStateGet())
+	}
+
+	// deleting p1 sends dying gasp, p2 must not wait for the lost link timer
+	DeleteOamPort(p1.IfIndex)
+	if !OamTestWaitForState(p2, func(s OamPortState) bool {
+		return s.Counters.DyingGaspRx > 0 && !s.RemoteStateValid
+	}) {
+		t.Error("Peer did not handle dying gasp", p2.OamPortStateGet())
+	}
+}
@@ -0,0 +1,288 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// pdu.go - encode/decode of OAMPDUs, the payload which follows the slow
+// protocol subtype octet
+package oam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// OamInfoTlv 802.3 57.5.2.1 Local/Remote Information TLV
+type OamInfoTlv struct {
+	Type       uint8
+	Version    uint8
+	Revision   uint16
+	State      uint8
+	Config     uint8
+	PduConfig  uint16
+	Oui        [3]uint8
+	VendorInfo [4]uint8
+}
+
+// OamEventTlv holds any of the four standard link event TLVs 802.3 57.5.3.
+// Field widths on the wire depend on the TLV type
+type OamEventTlv struct {
+	Type              uint8
+	Timestamp         uint16
+	Window            uint64
+	Threshold         uint64
+	Errors            uint64
+	ErrorRunningTotal uint64
+	EventRunningTotal uint32
+}
+
+// OamPdu is a decoded OAMPDU, only the members relevant to Code are valid
+type OamPdu struct {
+	Flags uint16
+	Code  uint8
+	// Information
+	LocalInfo  *OamInfoTlv
+	RemoteInfo *OamInfoTlv
+	// Event Notification
+	SequenceNumber uint16
+	Events         []OamEventTlv
+	// Loopback Control
+	LoopbackCmd uint8
+	// Variable Request/Response and Organization Specific are
+	// not interpreted
+	Data []uint8
+}
+
+func oamEventTlvLength(tlvType uint8) int {
+	switch tlvType {
+	case OamEventTlvErroredSymbolPeriod:
+		return OamEventTlvErroredSymbolPeriodLen
+	case OamEventTlvErroredFrame:
+		return OamEventTlvErroredFrameLen
+	case OamEventTlvErroredFramePeriod:
+		return OamEventTlvErroredFramePeriodLen
+	case OamEventTlvErroredFrameSecsSummary:
+		return OamEventTlvErroredFrameSecsSumLen
+	}
+	return 0
+}
+
+func (tlv *OamInfoTlv) encode(b []uint8) []uint8 {
+	b = append(b, tlv.Type, OamInfoTlvLength, tlv.Version)
+	b = append(b, uint8(tlv.Revision>>8), uint8(tlv.Revision))
+	b = append(b, tlv.State, tlv.Config)
+	b = append(b, uint8(tlv.PduConfig>>8), uint8(tlv.PduConfig))
+	b = append(b, tlv.Oui[:]...)
+	b = append(b, tlv.VendorInfo[:]...)
+	return b
+}
+
+func (tlv *OamEventTlv) encode(b []uint8) []uint8 {
+	length := oamEventTlvLength(tlv.Type)
+	start := len(b)
+	b = append(b, make([]uint8, length)...)
+	e := b[start:]
+	e[0] = tlv.Type
+	e[1] = uint8(length)
+	binary.BigEndian.PutUint16(e[2:], tlv.Timestamp)
+	switch tlv.Type {
+	case OamEventTlvErroredSymbolPeriod:
+		binary.BigEndian.PutUint64(e[4:], tlv.Window)
+		binary.BigEndian.PutUint64(e[12:], tlv.Threshold)
+		binary.BigEndian.PutUint64(e[20:], tlv.Errors)
+		binary.BigEndian.PutUint64(e[28:], tlv.ErrorRunningTotal)
+		binary.BigEndian.PutUint32(e[36:], tlv.EventRunningTotal)
+	case OamEventTlvErroredFrame:
+		binary.BigEndian.PutUint16(e[4:], uint16(tlv.Window))
+		binary.BigEndian.PutUint32(e[6:], uint32(tlv.Threshold))
+		binary.BigEndian.PutUint32(e[10:], uint32(tlv.Errors))
+		binary.BigEndian.PutUint64(e[14:], tlv.ErrorRunningTotal)
+		binary.BigEndian.PutUint32(e[22:], tlv.EventRunningTotal)
+	case OamEventTlvErroredFramePeriod:
+		binary.BigEndian.PutUint32(e[4:], uint32(tlv.Window))
+		binary.BigEndian.PutUint32(e[8:], uint32(tlv.Threshold))
+		binary.BigEndian.PutUint32(e[12:], uint32(tlv.Errors))
+		binary.BigEndian.PutUint64(e[16:], tlv.ErrorRunningTotal)
+		binary.BigEndian.PutUint32(e[24:], tlv.EventRunningTotal)
+	case OamEventTlvErroredFrameSecsSummary:
+		binary.BigEndian.PutUint16(e[4:], uint16(tlv.Window))
+		binary.BigEndian.PutUint16(e[6:], uint16(tlv.Threshold))
+		binary.BigEndian.PutUint16(e[8:], uint16(tlv.Errors))
+		binary.BigEndian.PutUint32(e[10:], uint32(tlv.ErrorRunningTotal))
+		binary.BigEndian.PutUint32(e[14:], tlv.EventRunningTotal)
+	}
+	return b
+}
+
+// Encode will serialize the OAMPDU starting with the flags field, the
+// data is padded so that the frame meets the minimum ethernet size
+func (pdu *OamPdu) Encode() []uint8 {
+	b := make([]uint8, 0, OamPduMinSize)
+	b = append(b, uint8(pdu.Flags>>8), uint8(pdu.Flags), pdu.Code)
+
+	switch pdu.Code {
+	case OamCodeInformation:
+		if pdu.LocalInfo != nil {
+			b = pdu.LocalInfo.encode(b)
+			if pdu.RemoteInfo != nil {
+				b = pdu.RemoteInfo.encode(b)
+			}
+		}
+	case OamCodeEventNotify:
+		b = append(b, uint8(pdu.SequenceNumber>>8), uint8(pdu.SequenceNumber))
+		for i := range pdu.Events {
+			b = pdu.Events[i].encode(b)
+		}
+	case OamCodeLoopbackControl:
+		b = append(b, pdu.LoopbackCmd)
+	default:
+		b = append(b, pdu.Data...)
+	}
+
+	// padding is zero which also acts as the End of TLV marker
+	for len(b) < OamPduHeaderLength+OamPduMinDataLength {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func oamInfoTlvDecode(b []uint8) (*OamInfoTlv, error) {
+	if len(b) < OamInfoTlvLength {
+		return nil, errors.New(fmt.Sprintf("ERROR Information TLV truncated len %d", len(b)))
+	}
+	if b[1] != OamInfoTlvLength {
+		return nil, errors.New(fmt.Sprintf("ERROR Information TLV type %d invalid length %d", b[0], b[1]))
+	}
+	tlv := &OamInfoTlv{
+		Type:      b[0],
+		Version:   b[2],
+		Revision:  binary.BigEndian.Uint16(b[3:]),
+		State:     b[5],
+		Config:    b[6],
+		PduConfig: binary.BigEndian.Uint16(b[7:]),
+	}
+	copy(tlv.Oui[:], b[9:12])
+	copy(tlv.VendorInfo[:], b[12:16])
+	return tlv, nil
+}
+
+func oamEventTlvDecode(b []uint8) (*OamEventTlv, error) {
+	length := oamEventTlvLength(b[0])
+	if int(b[1]) != length || len(b) < length {
+		return nil, errors.New(fmt.Sprintf("ERROR Event TLV type %d invalid length %d", b[0], b[1]))
+	}
+	tlv := &OamEventTlv{
+		Type:      b[0],
+		Timestamp: binary.BigEndian.Uint16(b[2:]),
+	}
+	switch tlv.Type {
+	case OamEventTlvErroredSymbolPeriod:
+		tlv.Window = binary.BigEndian.Uint64(b[4:])
+		tlv.Threshold = binary.BigEndian.Uint64(b[12:])
+		tlv.Errors = binary.BigEndian.Uint64(b[20:])
+		tlv.ErrorRunningTotal = binary.BigEndian.Uint64(b[28:])
+		tlv.EventRunningTotal = binary.BigEndian.Uint32(b[36:])
+	case OamEventTlvErroredFrame:
+		tlv.Window = uint64(binary.BigEndian.Uint16(b[4:]))
+		tlv.Threshold = uint64(binary.BigEndian.Uint32(b[6:]))
+		tlv.Errors = uint64(binary.BigEndian.Uint32(b[10:]))
+		tlv.ErrorRunningTotal = binary.BigEndian.Uint64(b[14:])
+		tlv.EventRunningTotal = binary.BigEndian.Uint32(b[22:])
+	case OamEventTlvErroredFramePeriod:
+		tlv.Window = uint64(binary.BigEndian.Uint32(b[4:]))
+		tlv.Threshold = uint64(binary.BigEndian.Uint32(b[8:]))
+		tlv.Errors = uint64(binary.BigEndian.Uint32(b[12:]))
+		tlv.ErrorRunningTotal = binary.BigEndian.Uint64(b[16:])
+		tlv.EventRunningTotal = binary.BigEndian.Uint32(b[24:])
+	case OamEventTlvErroredFrameSecsSummary:
+		tlv.Window = uint64(binary.BigEndian.Uint16(b[4:]))
+		tlv.Threshold = uint64(binary.BigEndian.Uint16(b[6:]))
+		tlv.Errors = uint64(binary.BigEndian.Uint16(b[8:]))
+		tlv.ErrorRunningTotal = uint64(binary.BigEndian.Uint32(b[10:]))
+		tlv.EventRunningTotal = binary.BigEndian.Uint32(b[14:])
+	}
+	return tlv, nil
+}
+
+// OamPduDecode will parse the octets following the slow protocol subtype
+func OamPduDecode(b []uint8) (*OamPdu, error) {
+	if len(b) < OamPduHeaderLength {
+		return nil, errors.New(fmt.Sprintf("ERROR OAMPDU too short len %d", len(b)))
+	}
+	pdu := &OamPdu{
+		Flags: binary.BigEndian.Uint16(b[0:]),
+		Code:  b[2],
+	}
+	data := b[OamPduHeaderLength:]
+
+	switch pdu.Code {
+	case OamCodeInformation:
+		for len(data) >= 2 && data[0] != OamInfoTlvEndOfTlv {
+			if data[1] < 2 || int(data[1]) > len(data) {
+				return nil, errors.New(fmt.Sprintf("ERROR Information TLV type %d bad length %d", data[0], data[1]))
+			}
+			switch data[0] {
+			case OamInfoTlvLocalInfo:
+				tlv, err := oamInfoTlvDecode(data)
+				if err != nil {
+					return nil, err
+				}
+				pdu.LocalInfo = tlv
+			case OamInfoTlvRemoteInfo:
+				tlv, err := oamInfoTlvDecode(data)
+				if err != nil {
+					return nil, err
+				}
+				pdu.RemoteInfo = tlv
+			}
+			// unknown and organization specific TLVs are skipped
+			data = data[data[1]:]
+		}
+	case OamCodeEventNotify:
+		if len(data) < 2 {
+			return nil, errors.New(fmt.Sprintf("ERROR Event Notification missing sequence number"))
+		}
+		pdu.SequenceNumber = binary.BigEndian.Uint16(data)
+		data = data[2:]
+		for len(data) >= 2 && data[0] != OamEventTlvEndOfTlv {
+			if data[1] < 2 || int(data[1]) > len(data) {
+				return nil, errors.New(fmt.Sprintf("ERROR Event TLV type %d bad length %d", data[0], data[1]))
+			}
+			if oamEventTlvLength(data[0]) != 0 {
+				tlv, err := oamEventTlvDecode(data)
+				if err != nil {
+					return nil, err
+				}
+				pdu.Events = append(pdu.Events, *tlv)
+			}
+			data = data[data[1]:]
+		}
+	case OamCodeLoopbackControl:
+		if len(data) < 1 {
+			return nil, errors.New(fmt.Sprintf("ERROR Loopback Control missing command"))
+		}
+		pdu.LoopbackCmd = data[0]
+	default:
+		pdu.Data = data
+	}
+	return pdu, nil
+}
@@ -0,0 +1,620 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// port.go - per port EFM OAM instance, configuration and main event loop
+package oam
+
+import (
+	"asicd/asicdCommonDefs"
+	"errors"
+	"fmt"
+	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/utils"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+)
+
+const OamPortModuleStr = "EFM OAM"
+
+// OamPortConfig is the per port admin configuration
+type OamPortConfig struct {
+	IfIndex int32
+	IntfRef string
+	Enable  bool
+	// OamModeActive or OamModePassive
+	Mode int
+	// advertise and honor Loopback Control OAMPDUs
+	LoopbackSupport bool
+	// generate Event Notification OAMPDUs
+	LinkEvents bool
+
+	// link monitor windows and thresholds, 802.3 30.3.6.1
+	ErrSymPeriodWindow      uint64
+	ErrSymPeriodThreshold   uint64
+	ErrFrameWindow          uint16
+	ErrFrameThreshold       uint32
+	ErrFramePeriodWindow    uint32
+	ErrFramePeriodThreshold uint32
+}
+
+// OamPortCounters 802.3 30.3.6.1 aOAM counters
+type OamPortCounters struct {
+	InformationTx        uint64
+	InformationRx        uint64
+	EventNotificationTx  uint64
+	EventNotificationRx  uint64
+	DuplicateEventRx     uint64
+	LoopbackControlTx    uint64
+	LoopbackControlRx    uint64
+	VariableRequestRx    uint64
+	UnsupportedCodesRx   uint64
+	FramesLostDueToOam   uint64
+	DyingGaspRx          uint64
+	CriticalEventRx      uint64
+	LinkFaultRx          uint64
+	IllegalRx            uint64
+	ErroredSymbolEventTx uint64
+	ErroredFrameEventTx  uint64
+	ErroredPeriodEventTx uint64
+}
+
+// OamRxPdu is sent from the rx routine to the port event loop
+type OamRxPdu struct {
+	pdu    *OamPdu
+	srcMac net.HardwareAddr
+	src    string
+}
+
+type OamTxCallback func(p *OamPort, pdu *OamPdu)
+
+type OamPort struct {
+	IfIndex int32
+	IntfRef string
+	Mac     net.HardwareAddr
+	Config  OamPortConfig
+
+	// protects everything below, rpc get routines read state while the
+	// port event loop owns updates
+	lock sync.Mutex
+
+	// discovery variables 802.3 57.3.1.2
+	localPdu         int
+	localLinkUp      bool
+	localStable      bool
+	localSatisfied   bool
+	remoteStable     bool
+	remoteStateValid bool
+	localParser      uint8
+	localMuxDiscard  bool
+	localRevision    uint16
+
+	// local flag sources
+	localCriticalEvent bool
+	localDyingGasp     bool
+
+	// last information received from the peer
+	RemoteMac   net.HardwareAddr
+	RemoteFlags uint16
+	RemoteInfo  OamInfoTlv
+
+	// last link events reported by the peer, by tlv type
+	RemoteEvents     map[uint8]OamEventTlv
+	rxEventSeq       uint16
+	rxEventSeqValid  bool
+	txEventSeq       uint16
+	monitor          oamLinkMonitor
+	loopback         int
+	loopbackReflect  bool
+	Counters         OamPortCounters
+	DiscoveryFsm     *OamDiscoveryMachine
+	txCallback       OamTxCallback
+	txPdusThisSecond int
+	txSecondStart    time.Time
+	createTime       time.Time
+
+	// timers
+	pduTimer           *time.Timer
+	localLostLinkTimer *time.Timer
+
+	// rx
+	handle        *pcap.Handle
+	reflectHandle *pcap.Handle
+	OamPktRxEvent chan OamRxPdu
+
+	quit   chan bool
+	wg     sync.WaitGroup
+	logEna bool
+}
+
+// OamPortDB holds all OAM enabled ports keyed by ifindex, the rx routines
+// look ports up while config adds and removes them
+var OamPortDB map[int32]*OamPort
+var OamPortDBList []*OamPort
+var OamPortDBLock sync.RWMutex
+
+// OamPortConfigDefaultsSet fills in any link monitor value left zero
+func OamPortConfigDefaultsSet(cfg *OamPortConfig) {
+	if cfg.ErrSymPeriodWindow == 0 {
+		cfg.ErrSymPeriodWindow = OamDefaultErrSymPeriodWindow
+	}
+	if cfg.ErrSymPeriodThreshold == 0 {
+		cfg.ErrSymPeriodThreshold = OamDefaultErrSymPeriodThreshold
+	}
+	if cfg.ErrFrameWindow == 0 {
+		cfg.ErrFrameWindow = OamDefaultErrFrameWindow
+	}
+	if cfg.ErrFrameThreshold == 0 {
+		cfg.ErrFrameThreshold = OamDefaultErrFrameThreshold
+	}
+	if cfg.ErrFramePeriodWindow == 0 {
+		cfg.ErrFramePeriodWindow = OamDefaultErrFramePeriodWindow
+	}
+	if cfg.ErrFramePeriodThreshold == 0 {
+		cfg.ErrFramePeriodThreshold = OamDefaultErrFramePeriodThreshold
+	}
+}
+
+// OamPortConfigParamCheck validates the user supplied configuration
+func OamPortConfigParamCheck(cfg *OamPortConfig) error {
+	if cfg.Mode != OamModeActive && cfg.Mode != OamModePassive {
+		return errors.New(fmt.Sprintf("ERROR invalid OAM mode %d for %s", cfg.Mode, cfg.IntfRef))
+	}
+	// 802.3 30.3.6.1.35 errored frame window range 1 to 60 seconds
+	if cfg.ErrFrameWindow != 0 &&
+		(cfg.ErrFrameWindow < 10 || cfg.ErrFrameWindow > 600) {
+		return errors.New(fmt.Sprintf("ERROR invalid errored frame window %d (100ms units) for %s, range 10-600", cfg.ErrFrameWindow, cfg.IntfRef))
+	}
+	return nil
+}
+
+func OamFindPortByIfIndex(ifindex int32, p **OamPort) bool {
+	OamPortDBLock.RLock()
+	defer OamPortDBLock.RUnlock()
+	if port, ok := OamPortDB[ifindex]; ok {
+		*p = port
+		return true
+	}
+	return false
+}
+
+func OamFindPortByName(name string, p **OamPort) bool {
+	OamPortDBLock.RLock()
+	defer OamPortDBLock.RUnlock()
+	for _, port := range OamPortDBList {
+		if port.IntfRef == name {
+			*p = port
+			return true
+		}
+	}
+	return false
+}
+
+// OamGetPortNext iterates the configured OAM ports
+func OamGetPortNext(port **OamPort) bool {
+	OamPortDBLock.RLock()
+	defer OamPortDBLock.RUnlock()
+	returnNext := false
+	for _, p := range OamPortDBList {
+		if *port == nil {
+			// first port
+			*port = p
+			return true
+		} else if (*port).IfIndex == p.IfIndex {
+			// found port, lets return the next port
+			returnNext = true
+		} else if returnNext {
+			// next port
+			*port = p
+			return true
+		}
+	}
+	*port = nil
+	return false
+}
+
+// OamPortConfigCreateCheck validates a create before it is queued to the
+// server
+func OamPortConfigCreateCheck(cfg *OamPortConfig) error {
+	var p *OamPort
+	if cfg.IfIndex == 0 {
+		return errors.New(fmt.Sprintf("ERROR OAM invalid interface %s", cfg.IntfRef))
+	}
+	if OamFindPortByIfIndex(cfg.IfIndex, &p) {
+		return errors.New(fmt.Sprintf("ERROR OAM already configured on %s", cfg.IntfRef))
+	}
+	return OamPortConfigParamCheck(cfg)
+}
+
+// OamPortState is a consistent snapshot of a port for the rpc layer
+type OamPortState struct {
+	Config              OamPortConfig
+	DiscoveryState      string
+	LocalPdu            string
+	LocalStable         bool
+	LocalSatisfied      bool
+	RemoteStable        bool
+	RemoteStateValid    bool
+	LocalCriticalEvent  bool
+	RemoteMac           string
+	RemoteInfo          OamInfoTlv
+	RemoteLinkFault     bool
+	RemoteCriticalEvent bool
+	LoopbackStatus      string
+	RemoteEvents        []OamEventTlv
+	Counters            OamPortCounters
+}
+
+func (p *OamPort) OamPortStateGet() OamPortState {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	s := OamPortState{
+		Config:              p.Config,
+		DiscoveryState:      OamDiscoveryStateStrMap[OamDiscoveryStateNone],
+		LocalPdu:            OamLocalPduStrMap[p.localPdu],
+		LocalStable:         p.localStable,
+		LocalSatisfied:      p.localSatisfied,
+		RemoteStable:        p.remoteStable,
+		RemoteStateValid:    p.remoteStateValid,
+		LocalCriticalEvent:  p.localCriticalEvent,
+		RemoteInfo:          p.RemoteInfo,
+		RemoteLinkFault:     p.RemoteFlags&OamFlagLinkFault != 0,
+		RemoteCriticalEvent: p.RemoteFlags&OamFlagCriticalEvent != 0,
+		LoopbackStatus:      OamLoopbackStrMap[p.loopback],
+		Counters:            p.Counters,
+	}
+	if p.DiscoveryFsm != nil && p.DiscoveryFsm.Machine != nil {
+		s.DiscoveryState = OamDiscoveryStateStrMap[p.DiscoveryFsm.Machine.Curr.CurrentState()]
+	}
+	if p.RemoteMac != nil {
+		s.RemoteMac = p.RemoteMac.String()
+	}
+	for _, tlv := range p.RemoteEvents {
+		s.RemoteEvents = append(s.RemoteEvents, tlv)
+	}
+	return s
+}
+
+func (p *OamPort) OamPortLog(msg string) {
+	if p.logEna {
+		utils.GlobalLogger.Info(fmt.Sprintf("%s %s: %s", OamPortModuleStr, p.IntfRef, msg))
+	}
+}
+
+func (p *OamPort) EnableLogging(ena bool) {
+	p.logEna = ena
+	if p.DiscoveryFsm != nil && p.DiscoveryFsm.Machine != nil {
+		p.DiscoveryFsm.Machine.Curr.EnableLogging(ena)
+	}
+}
+
+// CreateOamPort will create the OAM instance of a port and start discovery
+// when the port is admin enabled
+func CreateOamPort(cfg *OamPortConfig) (*OamPort, error) {
+	if err := OamPortConfigParamCheck(cfg); err != nil {
+		return nil, err
+	}
+	var existing *OamPort
+	if OamFindPortByIfIndex(cfg.IfIndex, &existing) {
+		return nil, errors.New(fmt.Sprintf("ERROR OAM already configured on %s", cfg.IntfRef))
+	}
+	OamPortConfigDefaultsSet(cfg)
+
+	p := &OamPort{
+		IfIndex:       cfg.IfIndex,
+		IntfRef:       cfg.IntfRef,
+		Config:        *cfg,
+		localPdu:      OamLocalPduRxInfo,
+		localParser:   OamStateParserForward,
+		RemoteEvents:  make(map[uint8]OamEventTlv),
+		OamPktRxEvent: make(chan OamRxPdu, 100),
+		createTime:    time.Now(),
+		logEna:        true,
+	}
+	if portcfg, ok := utils.PortConfigMap[cfg.IfIndex]; ok {
+		p.Mac = portcfg.HardwareAddr
+	}
+
+	OamPortDBLock.Lock()
+	OamPortDB[p.IfIndex] = p
+	OamPortDBList = append(OamPortDBList, p)
+	OamPortDBLock.Unlock()
+	utils.CreateOamEventMap(p.IfIndex)
+
+	if p.Config.Enable {
+		p.OamPortEnable()
+	}
+	return p, nil
+}
+
+// DeleteOamPort sends a dying gasp to the peer and tears down the instance
+func DeleteOamPort(ifindex int32) {
+	var p *OamPort
+	if OamFindPortByIfIndex(ifindex, &p) {
+		p.OamPortDisable()
+		utils.DeleteOamEventMap(p.IfIndex)
+		OamPortDBLock.Lock()
+		delete(OamPortDB, ifindex)
+		for i, port := range OamPortDBList {
+			if port == p {
+				OamPortDBList = append(OamPortDBList[:i], OamPortDBList[i+1:]...)
+				break
+			}
+		}
+		OamPortDBLock.Unlock()
+	}
+}
+
+// UpdateOamPort applies a configuration change in place, a mode change or
+// capability change will restart discovery so the peer re-evaluates us
+func UpdateOamPort(cfg *OamPortConfig) error {
+	var p *OamPort
+	if !OamFindPortByIfIndex(cfg.IfIndex, &p) {
+		return errors.New(fmt.Sprintf("ERROR OAM not configured on %s", cfg.IntfRef))
+	}
+	if err := OamPortConfigParamCheck(cfg); err != nil {
+		return err
+	}
+	OamPortConfigDefaultsSet(cfg)
+
+	if cfg.Enable != p.Config.Enable {
+		p.lock.Lock()
+		p.Config = *cfg
+		p.lock.Unlock()
+		if cfg.Enable {
+			p.OamPortEnable()
+		} else {
+			p.OamPortDisable()
+		}
+		return nil
+	}
+
+	p.lock.Lock()
+	rediscover := cfg.Mode != p.Config.Mode ||
+		cfg.LoopbackSupport != p.Config.LoopbackSupport ||
+		cfg.LinkEvents != p.Config.LinkEvents
+	p.Config = *cfg
+	p.monitor.reset()
+	if rediscover && p.DiscoveryFsm != nil {
+		// revision must change whenever the local information changes
+		p.localRevision++
+		p.oamDiscoveryProcessEvent(OamDiscoveryModuleStr, OamDiscoveryEventBegin)
+		p.oamDiscoveryEvaluate()
+	}
+	p.lock.Unlock()
+	return nil
+}
+
+// OamPortEnable opens the rx/tx path and starts the port event loop
+func (p *OamPort) OamPortEnable() {
+	if p.quit != nil {
+		return
+	}
+	p.quit = make(chan bool)
+	p.CreateRxTx()
+
+	p.lock.Lock()
+	p.localLinkUp = OamPortLinkStatusGet(p)
+	OamDiscoveryMachineFSMBuild(p)
+	p.monitor.reset()
+	p.pduTimer = time.NewTimer(OamPduTimerInterval)
+	p.localLostLinkTimer = time.NewTimer(OamLocalLostLinkTimeout)
+	p.localLostLinkTimer.Stop()
+	p.DiscoveryFsm.Machine.Start(p.DiscoveryFsm.PrevState())
+	p.oamDiscoveryProcessEvent(OamDiscoveryModuleStr, OamDiscoveryEventBegin)
+	p.oamDiscoveryEvaluate()
+	p.lock.Unlock()
+
+	p.OamPortMain()
+}
+
+// OamPortDisable stops the event loop, a dying gasp is sent first so the
+// peer knows this is an intentional shutdown rather than a failure
+func (p *OamPort) OamPortDisable() {
+	if p.quit == nil {
+		return
+	}
+	p.lock.Lock()
+	if p.remoteStateValid {
+		p.oamTxDyingGasp()
+	}
+	if p.loopback != OamLoopbackNone {
+		p.oamLoopbackStateSet(OamLoopbackNone)
+	}
+	p.lock.Unlock()
+
+	close(p.quit)
+	p.wg.Wait()
+	p.quit = nil
+	p.DeleteRxTx()
+
+	p.lock.Lock()
+	p.pduTimer.Stop()
+	p.localLostLinkTimer.Stop()
+	p.DiscoveryFsm = nil
+	p.localPdu = OamLocalPduRxInfo
+	p.remoteStateValid = false
+	p.remoteStable = false
+	p.localStable = false
+	p.localSatisfied = false
+	p.lock.Unlock()
+}
+
+// OamPortLinkStatusSet is called on link state notifications
+func OamPortLinkStatusSet(ifindex int32, up bool) {
+	var p *OamPort
+	if OamFindPortByIfIndex(ifindex, &p) {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		if p.localLinkUp == up {
+			return
+		}
+		p.localLinkUp = up
+		if p.DiscoveryFsm != nil {
+			if !up {
+				p.oamDiscoveryProcessEvent(OamDiscoveryModuleStr, OamDiscoveryEventLinkFail)
+			}
+			p.oamDiscoveryEvaluate()
+		}
+	}
+}
+
+// OamPortCriticalEventSet sets or clears the critical event flag which is
+// sent to the peer in every OAMPDU
+func OamPortCriticalEventSet(ifindex int32, set bool) error {
+	var p *OamPort
+	if !OamFindPortByIfIndex(ifindex, &p) {
+		return errors.New(fmt.Sprintf("ERROR OAM not configured on ifindex %d", ifindex))
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.localCriticalEvent != set {
+		p.localCriticalEvent = set
+		// let the peer know right away rather than waiting for the pdu timer
+		if p.DiscoveryFsm != nil {
+			p.oamTxInformation(false)
+		}
+	}
+	return nil
+}
+
+// OamDyingGaspAll is used on daemon shutdown to notify every peer
+func OamDyingGaspAll() {
+	OamPortDBLock.RLock()
+	defer OamPortDBLock.RUnlock()
+	for _, p := range OamPortDBList {
+		p.lock.Lock()
+		if p.DiscoveryFsm != nil && p.remoteStateValid {
+			p.oamTxDyingGasp()
+		}
+		p.lock.Unlock()
+	}
+}
+
+// OamPortMain is the per port event loop, it owns timers and received
+// OAMPDUs
+func (p *OamPort) OamPortMain() {
+	p.wg.Add(1)
+	go func(p *OamPort) {
+		defer p.wg.Done()
+		p.OamPortLog("Event loop start")
+		monitorTicker := time.NewTicker(OamLinkMonitorPollInterval)
+		defer monitorTicker.Stop()
+		for {
+			select {
+			case <-p.quit:
+				p.OamPortLog("Event loop end")
+				return
+
+			case event, ok := <-p.DiscoveryFsm.OamDiscoveryEvents:
+				if ok {
+					p.lock.Lock()
+					p.oamDiscoveryProcessEvent(event.Src, event.E)
+					p.oamDiscoveryEvaluate()
+					p.lock.Unlock()
+					if event.ResponseChan != nil {
+						utils.SendResponse(OamDiscoveryModuleStr, event.ResponseChan)
+					}
+				}
+
+			case rx, ok := <-p.OamPktRxEvent:
+				if ok {
+					p.lock.Lock()
+					p.oamRxPdu(rx)
+					p.lock.Unlock()
+				}
+
+			case <-p.pduTimer.C:
+				p.lock.Lock()
+				p.oamTxInformation(true)
+				p.pduTimer.Reset(OamPduTimerInterval)
+				p.lock.Unlock()
+
+			case <-p.localLostLinkTimer.C:
+				p.lock.Lock()
+				p.OamPortLog("local_lost_link_timer expired")
+				p.oamDiscoveryProcessEvent(OamDiscoveryModuleStr, OamDiscoveryEventLocalLostLinkTimerDone)
+				p.oamDiscoveryEvaluate()
+				p.lock.Unlock()
+
+			case <-monitorTicker.C:
+				p.lock.Lock()
+				p.oamLinkMonitorPoll()
+				p.lock.Unlock()
+			}
+		}
+	}(p)
+}
+
+// frames reflected while in remote loopback
+const oamLoopbackBpfFilter = "not ether proto 0x8809"
+
+// OamMacCaptureSet tracks whether asicd has been asked to punt slow
+// protocol frames to the cpu on behalf of OAM
+var OamMacCaptureSet bool
+
+func (p *OamPort) CreateRxTx() {
+	if p.handle != nil {
+		return
+	}
+	if !OamMacCaptureSet {
+		for _, client := range utils.GetAsicDPluginList() {
+			client.EnablePacketReception("01:80:C2:00:00:02", 0, 0)
+			OamMacCaptureSet = true
+		}
+	}
+
+	// the slow protocol capture is shared with lacp, OAMPDUs are
+	// dispatched to OamRxSlowProtocol from the lacp rx path
+	handle, err := lacp.LaSlowProtocolRxTxCreate(p.IntfRef,
+		uint16(asicdCommonDefs.GetIntfIdFromIfIndex(p.IfIndex)))
+	if err != nil {
+		// failure here may be ok as this may be SIM
+		if !strings.Contains(p.IntfRef, "SIM") {
+			p.OamPortLog(fmt.Sprintln("Error creating rx/tx for port", p.IntfRef, err))
+		}
+		return
+	}
+	p.lock.Lock()
+	p.handle = handle
+	p.lock.Unlock()
+	p.OamPortLog("Rx Main Started")
+}
+
+func (p *OamPort) DeleteRxTx() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.reflectHandle != nil {
+		p.reflectHandle.Close()
+		p.reflectHandle = nil
+	}
+	if p.handle != nil {
+		lacp.LaSlowProtocolRxTxDelete(p.IntfRef)
+		p.handle = nil
+		p.loopbackReflect = false
+		p.OamPortLog("RX/TX handle closed")
+	}
+}
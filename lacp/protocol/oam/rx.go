@@ -0,0 +1,238 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// rx.go - receive path for slow protocol subtype 3 frames, validated
+// OAMPDUs are handed to the port event loop
+package oam
+
+import (
+	"bytes"
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const RxModuleStr = "Rx Module"
+
+// OamRxMain will process incomming packets from a test channel, on a
+// real interface OAMPDUs arrive through OamRxSlowProtocol
+func OamRxMain(ifindex int32, rxPktChan chan gopacket.Packet) {
+	go func(ifindex int32, rx chan gopacket.Packet) {
+		for {
+			select {
+			case packet, ok := <-rx:
+				if !ok {
+					return
+				}
+				if pdu, srcMac := IsControlFrame(ifindex, packet); pdu != nil {
+					ProcessOamFrame(ifindex, srcMac, pdu)
+				} else {
+					oamLoopbackReflect(ifindex, packet)
+				}
+			}
+		}
+	}(ifindex, rxPktChan)
+}
+
+// OamRxSlowProtocol is registered with lacp which owns the slow protocol
+// capture of the interface
+func OamRxSlowProtocol(intfRef string, packet gopacket.Packet) {
+	var p *OamPort
+	if OamFindPortByName(intfRef, &p) {
+		if pdu, srcMac := IsControlFrame(p.IfIndex, packet); pdu != nil {
+			ProcessOamFrame(p.IfIndex, srcMac, pdu)
+		}
+	}
+}
+
+// IsControlFrame returns the decoded OAMPDU when the packet is a valid
+// slow protocol subtype 3 frame
+func IsControlFrame(ifindex int32, packet gopacket.Packet) (*OamPdu, net.HardwareAddr) {
+	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
+	if ethernetLayer == nil {
+		return nil, nil
+	}
+	ethernet := ethernetLayer.(*layers.Ethernet)
+	if ethernet.EthernetType != layers.EthernetTypeSlowProtocol ||
+		!bytes.Equal(ethernet.DstMAC, OamSlowProtocolMac) {
+		return nil, nil
+	}
+	payload := ethernet.LayerPayload()
+	if len(payload) < 1 || payload[0] != OamSlowProtocolSubType {
+		return nil, nil
+	}
+
+	pdu, err := OamPduDecode(payload[1:])
+	if err != nil {
+		var p *OamPort
+		if OamFindPortByIfIndex(ifindex, &p) {
+			p.lock.Lock()
+			p.Counters.IllegalRx++
+			p.lock.Unlock()
+			p.OamPortLog(fmt.Sprintln("RX:", err))
+		}
+		return nil, nil
+	}
+	return pdu, ethernet.SrcMAC
+}
+
+// ProcessOamFrame will forward the OAMPDU to the port event loop
+func ProcessOamFrame(ifindex int32, srcMac net.HardwareAddr, pdu *OamPdu) {
+	var p *OamPort
+	if OamFindPortByIfIndex(ifindex, &p) {
+		select {
+		case p.OamPktRxEvent <- OamRxPdu{
+			pdu:    pdu,
+			srcMac: srcMac,
+			src:    RxModuleStr}:
+		default:
+			// event loop is not running or is backed up
+			p.OamPortLog("RX: dropping OAMPDU, event queue full")
+		}
+	}
+}
+
+// oamRxFlags publishes remote link fault, dying gasp and critical event
+// transitions 802.3 57.2.10
+func (p *OamPort) oamRxFlags(flags uint16) {
+	prev := p.RemoteFlags
+	p.RemoteFlags = flags
+
+	if flags&OamFlagLinkFault != prev&OamFlagLinkFault {
+		if flags&OamFlagLinkFault != 0 {
+			p.Counters.LinkFaultRx++
+			p.OamPortLog("Peer reported link fault")
+		}
+		utils.ProcessOamLinkFault(p.IfIndex, flags&OamFlagLinkFault != 0)
+	}
+	if flags&OamFlagCriticalEvent != prev&OamFlagCriticalEvent {
+		if flags&OamFlagCriticalEvent != 0 {
+			p.Counters.CriticalEventRx++
+			p.OamPortLog("Peer reported critical event")
+		}
+		utils.ProcessOamCriticalEvent(p.IfIndex, flags&OamFlagCriticalEvent != 0)
+	}
+}
+
+func (p *OamPort) oamRxPdu(rx OamRxPdu) {
+	pdu := rx.pdu
+	if p.DiscoveryFsm == nil {
+		return
+	}
+
+	p.oamRxFlags(pdu.Flags)
+
+	if pdu.Flags&OamFlagDyingGasp != 0 {
+		// the peer is going away, don't wait for local_lost_link_timer
+		p.Counters.DyingGaspRx++
+		p.OamPortLog("Peer sent dying gasp")
+		utils.ProcessOamDyingGasp(p.IfIndex)
+		if pdu.Code == OamCodeInformation {
+			p.Counters.InformationRx++
+		}
+		if p.remoteStateValid {
+			p.oamDiscoveryProcessEvent(RxModuleStr, OamDiscoveryEventLocalLostLinkTimerDone)
+			p.oamDiscoveryEvaluate()
+		}
+		return
+	}
+
+	if pdu.Code == OamCodeInformation {
+		p.Counters.InformationRx++
+		p.oamRxInformation(rx)
+		return
+	}
+
+	// 802.3 57.3.2.1 only Information OAMPDUs are accepted until
+	// discovery completes
+	if p.localPdu != OamLocalPduAny {
+		p.OamPortLog(fmt.Sprintf("RX: discarding code %d before discovery complete", pdu.Code))
+		return
+	}
+
+	switch pdu.Code {
+	case OamCodeEventNotify:
+		p.Counters.EventNotificationRx++
+		p.oamRxEventNotification(pdu)
+	case OamCodeLoopbackControl:
+		p.Counters.LoopbackControlRx++
+		p.oamLoopbackControlRx(pdu.LoopbackCmd)
+	case OamCodeVarRequest:
+		// variable retrieval is not advertised so nothing is returned
+		p.Counters.VariableRequestRx++
+	default:
+		p.Counters.UnsupportedCodesRx++
+	}
+}
+
+func (p *OamPort) oamRxInformation(rx OamRxPdu) {
+	pdu := rx.pdu
+	if pdu.LocalInfo == nil {
+		// link fault information from a peer which can't hear us
+		return
+	}
+
+	p.RemoteMac = rx.srcMac
+	p.RemoteInfo = *pdu.LocalInfo
+	p.remoteStateValid = true
+	p.remoteStable = pdu.Flags&OamFlagLocalStable != 0
+	p.localSatisfied = p.oamDiscoveryLocalSatisfiedEval()
+	p.localLostLinkTimer.Reset(OamLocalLostLinkTimeout)
+
+	p.oamLoopbackRemoteStateRx(pdu.LocalInfo.State)
+	p.oamDiscoveryEvaluate()
+}
+
+func (p *OamPort) oamRxEventNotification(pdu *OamPdu) {
+	// peers may repeat an event notification to survive loss
+	if p.rxEventSeqValid && pdu.SequenceNumber == p.rxEventSeq {
+		p.Counters.DuplicateEventRx++
+		return
+	}
+	p.rxEventSeq = pdu.SequenceNumber
+	p.rxEventSeqValid = true
+
+	for _, tlv := range pdu.Events {
+		p.RemoteEvents[tlv.Type] = tlv
+		p.OamPortLog(fmt.Sprintf("Peer link event type %d errors %d threshold %d window %d", tlv.Type, tlv.Errors, tlv.Threshold, tlv.Window))
+		switch tlv.Type {
+		case OamEventTlvErroredSymbolPeriod:
+			utils.ProcessOamErroredSymbolPeriod(p.IfIndex)
+		case OamEventTlvErroredFrame:
+			utils.ProcessOamErroredFrame(p.IfIndex)
+		case OamEventTlvErroredFramePeriod:
+			utils.ProcessOamErroredFramePeriod(p.IfIndex)
+		case OamEventTlvErroredFrameSecsSummary:
+			utils.ProcessOamErroredFrameSecsSummary(p.IfIndex)
+		}
+	}
+}
+
+// oamEventTimestamp is the 100ms resolution timestamp used in event TLVs
+func (p *OamPort) oamEventTimestamp() uint16 {
+	return uint16(time.Since(p.createTime) / (100 * time.Millisecond))
+}
@@ -0,0 +1,227 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// tx.go - OAMPDU transmit, 802.3 57.3.2.2 transmit rules and the
+// simulation/linux transports
+package oam
+
+import (
+	"fmt"
+	"l2/lacp/protocol/utils"
+	"net"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// bridge will simulate communication between two channels
+type SimulationBridge struct {
+	Port1    int32
+	Port2    int32
+	RxPort1  chan gopacket.Packet
+	RxPort2  chan gopacket.Packet
+	Dropping bool
+}
+
+func oamPacketSerialize(srcMac net.HardwareAddr, pdu *OamPdu) []byte {
+	eth := layers.Ethernet{
+		SrcMAC:       srcMac,
+		DstMAC:       OamSlowProtocolMac,
+		EthernetType: layers.EthernetTypeSlowProtocol,
+	}
+	payload := append([]byte{OamSlowProtocolSubType}, pdu.Encode()...)
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	gopacket.SerializeLayers(buf, opts, &eth, gopacket.Payload(payload))
+	return buf.Bytes()
+}
+
+func (bridge *SimulationBridge) TxViaGoChannel(p *OamPort, pdu *OamPdu) {
+	if bridge.Dropping {
+		return
+	}
+	srcMac := net.HardwareAddr{0x00, uint8(p.IfIndex & 0xff), 0x00, 0x01, 0x01, 0x01}
+	pkt := gopacket.NewPacket(oamPacketSerialize(srcMac, pdu), layers.LinkTypeEthernet, gopacket.Default)
+	if p.IfIndex != bridge.Port1 && bridge.RxPort1 != nil {
+		bridge.RxPort1 <- pkt
+	} else if bridge.RxPort2 != nil {
+		bridge.RxPort2 <- pkt
+	}
+}
+
+func TxViaLinuxIf(p *OamPort, pdu *OamPdu) {
+	if p.handle == nil {
+		return
+	}
+	srcMac := p.Mac
+	if srcMac == nil {
+		txIface, err := net.InterfaceByName(p.IntfRef)
+		if err != nil {
+			utils.GlobalLogger.Err(fmt.Sprintln("ERROR could not find OAM interface", p.IntfRef, err))
+			return
+		}
+		srcMac = txIface.HardwareAddr
+	}
+	if err := p.handle.WritePacketData(oamPacketSerialize(srcMac, pdu)); err != nil {
+		utils.GlobalLogger.Err(fmt.Sprintf("%s\n", err))
+	}
+}
+
+// OamPortRegisterTxCallback replaces the transmit function of the port,
+// used by tests to plug in a SimulationBridge
+func (p *OamPort) OamPortRegisterTxCallback(f OamTxCallback) {
+	p.lock.Lock()
+	p.txCallback = f
+	p.lock.Unlock()
+}
+
+// oamTxFlags builds the flags field 802.3 57.4.2.1
+func (p *OamPort) oamTxFlags() uint16 {
+	var flags uint16
+	if !p.localLinkUp {
+		flags |= OamFlagLinkFault
+	}
+	if p.localDyingGasp {
+		flags |= OamFlagDyingGasp
+	}
+	if p.localCriticalEvent {
+		flags |= OamFlagCriticalEvent
+	}
+	// local evaluating/stable, both clear means discovery can't complete
+	if p.localStable {
+		flags |= OamFlagLocalStable
+	} else if !p.remoteStateValid || p.oamDiscoveryLocalSatisfiedEval() {
+		flags |= OamFlagLocalEvaluating
+	}
+	// remote bits reflect what the peer last told us
+	if p.remoteStateValid {
+		if p.RemoteFlags&OamFlagLocalStable != 0 {
+			flags |= OamFlagRemoteStable
+		} else if p.RemoteFlags&OamFlagLocalEvaluating != 0 {
+			flags |= OamFlagRemoteEvaluating
+		}
+	}
+	return flags
+}
+
+func (p *OamPort) oamLocalInfoTlv() *OamInfoTlv {
+	cfg := uint8(0)
+	if p.Config.Mode == OamModeActive {
+		cfg |= OamConfigModeActive
+	}
+	if p.Config.LoopbackSupport {
+		cfg |= OamConfigLoopbackSupport
+	}
+	if p.Config.LinkEvents {
+		cfg |= OamConfigLinkEvents
+	}
+	state := p.localParser & OamStateParserMask
+	if p.localMuxDiscard {
+		state |= OamStateMuxDiscard
+	}
+	return &OamInfoTlv{
+		Type:      OamInfoTlvLocalInfo,
+		Version:   OamVersion,
+		Revision:  p.localRevision,
+		State:     state,
+		Config:    cfg,
+		PduConfig: OamPduMaxSize,
+	}
+}
+
+// oamTxPdu enforces the ten OAMPDUs per second limit of 802.3 57.3.2.2
+func (p *OamPort) oamTxPdu(pdu *OamPdu, force bool) bool {
+	now := time.Now()
+	if now.Sub(p.txSecondStart) >= time.Second {
+		p.txSecondStart = now
+		p.txPdusThisSecond = 0
+	}
+	if !force && p.txPdusThisSecond >= OamMaxPduPerSecond {
+		p.Counters.FramesLostDueToOam++
+		return false
+	}
+	p.txPdusThisSecond++
+
+	tx := p.txCallback
+	if tx == nil {
+		tx = TxViaLinuxIf
+	}
+	tx(p, pdu)
+
+	switch pdu.Code {
+	case OamCodeInformation:
+		p.Counters.InformationTx++
+	case OamCodeEventNotify:
+		p.Counters.EventNotificationTx++
+	case OamCodeLoopbackControl:
+		p.Counters.LoopbackControlTx++
+	}
+	return true
+}
+
+// oamTxInformation sends an Information OAMPDU appropriate for local_pdu,
+// periodic is set when driven by the pdu_timer
+func (p *OamPort) oamTxInformation(periodic bool) {
+	pdu := &OamPdu{
+		Code: OamCodeInformation,
+	}
+	switch p.localPdu {
+	case OamLocalPduRxInfo:
+		// receive only until a peer has been heard
+		return
+	case OamLocalPduLfInfo:
+		// link fault information carries no TLVs
+	default:
+		pdu.LocalInfo = p.oamLocalInfoTlv()
+		if p.remoteStateValid {
+			remote := p.RemoteInfo
+			remote.Type = OamInfoTlvRemoteInfo
+			pdu.RemoteInfo = &remote
+		}
+	}
+	pdu.Flags = p.oamTxFlags()
+	if !periodic {
+		p.pduTimer.Reset(OamPduTimerInterval)
+	}
+	p.oamTxPdu(pdu, false)
+}
+
+// oamTxDyingGasp sends dying gasp back to back so at least one
+// survives, 802.3 57.2.10.1 unrecoverable local failure
+func (p *OamPort) oamTxDyingGasp() {
+	p.localDyingGasp = true
+	pdu := &OamPdu{
+		Code:      OamCodeInformation,
+		LocalInfo: p.oamLocalInfoTlv(),
+	}
+	pdu.Flags = p.oamTxFlags()
+	for i := 0; i < OamDyingGaspTxCount; i++ {
+		p.oamTxPdu(pdu, true)
+	}
+	p.localDyingGasp = false
+}
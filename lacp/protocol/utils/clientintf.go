@@ -23,6 +23,8 @@
 package utils
 
 import (
+	"fmt"
+	"sync"
 	"utils/asicdClient"
 )
 
@@ -39,3 +41,45 @@ func GetAsicDPluginList() []asicdClient.AsicdClientIntf {
 func DeleteAllAsicDPlugins() {
 	ClientIntfs = nil
 }
+
+// DrniClientIntf is the extension of the asicd client required by DRNI in
+// order to select the gateway of a frame by flow hash, to translate the tag
+// of a gateway conversation so that the IPL can share a link with network
+// traffic and to flush the learned MAC entries when the ownership of a
+// gateway or port conversation moves between portal systems
+type DrniClientIntf interface {
+	DrniFlowHashFieldsSet(aggId int32, fields uint32) error
+	IppFlowHashConversationSet(cid uint16, ippid int32) error
+	IppFlowHashConversationClear(cid uint16, ippid int32) error
+	IppEncapTranslationSet(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error
+	IppEncapTranslationClear(ippid int32, encapMethod string, cid uint16, iplId uint32, netId uint32) error
+	FdbFlushByConversation(cidList []uint16, ifindexList []int32) error
+	FdbFlushByPort(ifindex int32) error
+}
+
+var drniPluginMissingMutex sync.Mutex
+var drniPluginMissingLogged = make(map[string]bool)
+
+// GetDrniPluginList returns the asicd plugins which implement the DRNI
+// extension.  If no plugin implements it the operation is not programmed
+// into hw, an error is logged once per operation
+func GetDrniPluginList(operation string) []DrniClientIntf {
+	clientList := make([]DrniClientIntf, 0)
+	for _, client := range ClientIntfs {
+		if drniclient, ok := client.(DrniClientIntf); ok {
+			clientList = append(clientList, drniclient)
+		}
+	}
+	if len(clientList) == 0 &&
+		len(ClientIntfs) != 0 {
+		drniPluginMissingMutex.Lock()
+		if !drniPluginMissingLogged[operation] {
+			drniPluginMissingLogged[operation] = true
+			if GlobalLogger != nil {
+				GlobalLogger.Err(fmt.Sprintf("ERROR no asicd plugin implements DRNI operation %s, operation will not be programmed", operation))
+			}
+		}
+		drniPluginMissingMutex.Unlock()
+	}
+	return clientList
+}
@@ -49,6 +49,8 @@ func CreateEventMap(ifindex int32) {
 	EventMap[evt] = false
 	evt.event = events.LacpdEventPortPartnerInfoMismatch
 	EventMap[evt] = false
+	evt.event = events.LacpdEventPortErrDisabled
+	EventMap[evt] = false
 
 }
 
@@ -63,6 +65,8 @@ func DeleteEventMap(ifindex int32) {
 	delete(EventMap, evt)
 	evt.event = events.LacpdEventPortPartnerInfoMismatch
 	delete(EventMap, evt)
+	evt.event = events.LacpdEventPortErrDisabled
+	delete(EventMap, evt)
 }
 
 func ProcessLacpGroupOperStateDown(ifindex int32) {
@@ -243,3 +247,170 @@ func ProcessLacpPortPartnerInfoSync(ifindex int32) {
 		GlobalLogger.Err(fmt.Sprintf("Error in publishing LacpdEventPortPartnerInfoSync Event, ifindex %d not found", ifindex))
 	}
 }
+
+func ProcessLacpPortErrDisabled(ifindex int32) {
+	intfref := GetNameFromIfIndex(ifindex)
+
+	if intfref != "" {
+		evt := ifindex_event{
+			ifindex: ifindex,
+			event:   events.LacpdEventPortErrDisabled,
+		}
+
+		if isset, ok := EventMap[evt]; ok {
+			if !isset {
+				EventMap[evt] = true
+				evtKey := events.LacpPortEntryKey{
+					IntfRef: intfref,
+				}
+				txEvent := eventUtils.TxEvent{
+					EventId: events.LacpdEventPortErrDisabled,
+					Key:     evtKey,
+				}
+				err := eventUtils.PublishEvents(&txEvent)
+				if err != nil {
+					GlobalLogger.Err("Error in publishing LacpdEventPortErrDisabled Event")
+				}
+			}
+		}
+	} else {
+		GlobalLogger.Err(fmt.Sprintf("Error in publishing LacpdEventPortErrDisabled Event, ifindex %d not found", ifindex))
+	}
+}
+
+func ProcessLacpPortErrDisableRecovered(ifindex int32) {
+	intfref := GetNameFromIfIndex(ifindex)
+
+	if intfref != "" {
+		evt := ifindex_event{
+			ifindex: ifindex,
+			event:   events.LacpdEventPortErrDisabled,
+		}
+
+		if isset, ok := EventMap[evt]; ok {
+			if isset {
+				EventMap[evt] = false
+				evtKey := events.LacpPortEntryKey{
+					IntfRef: intfref,
+				}
+				txEvent := eventUtils.TxEvent{
+					EventId: events.LacpdEventPortErrDisableRecovered,
+					Key:     evtKey,
+				}
+				err := eventUtils.PublishEvents(&txEvent)
+				if err != nil {
+					GlobalLogger.Err("Error in publishing LacpdEventPortErrDisableRecovered Event")
+				}
+			}
+		}
+	} else {
+		GlobalLogger.Err(fmt.Sprintf("Error in publishing LacpdEventPortErrDisableRecovered Event, ifindex %d not found", ifindex))
+	}
+}
+
+// CreateOamEventMap adds the EFM OAM events of a port, kept separate from
+// the LACP events as OAM may run on ports which are not LAG members
+func CreateOamEventMap(ifindex int32) {
+	evt := ifindex_event{
+		ifindex: ifindex,
+		event:   events.LacpdEventOamLinkFault,
+	}
+	EventMap[evt] = false
+	evt.event = events.LacpdEventOamCriticalEvent
+	EventMap[evt] = false
+}
+
+func DeleteOamEventMap(ifindex int32) {
+	evt := ifindex_event{
+		ifindex: ifindex,
+		event:   events.LacpdEventOamLinkFault,
+	}
+	delete(EventMap, evt)
+	evt.event = events.LacpdEventOamCriticalEvent
+	delete(EventMap, evt)
+}
+
+// processOamStateEvent publishes setEvt when a condition is raised and
+// clearEvt when it clears, suppressing repeats of the same state
+func processOamStateEvent(ifindex int32, set bool, setEvt, clearEvt events.EventId, name string) {
+	intfref := GetNameFromIfIndex(ifindex)
+
+	if intfref != "" {
+		evt := ifindex_event{
+			ifindex: ifindex,
+			event:   setEvt,
+		}
+
+		if isset, ok := EventMap[evt]; ok {
+			if isset != set {
+				EventMap[evt] = set
+				evtId := setEvt
+				if !set {
+					evtId = clearEvt
+				}
+				evtKey := events.LacpPortEntryKey{
+					IntfRef: intfref,
+				}
+				txEvent := eventUtils.TxEvent{
+					EventId: evtId,
+					Key:     evtKey,
+				}
+				err := eventUtils.PublishEvents(&txEvent)
+				if err != nil {
+					GlobalLogger.Err(fmt.Sprintf("Error in publishing %s Event", name))
+				}
+			}
+		}
+	} else {
+		GlobalLogger.Err(fmt.Sprintf("Error in publishing %s Event, ifindex %d not found", name, ifindex))
+	}
+}
+
+// processOamEvent publishes a one shot event, every occurrence is reported
+func processOamEvent(ifindex int32, evtId events.EventId, name string) {
+	intfref := GetNameFromIfIndex(ifindex)
+
+	if intfref != "" {
+		evtKey := events.LacpPortEntryKey{
+			IntfRef: intfref,
+		}
+		txEvent := eventUtils.TxEvent{
+			EventId: evtId,
+			Key:     evtKey,
+		}
+		err := eventUtils.PublishEvents(&txEvent)
+		if err != nil {
+			GlobalLogger.Err(fmt.Sprintf("Error in publishing %s Event", name))
+		}
+	} else {
+		GlobalLogger.Err(fmt.Sprintf("Error in publishing %s Event, ifindex %d not found", name, ifindex))
+	}
+}
+
+func ProcessOamLinkFault(ifindex int32, set bool) {
+	processOamStateEvent(ifindex, set, events.LacpdEventOamLinkFault, events.LacpdEventOamLinkFaultCleared, "LacpdEventOamLinkFault")
+}
+
+func ProcessOamCriticalEvent(ifindex int32, set bool) {
+	processOamStateEvent(ifindex, set, events.LacpdEventOamCriticalEvent, events.LacpdEventOamCriticalEventCleared, "LacpdEventOamCriticalEvent")
+}
+
+func ProcessOamDyingGasp(ifindex int32) {
+	processOamEvent(ifindex, events.LacpdEventOamDyingGasp, "LacpdEventOamDyingGasp")
+}
+
+func ProcessOamErroredSymbolPeriod(ifindex int32) {
+	processOamEvent(ifindex, events.LacpdEventOamErroredSymbolPeriod, "LacpdEventOamErroredSymbolPeriod")
+}
+
+func ProcessOamErroredFrame(ifindex int32) {
+	processOamEvent(ifindex, events.LacpdEventOamErroredFrame, "LacpdEventOamErroredFrame")
+}
+
+func ProcessOamErroredFramePeriod(ifindex int32) {
+	processOamEvent(ifindex, events.LacpdEventOamErroredFramePeriod, "LacpdEventOamErroredFramePeriod")
+}
+
+func ProcessOamErroredFrameSecsSummary(ifindex int32) {
+	processOamEvent(ifindex, events.LacpdEventOamErroredFrameSecsSummary, "LacpdEventOamErroredFrameSecsSummary")
+}
@@ -33,6 +33,7 @@ import (
 	"fmt"
 	"l2/lacp/protocol/drcp"
 	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/oam"
 	"l2/lacp/protocol/utils"
 	"l2/lacp/server"
 	"lacpd"
@@ -151,6 +152,12 @@ func ConvertModelLacpPeriodToLaAggInterval(yangInterval int32) time.Duration {
 	return interval
 }
 
+// ConvertModelLacpPeriodToLaAggAutoRate returns true when the model period
+// is AUTO, the aggregator will start at the FAST interval
+func ConvertModelLacpPeriodToLaAggAutoRate(yangInterval int32) bool {
+	return yangInterval == 2
+}
+
 func ConvertLaAggIntervalToLacpPeriod(interval time.Duration) int32 {
 	var period int32
 	switch interval {
@@ -166,6 +173,15 @@ func ConvertLaAggIntervalToLacpPeriod(interval time.Duration) int32 {
 	return period
 }
 
+// ConvertLaAggLacpConfigToLacpPeriod will return AUTO when the adaptive rate
+// is enabled otherwise the period of the configured interval
+func ConvertLaAggLacpConfigToLacpPeriod(cfg lacp.LacpConfigInfo) int32 {
+	if cfg.AutoRate {
+		return 2
+	}
+	return ConvertLaAggIntervalToLacpPeriod(cfg.Interval)
+}
+
 func ConvertSqlBooleanToBool(sqlbool string) bool {
 	if sqlbool == "true" {
 		return true
@@ -251,38 +267,20 @@ func ConvertCdmMachineStateToYangState(state int) int32 {
 	return yangstate
 }
 
-var gAggKeyMap map[string]uint16
-var gAggKeyVal uint16
-var gAggKeyFreeList []uint16
-
-func GenerateKeyByAggName(AggName string) uint16 {
-	var rKey uint16
-	if len(gAggKeyFreeList) == 0 {
-		gAggKeyVal += 1
-		rKey = gAggKeyVal
-	} else {
-		rKey = gAggKeyFreeList[0]
-		// remove element from list
-		gAggKeyFreeList = append(gAggKeyFreeList[:0], gAggKeyFreeList[1:]...)
-	}
-	return rKey
-}
-
+// GetKeyByAggName will return the Actor_Admin_Aggregator_Key allocated to
+// the aggregator, if no key has been allocated yet one will be allocated
 func GetKeyByAggName(AggName string) uint16 {
-
-	var Key uint16
-	if gAggKeyMap == nil {
-		gAggKeyMap = make(map[string]uint16)
-		gAggKeyFreeList = make([]uint16, 0)
+	Key, err := lacp.LaAggKeyAllocate(AggName, 0)
+	if err != nil {
+		utils.GetLaLogger().Err(fmt.Sprintln("Unable to allocate key for", AggName, err))
 	}
+	return Key
+}
 
-	if _, ok := gAggKeyMap[AggName]; ok {
-		Key = gAggKeyMap[AggName]
-	} else {
-		Key = GenerateKeyByAggName(AggName)
-		// store the newly generated Key
-		gAggKeyMap[AggName] = Key
-	}
+// FindKeyByAggName will return the key allocated to the aggregator without
+// allocating a new key
+func FindKeyByAggName(AggName string) uint16 {
+	Key, _ := lacp.LaAggKeyGet(AggName)
 	return Key
 }
 
@@ -357,6 +355,31 @@ func (la *LACPDServiceHandler) HandleDbReadLaPortChannel(dbHdl *dbutils.DBUtil,
 	return nil
 }
 
+func (la *LACPDServiceHandler) HandleDbReadEthernetOam(dbHdl *dbutils.DBUtil, del bool) error {
+	if dbHdl != nil {
+		var dbObj objects.EthernetOam
+		objList, err := dbObj.GetAllObjFromDb(dbHdl)
+		if err != nil {
+			fmt.Println("DB Query failed when retrieving EthernetOam objects")
+			return err
+		}
+		for idx := 0; idx < len(objList); idx++ {
+			obj := lacpd.NewEthernetOam()
+			dbObject := objList[idx].(objects.EthernetOam)
+			objects.ConvertlacpdEthernetOamObjToThrift(&dbObject, obj)
+			if !del {
+				_, err = la.CreateEthernetOam(obj)
+			} else {
+				_, err = la.DeleteEthernetOam(obj)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (la *LACPDServiceHandler) ReadConfigFromDB(prevState int) error {
 	dbHdl := dbutils.NewDBUtil(utils.GetLaLogger())
 	err := dbHdl.Connect()
@@ -392,6 +415,11 @@ func (la *LACPDServiceHandler) ReadConfigFromDB(prevState int) error {
 			fmt.Println("Error getting All DistributedRelay objects")
 			return err
 		}
+
+		if err := la.HandleDbReadEthernetOam(dbHdl, true); err != nil {
+			fmt.Println("Error getting All EthernetOam objects")
+			return err
+		}
 	} else if prevState != currState {
 
 		if err := la.HandleDbReadDistributedRelay(dbHdl, false); err != nil {
@@ -403,11 +431,32 @@ func (la *LACPDServiceHandler) ReadConfigFromDB(prevState int) error {
 			fmt.Println("Error getting All LaPortChannel objects")
 			return err
 		}
+
+		if err := la.HandleDbReadEthernetOam(dbHdl, false); err != nil {
+			fmt.Println("Error getting All EthernetOam objects")
+			return err
+		}
 	}
 	return nil
 }
 
+// ConvertModelErrDisableToLaErrDisableConfig will convert the err-disable
+// attributes of the global object, intervals are in seconds
+func ConvertModelErrDisableToLaErrDisableConfig(config *lacpd.LacpGlobal) lacp.LaErrDisableConfig {
+	return lacp.LaErrDisableConfig{
+		ChurnEnable:           config.ErrDisableChurn,
+		ChurnCount:            int(config.ErrDisableChurnCount),
+		ChurnWindow:           time.Duration(config.ErrDisableChurnWindow) * time.Second,
+		LoopbackEnable:        config.ErrDisableLoopback,
+		PartnerMismatchEnable: config.ErrDisablePartnerMismatch,
+		PduStormEnable:        config.ErrDisablePduStorm,
+		PduStormRate:          int(config.ErrDisablePduStormRate),
+		RecoveryInterval:      time.Duration(config.ErrDisableRecoveryInterval) * time.Second,
+	}
+}
+
 func (la *LACPDServiceHandler) CreateLacpGlobal(config *lacpd.LacpGlobal) (bool, error) {
+	lacp.LaErrDisableConfigSet(ConvertModelErrDisableToLaErrDisableConfig(config))
 	if config.AdminState == "UP" {
 		prevState := utils.LacpGlobalStateGet()
 		utils.LacpGlobalStateSet(utils.LACP_GLOBAL_ENABLE)
@@ -426,6 +475,8 @@ func (la *LACPDServiceHandler) DeleteLacpGlobal(config *lacpd.LacpGlobal) (bool,
 func (la *LACPDServiceHandler) UpdateLacpGlobal(origconfig *lacpd.LacpGlobal, updateconfig *lacpd.LacpGlobal, attrset []bool, op []*lacpd.PatchOpInfo) (bool, error) {
 	prevState := utils.LacpGlobalStateGet()
 
+	lacp.LaErrDisableConfigSet(ConvertModelErrDisableToLaErrDisableConfig(updateconfig))
+
 	if updateconfig.AdminState == "UP" {
 		utils.LacpGlobalStateSet(utils.LACP_GLOBAL_ENABLE)
 	} else if updateconfig.AdminState == "DOWN" {
@@ -451,10 +502,18 @@ func (la *LACPDServiceHandler) UpdateLacpGlobal(origconfig *lacpd.LacpGlobal, up
 //	5 : i16 	MinLinks
 //	6 : string 	Type
 //	7 : string 	NameKey
-//	8 : i32 	Interval (0 == LONG, 1 == SHORT)
+//	8 : i32 	Interval (0 == LONG, 1 == SHORT, 2 == AUTO)
 //	9 : i32 	LacpMode (0 == ACTIVE, 1 == PASSIVE)
 //	10 : string SystemIdMac
 //	11 : i16 	SystemPriority
+//	12 : i16 	AdminKey (0 == allocated by system)
+//	13 : bool 	MicroBfdEnable
+//	14 : string MicroBfdLocalIp
+//	15 : string MicroBfdPeerIp
+//	16 : i32 	MicroBfdTxInterval (msec)
+//	17 : i32 	MicroBfdRxInterval (msec)
+//	18 : i32 	MicroBfdDetectMult
+//	19 : i32 	LacpAutoRateStableTime (sec, used when Interval == AUTO)
 func (la *LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel) (bool, error) {
 
 	aggModeMap := map[uint32]uint32{
@@ -488,10 +547,9 @@ func (la *LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel)
 		return false, errors.New(fmt.Sprintf("LACP: Error trying to create Lag %s that already exists", config.IntfRef))
 
 	} else {
-		id := GetKeyByAggName(nameKey)
 		conf := &lacp.LaAggConfig{
-			Id:  int(id),
-			Key: id,
+			// Actor_Admin_Aggregator_Key, allocated below if not supplied
+			Key: uint16(config.AdminKey),
 			// Identifier of the lag
 			Name: nameKey,
 			// Type of LAG STATIC or LACP
@@ -500,23 +558,42 @@ func (la *LACPDServiceHandler) CreateLaPortChannel(config *lacpd.LaPortChannel)
 			Enabled:  ConvertAdminStateStringToBool(config.AdminState),
 			// lacp config
 			Lacp: lacp.LacpConfigInfo{
-				Interval:       ConvertModelLacpPeriodToLaAggInterval(config.Interval),
-				Mode:           ConvertModelLacpModeToLaAggMode(config.LacpMode),
-				SystemIdMac:    switchIdMac,
-				SystemPriority: uint16(config.SystemPriority),
+				Interval:           ConvertModelLacpPeriodToLaAggInterval(config.Interval),
+				Mode:               ConvertModelLacpModeToLaAggMode(config.LacpMode),
+				SystemIdMac:        switchIdMac,
+				SystemPriority:     uint16(config.SystemPriority),
+				AutoRate:           ConvertModelLacpPeriodToLaAggAutoRate(config.Interval),
+				AutoRateStableTime: time.Duration(config.LacpAutoRateStableTime) * time.Second,
 			},
 			HashMode: uint32(config.LagHash),
+			MicroBfd: lacp.MicroBfdConfig{
+				Enable:                config.MicroBfdEnable,
+				LocalIp:               config.MicroBfdLocalIp,
+				PeerIp:                config.MicroBfdPeerIp,
+				DesiredMinTxInterval:  time.Duration(config.MicroBfdTxInterval) * time.Millisecond,
+				RequiredMinRxInterval: time.Duration(config.MicroBfdRxInterval) * time.Millisecond,
+				DetectMult:            uint8(config.MicroBfdDetectMult),
+			},
 		}
 		for _, intfref := range config.IntfRefList {
 			ifindex := utils.GetIfIndexFromName(intfref)
 			conf.LagMembers = append(conf.LagMembers, uint16(ifindex))
 		}
-		err1 := lacp.LaAggConfigAggCreateCheck(conf)
 		err2 := lacp.LaAggConfigParamCheck(conf)
+		if err2 != nil {
+			return false, err2
+		}
+		alloc, err3 := lacp.LaAggKeyReserve(nameKey, conf.Key)
+		if err3 != nil {
+			return false, err3
+		}
+		conf.Id = int(alloc.Key)
+		conf.Key = alloc.Key
+		err1 := lacp.LaAggConfigAggCreateCheck(conf)
 		if err1 != nil {
+			// restore the key db to the state prior to this create
+			alloc.Rollback()
 			return false, err1
-		} else if err2 != nil {
-			return false, err2
 		} else {
 			if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
 
@@ -570,7 +647,7 @@ func (la *LACPDServiceHandler) DeleteLaPortChannel(config *lacpd.LaPortChannel)
 			logger := utils.GetLaLogger()
 			logger.Info(fmt.Sprintln("Deleting La PortChannel", config.IntfRef))
 			//nameKey := fmt.Sprintf("agg-%d", config.LagId)
-			id := GetKeyByAggName(config.IntfRef)
+			id := FindKeyByAggName(config.IntfRef)
 			conf := &lacp.LaAggConfig{
 				Id: int(id),
 			}
@@ -581,6 +658,12 @@ func (la *LACPDServiceHandler) DeleteLaPortChannel(config *lacpd.LaPortChannel)
 			la.svr.ConfigCh <- cfg
 		}
 
+		// keep the key when lacp is globally disabled as the aggregator
+		// will be re-created with the same key when lacp is re-enabled
+		if utils.LacpGlobalStateGet() != utils.LACP_GLOBAL_DISABLE_PENDING {
+			lacp.LaAggKeyRelease(config.IntfRef)
+		}
+
 		return true, nil
 	}
 	return false, err
@@ -630,7 +713,11 @@ func (la *LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChann
 
 	nameKey := updateconfig.IntfRef
 
-	id := GetKeyByAggName(nameKey)
+	id := FindKeyByAggName(nameKey)
+	if updateconfig.AdminKey != 0 &&
+		uint16(updateconfig.AdminKey) != id {
+		return false, errors.New(fmt.Sprintf("LACP: Aggregator Key can not be changed on existing Lag %s", nameKey))
+	}
 	conf := &lacp.LaAggConfig{
 		Id:  int(id),
 		Key: id,
@@ -642,12 +729,22 @@ func (la *LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChann
 		Enabled:  ConvertAdminStateStringToBool(updateconfig.AdminState),
 		// lacp config
 		Lacp: lacp.LacpConfigInfo{
-			Interval:       ConvertModelLacpPeriodToLaAggInterval(updateconfig.Interval),
-			Mode:           ConvertModelLacpModeToLaAggMode(updateconfig.LacpMode),
-			SystemIdMac:    updateconfig.SystemIdMac,
-			SystemPriority: uint16(updateconfig.SystemPriority),
+			Interval:           ConvertModelLacpPeriodToLaAggInterval(updateconfig.Interval),
+			Mode:               ConvertModelLacpModeToLaAggMode(updateconfig.LacpMode),
+			SystemIdMac:        updateconfig.SystemIdMac,
+			SystemPriority:     uint16(updateconfig.SystemPriority),
+			AutoRate:           ConvertModelLacpPeriodToLaAggAutoRate(updateconfig.Interval),
+			AutoRateStableTime: time.Duration(updateconfig.LacpAutoRateStableTime) * time.Second,
 		},
 		HashMode: uint32(updateconfig.LagHash),
+		MicroBfd: lacp.MicroBfdConfig{
+			Enable:                updateconfig.MicroBfdEnable,
+			LocalIp:               updateconfig.MicroBfdLocalIp,
+			PeerIp:                updateconfig.MicroBfdPeerIp,
+			DesiredMinTxInterval:  time.Duration(updateconfig.MicroBfdTxInterval) * time.Millisecond,
+			RequiredMinRxInterval: time.Duration(updateconfig.MicroBfdRxInterval) * time.Millisecond,
+			DetectMult:            uint8(updateconfig.MicroBfdDetectMult),
+		},
 	}
 
 	ifindexList := make([]int32, 0)
@@ -706,7 +803,7 @@ func (la *LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChann
 								if !ok {
 									timeout = lacp.LacpLongTimeoutTime
 								}
-								id := GetKeyByAggName(nameKey)
+								id := FindKeyByAggName(nameKey)
 								conf := &lacp.LaAggPortConfig{
 									Id:       uint16(ifindex),
 									Prio:     uint16(a.Config.SystemPriority),
@@ -730,13 +827,20 @@ func (la *LACPDServiceHandler) UpdateLaPortChannel(origconfig *lacpd.LaPortChann
 			}
 
 			attrMap := map[string]server.LaConfigMsgType{
-				"AdminState":     server.LAConfigMsgUpdateLaPortChannelAdminState,
-				"LagType":        server.LAConfigMsgUpdateLaPortChannelLagType,
-				"LagHash":        server.LAConfigMsgUpdateLaPortChannelLagHash,
-				"LacpMode":       server.LAConfigMsgUpdateLaPortChannelAggMode,
-				"Interval":       server.LAConfigMsgUpdateLaPortChannelPeriod,
-				"SystemIdMac":    server.LAConfigMsgUpdateLaPortChannelSystemIdMac,
-				"SystemPriority": server.LAConfigMsgUpdateLaPortChannelSystemPriority,
+				"AdminState":             server.LAConfigMsgUpdateLaPortChannelAdminState,
+				"LagType":                server.LAConfigMsgUpdateLaPortChannelLagType,
+				"LagHash":                server.LAConfigMsgUpdateLaPortChannelLagHash,
+				"LacpMode":               server.LAConfigMsgUpdateLaPortChannelAggMode,
+				"Interval":               server.LAConfigMsgUpdateLaPortChannelPeriod,
+				"LacpAutoRateStableTime": server.LAConfigMsgUpdateLaPortChannelPeriod,
+				"SystemIdMac":            server.LAConfigMsgUpdateLaPortChannelSystemIdMac,
+				"SystemPriority":         server.LAConfigMsgUpdateLaPortChannelSystemPriority,
+				"MicroBfdEnable":         server.LAConfigMsgUpdateLaPortChannelMicroBfd,
+				"MicroBfdLocalIp":        server.LAConfigMsgUpdateLaPortChannelMicroBfd,
+				"MicroBfdPeerIp":         server.LAConfigMsgUpdateLaPortChannelMicroBfd,
+				"MicroBfdTxInterval":     server.LAConfigMsgUpdateLaPortChannelMicroBfd,
+				"MicroBfdRxInterval":     server.LAConfigMsgUpdateLaPortChannelMicroBfd,
+				"MicroBfdDetectMult":     server.LAConfigMsgUpdateLaPortChannelMicroBfd,
 			}
 
 			// important to note that the attrset starts at index 0 which is the BaseObj
@@ -862,7 +966,7 @@ func (la *LACPDServiceHandler) GetLaPortChannelState(IntfRef string) (*lacpd.LaP
 
 	if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
 		var a *lacp.LaAggregator
-		id := GetKeyByAggName(IntfRef)
+		id := FindKeyByAggName(IntfRef)
 		if lacp.LaFindAggById(int(id), &a) {
 			pcs.IntfRef = a.AggName
 			pcs.IfIndex = int32(a.HwAggId)
@@ -877,7 +981,7 @@ func (la *LACPDServiceHandler) GetLaPortChannelState(IntfRef string) (*lacpd.LaP
 				pcs.OperState = "UP"
 			}
 			pcs.MinLinks = int16(a.AggMinLinks)
-			pcs.Interval = ConvertLaAggIntervalToLacpPeriod(a.Config.Interval)
+			pcs.Interval = ConvertLaAggLacpConfigToLacpPeriod(a.Config)
 			pcs.LacpMode = ConvertLaAggModeToModelLacpMode(a.Config.Mode)
 			pcs.SystemIdMac = a.Config.SystemIdMac
 			pcs.SystemPriority = int16(a.Config.SystemPriority)
@@ -947,7 +1051,7 @@ func (la *LACPDServiceHandler) GetLaPortChannelState(IntfRef string) (*lacpd.LaP
 			*/
 			pcs.OperState = "DOWN"
 			pcs.MinLinks = int16(ac.MinLinks)
-			pcs.Interval = ConvertLaAggIntervalToLacpPeriod(ac.Lacp.Interval)
+			pcs.Interval = ConvertLaAggLacpConfigToLacpPeriod(ac.Lacp)
 			pcs.LacpMode = ConvertLaAggModeToModelLacpMode(ac.Lacp.Mode)
 			pcs.SystemIdMac = ac.Lacp.SystemIdMac
 			pcs.SystemPriority = int16(ac.Lacp.SystemPriority)
@@ -1003,7 +1107,7 @@ func (la *LACPDServiceHandler) GetBulkLaPortChannelState(fromIndex lacpd.Int, co
 				}
 				nextLagState.OperState = "DOWN"
 				nextLagState.MinLinks = int16(ac.MinLinks)
-				nextLagState.Interval = ConvertLaAggIntervalToLacpPeriod(ac.Lacp.Interval)
+				nextLagState.Interval = ConvertLaAggLacpConfigToLacpPeriod(ac.Lacp)
 				nextLagState.LacpMode = ConvertLaAggModeToModelLacpMode(ac.Lacp.Mode)
 				nextLagState.SystemIdMac = ac.Lacp.SystemIdMac
 				nextLagState.SystemPriority = int16(ac.Lacp.SystemPriority)
@@ -1048,7 +1152,7 @@ func (la *LACPDServiceHandler) GetBulkLaPortChannelState(fromIndex lacpd.Int, co
 					nextLagState.OperState = "UP"
 				}
 				nextLagState.MinLinks = int16(a.AggMinLinks)
-				nextLagState.Interval = ConvertLaAggIntervalToLacpPeriod(a.Config.Interval)
+				nextLagState.Interval = ConvertLaAggLacpConfigToLacpPeriod(a.Config)
 				nextLagState.LacpMode = ConvertLaAggModeToModelLacpMode(a.Config.Mode)
 				nextLagState.SystemIdMac = fmt.Sprintf("%02x:%02x:%02x:%02x:%02x:%02x", a.AggMacAddr[0],
 					a.AggMacAddr[1],
@@ -1236,6 +1340,13 @@ func (la *LACPDServiceHandler) GetLaPortChannelIntfRefListState(intfref string)
 
 			pcms.DrniName = p.DrniName
 			pcms.DrniSynced = p.DrniSynced
+			pcms.ErrDisableReason = p.ErrDisableReason()
+			pcms.MicroBfdState = p.MicroBfdStateGet()
+			autoRate := p.LaAutoRateStatsGet()
+			pcms.AutoRateState = autoRate.State
+			pcms.AutoRateFastTime = int64(autoRate.FastTime.Seconds())
+			pcms.AutoRateSlowTime = int64(autoRate.SlowTime.Seconds())
+			pcms.AutoRateTransitions = int64(autoRate.Transitions)
 
 			// partner info
 			pcms.PartnerId = p.PartnerOper.System.LacpSystemConvertSystemIdToString()
@@ -1455,6 +1566,13 @@ func (la *LACPDServiceHandler) GetBulkLaPortChannelIntfRefListState(fromIndex la
 				nextLagMemberState.OperKey = int16(p.ActorOper.Key)
 				nextLagMemberState.IntfRef = p.IntfNum
 				nextLagMemberState.IfIndex = utils.GetIfIndexFromName(p.IntfNum)
+				nextLagMemberState.ErrDisableReason = p.ErrDisableReason()
+				nextLagMemberState.MicroBfdState = p.MicroBfdStateGet()
+				autoRate := p.LaAutoRateStatsGet()
+				nextLagMemberState.AutoRateState = autoRate.State
+				nextLagMemberState.AutoRateFastTime = int64(autoRate.FastTime.Seconds())
+				nextLagMemberState.AutoRateSlowTime = int64(autoRate.SlowTime.Seconds())
+				nextLagMemberState.AutoRateTransitions = int64(autoRate.Transitions)
 
 				if p.AggAttached != nil {
 					nextLagMemberState.LagIntfRef = p.AggAttached.AggName
@@ -1537,7 +1655,7 @@ func (la *LACPDServiceHandler) convertDbObjDataToDRCPData(objData *objects.Distr
 	for i, val := range objData.IntfReflist {
 		cfgData.DrniIntraPortalLinkList[i] = uint32(utils.GetIfIndexFromName(val))
 	}
-	cfgData.DrniAggregator = uint32(GetKeyByAggName(objData.IntfRef))
+	cfgData.DrniAggregator = uint32(FindKeyByAggName(objData.IntfRef))
 
 	cfgData.DrniGatewayAlgorithm = objData.GatewayAlgorithm
 	cfgData.DrniNeighborAdminGatewayAlgorithm = objData.NeighborGatewayAlgorithm
@@ -1545,6 +1663,68 @@ func (la *LACPDServiceHandler) convertDbObjDataToDRCPData(objData *objects.Distr
 	cfgData.DrniNeighborAdminDRCPState = objData.NeighborAdminDRCPState
 	cfgData.DrniEncapMethod = objData.EncapMethod
 	cfgData.DrniIntraPortalPortProtocolDA = objData.IntraPortalPortProtocolDA
+	cfgData.DrniEcmpFlowHashFields = uint32(objData.EcmpFlowHashFields)
+	// 32 bit TE-SIDs are carried in the signed model attribute as is
+	for _, id := range objData.ServiceIdList {
+		cfgData.DrniServiceIdList = append(cfgData.DrniServiceIdList, uint32(id))
+	}
+	cfgData.DrniConvAdminGateway = convertDRCPConvAdminGateway(objData.ConvAdminGateway)
+	cfgData.DrniIPLEncapMap = convertDRCPEncapMap(objData.IPLEncapMap)
+	cfgData.DrniNetEncapMap = convertDRCPEncapMap(objData.NetEncapMap)
+	cfgData.DrniKeepaliveSourceIp = objData.PeerKeepaliveSrcIp
+	cfgData.DrniKeepaliveDestIp = objData.PeerKeepaliveDstIp
+	cfgData.DrniKeepaliveUdpPort = uint16(objData.PeerKeepaliveUdpPort)
+	cfgData.DrniKeepaliveInterval = uint32(objData.PeerKeepaliveInterval)
+	cfgData.DrniKeepalivePriority = uint16(objData.PeerKeepalivePriority)
+	cfgData.DrniSplitBrainPolicy = objData.SplitBrainPolicy
+}
+
+// convertDRCPEncapMap converts the model encap map entries of the format
+// "<conversation id>:<identifier>" to the drcp encap map.  An entry which
+// fails to convert will produce an invalid conversation id so that the param
+// check will reject the config
+func convertDRCPEncapMap(entries []string) map[uint32]uint32 {
+	encapMap := make(map[uint32]uint32)
+	for _, entry := range entries {
+		var cid, id uint32
+		_, err := fmt.Sscanf(entry, "%d:%d", &cid, &id)
+		if err != nil {
+			cid = drcp.MAX_CONVERSATION_IDS
+		}
+		encapMap[cid] = id
+	}
+	return encapMap
+}
+
+// convertDRCPConvAdminGateway converts the model conversation gateway entries
+// of the format "<conversation id>:<portal system>[,<portal system>...]", listed
+// in priority order, to the drcp conversation gateway lists.  An entry which
+// fails to convert will produce an invalid portal system so that the param
+// check will reject the config
+func convertDRCPConvAdminGateway(entries []string) (convAdminGateway [drcp.MAX_CONVERSATION_IDS][3]uint8) {
+	invalid := [3]uint8{drcp.DRNI_PORTAL_SYSTEM_ID_MAX + 1}
+	for _, entry := range entries {
+		fields := strings.Split(entry, ":")
+		cid, err := strconv.Atoi(fields[0])
+		if err != nil || len(fields) != 2 ||
+			cid < 0 || cid >= drcp.MAX_CONVERSATION_IDS {
+			convAdminGateway[0] = invalid
+			continue
+		}
+		systems := strings.Split(fields[1], ",")
+		if len(systems) > len(convAdminGateway[cid]) {
+			convAdminGateway[cid] = invalid
+			continue
+		}
+		for i, sysnum := range systems {
+			val, err := strconv.Atoi(sysnum)
+			if err != nil || val < 0 || val > drcp.DRNI_PORTAL_SYSTEM_ID_MAX {
+				val = drcp.DRNI_PORTAL_SYSTEM_ID_MAX + 1
+			}
+			convAdminGateway[cid][i] = uint8(val)
+		}
+	}
+	return convAdminGateway
 }
 
 func (la *LACPDServiceHandler) CreateDistributedRelay(config *lacpd.DistributedRelay) (bool, error) {
@@ -1555,6 +1735,10 @@ func (la *LACPDServiceHandler) CreateDistributedRelay(config *lacpd.DistributedR
 	conf := &drcp.DistributedRelayConfig{}
 	// convert to drcp module config data
 	la.convertDbObjDataToDRCPData(data, conf)
+	// the key is allocated when the aggregator is created
+	if conf.DrniAggregator == 0 {
+		return false, errors.New(fmt.Sprintf("ERROR Aggregator %s must be created before the Distributed Relay", data.IntfRef))
+	}
 	err1 := drcp.DistributedRelayConfigCreateCheck(conf.DrniName, conf.DrniAggregator)
 	err2 := drcp.DistributedRelayConfigParamCheck(conf)
 	if err1 != nil {
@@ -1562,6 +1746,7 @@ func (la *LACPDServiceHandler) CreateDistributedRelay(config *lacpd.DistributedR
 	} else if err2 != nil {
 		return false, err2
 	} else {
+		drcp.DistributedRelayConfigSave(conf.DrniName, conf.DrniAggregator)
 		if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
 
 			cfg := server.LAConfig{
@@ -1589,6 +1774,9 @@ func (la *LACPDServiceHandler) UpdateDistributedRelay(origconfig *lacpd.Distribu
 	newconf := &drcp.DistributedRelayConfig{}
 	// convert to drcp module config data
 	la.convertDbObjDataToDRCPData(newdata, newconf)
+	if newconf.DrniAggregator == 0 {
+		return false, errors.New(fmt.Sprintf("ERROR Aggregator %s must be created before the Distributed Relay", newdata.IntfRef))
+	}
 	err1 := drcp.DistributedRelayConfigCreateCheck(newconf.DrniName, newconf.DrniAggregator)
 	err2 := drcp.DistributedRelayConfigParamCheck(newconf)
 	if err1 != nil {
@@ -1596,17 +1784,49 @@ func (la *LACPDServiceHandler) UpdateDistributedRelay(origconfig *lacpd.Distribu
 	} else if err2 != nil {
 		return false, err2
 	} else {
+		drcp.DistributedRelayConfigSave(newconf.DrniName, newconf.DrniAggregator)
 		if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
-			// TODO need to set valid attribute types for update
-			attrMap := map[string]server.LaConfigMsgType{}
-			// lets deal with Members attribute first
+			// attributes which can be applied to a running portal, changes to
+			// the other attributes require the relay to be re-created
+			attrMap := map[string]server.LaConfigMsgType{
+				"PortalPriority":     server.LAConfigMsgUpdateDistributedRelayPortalPriority,
+				"GatewayAlgorithm":   server.LAConfigMsgUpdateDistributedRelayGatewayAlgorithm,
+				"EcmpFlowHashFields": server.LAConfigMsgUpdateDistributedRelayGatewayAlgorithm,
+				"ConvAdminGateway":   server.LAConfigMsgUpdateDistributedRelayConvAdminGateway,
+				"IntfReflist":        server.LAConfigMsgUpdateDistributedRelayIntraPortalLinkList,
+				"IPLEncapMap":        server.LAConfigMsgUpdateDistributedRelayEncapMap,
+				"NetEncapMap":        server.LAConfigMsgUpdateDistributedRelayEncapMap,
+				"ServiceIdList":      server.LAConfigMsgUpdateDistributedRelayServiceIdList,
+			}
+			recreate := false
+			for i := 0; i < objTyp.NumField(); i++ {
+				objName := objTyp.Field(i).Name
+				if _, ok := attrMap[objName]; attrset[i] && !ok {
+					recreate = true
+				}
+			}
+			if recreate {
+				fmt.Println("UpdateDistributedRelay (server): re-creating ", newconf.DrniName)
+				la.svr.ConfigCh <- server.LAConfig{
+					Msgtype: server.LAConfigMsgDeleteDistributedRelay,
+					Msgdata: oldconf,
+				}
+				la.svr.ConfigCh <- server.LAConfig{
+					Msgtype: server.LAConfigMsgCreateDistributedRelay,
+					Msgdata: newconf,
+				}
+				return true, nil
+			}
+
+			sentMsgs := make(map[server.LaConfigMsgType]bool)
 			for i := 0; i < objTyp.NumField(); i++ {
 				objName := objTyp.Field(i).Name
 				//fmt.Println("UpdateDistributedRelay (server): (index, objName) ", i, objName)
 				if attrset[i] {
 					fmt.Println("UpdateDistributedRelay (server): changed ", objName)
 
-					if msgtype, ok := attrMap[objName]; ok {
+					if msgtype, ok := attrMap[objName]; ok && !sentMsgs[msgtype] {
+						sentMsgs[msgtype] = true
 						// set message type
 						cfg := server.LAConfig{
 							Msgdata: newconf,
@@ -1746,12 +1966,220 @@ func (la *LACPDServiceHandler) GetBulkDistributedRelayState(fromIndex lacpd.Int,
 	return obj, err
 }
 
+// convertDRCPIppStateToIppLinkState will convert the drcp ipp state to the model
+//
+//	1 : string 	IntfRef
+//	2 : string 	DrNameRef
+//	3 : bool 	IppPortEnabled
+//	4 : bool 	DRCPEnabled
+//	5 : i8 	HomeConfNeighborPortalSystemNumber
+//	6 : i8 	NeighborPortalSystemNumber
+//	7 : i8 	NeighborConfPortalSystemNumber
+//	8 : string 	NeighborPortalAddr
+//	9 : i16 	NeighborPortalPriority
+//	10 : string NeighborOperDRCPState
+//	11 : bool 	NeighborONN
+//	12 : bool 	NeighborThreePortalSystem
+//	13 : bool 	DifferPortal
+//	14 : bool 	DifferConfPortal
+//	15 : bool 	DifferConfPortalSystemNumber
+//	16 : bool 	DifferGatewayDigest
+//	17 : bool 	DifferGatewayAlgorithm
+//	18 : bool 	DifferPortDigest
+//	19 : string DifferPortalReason
+//	20 : string RxMachineState
+//	21 : string PtxMachineState
+//	22 : string TxMachineState
+//	23 : string IAMachineState
+//	24 : string IGMachineState
+//	25 : string NetIplShareMachineState
+//	26 : string HomeConvGatewayListDigest
+//	27 : string NeighborConvGatewayListDigest
+//	28 : string HomeConvPortListDigest
+//	29 : string NeighborConvPortListDigest
+//	30 : string HomeIPLEncapDigest
+//	31 : string NeighborIPLEncapDigest
+//	32 : string HomeNetEncapDigest
+//	33 : string NeighborNetEncapDigest
+//	34 : list<string> NeighborPortalSystemState
+//	35 : i64 	DRCPDUsRx
+//	36 : i64 	IllegalRx
+//	37 : i64 	DRCPDUsTx
+//	38 : string LastRxTime
+func convertDRCPIppStateToIppLinkState(ippState *drcp.DRCPIppState, obj *lacpd.IppLinkState) {
+	obj.IntfRef = ippState.IntfRef
+	obj.DrNameRef = ippState.DrniName
+	obj.IppPortEnabled = ippState.IppPortEnabled
+	obj.DRCPEnabled = ippState.DRCPEnabled
+	obj.HomeConfNeighborPortalSystemNumber = int8(ippState.HomeConfNeighborPortalSystemNumber)
+	obj.NeighborPortalSystemNumber = int8(ippState.NeighborPortalSystemNumber)
+	obj.NeighborConfPortalSystemNumber = int8(ippState.NeighborConfPortalSystemNumber)
+	obj.NeighborPortalAddr = ippState.NeighborPortalAddr
+	obj.NeighborPortalPriority = int16(ippState.NeighborPortalPriority)
+	obj.NeighborOperDRCPState = ippState.NeighborOperDRCPState
+	obj.NeighborONN = ippState.NeighborONN
+	obj.NeighborThreePortalSystem = ippState.NeighborThreeSystemPortal
+	obj.DifferPortal = ippState.DifferPortal
+	obj.DifferConfPortal = ippState.DifferConfPortal
+	obj.DifferConfPortalSystemNumber = ippState.DifferConfPortalSystemNumber
+	obj.DifferGatewayDigest = ippState.DifferGatewayDigest
+	obj.DifferGatewayAlgorithm = ippState.DifferGatewayAlgorithm
+	obj.DifferPortDigest = ippState.DifferPortDigest
+	obj.DifferPortalReason = ippState.DifferPortalReason
+	obj.RxMachineState = ippState.RxMachineState
+	obj.PtxMachineState = ippState.PtxMachineState
+	obj.TxMachineState = ippState.TxMachineState
+	obj.IAMachineState = ippState.IAMachineState
+	obj.IGMachineState = ippState.IGMachineState
+	obj.NetIplShareMachineState = ippState.NetIplShareMachineState
+	obj.HomeConvGatewayListDigest = ippState.HomeConvGatewayListDigest
+	obj.NeighborConvGatewayListDigest = ippState.NeighborConvGatewayListDigest
+	obj.HomeConvPortListDigest = ippState.HomeConvPortListDigest
+	obj.NeighborConvPortListDigest = ippState.NeighborConvPortListDigest
+	obj.HomeIPLEncapDigest = ippState.HomeIPLEncapDigest
+	obj.NeighborIPLEncapDigest = ippState.NeighborIPLEncapDigest
+	obj.HomeNetEncapDigest = ippState.HomeNetEncapDigest
+	obj.NeighborNetEncapDigest = ippState.NeighborNetEncapDigest
+	obj.NeighborPortalSystemState = ippState.NeighborPortalSystemState
+	obj.DRCPDUsRx = int64(ippState.DRCPDUsRx)
+	obj.IllegalRx = int64(ippState.IllegalRx)
+	obj.DRCPDUsTx = int64(ippState.DRCPDUsTx)
+	obj.LastRxTime = "never"
+	if !ippState.LastRxTime.IsZero() {
+		obj.LastRxTime = ippState.LastRxTime.String()
+	}
+}
+
 func (la *LACPDServiceHandler) GetIppLinkState(intref, drnameref string) (obj *lacpd.IppLinkState, err error) {
+	obj = &lacpd.IppLinkState{}
+	if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
+		var ipp *drcp.DRCPIpp
+		if drcp.DRCPIppFindByName(intref, drnameref, &ipp) {
+			convertDRCPIppStateToIppLinkState(ipp.GetState(), obj)
+		} else {
+			err = errors.New(fmt.Sprintf("Unable to find IPP %s in Distributed Relay %s", intref, drnameref))
+		}
+	}
 	return obj, err
 }
 
 func (la *LACPDServiceHandler) GetBulkIppLinkState(fromIndex lacpd.Int, count lacpd.Int) (obj *lacpd.IppLinkStateGetInfo, err error) {
-	// TODO
+	var ippStateList []lacpd.IppLinkState = make([]lacpd.IppLinkState, count)
+	var nextIppState *lacpd.IppLinkState
+	var returnIppStates []*lacpd.IppLinkState
+	var returnIppStateGetInfo lacpd.IppLinkStateGetInfo
+	var ipp *drcp.DRCPIpp
+	validCount := lacpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnIppStateGetInfo
+
+	for currIndex := lacpd.Int(0); validCount != count && drcp.DRCPIppGetNext(&ipp); currIndex++ {
+
+		if currIndex < fromIndex {
+			continue
+		} else {
+			nextIppState = &ippStateList[validCount]
+			convertDRCPIppStateToIppLinkState(ipp.GetState(), nextIppState)
+
+			if len(returnIppStates) == 0 {
+				returnIppStates = make([]*lacpd.IppLinkState, 0)
+			}
+			returnIppStates = append(returnIppStates, nextIppState)
+			validCount++
+			toIndex++
+		}
+	}
+	// lets try and get the next ipp if one exists then there are more routes
+	moreRoutes := false
+	if ipp != nil {
+		moreRoutes = drcp.DRCPIppGetNext(&ipp)
+	}
+
+	obj.IppLinkStateList = returnIppStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+
+	return obj, err
+}
+
+// convertDRNIConsistencyReportToState will convert the drcp consistency report to the model
+//
+//	1 : string 	IntfRef
+//	2 : string 	DrNameRef
+//	3 : bool 	NeighborValid
+//	4 : bool 	Consistent
+//	5 : list<string> DiffList
+//	6 : list<i16> GatewayConversationDiff
+//	7 : list<i16> PortConversationDiff
+func convertDRNIConsistencyReportToState(report *drcp.DRNIConsistencyReport, obj *lacpd.DistributedRelayConsistencyState) {
+	obj.IntfRef = report.IntfRef
+	obj.DrNameRef = report.DrniName
+	obj.NeighborValid = report.NeighborValid
+	obj.Consistent = report.Consistent
+	for _, diff := range report.Diffs {
+		obj.DiffList = append(obj.DiffList, fmt.Sprintf("%s: home %s neighbor %s", diff.Field, diff.Home, diff.Neighbor))
+	}
+	for _, cid := range report.GatewayConversationDiff {
+		obj.GatewayConversationDiff = append(obj.GatewayConversationDiff, int16(cid))
+	}
+	for _, cid := range report.PortConversationDiff {
+		obj.PortConversationDiff = append(obj.PortConversationDiff, int16(cid))
+	}
+}
+
+func (la *LACPDServiceHandler) GetDistributedRelayConsistencyState(intref, drnameref string) (obj *lacpd.DistributedRelayConsistencyState, err error) {
+	obj = &lacpd.DistributedRelayConsistencyState{}
+	if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
+		var ipp *drcp.DRCPIpp
+		if drcp.DRCPIppFindByName(intref, drnameref, &ipp) {
+			convertDRNIConsistencyReportToState(ipp.ConsistencyCheck(), obj)
+		} else {
+			err = errors.New(fmt.Sprintf("Unable to find IPP %s in Distributed Relay %s", intref, drnameref))
+		}
+	}
+	return obj, err
+}
+
+func (la *LACPDServiceHandler) GetBulkDistributedRelayConsistencyState(fromIndex lacpd.Int, count lacpd.Int) (obj *lacpd.DistributedRelayConsistencyStateGetInfo, err error) {
+	var consistencyStateList []lacpd.DistributedRelayConsistencyState = make([]lacpd.DistributedRelayConsistencyState, count)
+	var nextConsistencyState *lacpd.DistributedRelayConsistencyState
+	var returnConsistencyStates []*lacpd.DistributedRelayConsistencyState
+	var returnConsistencyStateGetInfo lacpd.DistributedRelayConsistencyStateGetInfo
+	var ipp *drcp.DRCPIpp
+	validCount := lacpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnConsistencyStateGetInfo
+
+	for currIndex := lacpd.Int(0); validCount != count && drcp.DRCPIppGetNext(&ipp); currIndex++ {
+
+		if currIndex < fromIndex {
+			continue
+		} else {
+			nextConsistencyState = &consistencyStateList[validCount]
+			convertDRNIConsistencyReportToState(ipp.ConsistencyCheck(), nextConsistencyState)
+
+			if len(returnConsistencyStates) == 0 {
+				returnConsistencyStates = make([]*lacpd.DistributedRelayConsistencyState, 0)
+			}
+			returnConsistencyStates = append(returnConsistencyStates, nextConsistencyState)
+			validCount++
+			toIndex++
+		}
+	}
+	// lets try and get the next ipp if one exists then there are more routes
+	moreRoutes := false
+	if ipp != nil {
+		moreRoutes = drcp.DRCPIppGetNext(&ipp)
+	}
+
+	obj.DistributedRelayConsistencyStateList = returnConsistencyStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+
 	return obj, err
 }
 
@@ -1781,6 +2209,13 @@ func (la *LACPDServiceHandler) GetLacpGlobalState(vrf string) (*lacpd.LacpGlobal
 			obj.DistributedRelayList = append(obj.DistributedRelayList, dr.DrniName)
 		}
 
+		var ipp *drcp.DRCPIpp
+		for drcp.DRCPIppGetNext(&ipp) {
+			obj.DrcpTotalRxPkts += int64(ipp.DRCPDUsRX)
+			obj.DrcpTotalTxPkts += int64(ipp.DRCPDUsTX)
+			obj.DrcpErrorsInPkts += int64(ipp.IllegalRX)
+		}
+
 		var p *lacp.LaAggPort
 		for lacp.LaGetPortNext(&p) {
 			obj.LacpErrorsInPkts += int64(p.LacpCounter.AggPortStatsIllegalRx) + int64(p.LacpCounter.AggPortStatsUnknownRx)
@@ -1791,9 +2226,19 @@ func (la *LACPDServiceHandler) GetLacpGlobalState(vrf string) (*lacpd.LacpGlobal
 	} else {
 		var currIndex lacpd.Int
 		var ac *lacp.LaAggConfig
+		aggNameMap := make(map[uint32]string)
 		for currIndex = 0; lacp.LaAggConfigGetByIndex(int(currIndex), &ac); currIndex++ {
 			obj.AggList = append(obj.AggList, ac.Name)
-			// TODO need to add DRCP info
+			aggNameMap[uint32(ac.Id)] = ac.Name
+		}
+		// distributed relays are only configured, nothing is operational
+		drniname, aggid, ok := drcp.DistributedRelayConfigGetNext("")
+		for ok {
+			obj.DistributedRelayList = append(obj.DistributedRelayList, drniname)
+			if aggName, exists := aggNameMap[aggid]; exists {
+				obj.DistributedRelayAttachedList = append(obj.DistributedRelayAttachedList, fmt.Sprintf("%s-%s", drniname, aggName))
+			}
+			drniname, aggid, ok = drcp.DistributedRelayConfigGetNext(drniname)
 		}
 	}
 
@@ -1820,3 +2265,202 @@ func (la *LACPDServiceHandler) GetBulkLacpGlobalState(fromIndex lacpd.Int, count
 	obj.Count = 1
 	return obj, err
 }
+
+// ConvertModelEthernetOamToOamPortConfig will convert the EFM OAM model
+//
+//	1 : string 	IntfRef
+//	2 : string 	AdminState
+//	3 : string 	Mode (ACTIVE, PASSIVE)
+//	4 : bool 	LoopbackSupport
+//	5 : bool 	LinkEvents
+//	6 : i64 	ErrSymPeriodWindow (symbols)
+//	7 : i64 	ErrSymPeriodThreshold
+//	8 : i32 	ErrFrameWindow (100 msec)
+//	9 : i32 	ErrFrameThreshold
+//	10 : i32 	ErrFramePeriodWindow (frames)
+//	11 : i32 	ErrFramePeriodThreshold
+//	12 : bool 	RemoteLoopback
+func ConvertModelEthernetOamToOamPortConfig(config *lacpd.EthernetOam) *oam.OamPortConfig {
+	mode := oam.OamModeActive
+	if strings.ToUpper(config.Mode) == "PASSIVE" {
+		mode = oam.OamModePassive
+	}
+	return &oam.OamPortConfig{
+		IfIndex:                 utils.GetIfIndexFromName(config.IntfRef),
+		IntfRef:                 config.IntfRef,
+		Enable:                  ConvertAdminStateStringToBool(config.AdminState),
+		Mode:                    mode,
+		LoopbackSupport:         config.LoopbackSupport,
+		LinkEvents:              config.LinkEvents,
+		ErrSymPeriodWindow:      uint64(config.ErrSymPeriodWindow),
+		ErrSymPeriodThreshold:   uint64(config.ErrSymPeriodThreshold),
+		ErrFrameWindow:          uint16(config.ErrFrameWindow),
+		ErrFrameThreshold:       uint32(config.ErrFrameThreshold),
+		ErrFramePeriodWindow:    uint32(config.ErrFramePeriodWindow),
+		ErrFramePeriodThreshold: uint32(config.ErrFramePeriodThreshold),
+	}
+}
+
+func (la *LACPDServiceHandler) CreateEthernetOam(config *lacpd.EthernetOam) (bool, error) {
+	conf := ConvertModelEthernetOamToOamPortConfig(config)
+	if err := oam.OamPortConfigCreateCheck(conf); err != nil {
+		return false, err
+	}
+	if config.RemoteLoopback {
+		return false, errors.New(fmt.Sprintf("ERROR OAM remote loopback can only be requested once discovery completes on %s", config.IntfRef))
+	}
+	if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
+		cfg := server.LAConfig{
+			Msgtype: server.LAConfigMsgCreateEthernetOam,
+			Msgdata: conf,
+		}
+		la.svr.ConfigCh <- cfg
+	}
+	return true, nil
+}
+
+func (la *LACPDServiceHandler) UpdateEthernetOam(origconfig *lacpd.EthernetOam, updateconfig *lacpd.EthernetOam, attrset []bool, op []*lacpd.PatchOpInfo) (bool, error) {
+	objTyp := reflect.TypeOf(*origconfig)
+	conf := ConvertModelEthernetOamToOamPortConfig(updateconfig)
+	if err := oam.OamPortConfigParamCheck(conf); err != nil {
+		return false, err
+	}
+	if utils.LacpGlobalStateGet() != utils.LACP_GLOBAL_ENABLE {
+		return true, nil
+	}
+
+	configChanged := false
+	for i := 0; i < objTyp.NumField(); i++ {
+		objName := objTyp.Field(i).Name
+		if attrset[i] {
+			if objName == "RemoteLoopback" {
+				// action like attribute, apply now so the caller sees failures
+				if err := oam.OamPortRemoteLoopbackSet(conf.IfIndex, updateconfig.RemoteLoopback); err != nil {
+					return false, err
+				}
+			} else if objName != "IntfRef" {
+				configChanged = true
+			}
+		}
+	}
+	if configChanged {
+		cfg := server.LAConfig{
+			Msgtype: server.LAConfigMsgUpdateEthernetOam,
+			Msgdata: conf,
+		}
+		la.svr.ConfigCh <- cfg
+	}
+	return true, nil
+}
+
+func (la *LACPDServiceHandler) DeleteEthernetOam(config *lacpd.EthernetOam) (bool, error) {
+	if utils.LacpGlobalStateGet() == utils.LACP_GLOBAL_ENABLE {
+		conf := ConvertModelEthernetOamToOamPortConfig(config)
+		cfg := server.LAConfig{
+			Msgtype: server.LAConfigMsgDeleteEthernetOam,
+			Msgdata: conf,
+		}
+		la.svr.ConfigCh <- cfg
+	}
+	return true, nil
+}
+
+func ConvertOamPortStateToModel(p *oam.OamPort, obj *lacpd.EthernetOamState) {
+	s := p.OamPortStateGet()
+	obj.IntfRef = p.IntfRef
+	obj.AdminState = "DOWN"
+	if s.Config.Enable {
+		obj.AdminState = "UP"
+	}
+	obj.Mode = "PASSIVE"
+	if s.Config.Mode == oam.OamModeActive {
+		obj.Mode = "ACTIVE"
+	}
+	obj.DiscoveryState = s.DiscoveryState
+	obj.LocalPdu = s.LocalPdu
+	obj.LocalStable = s.LocalStable
+	obj.LocalSatisfied = s.LocalSatisfied
+	obj.LocalCriticalEvent = s.LocalCriticalEvent
+	obj.RemoteStable = s.RemoteStable
+	if s.RemoteStateValid {
+		obj.RemoteMac = s.RemoteMac
+		obj.RemoteMode = "PASSIVE"
+		if s.RemoteInfo.Config&oam.OamConfigModeActive != 0 {
+			obj.RemoteMode = "ACTIVE"
+		}
+		obj.RemoteLoopbackSupport = s.RemoteInfo.Config&oam.OamConfigLoopbackSupport != 0
+		obj.RemoteLinkEvents = s.RemoteInfo.Config&oam.OamConfigLinkEvents != 0
+		obj.RemoteOamPduSize = int32(s.RemoteInfo.PduConfig)
+		obj.RemoteRevision = int32(s.RemoteInfo.Revision)
+	}
+	obj.RemoteLinkFault = s.RemoteLinkFault
+	obj.RemoteCriticalEvent = s.RemoteCriticalEvent
+	obj.LoopbackStatus = s.LoopbackStatus
+	for _, tlv := range s.RemoteEvents {
+		switch tlv.Type {
+		case oam.OamEventTlvErroredSymbolPeriod:
+			obj.RemoteErroredSymbolPeriodEvents = int64(tlv.EventRunningTotal)
+		case oam.OamEventTlvErroredFrame:
+			obj.RemoteErroredFrameEvents = int64(tlv.EventRunningTotal)
+		case oam.OamEventTlvErroredFramePeriod:
+			obj.RemoteErroredFramePeriodEvents = int64(tlv.EventRunningTotal)
+		case oam.OamEventTlvErroredFrameSecsSummary:
+			obj.RemoteErroredFrameSecsSummaryEvents = int64(tlv.EventRunningTotal)
+		}
+	}
+	obj.InformationTx = int64(s.Counters.InformationTx)
+	obj.InformationRx = int64(s.Counters.InformationRx)
+	obj.EventNotificationTx = int64(s.Counters.EventNotificationTx)
+	obj.EventNotificationRx = int64(s.Counters.EventNotificationRx)
+	obj.DuplicateEventRx = int64(s.Counters.DuplicateEventRx)
+	obj.LoopbackControlTx = int64(s.Counters.LoopbackControlTx)
+	obj.LoopbackControlRx = int64(s.Counters.LoopbackControlRx)
+	obj.UnsupportedCodesRx = int64(s.Counters.UnsupportedCodesRx)
+	obj.DyingGaspRx = int64(s.Counters.DyingGaspRx)
+	obj.FramesLostDueToOam = int64(s.Counters.FramesLostDueToOam)
+}
+
+func (la *LACPDServiceHandler) GetEthernetOamState(intfref string) (*lacpd.EthernetOamState, error) {
+	obj := &lacpd.EthernetOamState{}
+	var p *oam.OamPort
+	if oam.OamFindPortByName(intfref, &p) {
+		ConvertOamPortStateToModel(p, obj)
+		return obj, nil
+	}
+	return obj, errors.New(fmt.Sprintf("Unable to find Ethernet OAM on %s", intfref))
+}
+
+func (la *LACPDServiceHandler) GetBulkEthernetOamState(fromIndex lacpd.Int, count lacpd.Int) (obj *lacpd.EthernetOamStateGetInfo, err error) {
+	var oamStateList []lacpd.EthernetOamState = make([]lacpd.EthernetOamState, count)
+	var returnOamStates []*lacpd.EthernetOamState
+	var returnOamStateGetInfo lacpd.EthernetOamStateGetInfo
+	var p *oam.OamPort
+	validCount := lacpd.Int(0)
+	toIndex := fromIndex
+	obj = &returnOamStateGetInfo
+
+	for currIndex := lacpd.Int(0); validCount != count && oam.OamGetPortNext(&p); currIndex++ {
+		if currIndex < fromIndex {
+			continue
+		}
+		nextOamState := &oamStateList[validCount]
+		ConvertOamPortStateToModel(p, nextOamState)
+		if len(returnOamStates) == 0 {
+			returnOamStates = make([]*lacpd.EthernetOamState, 0)
+		}
+		returnOamStates = append(returnOamStates, nextOamState)
+		validCount++
+		toIndex++
+	}
+	moreRoutes := false
+	if p != nil {
+		moreRoutes = oam.OamGetPortNext(&p)
+	}
+
+	obj.EthernetOamStateList = returnOamStates
+	obj.StartIdx = fromIndex
+	obj.EndIdx = toIndex + 1
+	obj.More = moreRoutes
+	obj.Count = validCount
+	return obj, err
+}
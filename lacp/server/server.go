@@ -6,6 +6,7 @@ import (
 	//"infra/sysd/sysdCommonDefs"
 	"l2/lacp/protocol/drcp"
 	"l2/lacp/protocol/lacp"
+	"l2/lacp/protocol/oam"
 	"l2/lacp/protocol/utils"
 	"utils/commonDefs"
 	//"utils/keepalive"
@@ -37,6 +38,17 @@ const (
 	LAConfigMsgDeleteConversationId
 	LAConfigMsgAddL3IntfType
 	LAConfigMsgAddL2IntfType
+	LAConfigMsgUpdateLaPortChannelMicroBfd
+	LAConfigMsgCreateEthernetOam
+	LAConfigMsgDeleteEthernetOam
+	LAConfigMsgUpdateEthernetOam
+	LAConfigMsgShutdown
+	LAConfigMsgUpdateDistributedRelayServiceIdList
+	LAConfigMsgUpdateDistributedRelayPortalPriority
+	LAConfigMsgUpdateDistributedRelayGatewayAlgorithm
+	LAConfigMsgUpdateDistributedRelayConvAdminGateway
+	LAConfigMsgUpdateDistributedRelayIntraPortalLinkList
+	LAConfigMsgUpdateDistributedRelayEncapMap
 )
 
 type LAConfig struct {
@@ -69,6 +81,7 @@ func (server *LAServer) InitServer() {
 	// TODO
 	//go server.ListenToClientStateChanges()
 	server.StartLaConfigNotificationListener()
+	// S-VID conversations are filled in when an S-VID portal is created
 	drcp.GetAllCVIDConversations()
 }
 
@@ -146,6 +159,11 @@ func (s *LAServer) processLaConfig(conf LAConfig) {
 		config := conf.Msgdata.(*lacp.LaAggConfig)
 		lacp.SetLaAggHashMode(config.Id, config.HashMode)
 
+	case LAConfigMsgUpdateLaPortChannelMicroBfd:
+		s.logger.Info("CONFIG: Link Aggregation Group / Port Channel Micro BFD")
+		config := conf.Msgdata.(*lacp.LaAggConfig)
+		lacp.SetLaAggMicroBfd(config.Id, config.MicroBfd)
+
 	case LAConfigMsgUpdateLaPortChannelSystemIdMac:
 		s.logger.Info("CONFIG: Link Aggregation Group / Port Channel SystemId MAC")
 		config := conf.Msgdata.(*lacp.LaAggConfig)
@@ -204,9 +222,12 @@ func (s *LAServer) processLaConfig(conf LAConfig) {
 		config := conf.Msgdata.(*lacp.LaAggConfig)
 		var a *lacp.LaAggregator
 		if lacp.LaFindAggById(config.Id, &a) {
-			// configured ports
-			for _, pId := range a.PortNumList {
-				lacp.SetLaAggPortLacpPeriod(uint16(pId), config.Lacp.Interval)
+			lacp.SetLaAggLacpAutoRate(config.Id, config.Lacp.AutoRate, config.Lacp.AutoRateStableTime)
+			if !config.Lacp.AutoRate {
+				// configured ports
+				for _, pId := range a.PortNumList {
+					lacp.SetLaAggPortLacpPeriod(uint16(pId), config.Lacp.Interval)
+				}
 			}
 		}
 	case LAConfigMsgCreateLaAggPort:
@@ -229,10 +250,42 @@ func (s *LAServer) processLaConfig(conf LAConfig) {
 		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
 		drcp.DeleteDistributedRelay(config.GetKey())
 
+	case LAConfigMsgUpdateDistributedRelayPortalPriority:
+		s.logger.Info("CONFIG: Update Distributed Relay Portal Priority")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayPortalPriority(config)
+
+	case LAConfigMsgUpdateDistributedRelayGatewayAlgorithm:
+		s.logger.Info("CONFIG: Update Distributed Relay Gateway Algorithm")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayGatewayAlgorithm(config)
+
+	case LAConfigMsgUpdateDistributedRelayConvAdminGateway:
+		s.logger.Info("CONFIG: Update Distributed Relay Conversation Admin Gateway")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayConvAdminGateway(config)
+
+	case LAConfigMsgUpdateDistributedRelayIntraPortalLinkList:
+		s.logger.Info("CONFIG: Update Distributed Relay Intra Portal Link List")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayIntraPortalLinkList(config)
+
+	case LAConfigMsgUpdateDistributedRelayEncapMap:
+		s.logger.Info("CONFIG: Update Distributed Relay Encap Map")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayEncapMap(config)
+
+	case LAConfigMsgUpdateDistributedRelayServiceIdList:
+		s.logger.Info("CONFIG: Update Distributed Relay Service Id List")
+		config := conf.Msgdata.(*drcp.DistributedRelayConfig)
+		drcp.UpdateDistributedRelayServiceIdList(config)
+
 	case LAConfigMsgCreateConversationId:
 		s.logger.Info("CONFIG: Create Conversation Id")
 		config := conf.Msgdata.(*drcp.DRConversationConfig)
-		drcp.CreateConversationId(config)
+		if err := drcp.CreateConversationId(config); err != nil {
+			s.logger.Err(err.Error())
+		}
 
 	case LAConfigMsgDeleteConversationId:
 		s.logger.Info("CONFIG: Delete Conversation Id")
@@ -244,6 +297,31 @@ func (s *LAServer) processLaConfig(conf LAConfig) {
 		config := conf.Msgdata.(*drcp.DRConversationConfig)
 		drcp.UpdateConversationId(config)
 
+	case LAConfigMsgCreateEthernetOam:
+		s.logger.Info("CONFIG: Create Ethernet OAM")
+		config := conf.Msgdata.(*oam.OamPortConfig)
+		if _, err := oam.CreateOamPort(config); err != nil {
+			s.logger.Err(err.Error())
+		}
+
+	case LAConfigMsgDeleteEthernetOam:
+		s.logger.Info("CONFIG: Delete Ethernet OAM")
+		config := conf.Msgdata.(*oam.OamPortConfig)
+		oam.DeleteOamPort(config.IfIndex)
+
+	case LAConfigMsgUpdateEthernetOam:
+		s.logger.Info("CONFIG: Update Ethernet OAM")
+		config := conf.Msgdata.(*oam.OamPortConfig)
+		if err := oam.UpdateOamPort(config); err != nil {
+			s.logger.Err(err.Error())
+		}
+
+	case LAConfigMsgShutdown:
+		s.logger.Info("CONFIG: Shutdown")
+		done := conf.Msgdata.(chan bool)
+		s.shutdown()
+		close(done)
+
 	case LAConfigMsgAddL2IntfType:
 		s.logger.Info("CONFIG: Update L2 Intf")
 		config := conf.Msgdata.(*commonDefs.IPv4L3IntfStateNotifyMsg)
@@ -256,6 +334,24 @@ func (s *LAServer) processLaConfig(conf LAConfig) {
 	}
 }
 
+// Shutdown will send an OAM dying gasp on all OAM ports so peers don't have
+// to wait for the lost link timer.  LAG and DR state is left in the hw so that
+// traffic is not dropped across a daemon restart.  The dying gasp runs on the
+// config listener so that it is serialized with any config currently being
+// processed
+func (s *LAServer) Shutdown() {
+	done := make(chan bool)
+	s.ConfigCh <- LAConfig{
+		Msgtype: LAConfigMsgShutdown,
+		Msgdata: done,
+	}
+	<-done
+}
+
+func (s *LAServer) shutdown() {
+	oam.OamDyingGaspAll()
+}
+
 func (s *LAServer) processLinkDownEvent(linkId int) {
 	s.logger.Info(fmt.Sprintln("LA EVT: Link Down", linkId))
 	var p *lacp.LaAggPort
@@ -319,6 +415,11 @@ func (s *LAServer) processVlanEvent(vlanMsg commonDefs.VlanNotifyMsg) {
 							Idtype:   drcp.GATEWAY_ALGORITHM_CVID,
 							Cvlan:    uint16(vlanMsg.VlanId),
 						}
+						// provider edge portal, vlans are S-VLANs
+						if dr.DrniGatewayAlgorithm == drcp.GATEWAY_ALGORITHM_SVID {
+							cfg.Idtype = drcp.GATEWAY_ALGORITHM_SVID
+							cfg.Svlan = uint16(vlanMsg.VlanId)
+						}
 
 						s.ConfigCh <- LAConfig{
 							Msgtype: msgtype,
@@ -367,8 +468,10 @@ func (s *LAServer) processAsicdNotification(msg commonDefs.AsicdNotifyMsg) {
 		s.logger.Info(fmt.Sprintf("Msg linkstatus = %d msg port = %d\n", l2Msg.IfState, l2Msg.IfIndex))
 		if l2Msg.IfState == asicdCommonDefs.INTF_STATE_DOWN {
 			s.processLinkDownEvent(asicdCommonDefs.GetIntfIdFromIfIndex(l2Msg.IfIndex)) //asicd always sends out link State events for PHY ports
+			oam.OamPortLinkStatusSet(l2Msg.IfIndex, false)
 		} else {
 			s.processLinkUpEvent(asicdCommonDefs.GetIntfIdFromIfIndex(l2Msg.IfIndex))
+			oam.OamPortLinkStatusSet(l2Msg.IfIndex, true)
 		}
 	case commonDefs.VlanNotifyMsg:
 		vlanMsg := msg.(commonDefs.VlanNotifyMsg)
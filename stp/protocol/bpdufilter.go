@@ -0,0 +1,76 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// bpdufilter.go
+// BPDU Filter
+//
+// Port BPDU Filter: the port will never transmit a BPDU and any received
+// BPDU is dropped.
+//
+// Global Edge BPDU Filter: operational edge ports will transmit
+// BpduFilterLinkUpTxCount BPDUs when the port becomes enabled and then stop
+// transmitting.  If a BPDU is received the filter is removed from the port
+// and the port reverts to normal operation until the port is enabled again.
+package stp
+
+// number of BPDUs sent on an edge port at link up before filtering
+const BpduFilterLinkUpTxCount = 11
+
+// IsBpduFilterEdgeActive returns whether the global edge filter applies to the port
+func (p *StpPort) IsBpduFilterEdgeActive() bool {
+	return StpGlobalBpduFilterEdgeGet() &&
+		p.OperEdge &&
+		!p.BpduFilterEdgeReverted
+}
+
+// BpduFilterLinkUp restarts the link up transmit window for the edge filter
+func (p *StpPort) BpduFilterLinkUp() {
+	p.BpduFilterLinkUpTxCnt = BpduFilterLinkUpTxCount
+	p.BpduFilterEdgeReverted = false
+}
+
+// BpduFilterTx returns true when a BPDU may be transmitted on the port
+func (p *StpPort) BpduFilterTx() bool {
+	if p.BpduFilter {
+		return false
+	}
+	if p.IsBpduFilterEdgeActive() {
+		if p.BpduFilterLinkUpTxCnt == 0 {
+			return false
+		}
+		p.BpduFilterLinkUpTxCnt--
+	}
+	return true
+}
+
+// BpduFilterRx returns true when a received BPDU should be processed
+func (p *StpPort) BpduFilterRx() bool {
+	if p.BpduFilter {
+		return false
+	}
+	if p.IsBpduFilterEdgeActive() {
+		StpMachineLogger("INFO", RxModuleStr, p.IfIndex, p.BrgIfIndex, "BPDU received on edge port, removing BPDU Filter")
+		p.BpduFilterEdgeReverted = true
+	}
+	return true
+}
@@ -38,7 +38,8 @@ var StpBridgeMac [6]uint8
 
 type BridgeId [8]uint8
 type BridgeKey struct {
-	Vlan uint16
+	Vlan  uint16
+	Mstid uint16
 }
 
 type Bridge struct {
@@ -59,12 +60,16 @@ type Bridge struct {
 	// Stp IfIndex
 	StpPorts []int32
 
-	ForceVersion int32
-	TxHoldCount  uint64
+	ForceVersion   int32
+	TxHoldCount    uint64
+	PathCostMethod PathCostMethod
 
 	// Vlan
 	Vlan uint16
 
+	// MSTI identifier, 0 == CIST
+	Mstid uint16
+
 	PrsMachineFsm *PrsMachine
 
 	// store the previous bridge id
@@ -82,11 +87,19 @@ type Bridge struct {
 	wg sync.WaitGroup
 
 	DebugLevel int
+
+	// topology change history
+	TcHistory      TcHistory
+	tcHistoryMutex sync.Mutex
 }
 
 type PriorityVector struct {
-	RootBridgeId       BridgeId
-	RootPathCost       uint32
+	RootBridgeId BridgeId
+	RootPathCost uint32
+	// 13.10 CIST regional root and internal root path cost,
+	// only used by the MSTP CIST
+	RegionalRootId     BridgeId
+	IntRootPathCost    uint32
 	DesignatedBridgeId BridgeId
 	DesignatedPortId   uint16
 	BridgePortId       uint16
@@ -97,6 +110,8 @@ type Times struct {
 	HelloTime       uint16
 	MaxAge          uint16
 	MessageAge      uint16
+	// 13.26.8 only used by the MSTP CIST
+	RemainingHops uint8
 }
 
 func SaveSwitchMac(switchMac string) {
@@ -111,10 +126,20 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 	if vlan == DEFAULT_STP_BRIDGE_VLAN {
 		bridgeId = CreateBridgeId(StpBridgeMac, c.Priority, 0)
 	}
+	// MSTI Bridge Identifier system id extension is the mstid
+	if c.Mstid != 0 {
+		bridgeId = CreateBridgeId(StpBridgeMac, c.Priority, c.Mstid)
+	}
+
+	forceVersion := int32(StpForceVersionRSTP)
+	if c.ForceVersion == StpForceVersionMSTP ||
+		IsStpForceVersionLegacy(c.ForceVersion) {
+		forceVersion = c.ForceVersion
+	}
 
 	b := &Bridge{
 		Begin:            true,
-		ForceVersion:     2,
+		ForceVersion:     forceVersion,
 		BridgeIdentifier: bridgeId,
 		BridgePriority: PriorityVector{
 			RootBridgeId:       bridgeId,
@@ -134,13 +159,16 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 			HelloTime:  c.HelloTime,
 			MaxAge:     c.MaxAge,
 			MessageAge: 0}, // this will be set once a port is set as root
-		TxHoldCount: uint64(c.TxHoldCount),
-		Vlan:        vlan,
-		DebugLevel:  c.DebugLevel,
+		TxHoldCount:    uint64(c.TxHoldCount),
+		PathCostMethod: c.PathCostMethod,
+		Vlan:           vlan,
+		Mstid:          c.Mstid,
+		DebugLevel:     c.DebugLevel,
 	}
 
 	key := BridgeKey{
-		Vlan: b.Vlan,
+		Vlan:  b.Vlan,
+		Mstid: b.Mstid,
 	}
 
 	BridgeMapTable[key] = b
@@ -151,7 +179,9 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 	BridgeListTable = append(BridgeListTable, b)
 
 	// TODO lets get the linux bridge
-	if c.Vlan == 0 {
+	if c.Mstid != 0 {
+		b.BrgIfIndex = MstiBrgIfIndex(c.Mstid)
+	} else if c.Vlan == 0 {
 		// default vlan
 		b.BrgIfIndex = DEFAULT_STP_BRIDGE_VLAN
 	} else {
@@ -160,7 +190,7 @@ func NewStpBridge(c *StpBridgeConfig) *Bridge {
 
 	// lets create the stg group
 	for _, client := range GetAsicDPluginList() {
-		b.StgId = client.CreateStgBridge([]uint16{b.Vlan})
+		b.StgId = client.CreateStgBridge(b.StgVlanList())
 	}
 	StpLogger("DEBUG", fmt.Sprintf("NEW BRIDGE: %#v\n", b))
 	return b
@@ -186,7 +216,8 @@ func DelStpBridge(b *Bridge, force bool) {
 	b.Stop()
 
 	key := BridgeKey{
-		Vlan: b.Vlan,
+		Vlan:  b.Vlan,
+		Mstid: b.Mstid,
 	}
 
 	delete(BridgeMapTable, key)
@@ -197,7 +228,7 @@ func DelStpBridge(b *Bridge, force bool) {
 			} else {
 				BridgeListTable = append(BridgeListTable[:i], BridgeListTable[i+1:]...)
 				for _, client := range GetAsicDPluginList() {
-					client.DeleteStgBridge(b.StgId, []uint16{b.Vlan})
+					client.DeleteStgBridge(b.StgId, b.StgVlanList())
 				}
 			}
 		}
@@ -336,6 +367,28 @@ func CompareBridgeAddr(b1 [6]uint8, b2 [6]uint8) int {
 
 }
 
+// CompareRootPathPriority compares the root bridge and root path cost of two
+// priority vectors, 13.10 for the MSTP CIST the regional root and internal
+// root path cost follow the external root path cost, otherwise these are zero
+func CompareRootPathPriority(v1 *PriorityVector, v2 *PriorityVector) int {
+	if compare := CompareBridgeId(v1.RootBridgeId, v2.RootBridgeId); compare != 0 {
+		return compare
+	} else if v1.RootPathCost != v2.RootPathCost {
+		if v1.RootPathCost < v2.RootPathCost {
+			return -1
+		}
+		return 1
+	} else if compare = CompareBridgeId(v1.RegionalRootId, v2.RegionalRootId); compare != 0 {
+		return compare
+	} else if v1.IntRootPathCost != v2.IntRootPathCost {
+		if v1.IntRootPathCost < v2.IntRootPathCost {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
 // 17.6 Priority vector calculations
 func IsMsgPriorityVectorSuperiorThanPortPriorityVector(msg *PriorityVector, port *PriorityVector) bool {
 	/*
@@ -347,23 +400,18 @@ func IsMsgPriorityVectorSuperiorThanPortPriorityVector(msg *PriorityVector, port
 				GetBridgeAddrFromBridgeId(port.DesignatedBridgeId)) == 0 &&
 				(msg.DesignatedPortId == port.DesignatedPortId))
 	*/
-	if CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) < 0 {
-		//StpLogger("DEBUG", "b1 root bridge id superior to b2 root bridge id")
-		return true
-	} else if (CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0) &&
-		(msg.RootPathCost < port.RootPathCost) {
-		//StpLogger("DEBUG", "b1 root bridge id equal b1 root bridge id and b1 root path superior to b2 root path cost")
+	compare := CompareRootPathPriority(msg, port)
+	if compare < 0 {
+		//StpLogger("DEBUG", "b1 root path priority superior to b2 root path priority")
 		return true
-	} else if (CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0) &&
-		(msg.RootPathCost == port.RootPathCost) &&
+	} else if compare == 0 &&
 		(CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) < 0) {
-		//StpLogger("DEBUG", "b1 root bridge id equal b1 root bridge id and b1 root path equal to b2 root path cost, desgn bridge id superior to b1 desgn bridge id")
+		//StpLogger("DEBUG", "b1 root path priority equal to b2 root path priority, desgn bridge id superior to b1 desgn bridge id")
 		return true
-	} else if (CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0) &&
-		(msg.RootPathCost == port.RootPathCost) &&
+	} else if compare == 0 &&
 		(CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) == 0) &&
 		(msg.DesignatedPortId < port.DesignatedPortId) {
-		//StpLogger("DEBUG", "b1 root bridge id equal b1 root bridge id and b1 root path equal to b2 root path cost, desgn bridge id equal to b1 desgn bridge id, b1 desgn portid superior to b2 desgn portid")
+		//StpLogger("DEBUG", "b1 root path priority equal to b2 root path priority, desgn bridge id equal to b1 desgn bridge id, b1 desgn portid superior to b2 desgn portid")
 		return true
 	} else if CompareBridgeAddr(GetBridgeAddrFromBridgeId(msg.DesignatedBridgeId),
 		GetBridgeAddrFromBridgeId(port.DesignatedBridgeId)) == 0 &&
@@ -388,10 +436,10 @@ func IsMsgPriorityVectorTheSameOrWorseThanPortPriorityVector(msg *PriorityVector
 }
 
 func IsMsgPriorityVectorWorseThanPortPriorityVector(msg *PriorityVector, port *PriorityVector) bool {
-	return (CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) > 0) ||
-		((CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0 && (msg.RootPathCost > port.RootPathCost)) ||
-			((CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0) && (msg.RootPathCost == port.RootPathCost) && (CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) > 0))) ||
-		((CompareBridgeId(msg.RootBridgeId, port.RootBridgeId) == 0) && (msg.RootPathCost == port.RootPathCost) && (CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) == 0) && (msg.DesignatedPortId > port.DesignatedPortId))
+	compare := CompareRootPathPriority(msg, port)
+	return (compare > 0) ||
+		((compare == 0) && (CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) > 0)) ||
+		((compare == 0) && (CompareBridgeId(msg.DesignatedBridgeId, port.DesignatedBridgeId) == 0) && (msg.DesignatedPortId > port.DesignatedPortId))
 
 }
 
@@ -32,16 +32,18 @@ import (
 
 // StpBridgeConfig config data
 type StpBridgeConfig struct {
-	IfIndex      int32
-	Address      string
-	Priority     uint16
-	MaxAge       uint16
-	HelloTime    uint16
-	ForwardDelay uint16
-	ForceVersion int32
-	TxHoldCount  int32
-	Vlan         uint16
-	DebugLevel   int
+	IfIndex        int32
+	Address        string
+	Priority       uint16
+	MaxAge         uint16
+	HelloTime      uint16
+	ForwardDelay   uint16
+	ForceVersion   int32
+	TxHoldCount    int32
+	Vlan           uint16
+	Mstid          uint16
+	PathCostMethod PathCostMethod
+	DebugLevel     int
 }
 
 // StpPortConfig config data
@@ -58,6 +60,9 @@ type StpPortConfig struct {
 	BridgeAssurance   bool
 	BpduGuard         bool
 	BpduGuardInterval int32
+	RootGuard         bool
+	LoopGuard         bool
+	BpduFilter        bool
 }
 
 // store the port config for each port
@@ -168,12 +173,20 @@ func StpBrgConfigParamCheck(c *StpBridgeConfig, create bool) error {
 		return errors.New(fmt.Sprintf("Invalid Bridge Hello Time %d valid range 3.0 - 30.0", c.ForwardDelay))
 	}
 
+	// 0 == STP (17.13.4 STP compatibility)
 	// 1 == STP
 	// 2 == RSTP
-	// 3 == MSTP currently not support
-	if c.ForceVersion != 1 &&
-		c.ForceVersion != 2 {
-		return errors.New(fmt.Sprintf("Invalid Bridge Force Version %d valid 1 (STP) 2 (RSTP)", c.ForceVersion))
+	// 3 == MSTP only supported on the default bridge (CIST)
+	if c.ForceVersion != StpForceVersionSTPCompat &&
+		c.ForceVersion != StpForceVersionSTP &&
+		c.ForceVersion != StpForceVersionRSTP &&
+		c.ForceVersion != StpForceVersionMSTP {
+		return errors.New(fmt.Sprintf("Invalid Bridge Force Version %d valid 0/1 (STP) 2 (RSTP) 3 (MSTP)", c.ForceVersion))
+	}
+
+	if c.ForceVersion == StpForceVersionMSTP &&
+		c.Vlan != DEFAULT_STP_BRIDGE_VLAN {
+		return errors.New(fmt.Sprintf("Invalid Bridge Force Version %d, MSTP only valid on default bridge not vlan %d", c.ForceVersion, c.Vlan))
 	}
 
 	if c.TxHoldCount < 1 ||
@@ -181,6 +194,11 @@ func StpBrgConfigParamCheck(c *StpBridgeConfig, create bool) error {
 		return errors.New(fmt.Sprintf("Invalid Bridge Tx Hold Count %d valid range 1 - 10", c.TxHoldCount))
 	}
 
+	if c.PathCostMethod != PathCostMethodLong &&
+		c.PathCostMethod != PathCostMethodShort {
+		return errors.New(fmt.Sprintf("Invalid Bridge Path Cost Method %d valid 0 (long) 1 (short)", c.PathCostMethod))
+	}
+
 	// if zero is used then we will convert this to use default
 	if c.Vlan != 0 {
 		if c.Vlan > 4095 {
@@ -227,6 +245,21 @@ func StpPortConfigParamCheck(c *StpPortConfig, update bool, create bool) error {
 		return errors.New(fmt.Sprintf("Invalid Port %d Bridge Assurance only available on non Edge Ports", c.IfIndex))
 	}
 
+	if (c.AdminEdgePort) &&
+		c.LoopGuard {
+		return errors.New(fmt.Sprintf("Invalid Port %d Loop Guard only available on non Edge Ports", c.IfIndex))
+	}
+
+	if c.RootGuard &&
+		c.LoopGuard {
+		return errors.New(fmt.Sprintf("Invalid Port %d Root Guard and Loop Guard can not both be enabled", c.IfIndex))
+	}
+
+	if c.BpduFilter &&
+		c.BridgeAssurance {
+		return errors.New(fmt.Sprintf("Invalid Port %d Bpdu Filter and Bridge Assurance can not both be enabled", c.IfIndex))
+	}
+
 	// all bridge port configurations are applied against all bridge ports applied to a given
 	// port, updates are applied to all bridge ports
 	// 9/20/16 relaxing this restriction as users will not know this
@@ -274,6 +307,9 @@ func StpBridgeDelete(c *StpBridgeConfig) error {
 		Vlan: c.Vlan,
 	}
 	if StpFindBridgeById(key, &b) {
+		if b.IsMstpCist() {
+			StpMstiDeleteAll()
+		}
 		DelStpBridge(b, true)
 		for _, btmp := range StpBridgeConfigMap {
 			if btmp.Vlan == c.Vlan {
@@ -300,6 +336,9 @@ func StpPortCreate(c *StpPortConfig) error {
 		if StpFindBridgeByIfIndex(c.BrgIfIndex, &b) {
 			p := NewStpPort(c)
 			StpPortAddToBridge(p.IfIndex, p.BrgIfIndex)
+			if b.IsMstpCist() {
+				b.MstiPortsCreate(p)
+			}
 		}
 	} else {
 		return errors.New(fmt.Sprintf("Invalid config, port %d bridge %d already exists", c.IfIndex, c.BrgIfIndex))
@@ -312,6 +351,9 @@ func StpPortDelete(c *StpPortConfig) error {
 	var b *Bridge
 	if StpFindPortByIfIndex(c.IfIndex, c.BrgIfIndex, &p) {
 		if StpFindBridgeByIfIndex(p.BrgIfIndex, &b) {
+			if b.IsMstpCist() {
+				b.MstiPortsDelete(c.IfIndex)
+			}
 			StpPortDelFromBridge(c.IfIndex, p.BrgIfIndex)
 		}
 		DelStpPort(p)
@@ -389,7 +431,8 @@ func StpPortEnable(pId int32, bId int32, enable bool) error {
 				}
 			} else {
 				for _, client := range GetAsicDPluginList() {
-					if client.GetPortLinkStatus(pId) {
+					if client.GetPortLinkStatus(pId) &&
+						!p.ErrDisabled {
 						defer p.NotifyPortEnabled("CONFIG: ", p.PortEnabled, true)
 						p.PortEnabled = true
 					}
@@ -409,7 +452,8 @@ func StpPortLinkUp(pId int32) {
 	for _, p := range PortListTable {
 		if p.IfIndex == pId {
 			p.CreateRxTx()
-			if p.AdminPortEnabled {
+			if p.AdminPortEnabled &&
+				!p.ErrDisabled {
 				defer p.NotifyPortEnabled("LINK EVENT", p.PortEnabled, true)
 				p.PortEnabled = true
 			}
@@ -472,9 +516,13 @@ func StpBrgForceVersion(bId int32, version int32) error {
 	var b *Bridge
 	var p *StpPort
 	if StpFindBridgeByIfIndex(bId, &b) {
-		// version 1 STP
+		// version 0/1 STP
 		// version 2 RSTP
 		if b.ForceVersion != version {
+			if b.IsMstpCist() &&
+				len(MstiBridges()) != 0 {
+				return errors.New(fmt.Sprintf("Invalid Force Version %d, MSTIs must be deleted before leaving MSTP", version))
+			}
 			c := StpBrgConfigGet(bId)
 			c.ForceVersion = version
 			err := StpBrgConfigParamCheck(c, false)
@@ -482,7 +530,7 @@ func StpBrgForceVersion(bId int32, version int32) error {
 				b.ForceVersion = version
 				for _, pId := range b.StpPorts {
 					if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
-						if b.ForceVersion == 1 {
+						if b.IsLegacyStp() {
 							p.RstpVersion = false
 						} else {
 							p.RstpVersion = true
@@ -546,10 +594,21 @@ func StpPortPrioritySet(pId int32, bId int32, priority uint16) error {
 	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Port Priority", pId, bId))
 }
 
-// StpPortPortPathCostSet N/A
+// StpPortPortPathCostSet will set the port path cost, a path cost of 1 will
+// auto discover the path cost from the speed of the port
 func StpPortPortPathCostSet(pId int32, bId int32, pathcost uint32) error {
-	// TODO
-	return nil
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if pathcost <= 1 {
+			p.PathCostAuto = true
+			p.PathCostUpdate("CONFIG: PortPathCostSet")
+		} else {
+			p.PathCostAuto = false
+			p.PortPathCostApply("CONFIG: PortPathCostSet", pathcost)
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Port Path Cost", pId, bId))
 }
 
 // StpPortAdminEdgeSet will set all bridge port as admin edge ports
@@ -783,6 +842,10 @@ func StpPortBpduGuardSet(pId int32, bId int32, bpduguard bool) error {
 				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Clearing BPDU Guard")
 			}
 			p.BpduGuard = bpduguard
+			if !bpduguard &&
+				p.ErrDisableReason == ErrDisableReasonBpduGuard {
+				p.ErrDisableRecover("CONFIG: BpduGuardSet")
+			}
 
 			//return err
 			return nil
@@ -830,3 +893,74 @@ func StpPortBridgeAssuranceSet(pId int32, bId int32, bridgeassurance bool) error
 	}
 	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Bridge Assurance", pId, bId))
 }
+
+func StpPortRootGuardSet(pId int32, bId int32, rootguard bool) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.RootGuard != rootguard {
+			if rootguard &&
+				p.LoopGuard {
+				return errors.New(fmt.Sprintf("Invalid Port %d Root Guard and Loop Guard can not both be enabled", pId))
+			}
+			if rootguard {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Setting Root Guard")
+			} else {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Clearing Root Guard")
+			}
+			p.RootGuard = rootguard
+			// re-evaluate the port role against the guard
+			p.GuardReselect("CONFIG: RootGuardSet")
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Root Guard", pId, bId))
+}
+
+func StpPortLoopGuardSet(pId int32, bId int32, loopguard bool) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.LoopGuard != loopguard {
+			if loopguard &&
+				p.RootGuard {
+				return errors.New(fmt.Sprintf("Invalid Port %d Root Guard and Loop Guard can not both be enabled", pId))
+			}
+			if loopguard &&
+				p.OperEdge {
+				return errors.New(fmt.Sprintf("Invalid Port %d Loop Guard only available on non Edge Ports", pId))
+			}
+			if loopguard {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Setting Loop Guard")
+			} else {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Clearing Loop Guard")
+			}
+			p.LoopGuard = loopguard
+			if !loopguard &&
+				p.LoopGuardInconsistant {
+				p.SetLoopGuardInconsistant("CONFIG: LoopGuardSet", false)
+				p.GuardReselect("CONFIG: LoopGuardSet")
+			}
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Loop Guard", pId, bId))
+}
+
+func StpPortBpduFilterSet(pId int32, bId int32, bpdufilter bool) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		if p.BpduFilter != bpdufilter {
+			if bpdufilter &&
+				p.BridgeAssurance {
+				return errors.New(fmt.Sprintf("Invalid Port %d Bpdu Filter and Bridge Assurance can not both be enabled", pId))
+			}
+			if bpdufilter {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Setting BPDU Filter")
+			} else {
+				StpMachineLogger("INFO", "CONFIG", p.IfIndex, p.BrgIfIndex, "Clearing BPDU Filter")
+			}
+			p.BpduFilter = bpdufilter
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for setting Bpdu Filter", pId, bId))
+}
@@ -783,6 +783,89 @@ func TestStpPortParamAdminPathCost(t *testing.T) {
 	*/
 }
 
+func TestStpPortPathCostSpeedChange(t *testing.T) {
+	defer MemoryCheck(t)
+
+	p, b := StpPortConfigSetup(true, false)
+	defer StpPortConfigDelete(p.IfIndex)
+	if b != nil {
+		defer StpBridgeDelete(b)
+	}
+
+	StpPortCreate(p)
+	defer StpPortDelete(p)
+
+	var port *StpPort
+	if !StpFindPortByIfIndex(p.IfIndex, p.BrgIfIndex, &port) {
+		t.Error("ERROR: did not find bridge port")
+		return
+	}
+
+	if port.PathCostAuto {
+		t.Error("ERROR: configured path cost should not be auto")
+	}
+
+	// path cost of 1 auto discovers the path cost from the port speed
+	StpPortSpeedSet(p.IfIndex, 1000)
+	err := StpPortPortPathCostSet(p.IfIndex, p.BrgIfIndex, 1)
+	if err != nil {
+		t.Error("ERROR: failed to set port path cost", err)
+	}
+	if !port.PathCostAuto || port.PortPathCost != PortPathCost1Gb {
+		t.Error("ERROR: auto path cost not set from port speed", port.PathCostAuto, port.PortPathCost)
+	}
+
+	StpPortSpeedSet(p.IfIndex, 10000)
+	if port.PortPathCost != PortPathCost10Gb {
+		t.Error("ERROR: auto path cost not updated on speed change", port.PortPathCost)
+	}
+
+	// 802.1D-1998 short method
+	err = StpBrgPathCostMethodSet(p.BrgIfIndex, PathCostMethodShort)
+	if err != nil {
+		t.Error("ERROR: failed to set bridge path cost method", err)
+	}
+	if port.PortPathCost != 2 {
+		t.Error("ERROR: short method path cost not applied", port.PortPathCost)
+	}
+	StpBrgPathCostMethodSet(p.BrgIfIndex, PathCostMethodLong)
+
+	// LAG speed is the aggregate of the member speeds which are up
+	lagifindex := int32(1000)
+	StpPortLinkStateSet(p.IfIndex, true)
+	StpLagMembersSet(lagifindex, []int32{p.IfIndex})
+	if PortConfigMap[lagifindex].Speed != 10000 {
+		t.Error("ERROR: LAG speed not set from member speed", PortConfigMap[lagifindex].Speed)
+	}
+	StpPortSpeedSet(p.IfIndex, 1000)
+	if PortConfigMap[lagifindex].Speed != 1000 {
+		t.Error("ERROR: LAG speed not updated on member speed change", PortConfigMap[lagifindex].Speed)
+	}
+	StpPortLinkStateSet(p.IfIndex, false)
+	if PortConfigMap[lagifindex].Speed != 0 {
+		t.Error("ERROR: LAG speed should not include members which are down", PortConfigMap[lagifindex].Speed)
+	}
+	StpLagMembersSet(lagifindex, nil)
+	delete(PortConfigMap, lagifindex)
+
+	// configured path cost disables auto
+	err = StpPortPortPathCostSet(p.IfIndex, p.BrgIfIndex, 200)
+	if err != nil {
+		t.Error("ERROR: failed to set port path cost", err)
+	}
+	if port.PathCostAuto || port.PortPathCost != 200 {
+		t.Error("ERROR: configured path cost not applied", port.PathCostAuto, port.PortPathCost)
+	}
+
+	StpPortSpeedSet(p.IfIndex, 10000)
+	if port.PortPathCost != 200 {
+		t.Error("ERROR: configured path cost should not change on speed change", port.PortPathCost)
+	}
+
+	// give test time to complete
+	time.Sleep(time.Millisecond * 10)
+}
+
 func TestStpPortParamBridgeAssurance(t *testing.T) {
 	defer MemoryCheck(t)
 	p, b := StpPortConfigSetup(true, false)
@@ -895,6 +978,260 @@ func TestStpPortParamBridgeAssurance(t *testing.T) {
 	time.Sleep(time.Millisecond * 10)
 }
 
+func TestStpPortParamRootGuardLoopGuard(t *testing.T) {
+	defer MemoryCheck(t)
+	p, b := StpPortConfigSetup(true, false)
+	defer StpPortConfigDelete(p.IfIndex)
+	if b != nil {
+		defer StpBridgeDelete(b)
+	}
+
+	StpPortCreate(p)
+	defer StpPortDelete(p)
+
+	// loop guard is only valid on an non edge port
+	p.AdminEdgePort = true
+	p.LoopGuard = true
+	err := StpPortConfigParamCheck(p, true, false)
+	if err == nil {
+		t.Error("ERROR: an invalid port config Admin Edge and Loop Guard set should have errored", p.AdminEdgePort, p.LoopGuard, err)
+	}
+
+	// root guard and loop guard are mutually exclusive
+	p.AdminEdgePort = false
+	p.RootGuard = true
+	err = StpPortConfigParamCheck(p, true, false)
+	if err == nil {
+		t.Error("ERROR: an invalid port config Root Guard and Loop Guard set should have errored", p.RootGuard, p.LoopGuard, err)
+	}
+
+	p.LoopGuard = false
+	err = StpPortConfigParamCheck(p, true, false)
+	if err != nil {
+		t.Error("ERROR: valid port config Root Guard set should not have errored", p.RootGuard, err)
+	}
+
+	err = StpPortRootGuardSet(p.IfIndex, p.BrgIfIndex, true)
+	if err != nil {
+		t.Error("ERROR: failed to set Root Guard on port", err)
+	}
+
+	err = StpPortLoopGuardSet(p.IfIndex, p.BrgIfIndex, true)
+	if err == nil {
+		t.Error("ERROR: Loop Guard set while Root Guard enabled should have errored")
+	}
+
+	var port *StpPort
+	if !StpFindPortByIfIndex(p.IfIndex, p.BrgIfIndex, &port) {
+		t.Error("ERROR: did not find bridge port")
+		return
+	}
+
+	// superior root received on a root guard port
+	port.PortPriority.RootBridgeId = CreateBridgeId([6]uint8{0x00, 0x00, 0x00, 0x00, 0x00, 0x01}, 0, 0)
+	if !port.IsRootGuardSuperiorInfo() {
+		t.Error("ERROR: superior root not detected on Root Guard port")
+	}
+	port.PortPriority.RootBridgeId = CreateBridgeId([6]uint8{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, 61440, 0)
+	if port.IsRootGuardSuperiorInfo() {
+		t.Error("ERROR: inferior root detected as superior on Root Guard port")
+	}
+
+	err = StpPortRootGuardSet(p.IfIndex, p.BrgIfIndex, false)
+	if err != nil {
+		t.Error("ERROR: failed to clear Root Guard on port", err)
+	}
+
+	err = StpPortLoopGuardSet(p.IfIndex, p.BrgIfIndex, true)
+	if err != nil {
+		t.Error("ERROR: failed to set Loop Guard on port", err)
+	}
+
+	if !port.LoopGuard || port.RootGuard {
+		t.Error("ERROR: Root Guard/Loop Guard not set in db record", port.RootGuard, port.LoopGuard)
+	}
+
+	// designated ports are not affected by loop guard
+	port.Role = PortRoleDesignatedPort
+	if port.LoopGuardRcvdInfoWhileExpired() {
+		t.Error("ERROR: Loop Guard should not apply to a designated port")
+	}
+
+	err = StpPortLoopGuardSet(p.IfIndex, p.BrgIfIndex, false)
+	if err != nil {
+		t.Error("ERROR: failed to clear Loop Guard on port", err)
+	}
+
+	// give test time to complete
+	time.Sleep(time.Millisecond * 10)
+}
+
+func TestStpPortParamBpduFilter(t *testing.T) {
+	defer MemoryCheck(t)
+	p, b := StpPortConfigSetup(true, false)
+	defer StpPortConfigDelete(p.IfIndex)
+	if b != nil {
+		defer StpBridgeDelete(b)
+	}
+	defer StpGlobalBpduFilterEdgeSet(false)
+
+	StpPortCreate(p)
+	defer StpPortDelete(p)
+
+	// bpdu filter and bridge assurance are mutually exclusive
+	p.BpduFilter = true
+	p.BridgeAssurance = true
+	err := StpPortConfigParamCheck(p, true, false)
+	if err == nil {
+		t.Error("ERROR: an invalid port config Bpdu Filter and Bridge Assurance set should have errored", p.BpduFilter, p.BridgeAssurance, err)
+	}
+
+	p.BridgeAssurance = false
+	err = StpPortConfigParamCheck(p, true, false)
+	if err != nil {
+		t.Error("ERROR: valid port config Bpdu Filter set should not have errored", p.BpduFilter, err)
+	}
+
+	var port *StpPort
+	if !StpFindPortByIfIndex(p.IfIndex, p.BrgIfIndex, &port) {
+		t.Error("ERROR: did not find bridge port")
+		return
+	}
+
+	err = StpPortBpduFilterSet(p.IfIndex, p.BrgIfIndex, true)
+	if err != nil {
+		t.Error("ERROR: failed to set Bpdu Filter on port", err)
+	}
+
+	if port.BpduFilterTx() || port.BpduFilterRx() {
+		t.Error("ERROR: BPDU should be filtered on a Bpdu Filter port")
+	}
+
+	err = StpPortBpduFilterSet(p.IfIndex, p.BrgIfIndex, false)
+	if err != nil {
+		t.Error("ERROR: failed to clear Bpdu Filter on port", err)
+	}
+
+	// global edge filter sends a few BPDUs at link up then stops
+	StpGlobalBpduFilterEdgeSet(true)
+	port.OperEdge = true
+	port.BpduFilterLinkUp()
+	for i := 0; i < BpduFilterLinkUpTxCount; i++ {
+		if !port.BpduFilterTx() {
+			t.Error("ERROR: BPDU should be sent during link up on edge port", i)
+		}
+	}
+	if port.BpduFilterTx() {
+		t.Error("ERROR: BPDU should be filtered on edge port after link up")
+	}
+
+	// a received BPDU reverts the port to normal operation
+	if !port.BpduFilterRx() {
+		t.Error("ERROR: BPDU should be received on edge port")
+	}
+	if port.IsBpduFilterEdgeActive() || !port.BpduFilterTx() {
+		t.Error("ERROR: Edge BPDU Filter should be removed after BPDU received")
+	}
+
+	// non edge ports are not filtered
+	port.BpduFilterLinkUp()
+	port.OperEdge = false
+	if port.IsBpduFilterEdgeActive() {
+		t.Error("ERROR: Edge BPDU Filter should not apply to non edge port")
+	}
+
+	// give test time to complete
+	time.Sleep(time.Millisecond * 10)
+}
+
+func TestStpPortBpduGuardErrDisable(t *testing.T) {
+	defer MemoryCheck(t)
+	p, b := StpPortConfigSetup(true, false)
+	defer StpPortConfigDelete(p.IfIndex)
+	if b != nil {
+		defer StpBridgeDelete(b)
+	}
+
+	p.AdminEdgePort = true
+	p.BpduGuard = true
+	p.BpduGuardInterval = 0
+	StpPortCreate(p)
+	defer StpPortDelete(p)
+
+	var port *StpPort
+	if !StpFindPortByIfIndex(p.IfIndex, p.BrgIfIndex, &port) {
+		t.Error("ERROR: did not find bridge port")
+		return
+	}
+
+	port.BpduGuardDetected("TEST")
+	if !port.ErrDisabled ||
+		port.ErrDisableReason != ErrDisableReasonBpduGuard ||
+		port.PortEnabled {
+		t.Error("ERROR: port should be err-disabled by BPDU Guard", port.ErrDisabled, port.ErrDisableReason, port.PortEnabled)
+	}
+
+	// no recovery interval, port remains err-disabled until cleared
+	if port.BPDUGuardTimer.count != 0 {
+		t.Error("ERROR: recovery timer should not be running", port.BPDUGuardTimer.count)
+	}
+
+	err := StpPortErrDisableClear(p.IfIndex, p.BrgIfIndex)
+	if err != nil {
+		t.Error("ERROR: failed to clear err-disable on port", err)
+	}
+	if port.ErrDisabled ||
+		port.ErrDisableReason != ErrDisableReasonNone {
+		t.Error("ERROR: port should have recovered from err-disable", port.ErrDisabled, port.ErrDisableReason)
+	}
+
+	err = StpPortErrDisableClear(p.IfIndex, p.BrgIfIndex+1)
+	if err == nil {
+		t.Error("ERROR: clearing err-disable on an invalid port should have errored")
+	}
+
+	// give test time to complete
+	time.Sleep(time.Millisecond * 10)
+}
+
+func TestStpPortBpduGuardErrDisablePerVlan(t *testing.T) {
+	defer MemoryCheck(t)
+	brgcfgs, pcfgs := UsedForTestOnlyPvstTestSetup(t)
+
+	var pa, pb *StpPort
+	if !StpFindPortByIfIndex(PvstTestPort, int32(PvstTestVlanA), &pa) ||
+		!StpFindPortByIfIndex(PvstTestPort, int32(PvstTestVlanB), &pb) {
+		t.Error("ERROR: did not find bridge ports")
+		UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+		return
+	}
+
+	pa.BpduGuardDetected("TEST")
+	pb.BpduGuardDetected("TEST")
+	if !pa.ErrDisabled || !pb.ErrDisabled {
+		t.Error("ERROR: ports should be err-disabled by BPDU Guard", pa.ErrDisabled, pb.ErrDisabled)
+	}
+
+	// the other vlan instance is still err-disabled so the port
+	// err-disable state must not be cleared
+	pa.ErrDisableRecover("TEST")
+	if pa.ErrDisabled ||
+		!pb.ErrDisabled ||
+		!pa.IsErrDisabledOnOtherBridge() {
+		t.Error("ERROR: port should still be err-disabled by other vlan instance", pa.ErrDisabled, pb.ErrDisabled)
+	}
+
+	pb.ErrDisableRecover("TEST")
+	if pb.ErrDisabled ||
+		pb.IsErrDisabledOnOtherBridge() {
+		t.Error("ERROR: port should have recovered from err-disable on all vlan instances")
+	}
+
+	// give test time to complete
+	time.Sleep(time.Millisecond * 10)
+	UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+}
+
 func TestStpPortParamBpduGuard(t *testing.T) {
 	defer MemoryCheck(t)
 	p, b := StpPortConfigSetup(true, false)
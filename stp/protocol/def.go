@@ -46,6 +46,7 @@ const (
 	BPDURxTypeTopo
 	BPDURxTypeTopoAck
 	BPDURxTypePVST
+	BPDURxTypeMSTP
 )
 
 const (
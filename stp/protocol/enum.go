@@ -55,6 +55,16 @@ const (
 	PortRoleDisabledPort
 )
 
+var PortRoleStrMap = map[PortRole]string{
+	PortRoleInvalid:        "Invalid",
+	PortRoleBridgePort:     "Bridge",
+	PortRoleRootPort:       "Root",
+	PortRoleDesignatedPort: "Designated",
+	PortRoleAlternatePort:  "Alternate",
+	PortRoleBackupPort:     "Backup",
+	PortRoleDisabledPort:   "Disabled",
+}
+
 type PointToPointMac int
 
 const (
@@ -0,0 +1,134 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// errdisable.go
+// Err-Disable
+//
+// A port which detects an error condition, BPDU Guard being the current
+// condition, is placed in the err-disabled state.  An err-disabled port is
+// treated as a disabled port by the state machines until the port is
+// recovered.  Recovery occurs when the recovery timer expires, BpduGuardInterval
+// seconds after the last BPDU was received, or when the state is manually
+// cleared.  A BpduGuardInterval of zero requires a manual clear.
+package stp
+
+import (
+	"errors"
+	"fmt"
+	"models/events"
+)
+
+type ErrDisableReason int
+
+const (
+	ErrDisableReasonNone ErrDisableReason = iota
+	ErrDisableReasonBpduGuard
+)
+
+var ErrDisableReasonStrMap = map[ErrDisableReason]string{
+	ErrDisableReasonNone:      "None",
+	ErrDisableReasonBpduGuard: "BPDU Guard",
+}
+
+// BpduGuardDetected is called when a BPDU is received on a BPDU Guard port
+func (p *StpPort) BpduGuardDetected(src string) {
+	if !p.ErrDisabled {
+		p.ErrDisable(src, ErrDisableReasonBpduGuard)
+	} else {
+		// restart recovery as the condition is still present
+		p.BPDUGuardTimer.count = p.BpduGuardInterval
+	}
+}
+
+// ErrDisable will place the port into the err-disabled state
+func (p *StpPort) ErrDisable(src string, reason ErrDisableReason) {
+	if p.ErrDisabled {
+		return
+	}
+	StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Port err-disabled reason %s", ErrDisableReasonStrMap[reason]))
+	p.ErrDisabled = true
+	p.ErrDisableReason = reason
+	p.BPDUGuardTimer.count = p.BpduGuardInterval
+	for _, client := range GetAsicDPluginList() {
+		client.BPDUGuardDetected(p.IfIndex, true)
+	}
+	StpPublishPortEvent(p, events.StpdEventPortErrDisabled)
+
+	if p.PortEnabled {
+		// when the receive machine is the caller it is not notified, it
+		// handles the port being disabled once this returns
+		defer p.NotifyPortEnabled(src, true, false)
+		p.PortEnabled = false
+	}
+}
+
+// ErrDisableRecover will remove the port from the err-disabled state, the port
+// is enabled if it is admin enabled and the link is up
+func (p *StpPort) ErrDisableRecover(src string) {
+	if !p.ErrDisabled {
+		return
+	}
+	StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Port recovered from err-disable reason %s", ErrDisableReasonStrMap[p.ErrDisableReason]))
+	p.ErrDisabled = false
+	p.ErrDisableReason = ErrDisableReasonNone
+	p.BPDUGuardTimer.count = 0
+	linkup := false
+	// the port may still be err-disabled by another vlan instance
+	clear := !p.IsErrDisabledOnOtherBridge()
+	for _, client := range GetAsicDPluginList() {
+		if clear {
+			client.BPDUGuardDetected(p.IfIndex, false)
+		}
+		linkup = linkup || client.GetPortLinkStatus(p.IfIndex)
+	}
+	StpPublishPortEvent(p, events.StpdEventPortErrDisableRecovered)
+
+	if p.AdminPortEnabled &&
+		linkup &&
+		!p.PortEnabled {
+		defer p.NotifyPortEnabled(src, p.PortEnabled, true)
+		p.PortEnabled = true
+	}
+}
+
+// IsErrDisabledOnOtherBridge is the port err-disabled by another bridge instance
+func (p *StpPort) IsErrDisabledOnOtherBridge() bool {
+	for _, op := range PortListTable {
+		if op != p &&
+			op.IfIndex == p.IfIndex &&
+			op.ErrDisabled {
+			return true
+		}
+	}
+	return false
+}
+
+// StpPortErrDisableClear manually recovers an err-disabled port
+func StpPortErrDisableClear(pId int32, bId int32) error {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, bId, &p) {
+		p.ErrDisableRecover("CONFIG: ErrDisableClear")
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid port %d or bridge %d supplied for clearing err-disable", pId, bId))
+}
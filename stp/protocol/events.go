@@ -0,0 +1,53 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// events.go
+package stp
+
+import (
+	"fmt"
+	"models/events"
+	"utils/eventUtils"
+)
+
+// StpPublishPortEvent will publish an event for the given bridge port
+func StpPublishPortEvent(p *StpPort, evtId events.EventId) {
+	intfref := GetPortNameFromIfIndex(p.IfIndex)
+	if intfref == "" {
+		StpLogger("ERROR", fmt.Sprintf("Error in publishing Event %d, ifindex %d not found", evtId, p.IfIndex))
+		return
+	}
+
+	evtKey := events.StpPortEntryKey{
+		Vlan:    p.BrgIfIndex,
+		IntfRef: intfref,
+	}
+	txEvent := eventUtils.TxEvent{
+		EventId: evtId,
+		Key:     evtKey,
+	}
+	err := eventUtils.PublishEvents(&txEvent)
+	if err != nil {
+		StpLogger("ERROR", fmt.Sprintf("Error in publishing Event %d port %s vlan %d", evtId, intfref, p.BrgIfIndex))
+	}
+}
@@ -22,3 +22,21 @@ func StpGlobalStateSet(state int) {
 func StpGlobalStateGet() int {
 	return StpGlobalState
 }
+
+// filter BPDUs on operational edge ports
+var StpGlobalBpduFilterEdge bool
+
+func StpGlobalBpduFilterEdgeSet(filter bool) {
+	if StpGlobalBpduFilterEdge != filter {
+		if filter {
+			StpLogger("INFO", "Setting Global Edge BPDU Filter")
+		} else {
+			StpLogger("INFO", "Clearing Global Edge BPDU Filter")
+		}
+	}
+	StpGlobalBpduFilterEdge = filter
+}
+
+func StpGlobalBpduFilterEdgeGet() bool {
+	return StpGlobalBpduFilterEdge
+}
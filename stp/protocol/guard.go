@@ -0,0 +1,121 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// guard.go
+// Root Guard and Loop Guard
+//
+// Root Guard: a port configured with root guard is never selected as the
+// Root Port.  When the port receives information which is superior to the
+// current root path the port is placed in a root inconsistent (Alternate)
+// state until the superior information ages out.
+//
+// Loop Guard: when BPDUs stop being received on a non designated port the
+// received information is retained rather than aged, the port is placed in
+// a loop inconsistent (Alternate) state until BPDUs are received again.  This
+// protects against a unidirectional link transitioning a blocked port to
+// Designated Forwarding.
+package stp
+
+import (
+	"fmt"
+	"models/events"
+)
+
+// IsRootGuardSuperiorInfo returns true when the received port priority vector
+// would have been selected as the root path of the bridge
+func (p *StpPort) IsRootGuardSuperiorInfo() bool {
+	compare := CompareBridgeId(p.PortPriority.RootBridgeId, p.b.BridgePriority.RootBridgeId)
+	return compare < 0 ||
+		(compare == 0 &&
+			p.PortPriority.RootPathCost+p.PortPathCost < p.b.BridgePriority.RootPathCost)
+}
+
+// IsRootGuardBlocked returns whether the port should be root inconsistent
+func (p *StpPort) IsRootGuardBlocked() bool {
+	return p.RootGuard &&
+		p.PortEnabled &&
+		p.InfoIs == PortInfoStateReceived &&
+		p.IsRootGuardSuperiorInfo()
+}
+
+func (p *StpPort) SetRootGuardInconsistant(src string, inconsistant bool) {
+	if p.RootGuardInconsistant != inconsistant {
+		p.RootGuardInconsistant = inconsistant
+		if inconsistant {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, "Root Guard superior BPDU received, port root inconsistent")
+			StpPublishPortEvent(p, events.StpdEventPortRootInconsistent)
+		} else {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, "Root Guard port root consistent")
+			StpPublishPortEvent(p, events.StpdEventPortRootConsistent)
+		}
+	}
+}
+
+func (p *StpPort) SetLoopGuardInconsistant(src string, inconsistant bool) {
+	if p.LoopGuardInconsistant != inconsistant {
+		p.LoopGuardInconsistant = inconsistant
+		if inconsistant {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Loop Guard BPDU timeout on role %d port, port loop inconsistent", p.Role))
+			StpPublishPortEvent(p, events.StpdEventPortLoopInconsistent)
+		} else {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, "Loop Guard BPDU received, port loop consistent")
+			StpPublishPortEvent(p, events.StpdEventPortLoopConsistent)
+		}
+	}
+}
+
+// LoopGuardRcvdInfoWhileExpired is called when the received info timer expires,
+// returns true when the received information is to be retained as the port
+// has transitioned to, or is already in, the loop inconsistent state
+func (p *StpPort) LoopGuardRcvdInfoWhileExpired() bool {
+	if p.LoopGuard &&
+		!p.OperEdge &&
+		p.PortEnabled &&
+		!p.RootGuardInconsistant &&
+		p.InfoIs == PortInfoStateReceived &&
+		(p.Role == PortRoleRootPort ||
+			p.Role == PortRoleAlternatePort ||
+			p.Role == PortRoleBackupPort) {
+		// keep the info from aging until a BPDU is received
+		p.RcvdInfoWhiletimer.count = int32(p.b.RootTimes.HelloTime * 3)
+		if !p.LoopGuardInconsistant {
+			p.SetLoopGuardInconsistant(PtmMachineModuleStr, true)
+			p.GuardReselect(PtmMachineModuleStr)
+		}
+		return true
+	}
+	return false
+}
+
+// GuardReselect will trigger the Port Role Selection for the port
+func (p *StpPort) GuardReselect(src string) {
+	p.Selected = false
+	p.Reselect = true
+	if p.b != nil &&
+		p.b.PrsMachineFsm != nil {
+		p.b.PrsMachineFsm.PrsEvents <- MachineEvent{
+			e:   PrsEventReselect,
+			src: src,
+		}
+	}
+}
@@ -34,11 +34,17 @@ var portDbMutex *sync.Mutex
 
 func init() {
 	portDbMutex = &sync.Mutex{}
+	stpPortVlanMutex = &sync.Mutex{}
 	PortConfigMap = make(map[int32]portConfig)
 	PortMapTable = make(map[PortMapKey]*StpPort, 0)
 	BridgeMapTable = make(map[BridgeKey]*Bridge, 0)
 	StpPortConfigMap = make(map[int32]StpPortConfig, 0)
 	StpBridgeConfigMap = make(map[int32]StpBridgeConfig, 0)
+	StpMstiConfigMap = make(map[uint16]StpMstiConfig, 0)
+	StpLagMemberMap = make(map[int32][]int32, 0)
+	StpPortPvidMap = make(map[int32]uint16, 0)
+	StpPortTaggedVlanMap = make(map[int32]map[uint16]bool, 0)
+	StpMstRegionInit()
 
 	// Init the state string maps
 	TimerTypeStrStateMapInit()
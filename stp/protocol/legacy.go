@@ -0,0 +1,111 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// legacy.go
+/*
+ 802.1D-1998 STP operating mode
+
+ When the bridge Force Version selects STP the ports run with stpVersion
+ (17.20.12) TRUE and rstpVersion FALSE:
+ - only Config and TCN BPDUs are transmitted
+ - Root and Designated Ports are Listening for Forward Delay, then Learning
+   for Forward Delay before Forwarding, the proposal/agreement and reroot
+   shortcuts are not used
+ - a topology change detected or received is sent towards the root as TCN
+   BPDUs every Hello Time on the Root Port until a Config BPDU with the TCA
+   flag is received, the Designated Port receiving the TCN sets TCA in the
+   next Config BPDU
+ - Config BPDUs carry the TC flag for the topology change time, Max Age plus
+   Forward Delay of the root times
+ - the filtering database is not flushed, the port ageing time is shortened
+   to Forward Delay instead (rapid ageing 17.19.1), approximated by flushing
+   at the start and end of the Forward Delay period
+*/
+package stp
+
+import (
+	"fmt"
+)
+
+const (
+	// 17.13.4 Force Protocol Version 0, STP compatibility
+	StpForceVersionSTPCompat = 0
+	// model value for STP
+	StpForceVersionSTP  = 1
+	StpForceVersionRSTP = 2
+)
+
+// IsStpForceVersionLegacy returns true when the force version selects the
+// 802.1D-1998 STP operating mode
+func IsStpForceVersionLegacy(version int32) bool {
+	return version < StpForceVersionRSTP
+}
+
+func (b *Bridge) IsLegacyStp() bool {
+	return IsStpForceVersionLegacy(b.ForceVersion)
+}
+
+// IsListening 802.1D-1998 8.4.2, the port has been selected as a Root or
+// Designated Port but is still discarding frames while fdWhile runs
+func (p *StpPort) IsListening() bool {
+	return p.PortEnabled &&
+		(p.Role == PortRoleRootPort ||
+			p.Role == PortRoleDesignatedPort) &&
+		!p.Learning &&
+		!p.Forwarding
+}
+
+// RapidAgeingStart 17.19.1 the ageing time of the port is changed to Forward
+// Delay for a period of Forward Delay after fdbFlush is set.  asicd does not
+// expose a per port ageing time so the port is flushed at the start and at
+// the end of the period instead, flush requests received while the period
+// is running do not extend it
+func (p *StpPort) RapidAgeingStart(src string) {
+	if p.RapidAgeingWhileTimer.count != 0 {
+		return
+	}
+	fwddelay := int32(p.b.RootTimes.ForwardingDelay)
+	StpMachineLogger("DEBUG", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Rapid ageing start, ageing time %d", fwddelay))
+	p.RapidAgeingFlush(src)
+	p.RapidAgeingWhileTimer.count = fwddelay
+}
+
+// RapidAgeingExpired is called once Forward Delay has elapsed since rapid
+// ageing started, stations learned on the port during the period are
+// removed, active stations are relearned
+func (p *StpPort) RapidAgeingExpired(src string) {
+	StpMachineLogger("DEBUG", src, p.IfIndex, p.BrgIfIndex, "Rapid ageing complete")
+	p.RapidAgeingFlush(src)
+}
+
+// RapidAgeingFlush will remove the fdb entries learned on the port
+func (p *StpPort) RapidAgeingFlush(src string) {
+	if p.b == nil {
+		return
+	}
+	for _, client := range GetAsicDPluginList() {
+		client.FlushStgFdb(p.b.StgId, p.IfIndex)
+	}
+	StpMachineLogger("DEBUG", src, p.IfIndex, p.BrgIfIndex, "FDB Flush")
+	p.b.TcHistoryRecord(TcHistoryTypeFlush, p.IfIndex, p.b.BridgeIdentifier)
+}
@@ -0,0 +1,212 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// legacy_test.go
+package stp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// the ports must pass through listening and learning for forward delay each,
+// allow for the tick the port came up on
+const StpSimLegacyMinConvergeTicks = 2*StpSimForwardDelay - 1
+
+func TestStpLegacyForceVersion(t *testing.T) {
+	defer MemoryCheck(t)
+
+	brgcfg := StpBridgeConfigSetup()
+	brgcfg.ForceVersion = 4
+	if err := StpBrgConfigParamCheck(brgcfg, true); err == nil {
+		t.Error("ERROR invalid force version accepted")
+	}
+	brgcfg.ForceVersion = StpForceVersionSTPCompat
+	if err := StpBrgConfigParamCheck(brgcfg, true); err != nil {
+		t.Error("ERROR valid STP force version failed", err)
+	}
+	if err := StpBridgeCreate(brgcfg); err != nil {
+		t.Error("ERROR valid bridge creation failed", err)
+	}
+	pcfg, _ := StpPortConfigSetup(false, false)
+	if err := StpPortCreate(pcfg); err != nil {
+		t.Error("ERROR valid stp port creation failed", err)
+	}
+
+	var b *Bridge
+	var p *StpPort
+	if !StpFindBridgeByIfIndex(int32(brgcfg.Vlan), &b) ||
+		!b.IsLegacyStp() {
+		t.Error("ERROR bridge not created in STP mode")
+	}
+	if !StpFindPortByIfIndex(pcfg.IfIndex, pcfg.BrgIfIndex, &p) {
+		t.Error("ERROR unable to find bridge port that was just created")
+	} else {
+		if p.RstpVersion || p.SendRSTP {
+			t.Error("ERROR STP bridge port running rstp", p.RstpVersion, p.SendRSTP)
+		}
+	}
+
+	// moving to rstp re-enables rstp on the ports
+	if err := StpBrgForceVersion(int32(brgcfg.Vlan), StpForceVersionRSTP); err != nil {
+		t.Error("ERROR valid force version change failed", err)
+	}
+	if p != nil && !p.RstpVersion {
+		t.Error("ERROR RSTP bridge port not running rstp")
+	}
+
+	if err := StpPortDelete(pcfg); err != nil {
+		t.Error("ERROR valid stp port deletion failed", err)
+	}
+	if err := StpBridgeDelete(brgcfg); err != nil {
+		t.Error("ERROR valid deletion failed", err)
+	}
+}
+
+// an RSTP root with an STP only neighbor, the neighbor is timed through
+// listening and learning and the RSTP port facing it migrates to STP
+func TestStpSimLegacyInterop(t *testing.T) {
+	s := NewStpSimulator([]StpSimBridgeConfig{
+		{Num: 1, Priority: 4096},
+		{Num: 2, Priority: 32768, Legacy: true},
+	}, []StpSimLinkConfig{
+		{A: 1, B: 2},
+	}, t)
+
+	ticks, ok := s.WaitForConvergence(StpSimMaxTicks)
+	if !ok {
+		t.Error(fmt.Sprintf("network did not converge within %d ticks", StpSimMaxTicks))
+		s.dump(t)
+	} else if ticks < StpSimLegacyMinConvergeTicks {
+		t.Error(fmt.Sprintf("network converged in %d ticks, listening and learning require %d", ticks, StpSimLegacyMinConvergeTicks))
+	}
+	s.VerifyRoot("formation", 1, []uint8{1, 2}, t)
+	s.VerifyPortRole("formation", 1, 2, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("formation", 2, 1, PortRoleRootPort, t)
+
+	// once migrated the RSTP designated port only sends Config BPDUs
+	before := s.TxStats(1, 2)
+	s.Run(3 * StpSimHelloTime)
+	if tx := s.TxStats(1, 2); tx.Config == before.Config ||
+		tx.Rstp != before.Rstp {
+		t.Error("ERROR RSTP port facing STP only bridge still sending RST BPDUs", before, tx)
+	}
+	if tx := s.TxStats(2, 1); tx.Config == 0 || tx.Rstp != 0 {
+		t.Error("ERROR STP only bridge port should only send Config BPDUs", tx)
+	}
+
+	s.Teardown(t)
+}
+
+// bridge 3 joining the STP network signals the topology change to the root
+// with TCN BPDUs until acknowledged, the root sets the TC flag for the
+// topology change time and the fdb is rapid aged for forward delay rather
+// than flushed
+//
+//	bridge 1 (root) --- bridge 2 --- bridge 4
+//	    \
+//	     bridge 3 (joins later)
+func TestStpSimLegacyTopologyChange(t *testing.T) {
+	s := NewStpSimulator([]StpSimBridgeConfig{
+		{Num: 1, Priority: 4096, Legacy: true},
+		{Num: 2, Priority: 32768, Legacy: true},
+		{Num: 3, Priority: 32768, Legacy: true},
+		{Num: 4, Priority: 32768, Legacy: true},
+	}, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 2, B: 4},
+		{A: 1, B: 3, Down: true},
+	}, t)
+
+	if _, ok := s.WaitForConvergence(StpSimMaxTicks); !ok {
+		t.Error(fmt.Sprintf("step: formation network did not converge within %d ticks", StpSimMaxTicks))
+		s.dump(t)
+	}
+	// let the topology changes of the formation run out
+	s.Run(StpSimMaxAge + 2*StpSimForwardDelay)
+
+	restoretime := time.Now()
+	s.LinkRestore(1, 3)
+
+	// the ticks at which each event was first seen
+	p13 := s.Port(1, 3)
+	p24 := s.Port(2, 4)
+	tcnrcvd := -1
+	tcacksent := -1
+	flushes := make([]int, 0)
+	if !s.WaitFor(StpSimMaxTicks, func() bool {
+		if tcnrcvd < 0 &&
+			s.TcHistoryCount(1, TcHistoryTypeRcvdTcn, p13.IfIndex, restoretime) != 0 {
+			tcnrcvd = s.ticks
+		}
+		if tcacksent < 0 &&
+			s.TxStats(1, 3).TcAck != 0 {
+			tcacksent = s.ticks
+		}
+		for n := s.TcHistoryCount(2, TcHistoryTypeFlush, p24.IfIndex, restoretime); len(flushes) < n; {
+			flushes = append(flushes, s.ticks)
+		}
+		return tcnrcvd >= 0 && tcacksent >= 0 && len(flushes) >= 2
+	}) {
+		t.Error("ERROR topology change not complete", tcnrcvd, tcacksent, flushes)
+		s.dump(t)
+	}
+
+	// TCN from bridge 3 acknowledged by the root
+	if tcnrcvd < 0 {
+		t.Error("ERROR root did not receive TCN from bridge 3")
+	}
+	if tcacksent < 0 {
+		t.Error("ERROR root did not acknowledge TCN from bridge 3")
+	}
+	if tx := s.TxStats(3, 1); tx.Tcn == 0 || tx.Rstp != 0 {
+		t.Error("ERROR bridge 3 did not send TCN BPDUs", tx)
+	}
+	s.Run(1)
+	tcn := s.TxStats(3, 1).Tcn
+	s.Run(3 * StpSimHelloTime)
+	if tx := s.TxStats(3, 1); tx.Tcn != tcn {
+		t.Error("ERROR bridge 3 still sending TCN BPDUs once acknowledged", tcn, tx.Tcn)
+	}
+
+	// bridge 2 receives the TC flag from the root every hello for the
+	// topology change time, the rapid ageing of the port to bridge 4 is
+	// flushed when it starts and must still end forward delay later
+	if len(flushes) >= 2 {
+		if d := flushes[1] - flushes[0]; d < StpSimForwardDelay-1 ||
+			d > StpSimForwardDelay+1 {
+			t.Error(fmt.Sprintf("ERROR rapid ageing lasted %d ticks expected forward delay %d", d, StpSimForwardDelay))
+		}
+	}
+
+	if _, ok := s.WaitForConvergence(StpSimMaxTicks); !ok {
+		t.Error(fmt.Sprintf("step: bridge 3 joined network did not converge within %d ticks", StpSimMaxTicks))
+		s.dump(t)
+	}
+	s.VerifyRoot("bridge 3 joined", 1, []uint8{1, 2, 3, 4}, t)
+	s.VerifyPortRole("bridge 3 joined", 3, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("bridge 3 joined", 4, 2, PortRoleRootPort, t)
+
+	s.Teardown(t)
+}
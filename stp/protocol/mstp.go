@@ -0,0 +1,974 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// mstp.go
+// 802.1Q-2014 Clause 13 Multiple Spanning Tree Protocol
+//
+// The CIST is the default bridge configured with Force Version 3 (MSTP), it
+// continues to run the RSTP machines.  Each MSTI is its own Bridge instance,
+// keyed by Mstid, with one bridge port per CIST port so that the existing port
+// machines are re-used per instance.  MSTI ports do not own a rx/tx handle,
+// received M-records are dispatched by the CIST port and MSTI information is
+// transmitted as M-records within the MST BPDU sent by the CIST port.
+package stp
+
+import (
+	"asicd/pluginManager/pluginCommon"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const MstpModuleStr = "MSTP"
+
+const (
+	MSTPProtocolVersion = 3
+	StpForceVersionMSTP = 3
+	// number of MSTIs supported by this bridge
+	MstpMaxMsti  = 64
+	MstpMaxMstid = 4094
+	// 13.26.4 MaxHops default and range
+	MstpMaxHopsDefault = 20
+	MstpMaxHopsMin     = 6
+	MstpMaxHopsMax     = 40
+	// 13.8 MST Configuration Identifier
+	MstConfigIdFormatSelector = 0
+	MstConfigNameLength       = 32
+	// 14.6 MST BPDU encoding
+	MstBpduCistLength         = 102
+	MstBpduMRecordLength      = 16
+	MstBpduVersion3LengthBase = 64
+	// bit 8 of the MSTI flags is the Master flag
+	MstiMasterFlag = 0x80
+	// MSTI bridges are indexed above the vlan range
+	MstiBrgIfIndexBase = 4096
+)
+
+// 13.8 the Configuration Digest is a HMAC-MD5 signature of the MST
+// Configuration Table using the following key
+var MstConfigDigestKey = []byte{0x13, 0xAC, 0x06, 0xA6, 0x2E, 0x47, 0xFD, 0x51, 0xF9, 0x5D, 0x2B, 0xA2, 0x43, 0xCD, 0x03, 0x46}
+
+// StpMstRegionConfig config data
+type StpMstRegionConfig struct {
+	Name     string
+	Revision uint16
+	// 0 == MstpMaxHopsDefault
+	MaxHops uint8
+}
+
+// StpMstiConfig config data
+type StpMstiConfig struct {
+	Mstid    uint16
+	Priority uint16
+	Vlans    []uint16
+}
+
+// 13.8 MST Configuration Identification
+type MstConfigId struct {
+	FormatSelector uint8
+	Name           [MstConfigNameLength]uint8
+	Revision       uint16
+	Digest         [16]uint8
+}
+
+type MstRegion struct {
+	ConfigId MstConfigId
+	// MST Configuration Table, index is the vlan value is the mstid
+	// 0 == CIST
+	VlanMap [4096]uint16
+	// 13.26.4 initial remaining hops of the CIST and MSTI information
+	// originated by the regional root
+	MaxHops uint8
+}
+
+// 14.6 MSTI Configuration Message (M-record)
+type MstiConfigMsg struct {
+	Flags                uint8
+	RegionalRootId       BridgeId
+	InternalRootPathCost uint32
+	BridgePriority       uint8
+	PortPriority         uint8
+	RemainingHops        uint8
+}
+
+// 14.6 MST BPDU
+type MstBpdu struct {
+	ProtocolId          uint16
+	ProtocolVersionId   uint8
+	BPDUType            uint8
+	Flags               uint8
+	CistRootId          BridgeId
+	CistExtRootPathCost uint32
+	CistRegionalRootId  BridgeId
+	CistPortId          uint16
+	MsgAge              uint16
+	MaxAge              uint16
+	HelloTime           uint16
+	FwdDelay            uint16
+	Version1Length      uint8
+	Version3Length      uint16
+	ConfigId            MstConfigId
+	CistIntRootPathCost uint32
+	CistBridgeId        BridgeId
+	CistRemainingHops   uint8
+	MRecords            []MstiConfigMsg
+}
+
+var StpMstRegion MstRegion
+
+// store the config for each msti
+var StpMstiConfigMap map[uint16]StpMstiConfig
+
+func StpMstRegionInit() {
+	StpMstRegion = MstRegion{}
+	StpMstRegion.ConfigId.FormatSelector = MstConfigIdFormatSelector
+	StpMstRegion.MaxHops = MstpMaxHopsDefault
+	StpMstRegion.ConfigId.Digest = MstConfigDigest(&StpMstRegion.VlanMap)
+}
+
+// MstConfigDigest: 13.8 calculate the Configuration Digest from the
+// MST Configuration Table, each of the 4096 entries is encoded as
+// a 2 octet mstid
+func MstConfigDigest(vlanmap *[4096]uint16) (digest [16]uint8) {
+	table := make([]byte, len(vlanmap)*2)
+	for vid, mstid := range vlanmap {
+		binary.BigEndian.PutUint16(table[vid*2:], mstid)
+	}
+	mac := hmac.New(md5.New, MstConfigDigestKey)
+	mac.Write(table)
+	copy(digest[:], mac.Sum(nil))
+	return digest
+}
+
+// setMstiVlans will update the MST Configuration Table for the given msti
+// and recalculate the Configuration Digest
+func (r *MstRegion) setMstiVlans(mstid uint16, vlans []uint16) {
+	for vid, id := range r.VlanMap {
+		if id == mstid {
+			r.VlanMap[vid] = 0
+		}
+	}
+	for _, vid := range vlans {
+		r.VlanMap[vid] = mstid
+	}
+	r.ConfigId.Digest = MstConfigDigest(&r.VlanMap)
+}
+
+func MstiBrgIfIndex(mstid uint16) int32 {
+	return MstiBrgIfIndexBase + int32(mstid)
+}
+
+func StpFindMstiBridgeById(mstid uint16, b **Bridge) bool {
+	key := BridgeKey{
+		Vlan:  DEFAULT_STP_BRIDGE_VLAN,
+		Mstid: mstid,
+	}
+	return StpFindBridgeById(key, b)
+}
+
+func StpFindCistBridge(b **Bridge) bool {
+	key := BridgeKey{
+		Vlan: DEFAULT_STP_BRIDGE_VLAN,
+	}
+	return StpFindBridgeById(key, b)
+}
+
+// IsMsti is this bridge instance a MSTI
+func (b *Bridge) IsMsti() bool {
+	return b.Mstid != 0
+}
+
+// IsMstpCist is this bridge instance the CIST of a MSTP bridge
+func (b *Bridge) IsMstpCist() bool {
+	return !b.IsMsti() && b.ForceVersion == StpForceVersionMSTP
+}
+
+// StgVlanList returns the vlans which are members of the bridge stg, a MSTI
+// contains the vlans allocated to it in the MST Configuration Table
+func (b *Bridge) StgVlanList() (vlans []uint16) {
+	if !b.IsMsti() {
+		return []uint16{b.Vlan}
+	}
+	for vid, mstid := range StpMstRegion.VlanMap {
+		if mstid == b.Mstid {
+			vlans = append(vlans, uint16(vid))
+		}
+	}
+	return vlans
+}
+
+// RootPathPriority 13.10 the root path priority vector of a port, for the
+// MSTP CIST the port path cost is added to the internal root path cost when
+// the port is internal to the region, otherwise it is added to the external
+// root path cost and this bridge becomes the regional root
+func (p *StpPort) RootPathPriority() PriorityVector {
+	v := p.PortPriority
+	if !p.b.IsMstpCist() {
+		v.RootPathCost += p.PortPathCost
+	} else if p.RcvdInternal {
+		v.IntRootPathCost += p.PortPathCost
+	} else {
+		v.RootPathCost += p.PortPathCost
+		v.RegionalRootId = p.b.BridgeIdentifier
+		v.IntRootPathCost = 0
+	}
+	return v
+}
+
+// MstiBridges returns the MSTI bridges ordered by mstid
+func MstiBridges() (mstis []*Bridge) {
+	for _, b := range BridgeListTable {
+		if b.IsMsti() {
+			mstis = append(mstis, b)
+		}
+	}
+	sort.Slice(mstis, func(i, j int) bool { return mstis[i].Mstid < mstis[j].Mstid })
+	return mstis
+}
+
+// MstiReselect will run the Port Role Selection of each MSTI which has a
+// boundary port whose role no longer follows the CIST port role
+func (b *Bridge) MstiReselect(src string) {
+	var p *StpPort
+	if !b.IsMstpCist() {
+		return
+	}
+	for _, mb := range MstiBridges() {
+		reselect := false
+		for _, pId := range mb.StpPorts {
+			if StpFindPortByIfIndex(pId, mb.BrgIfIndex, &p) &&
+				!p.IsMstiInternal() &&
+				p.SelectedRole != p.MstiBoundaryRole() {
+				p.Selected = false
+				p.Reselect = true
+				reselect = true
+			}
+		}
+		if reselect &&
+			mb.PrsMachineFsm != nil {
+			mb.PrsMachineFsm.PrsEvents <- MachineEvent{
+				e:   PrsEventReselect,
+				src: src,
+			}
+		}
+	}
+}
+
+// CistPort returns the CIST port associated with a MSTI port
+func (p *StpPort) CistPort() *StpPort {
+	var cp *StpPort
+	if !p.b.IsMsti() {
+		return p
+	}
+	if StpFindPortByIfIndex(p.IfIndex, DEFAULT_STP_BRIDGE_VLAN, &cp) {
+		return cp
+	}
+	return nil
+}
+
+// MstiPorts returns the MSTI ports associated with a CIST port ordered by mstid
+func (p *StpPort) MstiPorts() (ports []*StpPort) {
+	var mp *StpPort
+	for _, mb := range MstiBridges() {
+		if StpFindPortByIfIndex(p.IfIndex, mb.BrgIfIndex, &mp) {
+			ports = append(ports, mp)
+		}
+	}
+	return ports
+}
+
+// IsMstiInternal a MSTI port is internal to the region when
+// the CIST port receives MST BPDUs with the same MST Configuration Identifier
+func (p *StpPort) IsMstiInternal() bool {
+	cp := p.CistPort()
+	return cp != nil && cp.RcvdInternal
+}
+
+// MstiBoundaryRole the role of a MSTI boundary port is the role of
+// the CIST port, a CIST Root Port is the Master Port of the MSTI and will
+// transition as a Root Port
+func (p *StpPort) MstiBoundaryRole() PortRole {
+	cp := p.CistPort()
+	if cp == nil {
+		return PortRoleDisabledPort
+	}
+	return cp.SelectedRole
+}
+
+func (p *StpPort) NotifyRcvdInternalChanged(src string, oldrcvdinternal bool, newrcvdinternal bool) {
+	if oldrcvdinternal != newrcvdinternal {
+		StpMachineLogger("DEBUG", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("NotifyRcvdInternalChanged: port is region boundary %t", !newrcvdinternal))
+		for _, mp := range p.MstiPorts() {
+			mp.Selected = false
+			mp.Reselect = true
+			if mp.b.PrsMachineFsm != nil {
+				mp.b.PrsMachineFsm.PrsEvents <- MachineEvent{
+					e:   PrsEventReselect,
+					src: src,
+				}
+			}
+		}
+	}
+}
+
+// StpMstRegionConfigParamCheck will validate the region config paramaters
+func StpMstRegionConfigParamCheck(c *StpMstRegionConfig) error {
+	if len(c.Name) > MstConfigNameLength {
+		return errors.New(fmt.Sprintf("Invalid MST Region Name %s max length %d", c.Name, MstConfigNameLength))
+	}
+	if c.MaxHops != 0 &&
+		(c.MaxHops < MstpMaxHopsMin || c.MaxHops > MstpMaxHopsMax) {
+		return errors.New(fmt.Sprintf("Invalid MST Region MaxHops %d valid range %d-%d", c.MaxHops, MstpMaxHopsMin, MstpMaxHopsMax))
+	}
+	return nil
+}
+
+// StpMstRegionConfigSet will set the region name, revision and max hops, ports
+// will re-evaluate whether they are internal to the region on the next BPDU received
+func StpMstRegionConfigSet(c *StpMstRegionConfig) error {
+	err := StpMstRegionConfigParamCheck(c)
+	if err != nil {
+		return err
+	}
+
+	maxhops := c.MaxHops
+	if maxhops == 0 {
+		maxhops = MstpMaxHopsDefault
+	}
+	if StpMstRegion.MaxHops != maxhops {
+		StpMstRegion.MaxHops = maxhops
+		// if we are the regional root lets update the port times
+		var cist *Bridge
+		var p *StpPort
+		if StpFindCistBridge(&cist) &&
+			cist.IsMstpCist() &&
+			cist.BridgePriority.RegionalRootId == cist.BridgeIdentifier {
+			cist.RootTimes.RemainingHops = maxhops
+			for _, pId := range cist.StpPorts {
+				if StpFindPortByIfIndex(pId, cist.BrgIfIndex, &p) {
+					p.PortTimes.RemainingHops = cist.RootTimes.RemainingHops
+				}
+			}
+		}
+	}
+
+	var name [MstConfigNameLength]uint8
+	copy(name[:], c.Name)
+	if StpMstRegion.ConfigId.Name != name ||
+		StpMstRegion.ConfigId.Revision != c.Revision {
+		StpMstRegion.ConfigId.Name = name
+		StpMstRegion.ConfigId.Revision = c.Revision
+		StpMstRegionChanged("CONFIG: MstRegionSet")
+	}
+	return nil
+}
+
+// StpMstRegionChanged the MST Configuration Identifier has changed all ports
+// are considered boundary ports until a MST BPDU from the region is received
+func StpMstRegionChanged(src string) {
+	var cist *Bridge
+	var p *StpPort
+	if StpFindCistBridge(&cist) && cist.IsMstpCist() {
+		for _, pId := range cist.StpPorts {
+			if StpFindPortByIfIndex(pId, cist.BrgIfIndex, &p) {
+				p.NotifyRcvdInternalChanged(src, p.RcvdInternal, false)
+				p.RcvdInternal = false
+			}
+		}
+	}
+}
+
+// StpMstiConfigParamCheck will validate the msti config paramaters
+func StpMstiConfigParamCheck(c *StpMstiConfig, create bool) error {
+	var cist *Bridge
+
+	if !StpFindCistBridge(&cist) || !cist.IsMstpCist() {
+		return errors.New(fmt.Sprintf("Invalid Config, MSTI %d requires default bridge with Force Version %d (MSTP)", c.Mstid, StpForceVersionMSTP))
+	}
+
+	if c.Mstid == 0 || c.Mstid > MstpMaxMstid {
+		return errors.New(fmt.Sprintf("Invalid MSTI %d valid range 1 - %d", c.Mstid, MstpMaxMstid))
+	}
+
+	_, ok := StpMstiConfigMap[c.Mstid]
+	if create {
+		if ok {
+			return errors.New(fmt.Sprintf("Invalid Config, MSTI %d already exists", c.Mstid))
+		}
+		if len(StpMstiConfigMap) >= MstpMaxMsti {
+			return errors.New(fmt.Sprintf("Invalid Config, MSTI %d max number of MSTIs %d already exist", c.Mstid, MstpMaxMsti))
+		}
+	} else if !ok {
+		return errors.New(fmt.Sprintf("Invalid Config, MSTI %d does not exist", c.Mstid))
+	}
+
+	// Table 13-3 same as Bridge Priority 0-61440 in increments of 4096
+	if math.Mod(float64(c.Priority), 4096) != 0 || c.Priority > 61440 {
+		return errors.New(fmt.Sprintf("Invalid MSTI %d Priority %d valid values 0-61440 increments of 4096", c.Mstid, c.Priority))
+	}
+
+	for _, vid := range c.Vlans {
+		if vid == 0 || vid > 4094 {
+			return errors.New(fmt.Sprintf("Invalid MSTI %d Vlan %d valid range 1 - 4094", c.Mstid, vid))
+		}
+		if StpMstRegion.VlanMap[vid] != 0 &&
+			StpMstRegion.VlanMap[vid] != c.Mstid {
+			return errors.New(fmt.Sprintf("Invalid MSTI %d Vlan %d already allocated to MSTI %d", c.Mstid, vid, StpMstRegion.VlanMap[vid]))
+		}
+	}
+	return nil
+}
+
+// StpMstiCreate will create the MSTI bridge along with a MSTI port for each
+// of the CIST ports
+func StpMstiCreate(c *StpMstiConfig) error {
+	var cist *Bridge
+	var b *Bridge
+	var p *StpPort
+
+	if !StpFindCistBridge(&cist) || !cist.IsMstpCist() {
+		return errors.New(fmt.Sprintf("Invalid config, MSTI %d requires default bridge running MSTP", c.Mstid))
+	}
+	if StpFindMstiBridgeById(c.Mstid, &b) {
+		return errors.New(fmt.Sprintf("Invalid config, MSTI %d already exists", c.Mstid))
+	}
+
+	StpMstiConfigMap[c.Mstid] = *c
+	StpMstRegion.setMstiVlans(c.Mstid, c.Vlans)
+
+	brgconfig := &StpBridgeConfig{
+		Priority:     c.Priority,
+		MaxAge:       cist.BridgeTimes.MaxAge,
+		HelloTime:    cist.BridgeTimes.HelloTime,
+		ForwardDelay: cist.BridgeTimes.ForwardingDelay,
+		ForceVersion: StpForceVersionMSTP,
+		TxHoldCount:  int32(cist.TxHoldCount),
+		Vlan:         DEFAULT_STP_BRIDGE_VLAN,
+		Mstid:        c.Mstid,
+		DebugLevel:   cist.DebugLevel,
+	}
+	b = NewStpBridge(brgconfig)
+	b.BEGIN(false)
+
+	for _, pId := range cist.StpPorts {
+		if StpFindPortByIfIndex(pId, cist.BrgIfIndex, &p) {
+			b.MstiPortCreate(p)
+		}
+	}
+	StpMstRegionChanged("CONFIG: MstiCreate")
+	return nil
+}
+
+// StpMstiDelete will delete the MSTI bridge and all its ports
+func StpMstiDelete(c *StpMstiConfig) error {
+	var b *Bridge
+	if !StpFindMstiBridgeById(c.Mstid, &b) {
+		return errors.New(fmt.Sprintf("Invalid config, MSTI %d does not exists", c.Mstid))
+	}
+	// ports are deleted along with the bridge, the stg vlan list
+	// is needed by the bridge delete so clear the table after
+	DelStpBridge(b, true)
+	StpMstRegion.setMstiVlans(c.Mstid, nil)
+	delete(StpMstiConfigMap, c.Mstid)
+	StpMstRegionChanged("CONFIG: MstiDelete")
+	return nil
+}
+
+// StpMstiDeleteAll will delete all MSTIs, used when the CIST is deleted
+// or is no longer running MSTP
+func StpMstiDeleteAll() {
+	for _, b := range MstiBridges() {
+		StpMstiDelete(&StpMstiConfig{Mstid: b.Mstid})
+	}
+}
+
+// StpMstiPrioritySet will set the MSTI bridge priority
+func StpMstiPrioritySet(mstid uint16, priority uint16) error {
+	var b *Bridge
+	var p *StpPort
+	if StpFindMstiBridgeById(mstid, &b) {
+		c := StpMstiConfigMap[mstid]
+		c.Priority = priority
+		err := StpMstiConfigParamCheck(&c, false)
+		if err != nil {
+			return err
+		}
+		StpMstiConfigMap[mstid] = c
+		if GetBridgePriorityFromBridgeId(b.BridgeIdentifier)&0xf000 != priority {
+			addr := GetBridgeAddrFromBridgeId(b.BridgeIdentifier)
+			b.BridgeIdentifier = CreateBridgeId(addr, priority, mstid)
+			b.BridgePriority.DesignatedBridgeId = b.BridgeIdentifier
+
+			for _, pId := range b.StpPorts {
+				if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+					p.Selected = false
+					p.Reselect = true
+				}
+			}
+			if b.PrsMachineFsm != nil {
+				b.PrsMachineFsm.PrsEvents <- MachineEvent{
+					e:   PrsEventReselect,
+					src: "CONFIG: MstiPrioritySet",
+				}
+			}
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid MSTI %d supplied for setting Priority", mstid))
+}
+
+// StpMstiVlanSet will update the vlans allocated to the MSTI, the stg is
+// re-created with the new vlan list and the current port states re-applied
+func StpMstiVlanSet(mstid uint16, vlans []uint16) error {
+	var b *Bridge
+	var p *StpPort
+	if StpFindMstiBridgeById(mstid, &b) {
+		c := StpMstiConfigMap[mstid]
+		c.Vlans = vlans
+		err := StpMstiConfigParamCheck(&c, false)
+		if err != nil {
+			return err
+		}
+		StpMstiConfigMap[mstid] = c
+
+		for _, client := range GetAsicDPluginList() {
+			client.DeleteStgBridge(b.StgId, b.StgVlanList())
+		}
+		StpMstRegion.setMstiVlans(mstid, vlans)
+		for _, client := range GetAsicDPluginList() {
+			b.StgId = client.CreateStgBridge(b.StgVlanList())
+		}
+		for _, pId := range b.StpPorts {
+			if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
+				p.StgPortStateSet()
+			}
+		}
+		StpMstRegionChanged("CONFIG: MstiVlanSet")
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid MSTI %d supplied for setting Vlans", mstid))
+}
+
+// MstiPortCreate will create the MSTI port for the given CIST port
+func (b *Bridge) MstiPortCreate(cp *StpPort) {
+	// Bridge Assurance and BPDU Guard are handled by the CIST port
+	c := &StpPortConfig{
+		IfIndex:           cp.IfIndex,
+		Priority:          cp.Priority,
+		Enable:            cp.AdminPortEnabled,
+		PathCost:          int32(cp.PortPathCost),
+		AdminPointToPoint: int32(cp.AdminPointToPointMAC),
+		AdminEdgePort:     cp.AdminEdge,
+		AdminPathCost:     cp.AdminPathCost,
+		BrgIfIndex:        b.BrgIfIndex,
+		RootGuard:         cp.RootGuard,
+		LoopGuard:         cp.LoopGuard,
+		BpduFilter:        cp.BpduFilter,
+	}
+	p := NewStpPort(c)
+	p.PathCostAuto = cp.PathCostAuto
+	StpPortAddToBridge(p.IfIndex, p.BrgIfIndex)
+}
+
+// MstiPortsCreate will create a MSTI port in each MSTI for the given CIST port
+func (b *Bridge) MstiPortsCreate(cp *StpPort) {
+	for _, mb := range MstiBridges() {
+		mb.MstiPortCreate(cp)
+	}
+}
+
+// MstiPortsDelete will delete the MSTI ports in each MSTI for the given CIST port
+func (b *Bridge) MstiPortsDelete(pId int32) {
+	var p *StpPort
+	for _, mb := range MstiBridges() {
+		if StpFindPortByIfIndex(pId, mb.BrgIfIndex, &p) {
+			StpPortDelFromBridge(pId, mb.BrgIfIndex)
+			DelStpPort(p)
+		}
+	}
+}
+
+// StgPortStateSet will program the current port state into the bridge stg
+func (p *StpPort) StgPortStateSet() {
+	state := pluginCommon.STP_PORT_STATE_BLOCKING
+	if p.Forwarding {
+		state = pluginCommon.STP_PORT_STATE_FORWARDING
+	} else if p.Learning {
+		state = pluginCommon.STP_PORT_STATE_LEARNING
+	}
+	for _, client := range GetAsicDPluginList() {
+		client.SetStgPortState(p.b.StgId, p.IfIndex, state)
+	}
+}
+
+// Encode 14.6 encode the MST BPDU
+func (m *MstBpdu) Encode() []byte {
+	m.Version3Length = uint16(MstBpduVersion3LengthBase + len(m.MRecords)*MstBpduMRecordLength)
+	data := make([]byte, MstBpduCistLength+len(m.MRecords)*MstBpduMRecordLength)
+	binary.BigEndian.PutUint16(data[0:], m.ProtocolId)
+	data[2] = m.ProtocolVersionId
+	data[3] = m.BPDUType
+	data[4] = m.Flags
+	copy(data[5:13], m.CistRootId[:])
+	binary.BigEndian.PutUint32(data[13:], m.CistExtRootPathCost)
+	copy(data[17:25], m.CistRegionalRootId[:])
+	binary.BigEndian.PutUint16(data[25:], m.CistPortId)
+	binary.BigEndian.PutUint16(data[27:], m.MsgAge)
+	binary.BigEndian.PutUint16(data[29:], m.MaxAge)
+	binary.BigEndian.PutUint16(data[31:], m.HelloTime)
+	binary.BigEndian.PutUint16(data[33:], m.FwdDelay)
+	data[35] = m.Version1Length
+	binary.BigEndian.PutUint16(data[36:], m.Version3Length)
+	data[38] = m.ConfigId.FormatSelector
+	copy(data[39:71], m.ConfigId.Name[:])
+	binary.BigEndian.PutUint16(data[71:], m.ConfigId.Revision)
+	copy(data[73:89], m.ConfigId.Digest[:])
+	binary.BigEndian.PutUint32(data[89:], m.CistIntRootPathCost)
+	copy(data[93:101], m.CistBridgeId[:])
+	data[101] = m.CistRemainingHops
+
+	for i, mrec := range m.MRecords {
+		offset := MstBpduCistLength + i*MstBpduMRecordLength
+		data[offset] = mrec.Flags
+		copy(data[offset+1:offset+9], mrec.RegionalRootId[:])
+		binary.BigEndian.PutUint32(data[offset+9:], mrec.InternalRootPathCost)
+		data[offset+13] = mrec.BridgePriority
+		data[offset+14] = mrec.PortPriority
+		data[offset+15] = mrec.RemainingHops
+	}
+	return data
+}
+
+// DecodeMstBpdu 14.4 decode and validate a MST BPDU, an error is returned
+// when the BPDU is not a valid MST BPDU in which case it is to be treated
+// as a RST BPDU received from outside the region
+func DecodeMstBpdu(data []byte) (*MstBpdu, error) {
+	if len(data) < MstBpduCistLength {
+		return nil, errors.New(fmt.Sprintf("Invalid MST BPDU length %d", len(data)))
+	}
+	m := &MstBpdu{
+		ProtocolId:          binary.BigEndian.Uint16(data[0:]),
+		ProtocolVersionId:   data[2],
+		BPDUType:            data[3],
+		Flags:               data[4],
+		CistExtRootPathCost: binary.BigEndian.Uint32(data[13:]),
+		CistPortId:          binary.BigEndian.Uint16(data[25:]),
+		MsgAge:              binary.BigEndian.Uint16(data[27:]),
+		MaxAge:              binary.BigEndian.Uint16(data[29:]),
+		HelloTime:           binary.BigEndian.Uint16(data[31:]),
+		FwdDelay:            binary.BigEndian.Uint16(data[33:]),
+		Version1Length:      data[35],
+		Version3Length:      binary.BigEndian.Uint16(data[36:]),
+		CistIntRootPathCost: binary.BigEndian.Uint32(data[89:]),
+		CistRemainingHops:   data[101],
+	}
+	copy(m.CistRootId[:], data[5:13])
+	copy(m.CistRegionalRootId[:], data[17:25])
+	m.ConfigId.FormatSelector = data[38]
+	copy(m.ConfigId.Name[:], data[39:71])
+	m.ConfigId.Revision = binary.BigEndian.Uint16(data[71:])
+	copy(m.ConfigId.Digest[:], data[73:89])
+	copy(m.CistBridgeId[:], data[93:101])
+
+	if m.ProtocolVersionId < MSTPProtocolVersion ||
+		m.BPDUType != uint8(layers.BPDUTypeRSTP) {
+		return nil, errors.New(fmt.Sprintf("Invalid MST BPDU version %d type %d", m.ProtocolVersionId, m.BPDUType))
+	}
+
+	// 14.4 (d) the Version 3 Length must be a multiple of the M-record length
+	if m.Version3Length < MstBpduVersion3LengthBase ||
+		(m.Version3Length-MstBpduVersion3LengthBase)%MstBpduMRecordLength != 0 ||
+		len(data) < int(m.Version3Length)+MstBpduCistLength-MstBpduVersion3LengthBase {
+		return nil, errors.New(fmt.Sprintf("Invalid MST BPDU Version 3 Length %d", m.Version3Length))
+	}
+
+	numRecords := int(m.Version3Length-MstBpduVersion3LengthBase) / MstBpduMRecordLength
+	for i := 0; i < numRecords; i++ {
+		offset := MstBpduCistLength + i*MstBpduMRecordLength
+		mrec := MstiConfigMsg{
+			Flags:                data[offset],
+			InternalRootPathCost: binary.BigEndian.Uint32(data[offset+9:]),
+			BridgePriority:       data[offset+13],
+			PortPriority:         data[offset+14],
+			RemainingHops:        data[offset+15],
+		}
+		copy(mrec.RegionalRootId[:], data[offset+1:offset+9])
+		m.MRecords = append(m.MRecords, mrec)
+	}
+	return m, nil
+}
+
+// CistRSTP converts the CIST information of a MST BPDU to the RST BPDU form,
+// used by the CIST port machines for the flags and BPDU version
+func (m *MstBpdu) CistRSTP() *layers.RSTP {
+	return &layers.RSTP{
+		ProtocolId:        layers.RSTPProtocolIdentifier,
+		ProtocolVersionId: layers.RSTPProtocolVersion,
+		BPDUType:          layers.BPDUTypeRSTP,
+		Flags:             layers.StpFlags(m.Flags),
+		RootId:            m.CistRootId,
+		RootPathCost:      m.CistExtRootPathCost,
+		BridgeId:          m.CistBridgeId,
+		PortId:            m.CistPortId,
+		MsgAge:            m.MsgAge,
+		MaxAge:            m.MaxAge,
+		HelloTime:         m.HelloTime,
+		FwdDelay:          m.FwdDelay,
+		Version1Length:    0,
+	}
+}
+
+// CistMsgPriority 13.10 the message priority vector of a MST BPDU received
+// from a bridge in the same region
+func (m *MstBpdu) CistMsgPriority() *PriorityVector {
+	return &PriorityVector{
+		RootBridgeId:       m.CistRootId,
+		RootPathCost:       m.CistExtRootPathCost,
+		RegionalRootId:     m.CistRegionalRootId,
+		IntRootPathCost:    m.CistIntRootPathCost,
+		DesignatedBridgeId: m.CistBridgeId,
+		DesignatedPortId:   m.CistPortId,
+		BridgePortId:       m.CistPortId,
+	}
+}
+
+// CistMsgTimes 13.26.8 the message times of a MST BPDU received from a
+// bridge in the same region, remaining hops are decremented on receipt
+func (m *MstBpdu) CistMsgTimes() *Times {
+	hops := uint8(0)
+	if m.CistRemainingHops > 0 {
+		hops = m.CistRemainingHops - 1
+	}
+	return &Times{
+		ForwardingDelay: m.FwdDelay >> 8,
+		HelloTime:       m.HelloTime >> 8,
+		MaxAge:          m.MaxAge >> 8,
+		MessageAge:      m.MsgAge >> 8,
+		RemainingHops:   hops,
+	}
+}
+
+// MstiRSTP converts a M-record to the RST BPDU form consumed by the MSTI port
+// machines.  The MSTI designated bridge and port identifiers are made of
+// the M-record priorities and the CIST bridge and port identifiers.  Remaining
+// hops are carried as message age against a max age of MaxHops.
+func (m *MstBpdu) MstiRSTP(mrec *MstiConfigMsg) *layers.RSTP {
+	mstid := GetBridgeVlanFromBridgeId(mrec.RegionalRootId)
+	maxhops := uint16(StpMstRegion.MaxHops)
+	hops := uint16(0)
+	if uint16(mrec.RemainingHops) < maxhops {
+		hops = maxhops - uint16(mrec.RemainingHops)
+	}
+	return &layers.RSTP{
+		ProtocolId:        layers.RSTPProtocolIdentifier,
+		ProtocolVersionId: layers.RSTPProtocolVersion,
+		BPDUType:          layers.BPDUTypeRSTP,
+		Flags:             layers.StpFlags(mrec.Flags &^ MstiMasterFlag),
+		RootId:            mrec.RegionalRootId,
+		RootPathCost:      mrec.InternalRootPathCost,
+		BridgeId:          CreateBridgeId(GetBridgeAddrFromBridgeId(m.CistBridgeId), uint16(mrec.BridgePriority)<<8, mstid),
+		PortId:            uint16(mrec.PortPriority)<<8 | m.CistPortId&0x0fff,
+		MsgAge:            hops << 8,
+		MaxAge:            maxhops << 8,
+		HelloTime:         m.HelloTime,
+		FwdDelay:          m.FwdDelay,
+		Version1Length:    0,
+	}
+}
+
+// ProcessMstBpduFrame determine if the BPDU received on the CIST port
+// was sent by a bridge in the same region, if so the M-records are dispatched
+// to the MSTI ports.  Returns the BPDU type used for the CIST port counters
+// and the MST BPDU when it is internal to the region so that the CIST port
+// records the regional root, internal root path cost and remaining hops.
+func ProcessMstBpduFrame(p *StpPort, ptype BPDURxType, packet gopacket.Packet) (BPDURxType, *MstBpdu) {
+	var m *MstBpdu
+	var err error
+
+	internal := false
+	if ptype == BPDURxTypeRSTP {
+		llcLayer := packet.Layer(layers.LayerTypeLLC)
+		if llcLayer != nil {
+			m, err = DecodeMstBpdu(llcLayer.LayerPayload())
+			if err == nil {
+				ptype = BPDURxTypeMSTP
+				internal = m.ConfigId == StpMstRegion.ConfigId
+			}
+		}
+	}
+
+	defer p.NotifyRcvdInternalChanged(MstpModuleStr, p.RcvdInternal, internal)
+	p.RcvdInternal = internal
+	if internal {
+		p.DispatchMRecords(m)
+		return ptype, m
+	}
+	return ptype, nil
+}
+
+// DispatchMRecords will send each M-record to the MSTI port identified by
+// the mstid encoded in the MSTI Regional Root Identifier
+func (p *StpPort) DispatchMRecords(m *MstBpdu) {
+	var mp *StpPort
+
+	// BPDU Guard applies to the CIST port the BPDU will be discarded
+	if p.BpduGuard &&
+		p.AdminEdge {
+		return
+	}
+
+	for i, mrec := range m.MRecords {
+		// information with no remaining hops is discarded
+		if mrec.RemainingHops == 0 {
+			continue
+		}
+		mstid := GetBridgeVlanFromBridgeId(mrec.RegionalRootId)
+		if StpFindPortByIfIndex(p.IfIndex, MstiBrgIfIndex(mstid), &mp) &&
+			mp.PrxmMachineFsm != nil {
+			mp.RcvdBPDU = true
+			mp.PrxmMachineFsm.PrxmRxBpduPkt <- RxBpduPdu{
+				pdu:   m.MstiRSTP(&m.MRecords[i]),
+				ptype: BPDURxTypeMSTP,
+				src:   MstpModuleStr}
+		}
+	}
+}
+
+// BuildMstiConfigMsg 14.6 build the M-record for a MSTI port
+func (p *StpPort) BuildMstiConfigMsg() MstiConfigMsg {
+	var flags uint8
+	StpSetBpduFlags(ConvertBoolToUint8(p.Master),
+		ConvertBoolToUint8(p.Agree),
+		ConvertBoolToUint8(p.Forwarding),
+		ConvertBoolToUint8(p.Learning),
+		ConvertRoleToPktRole(p.Role),
+		ConvertBoolToUint8(p.Proposed),
+		ConvertBoolToUint8(p.TcWhileTimer.count != 0),
+		&flags)
+
+	hops := uint8(0)
+	if p.b.RootTimes.MessageAge < uint16(StpMstRegion.MaxHops) {
+		hops = StpMstRegion.MaxHops - uint8(p.b.RootTimes.MessageAge)
+	}
+
+	return MstiConfigMsg{
+		Flags:                flags,
+		RegionalRootId:       p.PortPriority.RootBridgeId,
+		InternalRootPathCost: p.b.BridgePriority.RootPathCost,
+		BridgePriority:       uint8(GetBridgePriorityFromBridgeId(p.b.BridgeIdentifier)>>8) & 0xf0,
+		PortPriority:         uint8(p.Priority) & 0xf0,
+		RemainingHops:        hops,
+	}
+}
+
+// BuildMstBpdu 14.6 build the MST BPDU for a CIST port including a
+// M-record for each MSTI
+func (p *StpPort) BuildMstBpdu() *MstBpdu {
+	var flags uint8
+	StpSetBpduFlags(ConvertBoolToUint8(p.TcAck),
+		ConvertBoolToUint8(p.Agree),
+		ConvertBoolToUint8(p.Forwarding),
+		ConvertBoolToUint8(p.Learning),
+		ConvertRoleToPktRole(p.Role),
+		ConvertBoolToUint8(p.Proposed),
+		ConvertBoolToUint8(p.TcWhileTimer.count != 0),
+		&flags)
+
+	m := &MstBpdu{
+		ProtocolId:          uint16(layers.RSTPProtocolIdentifier),
+		ProtocolVersionId:   MSTPProtocolVersion,
+		BPDUType:            uint8(layers.BPDUTypeRSTP),
+		Flags:               flags,
+		CistRootId:          p.PortPriority.RootBridgeId,
+		CistExtRootPathCost: p.b.BridgePriority.RootPathCost,
+		CistRegionalRootId:  p.b.BridgePriority.RegionalRootId,
+		CistPortId:          uint16(p.PortId | p.Priority<<8),
+		MsgAge:              uint16(p.b.RootTimes.MessageAge << 8),
+		MaxAge:              uint16(p.b.RootTimes.MaxAge << 8),
+		HelloTime:           uint16(p.b.RootTimes.HelloTime << 8),
+		FwdDelay:            uint16(p.b.RootTimes.ForwardingDelay << 8),
+		Version1Length:      0,
+		ConfigId:            StpMstRegion.ConfigId,
+		CistIntRootPathCost: p.b.BridgePriority.IntRootPathCost,
+		CistBridgeId:        p.b.BridgeIdentifier,
+		CistRemainingHops:   p.b.RootTimes.RemainingHops,
+	}
+
+	for _, mp := range p.MstiPorts() {
+		m.MRecords = append(m.MRecords, mp.BuildMstiConfigMsg())
+	}
+	return m
+}
+
+// TxMSTP will transmit a MST BPDU on the CIST port
+func (p *StpPort) TxMSTP() {
+	if handle := p.txHandle(); handle != nil {
+		eth, llc := p.BuildRSTPEthernetLlcHeaders()
+		mstp := p.BuildMstBpdu().Encode()
+		eth.Length = uint16(len(mstp) + 3)
+
+		// Set up buffer and options for serialization.
+		buf := gopacket.NewSerializeBuffer()
+		opts := gopacket.SerializeOptions{
+			FixLengths:       true,
+			ComputeChecksums: true,
+		}
+		gopacket.SerializeLayers(buf, opts, &eth, &llc, gopacket.Payload(mstp))
+		if err := handle.WritePacketData(buf.Bytes()); err != nil {
+			StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
+			return
+		}
+		pIntf, _ := PortConfigMap[p.IfIndex]
+		p.SetTxPortCounters(BPDURxTypeMSTP)
+		if p.TcWhileTimer.count != 0 {
+			StpMachineLogger("DEBUG", "TX", p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Sent TC packet on interface %s\n", pIntf.Name))
+			p.SetTxPortCounters(BPDURxTypeTopo)
+		}
+		if p.TcAck {
+			StpMachineLogger("DEBUG", "TX", p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Sent TC Ack packet on interface %s\n", pIntf.Name))
+			p.SetTxPortCounters(BPDURxTypeTopoAck)
+		}
+	}
+}
+
+// TxMsti MSTI ports do not transmit on their own, the MSTI information is
+// carried as a M-record in the MST BPDU transmitted by the CIST port, so
+// the CIST port is told it has new info and transmits a single MST BPDU
+// carrying all M-records subject to its own TxHoldCount
+func (p *StpPort) TxMsti() {
+	cp := p.CistPort()
+	if cp != nil &&
+		cp.PtxmMachineFsm != nil {
+		cp.PtxmMachineFsm.PtxmEvents <- MachineEvent{
+			e:   PtxmEventMstiNewInfo,
+			src: MstpModuleStr,
+		}
+	}
+}
@@ -0,0 +1,469 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// mstp_test.go
+package stp
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"testing"
+	"utils/fsm"
+)
+
+func UsedForTestOnlyMstpCistSetup(t *testing.T) (*StpPortConfig, *StpBridgeConfig) {
+	brgcfg := StpBridgeConfigSetup()
+	brgcfg.Vlan = DEFAULT_STP_BRIDGE_VLAN
+	brgcfg.ForceVersion = StpForceVersionMSTP
+
+	err := StpBrgConfigParamCheck(brgcfg, true)
+	if err != nil {
+		t.Error("ERROR valid MSTP brg config failed", err)
+	}
+	err = StpBridgeCreate(brgcfg)
+	if err != nil {
+		t.Error("ERROR valid MSTP brg creation failed", err)
+	}
+
+	pcfg, _ := StpPortConfigSetup(false, false)
+	pcfg.BrgIfIndex = DEFAULT_STP_BRIDGE_VLAN
+	err = StpPortCreate(pcfg)
+	if err != nil {
+		t.Error("ERROR valid stp port creation failed", err)
+	}
+	return pcfg, brgcfg
+}
+
+func TestMstConfigDigest(t *testing.T) {
+	var vlanmap [4096]uint16
+
+	// 13.8 all vlans mapped to the CIST
+	expected := [16]uint8{0xAC, 0x36, 0x17, 0x7F, 0x50, 0x28, 0x3C, 0xD4, 0xB8, 0x38, 0x21, 0xD8, 0xAB, 0x26, 0xDE, 0x62}
+	if MstConfigDigest(&vlanmap) != expected {
+		t.Errorf("ERROR default configuration digest incorrect %X", MstConfigDigest(&vlanmap))
+	}
+
+	vlanmap[10] = 1
+	if MstConfigDigest(&vlanmap) == expected {
+		t.Error("ERROR configuration digest did not change when vlan mapping changed")
+	}
+}
+
+func TestMstBpduEncodeDecode(t *testing.T) {
+	m := &MstBpdu{
+		ProtocolId:          uint16(layers.RSTPProtocolIdentifier),
+		ProtocolVersionId:   MSTPProtocolVersion,
+		BPDUType:            uint8(layers.BPDUTypeRSTP),
+		Flags:               0x7c,
+		CistRootId:          BridgeId{0x80, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		CistExtRootPathCost: 20000,
+		CistPortId:          0x8001,
+		MsgAge:              1 << 8,
+		MaxAge:              20 << 8,
+		HelloTime:           2 << 8,
+		FwdDelay:            15 << 8,
+		CistRemainingHops:   MstpMaxHopsDefault,
+		MRecords: []MstiConfigMsg{
+			{
+				Flags:                MstiMasterFlag | 0x3c,
+				RegionalRootId:       CreateBridgeId([6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, 8192, 1),
+				InternalRootPathCost: 200000,
+				BridgePriority:       0x20,
+				PortPriority:         0x80,
+				RemainingHops:        19,
+			},
+		},
+	}
+	m.ConfigId.Revision = 1
+	copy(m.ConfigId.Name[:], "REGION1")
+
+	data := m.Encode()
+	if len(data) != MstBpduCistLength+MstBpduMRecordLength ||
+		m.Version3Length != MstBpduVersion3LengthBase+MstBpduMRecordLength {
+		t.Error("ERROR MST BPDU encoded length incorrect", len(data), m.Version3Length)
+	}
+
+	rm, err := DecodeMstBpdu(data)
+	if err != nil {
+		t.Error("ERROR valid MST BPDU failed to decode", err)
+	} else if rm.ConfigId != m.ConfigId ||
+		rm.CistRootId != m.CistRootId ||
+		len(rm.MRecords) != 1 ||
+		rm.MRecords[0] != m.MRecords[0] {
+		t.Errorf("ERROR MST BPDU decode mismatch %#v", rm)
+	}
+
+	// truncated M-record
+	_, err = DecodeMstBpdu(data[:len(data)-1])
+	if err == nil {
+		t.Error("ERROR truncated MST BPDU decoded")
+	}
+
+	// RST BPDU
+	data[2] = layers.RSTPProtocolVersion
+	_, err = DecodeMstBpdu(data)
+	if err == nil {
+		t.Error("ERROR RST BPDU decoded as MST BPDU")
+	}
+}
+
+func TestMstiCreationDeletion(t *testing.T) {
+	defer MemoryCheck(t)
+
+	// MSTI requires the CIST to exist
+	msticfg := &StpMstiConfig{
+		Mstid:    1,
+		Priority: 8192,
+		Vlans:    []uint16{10, 20},
+	}
+	err := StpMstiConfigParamCheck(msticfg, true)
+	if err == nil {
+		t.Error("ERROR MSTI param check passed without a MSTP bridge")
+	}
+
+	// MSTP only valid on the default bridge
+	brgcfg := StpBridgeConfigSetup()
+	brgcfg.ForceVersion = StpForceVersionMSTP
+	err = StpBrgConfigParamCheck(brgcfg, true)
+	if err == nil {
+		t.Error("ERROR MSTP allowed on a vlan bridge")
+	}
+
+	pcfg, brgcfg := UsedForTestOnlyMstpCistSetup(t)
+	defaultDigest := StpMstRegion.ConfigId.Digest
+
+	invalid := []StpMstiConfig{
+		{Mstid: 0, Priority: 8192},
+		{Mstid: 4095, Priority: 8192},
+		{Mstid: 1, Priority: 100},
+		{Mstid: 1, Priority: 8192, Vlans: []uint16{0}},
+		{Mstid: 1, Priority: 8192, Vlans: []uint16{4095}},
+	}
+	for _, c := range invalid {
+		err = StpMstiConfigParamCheck(&c, true)
+		if err == nil {
+			t.Errorf("ERROR invalid MSTI config passed param check %#v", c)
+		}
+	}
+
+	err = StpMstiConfigParamCheck(msticfg, true)
+	if err != nil {
+		t.Error("ERROR valid MSTI config failed", err)
+	}
+	err = StpMstiCreate(msticfg)
+	if err != nil {
+		t.Error("ERROR valid MSTI creation failed", err)
+	}
+
+	var b *Bridge
+	if !StpFindMstiBridgeById(msticfg.Mstid, &b) {
+		t.Error("ERROR unable to find MSTI that was just created")
+	} else {
+		vlans := b.StgVlanList()
+		if len(vlans) != 2 || vlans[0] != 10 || vlans[1] != 20 {
+			t.Error("ERROR MSTI stg vlan list incorrect", vlans)
+		}
+		if GetBridgeVlanFromBridgeId(b.BridgeIdentifier) != msticfg.Mstid ||
+			GetBridgePriorityFromBridgeId(b.BridgeIdentifier)&0xf000 != msticfg.Priority {
+			t.Errorf("ERROR MSTI bridge identifier incorrect %#v", b.BridgeIdentifier)
+		}
+	}
+
+	if StpMstRegion.ConfigId.Digest == defaultDigest {
+		t.Error("ERROR configuration digest did not change after MSTI creation")
+	}
+
+	var p *StpPort
+	var mp *StpPort
+	if !StpFindPortByIfIndex(pcfg.IfIndex, MstiBrgIfIndex(msticfg.Mstid), &mp) {
+		t.Error("ERROR unable to find MSTI port for CIST port")
+	} else if !StpFindPortByIfIndex(pcfg.IfIndex, pcfg.BrgIfIndex, &p) ||
+		mp.CistPort() != p {
+		t.Error("ERROR MSTI port not associated with CIST port")
+	}
+
+	// vlan already allocated
+	err = StpMstiConfigParamCheck(&StpMstiConfig{Mstid: 2, Vlans: []uint16{10}}, true)
+	if err == nil {
+		t.Error("ERROR vlan allocated to two MSTIs")
+	}
+
+	// MSTI already exists
+	err = StpMstiConfigParamCheck(msticfg, true)
+	if err == nil {
+		t.Error("ERROR duplicate MSTI passed param check")
+	}
+
+	// can not leave MSTP while MSTIs exist
+	err = StpBrgForceVersion(DEFAULT_STP_BRIDGE_VLAN, 2)
+	if err == nil {
+		t.Error("ERROR force version changed while MSTIs exist")
+	}
+
+	err = StpMstiVlanSet(msticfg.Mstid, []uint16{30})
+	if err != nil {
+		t.Error("ERROR valid MSTI vlan set failed", err)
+	}
+	if StpMstRegion.VlanMap[10] != 0 ||
+		StpMstRegion.VlanMap[30] != msticfg.Mstid {
+		t.Error("ERROR MST configuration table not updated")
+	}
+
+	// deleting the CIST port deletes the MSTI ports
+	err = StpPortDelete(pcfg)
+	if err != nil {
+		t.Error("ERROR valid stp port deletion failed", err)
+	}
+	if StpFindPortByIfIndex(pcfg.IfIndex, MstiBrgIfIndex(msticfg.Mstid), &mp) {
+		t.Error("ERROR MSTI port found after CIST port deleted")
+	}
+
+	err = StpMstiDelete(msticfg)
+	if err != nil {
+		t.Error("ERROR valid MSTI deletion failed", err)
+	}
+	if StpMstRegion.ConfigId.Digest != defaultDigest ||
+		len(StpMstiConfigMap) != 0 {
+		t.Error("ERROR MST region not restored after MSTI deletion")
+	}
+
+	err = StpBridgeDelete(brgcfg)
+	if err != nil {
+		t.Error("ERROR valid deletion failed", err)
+	}
+}
+
+func TestMstBpduRegionBoundary(t *testing.T) {
+	defer MemoryCheck(t)
+
+	pcfg, brgcfg := UsedForTestOnlyMstpCistSetup(t)
+	msticfg := &StpMstiConfig{
+		Mstid:    1,
+		Priority: 8192,
+		Vlans:    []uint16{10},
+	}
+	StpMstiCreate(msticfg)
+
+	var p *StpPort
+	if !StpFindPortByIfIndex(pcfg.IfIndex, pcfg.BrgIfIndex, &p) {
+		t.Error("ERROR unable to find bridge port")
+		return
+	}
+
+	buildPacket := func(m *MstBpdu) gopacket.Packet {
+		eth, llc := p.BuildRSTPEthernetLlcHeaders()
+		data := m.Encode()
+		eth.Length = uint16(len(data) + 3)
+		buf := gopacket.NewSerializeBuffer()
+		gopacket.SerializeLayers(buf, gopacket.SerializeOptions{}, &eth, &llc, gopacket.Payload(data))
+		return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	}
+
+	// same MST Configuration Identifier, internal to the region
+	m := p.BuildMstBpdu()
+	if len(m.MRecords) != 1 {
+		t.Error("ERROR MST BPDU does not contain MSTI M-record", len(m.MRecords))
+	}
+	ptype, mst := ProcessMstBpduFrame(p, BPDURxTypeRSTP, buildPacket(m))
+	if ptype != BPDURxTypeMSTP || !p.RcvdInternal || mst == nil {
+		t.Error("ERROR MST BPDU from same region not detected as internal", ptype, p.RcvdInternal)
+	}
+
+	// different revision, region boundary
+	m = p.BuildMstBpdu()
+	m.ConfigId.Revision++
+	ptype, mst = ProcessMstBpduFrame(p, BPDURxTypeRSTP, buildPacket(m))
+	if ptype != BPDURxTypeMSTP || p.RcvdInternal || mst != nil {
+		t.Error("ERROR MST BPDU from different region not detected as boundary", ptype, p.RcvdInternal)
+	}
+
+	StpMstiDelete(msticfg)
+	StpPortDelete(pcfg)
+	StpBridgeDelete(brgcfg)
+}
+
+func TestMstBpduCistRegionalInfo(t *testing.T) {
+	defer MemoryCheck(t)
+
+	pcfg, brgcfg := UsedForTestOnlyMstpCistSetup(t)
+
+	var p *StpPort
+	if !StpFindPortByIfIndex(pcfg.IfIndex, pcfg.BrgIfIndex, &p) {
+		t.Error("ERROR unable to find bridge port")
+		return
+	}
+
+	// regional root, internal root path cost and remaining hops are
+	// taken from the bridge priority vector and root times
+	regionalRoot := CreateBridgeId([6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, 4096, 0)
+	p.b.BridgePriority.RegionalRootId = regionalRoot
+	p.b.BridgePriority.IntRootPathCost = 40000
+	p.b.RootTimes.RemainingHops = 15
+	m := p.BuildMstBpdu()
+	if m.CistRegionalRootId != regionalRoot ||
+		m.CistIntRootPathCost != 40000 ||
+		m.CistRemainingHops != 15 {
+		t.Errorf("ERROR MST BPDU CIST regional info incorrect %#v", m)
+	}
+
+	// remaining hops are decremented on receipt
+	rm, err := DecodeMstBpdu(m.Encode())
+	if err != nil {
+		t.Error("ERROR valid MST BPDU failed to decode", err)
+	} else {
+		msgpriority := rm.CistMsgPriority()
+		if msgpriority.RegionalRootId != regionalRoot ||
+			msgpriority.IntRootPathCost != 40000 {
+			t.Errorf("ERROR MST BPDU message priority incorrect %#v", msgpriority)
+		}
+		if rm.CistMsgTimes().RemainingHops != 14 {
+			t.Error("ERROR MST BPDU remaining hops not decremented", rm.CistMsgTimes().RemainingHops)
+		}
+	}
+
+	// a superior regional root is preferred when the root and external
+	// root path cost are the same
+	msgpriority := p.b.BridgePriority
+	msgpriority.RegionalRootId = CreateBridgeId([6]uint8{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}, 0, 0)
+	if !IsMsgPriorityVectorSuperiorThanPortPriorityVector(&msgpriority, &p.b.BridgePriority) {
+		t.Error("ERROR superior regional root not detected")
+	}
+	msgpriority = p.b.BridgePriority
+	msgpriority.IntRootPathCost++
+	if !IsMsgPriorityVectorWorseThanPortPriorityVector(&msgpriority, &p.b.BridgePriority) {
+		t.Error("ERROR worse internal root path cost not detected")
+	}
+
+	// internal ports add the port path cost to the internal root path cost
+	p.RcvdInternal = true
+	rpv := p.RootPathPriority()
+	if rpv.RootPathCost != p.PortPriority.RootPathCost ||
+		rpv.IntRootPathCost != p.PortPriority.IntRootPathCost+p.PortPathCost {
+		t.Errorf("ERROR internal root path priority incorrect %#v", rpv)
+	}
+	p.RcvdInternal = false
+	rpv = p.RootPathPriority()
+	if rpv.RootPathCost != p.PortPriority.RootPathCost+p.PortPathCost ||
+		rpv.RegionalRootId != p.b.BridgeIdentifier ||
+		rpv.IntRootPathCost != 0 {
+		t.Errorf("ERROR boundary root path priority incorrect %#v", rpv)
+	}
+
+	StpPortDelete(pcfg)
+	StpBridgeDelete(brgcfg)
+}
+
+func TestMstRegionMaxHops(t *testing.T) {
+	invalid := []uint8{MstpMaxHopsMin - 1, MstpMaxHopsMax + 1}
+	for _, maxhops := range invalid {
+		err := StpMstRegionConfigParamCheck(&StpMstRegionConfig{MaxHops: maxhops})
+		if err == nil {
+			t.Error("ERROR invalid MaxHops passed param check", maxhops)
+		}
+	}
+
+	err := StpMstRegionConfigSet(&StpMstRegionConfig{MaxHops: MstpMaxHopsMax})
+	if err != nil || StpMstRegion.MaxHops != MstpMaxHopsMax {
+		t.Error("ERROR valid MaxHops not set", err, StpMstRegion.MaxHops)
+	}
+
+	// MSTI hops are carried as message age against MaxHops
+	m := &MstBpdu{}
+	rstp := m.MstiRSTP(&MstiConfigMsg{RemainingHops: MstpMaxHopsMax - 2})
+	if rstp.MsgAge != 2<<8 || rstp.MaxAge != MstpMaxHopsMax<<8 {
+		t.Error("ERROR MSTI hops not relative to MaxHops", rstp.MsgAge, rstp.MaxAge)
+	}
+
+	// 0 restores the default
+	err = StpMstRegionConfigSet(&StpMstRegionConfig{})
+	if err != nil || StpMstRegion.MaxHops != MstpMaxHopsDefault {
+		t.Error("ERROR default MaxHops not restored", err, StpMstRegion.MaxHops)
+	}
+}
+
+type UsedForTestOnlyMstpTxHandle struct {
+	frames [][]byte
+}
+
+func (h *UsedForTestOnlyMstpTxHandle) WritePacketData(data []byte) error {
+	h.frames = append(h.frames, data)
+	return nil
+}
+
+func TestMstiNewInfoTxHoldCount(t *testing.T) {
+	handle := &UsedForTestOnlyMstpTxHandle{}
+	b := &Bridge{
+		ForceVersion: StpForceVersionMSTP,
+		Vlan:         DEFAULT_STP_BRIDGE_VLAN,
+		TxHoldCount:  2,
+	}
+	p := &StpPort{
+		b:                       b,
+		SendRSTP:                true,
+		Selected:                true,
+		usedForTestOnlyTxHandle: handle,
+	}
+	p.HelloWhenTimer.count = BridgeHelloTimeDefault
+
+	ptxm := PtxmMachineFSMBuild(p)
+	ptxm.Machine.Curr.SetState(PtxmStateIdle)
+
+	// each MSTI with new info is carried by the CIST port, which transmits
+	// a single MST BPDU per new info limited by its own TxHoldCount
+	for i := 0; i < 3; i++ {
+		ptxm.Machine.ProcessEvent("TEST", PtxmEventMstiNewInfo, nil)
+		ptxm.ProcessPostStateProcessing()
+	}
+	if len(handle.frames) != 2 ||
+		p.TxCount != 2 ||
+		!p.NewInfo {
+		t.Error("ERROR MSTI new info not limited by CIST TxHoldCount", len(handle.frames), p.TxCount, p.NewInfo)
+	}
+
+	for _, frame := range handle.frames {
+		packet := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+		llcLayer := packet.Layer(layers.LayerTypeLLC)
+		if llcLayer == nil {
+			t.Error("ERROR MST BPDU missing LLC header")
+			continue
+		}
+		if _, err := DecodeMstBpdu(llcLayer.LayerPayload()); err != nil {
+			t.Error("ERROR CIST port did not transmit a MST BPDU", err)
+		}
+	}
+
+	// MSTI new info must not be dropped while the CIST port transmit
+	// machine is not idle
+	for _, s := range []fsm.State{PtxmStateTransmitInit,
+		PtxmStateTransmitConfig,
+		PtxmStateTransmitTCN,
+		PtxmStateTransmitPeriodic,
+		PtxmStateTransmitRSTP} {
+		p.NewInfo = false
+		ptxm.Machine.Curr.SetState(s)
+		if err := ptxm.Machine.ProcessEvent("TEST", PtxmEventMstiNewInfo, nil); err != nil ||
+			!p.NewInfo {
+			t.Error("ERROR MSTI new info dropped in state", PtxmStateStrMap[s], err)
+		}
+	}
+}
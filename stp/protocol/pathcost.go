@@ -0,0 +1,265 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// pathcost.go
+// Port Path Cost
+//
+// When the port path cost is automatically determined the cost is derived
+// from the speed of the port, for a LAG the speed is the aggregate speed of
+// the member ports.  The cost is recalculated when the speed of a port or the
+// membership of a LAG changes, a change in cost will trigger port role
+// reselection.  A bridge may use either the 32-bit long method (Table 17-3)
+// or the legacy 16-bit short method of 802.1D-1998.
+package stp
+
+import (
+	"asicd/asicdCommonDefs"
+	"errors"
+	"fmt"
+)
+
+type PathCostMethod int
+
+const (
+	PathCostMethodLong PathCostMethod = iota
+	PathCostMethodShort
+)
+
+var PathCostMethodStrMap = map[PathCostMethod]string{
+	PathCostMethodLong:  "long",
+	PathCostMethodShort: "short",
+}
+
+// 802.1D-1998 Table 8-5 recommended path cost values, speed in Mb/s
+var shortPathCostTable = []struct {
+	speed int32
+	cost  uint32
+}{
+	{4, 250},
+	{10, 100},
+	{16, 62},
+	{100, 19},
+	{1000, 4},
+	{10000, 2},
+}
+
+// LAG ifindex -> member ifindex list
+var StpLagMemberMap map[int32][]int32
+
+// GetPathCostFromSpeed returns the path cost for the speed in Mb/s, zero
+// is returned when the speed is not known
+func GetPathCostFromSpeed(speed int32, method PathCostMethod) uint32 {
+	if speed <= 0 {
+		return 0
+	}
+	if method == PathCostMethodShort {
+		for _, ent := range shortPathCostTable {
+			if speed <= ent.speed {
+				return ent.cost
+			}
+		}
+		return 1
+	}
+	// Table 17-3 20,000,000,000 / speed in Kb/s
+	cost := uint32(int64(20000000) / int64(speed))
+	if cost < 1 {
+		cost = 1
+	} else if cost > PortPathCostSpeedLess100Kbs {
+		cost = PortPathCostSpeedLess100Kbs
+	}
+	return cost
+}
+
+// GetAutoPathCost returns the path cost for the current speed of the port,
+// MSTI ports use the method of the CIST
+func (p *StpPort) GetAutoPathCost() uint32 {
+	b := p.b
+	if b.IsMsti() {
+		var cb *Bridge
+		if StpFindCistBridge(&cb) {
+			b = cb
+		}
+	}
+	return GetPathCostFromSpeed(PortConfigMap[p.IfIndex].Speed, b.PathCostMethod)
+}
+
+// PathCostUpdate will recalculate the path cost of an auto path cost port
+func (p *StpPort) PathCostUpdate(src string) {
+	if p.PathCostAuto {
+		if cost := p.GetAutoPathCost(); cost != 0 {
+			p.PortPathCostApply(src, cost)
+		}
+	}
+}
+
+// PortPathCostApply will set the port path cost and trigger reselection
+func (p *StpPort) PortPathCostApply(src string, cost uint32) {
+	if p.PortPathCost != cost {
+		StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Port Path Cost changed %d -> %d", p.PortPathCost, cost))
+		p.PortPathCost = cost
+		p.Selected = false
+		p.Reselect = true
+		if p.b.PrsMachineFsm != nil {
+			p.b.PrsMachineFsm.PrsEvents <- MachineEvent{
+				e:   PrsEventReselect,
+				src: src,
+			}
+		}
+	}
+}
+
+// stpPortsPathCostUpdate recalculates the path cost of all bridge ports for
+// the given port
+func stpPortsPathCostUpdate(ifindex int32, src string) {
+	portList := make([]*StpPort, 0)
+	portDbMutex.Lock()
+	for _, p := range PortListTable {
+		if p.IfIndex == ifindex {
+			portList = append(portList, p)
+		}
+	}
+	portDbMutex.Unlock()
+
+	for _, p := range portList {
+		p.PathCostUpdate(src)
+	}
+}
+
+// stpLagSpeedUpdate sets the LAG speed to the aggregate speed of the members
+// which are up, a member which is down can not be distributing
+func stpLagSpeedUpdate(lagifindex int32) bool {
+	speed := int32(0)
+	for _, ifindex := range StpLagMemberMap[lagifindex] {
+		if PortConfigMap[ifindex].LinkUp {
+			speed += PortConfigMap[ifindex].Speed
+		}
+	}
+	ent := PortConfigMap[lagifindex]
+	if ent.Speed == speed {
+		return false
+	}
+	ent.Speed = speed
+	PortConfigMap[lagifindex] = ent
+	return true
+}
+
+// stpLagMemberUpdate updates the speed of any LAG the port is a member of
+func stpLagMemberUpdate(ifindex int32) {
+	for lagifindex, members := range StpLagMemberMap {
+		for _, member := range members {
+			if member == ifindex {
+				if stpLagSpeedUpdate(lagifindex) {
+					stpPortsPathCostUpdate(lagifindex, "LAG SPEED CHANGE")
+				}
+				break
+			}
+		}
+	}
+}
+
+// StpPortSpeedSet is called when the speed of a port changes
+func StpPortSpeedSet(ifindex int32, speed int32) {
+	ent, ok := PortConfigMap[ifindex]
+	if !ok ||
+		ent.Speed == speed {
+		return
+	}
+	StpLogger("INFO", fmt.Sprintf("Port %d speed changed %d -> %d", ifindex, ent.Speed, speed))
+	ent.Speed = speed
+	PortConfigMap[ifindex] = ent
+	stpPortsPathCostUpdate(ifindex, "SPEED CHANGE")
+
+	// update any LAG this port is a member of
+	stpLagMemberUpdate(ifindex)
+}
+
+// StpPortLinkStateSet is called when the link state of a port changes, only
+// members which are up contribute to the speed of a LAG
+func StpPortLinkStateSet(ifindex int32, linkup bool) {
+	ent, ok := PortConfigMap[ifindex]
+	if !ok ||
+		ent.LinkUp == linkup {
+		return
+	}
+	ent.LinkUp = linkup
+	PortConfigMap[ifindex] = ent
+	stpLagMemberUpdate(ifindex)
+}
+
+// StpLagMembersSet is called when the member ports of a LAG change, a nil
+// member list will remove the LAG
+func StpLagMembersSet(lagifindex int32, members []int32) {
+	if members == nil {
+		delete(StpLagMemberMap, lagifindex)
+		return
+	}
+	StpLagMemberMap[lagifindex] = members
+	stpLagSpeedUpdate(lagifindex)
+	StpLogger("INFO", fmt.Sprintf("LAG %d members %v speed %d", lagifindex, members, PortConfigMap[lagifindex].Speed))
+	stpPortsPathCostUpdate(lagifindex, "LAG MEMBER CHANGE")
+}
+
+// StpPortSpeedRefresh will read the current speed of the port from asicd,
+// asicd indexes the port list by port id so only this port is read
+func StpPortSpeedRefresh(ifindex int32) {
+	for _, client := range GetAsicDPluginList() {
+		bulkCfgInfo, err := client.GetBulkPort(asicdCommonDefs.GetIntfIdFromIfIndex(ifindex), 1)
+		if err != nil {
+			StpLogger("ERROR", fmt.Sprintf("GetBulkPort Error: %s", err))
+			return
+		}
+		if bulkCfgInfo.Count > 0 &&
+			bulkCfgInfo.PortList[0].IfIndex == ifindex {
+			StpPortSpeedSet(ifindex, bulkCfgInfo.PortList[0].Speed)
+			return
+		}
+	}
+	StpLogger("ERROR", fmt.Sprintf("Port %d not found reading speed", ifindex))
+}
+
+// StpBrgPathCostMethodSet will set the path cost method used by auto path
+// cost ports on the bridge
+func StpBrgPathCostMethodSet(bId int32, method PathCostMethod) error {
+	var b *Bridge
+	if StpFindBridgeByIfIndex(bId, &b) {
+		if b.PathCostMethod != method {
+			StpLogger("INFO", fmt.Sprintf("Bridge %d Path Cost Method %s", bId, PathCostMethodStrMap[method]))
+			b.PathCostMethod = method
+			portList := make([]*StpPort, 0)
+			portDbMutex.Lock()
+			for _, p := range PortListTable {
+				// MSTI ports use the method of the CIST
+				if p.b == b ||
+					(b.IsMstpCist() && p.b.IsMsti()) {
+					portList = append(portList, p)
+				}
+			}
+			portDbMutex.Unlock()
+			for _, p := range portList {
+				p.PathCostUpdate("CONFIG: PathCostMethodSet")
+			}
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("Invalid bridge %d supplied for setting Path Cost Method", bId))
+}
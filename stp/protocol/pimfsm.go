@@ -174,6 +174,7 @@ func (pim *PimMachine) PimMachineDisabled(m fsm.Machine, data interface{}) fsm.S
 	p.Agreed = false
 	p.RcvdInfoWhiletimer.count = 0
 	p.InfoIs = PortInfoStateDisabled
+	p.SetLoopGuardInconsistant(PimMachineModuleStr, false)
 	defer p.NotifySelectedChanged(PimMachineModuleStr, p.Selected, false)
 	p.Selected = false
 	defer pim.NotifyReselectChanged(p.Reselect, true)
@@ -213,9 +214,12 @@ func (pim *PimMachine) PimMachineUpdate(m fsm.Machine, data interface{}) fsm.Sta
 		p.PortPriority.RootBridgeId = p.b.BridgePriority.RootBridgeId
 		p.PortPriority.RootPathCost = p.b.BridgePriority.RootPathCost
 	}
+	p.PortPriority.RegionalRootId = p.b.BridgePriority.RegionalRootId
+	p.PortPriority.IntRootPathCost = p.b.BridgePriority.IntRootPathCost
 	p.PortPriority.DesignatedBridgeId = p.b.BridgeIdentifier
 	p.PortPriority.DesignatedPortId = uint16(p.Priority<<8 | p.PortId)
 	p.PortTimes = p.b.BridgeTimes
+	p.PortTimes.RemainingHops = p.b.RootTimes.RemainingHops
 	//defer p.NotifyUpdtInfoChanged(PimMachineModuleStr, p.UpdtInfo, false)
 	p.UpdtInfo = false
 	p.InfoIs = PortInfoStateMine
@@ -239,6 +243,10 @@ func (pim *PimMachine) PimMachineReceive(m fsm.Machine, data interface{}) fsm.St
 		p.BAWhileTimer.count = int32(p.b.RootTimes.HelloTime * 3)
 		p.BridgeAssuranceInconsistant = false
 	}
+	if p.LoopGuardInconsistant {
+		p.SetLoopGuardInconsistant(PimMachineModuleStr, false)
+		p.GuardReselect(PimMachineModuleStr)
+	}
 
 	return PimStateReceive
 }
@@ -1142,6 +1150,10 @@ func (pim *PimMachine) rcvInfo(data interface{}) PortDesignatedRcvInfo {
 	switch data.(type) {
 	case *layers.STP:
 		msgRole = PortRoleDesignatedPort
+	case *layers.PVST:
+		if data.(*layers.PVST).BPDUType == layers.BPDUTypeSTP {
+			msgRole = PortRoleDesignatedPort
+		}
 	}
 
 	if CompareBridgeAddr(GetBridgeAddrFromBridgeId(msgpriority.RootBridgeId), GetBridgeAddrFromBridgeId(p.b.BridgeIdentifier)) == 0 {
@@ -1203,6 +1215,9 @@ func (pim *PimMachine) getRcvdMsgFlags(bpduLayer interface{}) uint8 {
 		//StpMachineLogger("DEBUG", PimMachineModuleStr, p.IfIndex, "Found PVST frame getting flags")
 		pvst := bpduLayer.(*layers.PVST)
 		flags = uint8(pvst.Flags)
+	case *MstBpdu:
+		mst := bpduLayer.(*MstBpdu)
+		flags = mst.Flags
 		//default:
 		//	StpMachineLogger("ERROR", PimMachineModuleStr, p.IfIndex, fmt.Sprintf("Error getRcvdMsgFlags rcvd TCN %T\n", bpduLayer))
 	}
@@ -1234,6 +1249,9 @@ func (pim *PimMachine) getRcvdMsgPriority(bpduLayer interface{}) (msgpriority *P
 		msgpriority.DesignatedBridgeId = pvst.BridgeId
 		msgpriority.DesignatedPortId = pvst.PortId
 		msgpriority.BridgePortId = pvst.PortId
+	case *MstBpdu:
+		mst := bpduLayer.(*MstBpdu)
+		msgpriority = mst.CistMsgPriority()
 	}
 	return msgpriority
 }
@@ -1260,6 +1278,9 @@ func (pim *PimMachine) getRcvdMsgTimes(bpduLayer interface{}) (msgtimes *Times)
 		msgtimes.MaxAge = pvst.MaxAge >> 8
 		msgtimes.HelloTime = pvst.HelloTime >> 8
 		msgtimes.ForwardingDelay = pvst.FwdDelay >> 8
+	case *MstBpdu:
+		mst := bpduLayer.(*MstBpdu)
+		msgtimes = mst.CistMsgTimes()
 	}
 	return msgtimes
 }
@@ -1342,13 +1363,22 @@ func (pim *PimMachine) recordTimes(rcvdMsgTimes *Times) {
 	}
 	p.PortTimes.MaxAge = rcvdMsgTimes.MaxAge
 	p.PortTimes.MessageAge = rcvdMsgTimes.MessageAge
+	p.PortTimes.RemainingHops = rcvdMsgTimes.RemainingHops
 }
 
 // updtRcvdInfoWhile 17.21.23
 func (pim *PimMachine) updtRcvdInfoWhile() {
 	p := pim.p
 	//StpMachineLogger("DEBUG", PimMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("PortTimes msgAge[%d] maxAge[%d]", p.PortTimes.MessageAge, p.PortTimes.MaxAge))
-	if p.PortTimes.MessageAge+1 <= p.PortTimes.MaxAge {
+	// 13.26.23 information from within the region is aged by remaining hops
+	// which have already been decremented on receipt
+	if p.b.IsMstpCist() && p.RcvdInternal {
+		if p.PortTimes.RemainingHops > 0 {
+			p.RcvdInfoWhiletimer.count = 3 * int32(p.PortTimes.HelloTime)
+		} else {
+			p.RcvdInfoWhiletimer.count = 0
+		}
+	} else if p.PortTimes.MessageAge+1 <= p.PortTimes.MaxAge {
 		p.RcvdInfoWhiletimer.count = 3 * int32(p.PortTimes.HelloTime)
 	} else {
 		p.RcvdInfoWhiletimer.count = 0
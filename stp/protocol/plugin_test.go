@@ -10,3 +10,7 @@ func UsedForTestOnlySetupAsicDPlugin() {
 	// Use MOCK plugin
 	SetAsicDPlugin(&asicdmock.MockAsicdClientMgr{})
 }
+
+func UsedForTestOnlyDeleteAllAsicDPlugins() {
+	ClientIntfs = nil
+}
@@ -57,6 +57,7 @@ type portConfig struct {
 	HardwareAddr net.HardwareAddr
 	Speed        int32
 	IfIndex      int32
+	LinkUp       bool
 }
 
 type StpPort struct {
@@ -64,16 +65,26 @@ type StpPort struct {
 	ProtocolPortId uint16
 
 	// 17.19
-	AgeingTime                  int32 // TODO STP functionality
 	Agree                       bool
 	Agreed                      bool
 	AdminEdge                   bool
 	AutoEdgePort                bool // optional
 	AdminPathCost               int32
+	PathCostAuto                bool
 	BpduGuard                   bool
 	BpduGuardInterval           int32
+	ErrDisabled                 bool
+	ErrDisableReason            ErrDisableReason
 	BridgeAssurance             bool
 	BridgeAssuranceInconsistant bool
+	RootGuard                   bool
+	RootGuardInconsistant       bool
+	LoopGuard                   bool
+	LoopGuardInconsistant       bool
+	PvstInconsistency           PvstInconsistency
+	BpduFilter                  bool
+	BpduFilterEdgeReverted      bool
+	BpduFilterLinkUpTxCnt       int32
 	Disputed                    bool
 	FdbFlush                    bool
 	Forward                     bool
@@ -101,6 +112,7 @@ type StpPort struct {
 	RcvdSTP                     bool
 	RcvdTc                      bool
 	RcvdTcAck                   bool
+	RcvdTcBridgeId              BridgeId
 	RcvdTcn                     bool
 	RstpVersion                 bool
 	ReRoot                      bool
@@ -116,6 +128,9 @@ type StpPort struct {
 	Tick                        bool
 	TxCount                     uint64
 	UpdtInfo                    bool
+	// MSTP
+	RcvdInternal bool
+	Master       bool
 	// 6.4.3
 	OperPointToPointMAC  bool
 	AdminPointToPointMAC PointToPointMac
@@ -141,6 +156,8 @@ type StpPort struct {
 	RstpTx  uint64
 	PvstRx  uint64
 	PvstTx  uint64
+	MstpRx  uint64
+	MstpTx  uint64
 
 	ForwardingTransitions uint64
 
@@ -155,6 +172,10 @@ type StpPort struct {
 	TcWhileTimer        PortTimer
 	BAWhileTimer        PortTimer
 	BPDUGuardTimer      PortTimer
+	// Rapid-PVST+ inconsistent BPDU
+	PvstInconsistentWhileTimer PortTimer
+	// 17.19.1 stpVersion rapid ageing
+	RapidAgeingWhileTimer PortTimer
 
 	PrxmMachineFsm *PrxmMachine
 	PtmMachineFsm  *PtmMachine
@@ -170,6 +191,10 @@ type StpPort struct {
 
 	// handle used to tx packets to linux if
 	handle *pcap.Handle
+	// handle used in place of the linux if handle, only set by unit
+	// tests in order to capture the transmitted BPDUs or by the unit test
+	// simulator when the port is connected to a simulated link
+	usedForTestOnlyTxHandle BpduTxHandle
 
 	// a way to sync all machines
 	wg sync.WaitGroup
@@ -235,10 +260,10 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 		Role:                PortRoleDisabledPort,
 		SelectedRole:        PortRoleDisabledPort,
 		PortTimes:           RootTimes,
-		SendRSTP:            b.ForceVersion >= 2, // default
-		RcvdRSTP:            b.ForceVersion >= 2, // default
-		RstpVersion:         b.ForceVersion >= 2,
-		Mcheck:              b.ForceVersion >= 2,
+		SendRSTP:            !b.IsLegacyStp(), // default
+		RcvdRSTP:            !b.IsLegacyStp(), // default
+		RstpVersion:         !b.IsLegacyStp(),
+		Mcheck:              !b.IsLegacyStp(),
 		EdgeDelayWhileTimer: PortTimer{count: MigrateTimeDefault},
 		FdWhileTimer:        PortTimer{count: int32(b.RootTimes.ForwardingDelay)}, // TODO same as ForwardingDelay above
 		HelloWhenTimer:      PortTimer{count: int32(b.RootTimes.HelloTime)},
@@ -258,28 +283,22 @@ func NewStpPort(c *StpPortConfig) *StpPort {
 			DesignatedPortId:   uint16(uint16(pluginCommon.GetIdFromIfIndex(c.IfIndex)) | c.Priority<<8),
 		},
 		BridgeAssurance:   c.BridgeAssurance,
+		RootGuard:         c.RootGuard,
+		LoopGuard:         c.LoopGuard,
+		BpduFilter:        c.BpduFilter,
 		BpduGuard:         c.BpduGuard,
 		BpduGuardInterval: c.BpduGuardInterval,
 		b:                 b, // reference to brige
 	}
 
-	if c.AdminPathCost == 0 {
-		// TODO need to get speed of port to automatically the port path cost
-		// Table 17-3
-		AutoPathCostDefaultMap := map[int32]uint32{
-			10:         200000000,
-			100:        20000000,
-			1000:       2000000,
-			10000:      200000,
-			100000:     20000,
-			1000000:    2000,
-			10000000:   200,
-			100000000:  20,
-			1000000000: 2,
-		}
+	// path cost of 1 or admin path cost of 0 will auto discover the
+	// path cost from the speed of the port
+	p.PathCostAuto = c.AdminPathCost == 0 || c.PathCost <= 1
+	if p.PathCostAuto {
 		speed := PortConfigMap[p.IfIndex].Speed
-
-		p.PortPathCost = AutoPathCostDefaultMap[speed]
+		if cost := p.GetAutoPathCost(); cost != 0 {
+			p.PortPathCost = cost
+		}
 		StpLogger("INFO", fmt.Sprintf("Auto Port Path Cost for port %d speed %d = %d", p.IfIndex, speed, p.PortPathCost))
 	}
 
@@ -330,7 +349,7 @@ func (p *StpPort) PollLinuxLinkStatus() {
 				netifattr := netif.Attrs()
 				//StpLogger("DEBUG", fmt.Sprintf("Polling link flags%#v, running=0x%x up=0x%x check1 %t check2 %t", netifattr.Flags, syscall.IFF_RUNNING, syscall.IFF_UP, ((netifattr.Flags>>6)&0x1) == 1, (netifattr.Flags&1) == 1))
 				//if (((netifattr.Flags >> 6) & 0x1) == 1) && (netifattr.Flags&1) == 1 {
-				if (netifattr.Flags & 1) == 1 {
+				if (netifattr.Flags&1) == 1 && !p.ErrDisabled {
 					//StpLogger("DEBUG", "LINUX LINK UP")
 					prevPortEnabled := p.PortEnabled
 					p.PortEnabled = true
@@ -351,6 +370,12 @@ func (p *StpPort) PollLinuxLinkStatus() {
 */
 func DelStpPort(p *StpPort) {
 	p.Stop()
+	if p.ErrDisabled &&
+		!p.IsErrDisabledOnOtherBridge() {
+		for _, client := range GetAsicDPluginList() {
+			client.BPDUGuardDetected(p.IfIndex, false)
+		}
+	}
 	key := PortMapKey{
 		IfIndex:    p.IfIndex,
 		BrgIfIndex: p.b.BrgIfIndex,
@@ -568,6 +593,11 @@ func (p *StpPort) BEGIN(restart bool) {
 
 func (p *StpPort) CreateRxTx() {
 
+	// MSTI ports send and receive via the CIST port
+	if p.b != nil && p.b.IsMsti() {
+		return
+	}
+
 	if p.handle == nil {
 		// lets setup the port receive/transmit handle
 		ifName, _ := PortConfigMap[p.IfIndex]
@@ -683,6 +713,8 @@ func (p *StpPort) SetRxPortCounters(ptype BPDURxType) {
 		p.TcAckRx++
 	case BPDURxTypePVST:
 		p.PvstRx++
+	case BPDURxTypeMSTP:
+		p.MstpRx++
 	}
 }
 
@@ -699,6 +731,8 @@ func (p *StpPort) SetTxPortCounters(ptype BPDURxType) {
 		p.TcAckTx++
 	case BPDURxTypePVST:
 		p.PvstTx++
+	case BPDURxTypeMSTP:
+		p.MstpTx++
 	}
 }
 
@@ -766,6 +800,11 @@ func (p *StpPort) SaveMsgRcvInfo(data interface{}) {
 		p.MsgTimes.MaxAge = pvst.MaxAge >> 8
 		p.MsgTimes.MessageAge = pvst.MsgAge >> 8
 
+	case *MstBpdu:
+		mst := bpduLayer.(*MstBpdu)
+		p.MsgPriority = *mst.CistMsgPriority()
+		p.MsgTimes = *mst.CistMsgTimes()
+
 	}
 }
 
@@ -790,7 +829,10 @@ func (p *StpPort) NotifyPortEnabled(src string, oldportenabled bool, newportenab
 		// notify the state machines
 		if !newportenabled {
 
-			if p.EdgeDelayWhileTimer.count != MigrateTimeDefault {
+			// the receive machine handles the port being disabled itself
+			// when it is the caller, see ErrDisable
+			if p.EdgeDelayWhileTimer.count != MigrateTimeDefault &&
+				src != PrxmMachineModuleStr {
 				if p.PrxmMachineFsm != nil {
 					mEvtChan = append(mEvtChan, p.PrxmMachineFsm.PrxmEvents)
 					evt = append(evt, MachineEvent{e: PrxmEventEdgeDelayWhileNotEqualMigrateTimeAndNotPortEnabled,
@@ -843,6 +885,8 @@ func (p *StpPort) NotifyPortEnabled(src string, oldportenabled bool, newportenab
 			if p.BridgeAssurance {
 				p.BAWhileTimer.count = int32(p.b.RootTimes.HelloTime * 3)
 			}
+			// restart the edge BPDU filter link up transmit window
+			p.BpduFilterLinkUp()
 
 			/*
 				This should only be triggered from RcvdBpdu being set becuase
@@ -2283,6 +2327,8 @@ func ConstructPortConfigMap() {
 				ent.IfIndex = ifindex
 				ent.Name = bulkInfo.PortStateList[i].Name
 				ent.HardwareAddr, _ = net.ParseMAC(bulkCfgInfo.PortList[i].MacAddr)
+				ent.Speed = bulkCfgInfo.PortList[i].Speed
+				ent.LinkUp = client.GetPortLinkStatus(ifindex)
 				PortConfigMap[ifindex] = ent
 				StpLogger("INIT", fmt.Sprintf("Found Port IfIndex %d Name %s\n", ent.IfIndex, ent.Name))
 			}
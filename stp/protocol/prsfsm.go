@@ -147,6 +147,9 @@ func (prsm *PrsMachine) PrsMachineRoleSelection(m fsm.Machine, data interface{})
 	prsm.updtRolesTree()
 	prsm.setSelectedTree()
 
+	// MSTI boundary ports follow the CIST port role
+	prsm.b.MstiReselect(PrsMachineModuleStr)
+
 	return PrsStateRoleSelection
 }
 
@@ -254,6 +257,7 @@ func (prsm *PrsMachine) updtRolesTree() {
 
 	var p *StpPort
 	var rootPortId int32
+	rootPortInternal := false
 	rootPathVector := PriorityVector{
 		RootBridgeId:       b.BridgePriority.DesignatedBridgeId,
 		DesignatedBridgeId: b.BridgePriority.DesignatedBridgeId,
@@ -267,6 +271,13 @@ func (prsm *PrsMachine) updtRolesTree() {
 		MessageAge:      b.BridgeTimes.MessageAge,
 	}
 
+	// 13.10 this bridge is the CIST regional root until a root port
+	// is selected from within the region
+	if b.IsMstpCist() {
+		rootPathVector.RegionalRootId = b.BridgeIdentifier
+		rootTimes.RemainingHops = StpMstRegion.MaxHops
+	}
+
 	tmpVector := rootPathVector
 
 	// lets consider each port a root to begin with
@@ -277,7 +288,11 @@ func (prsm *PrsMachine) updtRolesTree() {
 		if StpFindPortByIfIndex(pId, b.BrgIfIndex, &p) {
 			StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: InfoIs %d", p.InfoIs))
 			// 17.21.25 (a)
-			if p.InfoIs == PortInfoStateReceived {
+			// root guard, loop and pvst inconsistent ports are not to be selected as root
+			if p.InfoIs == PortInfoStateReceived &&
+				!p.RootGuard &&
+				!p.LoopGuardInconsistant &&
+				p.PvstInconsistency == PvstInconsistencyNone {
 
 				/*if CompareBridgeAddr(GetBridgeAddrFromBridgeId(myBridgeId),
 					GetBridgeAddrFromBridgeId(p.PortPriority.DesignatedBridgeId)) == 0 {
@@ -292,31 +307,40 @@ func (prsm *PrsMachine) updtRolesTree() {
 					if prsm.debugLevel > 1 {
 						StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: Root Bridge Received is SUPERIOR port Priority %#v", p.PortPriority))
 					}
-					tmpVector.RootBridgeId = p.PortPriority.RootBridgeId
-					tmpVector.RootPathCost = p.PortPriority.RootPathCost + p.PortPathCost
+					rpv := p.RootPathPriority()
+					tmpVector.RootBridgeId = rpv.RootBridgeId
+					tmpVector.RootPathCost = rpv.RootPathCost
+					tmpVector.RegionalRootId = rpv.RegionalRootId
+					tmpVector.IntRootPathCost = rpv.IntRootPathCost
 					tmpVector.DesignatedBridgeId = p.PortPriority.DesignatedBridgeId
 					tmpVector.DesignatedPortId = p.PortPriority.DesignatedPortId
 					rootPortId = int32(p.Priority<<8 | p.PortId)
+					rootPortInternal = b.IsMstpCist() && p.RcvdInternal
 					// 17.21.25 (c)(2)
 					rootTimes = p.PortTimes
 				case 0:
 					if prsm.debugLevel > 1 {
 						StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Root Bridge Received by port SAME")
 					}
-					// 17.21.25 (b) path cost or port determines root
-					tmpCost := p.PortPriority.RootPathCost + p.PortPathCost
+					// 17.21.25 (b) path cost or port determines root, 13.10 the
+					// MSTP CIST regional root and internal path cost follow
+					rpv := p.RootPathPriority()
 					if prsm.debugLevel > 1 {
-						StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: rx+txCost[%d] bridgeCost[%d]", tmpCost, tmpVector.RootPathCost))
+						StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: rx+txCost[%d] bridgeCost[%d] rx+txIntCost[%d] bridgeIntCost[%d]", rpv.RootPathCost, tmpVector.RootPathCost, rpv.IntRootPathCost, tmpVector.IntRootPathCost))
 					}
-					if tmpCost < tmpVector.RootPathCost {
+					costCompare := CompareRootPathPriority(&rpv, &tmpVector)
+					if costCompare < 0 {
 						if prsm.debugLevel > 1 {
 							StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: DesignatedBridgeId received by port is SUPERIOR")
 						}
-						tmpVector.RootPathCost = tmpCost
+						tmpVector.RootPathCost = rpv.RootPathCost
+						tmpVector.RegionalRootId = rpv.RegionalRootId
+						tmpVector.IntRootPathCost = rpv.IntRootPathCost
 						tmpVector.DesignatedBridgeId = p.PortPriority.DesignatedBridgeId
 						tmpVector.DesignatedPortId = p.PortPriority.DesignatedPortId
 						rootPortId = int32(p.Priority<<8 | p.PortId)
-					} else if tmpCost == tmpVector.RootPathCost {
+						rootPortInternal = b.IsMstpCist() && p.RcvdInternal
+					} else if costCompare == 0 {
 						if prsm.debugLevel > 1 {
 							StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: DesignatedBridgeId received by port is SAME")
 						}
@@ -327,6 +351,7 @@ func (prsm *PrsMachine) updtRolesTree() {
 							}
 							tmpVector.DesignatedPortId = p.PortPriority.DesignatedPortId
 							rootPortId = int32(p.Priority<<8 | p.PortId)
+							rootPortInternal = b.IsMstpCist() && p.RcvdInternal
 						} else if p.PortPriority.DesignatedPortId ==
 							tmpVector.DesignatedPortId {
 							var rp *StpPort
@@ -339,6 +364,7 @@ func (prsm *PrsMachine) updtRolesTree() {
 										StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: received portId is SUPPERIOR")
 									}
 									rootPortId = int32(p.Priority<<8 | p.PortId)
+									rootPortInternal = b.IsMstpCist() && p.RcvdInternal
 								}
 							}
 						}
@@ -361,8 +387,17 @@ func (prsm *PrsMachine) updtRolesTree() {
 
 		b.BridgePriority.RootBridgeId = tmpVector.RootBridgeId
 		b.BridgePriority.RootPathCost = tmpVector.RootPathCost
+		b.BridgePriority.RegionalRootId = tmpVector.RegionalRootId
+		b.BridgePriority.IntRootPathCost = tmpVector.IntRootPathCost
 		b.RootTimes = rootTimes
-		b.RootTimes.MessageAge += 1
+		// 13.26.23 information from within the region is aged by the
+		// remaining hops, these were decremented on receipt
+		if !rootPortInternal {
+			b.RootTimes.MessageAge += 1
+			if b.IsMstpCist() {
+				b.RootTimes.RemainingHops = StpMstRegion.MaxHops
+			}
+		}
 		b.RootPortId = rootPortId
 	} else {
 		if prsm.debugLevel > 1 {
@@ -376,6 +411,8 @@ func (prsm *PrsMachine) updtRolesTree() {
 
 		b.BridgePriority.RootBridgeId = tmpVector.RootBridgeId
 		b.BridgePriority.RootPathCost = tmpVector.RootPathCost
+		b.BridgePriority.RegionalRootId = tmpVector.RegionalRootId
+		b.BridgePriority.IntRootPathCost = tmpVector.IntRootPathCost
 		b.RootTimes = rootTimes
 		b.RootPortId = 0
 	}
@@ -396,6 +433,9 @@ func (prsm *PrsMachine) updtRolesTree() {
 			p.b.BridgePriority.DesignatedPortId = 0
 			p.PortPriority.DesignatedPortId = 0
 			p.PortPriority.BridgePortId = 0
+			p.Master = false
+
+			p.SetRootGuardInconsistant(PrsMachineModuleStr, p.IsRootGuardBlocked())
 
 			if prsm.debugLevel > 1 {
 				StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, b.BrgIfIndex, fmt.Sprintf("updtRolesTree: portEnabled %t, infoIs %d\n", p.PortEnabled, p.InfoIs))
@@ -411,6 +451,18 @@ func (prsm *PrsMachine) updtRolesTree() {
 				if prsm.debugLevel > 1 {
 					StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree: Bridge Assurance port role selected ALTERNATE")
 				}
+			} else if p.PortEnabled &&
+				(p.RootGuardInconsistant || p.LoopGuardInconsistant ||
+					p.PvstInconsistency != PvstInconsistencyNone) {
+				// root guard superior info, loop guard bpdu timeout or
+				// rapid-pvst+ inconsistent bpdu, block the port
+				defer p.NotifyUpdtInfoChanged(PrsMachineModuleStr, p.UpdtInfo, false)
+				p.UpdtInfo = false
+				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, PortRoleAlternatePort)
+				p.SelectedRole = PortRoleAlternatePort
+				if prsm.debugLevel > 1 {
+					StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: root inconsistent %t loop inconsistent %t pvst %s port role selected ALTERNATE", p.RootGuardInconsistant, p.LoopGuardInconsistant, PvstInconsistencyStrMap[p.PvstInconsistency]))
+				}
 			} else if !p.PortEnabled || p.InfoIs == PortInfoStateDisabled {
 				// 17.21.25 (f) if port is disabled
 				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, PortRoleDisabledPort)
@@ -419,6 +471,18 @@ func (prsm *PrsMachine) updtRolesTree() {
 				if prsm.debugLevel > 1 {
 					StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, "updtRolesTree:1 port role selected DISABLED")
 				}
+			} else if b.IsMsti() && !p.IsMstiInternal() {
+				// MSTI boundary port role is the CIST port role,
+				// the CIST Root Port is the MSTI Master Port
+				role := p.MstiBoundaryRole()
+				p.Master = role == PortRoleRootPort
+				defer p.NotifySelectedRoleChanged(PrsMachineModuleStr, p.SelectedRole, role)
+				p.SelectedRole = role
+				defer p.NotifyUpdtInfoChanged(PrsMachineModuleStr, p.UpdtInfo, role == PortRoleDesignatedPort)
+				p.UpdtInfo = role == PortRoleDesignatedPort
+				if prsm.debugLevel > 1 {
+					StpMachineLogger("DEBUG", PrsMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("updtRolesTree: MSTI boundary port role selected %d master %t", role, p.Master))
+				}
 			} else if p.InfoIs == PortInfoStateAged {
 				// 17.21.25 (g)
 				defer p.NotifyUpdtInfoChanged(PrsMachineModuleStr, p.UpdtInfo, true)
@@ -266,13 +266,15 @@ func (p *StpPort) PrxmMachineMain() {
 
 				if p.BpduGuard &&
 					p.AdminEdge {
-					if p.BPDUGuardTimer.count != 0 {
-						p.BPDUGuardTimer.count = p.BpduGuardInterval
-						for _, client := range GetAsicDPluginList() {
-							client.BPDUGuardDetected(p.IfIndex, true)
+					p.BpduGuardDetected(PrxmMachineModuleStr)
+					// port was err-disabled, the other machines have been
+					// notified, lets handle the port being disabled here
+					if !p.PortEnabled &&
+						p.EdgeDelayWhileTimer.count != MigrateTimeDefault {
+						rv := m.Machine.ProcessEvent(PrxmMachineModuleStr, PrxmEventEdgeDelayWhileNotEqualMigrateTimeAndNotPortEnabled, nil)
+						if rv != nil {
+							StpMachineLogger("ERROR", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("%s state[%s]event[%d]\n", rv, PrxmStateStrMap[m.Machine.Curr.CurrentState()], PrxmEventEdgeDelayWhileNotEqualMigrateTimeAndNotPortEnabled))
 						}
-					} else {
-						p.BPDUGuardTimer.count = p.BpduGuardInterval
 					}
 				} else {
 
@@ -368,6 +370,11 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 	bpdumsg := data.(RxBpduPdu)
 	bpduLayer := bpdumsg.pdu
 	flags := uint8(0)
+	// 14.4 the CIST information of a MST BPDU from within the region is
+	// treated as a RST BPDU
+	if mst, ok := bpduLayer.(*MstBpdu); ok {
+		bpduLayer = mst.CistRSTP()
+	}
 	StpMachineLogger("DEBUG", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("UpdtBPDUVersion: pbduType %#v", bpduLayer))
 	switch bpduLayer.(type) {
 	case *layers.RSTP:
@@ -376,7 +383,8 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 		// the BPDUType, but for completness going to add the check anyways
 		rstp := bpduLayer.(*layers.RSTP)
 		flags = uint8(rstp.Flags)
-		if rstp.ProtocolVersionId == layers.RSTPProtocolVersion &&
+		// 14.4 MST BPDUs (version 3 and above) are treated as RST BPDUs
+		if rstp.ProtocolVersionId >= layers.RSTPProtocolVersion &&
 			rstp.BPDUType == layers.BPDUTypeRSTP {
 			// Inform the Port Protocol Migration STate machine
 			// that we have received a RSTP packet when we were previously
@@ -402,6 +410,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 
 		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, false)
 		p.RcvdTc = StpGetBpduTopoChange(flags)
+		p.RcvdTcBridgeId = rstp.BridgeId
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
 
@@ -441,12 +450,29 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 
 			p.RcvdRSTP = true
 			validPdu = true
+		} else if pvst.ProtocolVersionId == layers.STPProtocolVersion &&
+			pvst.BPDUType == layers.BPDUTypeSTP {
+			// PVST+ Config BPDU, same as the STP Config BPDU
+			if p.MdelayWhiletimer.count == 0 {
+				if p.SendRSTP {
+					if p.PpmmMachineFsm != nil {
+						p.PpmmMachineFsm.PpmmEvents <- MachineEvent{
+							e:    PpmmEventSendRSTPAndRcvdSTP,
+							data: bpduLayer,
+							src:  PrxmMachineModuleStr}
+					}
+				}
+			}
+
+			p.RcvdSTP = true
+			validPdu = true
 		}
 
 		//StpMachineLogger("DEBUG", PrxmMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Received PVST packet flags", pvst.Flags))
 
 		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags))
 		p.RcvdTc = StpGetBpduTopoChange(flags)
+		p.RcvdTcBridgeId = pvst.BridgeId
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
 
@@ -490,6 +516,7 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 		StpMachineLogger("DEBUG", PrtMachineModuleStr, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Received STP packet %#v", stp))
 		defer p.NotifyRcvdTcRcvdTcnRcvdTcAck(p.RcvdTc, p.RcvdTcn, p.RcvdTcAck, StpGetBpduTopoChange(flags), false, StpGetBpduTopoChangeAck(flags))
 		p.RcvdTc = StpGetBpduTopoChange(flags)
+		p.RcvdTcBridgeId = stp.BridgeId
 		p.RcvdTcn = false
 		p.RcvdTcAck = StpGetBpduTopoChangeAck(flags)
 
@@ -528,9 +555,9 @@ func (prxm *PrxmMachine) UpdtBPDUVersion(data interface{}) bool {
 			p.RcvdTc = false
 			p.RcvdTcn = true
 			p.RcvdTcAck = false
-			if p.RcvdTc {
-				p.SetRxPortCounters(BPDURxTypeTopo)
-			}
+			// TCN carries no bridge identifier
+			p.RcvdTcBridgeId = BridgeId{}
+			p.SetRxPortCounters(BPDURxTypeTopo)
 
 		}
 	}
@@ -204,10 +204,8 @@ func (p *StpPort) PtmMachineMain() {
 				m.Machine.ProcessEvent(PtmMachineModuleStr, PtmEventTickEqualsTrue, nil)
 
 				// post state processing
-				if m.Machine.Curr.CurrentState() == PtmStateTick {
-					m.Machine.ProcessEvent(PtmMachineModuleStr, PtmEventUnconditionalFallthrough, nil)
+				m.ProcessPostStateProcessing()
 
-				}
 				// restart the timer
 				m.TickTimerStart()
 
@@ -216,6 +214,9 @@ func (p *StpPort) PtmMachineMain() {
 				if ok {
 					m.Machine.ProcessEvent(event.src, event.e, nil)
 
+					// post state processing
+					m.ProcessPostStateProcessing()
+
 					if event.responseChan != nil {
 						SendResponse(PtmMachineModuleStr, event.responseChan)
 					}
@@ -229,3 +230,11 @@ func (p *StpPort) PtmMachineMain() {
 		}
 	}(ptm)
 }
+
+// ProcessPostStateProcessing will return to the one second state after the
+// port timers have been decremented
+func (ptm *PtmMachine) ProcessPostStateProcessing() {
+	if ptm.Machine.Curr.CurrentState() == PtmStateTick {
+		ptm.Machine.ProcessEvent(PtmMachineModuleStr, PtmEventUnconditionalFallthrough, nil)
+	}
+}
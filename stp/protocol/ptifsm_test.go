@@ -1422,6 +1422,8 @@ func TestPtmBPDUGuardTimerExpired(t *testing.T) {
 	p.SelectedRole = PortRoleDesignatedPort
 	p.InfoIs = PortInfoStateReceived
 	p.BPDUGuardTimer.count = 1
+	p.ErrDisabled = true
+	p.ErrDisableReason = ErrDisableReasonBpduGuard
 	p.BpduGuard = true
 	p.AdminEdge = true
 	p.OperEdge = true
@@ -1444,5 +1446,10 @@ func TestPtmBPDUGuardTimerExpired(t *testing.T) {
 
 	<-wait
 
+	if p.ErrDisabled ||
+		p.ErrDisableReason != ErrDisableReasonNone {
+		t.Error("ERROR: port should have recovered from err-disable")
+	}
+
 	UsedForTestOnlyPtmTestTeardown(p, t)
 }
@@ -67,6 +67,7 @@ const (
 	PtxmEventNotSendRSTPAndNewInfoAndRootPortAndTxCountLessThanTxHoldCountAndHellWhenNotEqualZeroAndSelectedAndNotUpdtInfo
 	PtxmEventNotSendRSTPAndNewInfoAndDesignatedPortAndTxCountLessThanTxHoldCountAndHellWhenNotEqualZeroAndSelectedAndNotUpdtInfo
 	PtxmEventHelloWhenEqualsZeroAndSelectedAndNotUpdtInfo
+	PtxmEventMstiNewInfo
 )
 
 // LacpRxMachine holds FSM and current State
@@ -174,6 +175,15 @@ func (ptxm *PtxmMachine) PtxmMachineTransmitRSTP(m fsm.Machine, data interface{}
 	return PtxmStateTransmitRSTP
 }
 
+// PtxmMachineMstiNewInfo a MSTI port has new info, the CIST port carries
+// it as a M-record in the next MST BPDU transmitted
+func (ptxm *PtxmMachine) PtxmMachineMstiNewInfo(m fsm.Machine, data interface{}) fsm.State {
+	p := ptxm.p
+	p.NewInfo = true
+
+	return PtxmStateIdle
+}
+
 func (ptxm *PtxmMachine) PtxmMachineTransmitTCN(m fsm.Machine, data interface{}) fsm.State {
 	p := ptxm.p
 
@@ -232,6 +242,15 @@ func PtxmMachineFSMBuild(p *StpPort) *PtxmMachine {
 	// sendRSTP && NewInfo && Designated Port && TxCount < TxHoldCound && HelloWhen != 0 && Selected && !UpdtInfo -> TRANSMIT RSTP
 	rules.AddRule(PtxmStateIdle, PtxmEventNotSendRSTPAndNewInfoAndDesignatedPortAndTxCountLessThanTxHoldCountAndHellWhenNotEqualZeroAndSelectedAndNotUpdtInfo, ptxm.PtxmMachineTransmitConfig)
 
+	// MSTI newInfo -> IDLE, may arrive in any state once started as it
+	// is sent from the MSTI port machines
+	rules.AddRule(PtxmStateTransmitInit, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+	rules.AddRule(PtxmStateTransmitConfig, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+	rules.AddRule(PtxmStateTransmitTCN, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+	rules.AddRule(PtxmStateTransmitPeriodic, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+	rules.AddRule(PtxmStateTransmitRSTP, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+	rules.AddRule(PtxmStateIdle, PtxmEventMstiNewInfo, ptxm.PtxmMachineMstiNewInfo)
+
 	// Create a new FSM and apply the rules
 	ptxm.Apply(&rules)
 
@@ -0,0 +1,211 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// pvst.go
+// Rapid-PVST+ interoperability
+//
+// Native VLAN: VLAN 1 is the VLAN which interacts with the IEEE Common
+// Spanning Tree, the VLAN 1 bridge sends untagged IEEE BPDUs and processes
+// the IEEE BPDUs received when no CST bridge exists.  SSTP BPDUs for the
+// native VLAN (PVID) of a port are sent untagged, all others are tagged.
+// SSTP BPDUs received for VLAN 1 are only used for the consistency checks as
+// the VLAN 1 information is carried by the IEEE BPDU.
+//
+// PVID Inconsistent: the SSTP BPDU carries the VLAN it originated on, when
+// this does not match the VLAN on which it was received the port is blocked
+// in both VLANs.
+//
+// Type Inconsistent: an SSTP BPDU received on an access port, the port is
+// blocked in its access VLAN.
+//
+// The port remains blocked until inconsistent BPDUs have not been received
+// for max age.
+package stp
+
+import (
+	"fmt"
+	"models/events"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const PvstCstVlan = 1
+
+type PvstInconsistency int
+
+const (
+	PvstInconsistencyNone PvstInconsistency = iota
+	PvstInconsistencyPvid
+	PvstInconsistencyType
+)
+
+var PvstInconsistencyStrMap = map[PvstInconsistency]string{
+	PvstInconsistencyNone: "None",
+	PvstInconsistencyPvid: "PVID Inconsistent",
+	PvstInconsistencyType: "Type Inconsistent",
+}
+
+// port untagged vlan, as learned from the vlan membership
+var StpPortPvidMap map[int32]uint16
+
+// port tagged vlans, as learned from the vlan membership
+var StpPortTaggedVlanMap map[int32]map[uint16]bool
+
+// vlan membership is updated by the asicd notifications and read by the
+// rx and tx of every port
+var stpPortVlanMutex *sync.Mutex
+
+// StpVlanMembershipSet will update the vlan membership of the ports, a
+// delete will remove the vlan from all ports
+func StpVlanMembershipSet(vlan uint16, tagged []int32, untagged []int32, del bool) {
+	stpPortVlanMutex.Lock()
+	defer stpPortVlanMutex.Unlock()
+
+	for pId, vlans := range StpPortTaggedVlanMap {
+		delete(vlans, vlan)
+		if len(vlans) == 0 {
+			delete(StpPortTaggedVlanMap, pId)
+		}
+	}
+	for pId, pvid := range StpPortPvidMap {
+		if pvid == vlan {
+			delete(StpPortPvidMap, pId)
+		}
+	}
+	if del {
+		return
+	}
+	for _, pId := range tagged {
+		if _, ok := StpPortTaggedVlanMap[pId]; !ok {
+			StpPortTaggedVlanMap[pId] = make(map[uint16]bool)
+		}
+		StpPortTaggedVlanMap[pId][vlan] = true
+	}
+	for _, pId := range untagged {
+		StpPortPvidMap[pId] = vlan
+	}
+}
+
+// StpPortPvidGet returns the native vlan of the port, defaults to VLAN 1
+func StpPortPvidGet(pId int32) uint16 {
+	stpPortVlanMutex.Lock()
+	defer stpPortVlanMutex.Unlock()
+
+	if pvid, ok := StpPortPvidMap[pId]; ok {
+		return pvid
+	}
+	return PvstCstVlan
+}
+
+// IsStpPortAccess returns true when the port is known to only be an
+// untagged member of a vlan
+func IsStpPortAccess(pId int32) bool {
+	stpPortVlanMutex.Lock()
+	defer stpPortVlanMutex.Unlock()
+
+	_, untagged := StpPortPvidMap[pId]
+	return untagged && len(StpPortTaggedVlanMap[pId]) == 0
+}
+
+// IsPvstTxUntagged returns true when SSTP BPDUs for the bridge vlan should
+// be sent untagged on the port
+func (p *StpPort) IsPvstTxUntagged() bool {
+	return p.b.Vlan == StpPortPvidGet(p.IfIndex)
+}
+
+// PvstRxCheck will validate the received SSTP BPDU against the vlan it was
+// received on and the port type, the vlan on which the BPDU should be
+// processed is returned, false is returned when the BPDU is to be dropped
+func PvstRxCheck(pId int32, packet gopacket.Packet) (uint16, bool) {
+	pvstLayer := packet.Layer(layers.LayerTypePVST)
+	if pvstLayer == nil {
+		return DEFAULT_STP_BRIDGE_VLAN, true
+	}
+	pvst := pvstLayer.(*layers.PVST)
+	origvlan := pvst.OriginatingVlan.OrigVlan
+
+	rxvlan := StpPortPvidGet(pId)
+	if dot1qLayer := packet.Layer(layers.LayerTypeDot1Q); dot1qLayer != nil {
+		rxvlan = dot1qLayer.(*layers.Dot1Q).VLANIdentifier
+	}
+
+	if IsStpPortAccess(pId) {
+		StpPvstInconsistencySet(pId, rxvlan, PvstInconsistencyType)
+		return rxvlan, false
+	}
+	if origvlan != rxvlan {
+		StpPvstInconsistencySet(pId, rxvlan, PvstInconsistencyPvid)
+		StpPvstInconsistencySet(pId, origvlan, PvstInconsistencyPvid)
+		return origvlan, false
+	}
+	// vlan 1 info is carried by the IEEE BPDU
+	if origvlan == PvstCstVlan &&
+		!IsStpCstBridgePresent() {
+		return origvlan, false
+	}
+	return origvlan, true
+}
+
+// IsStpCstBridgePresent returns whether the IEEE CST bridge has been created,
+// when it has not the VLAN 1 bridge processes the IEEE BPDUs
+func IsStpCstBridgePresent() bool {
+	var b *Bridge
+	return StpFindBridgeByIfIndex(DEFAULT_STP_BRIDGE_VLAN, &b)
+}
+
+// StpPvstInconsistencySet will block the port in the vlan
+func StpPvstInconsistencySet(pId int32, vlan uint16, reason PvstInconsistency) {
+	var p *StpPort
+	if StpFindPortByIfIndex(pId, int32(vlan), &p) {
+		// keep the port blocked while inconsistent BPDUs are received
+		p.PvstInconsistentWhileTimer.count = int32(p.b.RootTimes.MaxAge)
+		if p.PvstInconsistency != reason {
+			p.SetPvstInconsistent(RxModuleStr, reason)
+			p.GuardReselect(RxModuleStr)
+		}
+	}
+}
+
+func (p *StpPort) SetPvstInconsistent(src string, reason PvstInconsistency) {
+	if p.PvstInconsistency != reason {
+		p.PvstInconsistency = reason
+		if reason != PvstInconsistencyNone {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, fmt.Sprintf("Rapid-PVST+ %s BPDU received, port blocked", PvstInconsistencyStrMap[reason]))
+			StpPublishPortEvent(p, events.StpdEventPortPvstInconsistent)
+		} else {
+			StpMachineLogger("INFO", src, p.IfIndex, p.BrgIfIndex, "Rapid-PVST+ port consistent")
+			StpPublishPortEvent(p, events.StpdEventPortPvstConsistent)
+		}
+	}
+}
+
+// PvstInconsistentWhileExpired is called when inconsistent BPDUs have not
+// been received for max age
+func (p *StpPort) PvstInconsistentWhileExpired(src string) {
+	if p.PvstInconsistency != PvstInconsistencyNone {
+		p.SetPvstInconsistent(src, PvstInconsistencyNone)
+		p.GuardReselect(src)
+	}
+}
@@ -0,0 +1,394 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// pvst_test.go
+package stp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	PvstTestPort  = 1
+	PvstTestVlanA = 10
+	PvstTestVlanB = 20
+)
+
+// Frames as sent by a Cisco Catalyst running rapid-pvst on an 802.1Q trunk
+// with native VLAN 1, bridge 32768 00:1e:bd:4a:52:00 port 128.1 designated
+// and forwarding
+
+// VLAN 1 is carried by the untagged IEEE BPDU, padded to the minimum frame size
+var PvstTestCiscoIeeeBpdu = []byte{
+	0x01, 0x80, 0xc2, 0x00, 0x00, 0x00, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x01, 0x00, 0x27,
+	0x42, 0x42, 0x03,
+	0x00, 0x00, 0x02, 0x02, 0x3c,
+	0x80, 0x01, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x80, 0x01, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x80, 0x01, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x0f, 0x00,
+	0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+// the native VLAN SSTP BPDU is sent untagged
+var PvstTestCiscoNativeSstp = []byte{
+	0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcd, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x01, 0x00, 0x32,
+	0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x01, 0x0b,
+	0x00, 0x00, 0x02, 0x02, 0x3c,
+	0x80, 0x01, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x80, 0x01, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x80, 0x01, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x0f, 0x00,
+	0x00,
+	0x00, 0x00, 0x00, 0x02, 0x00, 0x01,
+}
+
+// all other vlans are tagged
+var PvstTestCiscoTaggedSstp = []byte{
+	0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcd, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x01, 0x81, 0x00,
+	0xe0, 0x0a, 0x00, 0x32,
+	0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x01, 0x0b,
+	0x00, 0x00, 0x02, 0x02, 0x3c,
+	0x80, 0x0a, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+	0x80, 0x0a, 0x00, 0x1e, 0xbd, 0x4a, 0x52, 0x00,
+	0x80, 0x01, 0x00, 0x00, 0x14, 0x00, 0x02, 0x00, 0x0f, 0x00,
+	0x00,
+	0x00, 0x00, 0x00, 0x02, 0x00, 0x0a,
+}
+
+func UsedForTestOnlyPvstCiscoPacket(frame []byte) gopacket.Packet {
+	return gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.Default)
+}
+
+// UsedForTestOnlyPvstPacket builds a Rapid-PVST+ SSTP BPDU as a Cisco switch
+// would send it on the wire, txvlan of 0 will send the frame untagged
+func UsedForTestOnlyPvstPacket(txvlan, origvlan uint16) gopacket.Packet {
+	eth := layers.Ethernet{
+		SrcMAC:       []byte{0x00, 0x1e, 0xbd, 0x00, 0x00, 0x01},
+		DstMAC:       layers.BpduPVSTDMAC,
+		EthernetType: layers.EthernetTypeDot1Q,
+	}
+	vlan := layers.Dot1Q{
+		Priority:       PVST_VLAN_PRIORITY,
+		VLANIdentifier: txvlan,
+		Type:           layers.EthernetType(layers.PVSTProtocolLength + 3 + 5),
+	}
+	llc := layers.LLC{
+		DSAP:    0xAA,
+		SSAP:    0xAA,
+		Control: 0x03,
+	}
+	snap := layers.SNAP{
+		OrganizationalCode: []byte{0x00, 0x00, 0x0C},
+		Type:               0x010b,
+	}
+	pvst := layers.PVST{
+		ProtocolId:        layers.RSTPProtocolIdentifier,
+		ProtocolVersionId: layers.PVSTProtocolVersion,
+		BPDUType:          layers.BPDUTypeRSTP,
+		RootId:            [8]byte{0x80, byte(origvlan), 0x00, 0x1e, 0xbd, 0x00, 0x00, 0x01},
+		BridgeId:          [8]byte{0x80, byte(origvlan), 0x00, 0x1e, 0xbd, 0x00, 0x00, 0x01},
+		PortId:            0x8001,
+		MaxAge:            20 << 8,
+		HelloTime:         2 << 8,
+		FwdDelay:          15 << 8,
+		OriginatingVlan: layers.STPOriginatingVlanTlv{
+			Type:     0,
+			Length:   2,
+			OrigVlan: origvlan,
+		},
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	if txvlan == 0 {
+		eth.EthernetType = layers.EthernetTypeLLC
+		eth.Length = uint16(layers.PVSTProtocolLength + 3 + 5)
+		gopacket.SerializeLayers(buf, opts, &eth, &llc, &snap, &pvst)
+	} else {
+		gopacket.SerializeLayers(buf, opts, &eth, &vlan, &llc, &snap, &pvst)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func UsedForTestOnlyPvstTestSetup(t *testing.T) (brgcfgs []*StpBridgeConfig, pcfgs []*StpPortConfig) {
+	for _, vlan := range []uint16{PvstTestVlanA, PvstTestVlanB} {
+		brgcfg := StpBridgeConfigSetup()
+		brgcfg.Vlan = vlan
+		if err := StpBridgeCreate(brgcfg); err != nil {
+			t.Error("ERROR valid bridge creation failed", err)
+		}
+		brgcfgs = append(brgcfgs, brgcfg)
+
+		pcfg, _ := StpPortConfigSetup(false, false)
+		pcfg.IfIndex = PvstTestPort
+		pcfg.BrgIfIndex = int32(vlan)
+		if err := StpPortCreate(pcfg); err != nil {
+			t.Error("ERROR valid stp port creation failed", err)
+		}
+		pcfgs = append(pcfgs, pcfg)
+	}
+	// trunk port, native vlan 1
+	StpVlanMembershipSet(PvstCstVlan, nil, []int32{PvstTestPort}, false)
+	StpVlanMembershipSet(PvstTestVlanA, []int32{PvstTestPort}, nil, false)
+	StpVlanMembershipSet(PvstTestVlanB, []int32{PvstTestPort}, nil, false)
+	return brgcfgs, pcfgs
+}
+
+func UsedForTestOnlyPvstTestTeardown(brgcfgs []*StpBridgeConfig, pcfgs []*StpPortConfig, t *testing.T) {
+	for _, vlan := range []uint16{PvstCstVlan, PvstTestVlanA, PvstTestVlanB} {
+		StpVlanMembershipSet(vlan, nil, nil, true)
+	}
+	for _, pcfg := range pcfgs {
+		if err := StpPortDelete(pcfg); err != nil {
+			t.Error("ERROR valid stp port deletion failed", err)
+		}
+	}
+	for _, brgcfg := range brgcfgs {
+		if err := StpBridgeDelete(brgcfg); err != nil {
+			t.Error("ERROR valid bridge deletion failed", err)
+		}
+	}
+	if len(StpPortPvidMap) != 0 ||
+		len(StpPortTaggedVlanMap) != 0 {
+		t.Error("ERROR vlan membership not cleaned up", StpPortPvidMap, StpPortTaggedVlanMap)
+	}
+}
+
+func TestPvstRxConsistent(t *testing.T) {
+	defer MemoryCheck(t)
+	brgcfgs, pcfgs := UsedForTestOnlyPvstTestSetup(t)
+
+	vlan, valid := PvstRxCheck(PvstTestPort, UsedForTestOnlyPvstPacket(PvstTestVlanA, PvstTestVlanA))
+	if !valid || vlan != PvstTestVlanA {
+		t.Error("ERROR consistent SSTP BPDU not accepted", vlan, valid)
+	}
+
+	var p *StpPort
+	if StpFindPortByIfIndex(PvstTestPort, PvstTestVlanA, &p) &&
+		p.PvstInconsistency != PvstInconsistencyNone {
+		t.Error("ERROR port marked inconsistent", PvstInconsistencyStrMap[p.PvstInconsistency])
+	}
+
+	// only the native vlan is sent untagged
+	if p == nil || p.IsPvstTxUntagged() {
+		t.Error("ERROR tagged vlan SSTP BPDU should be sent tagged")
+	}
+
+	UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+}
+
+func TestPvstRxPvidInconsistent(t *testing.T) {
+	defer MemoryCheck(t)
+	brgcfgs, pcfgs := UsedForTestOnlyPvstTestSetup(t)
+
+	// BPDU for vlan B received on vlan A
+	_, valid := PvstRxCheck(PvstTestPort, UsedForTestOnlyPvstPacket(PvstTestVlanA, PvstTestVlanB))
+	if valid {
+		t.Error("ERROR PVID inconsistent SSTP BPDU accepted")
+	}
+
+	var pa, pb *StpPort
+	StpFindPortByIfIndex(PvstTestPort, PvstTestVlanA, &pa)
+	StpFindPortByIfIndex(PvstTestPort, PvstTestVlanB, &pb)
+	for _, p := range []*StpPort{pa, pb} {
+		if p == nil {
+			t.Error("ERROR unable to find bridge port")
+			continue
+		}
+		if p.PvstInconsistency != PvstInconsistencyPvid {
+			t.Error("ERROR port not marked PVID inconsistent", p.BrgIfIndex, PvstInconsistencyStrMap[p.PvstInconsistency])
+		}
+		if p.PvstInconsistentWhileTimer.count != int32(p.b.RootTimes.MaxAge) {
+			t.Error("ERROR inconsistent while timer not started", p.PvstInconsistentWhileTimer.count)
+		}
+		// inconsistent BPDUs no longer received
+		p.PvstInconsistentWhileExpired("TEST")
+		if p.PvstInconsistency != PvstInconsistencyNone {
+			t.Error("ERROR port not recovered from PVID inconsistency", p.BrgIfIndex)
+		}
+	}
+
+	UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+}
+
+func TestPvstRxTypeInconsistent(t *testing.T) {
+	defer MemoryCheck(t)
+	brgcfgs, pcfgs := UsedForTestOnlyPvstTestSetup(t)
+
+	// make the port an access port in vlan A
+	StpVlanMembershipSet(PvstCstVlan, nil, nil, true)
+	StpVlanMembershipSet(PvstTestVlanB, nil, nil, true)
+	StpVlanMembershipSet(PvstTestVlanA, nil, []int32{PvstTestPort}, false)
+	if !IsStpPortAccess(PvstTestPort) {
+		t.Error("ERROR port should be an access port")
+	}
+
+	_, valid := PvstRxCheck(PvstTestPort, UsedForTestOnlyPvstPacket(0, PvstTestVlanA))
+	if valid {
+		t.Error("ERROR SSTP BPDU accepted on access port")
+	}
+
+	var p *StpPort
+	if !StpFindPortByIfIndex(PvstTestPort, PvstTestVlanA, &p) {
+		t.Error("ERROR unable to find bridge port")
+	} else {
+		if p.PvstInconsistency != PvstInconsistencyType {
+			t.Error("ERROR port not marked type inconsistent", PvstInconsistencyStrMap[p.PvstInconsistency])
+		}
+		if !p.IsPvstTxUntagged() {
+			t.Error("ERROR native vlan SSTP BPDU should be sent untagged")
+		}
+		p.PvstInconsistentWhileExpired("TEST")
+	}
+
+	UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+}
+
+// UsedForTestOnlyPvstBridgeCreate adds a bridge with the pvst test port to
+// the setup
+func UsedForTestOnlyPvstBridgeCreate(vlan uint16, brgcfgs []*StpBridgeConfig, pcfgs []*StpPortConfig, t *testing.T) ([]*StpBridgeConfig, []*StpPortConfig) {
+	brgcfg := StpBridgeConfigSetup()
+	brgcfg.Vlan = vlan
+	if err := StpBridgeCreate(brgcfg); err != nil {
+		t.Error("ERROR valid bridge creation failed", err)
+	}
+	pcfg, _ := StpPortConfigSetup(false, false)
+	pcfg.IfIndex = PvstTestPort
+	pcfg.BrgIfIndex = int32(vlan)
+	if err := StpPortCreate(pcfg); err != nil {
+		t.Error("ERROR valid stp port creation failed", err)
+	}
+	return append(brgcfgs, brgcfg), append(pcfgs, pcfg)
+}
+
+func TestPvstRxCiscoCstVlan(t *testing.T) {
+	defer MemoryCheck(t)
+	brgcfgs, pcfgs := UsedForTestOnlyPvstTestSetup(t)
+	brgcfgs, pcfgs = UsedForTestOnlyPvstBridgeCreate(PvstCstVlan, brgcfgs, pcfgs, t)
+
+	// no CST bridge, the IEEE BPDU belongs to VLAN 1
+	ieee := UsedForTestOnlyPvstCiscoPacket(PvstTestCiscoIeeeBpdu)
+	if p := GetBrgPort(PvstTestPort, PvstCstVlan, ieee); p == nil ||
+		p.BrgIfIndex != PvstCstVlan {
+		t.Error("ERROR IEEE BPDU not given to the VLAN 1 bridge port")
+	} else if ptype := ValidateBPDUFrame(p, ieee); ptype != BPDURxTypeRSTP {
+		t.Error("ERROR IEEE BPDU not validated as RSTP", ptype)
+	}
+	if p := GetBrgPort(PvstTestPort, PvstTestVlanA, ieee); p != nil {
+		t.Error("ERROR IEEE BPDU given to vlan bridge port", p.BrgIfIndex)
+	}
+
+	// VLAN 1 info is taken from the IEEE BPDU, the native vlan SSTP BPDU is
+	// only checked for consistency
+	native := UsedForTestOnlyPvstCiscoPacket(PvstTestCiscoNativeSstp)
+	if p := GetBrgPort(PvstTestPort, PvstCstVlan, native); p != nil {
+		t.Error("ERROR native vlan SSTP BPDU given to the VLAN 1 bridge port")
+	}
+	var p *StpPort
+	if StpFindPortByIfIndex(PvstTestPort, PvstCstVlan, &p) &&
+		p.PvstInconsistency != PvstInconsistencyNone {
+		t.Error("ERROR native vlan SSTP BPDU marked port inconsistent", PvstInconsistencyStrMap[p.PvstInconsistency])
+	}
+
+	tagged := UsedForTestOnlyPvstCiscoPacket(PvstTestCiscoTaggedSstp)
+	if p := GetBrgPort(PvstTestPort, PvstTestVlanA, tagged); p == nil ||
+		p.BrgIfIndex != PvstTestVlanA {
+		t.Error("ERROR tagged SSTP BPDU not given to the vlan bridge port")
+	} else if ptype := ValidateBPDUFrame(p, tagged); ptype != BPDURxTypePVST {
+		t.Error("ERROR tagged SSTP BPDU not validated as PVST", ptype)
+	}
+
+	// once the CST bridge exists it owns the IEEE BPDU
+	brgcfgs, pcfgs = UsedForTestOnlyPvstBridgeCreate(DEFAULT_STP_BRIDGE_VLAN, brgcfgs, pcfgs, t)
+	if p := GetBrgPort(PvstTestPort, DEFAULT_STP_BRIDGE_VLAN, ieee); p == nil ||
+		p.BrgIfIndex != DEFAULT_STP_BRIDGE_VLAN {
+		t.Error("ERROR IEEE BPDU not given to the CST bridge port")
+	}
+	if p := GetBrgPort(PvstTestPort, PvstCstVlan, ieee); p != nil {
+		t.Error("ERROR IEEE BPDU given to the VLAN 1 bridge port while CST bridge exists")
+	}
+
+	UsedForTestOnlyPvstTestTeardown(brgcfgs, pcfgs, t)
+}
+
+func TestPvstTxCiscoEncoding(t *testing.T) {
+	// trunk port, native vlan 1
+	StpVlanMembershipSet(PvstCstVlan, nil, []int32{PvstTestPort}, false)
+	StpVlanMembershipSet(PvstTestVlanA, []int32{PvstTestPort}, nil, false)
+
+	for _, vlan := range []uint16{PvstCstVlan, PvstTestVlanA} {
+		handle := &UsedForTestOnlyMstpTxHandle{}
+		p := &StpPort{
+			b:                       &Bridge{Vlan: vlan},
+			IfIndex:                 PvstTestPort,
+			usedForTestOnlyTxHandle: handle,
+		}
+		p.TxPVST()
+		if len(handle.frames) != 1 {
+			t.Error("ERROR SSTP BPDU not sent", vlan, len(handle.frames))
+			continue
+		}
+		frame := handle.frames[0]
+		packet := UsedForTestOnlyPvstCiscoPacket(frame)
+
+		// length, LLC and SNAP headers and the originating vlan TLV must
+		// match what a Cisco switch sends
+		cisco := PvstTestCiscoTaggedSstp
+		hdr := 16
+		if vlan == PvstCstVlan {
+			cisco = PvstTestCiscoNativeSstp
+			hdr = 12
+			if packet.Layer(layers.LayerTypeDot1Q) != nil {
+				t.Error("ERROR native vlan SSTP BPDU sent tagged")
+			}
+		} else if dot1qLayer := packet.Layer(layers.LayerTypeDot1Q); dot1qLayer == nil ||
+			dot1qLayer.(*layers.Dot1Q).VLANIdentifier != vlan {
+			t.Error("ERROR SSTP BPDU not sent tagged in its vlan", vlan)
+		}
+		if len(frame) != len(cisco) {
+			t.Error("ERROR SSTP BPDU length does not match Cisco", vlan, len(frame), len(cisco))
+			continue
+		}
+		if !bytes.Equal(frame[hdr:hdr+10], cisco[hdr:hdr+10]) {
+			t.Errorf("ERROR SSTP BPDU headers do not match Cisco vlan %d % x", vlan, frame[hdr:hdr+10])
+		}
+		tlv := len(frame) - 6
+		if !bytes.Equal(frame[tlv:], []byte{0x00, 0x00, 0x00, 0x02, byte(vlan >> 8), byte(vlan)}) {
+			t.Errorf("ERROR SSTP BPDU originating vlan TLV incorrect vlan %d % x", vlan, frame[tlv:])
+		}
+	}
+
+	for _, vlan := range []uint16{PvstCstVlan, PvstTestVlanA} {
+		StpVlanMembershipSet(vlan, nil, nil, true)
+	}
+}
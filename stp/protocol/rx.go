@@ -118,10 +118,15 @@ func GetBrgPort(pId int32, bId int32, packet gopacket.Packet) *StpPort {
 		if IsValidStpPort(pId) {
 			vlan := uint16(DEFAULT_STP_BRIDGE_VLAN)
 			if pvstLayer != nil {
-				pvst := pvstLayer.(*layers.PVST)
-				if pvst.ProtocolVersionId == layers.PVSTProtocolVersion {
-					vlan = pvst.OriginatingVlan.OrigVlan
+				// both the PVST+ Config and Rapid-PVST+ BPDUs carry the
+				// originating vlan
+				var valid bool
+				if vlan, valid = PvstRxCheck(pId, packet); !valid {
+					return p
 				}
+			} else if !IsStpCstBridgePresent() {
+				// Rapid-PVST+ IEEE BPDUs belong to VLAN 1
+				vlan = PvstCstVlan
 			}
 			for _, b := range BridgeListTable {
 				if b.BrgIfIndex == bId &&
@@ -204,7 +209,8 @@ func ValidateBPDUFrame(p *StpPort, packet gopacket.Packet) (bpduType BPDURxType)
 		if len(pvst.Contents) >= layers.BPDUTopologyLength &&
 			pvst.ProtocolId == layers.RSTPProtocolIdentifier {
 			// condition 9.3.4 (a)
-			if pvst.BPDUType == layers.BPDUTypePVST &&
+			if (pvst.BPDUType == layers.BPDUTypePVST ||
+				pvst.BPDUType == layers.BPDUTypeSTP) &&
 				len(pvst.Contents) >= layers.PVSTProtocolLength {
 				bpduType = BPDURxTypePVST
 			} else {
@@ -226,10 +232,24 @@ func ProcessBpduFrame(p *StpPort, ptype BPDURxType, packet gopacket.Packet) {
 	//fmt.Printf("ProcessBpduFrame on port/bridge\n", pId, bId)
 	//fmt.Printf("ProcessBpduFrame %T\n", bpduLayer)
 	// lets find the port via the info in the packet
+	if !p.BpduFilterRx() {
+		return
+	}
 	p.RcvdBPDU = true
+	// MSTP region detection and dispatch of the MSTI information
+	var mst *MstBpdu
+	if p.b.IsMstpCist() {
+		ptype, mst = ProcessMstBpduFrame(p, ptype, packet)
+	}
 	//fmt.Println("Sending rx message to Port Rcvd State Machine", p.IfIndex, p.BrgIfIndex)
 	if p.PrxmMachineFsm != nil {
-		if pvstLayer == nil {
+		if mst != nil {
+			// CIST information from within the region
+			p.PrxmMachineFsm.PrxmRxBpduPkt <- RxBpduPdu{
+				pdu:   mst, // this is a pointer
+				ptype: ptype,
+				src:   RxModuleStr}
+		} else if pvstLayer == nil {
 			p.PrxmMachineFsm.PrxmRxBpduPkt <- RxBpduPdu{
 				pdu:   bpduLayer, // this is a pointer
 				ptype: ptype,
@@ -0,0 +1,1020 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// simulator_test.go
+package stp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+	asicdmock "utils/asicdClient/mock"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// The simulator runs N independent stp bridges within the same process.
+// Each bridge is its own vlan bridge instance with a unique bridge address,
+// bridge ports are connected to each other via simulated point to point
+// links which deliver the transmitted BPDU directly to the receive path of
+// the port on the far end of the link.
+//
+// The topology is created up front, links which should only come up later
+// in a scenario (i.e. a new root joining the network) can be created down
+// and restored when needed.
+//
+// The bridge and port tables are global and keyed by vlan, so each simulated
+// bridge must be a different vlan bridge. Bridges are Rapid-PVST+ vlan
+// bridges unless created as the single default vlan bridge which sends IEEE
+// encapsulated BPDUs. As the vlans differ across a link the received BPDU is
+// handed straight to the port of the far end bridge rather than demuxed by
+// vlan via GetBrgPort.
+//
+// Time is simulated, the port tick timers are not started and each tick is
+// driven through the Port Timers State Machine of every port.
+//
+//       bridge 1 --- bridge 2 --- bridge 4 (joins later as the new root)
+//           \          /
+//            bridge 3
+
+const StpSimVlanBase = 100
+const StpSimRxQueueSize = 64
+
+// max ticks to wait for the network to converge
+const StpSimMaxTicks = 30
+
+// time to let the state machines settle after a tick
+const StpSimSettleTime = time.Millisecond * 100
+const StpSimPollTime = time.Millisecond * 5
+
+// times used by every simulated bridge
+const StpSimHelloTime = 1
+const StpSimMaxAge = 10
+const StpSimForwardDelay = 6
+
+// RSTP should always converge faster than the 802.1D listening and
+// learning states would allow
+const StpSimMaxConvergeTicks = 2*StpSimForwardDelay - 1
+
+func stpSimBrgIfIndex(num uint8) int32 {
+	return StpSimVlanBase + int32(num)
+}
+
+func stpSimBridgeAddr(num uint8) [6]uint8 {
+	return [6]uint8{0x00, 0x00, 0x00, 0x00, num, 0x01}
+}
+
+func stpSimPortIfIndex(num uint8, port uint8) int32 {
+	return int32(num)*10 + int32(port)
+}
+
+// StpSimAsicdMock reports the link status of the simulated links
+type StpSimAsicdMock struct {
+	asicdmock.MockAsicdClientMgr
+	mutex      *sync.Mutex
+	linkStatus map[int32]bool
+}
+
+func NewStpSimAsicdMock() *StpSimAsicdMock {
+	return &StpSimAsicdMock{
+		mutex:      &sync.Mutex{},
+		linkStatus: make(map[int32]bool),
+	}
+}
+
+func (m *StpSimAsicdMock) GetPortLinkStatus(port int32) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.linkStatus[port]
+}
+
+func (m *StpSimAsicdMock) linkStatusSet(port int32, up bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.linkStatus[port] = up
+}
+
+// StpSimTxStats counts the BPDUs transmitted by a port
+type StpSimTxStats struct {
+	// Config, RST and TCN BPDUs regardless of encapsulation
+	Config int
+	Rstp   int
+	Tcn    int
+	// SSTP encapsulated BPDUs
+	Pvst int
+	// BPDUs with the TC Ack flag set
+	TcAck int
+}
+
+func (st *StpSimTxStats) record(packet gopacket.Packet) {
+	var flags uint8
+	if pvstLayer := packet.Layer(layers.LayerTypePVST); pvstLayer != nil {
+		pvst := pvstLayer.(*layers.PVST)
+		st.Pvst++
+		if pvst.ProtocolVersionId == layers.STPProtocolVersion {
+			st.Config++
+		} else {
+			st.Rstp++
+		}
+		flags = uint8(pvst.Flags)
+	} else {
+		switch bpdu := packet.Layer(layers.LayerTypeBPDU).(type) {
+		case *layers.RSTP:
+			st.Rstp++
+			flags = uint8(bpdu.Flags)
+		case *layers.STP:
+			st.Config++
+			flags = uint8(bpdu.Flags)
+		case *layers.BPDUTopology:
+			st.Tcn++
+		}
+	}
+	if StpGetBpduTopoChangeAck(flags) {
+		st.TcAck++
+	}
+}
+
+type StpSimBridgeConfig struct {
+	Num      uint8
+	Priority uint16
+	// 802.1D-1998 STP only bridge
+	Legacy bool
+	// default vlan bridge, IEEE BPDUs
+	DefaultVlan bool
+}
+
+type StpSimLinkConfig struct {
+	A    uint8
+	B    uint8
+	Down bool
+}
+
+// StpSimBridge is a single simulated bridge
+type StpSimBridge struct {
+	num        uint8
+	brgifindex int32
+	cfg        *StpBridgeConfig
+	ports      []*StpSimPort
+}
+
+// StpSimPort is one end of a simulated link
+type StpSimPort struct {
+	num  uint8
+	cfg  *StpPortConfig
+	rx   chan gopacket.Packet
+	link *StpSimLink
+	peer *StpSimPort
+	// protected by the link mutex
+	tx StpSimTxStats
+	// frames transmitted are lost, protected by the link mutex
+	txCut bool
+}
+
+// StpSimLink is a point to point link between bridge a and b
+type StpSimLink struct {
+	mutex *sync.Mutex
+	up    bool
+	a     *StpSimPort
+	b     *StpSimPort
+}
+
+type StpSimulator struct {
+	asicd   *StpSimAsicdMock
+	bridges []*StpSimBridge
+	links   []*StpSimLink
+	// simulated time
+	ticks int
+}
+
+// WritePacketData will deliver the BPDU to the far end of the link, as
+// with a real link the frame is lost if the link is down or the far end
+// is not keeping up
+func (sp *StpSimPort) WritePacketData(data []byte) error {
+	sp.link.mutex.Lock()
+	defer sp.link.mutex.Unlock()
+
+	if !sp.link.up ||
+		sp.txCut {
+		return nil
+	}
+	packet := gopacket.NewPacket(data, layers.LinkTypeEthernet, gopacket.Default)
+	sp.tx.record(packet)
+	select {
+	case sp.peer.rx <- packet:
+	default:
+		StpLogger("ERROR", fmt.Sprintf("SIM: rx queue full dropping packet on port %d", sp.peer.cfg.IfIndex))
+	}
+	return nil
+}
+
+// rxMain will process the packets received on the simulated link
+func (sp *StpSimPort) rxMain() {
+	go func(ifindex int32, brgifindex int32, rx chan gopacket.Packet) {
+		for packet := range rx {
+			var p *StpPort
+			if StpFindPortByIfIndex(ifindex, brgifindex, &p) {
+				ptype := ValidateBPDUFrame(p, packet)
+				if ptype != BPDURxTypeUnknown {
+					ProcessBpduFrame(p, ptype, packet)
+				}
+			}
+		}
+	}(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, sp.rx)
+}
+
+// NewStpSimulator will create the bridges and connect them via the links
+// provided, links which are not down are brought up once all bridges and
+// ports have been created
+func NewStpSimulator(bridges []StpSimBridgeConfig, links []StpSimLinkConfig, t *testing.T) *StpSimulator {
+	s := &StpSimulator{
+		asicd:   NewStpSimAsicdMock(),
+		bridges: make([]*StpSimBridge, 0),
+		links:   make([]*StpSimLink, 0),
+	}
+
+	UsedForTestOnlyDeleteAllAsicDPlugins()
+	SetAsicDPlugin(s.asicd)
+	usedForTestOnlyTickTimerExternal = true
+
+	for _, bc := range bridges {
+		addr := stpSimBridgeAddr(bc.Num)
+		forceversion := int32(StpForceVersionRSTP)
+		if bc.Legacy {
+			forceversion = StpForceVersionSTPCompat
+		}
+		brgifindex := stpSimBrgIfIndex(bc.Num)
+		if bc.DefaultVlan {
+			brgifindex = DEFAULT_STP_BRIDGE_VLAN
+		}
+		sb := &StpSimBridge{
+			num:        bc.Num,
+			brgifindex: brgifindex,
+			cfg: &StpBridgeConfig{
+				Address:      net.HardwareAddr(addr[:]).String(),
+				Priority:     bc.Priority,
+				MaxAge:       StpSimMaxAge,
+				HelloTime:    StpSimHelloTime,
+				ForwardDelay: StpSimForwardDelay,
+				ForceVersion: forceversion,
+				TxHoldCount:  TransmitHoldCountDefault,
+				Vlan:         uint16(brgifindex),
+			},
+			ports: make([]*StpSimPort, 0),
+		}
+		s.bridges = append(s.bridges, sb)
+	}
+
+	// all ports must be known before any bridge is running
+	for _, lc := range links {
+		link := &StpSimLink{
+			mutex: &sync.Mutex{},
+			up:    !lc.Down,
+		}
+		link.a = s.newSimPort(s.Bridge(lc.A), link)
+		link.b = s.newSimPort(s.Bridge(lc.B), link)
+		link.a.peer = link.b
+		link.b.peer = link.a
+		s.links = append(s.links, link)
+	}
+
+	for _, sb := range s.bridges {
+		if err := StpBrgConfigParamCheck(sb.cfg, true); err != nil {
+			t.Error("ERROR valid sim bridge config failed", sb.num, err)
+		}
+		if err := StpBridgeCreate(sb.cfg); err != nil {
+			t.Error("ERROR valid sim bridge creation failed", sb.num, err)
+		}
+		// every bridge in the process shares the switch mac, give each
+		// simulated bridge its own address
+		if b := s.StpBridge(sb.num); b != nil {
+			b.BridgeIdentifier = CreateBridgeId(stpSimBridgeAddr(sb.num), sb.cfg.Priority, 0)
+			b.BridgePriority.RootBridgeId = b.BridgeIdentifier
+			b.BridgePriority.DesignatedBridgeId = b.BridgeIdentifier
+		}
+
+		for _, sp := range sb.ports {
+			if err := StpPortConfigParamCheck(sp.cfg, false, true); err != nil {
+				t.Error("ERROR valid sim port config failed", sp.cfg.IfIndex, err)
+			}
+			if err := StpPortCreate(sp.cfg); err != nil {
+				t.Error("ERROR valid sim port creation failed", sp.cfg.IfIndex, err)
+			}
+			var p *StpPort
+			if StpFindPortByIfIndex(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, &p) {
+				p.usedForTestOnlyTxHandle = sp
+			}
+			sp.rxMain()
+		}
+	}
+
+	for _, link := range s.links {
+		if link.up {
+			s.linkUp(link)
+		}
+	}
+	return s
+}
+
+func (s *StpSimulator) newSimPort(sb *StpSimBridge, link *StpSimLink) *StpSimPort {
+	portnum := uint8(len(sb.ports) + 1)
+	ifindex := stpSimPortIfIndex(sb.num, portnum)
+	PortConfigMap[ifindex] = portConfig{Name: fmt.Sprintf("SIMbr%deth%d", sb.num, portnum),
+		HardwareAddr: net.HardwareAddr{0x00, byte(ifindex), 0x11, 0x22, 0x22, 0x33},
+		Speed:        1000,
+		IfIndex:      ifindex,
+	}
+	sp := &StpSimPort{
+		num: sb.num,
+		cfg: &StpPortConfig{
+			IfIndex:           ifindex,
+			Priority:          128,
+			Enable:            true,
+			PathCost:          20000,
+			ProtocolMigration: 1,
+			AdminPointToPoint: int32(StpPointToPointForceTrue),
+			AdminEdgePort:     false,
+			AdminPathCost:     20000,
+			BrgIfIndex:        sb.brgifindex,
+		},
+		rx:   make(chan gopacket.Packet, StpSimRxQueueSize),
+		link: link,
+	}
+	sb.ports = append(sb.ports, sp)
+	return sp
+}
+
+func (s *StpSimulator) linkUp(link *StpSimLink) {
+	link.mutex.Lock()
+	link.up = true
+	link.mutex.Unlock()
+	for _, sp := range []*StpSimPort{link.a, link.b} {
+		s.asicd.linkStatusSet(sp.cfg.IfIndex, true)
+		StpPortLinkUp(sp.cfg.IfIndex)
+	}
+}
+
+func (s *StpSimulator) linkDown(link *StpSimLink) {
+	link.mutex.Lock()
+	link.up = false
+	link.mutex.Unlock()
+	for _, sp := range []*StpSimPort{link.a, link.b} {
+		s.asicd.linkStatusSet(sp.cfg.IfIndex, false)
+		StpPortLinkDown(sp.cfg.IfIndex)
+	}
+}
+
+func (s *StpSimulator) Teardown(t *testing.T) {
+	for _, link := range s.links {
+		link.mutex.Lock()
+		link.up = false
+		link.mutex.Unlock()
+	}
+
+	for _, sb := range s.bridges {
+		for _, sp := range sb.ports {
+			if err := StpPortDelete(sp.cfg); err != nil {
+				t.Error("ERROR valid sim port deletion failed", sp.cfg.IfIndex, err)
+			}
+		}
+		if err := StpBridgeDelete(sb.cfg); err != nil {
+			t.Error("ERROR valid sim bridge deletion failed", sb.num, err)
+		}
+	}
+
+	for _, sb := range s.bridges {
+		for _, sp := range sb.ports {
+			close(sp.rx)
+			delete(PortConfigMap, sp.cfg.IfIndex)
+		}
+	}
+
+	UsedForTestOnlyDeleteAllAsicDPlugins()
+	UsedForTestOnlySetupAsicDPlugin()
+	usedForTestOnlyTickTimerExternal = false
+
+	MemoryCheck(t)
+}
+
+// Bridge returns the simulated bridge
+func (s *StpSimulator) Bridge(num uint8) *StpSimBridge {
+	for _, sb := range s.bridges {
+		if sb.num == num {
+			return sb
+		}
+	}
+	return nil
+}
+
+// StpBridge returns the stp bridge of the simulated bridge
+func (s *StpSimulator) StpBridge(num uint8) *Bridge {
+	var b *Bridge
+	if sb := s.Bridge(num); sb != nil &&
+		StpFindBridgeByIfIndex(sb.brgifindex, &b) {
+		return b
+	}
+	return nil
+}
+
+func (s *StpSimulator) findLink(a, b uint8) *StpSimLink {
+	for _, link := range s.links {
+		if (link.a.num == a && link.b.num == b) ||
+			(link.a.num == b && link.b.num == a) {
+			return link
+		}
+	}
+	return nil
+}
+
+// Port returns the stp port on bridge home connected to bridge neighbor
+func (s *StpSimulator) Port(home, neighbor uint8) *StpPort {
+	if link := s.findLink(home, neighbor); link != nil {
+		sp := link.a
+		if sp.num != home {
+			sp = link.b
+		}
+		var p *StpPort
+		if StpFindPortByIfIndex(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, &p) {
+			return p
+		}
+	}
+	return nil
+}
+
+// TxStats returns the BPDUs transmitted by the port on bridge home connected
+// to bridge neighbor
+func (s *StpSimulator) TxStats(home, neighbor uint8) StpSimTxStats {
+	if link := s.findLink(home, neighbor); link != nil {
+		sp := link.a
+		if sp.num != home {
+			sp = link.b
+		}
+		link.mutex.Lock()
+		defer link.mutex.Unlock()
+		return sp.tx
+	}
+	return StpSimTxStats{}
+}
+
+// LinkCut will take down both ends of the link between bridge a and b
+func (s *StpSimulator) LinkCut(a, b uint8) {
+	if link := s.findLink(a, b); link != nil {
+		s.linkDown(link)
+	}
+}
+
+// LinkRestore will bring the link between bridge a and b back up
+func (s *StpSimulator) LinkRestore(a, b uint8) {
+	if link := s.findLink(a, b); link != nil {
+		s.linkUp(link)
+	}
+}
+
+// LinkTxCut will lose the frames sent by bridge from to bridge to, the link
+// stays up so the link becomes one way
+func (s *StpSimulator) LinkTxCut(from, to uint8) {
+	s.linkTxCutSet(from, to, true)
+}
+
+// LinkTxRestore will deliver the frames sent by bridge from to bridge to again
+func (s *StpSimulator) LinkTxRestore(from, to uint8) {
+	s.linkTxCutSet(from, to, false)
+}
+
+func (s *StpSimulator) linkTxCutSet(from, to uint8, cut bool) {
+	if link := s.findLink(from, to); link != nil {
+		sp := link.a
+		if sp.num != from {
+			sp = link.b
+		}
+		link.mutex.Lock()
+		sp.txCut = cut
+		link.mutex.Unlock()
+	}
+}
+
+// BridgePrioritySet will change the bridge priority of the simulated bridge
+func (s *StpSimulator) BridgePrioritySet(num uint8, priority uint16, t *testing.T) {
+	if err := StpBrgPrioritySet(s.Bridge(num).brgifindex, priority); err != nil {
+		t.Error("ERROR valid sim bridge priority change failed", num, err)
+	}
+}
+
+// PortGuardSet will set root guard or loop guard on the port on bridge home
+// connected to bridge neighbor
+func (s *StpSimulator) PortGuardSet(home, neighbor uint8, rootguard, loopguard bool, t *testing.T) {
+	p := s.Port(home, neighbor)
+	if p == nil {
+		t.Error("ERROR unable to find sim port", home, neighbor)
+		return
+	}
+	if err := StpPortRootGuardSet(p.IfIndex, p.BrgIfIndex, rootguard); err != nil {
+		t.Error("ERROR valid sim port root guard set failed", home, neighbor, err)
+	}
+	if err := StpPortLoopGuardSet(p.IfIndex, p.BrgIfIndex, loopguard); err != nil {
+		t.Error("ERROR valid sim port loop guard set failed", home, neighbor, err)
+	}
+}
+
+// FlushedSince returns whether the bridge flushed its fdb since the time given
+func (s *StpSimulator) FlushedSince(num uint8, since time.Time) bool {
+	if b := s.StpBridge(num); b != nil {
+		for _, e := range b.TcHistoryGet() {
+			if e.Type == TcHistoryTypeFlush &&
+				e.Time.After(since) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TcHistoryCount returns the number of topology change history entries of the
+// given type recorded against the port since the time given
+func (s *StpSimulator) TcHistoryCount(num uint8, t TcHistoryType, ifindex int32, since time.Time) int {
+	cnt := 0
+	if b := s.StpBridge(num); b != nil {
+		for _, e := range b.TcHistoryGet() {
+			if e.Type == t &&
+				e.IfIndex == ifindex &&
+				e.Time.After(since) {
+				cnt++
+			}
+		}
+	}
+	return cnt
+}
+
+// stpSimPortConverged checks that the port has settled on a role and that the
+// port state agrees with that role
+func stpSimPortConverged(p *StpPort, up bool) bool {
+	if !p.Selected ||
+		p.UpdtInfo ||
+		p.Reselect ||
+		p.Role != p.SelectedRole {
+		return false
+	}
+	if !up {
+		return p.Role == PortRoleDisabledPort
+	}
+	switch p.Role {
+	case PortRoleRootPort, PortRoleDesignatedPort:
+		return p.Learning && p.Forwarding
+	case PortRoleAlternatePort, PortRoleBackupPort:
+		return !p.Learning && !p.Forwarding
+	}
+	return false
+}
+
+// Converged returns whether every port has settled on a role and that
+// every bridge has agreed on the root of its part of the network
+func (s *StpSimulator) Converged() bool {
+	for _, sb := range s.bridges {
+		b := s.StpBridge(sb.num)
+		if b == nil {
+			return false
+		}
+		rootports := 0
+		for _, sp := range sb.ports {
+			var p *StpPort
+			if !StpFindPortByIfIndex(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, &p) {
+				return false
+			}
+			sp.link.mutex.Lock()
+			up := sp.link.up
+			sp.link.mutex.Unlock()
+			if !stpSimPortConverged(p, up) {
+				return false
+			}
+			if p.Role == PortRoleRootPort {
+				rootports++
+			}
+			// both ends of a link must agree on the root
+			if peer := s.StpBridge(sp.peer.num); up &&
+				(peer == nil || peer.BridgePriority.RootBridgeId != b.BridgePriority.RootBridgeId) {
+				return false
+			}
+		}
+		if rootports > 1 ||
+			(rootports == 0) != (b.BridgePriority.RootBridgeId == b.BridgeIdentifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// Tick will advance the simulated time by one second, the port timers of
+// every port are decremented
+func (s *StpSimulator) Tick() {
+	s.ticks++
+	responseChan := make(chan string)
+	for _, sb := range s.bridges {
+		for _, sp := range sb.ports {
+			var p *StpPort
+			if StpFindPortByIfIndex(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, &p) &&
+				p.PtmMachineFsm != nil {
+				p.PtmMachineFsm.PtmEvents <- MachineEvent{e: PtmEventTickEqualsTrue,
+					src:          "SIM",
+					responseChan: responseChan}
+				<-responseChan
+			}
+		}
+	}
+}
+
+// Run will advance the simulated time by the number of ticks given
+func (s *StpSimulator) Run(ticks int) {
+	for i := 0; i < ticks; i++ {
+		s.Tick()
+		time.Sleep(StpSimSettleTime)
+	}
+}
+
+// settle will give the state machines time to process the events resulting
+// from the last tick, returns as soon as the condition is met
+func (s *StpSimulator) settle(cond func() bool) bool {
+	start := time.Now()
+	for time.Since(start) < StpSimSettleTime {
+		if cond() {
+			return true
+		}
+		time.Sleep(StpSimPollTime)
+	}
+	return cond()
+}
+
+// WaitForConvergence will tick until the network has converged and remained
+// converged for a tick, the number of ticks taken to converge is returned
+func (s *StpSimulator) WaitForConvergence(maxticks int) (int, bool) {
+	converged := -1
+	for ticks := 0; ticks <= maxticks; ticks++ {
+		if converged < 0 {
+			if s.settle(s.Converged) {
+				converged = ticks
+			}
+		} else {
+			// the network must still be converged once the machines have
+			// settled after the tick
+			time.Sleep(StpSimSettleTime)
+			if s.Converged() {
+				return converged, true
+			}
+			converged = -1
+		}
+		s.Tick()
+	}
+	return maxticks, false
+}
+
+// WaitFor will tick until the condition is met, returns false once max ticks
+// have passed
+func (s *StpSimulator) WaitFor(maxticks int, cond func() bool) bool {
+	for ticks := 0; ticks < maxticks; ticks++ {
+		if s.settle(cond) {
+			return true
+		}
+		s.Tick()
+	}
+	return s.settle(cond)
+}
+
+// VerifyConverged will check that the network converged within the max
+// converge ticks
+func (s *StpSimulator) VerifyConverged(step string, t *testing.T) {
+	ticks, ok := s.WaitForConvergence(StpSimMaxTicks)
+	if !ok {
+		t.Error(fmt.Sprintf("step: %s network did not converge within %d ticks", step, StpSimMaxTicks))
+		s.dump(t)
+	} else if ticks > StpSimMaxConvergeTicks {
+		t.Error(fmt.Sprintf("step: %s network took %d ticks to converge expected at most %d", step, ticks, StpSimMaxConvergeTicks))
+	}
+}
+
+// VerifyRoot will check that every bridge connected to the root agrees on the root
+func (s *StpSimulator) VerifyRoot(step string, root uint8, bridges []uint8, t *testing.T) {
+	rb := s.StpBridge(root)
+	for _, num := range bridges {
+		b := s.StpBridge(num)
+		if b == nil || rb == nil ||
+			b.BridgePriority.RootBridgeId != rb.BridgeIdentifier {
+			t.Error(fmt.Sprintf("step: %s bridge %d does not have bridge %d as root", step, num, root))
+		}
+	}
+	if rb != nil && rb.RootPortId != 0 {
+		t.Error(fmt.Sprintf("step: %s root bridge %d has a root port %d", step, root, rb.RootPortId))
+	}
+}
+
+// VerifyPortRole will check the role of the port on bridge home connected to bridge neighbor
+func (s *StpSimulator) VerifyPortRole(step string, home, neighbor uint8, role PortRole, t *testing.T) {
+	p := s.Port(home, neighbor)
+	if p == nil {
+		t.Error(fmt.Sprintf("step: %s unable to find port on bridge %d to bridge %d", step, home, neighbor))
+	} else if p.Role != role {
+		t.Error(fmt.Sprintf("step: %s bridge %d port to bridge %d role %s expected %s", step, home, neighbor,
+			PortRoleStrMap[p.Role], PortRoleStrMap[role]))
+	}
+}
+
+// VerifyPortGuard will wait for the port on bridge home connected to bridge
+// neighbor to reach the selected role and guard inconsistent state given
+func (s *StpSimulator) VerifyPortGuard(step string, home, neighbor uint8, role PortRole, rootinconsistant, loopinconsistant bool, t *testing.T) {
+	p := s.Port(home, neighbor)
+	if p == nil {
+		t.Error(fmt.Sprintf("step: %s unable to find port on bridge %d to bridge %d", step, home, neighbor))
+		return
+	}
+	if !s.WaitFor(StpSimMaxTicks, func() bool {
+		return p.SelectedRole == role &&
+			p.RootGuardInconsistant == rootinconsistant &&
+			p.LoopGuardInconsistant == loopinconsistant
+	}) {
+		t.Error(fmt.Sprintf("step: %s bridge %d port to bridge %d selected role %s root inconsistent %t loop inconsistent %t expected %s %t %t",
+			step, home, neighbor, PortRoleStrMap[p.SelectedRole], p.RootGuardInconsistant, p.LoopGuardInconsistant,
+			PortRoleStrMap[role], rootinconsistant, loopinconsistant))
+		s.dump(t)
+	}
+}
+
+func (s *StpSimulator) dump(t *testing.T) {
+	for _, sb := range s.bridges {
+		b := s.StpBridge(sb.num)
+		if b == nil {
+			continue
+		}
+		t.Log(fmt.Sprintf("bridge %d id %s root %s", sb.num, CreateBridgeIdStr(b.BridgeIdentifier), CreateBridgeIdStr(b.BridgePriority.RootBridgeId)))
+		for _, sp := range sb.ports {
+			var p *StpPort
+			if StpFindPortByIfIndex(sp.cfg.IfIndex, sp.cfg.BrgIfIndex, &p) {
+				t.Log(fmt.Sprintf("  port %d to bridge %d role %s selected role %s selected %t learning %t forwarding %t",
+					p.IfIndex, sp.peer.num, PortRoleStrMap[p.Role], PortRoleStrMap[p.SelectedRole], p.Selected, p.Learning, p.Forwarding))
+			}
+		}
+	}
+}
+
+// stpSimNetwork creates the bridges used by every scenario, bridge 4 has
+// the best priority but is isolated unless a link to it is provided
+func stpSimNetwork(t *testing.T, links []StpSimLinkConfig) *StpSimulator {
+	bridges := []StpSimBridgeConfig{
+		{Num: 1, Priority: 4096},
+		{Num: 2, Priority: 32768},
+		{Num: 3, Priority: 32768},
+		{Num: 4, Priority: 0},
+	}
+	return NewStpSimulator(bridges, links, t)
+}
+
+// bridge 1 is root, bridge 2 has the lower address so the link between
+// bridge 2 and 3 is blocked on bridge 3
+func TestStpSimTriangleConvergence(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+	})
+
+	s.VerifyConverged("formation", t)
+	s.VerifyRoot("formation", 1, []uint8{1, 2, 3}, t)
+	s.VerifyPortRole("formation", 1, 2, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("formation", 1, 3, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("formation", 2, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("formation", 3, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("formation", 2, 3, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("formation", 3, 2, PortRoleAlternatePort, t)
+
+	s.Teardown(t)
+}
+
+// cutting the root port of bridge 3 should move the alternate port to root
+// and flush the fdb, restoring the link should return to the original topology
+func TestStpSimLinkCut(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+	})
+
+	s.VerifyConverged("formation", t)
+
+	cuttime := time.Now()
+	s.LinkCut(1, 3)
+
+	s.VerifyConverged("link cut", t)
+	s.VerifyRoot("link cut", 1, []uint8{1, 2, 3}, t)
+	s.VerifyPortRole("link cut", 3, 2, PortRoleRootPort, t)
+	s.VerifyPortRole("link cut", 2, 3, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("link cut", 3, 1, PortRoleDisabledPort, t)
+	if !s.FlushedSince(3, cuttime) {
+		t.Error("ERROR bridge 3 did not flush fdb after root port link cut")
+	}
+
+	s.LinkRestore(1, 3)
+
+	s.VerifyConverged("link restored", t)
+	s.VerifyPortRole("link restored", 3, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("link restored", 3, 2, PortRoleAlternatePort, t)
+
+	s.Teardown(t)
+}
+
+// lowering the priority of bridge 3 should make it the root
+func TestStpSimPriorityChange(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+	})
+
+	s.VerifyConverged("formation", t)
+
+	s.BridgePrioritySet(3, 0, t)
+
+	s.VerifyConverged("priority change", t)
+	s.VerifyRoot("priority change", 3, []uint8{1, 2, 3}, t)
+	s.VerifyPortRole("priority change", 1, 3, PortRoleRootPort, t)
+	s.VerifyPortRole("priority change", 2, 3, PortRoleRootPort, t)
+	// bridge 1 has the better priority of the two
+	s.VerifyPortRole("priority change", 1, 2, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("priority change", 2, 1, PortRoleAlternatePort, t)
+
+	s.Teardown(t)
+}
+
+// bridge 4 with the best priority joins the network via bridge 2 and
+// takes over as root
+func TestStpSimNewRoot(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+		{A: 4, B: 2, Down: true},
+	})
+
+	s.VerifyConverged("formation", t)
+	s.VerifyRoot("formation", 1, []uint8{1, 2, 3}, t)
+	s.VerifyRoot("formation", 4, []uint8{4}, t)
+
+	s.LinkRestore(4, 2)
+
+	s.VerifyConverged("new root", t)
+	s.VerifyRoot("new root", 4, []uint8{1, 2, 3, 4}, t)
+	s.VerifyPortRole("new root", 4, 2, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("new root", 2, 4, PortRoleRootPort, t)
+	s.VerifyPortRole("new root", 1, 2, PortRoleRootPort, t)
+	s.VerifyPortRole("new root", 3, 2, PortRoleRootPort, t)
+	s.VerifyPortRole("new root", 3, 1, PortRoleAlternatePort, t)
+
+	s.Teardown(t)
+}
+
+// bridge 1 is the default vlan bridge sending IEEE BPDUs, it is root of
+// the Rapid-PVST+ bridges 2 and 3
+func TestStpSimDefaultVlanConvergence(t *testing.T) {
+	s := NewStpSimulator([]StpSimBridgeConfig{
+		{Num: 1, Priority: 4096, DefaultVlan: true},
+		{Num: 2, Priority: 32768},
+		{Num: 3, Priority: 32768},
+	}, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+	}, t)
+
+	if b := s.StpBridge(1); b == nil || b.Vlan != DEFAULT_STP_BRIDGE_VLAN {
+		t.Error("ERROR bridge 1 not created as the default vlan bridge")
+	}
+
+	s.VerifyConverged("formation", t)
+	s.VerifyRoot("formation", 1, []uint8{1, 2, 3}, t)
+	s.VerifyPortRole("formation", 2, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("formation", 3, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("formation", 2, 3, PortRoleDesignatedPort, t)
+	s.VerifyPortRole("formation", 3, 2, PortRoleAlternatePort, t)
+
+	// default vlan bridge only sends IEEE BPDUs
+	for _, neighbor := range []uint8{2, 3} {
+		if tx := s.TxStats(1, neighbor); tx.Rstp == 0 || tx.Pvst != 0 {
+			t.Error("ERROR default vlan bridge did not send only IEEE RST BPDUs to bridge", neighbor, tx)
+		}
+	}
+
+	s.Teardown(t)
+}
+
+// bridge 4 with the best priority joins the network via a root guard port
+// on bridge 2, the port is blocked rather than bridge 4 becoming root until
+// root guard is removed
+func TestStpSimRootGuard(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+		{A: 4, B: 2, Down: true},
+	})
+
+	s.VerifyConverged("formation", t)
+	s.PortGuardSet(2, 4, true, false, t)
+
+	s.LinkRestore(4, 2)
+
+	s.VerifyPortGuard("superior bpdu", 2, 4, PortRoleAlternatePort, true, false, t)
+	// bridge 4 does not become root of the rest of the network
+	s.Run(StpSimMaxAge)
+	s.VerifyPortGuard("superior bpdu", 2, 4, PortRoleAlternatePort, true, false, t)
+	s.VerifyRoot("superior bpdu", 1, []uint8{1, 2, 3}, t)
+	s.VerifyRoot("superior bpdu", 4, []uint8{4}, t)
+	s.VerifyPortRole("superior bpdu", 2, 1, PortRoleRootPort, t)
+	s.VerifyPortRole("superior bpdu", 3, 1, PortRoleRootPort, t)
+	if p := s.Port(2, 4); p == nil || p.Forwarding {
+		t.Error("ERROR root inconsistent port is forwarding")
+	}
+
+	s.PortGuardSet(2, 4, false, false, t)
+
+	s.VerifyConverged("root guard removed", t)
+	s.VerifyPortGuard("root guard removed", 2, 4, PortRoleRootPort, false, false, t)
+	s.VerifyRoot("root guard removed", 4, []uint8{1, 2, 3, 4}, t)
+
+	s.Teardown(t)
+}
+
+// BPDUs stop being received on the loop guard ports of bridge 3 while the
+// links stay up, the ports are blocked rather than aging the received info
+// and becoming designated until BPDUs are received again
+func TestStpSimLoopGuard(t *testing.T) {
+	s := stpSimNetwork(t, []StpSimLinkConfig{
+		{A: 1, B: 2},
+		{A: 1, B: 3},
+		{A: 2, B: 3},
+	})
+
+	s.VerifyConverged("formation", t)
+	s.PortGuardSet(3, 1, false, true, t)
+	s.PortGuardSet(3, 2, false, true, t)
+
+	// alternate port
+	s.LinkTxCut(2, 3)
+
+	s.VerifyPortGuard("alternate one way link", 3, 2, PortRoleAlternatePort, false, true, t)
+	for i := 0; i < StpSimMaxAge; i++ {
+		s.Run(1)
+		if p := s.Port(3, 2); p == nil ||
+			p.SelectedRole == PortRoleDesignatedPort ||
+			p.Forwarding {
+			t.Error("ERROR loop inconsistent alternate port became designated forwarding")
+			break
+		}
+	}
+	s.VerifyPortRole("alternate one way link", 3, 1, PortRoleRootPort, t)
+
+	s.LinkTxRestore(2, 3)
+
+	s.VerifyPortGuard("alternate link restored", 3, 2, PortRoleAlternatePort, false, false, t)
+	s.VerifyConverged("alternate link restored", t)
+
+	// root port, bridge 3 moves its root port to the alternate port
+	s.LinkTxCut(1, 3)
+
+	s.VerifyPortGuard("root one way link", 3, 1, PortRoleAlternatePort, false, true, t)
+	s.VerifyPortGuard("root one way link", 3, 2, PortRoleRootPort, false, false, t)
+	for i := 0; i < StpSimMaxAge; i++ {
+		s.Run(1)
+		if p := s.Port(3, 1); p == nil ||
+			p.SelectedRole == PortRoleDesignatedPort ||
+			p.Forwarding {
+			t.Error("ERROR loop inconsistent root port became designated forwarding")
+			break
+		}
+	}
+
+	s.LinkTxRestore(1, 3)
+
+	s.VerifyPortGuard("root link restored", 3, 1, PortRoleRootPort, false, false, t)
+	s.VerifyConverged("root link restored", t)
+	s.VerifyPortRole("root link restored", 3, 2, PortRoleAlternatePort, t)
+
+	s.Teardown(t)
+}
@@ -0,0 +1,164 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// tchistory.go
+// Topology Change History
+//
+// Each bridge keeps a ring of the most recent topology change events, the
+// port the TC/TCN was received on and the sender bridge identifier from the
+// BPDU, TC's detected by this bridge, and the resulting FDB flushes.
+package stp
+
+import (
+	"fmt"
+	"time"
+)
+
+const TcHistorySize = 64
+
+type TcHistoryType int
+
+const (
+	TcHistoryTypeRcvdTc TcHistoryType = iota
+	TcHistoryTypeRcvdTcn
+	TcHistoryTypeDetected
+	TcHistoryTypeFlush
+)
+
+var TcHistoryTypeStrMap = map[TcHistoryType]string{
+	TcHistoryTypeRcvdTc:   "Received TC",
+	TcHistoryTypeRcvdTcn:  "Received TCN",
+	TcHistoryTypeDetected: "Detected TC",
+	TcHistoryTypeFlush:    "FDB Flush",
+}
+
+type TcHistoryEntry struct {
+	// sequence number of the entry, increases for every entry recorded
+	// against the bridge so it stays the same as the ring wraps
+	Seq            uint32
+	Time           time.Time
+	Type           TcHistoryType
+	IfIndex        int32
+	SenderBridgeId BridgeId
+}
+
+type TcHistory struct {
+	// ring of entries, idx is the next entry to be written
+	entries [TcHistorySize]TcHistoryEntry
+	idx     int
+	cnt     int
+	seq     uint32
+
+	// number of topology changes detected by this bridge
+	TcCount          uint32
+	LastTcTime       time.Time
+	LastRcvdIfIndex  int32
+	LastRcvdBridgeId BridgeId
+}
+
+// TcHistoryRecord will add an entry to the topology change history
+func (b *Bridge) TcHistoryRecord(t TcHistoryType, ifindex int32, sender BridgeId) {
+	b.tcHistoryMutex.Lock()
+	defer b.tcHistoryMutex.Unlock()
+
+	h := &b.TcHistory
+	now := time.Now()
+	h.entries[h.idx] = TcHistoryEntry{
+		Seq:            h.seq,
+		Time:           now,
+		Type:           t,
+		IfIndex:        ifindex,
+		SenderBridgeId: sender,
+	}
+	h.seq++
+	h.idx = (h.idx + 1) % TcHistorySize
+	if h.cnt < TcHistorySize {
+		h.cnt++
+	}
+
+	switch t {
+	case TcHistoryTypeRcvdTc, TcHistoryTypeRcvdTcn:
+		h.LastTcTime = now
+		h.LastRcvdIfIndex = ifindex
+		h.LastRcvdBridgeId = sender
+		StpLogger("DEBUG", fmt.Sprintf("TC HISTORY: bridge %d %s port %d sender %s", b.BrgIfIndex, TcHistoryTypeStrMap[t], ifindex, CreateBridgeIdStr(sender)))
+	case TcHistoryTypeDetected:
+		h.TcCount++
+		h.LastTcTime = now
+	}
+}
+
+// TcHistoryGet returns a copy of the topology change history oldest first
+func (b *Bridge) TcHistoryGet() []TcHistoryEntry {
+	b.tcHistoryMutex.Lock()
+	defer b.tcHistoryMutex.Unlock()
+
+	h := &b.TcHistory
+	entries := make([]TcHistoryEntry, 0, h.cnt)
+	start := (h.idx - h.cnt + TcHistorySize) % TcHistorySize
+	for i := 0; i < h.cnt; i++ {
+		entries = append(entries, h.entries[(start+i)%TcHistorySize])
+	}
+	return entries
+}
+
+// TcHistoryFind returns the entry with the given sequence number if it is
+// still in the history
+func (b *Bridge) TcHistoryFind(seq uint32) (TcHistoryEntry, bool) {
+	b.tcHistoryMutex.Lock()
+	defer b.tcHistoryMutex.Unlock()
+
+	h := &b.TcHistory
+	// entries are written in sequence order so the oldest entry is
+	// h.seq - h.cnt
+	if h.seq-seq == 0 || h.seq-seq > uint32(h.cnt) {
+		return TcHistoryEntry{}, false
+	}
+	return h.entries[(h.idx-int(h.seq-seq)+TcHistorySize)%TcHistorySize], true
+}
+
+// TcHistoryStats returns the topology change count and last received info
+func (b *Bridge) TcHistoryStats() (tccount uint32, lasttc time.Time, lastifindex int32, lastsender BridgeId) {
+	b.tcHistoryMutex.Lock()
+	defer b.tcHistoryMutex.Unlock()
+
+	h := &b.TcHistory
+	return h.TcCount, h.LastTcTime, h.LastRcvdIfIndex, h.LastRcvdBridgeId
+}
+
+// TcHistoryRcvd records a received TC/TCN against the bridge this port belongs to
+func (p *StpPort) TcHistoryRcvd(t TcHistoryType, sender BridgeId) {
+	if p.b != nil {
+		p.b.TcHistoryRecord(t, p.IfIndex, sender)
+	}
+}
+
+// TcTimeSinceLast returns the time in hundredths of a second since the last
+// topology change was received or detected by this bridge
+func (b *Bridge) TcTimeSinceLast() uint32 {
+	_, lasttc, _, _ := b.TcHistoryStats()
+	if lasttc.IsZero() {
+		return 0
+	}
+	return uint32(time.Since(lasttc) / (time.Millisecond * 10))
+}
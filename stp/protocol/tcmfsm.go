@@ -171,6 +171,9 @@ func (tcm *TcMachine) TcMachineLearning(m fsm.Machine, data interface{}) fsm.Sta
 // TcMachineDetected
 func (tcm *TcMachine) TcMachineDetected(m fsm.Machine, data interface{}) fsm.State {
 	p := tcm.p
+	if p.b != nil {
+		p.b.TcHistoryRecord(TcHistoryTypeDetected, p.IfIndex, p.b.BridgeIdentifier)
+	}
 	newinfonotificationsent := tcm.newTcWhile()
 	tcm.setTcPropTree()
 	if !newinfonotificationsent {
@@ -188,7 +191,9 @@ func (tcm *TcMachine) TcMachineActive(m fsm.Machine, data interface{}) fsm.State
 
 // TcMachineNotifyTcn
 func (tcm *TcMachine) TcMachineNotifiedTcn(m fsm.Machine, data interface{}) fsm.State {
+	p := tcm.p
 
+	p.TcHistoryRcvd(TcHistoryTypeRcvdTcn, p.RcvdTcBridgeId)
 	tcm.newTcWhile()
 
 	return TcStateNotifiedTcn
@@ -198,6 +203,11 @@ func (tcm *TcMachine) TcMachineNotifiedTcn(m fsm.Machine, data interface{}) fsm.
 func (tcm *TcMachine) TcMachineNotifiedTc(m fsm.Machine, data interface{}) fsm.State {
 	p := tcm.p
 
+	// NOTIFIED_TCN falls through here with rcvdTcn still set and has
+	// already been recorded
+	if !p.RcvdTcn {
+		p.TcHistoryRcvd(TcHistoryTypeRcvdTc, p.RcvdTcBridgeId)
+	}
 	p.RcvdTcn = false
 	p.RcvdTc = false
 	if p.Role == PortRoleDesignatedPort {
@@ -563,10 +573,18 @@ func (tcm *TcMachine) FlushFdb() {
 	// or adjust timer to flush once flushing
 	// is complete lets clear FdbFlush and
 	// send event to TCM
-	for _, client := range GetAsicDPluginList() {
-		client.FlushStgFdb(p.b.StgId, p.IfIndex)
+	if !p.RstpVersion {
+		// stpVersion entries are removed by rapid ageing
+		p.RapidAgeingStart(TcMachineModuleStr)
+	} else {
+		for _, client := range GetAsicDPluginList() {
+			client.FlushStgFdb(p.b.StgId, p.IfIndex)
+		}
+		StpMachineLogger("DEBUG", TcMachineModuleStr, p.IfIndex, p.BrgIfIndex, "FDB Flush")
+		if p.b != nil {
+			p.b.TcHistoryRecord(TcHistoryTypeFlush, p.IfIndex, p.b.BridgeIdentifier)
+		}
 	}
-	StpMachineLogger("DEBUG", TcMachineModuleStr, p.IfIndex, p.BrgIfIndex, "FDB Flush")
 	p.FdbFlush = false
 	if p.Learn &&
 		p.TcMachineFsm != nil &&
@@ -671,7 +689,8 @@ func (tcm *TcMachine) newTcWhile() (newinfonotificationsent bool) {
 			newinfonotificationsent = true
 			p.NewInfo = true
 		} else {
-			p.TcWhileTimer.count = int32(p.PortTimes.MaxAge + p.PortTimes.ForwardingDelay)
+			// topology change time uses the root times
+			p.TcWhileTimer.count = int32(p.b.RootTimes.MaxAge + p.b.RootTimes.ForwardingDelay)
 		}
 	}
 	return newinfonotificationsent
@@ -900,6 +900,18 @@ func TestTcmLearningToActiveToNotifyTcnState(t *testing.T) {
 	} else {
 		t.Error("ERROR Tcwhile not set properly")
 	}
+	rcvdtcn := 0
+	for _, e := range p.b.TcHistoryGet() {
+		if e.Type == TcHistoryTypeRcvdTc {
+			t.Error("ERROR TCN recorded as a received TC in TC history")
+		}
+		if e.Type == TcHistoryTypeRcvdTcn {
+			rcvdtcn++
+		}
+	}
+	if rcvdtcn != 1 {
+		t.Error("ERROR received TCN not recorded once in TC history", rcvdtcn)
+	}
 
 	UsedForTestOnlyTcmTestTeardown(p, t)
 }
@@ -946,6 +958,15 @@ func TestTcmLearningToActiveToNotifyTcState(t *testing.T) {
 	if !p.TcAck {
 		t.Error("ERROR TcAck not set")
 	}
+	rcvd := 0
+	for _, e := range p.b.TcHistoryGet() {
+		if e.Type == TcHistoryTypeRcvdTc {
+			rcvd++
+		}
+	}
+	if rcvd != 1 {
+		t.Error("ERROR received TC not recorded once in TC history", rcvd)
+	}
 
 	UsedForTestOnlyTcmTestTeardown(p, t)
 }
@@ -1029,3 +1050,76 @@ func TestTcmLearningToActiveToAcknowledgedState(t *testing.T) {
 
 	UsedForTestOnlyTcmTestTeardown(p, t)
 }
+
+func TestTcmDetectedTcHistory(t *testing.T) {
+	p := UsedForTestOnlyTcmTestSetup(t)
+
+	p.Learn = true
+	p.FdbFlush = false
+
+	UsedForTestStartTcDetectedState(p, t)
+
+	history := p.b.TcHistoryGet()
+	found := false
+	for _, e := range history {
+		if e.Type == TcHistoryTypeDetected &&
+			e.IfIndex == p.IfIndex &&
+			e.SenderBridgeId == p.b.BridgeIdentifier {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("ERROR detected TC not recorded in TC history", history)
+	}
+	tccount, _, _, _ := p.b.TcHistoryStats()
+	if tccount == 0 {
+		t.Error("ERROR TC count not incremented")
+	}
+
+	UsedForTestOnlyTcmTestTeardown(p, t)
+}
+
+func TestTcHistoryWrap(t *testing.T) {
+	b := &Bridge{}
+	sender := BridgeId{0x80, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+	for i := 0; i < TcHistorySize+5; i++ {
+		b.TcHistoryRecord(TcHistoryTypeRcvdTc, int32(i), sender)
+	}
+	b.TcHistoryRecord(TcHistoryTypeFlush, int32(TcHistorySize+5), BridgeId{})
+
+	history := b.TcHistoryGet()
+	if len(history) != TcHistorySize {
+		t.Error("ERROR TC history not limited to history size", len(history))
+	}
+	// oldest entries should have been overwritten
+	if history[0].IfIndex != 6 {
+		t.Error("ERROR TC history oldest entry not correct", history[0].IfIndex)
+	}
+	if history[len(history)-1].Type != TcHistoryTypeFlush {
+		t.Error("ERROR TC history newest entry not flush")
+	}
+	tccount, _, lastifindex, lastsender := b.TcHistoryStats()
+	// only topology changes detected by this bridge are counted
+	if tccount != 0 {
+		t.Error("ERROR TC count not correct", tccount)
+	}
+	// entries are keyed by sequence number not ring position
+	if history[0].Seq != 6 ||
+		history[len(history)-1].Seq != TcHistorySize+5 {
+		t.Error("ERROR TC history sequence not correct", history[0].Seq, history[len(history)-1].Seq)
+	}
+	if _, ok := b.TcHistoryFind(5); ok {
+		t.Error("ERROR TC history found overwritten entry")
+	}
+	if _, ok := b.TcHistoryFind(TcHistorySize + 6); ok {
+		t.Error("ERROR TC history found entry not yet recorded")
+	}
+	if e, ok := b.TcHistoryFind(10); !ok || e.IfIndex != 10 {
+		t.Error("ERROR TC history entry not found by sequence", e)
+	}
+	if lastifindex != int32(TcHistorySize+4) ||
+		lastsender != sender {
+		t.Error("ERROR last TC received from not correct", lastifindex, lastsender)
+	}
+}
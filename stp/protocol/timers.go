@@ -58,12 +58,18 @@ func TimerTypeStrStateMapInit() {
 	TimerTypeStrMap[TimerTypeBAWhile] = "Bridge Assurance While Timer"
 }
 
+// usedForTestOnlyTickTimerExternal is only set by the unit test simulator,
+// when set the tick timer is left stopped and the port timers are only
+// decremented by sending PtmEventTickEqualsTrue to the Port Timers State
+// Machine, this allows the test to control time
+var usedForTestOnlyTickTimerExternal bool
+
 // TickTimerStart: Port Timers Tick timer
 func (m *PtmMachine) TickTimerStart() {
 
 	if m.TickTimer == nil {
 		m.TickTimer = time.NewTimer(time.Second * 1)
-	} else {
+	} else if !usedForTestOnlyTickTimerExternal {
 		m.TickTimer.Reset(time.Second * 1)
 	}
 }
@@ -228,7 +234,8 @@ func (p *StpPort) DecrementTimerCounters() {
 	if p.RcvdInfoWhiletimer.count > 0 {
 		p.RcvdInfoWhiletimer.count--
 
-		if p.RcvdInfoWhiletimer.count == 0 {
+		if p.RcvdInfoWhiletimer.count == 0 &&
+			!p.LoopGuardRcvdInfoWhileExpired() {
 			defer p.NotifyRcvdInfoWhileTimerExpired()
 		}
 	}
@@ -304,16 +311,29 @@ func (p *StpPort) DecrementTimerCounters() {
 		}
 	}
 
-	if p.BpduGuard &&
-		p.OperEdge &&
+	// Rapid-PVST+ inconsistency recovery
+	if p.PvstInconsistentWhileTimer.count > 0 {
+		p.PvstInconsistentWhileTimer.count--
+		if p.PvstInconsistentWhileTimer.count == 0 {
+			defer p.PvstInconsistentWhileExpired(PtmMachineModuleStr)
+		}
+	}
+
+	// 17.19.1 stpVersion rapid ageing
+	if p.RapidAgeingWhileTimer.count > 0 {
+		p.RapidAgeingWhileTimer.count--
+		if p.RapidAgeingWhileTimer.count == 0 {
+			defer p.RapidAgeingExpired(PtmMachineModuleStr)
+		}
+	}
+
+	// err-disable recovery
+	if p.ErrDisabled &&
 		p.BPDUGuardTimer.count > 0 {
 		p.BPDUGuardTimer.count--
-		// condition has not been detected lets clear the
-		// Detection
+		// condition has not been detected lets recover the port
 		if p.BPDUGuardTimer.count == 0 {
-			for _, client := range GetAsicDPluginList() {
-				client.BPDUGuardDetected(p.IfIndex, false)
-			}
+			defer p.ErrDisableRecover(PtmMachineModuleStr)
 		}
 	}
 }
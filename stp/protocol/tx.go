@@ -31,6 +31,23 @@ import (
 	"github.com/google/gopacket/layers"
 )
 
+// BpduTxHandle is used to transmit a serialized BPDU
+type BpduTxHandle interface {
+	WritePacketData(data []byte) error
+}
+
+// txHandle returns the handle BPDUs should be transmitted on, nil if the
+// port is not able to transmit
+func (p *StpPort) txHandle() BpduTxHandle {
+	if p.usedForTestOnlyTxHandle != nil {
+		return p.usedForTestOnlyTxHandle
+	}
+	if p.handle != nil {
+		return p.handle
+	}
+	return nil
+}
+
 func ConvertBoolToUint8(v bool) (rv uint8) {
 	if v {
 		rv = 1
@@ -75,7 +92,7 @@ func (p *StpPort) BuildRSTPEthernetLlcHeaders() (eth layers.Ethernet, llc layers
 }
 
 func (p *StpPort) TxPVST() {
-	if p.handle != nil {
+	if handle := p.txHandle(); handle != nil {
 		pIntf, _ := PortConfigMap[p.IfIndex]
 
 		eth := layers.Ethernet{
@@ -138,12 +155,12 @@ func (p *StpPort) TxPVST() {
 
 		pvst.Flags = layers.StpFlags(flags)
 
-		/* NOT VALID within PVST STP frames should have been detected outside of this logic
+		// PVST+ Config BPDU when talking to an STP bridge
 		if !p.SendRSTP {
-			pvst.ProtocolId = layers.RSTPProtocolIdentifier
 			pvst.ProtocolVersionId = layers.STPProtocolVersion
 			pvst.BPDUType = layers.BPDUTypeSTP
 			// only tc and tc ack are valid for stp
+			flags = 0
 			StpSetBpduFlags(ConvertBoolToUint8(p.TcAck),
 				0,
 				0,
@@ -155,7 +172,6 @@ func (p *StpPort) TxPVST() {
 
 			pvst.Flags = layers.StpFlags(flags)
 		}
-		*/
 
 		// Set up buffer and options for serialization.
 		buf := gopacket.NewSerializeBuffer()
@@ -164,8 +180,15 @@ func (p *StpPort) TxPVST() {
 			ComputeChecksums: true,
 		}
 		// Send one packet for every address.
-		gopacket.SerializeLayers(buf, opts, &eth, &vlan, &llc, &snap, &pvst)
-		if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+		if p.IsPvstTxUntagged() {
+			// native vlan SSTP BPDUs are sent untagged
+			eth.EthernetType = layers.EthernetTypeLLC
+			eth.Length = uint16(layers.PVSTProtocolLength + 3 + 5)
+			gopacket.SerializeLayers(buf, opts, &eth, &llc, &snap, &pvst)
+		} else {
+			gopacket.SerializeLayers(buf, opts, &eth, &vlan, &llc, &snap, &pvst)
+		}
+		if err := handle.WritePacketData(buf.Bytes()); err != nil {
 			StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
 			return
 		}
@@ -186,9 +209,26 @@ func (p *StpPort) TxPVST() {
 
 func (p *StpPort) TxRSTP() {
 
-	if p.handle != nil {
+	if p.b.IsMsti() {
+		p.TxMsti()
+		return
+	}
+	if !p.BpduFilterTx() {
+		return
+	}
+
+	if handle := p.txHandle(); handle != nil {
 		if p.b.Vlan != DEFAULT_STP_BRIDGE_VLAN {
 			p.TxPVST()
+			// Rapid-PVST+ VLAN 1 also sends the IEEE BPDU when the
+			// CST is not being run by the default bridge
+			if p.b.Vlan != PvstCstVlan ||
+				IsStpCstBridgePresent() {
+				return
+			}
+		}
+		if p.b.IsMstpCist() {
+			p.TxMSTP()
 			return
 		}
 
@@ -230,7 +270,7 @@ func (p *StpPort) TxRSTP() {
 		}
 		// Send one packet for every address.
 		gopacket.SerializeLayers(buf, opts, &eth, &llc, &rstp)
-		if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+		if err := handle.WritePacketData(buf.Bytes()); err != nil {
 			StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
 			return
 		}
@@ -249,7 +289,14 @@ func (p *StpPort) TxRSTP() {
 }
 
 func (p *StpPort) TxTCN() {
-	if p.handle != nil {
+	if p.b.IsMsti() {
+		p.TxMsti()
+		return
+	}
+	if !p.BpduFilterTx() {
+		return
+	}
+	if handle := p.txHandle(); handle != nil {
 		eth, llc := p.BuildRSTPEthernetLlcHeaders()
 
 		if !p.SendRSTP {
@@ -268,7 +315,7 @@ func (p *StpPort) TxTCN() {
 			}
 			// Send one packet for every address.
 			gopacket.SerializeLayers(buf, opts, &eth, &llc, &topo)
-			if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+			if err := handle.WritePacketData(buf.Bytes()); err != nil {
 				StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
 				return
 			}
@@ -284,12 +331,24 @@ func (p *StpPort) TxTCN() {
 }
 
 func (p *StpPort) TxConfig() {
-	if p.handle != nil {
+	if p.b.IsMsti() {
+		p.TxMsti()
+		return
+	}
+	if !p.BpduFilterTx() {
+		return
+	}
+	if handle := p.txHandle(); handle != nil {
 		eth, llc := p.BuildRSTPEthernetLlcHeaders()
 
 		if p.b.Vlan != DEFAULT_STP_BRIDGE_VLAN {
 			p.TxPVST()
-			return
+			// Rapid-PVST+ VLAN 1 also sends the IEEE BPDU when the
+			// CST is not being run by the default bridge
+			if p.b.Vlan != PvstCstVlan ||
+				IsStpCstBridgePresent() {
+				return
+			}
 		}
 
 		stp := layers.STP{
@@ -327,7 +386,7 @@ func (p *StpPort) TxConfig() {
 		}
 		// Send one packet for every address.
 		gopacket.SerializeLayers(buf, opts, &eth, &llc, &stp)
-		if err := p.handle.WritePacketData(buf.Bytes()); err != nil {
+		if err := handle.WritePacketData(buf.Bytes()); err != nil {
 			StpLogger("ERROR", fmt.Sprintf("Error writing packet to interface %s\n", err))
 			return
 		}
@@ -0,0 +1,218 @@
+//
+//Copyright [2016] [SnapRoute Inc]
+//
+//Licensed under the Apache License, Version 2.0 (the "License");
+//you may not use this file except in compliance with the License.
+//You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+//	 Unless required by applicable law or agreed to in writing, software
+//	 distributed under the License is distributed on an "AS IS" BASIS,
+//	 WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+//	 See the License for the specific language governing permissions and
+//	 limitations under the License.
+//
+// _______  __       __________   ___      _______.____    __    ____  __  .___________.  ______  __    __
+// |   ____||  |     |   ____\  \ /  /     /       |\   \  /  \  /   / |  | |           | /      ||  |  |  |
+// |  |__   |  |     |  |__   \  V  /     |   (----` \   \/    \/   /  |  | `---|  |----`|  ,----'|  |__|  |
+// |   __|  |  |     |   __|   >   <       \   \      \            /   |  |     |  |     |  |     |   __   |
+// |  |     |  `----.|  |____ /  .  \  .----)   |      \    /\    /    |  |     |  |     |  `----.|  |  |  |
+// |__|     |_______||_______/__/ \__\ |_______/        \__/  \__/     |__|     |__|      \______||__|  |__|
+//
+
+// mstphandler
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	stp "l2/stp/protocol"
+	"l2/stp/server"
+	"models/objects"
+	"reflect"
+	"stpd"
+	"utils/dbutils"
+)
+
+func ConvertThriftMstRegionToStpMstRegionConfig(config *stpd.StpMstRegion, regionconfig *stp.StpMstRegionConfig) {
+	regionconfig.Name = config.Name
+	regionconfig.Revision = uint16(config.Revision)
+	regionconfig.MaxHops = uint8(config.MaxHops)
+}
+
+func ConvertThriftMstInstanceToStpMstiConfig(config *stpd.StpMstInstance, msticonfig *stp.StpMstiConfig) {
+	msticonfig.Mstid = uint16(config.Msti)
+	msticonfig.Priority = uint16(config.Priority)
+	msticonfig.Vlans = make([]uint16, 0)
+	for _, vid := range config.Vlans {
+		msticonfig.Vlans = append(msticonfig.Vlans, uint16(vid))
+	}
+}
+
+func (s *STPDServiceHandler) CreateStpMstRegion(config *stpd.StpMstRegion) (rv bool, err error) {
+
+	regionconfig := &stp.StpMstRegionConfig{}
+	ConvertThriftMstRegionToStpMstRegionConfig(config, regionconfig)
+
+	err = stp.StpMstRegionConfigParamCheck(regionconfig)
+	if err == nil {
+		if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+			stp.StpLogger("INFO", fmt.Sprintf("CreateStpMstRegion (server): name %s revision %d", config.Name, config.Revision))
+			cfg := server.STPConfig{
+				Msgtype: server.STPConfigMsgUpdateMstRegion,
+				Msgdata: regionconfig,
+			}
+			s.server.ConfigCh <- cfg
+		}
+		return true, err
+	}
+	return rv, err
+}
+
+// DeleteStpMstRegion will restore the default region name, revision and max hops
+func (s *STPDServiceHandler) DeleteStpMstRegion(config *stpd.StpMstRegion) (rv bool, err error) {
+	rv = true
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+		stp.StpLogger("INFO", "DeleteStpMstRegion (server): deleted ")
+		cfg := server.STPConfig{
+			Msgtype: server.STPConfigMsgUpdateMstRegion,
+			Msgdata: &stp.StpMstRegionConfig{},
+		}
+		s.server.ConfigCh <- cfg
+	}
+	return rv, err
+}
+
+func (s *STPDServiceHandler) UpdateStpMstRegion(origconfig *stpd.StpMstRegion, updateconfig *stpd.StpMstRegion, attrset []bool, op []*stpd.PatchOpInfo) (rv bool, err error) {
+	// name and revision are both part of the MST Configuration Identifier
+	// thus any change is applied as a whole
+	return s.CreateStpMstRegion(updateconfig)
+}
+
+func (s *STPDServiceHandler) CreateStpMstInstance(config *stpd.StpMstInstance) (rv bool, err error) {
+
+	msticonfig := &stp.StpMstiConfig{}
+	ConvertThriftMstInstanceToStpMstiConfig(config, msticonfig)
+
+	err = stp.StpMstiConfigParamCheck(msticonfig, true)
+	if err == nil {
+		if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+			stp.StpLogger("INFO", fmt.Sprintf("CreateStpMstInstance (server): msti %d priority %d vlans %v", config.Msti, config.Priority, config.Vlans))
+			cfg := server.STPConfig{
+				Msgtype: server.STPConfigMsgCreateMsti,
+				Msgdata: msticonfig,
+			}
+			s.server.ConfigCh <- cfg
+		}
+		return true, err
+	}
+	return rv, err
+}
+
+func (s *STPDServiceHandler) DeleteStpMstInstance(config *stpd.StpMstInstance) (rv bool, err error) {
+	rv = true
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE ||
+		stp.StpGlobalStateGet() == stp.STP_GLOBAL_DISABLE_PENDING {
+		stp.StpLogger("INFO", "DeleteStpMstInstance (server): deleted ")
+		msticonfig := &stp.StpMstiConfig{}
+		ConvertThriftMstInstanceToStpMstiConfig(config, msticonfig)
+		cfg := server.STPConfig{
+			Msgtype: server.STPConfigMsgDeleteMsti,
+			Msgdata: msticonfig,
+		}
+		s.server.ConfigCh <- cfg
+	}
+	return rv, err
+}
+
+func (s *STPDServiceHandler) UpdateStpMstInstance(origconfig *stpd.StpMstInstance, updateconfig *stpd.StpMstInstance, attrset []bool, op []*stpd.PatchOpInfo) (rv bool, err error) {
+	rv = true
+
+	var b *stp.Bridge
+	msticonfig := &stp.StpMstiConfig{}
+	objTyp := reflect.TypeOf(*origconfig)
+
+	// convert thrift struct to stp struct
+	ConvertThriftMstInstanceToStpMstiConfig(updateconfig, msticonfig)
+	// perform paramater checks to validate the config coming down
+	err = stp.StpMstiConfigParamCheck(msticonfig, false)
+	if err != nil {
+		return false, err
+	}
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+
+		// see if the msti exists
+		if !stp.StpFindMstiBridgeById(uint16(origconfig.Msti), &b) {
+			return false, errors.New("Unknown MSTI in update config")
+		}
+
+		// config message data
+		cfg := server.STPConfig{
+			Msgdata: msticonfig,
+		}
+
+		// attribute that user is allowed to update
+		attrMap := map[string]server.STPConfigMsgType{
+			"Priority": server.STPConfigMsgUpdateMstiPriority,
+			"Vlans":    server.STPConfigMsgUpdateMstiVlans,
+		}
+
+		for i := 0; i < objTyp.NumField(); i++ {
+			objName := objTyp.Field(i).Name
+			if attrset[i] {
+				stp.StpLogger("INFO", fmt.Sprintf("UpdateStpMstInstance (server): changed ", objName))
+
+				if msgtype, ok := attrMap[objName]; ok {
+					// set message type
+					cfg.Msgtype = msgtype
+					// send config message to server
+					s.server.ConfigCh <- cfg
+				}
+			}
+		}
+	}
+	return rv, err
+}
+
+func (s *STPDServiceHandler) HandleDbReadStpMstRegion(dbHdl *dbutils.DBUtil) error {
+	if dbHdl != nil {
+		var dbObj objects.StpMstRegion
+		objList, err := dbHdl.GetAllObjFromDb(dbObj)
+		if err != nil {
+			stp.StpLogger("ERROR", "DB Query failed when retrieving StpMstRegion objects")
+			return err
+		}
+		for idx := 0; idx < len(objList); idx++ {
+			obj := stpd.NewStpMstRegion()
+			dbObject := objList[idx].(objects.StpMstRegion)
+			objects.ConvertstpdStpMstRegionObjToThrift(&dbObject, obj)
+			_, err = s.CreateStpMstRegion(obj)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *STPDServiceHandler) HandleDbReadStpMstInstance(dbHdl *dbutils.DBUtil) error {
+	if dbHdl != nil {
+		var dbObj objects.StpMstInstance
+		objList, err := dbHdl.GetAllObjFromDb(dbObj)
+		if err != nil {
+			stp.StpLogger("ERROR", "DB Query failed when retrieving StpMstInstance objects")
+			return err
+		}
+		for idx := 0; idx < len(objList); idx++ {
+			obj := stpd.NewStpMstInstance()
+			dbObject := objList[idx].(objects.StpMstInstance)
+			objects.ConvertstpdStpMstInstanceObjToThrift(&dbObject, obj)
+			_, err = s.CreateStpMstInstance(obj)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
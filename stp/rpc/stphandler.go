@@ -62,6 +62,11 @@ func ConvertThriftBrgConfigToStpBrgConfig(config *stpd.StpBridgeInstance, brgcon
 	brgconfig.ForwardDelay = uint16(config.ForwardDelay)
 	brgconfig.ForceVersion = int32(config.ForceVersion)
 	brgconfig.TxHoldCount = int32(config.TxHoldCount)
+	if config.PathCostMethod == "short" {
+		brgconfig.PathCostMethod = stp.PathCostMethodShort
+	} else {
+		brgconfig.PathCostMethod = stp.PathCostMethodLong
+	}
 }
 
 // converts yang true(1)/false(2) to bool
@@ -98,6 +103,9 @@ func ConvertThriftPortConfigToStpPortConfig(config *stpd.StpPort, portconfig *st
 	portconfig.BridgeAssurance = ConvertInt32ToBool(config.BridgeAssurance)
 	portconfig.BpduGuard = ConvertInt32ToBool(config.BpduGuard)
 	portconfig.BpduGuardInterval = config.BpduGuardInterval
+	portconfig.RootGuard = ConvertInt32ToBool(config.RootGuard)
+	portconfig.LoopGuard = ConvertInt32ToBool(config.LoopGuard)
+	portconfig.BpduFilter = ConvertInt32ToBool(config.BpduFilter)
 }
 
 func ConvertBridgeIdToString(bridgeid stp.BridgeId) string {
@@ -146,21 +154,41 @@ func GetPortState(p *stp.StpPort) (state int32) {
 	*/
 	state = 0
 	//stp.StpLogger("INFO", fmt.Sprintf("PortEnabled[%t] Learning[%t] Forwarding[%t]", p.PortEnabled, p.Learning, p.Forwarding))
-	if !p.PortEnabled {
+	if !p.AdminPortEnabled {
 		state = 1
+	} else if !p.PortEnabled ||
+		GetPortInconsistency(p) != "None" {
+		// MAC_Operational FALSE or port excluded by a guard
+		state = 6
 	} else if p.Forwarding {
 		state = 5
 	} else if p.Learning {
 		state = 4
-	} else if p.PortEnabled &&
-		!p.Learning &&
-		!p.Forwarding {
+	} else if p.IsListening() {
+		state = 3
+	} else {
 		state = 2
 	}
-	// TODO need to determine how to set listening and broken states
 	return state
 }
 
+// GetPortInconsistency returns the reason a port has been excluded from the
+// active topology by a guard, otherwise None
+func GetPortInconsistency(p *stp.StpPort) string {
+	if p.ErrDisabled {
+		return stp.ErrDisableReasonStrMap[p.ErrDisableReason]
+	} else if p.BridgeAssuranceInconsistant {
+		return "Bridge Assurance"
+	} else if p.RootGuardInconsistant {
+		return "Root Guard"
+	} else if p.LoopGuardInconsistant {
+		return "Loop Guard"
+	} else if p.PvstInconsistency != stp.PvstInconsistencyNone {
+		return stp.PvstInconsistencyStrMap[p.PvstInconsistency]
+	}
+	return "None"
+}
+
 func (s *STPDServiceHandler) CreateStpGlobal(config *stpd.StpGlobal) (rv bool, err error) {
 	rv = true
 	stp.StpLogger("INFO", fmt.Sprintf("CreateStpGlobal (server): %s", config.AdminState))
@@ -172,6 +200,11 @@ func (s *STPDServiceHandler) CreateStpGlobal(config *stpd.StpGlobal) (rv bool, e
 	} else if config.AdminState == "DOWN" {
 		stp.StpGlobalStateSet(stp.STP_GLOBAL_DISABLE)
 	}
+	cfg := server.STPConfig{
+		Msgtype: server.STPConfigMsgUpdateGlobalBpduFilterEdge,
+		Msgdata: ConvertInt32ToBool(config.BpduFilterEdge),
+	}
+	s.server.ConfigCh <- cfg
 	return rv, err
 }
 
@@ -195,6 +228,13 @@ func (s *STPDServiceHandler) UpdateStpGlobal(origconfig *stpd.StpGlobal, updatec
 			stp.StpGlobalStateSet(stp.STP_GLOBAL_DISABLE)
 		}
 	}
+	if origconfig.BpduFilterEdge != updateconfig.BpduFilterEdge {
+		cfg := server.STPConfig{
+			Msgtype: server.STPConfigMsgUpdateGlobalBpduFilterEdge,
+			Msgdata: ConvertInt32ToBool(updateconfig.BpduFilterEdge),
+		}
+		s.server.ConfigCh <- cfg
+	}
 	return rv, err
 }
 
@@ -332,6 +372,16 @@ func (s *STPDServiceHandler) ReadConfigFromDB(prevState int) error {
 			stp.StpLogger("ERROR", fmt.Sprintf("Error getting All StpPort objects %s", err))
 			return err
 		}
+
+		if err = s.HandleDbReadStpMstRegion(dbHdl); err != nil {
+			stp.StpLogger("ERROR", fmt.Sprintf("Error getting All StpMstRegion objects %s", err))
+			return err
+		}
+
+		if err = s.HandleDbReadStpMstInstance(dbHdl); err != nil {
+			stp.StpLogger("ERROR", fmt.Sprintf("Error getting All StpMstInstance objects %s", err))
+			return err
+		}
 	} else if currState == stp.STP_GLOBAL_DISABLE_PENDING ||
 		prevState == stp.STP_GLOBAL_ENABLE {
 		// only need to delete the bridge instance
@@ -394,12 +444,13 @@ func (s *STPDServiceHandler) UpdateStpBridgeInstance(origconfig *stpd.StpBridgeI
 
 		// attribute that user is allowed to update
 		attrMap := map[string]server.STPConfigMsgType{
-			"MaxAge":       server.STPConfigMsgUpdateBridgeMaxAge,
-			"HelloTime":    server.STPConfigMsgUpdateBridgeHelloTime,
-			"ForwardDelay": server.STPConfigMsgUpdateBridgeForwardDelay,
-			"TxHoldCount":  server.STPConfigMsgUpdateBridgeTxHoldCount,
-			"Priority":     server.STPConfigMsgUpdateBridgePriority,
-			"ForceVersion": server.STPConfigMsgUpdateBridgeForceVersion,
+			"MaxAge":         server.STPConfigMsgUpdateBridgeMaxAge,
+			"HelloTime":      server.STPConfigMsgUpdateBridgeHelloTime,
+			"ForwardDelay":   server.STPConfigMsgUpdateBridgeForwardDelay,
+			"TxHoldCount":    server.STPConfigMsgUpdateBridgeTxHoldCount,
+			"Priority":       server.STPConfigMsgUpdateBridgePriority,
+			"ForceVersion":   server.STPConfigMsgUpdateBridgeForceVersion,
+			"PathCostMethod": server.STPConfigMsgUpdateBridgePathCostMethod,
 		}
 
 		// important to note that the attrset starts at index 0 which is the BaseObj
@@ -517,6 +568,9 @@ func (s *STPDServiceHandler) UpdateStpPort(origconfig *stpd.StpPort, updateconfi
 			"AdminPathCost":     server.STPConfigMsgUpdatePortAdminPathCost,
 			"BpduGuard":         server.STPConfigMsgUpdatePortBpduGuard,
 			"BridgeAssurance":   server.STPConfigMsgUpdatePortBridgeAssurance,
+			"RootGuard":         server.STPConfigMsgUpdatePortRootGuard,
+			"LoopGuard":         server.STPConfigMsgUpdatePortLoopGuard,
+			"BpduFilter":        server.STPConfigMsgUpdatePortBpduFilter,
 		}
 
 		// important to note that the attrset starts at index 0 which is the BaseObj
@@ -558,6 +612,28 @@ func (s *STPDServiceHandler) UpdateStpPort(origconfig *stpd.StpPort, updateconfi
 	return rv, err
 }
 
+// ExecuteActionStpPortErrDisableClear will manually recover an err-disabled port
+func (s *STPDServiceHandler) ExecuteActionStpPortErrDisableClear(config *stpd.StpPortErrDisableClear) (rv bool, err error) {
+	rv = true
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+		ifIndex := stp.GetIfIndexFromIntfRef(config.IntfRef)
+		brgIfIndex := int32(config.Vlan)
+		var p *stp.StpPort
+		if !stp.StpFindPortByIfIndex(ifIndex, brgIfIndex, &p) {
+			return false, errors.New(fmt.Sprintf("Invalid port %s or bridge %d supplied for clearing err-disable", config.IntfRef, config.Vlan))
+		}
+		cfg := server.STPConfig{
+			Msgtype: server.STPConfigMsgClearPortErrDisable,
+			Msgdata: &stp.StpPortConfig{
+				IfIndex:    ifIndex,
+				BrgIfIndex: brgIfIndex,
+			},
+		}
+		s.server.ConfigCh <- cfg
+	}
+	return rv, err
+}
+
 func (s *STPDServiceHandler) GetStpBridgeInstanceState(vlan int16) (*stpd.StpBridgeInstanceState, error) {
 	sbs := &stpd.StpBridgeInstanceState{}
 
@@ -570,14 +646,18 @@ func (s *STPDServiceHandler) GetStpBridgeInstanceState(vlan int16) (*stpd.StpBri
 		if stp.StpFindBridgeById(key, &b) {
 			sbs.BridgeHelloTime = int32(b.BridgeTimes.HelloTime)
 			sbs.TxHoldCount = stp.TransmitHoldCountDefault
+			sbs.PathCostMethod = stp.PathCostMethodStrMap[b.PathCostMethod]
 			sbs.BridgeForwardDelay = int32(b.BridgeTimes.ForwardingDelay)
 			sbs.BridgeMaxAge = int32(b.BridgeTimes.MaxAge)
 			sbs.Address = ConvertAddrToString(stp.GetBridgeAddrFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 			sbs.Priority = int32(stp.GetBridgePriorityFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 			sbs.Vlan = int16(b.BrgIfIndex)
 			sbs.ProtocolSpecification = 2
-			//nextStpBridgeInstanceState.TimeSinceTopologyChange uint32 //The time (in hundredths of a second) since the last time a topology change was detected by the bridge entity. For RSTP, this reports the time since the tcWhile timer for any port on this Bridge was nonzero.
-			//nextStpBridgeInstanceState.TopChanges              uint32 //The total number of topology changes detected by this bridge since the management entity was last reset or initialized.
+			tccount, _, tcifindex, tcsender := b.TcHistoryStats()
+			sbs.TimeSinceTopologyChange = b.TcTimeSinceLast()
+			sbs.TopChanges = tccount
+			sbs.TcLastRcvdPort = stp.GetPortNameFromIfIndex(tcifindex)
+			sbs.TcLastRcvdBridgeId = ConvertBridgeIdToString(tcsender)
 			sbs.DesignatedRoot = ConvertBridgeIdToString(b.BridgePriority.RootBridgeId)
 			sbs.RootCost = int32(b.BridgePriority.RootPathCost)
 			sbs.RootPort = int32(b.BridgePriority.DesignatedPortId)
@@ -613,14 +693,18 @@ func (s *STPDServiceHandler) GetBulkStpBridgeInstanceState(fromIndex stpd.Int, c
 			nextStpBridgeInstanceState = &StpBridgeInstanceStateList[validCount]
 			nextStpBridgeInstanceState.BridgeHelloTime = int32(b.BridgeTimes.HelloTime)
 			nextStpBridgeInstanceState.TxHoldCount = stp.TransmitHoldCountDefault
+			nextStpBridgeInstanceState.PathCostMethod = stp.PathCostMethodStrMap[b.PathCostMethod]
 			nextStpBridgeInstanceState.BridgeForwardDelay = int32(b.BridgeTimes.ForwardingDelay)
 			nextStpBridgeInstanceState.BridgeMaxAge = int32(b.BridgeTimes.MaxAge)
 			nextStpBridgeInstanceState.Address = ConvertAddrToString(stp.GetBridgeAddrFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 			nextStpBridgeInstanceState.Priority = int32(stp.GetBridgePriorityFromBridgeId(b.BridgePriority.DesignatedBridgeId))
 			nextStpBridgeInstanceState.Vlan = int16(b.BrgIfIndex)
 			nextStpBridgeInstanceState.ProtocolSpecification = 2
-			//nextStpBridgeInstanceState.TimeSinceTopologyChange uint32 //The time (in hundredths of a second) since the last time a topology change was detected by the bridge entity. For RSTP, this reports the time since the tcWhile timer for any port on this Bridge was nonzero.
-			//nextStpBridgeInstanceState.TopChanges              uint32 //The total number of topology changes detected by this bridge since the management entity was last reset or initialized.
+			tccount, _, tcifindex, tcsender := b.TcHistoryStats()
+			nextStpBridgeInstanceState.TimeSinceTopologyChange = b.TcTimeSinceLast()
+			nextStpBridgeInstanceState.TopChanges = tccount
+			nextStpBridgeInstanceState.TcLastRcvdPort = stp.GetPortNameFromIfIndex(tcifindex)
+			nextStpBridgeInstanceState.TcLastRcvdBridgeId = ConvertBridgeIdToString(tcsender)
 			nextStpBridgeInstanceState.DesignatedRoot = ConvertBridgeIdToString(b.BridgePriority.RootBridgeId)
 			nextStpBridgeInstanceState.RootCost = int32(b.BridgePriority.RootPathCost)
 			nextStpBridgeInstanceState.RootPort = int32(b.BridgePriority.DesignatedPortId)
@@ -654,6 +738,80 @@ func (s *STPDServiceHandler) GetBulkStpBridgeInstanceState(fromIndex stpd.Int, c
 	return obj, nil
 }
 
+// convertTcHistoryEntry will fill in the thrift tc history state from the stp history entry
+func convertTcHistoryEntry(b *stp.Bridge, e stp.TcHistoryEntry, ths *stpd.StpTcHistoryState) {
+	ths.Vlan = int16(b.Vlan)
+	ths.Index = int32(e.Seq)
+	ths.Time = e.Time.String()
+	ths.Type = stp.TcHistoryTypeStrMap[e.Type]
+	ths.IntfRef = stp.GetPortNameFromIfIndex(e.IfIndex)
+	ths.SenderBridgeId = ConvertBridgeIdToString(e.SenderBridgeId)
+}
+
+// GetStpTcHistoryState will return a single entry from the topology change history of a bridge,
+// index is the sequence number of the entry
+func (s *STPDServiceHandler) GetStpTcHistoryState(vlan int16, index int32) (*stpd.StpTcHistoryState, error) {
+	ths := &stpd.StpTcHistoryState{}
+
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+
+		key := stp.BridgeKey{
+			Vlan: uint16(vlan),
+		}
+		var b *stp.Bridge
+		if !stp.StpFindBridgeById(key, &b) {
+			return ths, errors.New(fmt.Sprintf("STP: Error could not find bridge vlan %d", vlan))
+		}
+		e, ok := b.TcHistoryFind(uint32(index))
+		if index < 0 || !ok {
+			return ths, errors.New(fmt.Sprintf("STP: Error could not find tc history index %d for bridge vlan %d", index, vlan))
+		}
+		convertTcHistoryEntry(b, e, ths)
+	}
+	return ths, nil
+}
+
+// GetBulkStpTcHistoryState will dump the topology change history of all the stp bridges, oldest entry first
+func (s *STPDServiceHandler) GetBulkStpTcHistoryState(fromIndex stpd.Int, count stpd.Int) (obj *stpd.StpTcHistoryStateGetInfo, err error) {
+	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
+
+		var StpTcHistoryStateList []stpd.StpTcHistoryState = make([]stpd.StpTcHistoryState, count)
+		var nextStpTcHistoryState *stpd.StpTcHistoryState
+		var returnStpTcHistoryStates []*stpd.StpTcHistoryState
+		var returnStpTcHistoryStateGetInfo stpd.StpTcHistoryStateGetInfo
+		validCount := stpd.Int(0)
+		toIndex := fromIndex
+		obj = &returnStpTcHistoryStateGetInfo
+		currIndex := stpd.Int(0)
+		for _, b := range stp.BridgeListTable {
+			for _, e := range b.TcHistoryGet() {
+				if currIndex >= fromIndex && validCount != count {
+					nextStpTcHistoryState = &StpTcHistoryStateList[validCount]
+					convertTcHistoryEntry(b, e, nextStpTcHistoryState)
+					if len(returnStpTcHistoryStates) == 0 {
+						returnStpTcHistoryStates = make([]*stpd.StpTcHistoryState, 0)
+					}
+					returnStpTcHistoryStates = append(returnStpTcHistoryStates, nextStpTcHistoryState)
+					validCount++
+					toIndex++
+				}
+				currIndex++
+			}
+		}
+		moreRoutes := false
+		if fromIndex+count < currIndex {
+			moreRoutes = true
+		}
+
+		obj.StpTcHistoryStateList = returnStpTcHistoryStates
+		obj.StartIdx = fromIndex
+		obj.EndIdx = toIndex + 1
+		obj.More = moreRoutes
+		obj.Count = validCount
+	}
+	return obj, nil
+}
+
 func (s *STPDServiceHandler) GetStpPortState(vlan int32, intfRef string) (*stpd.StpPortState, error) {
 	sps := &stpd.StpPortState{}
 	if stp.StpGlobalStateGet() == stp.STP_GLOBAL_ENABLE {
@@ -675,6 +833,8 @@ func (s *STPDServiceHandler) GetStpPortState(vlan int32, intfRef string) (*stpd.
 			sps.DesignatedBridge = stp.CreateBridgeIdStr(p.PortPriority.DesignatedBridgeId)
 			//nextStpPortState.AdminPointToPoint  int32(p.)  //The administrative point-to-point status of the LAN segment attached to this port, using the enumeration values of the IEEE 802.1w clause.  A value of forceTrue(0) indicates that this port should always be treated as if it is connected to a point-to-point link.  A value of forceFalse(1) indicates that this port should be treated as having a shared media connection.  A value of auto(2) indicates that this port is considered to have a point-to-point link if it is an Aggregator and all of its    members are aggregatable, or if the MAC entity is configured for full duplex operation, either through auto-negotiation or by management means.  Manipulating this object changes the underlying adminPortToPortMAC.  The value of this object MUST be retained across reinitializations of the management system.
 			sps.State = GetPortState(p)
+			sps.Role = stp.PortRoleStrMap[p.Role]
+			sps.Inconsistency = GetPortInconsistency(p)
 			sps.Enable = ConvertBoolToInt32(p.PortEnabled)
 			sps.DesignatedRoot = stp.CreateBridgeIdStr(p.PortPriority.RootBridgeId)
 			sps.DesignatedCost = int32(p.PortPathCost)
@@ -683,9 +843,19 @@ func (s *STPDServiceHandler) GetStpPortState(vlan int32, intfRef string) (*stpd.
 			// Bridge Assurance
 			sps.BridgeAssuranceInconsistant = ConvertBoolToInt32(p.BridgeAssuranceInconsistant)
 			sps.BridgeAssurance = ConvertBoolToInt32(p.BridgeAssurance)
+			sps.RootGuard = ConvertBoolToInt32(p.RootGuard)
+			sps.RootGuardInconsistant = ConvertBoolToInt32(p.RootGuardInconsistant)
+			sps.LoopGuard = ConvertBoolToInt32(p.LoopGuard)
+			sps.LoopGuardInconsistant = ConvertBoolToInt32(p.LoopGuardInconsistant)
+			sps.BpduFilter = ConvertBoolToInt32(p.BpduFilter)
+			sps.BpduFilterActive = ConvertBoolToInt32(p.BpduFilter || p.IsBpduFilterEdgeActive())
 			// Bpdu Guard
 			sps.BpduGuard = ConvertBoolToInt32(p.BpduGuard)
-			sps.BpduGuardDetected = ConvertBoolToInt32(p.BPDUGuardTimer.GetCount() != 0)
+			sps.BpduGuardDetected = ConvertBoolToInt32(p.ErrDisableReason == stp.ErrDisableReasonBpduGuard)
+			// Err-Disable
+			sps.ErrDisabled = ConvertBoolToInt32(p.ErrDisabled)
+			sps.ErrDisableReason = stp.ErrDisableReasonStrMap[p.ErrDisableReason]
+			sps.ErrDisableRecoveryTime = p.BPDUGuardTimer.GetCount()
 			// root timers
 			sps.MaxAge = int32(p.PortTimes.MaxAge)
 			sps.ForwardDelay = int32(p.PortTimes.ForwardingDelay)
@@ -776,6 +946,8 @@ func (s *STPDServiceHandler) GetBulkStpPortState(fromIndex stpd.Int, count stpd.
 			nextStpPortState.DesignatedBridge = stp.CreateBridgeIdStr(p.PortPriority.DesignatedBridgeId)
 			//nextStpPortState.AdminPointToPoint  int32(p.)  //The administrative point-to-point status of the LAN segment attached to this port, using the enumeration values of the IEEE 802.1w clause.  A value of forceTrue(0) indicates that this port should always be treated as if it is connected to a point-to-point link.  A value of forceFalse(1) indicates that this port should be treated as having a shared media connection.  A value of auto(2) indicates that this port is considered to have a point-to-point link if it is an Aggregator and all of its    members are aggregatable, or if the MAC entity is configured for full duplex operation, either through auto-negotiation or by management means.  Manipulating this object changes the underlying adminPortToPortMAC.  The value of this object MUST be retained across reinitializations of the management system.
 			nextStpPortState.State = GetPortState(p)
+			nextStpPortState.Role = stp.PortRoleStrMap[p.Role]
+			nextStpPortState.Inconsistency = GetPortInconsistency(p)
 			nextStpPortState.Enable = ConvertBoolToInt32(p.PortEnabled)
 			nextStpPortState.DesignatedRoot = stp.CreateBridgeIdStr(p.PortPriority.RootBridgeId)
 			nextStpPortState.DesignatedCost = int32(p.PortPathCost)
@@ -784,9 +956,19 @@ func (s *STPDServiceHandler) GetBulkStpPortState(fromIndex stpd.Int, count stpd.
 			// Bridge Assurance
 			nextStpPortState.BridgeAssuranceInconsistant = ConvertBoolToInt32(p.BridgeAssuranceInconsistant)
 			nextStpPortState.BridgeAssurance = ConvertBoolToInt32(p.BridgeAssurance)
+			nextStpPortState.RootGuard = ConvertBoolToInt32(p.RootGuard)
+			nextStpPortState.RootGuardInconsistant = ConvertBoolToInt32(p.RootGuardInconsistant)
+			nextStpPortState.LoopGuard = ConvertBoolToInt32(p.LoopGuard)
+			nextStpPortState.LoopGuardInconsistant = ConvertBoolToInt32(p.LoopGuardInconsistant)
+			nextStpPortState.BpduFilter = ConvertBoolToInt32(p.BpduFilter)
+			nextStpPortState.BpduFilterActive = ConvertBoolToInt32(p.BpduFilter || p.IsBpduFilterEdgeActive())
 			// Bpdu Guard
 			nextStpPortState.BpduGuard = ConvertBoolToInt32(p.BpduGuard)
-			nextStpPortState.BpduGuardDetected = ConvertBoolToInt32(p.BPDUGuardTimer.GetCount() != 0)
+			nextStpPortState.BpduGuardDetected = ConvertBoolToInt32(p.ErrDisableReason == stp.ErrDisableReasonBpduGuard)
+			// Err-Disable
+			nextStpPortState.ErrDisabled = ConvertBoolToInt32(p.ErrDisabled)
+			nextStpPortState.ErrDisableReason = stp.ErrDisableReasonStrMap[p.ErrDisableReason]
+			nextStpPortState.ErrDisableRecoveryTime = p.BPDUGuardTimer.GetCount()
 			// root timers
 			nextStpPortState.MaxAge = int32(p.PortTimes.MaxAge)
 			nextStpPortState.ForwardDelay = int32(p.PortTimes.ForwardingDelay)
@@ -912,6 +1094,7 @@ func (s *STPDServiceHandler) GetBulkStpBridgeInstance(fromIndex stpd.Int, count
 		nextStpBridgeInstance.ForwardDelay = int32(15)
 		nextStpBridgeInstance.ForceVersion = int32(2)
 		nextStpBridgeInstance.TxHoldCount = int32(6)
+		nextStpBridgeInstance.PathCostMethod = "long"
 		// lets create the object in the stack now
 		// we are going to create based on CONFD creating StpGlobal
 		//s.CreateStpPort(nextStpPort)
@@ -1004,6 +1187,9 @@ func (s *STPDServiceHandler) GetBulkStpPort(fromIndex stpd.Int, count stpd.Int)
 		nextStpPort.BpduGuard = int32(2)
 		nextStpPort.BpduGuardInterval = int32(15)
 		nextStpPort.BridgeAssurance = int32(2)
+		nextStpPort.RootGuard = int32(2)
+		nextStpPort.LoopGuard = int32(2)
+		nextStpPort.BpduFilter = int32(2)
 
 		// lets create the object in the stack now
 		// we are going to create based on CONFD creating StpGlobal
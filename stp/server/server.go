@@ -5,6 +5,8 @@ import (
 	"fmt"
 	stp "l2/stp/protocol"
 	"utils/commonDefs"
+	"utils/dbutils"
+	"utils/eventUtils"
 	"utils/logging"
 )
 
@@ -33,6 +35,17 @@ const (
 	STPConfigMsgUpdatePortBridgeAssurance
 	STPConfigMsgGlobalEnable
 	STPConfigMsgGlobalDisable
+	STPConfigMsgUpdateMstRegion
+	STPConfigMsgCreateMsti
+	STPConfigMsgDeleteMsti
+	STPConfigMsgUpdateMstiPriority
+	STPConfigMsgUpdateMstiVlans
+	STPConfigMsgUpdatePortRootGuard
+	STPConfigMsgUpdatePortLoopGuard
+	STPConfigMsgUpdatePortBpduFilter
+	STPConfigMsgUpdateGlobalBpduFilterEdge
+	STPConfigMsgClearPortErrDisable
+	STPConfigMsgUpdateBridgePathCostMethod
 )
 
 type STPConfig struct {
@@ -44,6 +57,7 @@ type STPServer struct {
 	logger           *logging.Writer
 	ConfigCh         chan STPConfig
 	AsicdSubSocketCh chan commonDefs.AsicdNotifyMsg
+	eventDbHdl       *dbutils.DBUtil
 }
 
 func NewSTPServer(logger *logging.Writer) *STPServer {
@@ -57,11 +71,28 @@ func NewSTPServer(logger *logging.Writer) *STPServer {
 func (server *STPServer) InitServer() {
 	//stp.ConnectToClients()
 	stp.ConstructPortConfigMap()
+
+	err := server.initializeEvents()
+	if err != nil {
+		stp.StpLogger("ERROR", "Error initializing Event Db")
+	}
 	// TODO
 	//go server.ListenToClientStateChanges()
 	server.StartSTPSConfigNotificationListener()
 }
 
+func (server *STPServer) initializeEvents() error {
+	logger := stp.GetStpLogger()
+	server.eventDbHdl = dbutils.NewDBUtil(logger)
+	err := server.eventDbHdl.Connect()
+	if err != nil {
+		stp.StpLogger("ERROR", "Failed to create the DB handle")
+		return err
+	}
+
+	return eventUtils.InitEvents("STPD", server.eventDbHdl, server.eventDbHdl, logger, 1000)
+}
+
 /*
 TODO
 func (server *STPServer) ListenToClientStateChanges() {
@@ -213,6 +244,60 @@ func (server *STPServer) processStpConfig(conf STPConfig) {
 				stp.StpLogger("INFO", "CONFIG: Disable STP Global")
 				StpGlobalStateSet(false)
 		*/
+
+	case STPConfigMsgUpdateMstRegion:
+		stp.StpLogger("INFO", "CONFIG: MST Region")
+		config := conf.Msgdata.(*stp.StpMstRegionConfig)
+		stp.StpMstRegionConfigSet(config)
+
+	case STPConfigMsgCreateMsti:
+		stp.StpLogger("INFO", "CONFIG: MSTI Create")
+		config := conf.Msgdata.(*stp.StpMstiConfig)
+		stp.StpMstiCreate(config)
+
+	case STPConfigMsgDeleteMsti:
+		stp.StpLogger("INFO", "CONFIG: MSTI Delete")
+		config := conf.Msgdata.(*stp.StpMstiConfig)
+		stp.StpMstiDelete(config)
+
+	case STPConfigMsgUpdateMstiPriority:
+		stp.StpLogger("INFO", "CONFIG: MSTI Priority")
+		config := conf.Msgdata.(*stp.StpMstiConfig)
+		stp.StpMstiPrioritySet(config.Mstid, config.Priority)
+
+	case STPConfigMsgUpdateMstiVlans:
+		stp.StpLogger("INFO", "CONFIG: MSTI Vlans")
+		config := conf.Msgdata.(*stp.StpMstiConfig)
+		stp.StpMstiVlanSet(config.Mstid, config.Vlans)
+
+	case STPConfigMsgUpdatePortRootGuard:
+		stp.StpLogger("INFO", "CONFIG: Port Root Guard")
+		config := conf.Msgdata.(*stp.StpPortConfig)
+		stp.StpPortRootGuardSet(config.IfIndex, config.BrgIfIndex, config.RootGuard)
+
+	case STPConfigMsgUpdatePortLoopGuard:
+		stp.StpLogger("INFO", "CONFIG: Port Loop Guard")
+		config := conf.Msgdata.(*stp.StpPortConfig)
+		stp.StpPortLoopGuardSet(config.IfIndex, config.BrgIfIndex, config.LoopGuard)
+
+	case STPConfigMsgUpdatePortBpduFilter:
+		stp.StpLogger("INFO", "CONFIG: Port BPDU Filter")
+		config := conf.Msgdata.(*stp.StpPortConfig)
+		stp.StpPortBpduFilterSet(config.IfIndex, config.BrgIfIndex, config.BpduFilter)
+
+	case STPConfigMsgUpdateGlobalBpduFilterEdge:
+		stp.StpLogger("INFO", "CONFIG: Global Edge BPDU Filter")
+		stp.StpGlobalBpduFilterEdgeSet(conf.Msgdata.(bool))
+
+	case STPConfigMsgClearPortErrDisable:
+		stp.StpLogger("INFO", "CONFIG: Port Err-Disable Clear")
+		config := conf.Msgdata.(*stp.StpPortConfig)
+		stp.StpPortErrDisableClear(config.IfIndex, config.BrgIfIndex)
+
+	case STPConfigMsgUpdateBridgePathCostMethod:
+		stp.StpLogger("INFO", "CONFIG: Bridge Set Path Cost Method")
+		config := conf.Msgdata.(*stp.StpBridgeConfig)
+		stp.StpBrgPathCostMethodSet(config.IfIndex, config.PathCostMethod)
 	}
 }
 
@@ -233,8 +318,35 @@ func (server *STPServer) processAsicdNotification(msg commonDefs.AsicdNotifyMsg)
 		fmt.Printf("Msg linkstatus = %d msg port = %d\n", l2Msg.IfState, l2Msg.IfIndex)
 		if l2Msg.IfState == asicdCommonDefs.INTF_STATE_DOWN {
 			processLinkDownEvent(asicdCommonDefs.GetIntfIdFromIfIndex(l2Msg.IfIndex)) //asicd always sends out link State events for PHY ports
+			stp.StpPortLinkStateSet(l2Msg.IfIndex, false)
 		} else {
+			// speed may have been renegotiated
+			stp.StpPortLinkStateSet(l2Msg.IfIndex, true)
+			stp.StpPortSpeedRefresh(l2Msg.IfIndex)
 			processLinkUpEvent(asicdCommonDefs.GetIntfIdFromIfIndex(l2Msg.IfIndex))
 		}
+	case commonDefs.LagNotifyMsg:
+		lagMsg := msg.(commonDefs.LagNotifyMsg)
+		stp.StpLogger("INFO", fmt.Sprintf("Msg lag = %d members %v", lagMsg.IfIndex, lagMsg.IfIndexList))
+		if lagMsg.MsgType == commonDefs.NOTIFY_LAG_DELETE {
+			stp.StpLagMembersSet(lagMsg.IfIndex, nil)
+		} else {
+			stp.StpLagMembersSet(lagMsg.IfIndex, lagMsg.IfIndexList)
+		}
+	case commonDefs.VlanNotifyMsg:
+		vlanMsg := msg.(commonDefs.VlanNotifyMsg)
+		stp.StpLogger("INFO", fmt.Sprintf("Msg vlan = %d tagged %v untagged %v", vlanMsg.VlanId, vlanMsg.TagPorts, vlanMsg.UntagPorts))
+		// pvid/access information is needed for Rapid-PVST+ native vlan handling
+		stp.StpVlanMembershipSet(uint16(vlanMsg.VlanId),
+			convertVlanPortList(vlanMsg.TagPorts),
+			convertVlanPortList(vlanMsg.UntagPorts),
+			vlanMsg.MsgType == commonDefs.NOTIFY_VLAN_DELETE)
+	}
+}
+
+func convertVlanPortList(ifindexList []int32) (portList []int32) {
+	for _, ifindex := range ifindexList {
+		portList = append(portList, int32(asicdCommonDefs.GetIntfIdFromIfIndex(ifindex)))
 	}
+	return portList
 }